@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	domain "shop-ops/Domain"
+	usecases "shop-ops/Usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyController lets owners issue and revoke keys for integrations.
+type APIKeyController struct {
+	apiKeyUseCases usecases.APIKeyUseCases
+}
+
+func NewAPIKeyController(a usecases.APIKeyUseCases) *APIKeyController {
+	return &APIKeyController{apiKeyUseCases: a}
+}
+
+// CreateAPIKey handles POST /businesses/:businessId/api-keys. The key is in
+// the response only this once.
+func (c *APIKeyController) CreateAPIKey(ctx *gin.Context) {
+	var req domain.CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	key, err := c.apiKeyUseCases.CreateAPIKey(ctx.Param("businessId"), ctx.GetString("user_id"), &req)
+	if err != nil {
+		apiKeyError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys handles GET /businesses/:businessId/api-keys.
+func (c *APIKeyController) ListAPIKeys(ctx *gin.Context) {
+	keys, err := c.apiKeyUseCases.ListAPIKeys(ctx.Param("businessId"))
+	if err != nil {
+		apiKeyError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey handles DELETE /businesses/:businessId/api-keys/:keyId.
+func (c *APIKeyController) RevokeAPIKey(ctx *gin.Context) {
+	keyId := ctx.Param("keyId")
+	if err := c.apiKeyUseCases.RevokeAPIKey(ctx.Param("businessId"), keyId); err != nil {
+		apiKeyError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully", "key_id": keyId})
+}
+
+// apiKeyError maps API key errors to responses
+func apiKeyError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrAPIKeyNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "KEY_001"})
+	case errors.Is(err, domain.ErrAPIKeyNameRequired), errors.Is(err, domain.ErrInvalidAPIKeyScopes):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+	case errors.Is(err, domain.ErrBusinessNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Business not found", "code": "BIZ_001"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to manage API keys", "code": "SYS_001"})
+	}
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
+	usecases "shop-ops/Usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deviceIDHeader lets the apps say which device made a change.
+const deviceIDHeader = "X-Device-ID"
+
+// AuditController serves a business's audit log.
+type AuditController struct {
+	auditUseCases usecases.AuditUseCases
+}
+
+func NewAuditController(a usecases.AuditUseCases) *AuditController {
+	return &AuditController{auditUseCases: a}
+}
+
+// ListAudit handles GET /businesses/:businessId/audit.
+// Filters: actor_id, entity_type, entity_id, action, source, device_id,
+// start_date and end_date (YYYY-MM-DD), page and limit.
+func (c *AuditController) ListAudit(ctx *gin.Context) {
+	query := domain.AuditQuery{
+		BusinessID: ctx.Param("businessId"),
+		ActorID:    ctx.Query("actor_id"),
+		EntityType: domain.AuditEntityType(ctx.Query("entity_type")),
+		EntityID:   ctx.Query("entity_id"),
+		Action:     domain.AuditAction(ctx.Query("action")),
+		Source:     domain.AuditSource(ctx.Query("source")),
+		DeviceID:   ctx.Query("device_id"),
+	}
+	query.Page, _ = strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	query.Limit, _ = strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(domain.DefaultAuditPageSize)))
+
+	if startDate := ctx.Query("start_date"); startDate != "" {
+		from, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format, use YYYY-MM-DD", "code": "VAL_001"})
+			return
+		}
+		query.From = &from
+	}
+	if endDate := ctx.Query("end_date"); endDate != "" {
+		to, err := time.Parse("2006-01-02", endDate)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format, use YYYY-MM-DD", "code": "VAL_001"})
+			return
+		}
+		to = to.Add(24*time.Hour - time.Nanosecond)
+		query.To = &to
+	}
+
+	list, err := c.auditUseCases.ListEntries(query)
+	if errors.Is(err, domain.ErrInvalidAuditRange) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log", "code": "SYS_001"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// auditActor describes the caller of a mutating request for the audit log.
+func auditActor(ctx *gin.Context) domain.AuditActor {
+	return domain.AuditActor{
+		UserID:    ctx.GetString("user_id"),
+		Role:      infrastructure.BusinessRole(ctx),
+		RequestID: infrastructure.RequestID(ctx),
+		DeviceID:  ctx.GetHeader(deviceIDHeader),
+		Source:    domain.AuditSourceAPI,
+	}
+}
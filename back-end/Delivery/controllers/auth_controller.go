@@ -1,10 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"net/mail"
 	"regexp"
 
+	domain "shop-ops/Domain"
 	usecases "shop-ops/Usecases"
 
 	"github.com/gin-gonic/gin"
@@ -51,6 +53,10 @@ func (c *AuthController) Register(ctx *gin.Context) {
 	}
 
 	user, err := c.userUseCases.Register(&req)
+	if isInvitationError(err) {
+		invitationError(ctx, err)
+		return
+	}
 	if err != nil {
 		status := http.StatusBadRequest
 		if err.Error() == "user with this phone already exists" || err.Error() == "user with this email already exists" {
@@ -67,13 +73,17 @@ func (c *AuthController) Login(ctx *gin.Context) {
 	var req struct {
 		Phone    string `json:"phone" binding:"required"`
 		Password string `json:"password" binding:"required"`
+		// DeviceName labels the session in the user's session list
+		DeviceName string `json:"device_name"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Phone and password are required", "code": "VAL_002"})
 		return
 	}
 
-	resp, err := c.userUseCases.Login(req.Phone, req.Password)
+	client := sessionClient(ctx)
+	client.DeviceName = req.DeviceName
+	resp, err := c.userUseCases.Login(req.Phone, req.Password, client)
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials", "code": "AUTH_002"})
 		return
@@ -91,7 +101,7 @@ func (c *AuthController) RefreshToken(ctx *gin.Context) {
 		return
 	}
 
-	resp, err := c.userUseCases.RefreshToken(req.RefreshToken)
+	resp, err := c.userUseCases.RefreshToken(req.RefreshToken, sessionClient(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "AUTH_003"})
 		return
@@ -99,3 +109,161 @@ func (c *AuthController) RefreshToken(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, resp)
 }
+
+// Logout revokes the refresh token in the body and every token rotated from
+// the same login.
+func (c *AuthController) Logout(ctx *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token is required", "code": "VAL_003"})
+		return
+	}
+
+	if err := c.userUseCases.Logout(req.RefreshToken); err != nil {
+		if errors.Is(err, domain.ErrInvalidRefreshToken) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "AUTH_003"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out", "code": "SYS_001"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// sessionClient describes the device making an auth request.
+func sessionClient(ctx *gin.Context) domain.SessionClient {
+	return domain.SessionClient{
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
+}
+
+// ForgotPassword sends a password reset code. The response is the same
+// whether or not the account exists.
+func (c *AuthController) ForgotPassword(ctx *gin.Context) {
+	var req usecases.ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || (req.Phone == "" && req.Email == "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone or email is required", "code": "VAL_002"})
+		return
+	}
+
+	if err := c.userUseCases.ForgotPassword(&req); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset code", "code": "SYS_001"})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a reset code has been sent"})
+}
+
+func (c *AuthController) ResetPassword(ctx *gin.Context) {
+	var req usecases.ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+	if (req.Phone == "" && req.Email == "") || req.Code == "" || req.NewPassword == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone or email, code and new_password are required", "code": "VAL_002"})
+		return
+	}
+
+	if err := c.userUseCases.ResetPassword(&req); err != nil {
+		otpError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// ResendPhoneVerification sends the registration code for a phone again.
+func (c *AuthController) ResendPhoneVerification(ctx *gin.Context) {
+	var req struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone is required", "code": "VAL_002"})
+		return
+	}
+
+	if err := c.userUseCases.SendPhoneVerification(req.Phone); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code", "code": "SYS_001"})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "If the phone is awaiting verification, a code has been sent"})
+}
+
+func (c *AuthController) VerifyPhone(ctx *gin.Context) {
+	var req struct {
+		Phone string `json:"phone" binding:"required"`
+		Code  string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone and code are required", "code": "VAL_002"})
+		return
+	}
+
+	user, err := c.userUseCases.VerifyPhone(req.Phone, req.Code)
+	if err != nil {
+		otpError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// RequestLoginCode sends a passwordless login code to a verified phone.
+func (c *AuthController) RequestLoginCode(ctx *gin.Context) {
+	var req struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone is required", "code": "VAL_002"})
+		return
+	}
+
+	if err := c.userUseCases.RequestLoginCode(req.Phone); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send login code", "code": "SYS_001"})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "If the phone is registered and verified, a login code has been sent"})
+}
+
+func (c *AuthController) LoginWithOTP(ctx *gin.Context) {
+	var req struct {
+		Phone      string `json:"phone" binding:"required"`
+		Code       string `json:"code" binding:"required"`
+		DeviceName string `json:"device_name"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "phone and code are required", "code": "VAL_002"})
+		return
+	}
+
+	client := sessionClient(ctx)
+	client.DeviceName = req.DeviceName
+	resp, err := c.userUseCases.LoginWithOTP(req.Phone, req.Code, client)
+	if err != nil {
+		otpError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// otpError maps one-time code failures to responses.
+func otpError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidOTP):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "OTP_001"})
+	case errors.Is(err, domain.ErrOTPAttemptsExceeded):
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "code": "OTP_002"})
+	case err.Error() == "new password must be at least 8 characters":
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_002"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code", "code": "SYS_001"})
+	}
+}
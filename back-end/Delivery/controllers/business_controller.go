@@ -3,6 +3,8 @@ package controllers
 import (
 	"net/http"
 
+	domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
 	usecases "shop-ops/Usecases"
 
 	"github.com/gin-gonic/gin"
@@ -62,12 +64,14 @@ var supportedCurrencyList = func() []string {
 }()
 
 type BusinessController struct {
-	businessUseCases usecases.BusinessUseCases
+	businessUseCases   usecases.BusinessUseCases
+	membershipUseCases usecases.MembershipUseCases
 }
 
-func NewBusinessController(b usecases.BusinessUseCases) *BusinessController {
+func NewBusinessController(b usecases.BusinessUseCases, m usecases.MembershipUseCases) *BusinessController {
 	return &BusinessController{
-		businessUseCases: b,
+		businessUseCases:   b,
+		membershipUseCases: m,
 	}
 }
 
@@ -107,7 +111,7 @@ func (c *BusinessController) List(ctx *gin.Context) {
 		return
 	}
 
-	businesses, err := c.businessUseCases.GetByUserId(userId)
+	businesses, err := c.membershipUseCases.ListBusinesses(userId)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch businesses"})
 		return
@@ -117,13 +121,6 @@ func (c *BusinessController) List(ctx *gin.Context) {
 }
 
 func (c *BusinessController) GetById(ctx *gin.Context) {
-	// Check auth context first
-	userId := ctx.GetString("user_id")
-	if userId == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
 	businessId := ctx.Param("businessId")
 	if businessId == "" {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Business ID is required"})
@@ -147,11 +144,7 @@ func (c *BusinessController) GetById(ctx *gin.Context) {
 		return
 	}
 
-	// Authorization check: ensure user owns this business
-	if business.UserID.Hex() != userId {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
+	business.Role = infrastructure.BusinessRole(ctx)
 
 	ctx.JSON(http.StatusOK, business)
 }
@@ -193,3 +186,99 @@ func (c *BusinessController) Update(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, business)
 }
+
+func (c *BusinessController) RegisterDevice(ctx *gin.Context) {
+	userId := ctx.GetString("user_id")
+	if userId == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	businessId := ctx.Param("businessId")
+	var req usecases.RegisterDeviceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	device, err := c.businessUseCases.RegisterDevice(businessId, userId, &req)
+	if err != nil {
+		ctx.JSON(deviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, device)
+}
+
+func (c *BusinessController) ListDevices(ctx *gin.Context) {
+	userId := ctx.GetString("user_id")
+	if userId == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	devices, err := c.businessUseCases.ListDevices(ctx.Param("businessId"), userId)
+	if err != nil {
+		ctx.JSON(deviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, devices)
+}
+
+func (c *BusinessController) RevokeDevice(ctx *gin.Context) {
+	userId := ctx.GetString("user_id")
+	if userId == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	deviceId := ctx.Param("deviceId")
+	if err := c.businessUseCases.RevokeDevice(ctx.Param("businessId"), userId, deviceId); err != nil {
+		ctx.JSON(deviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Device revoked successfully", "device_id": deviceId})
+}
+
+func (c *BusinessController) UpdateSyncPolicy(ctx *gin.Context) {
+	userId := ctx.GetString("user_id")
+	if userId == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req usecases.UpdateSyncPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	business, err := c.businessUseCases.UpdateSyncPolicy(ctx.Param("businessId"), userId, &req)
+	if err != nil {
+		if err == domain.ErrInvalidSyncPolicy {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+			return
+		}
+		ctx.JSON(deviceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"business_id": business.ID.Hex(), "policy": business.ConflictPolicy()})
+}
+
+// deviceErrorStatus maps device registry errors to HTTP status codes
+func deviceErrorStatus(err error) int {
+	switch err.Error() {
+	case "business not found", "device not found":
+		return http.StatusNotFound
+	case "unauthorized":
+		return http.StatusForbidden
+	case "device already registered":
+		return http.StatusConflict
+	case "device_id is required":
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	domain "shop-ops/Domain"
 	infrastructure "shop-ops/Infrastructure"
@@ -14,9 +15,8 @@ import (
 )
 
 type ExpenseController struct {
-	expenseUseCases  *usecases.ExpenseUseCases
-	businessUseCases usecases.BusinessUseCases
-	logger           *infrastructure.Logger
+	expenseUseCases *usecases.ExpenseUseCases
+	logger          *infrastructure.Logger
 }
 
 type RecordExpenseRequest struct {
@@ -66,13 +66,11 @@ type PaginatedResponse struct {
 // NewExpenseController
 func NewExpenseController(
 	expenseUseCases *usecases.ExpenseUseCases,
-	businessUseCases usecases.BusinessUseCases,
 	logger *infrastructure.Logger,
 ) *ExpenseController {
 	return &ExpenseController{
-		expenseUseCases:  expenseUseCases,
-		businessUseCases: businessUseCases,
-		logger:           logger,
+		expenseUseCases: expenseUseCases,
+		logger:          logger,
 	}
 }
 
@@ -111,29 +109,9 @@ func (ctrl *ExpenseController) RecordExpense(c *gin.Context) {
 		return
 	}
 
-	business, err := ctrl.businessUseCases.GetById(req.BusinessID)
-	if err != nil {
-		ctrl.logger.Warn("EXPENSE", "Business not found: %v", err)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Business not found",
-			"code":  "BIZ_001",
-		})
-		return
-	}
-
-	if business.UserID.Hex() != userID {
-		ctrl.logger.Warn("EXPENSE", "User %s is not owner of business %s", userID, req.BusinessID)
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "You don't have permission to create expenses for this business",
-			"code":  "AUTH_003",
-		})
-		return
-	}
-	ctrl.logger.Debug("EXPENSE", "User %s is owner of business %s", userID, req.BusinessID)
-
 	amount := decimal.NewFromFloat(req.Amount)
 
-	expense, err := ctrl.expenseUseCases.RecordExpense(usecases.RecordExpenseRequest{
+	expense, err := ctrl.expenseUseCases.RecordExpense(auditActor(c), usecases.RecordExpenseRequest{
 		BusinessID: businessID,
 		Category:   domain.ExpenseCategory(req.Category),
 		Amount:     amount,
@@ -167,16 +145,6 @@ func (ctrl *ExpenseController) RecordExpense(c *gin.Context) {
 }
 
 func (ctrl *ExpenseController) GetExpenses(c *gin.Context) {
-
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Code:    "AUTH_001",
-			Message: "User not authenticated",
-		})
-		return
-	}
-
 	businessID := c.Query("businessId")
 	if businessID == "" {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -186,23 +154,6 @@ func (ctrl *ExpenseController) GetExpenses(c *gin.Context) {
 		return
 	}
 
-	business, err := ctrl.businessUseCases.GetById(businessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Code:    "BIZ_001",
-			Message: "Business not found",
-		})
-		return
-	}
-
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Code:    "AUTH_003",
-			Message: "You don't have access to this business",
-		})
-		return
-	}
-
 	businessObjID, err := primitive.ObjectIDFromHex(businessID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -293,18 +244,26 @@ func (ctrl *ExpenseController) GetExpenses(c *gin.Context) {
 	})
 }
 
-// GetExpenseById - GET /expenses/:expenseId
-func (ctrl *ExpenseController) GetExpenseById(c *gin.Context) {
+// ExpenseBusinessID resolves the business of the :expenseId expense so the
+// authorizer can check routes that only name the expense.
+func (ctrl *ExpenseController) ExpenseBusinessID(c *gin.Context) (string, error) {
+	expenseObjID, err := primitive.ObjectIDFromHex(c.Param("expenseId"))
+	if err != nil {
+		return "", errors.New("Invalid expense ID format")
+	}
 
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Code:    "AUTH_001",
-			Message: "User not authenticated",
-		})
-		return
+	expense, err := ctrl.expenseUseCases.GetExpenseById(expenseObjID)
+	if err != nil {
+		if err == domain.ErrExpenseNotFound {
+			return "", &infrastructure.ResolveError{Status: http.StatusNotFound, Code: "NOT_001", Message: "Expense not found"}
+		}
+		return "", &infrastructure.ResolveError{Status: http.StatusInternalServerError, Code: "SYS_001", Message: "Failed to fetch expense"}
 	}
+	return expense.BusinessID.Hex(), nil
+}
 
+// GetExpenseById - GET /expenses/:expenseId
+func (ctrl *ExpenseController) GetExpenseById(c *gin.Context) {
 	expenseID := c.Param("expenseId")
 	if expenseID == "" {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -339,37 +298,11 @@ func (ctrl *ExpenseController) GetExpenseById(c *gin.Context) {
 		return
 	}
 
-	business, err := ctrl.businessUseCases.GetById(expense.BusinessID.Hex())
-	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Code:    "BIZ_001",
-			Message: "Associated business not found",
-		})
-		return
-	}
-
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Code:    "AUTH_003",
-			Message: "You don't have access to this expense",
-		})
-		return
-	}
-
 	c.JSON(http.StatusOK, toExpenseResponse(expense))
 }
 
 // UpdateExpense - PATCH /expenses/:expenseId
 func (ctrl *ExpenseController) UpdateExpense(c *gin.Context) {
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Code:    "AUTH_001",
-			Message: "User not authenticated",
-		})
-		return
-	}
-
 	expenseID := c.Param("expenseId")
 	if expenseID == "" {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -397,39 +330,6 @@ func (ctrl *ExpenseController) UpdateExpense(c *gin.Context) {
 		return
 	}
 
-	expense, err := ctrl.expenseUseCases.GetExpenseById(expenseObjID)
-	if err != nil {
-		if err == domain.ErrExpenseNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Code:    "NOT_001",
-				Message: "Expense not found",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Code:    "SYS_001",
-			Message: "Failed to fetch expense",
-		})
-		return
-	}
-
-	business, err := ctrl.businessUseCases.GetById(expense.BusinessID.Hex())
-	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Code:    "BIZ_001",
-			Message: "Associated business not found",
-		})
-		return
-	}
-
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Code:    "AUTH_003",
-			Message: "You don't have permission to update this expense",
-		})
-		return
-	}
-
 	// Map controller request to usecase request
 	useCaseReq := usecases.UpdateExpenseRequest{}
 
@@ -447,7 +347,7 @@ func (ctrl *ExpenseController) UpdateExpense(c *gin.Context) {
 		useCaseReq.Note = req.Note
 	}
 
-	updatedExpense, err := ctrl.expenseUseCases.UpdateExpense(expenseObjID, useCaseReq)
+	updatedExpense, err := ctrl.expenseUseCases.UpdateExpense(expenseObjID, auditActor(c), useCaseReq)
 	if err != nil {
 		if err == domain.ErrCannotUpdateVoided {
 			c.JSON(http.StatusConflict, ErrorResponse{
@@ -482,15 +382,6 @@ func (ctrl *ExpenseController) UpdateExpense(c *gin.Context) {
 
 // VoidExpense - DELETE /expenses/:expenseId
 func (ctrl *ExpenseController) VoidExpense(c *gin.Context) {
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Code:    "AUTH_001",
-			Message: "User not authenticated",
-		})
-		return
-	}
-
 	expenseID := c.Param("expenseId")
 	if expenseID == "" {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
@@ -509,40 +400,7 @@ func (ctrl *ExpenseController) VoidExpense(c *gin.Context) {
 		return
 	}
 
-	expense, err := ctrl.expenseUseCases.GetExpenseById(expenseObjID)
-	if err != nil {
-		if err == domain.ErrExpenseNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
-				Code:    "NOT_001",
-				Message: "Expense not found",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Code:    "SYS_001",
-			Message: "Failed to fetch expense",
-		})
-		return
-	}
-
-	business, err := ctrl.businessUseCases.GetById(expense.BusinessID.Hex())
-	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Code:    "BIZ_001",
-			Message: "Associated business not found",
-		})
-		return
-	}
-
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Code:    "AUTH_003",
-			Message: "You don't have permission to void this expense",
-		})
-		return
-	}
-
-	err = ctrl.expenseUseCases.VoidExpense(expenseObjID)
+	err = ctrl.expenseUseCases.VoidExpense(expenseObjID, auditActor(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Code:    "SYS_001",
@@ -561,14 +419,6 @@ func (ctrl *ExpenseController) VoidExpense(c *gin.Context) {
 // GetSummary - GET /expenses/summary
 func (ctrl *ExpenseController) GetSummary(c *gin.Context) {
 	// 1. Récupérer l'user_id du token
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Code:    "AUTH_001",
-			Message: "User not authenticated",
-		})
-		return
-	}
 
 	businessID := c.Query("businessId")
 	if businessID == "" {
@@ -579,23 +429,6 @@ func (ctrl *ExpenseController) GetSummary(c *gin.Context) {
 		return
 	}
 
-	business, err := ctrl.businessUseCases.GetById(businessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Code:    "BIZ_001",
-			Message: "Business not found",
-		})
-		return
-	}
-
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, ErrorResponse{
-			Code:    "AUTH_003",
-			Message: "You don't have access to this business",
-		})
-		return
-	}
-
 	businessObjID, err := primitive.ObjectIDFromHex(businessID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
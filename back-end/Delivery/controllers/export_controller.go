@@ -7,37 +7,21 @@ import (
 	"strconv"
 
 	Domain "shop-ops/Domain"
-	Usecases "shop-ops/Usecases"
+	infrastructure "shop-ops/Infrastructure"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ExportController struct {
-	exportUC   Domain.ExportUsecases
-	businessUC Usecases.BusinessUseCases
+	exportUC Domain.ExportUsecases
 }
 
-func NewExportController(exportUC Domain.ExportUsecases, businessUC Usecases.BusinessUseCases) *ExportController {
+func NewExportController(exportUC Domain.ExportUsecases) *ExportController {
 	return &ExportController{
-		exportUC:   exportUC,
-		businessUC: businessUC,
+		exportUC: exportUC,
 	}
 }
 
-// verifyBusinessOwnership checks that the authenticated user owns the business.
-func (c *ExportController) verifyBusinessOwnership(ctx *gin.Context, businessID, userID string) bool {
-	business, err := c.businessUC.GetById(businessID)
-	if err != nil || business == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return true
-	}
-	if business.UserID.Hex() != userID {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this business"})
-		return true
-	}
-	return false
-}
-
 type CreateExportRequest struct {
 	BusinessID string                 `json:"business_id"`
 	Type       string                 `json:"type"`
@@ -77,10 +61,6 @@ func (c *ExportController) RequestExport(ctx *gin.Context) {
 		return
 	}
 
-	if c.verifyBusinessOwnership(ctx, req.BusinessID, userID) {
-		return
-	}
-
 	exportReq, err := c.exportUC.RequestExport(req.BusinessID, userID, req.Type, req.Format, req.Filters, req.Fields)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -111,11 +91,6 @@ func (c *ExportController) GetExportStatus(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetString("user_id")
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
 	exportReq, err := c.exportUC.GetExportStatus(exportID, businessID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -149,11 +124,6 @@ func (c *ExportController) GetExportHistory(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetString("user_id")
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
 	pageStr := ctx.DefaultQuery("page", "1")
 	limitStr := ctx.DefaultQuery("limit", "50")
 
@@ -174,6 +144,19 @@ func (c *ExportController) GetExportHistory(ctx *gin.Context) {
 	})
 }
 
+// ExportFileBusinessID resolves the business of the export a :filename
+// download belongs to, so the authorizer can check the download route.
+func (c *ExportController) ExportFileBusinessID(ctx *gin.Context) (string, error) {
+	exportReq, err := c.exportUC.GetExportByFilename(ctx.Param("filename"))
+	if err != nil {
+		return "", &infrastructure.ResolveError{Status: http.StatusInternalServerError, Code: "SYS_001", Message: "Failed to fetch export"}
+	}
+	if exportReq == nil {
+		return "", &infrastructure.ResolveError{Status: http.StatusNotFound, Code: "NOT_001", Message: "File not found"}
+	}
+	return exportReq.BusinessID, nil
+}
+
 // DownloadExport godoc
 // @Summary      Download the exported file
 // @Description  Provides the physical file associated with the export
@@ -181,6 +164,7 @@ func (c *ExportController) GetExportHistory(ctx *gin.Context) {
 // @Produce      application/octet-stream
 // @Param        filename path    string  true   "Filename"
 // @Success      200      {file}    binary
+// @Failure      403      {object}  map[string]interface{}
 // @Failure      404      {object}  map[string]interface{}
 // @Router       /download/{filename} [get]
 // @Security     BearerAuth
@@ -191,8 +175,8 @@ func (c *ExportController) DownloadExport(ctx *gin.Context) {
 		return
 	}
 
-	// Just checking the base dir from project scope for simplicity, you could use an env variable
-	filePath := filepath.Join("tmp", "exports", filename)
+	// The export record was found and its business checked by the authorizer
+	filePath := filepath.Join("tmp", "exports", filepath.Base(filename))
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -12,26 +13,10 @@ import (
 
 type InventoryController struct {
 	inventoryUC Usecases.InventoryUseCase
-	businessUC  Usecases.BusinessUseCases
 }
 
-func NewInventoryController(inventoryUC Usecases.InventoryUseCase, businessUC Usecases.BusinessUseCases) *InventoryController {
-	return &InventoryController{inventoryUC: inventoryUC, businessUC: businessUC}
-}
-
-// verifyBusinessOwnership checks that the authenticated user owns the business.
-// Returns true if access is denied (caller should return early).
-func (c *InventoryController) verifyBusinessOwnership(ctx *gin.Context, businessID, userID string) bool {
-	business, err := c.businessUC.GetById(businessID)
-	if err != nil || business == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return true
-	}
-	if business.UserID.Hex() != userID {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this business"})
-		return true
-	}
-	return false
+func NewInventoryController(inventoryUC Usecases.InventoryUseCase) *InventoryController {
+	return &InventoryController{inventoryUC: inventoryUC}
 }
 
 // CreateProduct godoc
@@ -45,6 +30,7 @@ func (c *InventoryController) verifyBusinessOwnership(ctx *gin.Context, business
 // @Success      201  {object}  Domain.ProductResponse
 // @Failure      400  {object}  map[string]interface{}
 // @Failure      401  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
 // @Router       /api/businesses/{businessId}/inventory/products [post]
 // @Security     BearerAuth
 func (c *InventoryController) CreateProduct(ctx *gin.Context) {
@@ -60,19 +46,9 @@ func (c *InventoryController) CreateProduct(ctx *gin.Context) {
 		return
 	}
 
-	userID, exists := ctx.Get("user_id")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	if c.verifyBusinessOwnership(ctx, businessID, userID.(string)) {
-		return
-	}
-
-	product, err := c.inventoryUC.CreateProduct(businessID, userID.(string), req)
+	product, err := c.inventoryUC.CreateProduct(businessID, auditActor(ctx), req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		productError(ctx, err)
 		return
 	}
 
@@ -85,7 +61,11 @@ func (c *InventoryController) CreateProduct(ctx *gin.Context) {
 // @Tags         inventory
 // @Produce      json
 // @Param        businessId      path    string  true   "Business ID"
-// @Param        search          query   string  false  "Search product name"
+// @Param        search          query   string  false  "Search product name, SKU or barcode"
+// @Param        barcode         query   string  false  "Exact barcode, as scanned"
+// @Param        sku             query   string  false  "Exact SKU"
+// @Param        category        query   string  false  "Filter by category"
+// @Param        parent_id       query   string  false  "Variants of this product"
 // @Param        low_stock_only  query   bool    false  "Filter low stock products"
 // @Param        page            query   int     false  "Page number (default: 1)"
 // @Param        limit           query   int     false  "Results per page (default: 50)"
@@ -155,7 +135,7 @@ func (c *InventoryController) GetProduct(ctx *gin.Context) {
 
 // UpdateProduct godoc
 // @Summary      Update product info
-// @Description  Update product information (name, price, threshold)
+// @Description  Update product information (name, SKU, barcode, category, unit, price, threshold)
 // @Tags         inventory
 // @Accept       json
 // @Produce      json
@@ -166,6 +146,7 @@ func (c *InventoryController) GetProduct(ctx *gin.Context) {
 // @Failure      400  {object}  map[string]interface{}
 // @Failure      401  {object}  map[string]interface{}
 // @Failure      404  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
 // @Router       /api/businesses/{businessId}/inventory/products/{productId} [patch]
 // @Security     BearerAuth
 func (c *InventoryController) UpdateProduct(ctx *gin.Context) {
@@ -187,19 +168,9 @@ func (c *InventoryController) UpdateProduct(ctx *gin.Context) {
 		return
 	}
 
-	userID, exists := ctx.Get("user_id")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	if c.verifyBusinessOwnership(ctx, businessID, userID.(string)) {
-		return
-	}
-
-	product, err := c.inventoryUC.UpdateProduct(productID, businessID, userID.(string), req)
+	product, err := c.inventoryUC.UpdateProduct(productID, businessID, auditActor(ctx), req)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		productError(ctx, err)
 		return
 	}
 
@@ -232,18 +203,8 @@ func (c *InventoryController) DeleteProduct(ctx *gin.Context) {
 		return
 	}
 
-	userID, exists := ctx.Get("user_id")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	if c.verifyBusinessOwnership(ctx, businessID, userID.(string)) {
-		return
-	}
-
-	if err := c.inventoryUC.DeleteProduct(productID, businessID, userID.(string)); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.inventoryUC.DeleteProduct(productID, businessID, auditActor(ctx)); err != nil {
+		productError(ctx, err)
 		return
 	}
 
@@ -263,6 +224,7 @@ func (c *InventoryController) DeleteProduct(ctx *gin.Context) {
 // @Failure      400  {object}  map[string]interface{}
 // @Failure      401  {object}  map[string]interface{}
 // @Failure      404  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
 // @Router       /api/businesses/{businessId}/inventory/products/{productId}/adjust [post]
 // @Security     BearerAuth
 func (c *InventoryController) AdjustStock(ctx *gin.Context) {
@@ -284,17 +246,11 @@ func (c *InventoryController) AdjustStock(ctx *gin.Context) {
 		return
 	}
 
-	userID, exists := ctx.Get("user_id")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	if c.verifyBusinessOwnership(ctx, businessID, userID.(string)) {
-		return
-	}
-
-	if err := c.inventoryUC.AdjustStock(productID, businessID, userID.(string), req); err != nil {
+	if err := c.inventoryUC.AdjustStock(productID, businessID, auditActor(ctx), req); err != nil {
+		if errors.Is(err, Domain.ErrInsufficientStock) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "STOCK_001"})
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -371,3 +327,79 @@ func (c *InventoryController) GetStockHistory(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, history)
 }
+
+// CreateVariant godoc
+// @Summary      Add product variant
+// @Description  Add a variant, such as a size or colour, with its own stock. Category, unit, price and threshold default to the product's
+// @Tags         inventory
+// @Accept       json
+// @Produce      json
+// @Param        businessId  path  string                       true  "Business ID"
+// @Param        productId   path  string                       true  "Product ID"
+// @Param        request     body  Domain.CreateVariantRequest  true  "Variant details"
+// @Success      201  {object}  Domain.ProductResponse
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
+// @Router       /api/businesses/{businessId}/inventory/products/{productId}/variants [post]
+// @Security     BearerAuth
+func (c *InventoryController) CreateVariant(ctx *gin.Context) {
+	productID := ctx.Param("productId")
+	if productID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Product ID is required"})
+		return
+	}
+
+	var req Domain.CreateVariantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant, err := c.inventoryUC.CreateVariant(productID, req.BusinessID, auditActor(ctx), req)
+	if err != nil {
+		productError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, variant)
+}
+
+// GetCategories godoc
+// @Summary      List product categories
+// @Description  Get the categories in use with how many products each holds
+// @Tags         inventory
+// @Produce      json
+// @Param        businessId  path    string  true   "Business ID"
+// @Success      200  {array}   Domain.ProductCategory
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]interface{}
+// @Router       /api/businesses/{businessId}/inventory/categories [get]
+// @Security     BearerAuth
+func (c *InventoryController) GetCategories(ctx *gin.Context) {
+	businessID := ctx.Query("business_id")
+	if businessID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "business_id query parameter is required"})
+		return
+	}
+
+	categories, err := c.inventoryUC.GetCategories(businessID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, categories)
+}
+
+// productError maps catalog conflicts to 409; anything else is a bad request
+func productError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, Domain.ErrDuplicateSKU), errors.Is(err, Domain.ErrDuplicateBarcode):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "PROD_001"})
+	case errors.Is(err, Domain.ErrProductHasVariants), errors.Is(err, Domain.ErrNestedVariant):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "PROD_002"})
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
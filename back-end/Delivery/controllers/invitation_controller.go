@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	domain "shop-ops/Domain"
+	usecases "shop-ops/Usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvitationController lets owners invite staff and invitees join.
+type InvitationController struct {
+	invitationUseCases usecases.InvitationUseCases
+}
+
+func NewInvitationController(i usecases.InvitationUseCases) *InvitationController {
+	return &InvitationController{invitationUseCases: i}
+}
+
+// CreateInvitation handles POST /businesses/:businessId/invitations.
+func (c *InvitationController) CreateInvitation(ctx *gin.Context) {
+	var req domain.CreateInvitationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	invitation, err := c.invitationUseCases.CreateInvitation(ctx.Param("businessId"), ctx.GetString("user_id"), &req)
+	if err != nil {
+		invitationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, invitation)
+}
+
+// ListInvitations handles GET /businesses/:businessId/invitations.
+func (c *InvitationController) ListInvitations(ctx *gin.Context) {
+	invitations, err := c.invitationUseCases.ListInvitations(ctx.Param("businessId"))
+	if err != nil {
+		invitationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invitations)
+}
+
+// RevokeInvitation handles DELETE /businesses/:businessId/invitations/:invitationId.
+func (c *InvitationController) RevokeInvitation(ctx *gin.Context) {
+	invitationId := ctx.Param("invitationId")
+	if err := c.invitationUseCases.RevokeInvitation(ctx.Param("businessId"), invitationId); err != nil {
+		invitationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully", "invitation_id": invitationId})
+}
+
+// PreviewInvitation handles GET /auth/invitations/:code. It is public so the
+// app can show what the invitee is joining before they register.
+func (c *InvitationController) PreviewInvitation(ctx *gin.Context) {
+	preview, err := c.invitationUseCases.PreviewInvitation(ctx.Param("code"))
+	if err != nil {
+		invitationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, preview)
+}
+
+// AcceptInvitation handles POST /invitations/accept for users who already
+// have an account.
+func (c *InvitationController) AcceptInvitation(ctx *gin.Context) {
+	var req domain.AcceptInvitationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "code is required", "code": "VAL_001"})
+		return
+	}
+
+	business, err := c.invitationUseCases.AcceptInvitation(ctx.GetString("user_id"), req.Code)
+	if err != nil {
+		invitationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, business)
+}
+
+// isInvitationError reports whether err comes from redeeming an invitation
+func isInvitationError(err error) bool {
+	for _, target := range []error{
+		domain.ErrInvitationNotFound,
+		domain.ErrInvitationExpired,
+		domain.ErrInvitationUsed,
+		domain.ErrInvitationPhoneMismatch,
+		domain.ErrPhoneNotVerified,
+		domain.ErrAlreadyMember,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// invitationError maps invitation errors to responses
+func invitationError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvitationNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "INV_001"})
+	case errors.Is(err, domain.ErrInvitationExpired):
+		ctx.JSON(http.StatusGone, gin.H{"error": err.Error(), "code": "INV_002"})
+	case errors.Is(err, domain.ErrInvitationUsed):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "INV_003"})
+	case errors.Is(err, domain.ErrInvitationPhoneMismatch):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "INV_004"})
+	case errors.Is(err, domain.ErrPhoneNotVerified):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "INV_005"})
+	case errors.Is(err, domain.ErrInvalidPhone), errors.Is(err, domain.ErrInvalidInvitationTTL):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+	default:
+		membershipError(ctx, err)
+	}
+}
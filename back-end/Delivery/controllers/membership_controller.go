@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	domain "shop-ops/Domain"
+	usecases "shop-ops/Usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MembershipController manages the staff of a business.
+type MembershipController struct {
+	membershipUseCases usecases.MembershipUseCases
+}
+
+func NewMembershipController(m usecases.MembershipUseCases) *MembershipController {
+	return &MembershipController{membershipUseCases: m}
+}
+
+// ListMembers handles GET /businesses/:businessId/members.
+func (c *MembershipController) ListMembers(ctx *gin.Context) {
+	members, err := c.membershipUseCases.ListMembers(ctx.Param("businessId"))
+	if err != nil {
+		membershipError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, members)
+}
+
+// AddMember handles POST /businesses/:businessId/members.
+func (c *MembershipController) AddMember(ctx *gin.Context) {
+	var req domain.AddMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	member, err := c.membershipUseCases.AddMember(ctx.Param("businessId"), ctx.GetString("user_id"), &req)
+	if err != nil {
+		membershipError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, member)
+}
+
+// UpdateMemberRole handles PATCH /businesses/:businessId/members/:userId.
+func (c *MembershipController) UpdateMemberRole(ctx *gin.Context) {
+	var req domain.UpdateMemberRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	member, err := c.membershipUseCases.UpdateRole(ctx.Param("businessId"), ctx.Param("userId"), &req)
+	if err != nil {
+		membershipError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, member)
+}
+
+// RemoveMember handles DELETE /businesses/:businessId/members/:userId.
+func (c *MembershipController) RemoveMember(ctx *gin.Context) {
+	userId := ctx.Param("userId")
+	if err := c.membershipUseCases.RemoveMember(ctx.Param("businessId"), userId); err != nil {
+		membershipError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member removed successfully", "user_id": userId})
+}
+
+// membershipError maps membership errors to responses
+func membershipError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrBusinessNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Business not found", "code": "BIZ_001"})
+	case errors.Is(err, domain.ErrMemberNotFound), errors.Is(err, domain.ErrUserNotRegistered):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "MEM_001"})
+	case errors.Is(err, domain.ErrAlreadyMember):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "MEM_002"})
+	case errors.Is(err, domain.ErrOwnerMembership):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "MEM_003"})
+	case errors.Is(err, domain.ErrPhoneNotVerified):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "MEM_004"})
+	case errors.Is(err, domain.ErrInvalidMemberRole), errors.Is(err, domain.ErrPhoneRequired):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update members", "code": "SYS_001"})
+	}
+}
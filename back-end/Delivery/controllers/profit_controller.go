@@ -10,30 +10,14 @@ import (
 
 type ProfitController struct {
 	profitUseCase    usecases.ProfitUseCase
-	businessUseCase  usecases.BusinessUseCases
 }
 
-func NewProfitController(useCase usecases.ProfitUseCase, businessUseCase usecases.BusinessUseCases) *ProfitController {
+func NewProfitController(useCase usecases.ProfitUseCase) *ProfitController {
 	return &ProfitController{
 		profitUseCase:   useCase,
-		businessUseCase: businessUseCase,
 	}
 }
 
-// verifyBusinessOwnership checks that the authenticated user owns the business.
-func (pc *ProfitController) verifyBusinessOwnership(c *gin.Context, businessID, userID string) bool {
-	business, err := pc.businessUseCase.GetById(businessID)
-	if err != nil || business == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return true
-	}
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this business"})
-		return true
-	}
-	return false
-}
-
 // GetSummary handles fetching profit summary for a period
 func (pc *ProfitController) GetSummary(c *gin.Context) {
 	businessID := c.Query("business_id")
@@ -42,15 +26,6 @@ func (pc *ProfitController) GetSummary(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if pc.verifyBusinessOwnership(c, businessID, userID) {
-		return
-	}
-
 	var query domain.ProfitQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -74,15 +49,6 @@ func (pc *ProfitController) GetTrends(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if pc.verifyBusinessOwnership(c, businessID, userID) {
-		return
-	}
-
 	var query domain.ProfitQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -106,12 +72,26 @@ func (pc *ProfitController) GetComparison(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	var query domain.ProfitQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comparison, err := pc.profitUseCase.GetComparison(businessID, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if pc.verifyBusinessOwnership(c, businessID, userID) {
+
+	c.JSON(http.StatusOK, comparison)
+}
+
+// GetProductMargins handles fetching the gross margin of each product for a period
+func (pc *ProfitController) GetProductMargins(c *gin.Context) {
+	businessID := c.Query("business_id")
+	if businessID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id query parameter is required"})
 		return
 	}
 
@@ -121,11 +101,11 @@ func (pc *ProfitController) GetComparison(c *gin.Context) {
 		return
 	}
 
-	comparison, err := pc.profitUseCase.GetComparison(businessID, query)
+	margins, err := pc.profitUseCase.GetProductMargins(businessID, query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, comparison)
+	c.JSON(http.StatusOK, margins)
 }
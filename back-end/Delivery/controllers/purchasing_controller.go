@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	domain "shop-ops/Domain"
+	usecases "shop-ops/Usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PurchasingController manages suppliers and the purchase orders that
+// restock the business.
+type PurchasingController struct {
+	purchasingUseCases usecases.PurchasingUseCases
+}
+
+func NewPurchasingController(p usecases.PurchasingUseCases) *PurchasingController {
+	return &PurchasingController{purchasingUseCases: p}
+}
+
+// CreateSupplier handles POST /businesses/:businessId/suppliers.
+func (c *PurchasingController) CreateSupplier(ctx *gin.Context) {
+	var req domain.SupplierRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	supplier, err := c.purchasingUseCases.CreateSupplier(ctx.Param("businessId"), auditActor(ctx), &req)
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, supplier)
+}
+
+// ListSuppliers handles GET /businesses/:businessId/suppliers.
+func (c *PurchasingController) ListSuppliers(ctx *gin.Context) {
+	suppliers, err := c.purchasingUseCases.ListSuppliers(ctx.Param("businessId"))
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, suppliers)
+}
+
+// GetSupplier handles GET /businesses/:businessId/suppliers/:supplierId.
+func (c *PurchasingController) GetSupplier(ctx *gin.Context) {
+	supplier, err := c.purchasingUseCases.GetSupplier(ctx.Param("businessId"), ctx.Param("supplierId"))
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, supplier)
+}
+
+// UpdateSupplier handles PUT /businesses/:businessId/suppliers/:supplierId.
+func (c *PurchasingController) UpdateSupplier(ctx *gin.Context) {
+	var req domain.SupplierRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	supplier, err := c.purchasingUseCases.UpdateSupplier(ctx.Param("businessId"), ctx.Param("supplierId"), auditActor(ctx), &req)
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, supplier)
+}
+
+// GetSupplierSpend handles GET /businesses/:businessId/suppliers/spend.
+func (c *PurchasingController) GetSupplierSpend(ctx *gin.Context) {
+	var query domain.SupplierSpendQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+		return
+	}
+
+	spend, err := c.purchasingUseCases.GetSupplierSpend(ctx.Param("businessId"), query)
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, spend)
+}
+
+// CreatePurchaseOrder handles POST /businesses/:businessId/purchase-orders.
+func (c *PurchasingController) CreatePurchaseOrder(ctx *gin.Context) {
+	var req domain.PurchaseOrderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	order, err := c.purchasingUseCases.CreatePurchaseOrder(ctx.Param("businessId"), auditActor(ctx), &req)
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, order)
+}
+
+// ListPurchaseOrders handles GET /businesses/:businessId/purchase-orders.
+func (c *PurchasingController) ListPurchaseOrders(ctx *gin.Context) {
+	var query domain.PurchaseOrderQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+		return
+	}
+
+	orders, err := c.purchasingUseCases.ListPurchaseOrders(ctx.Param("businessId"), query)
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, orders)
+}
+
+// GetPurchaseOrder handles GET /businesses/:businessId/purchase-orders/:orderId.
+func (c *PurchasingController) GetPurchaseOrder(ctx *gin.Context) {
+	order, err := c.purchasingUseCases.GetPurchaseOrder(ctx.Param("businessId"), ctx.Param("orderId"))
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// UpdatePurchaseOrder handles PUT /businesses/:businessId/purchase-orders/:orderId.
+func (c *PurchasingController) UpdatePurchaseOrder(ctx *gin.Context) {
+	var req domain.PurchaseOrderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+		return
+	}
+
+	order, err := c.purchasingUseCases.UpdatePurchaseOrder(ctx.Param("businessId"), ctx.Param("orderId"), auditActor(ctx), &req)
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// PlacePurchaseOrder handles POST /businesses/:businessId/purchase-orders/:orderId/order.
+func (c *PurchasingController) PlacePurchaseOrder(ctx *gin.Context) {
+	order, err := c.purchasingUseCases.PlacePurchaseOrder(ctx.Param("businessId"), ctx.Param("orderId"), auditActor(ctx))
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// ReceivePurchaseOrder handles POST /businesses/:businessId/purchase-orders/:orderId/receive.
+// An empty body receives everything still outstanding.
+func (c *PurchasingController) ReceivePurchaseOrder(ctx *gin.Context) {
+	var req domain.ReceivePurchaseOrderRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "code": "VAL_001"})
+			return
+		}
+	}
+
+	order, err := c.purchasingUseCases.ReceivePurchaseOrder(ctx.Param("businessId"), ctx.Param("orderId"), auditActor(ctx), &req)
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// CancelPurchaseOrder handles POST /businesses/:businessId/purchase-orders/:orderId/cancel.
+func (c *PurchasingController) CancelPurchaseOrder(ctx *gin.Context) {
+	order, err := c.purchasingUseCases.CancelPurchaseOrder(ctx.Param("businessId"), ctx.Param("orderId"), auditActor(ctx))
+	if err != nil {
+		purchasingError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// purchasingError maps supplier and purchase order errors to responses
+func purchasingError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrSupplierNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "SUP_001"})
+	case errors.Is(err, domain.ErrPurchaseOrderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "PO_001"})
+	case errors.Is(err, domain.ErrPurchaseOrderNotDraft),
+		errors.Is(err, domain.ErrPurchaseOrderNotOpen),
+		errors.Is(err, domain.ErrInvalidPurchaseOrderState):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "PO_002"})
+	case errors.Is(err, domain.ErrPurchaseOrderConflict):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "PO_003"})
+	case errors.Is(err, domain.ErrOverReceipt), errors.Is(err, domain.ErrNothingToReceive):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "PO_004"})
+	case errors.Is(err, domain.ErrSupplierNameRequired),
+		errors.Is(err, domain.ErrPurchaseOrderNoLines),
+		errors.Is(err, domain.ErrInvalidPurchaseLine),
+		errors.Is(err, domain.ErrInvalidPurchaseStatus),
+		errors.Is(err, domain.ErrInvalidSpendPeriod):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+	case errors.Is(err, domain.ErrBusinessNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "BIZ_001"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "code": "SYS_001"})
+	}
+}
@@ -13,33 +13,16 @@ import (
 
 // ReportController handles report-related HTTP requests
 type ReportController struct {
-	reportUC   *Usecases.ReportUsecases
-	businessUC Usecases.BusinessUseCases
+	reportUC *Usecases.ReportUsecases
 }
 
 // NewReportController creates a new ReportController
-func NewReportController(reportUC *Usecases.ReportUsecases, businessUC Usecases.BusinessUseCases) *ReportController {
+func NewReportController(reportUC *Usecases.ReportUsecases) *ReportController {
 	return &ReportController{
-		reportUC:   reportUC,
-		businessUC: businessUC,
+		reportUC: reportUC,
 	}
 }
 
-// verifyBusinessOwnership checks that the authenticated user owns the business.
-// Returns true if access is denied (caller should return early).
-func (rc *ReportController) verifyBusinessOwnership(c *gin.Context, businessID, userID string) bool {
-	business, err := rc.businessUC.GetById(businessID)
-	if err != nil || business == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return true
-	}
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this business"})
-		return true
-	}
-	return false
-}
-
 // parseDateRange parses start_date and end_date from query params.
 // Returns false if parsing failed and a response was already sent.
 func (rc *ReportController) parseDateRange(c *gin.Context) (Domain.DateRange, bool) {
@@ -84,15 +67,9 @@ func (rc *ReportController) parseGroupBy(c *gin.Context) Domain.GroupBy {
 	}
 }
 
-// getBusinessID gets business ID from query param and verifies ownership.
+// getBusinessID gets business ID from query param.
 // Returns primitive.NilObjectID and false if validation failed (response already sent).
 func (rc *ReportController) getBusinessID(c *gin.Context) (primitive.ObjectID, bool) {
-	userID := c.GetString("user_id")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return primitive.NilObjectID, false
-	}
-
 	businessIDStr := c.Query("business_id")
 	if businessIDStr == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id query parameter is required"})
@@ -105,11 +82,6 @@ func (rc *ReportController) getBusinessID(c *gin.Context) (primitive.ObjectID, b
 		return primitive.NilObjectID, false
 	}
 
-	// Verify ownership (sends its own JSON response on failure)
-	if rc.verifyBusinessOwnership(c, businessIDStr, userID) {
-		return primitive.NilObjectID, false
-	}
-
 	return businessID, true
 }
 
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	infrastructure "shop-ops/Infrastructure"
 	Usecases "shop-ops/Usecases"
 
 	"github.com/gin-gonic/gin"
@@ -12,28 +13,12 @@ import (
 
 // RestoreController handles HTTP requests for the data restore feature
 type RestoreController struct {
-	restoreUC  Usecases.RestoreUseCases
-	businessUC Usecases.BusinessUseCases
+	restoreUC Usecases.RestoreUseCases
 }
 
 // NewRestoreController creates a new RestoreController
-func NewRestoreController(restoreUC Usecases.RestoreUseCases, businessUC Usecases.BusinessUseCases) *RestoreController {
-	return &RestoreController{restoreUC: restoreUC, businessUC: businessUC}
-}
-
-// verifyBusinessOwnership checks that the authenticated user owns the business.
-// Returns true if access is denied (caller should return early).
-func (c *RestoreController) verifyBusinessOwnership(ctx *gin.Context, businessID, userID string) bool {
-	business, err := c.businessUC.GetById(businessID)
-	if err != nil || business == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return true
-	}
-	if business.UserID.Hex() != userID {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this business"})
-		return true
-	}
-	return false
+func NewRestoreController(restoreUC Usecases.RestoreUseCases) *RestoreController {
+	return &RestoreController{restoreUC: restoreUC}
 }
 
 // parseInclude parses the comma-separated include query parameter.
@@ -56,7 +41,7 @@ func parseInclude(raw string) []string {
 
 // FullRestore godoc
 // @Summary      Full data restore
-// @Description  Returns all sales, expenses, and products for a business. Use the include filter to select specific entity types.
+// @Description  Returns all sales, expenses, and products for a business. Use the include filter to select specific entity types. Expenses are left out for roles that cannot manage expenses, and costs for roles that cannot view reports.
 // @Tags         restore
 // @Produce      json
 // @Param        businessId  path   string  true   "Business ID"
@@ -74,18 +59,9 @@ func (c *RestoreController) FullRestore(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetString("user_id")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
 	include := parseInclude(ctx.Query("include"))
 
-	response, err := c.restoreUC.FullRestore(businessID, include)
+	response, err := c.restoreUC.FullRestore(businessID, infrastructure.BusinessRole(ctx), include)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -96,7 +72,7 @@ func (c *RestoreController) FullRestore(ctx *gin.Context) {
 
 // IncrementalRestore godoc
 // @Summary      Incremental data restore
-// @Description  Returns sales, expenses, and products modified since a given timestamp. Use the include filter to select entity types.
+// @Description  Returns sales, expenses, and products modified since a given timestamp. Use the include filter to select entity types. Expenses are left out for roles that cannot manage expenses, and costs for roles that cannot view reports.
 // @Tags         restore
 // @Produce      json
 // @Param        businessId  path   string  true   "Business ID"
@@ -127,18 +103,9 @@ func (c *RestoreController) IncrementalRestore(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetString("user_id")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
 	include := parseInclude(ctx.Query("include"))
 
-	response, err := c.restoreUC.IncrementalRestore(businessID, since, include)
+	response, err := c.restoreUC.IncrementalRestore(businessID, infrastructure.BusinessRole(ctx), since, include)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	Domain "shop-ops/Domain"
@@ -11,33 +12,17 @@ import (
 
 // SalesController handles HTTP requests for the sales feature
 type SalesController struct {
-	salesUC    Usecases.SalesUseCase
-	businessUC Usecases.BusinessUseCases
+	salesUC Usecases.SalesUseCase
 }
 
 // NewSalesController creates a new SalesController
-func NewSalesController(salesUC Usecases.SalesUseCase, businessUC Usecases.BusinessUseCases) *SalesController {
-	return &SalesController{salesUC: salesUC, businessUC: businessUC}
-}
-
-// verifyBusinessOwnership checks that the authenticated user owns the business.
-// Returns true if access is denied (caller should return early).
-func (c *SalesController) verifyBusinessOwnership(ctx *gin.Context, businessID, userID string) bool {
-	business, err := c.businessUC.GetById(businessID)
-	if err != nil || business == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return true
-	}
-	if business.UserID.Hex() != userID {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this business"})
-		return true
-	}
-	return false
+func NewSalesController(salesUC Usecases.SalesUseCase) *SalesController {
+	return &SalesController{salesUC: salesUC}
 }
 
 // CreateSale godoc
 // @Summary      Record a new sale
-// @Description  Record a sales transaction; optionally link to a product (which auto-decrements stock)
+// @Description  Record a sales transaction with one or more lines; lines linked to a product auto-decrement its stock
 // @Tags         sales
 // @Accept       json
 // @Produce      json
@@ -46,6 +31,7 @@ func (c *SalesController) verifyBusinessOwnership(ctx *gin.Context, businessID,
 // @Success      201  {object}  Domain.SaleResponse
 // @Failure      400  {object}  map[string]interface{}
 // @Failure      401  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
 // @Router       /api/businesses/{businessId}/sales [post]
 // @Security     BearerAuth
 func (c *SalesController) CreateSale(ctx *gin.Context) {
@@ -61,26 +47,16 @@ func (c *SalesController) CreateSale(ctx *gin.Context) {
 		return
 	}
 
-	userID, exists := ctx.Get("user_id")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	if len(req.LineRequests()) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "lines are required: each with quantity and unit_price greater than 0"})
 		return
 	}
 
-	if c.verifyBusinessOwnership(ctx, businessID, userID.(string)) {
+	sale, err := c.salesUC.CreateSale(businessID, auditActor(ctx), req)
+	if errors.Is(err, Domain.ErrInsufficientStock) {
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "STOCK_001"})
 		return
 	}
-
-	if req.UnitPrice <= 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "unit_price must be greater than 0"})
-		return
-	}
-	if req.Quantity <= 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be greater than 0"})
-		return
-	}
-
-	sale, err := c.salesUC.CreateSale(businessID, userID.(string), req)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -122,15 +98,6 @@ func (c *SalesController) GetSales(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetString("user_id")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
 	sales, err := c.salesUC.GetSales(businessID, query)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -166,15 +133,6 @@ func (c *SalesController) GetSale(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetString("user_id")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
 	sale, err := c.salesUC.GetSaleByID(saleID, businessID)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -186,7 +144,7 @@ func (c *SalesController) GetSale(ctx *gin.Context) {
 
 // VoidSale godoc
 // @Summary      Void a sale
-// @Description  Soft-delete a sale (marks as voided); reverses inventory stock if product was linked
+// @Description  Soft-delete a sale (marks as voided); returns the stock of every line linked to a product
 // @Tags         sales
 // @Produce      json
 // @Param        businessId  path  string  true  "Business ID"
@@ -210,17 +168,7 @@ func (c *SalesController) VoidSale(ctx *gin.Context) {
 		return
 	}
 
-	userID, exists := ctx.Get("user_id")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	if c.verifyBusinessOwnership(ctx, businessID, userID.(string)) {
-		return
-	}
-
-	if err := c.salesUC.VoidSale(saleID, businessID, userID.(string)); err != nil {
+	if err := c.salesUC.VoidSale(saleID, businessID, auditActor(ctx)); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -250,16 +198,6 @@ func (c *SalesController) GetSalesSummary(ctx *gin.Context) {
 
 	startDate := ctx.Query("start_date")
 	endDate := ctx.Query("end_date")
-
-	userID := ctx.GetString("user_id")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
 	summary, err := c.salesUC.GetSalesSummary(businessID, startDate, endDate)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -303,16 +241,7 @@ func (c *SalesController) UpdateSale(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetString("user_id")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
-	sale, err := c.salesUC.UpdateSale(saleID, businessID, req)
+	sale, err := c.salesUC.UpdateSale(saleID, businessID, auditActor(ctx), req)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -339,15 +268,6 @@ func (c *SalesController) GetSalesStats(ctx *gin.Context) {
 		return
 	}
 
-	userID := ctx.GetString("user_id")
-	if userID == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-	if c.verifyBusinessOwnership(ctx, businessID, userID) {
-		return
-	}
-
 	stats, err := c.salesUC.GetSalesStats(businessID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
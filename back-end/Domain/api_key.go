@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyPrefix starts every API key, so keys are recognisable in config files
+// and secret scanners, and the auth middleware can tell them from JWTs.
+const APIKeyPrefix = "sops_"
+
+// MaxAPIKeyNameLength bounds the label an owner gives a key.
+const MaxAPIKeyNameLength = 100
+
+// APIKeyTouchInterval limits last-used writes to one per key per minute, so
+// a busy integration does not turn every read into a write.
+const APIKeyTouchInterval = time.Minute
+
+// APIKeyScope limits what an integration may do with a key.
+type APIKeyScope string
+
+const (
+	ScopeReadSales      APIKeyScope = "read:sales"
+	ScopeWriteSales     APIKeyScope = "write:sales"
+	ScopeReadExpenses   APIKeyScope = "read:expenses"
+	ScopeWriteExpenses  APIKeyScope = "write:expenses"
+	ScopeReadInventory  APIKeyScope = "read:inventory"
+	ScopeWriteInventory APIKeyScope = "write:inventory"
+	ScopeReadReports    APIKeyScope = "read:reports"
+	ScopeExport         APIKeyScope = "export"
+)
+
+// scopeGrant is what one scope allows. Read scopes only allow safe methods,
+// since some permissions cover both reading and changing records.
+type scopeGrant struct {
+	permissions []Permission
+	readOnly    bool
+}
+
+var scopeGrants = map[APIKeyScope]scopeGrant{
+	ScopeReadSales:      {[]Permission{PermissionViewBusiness, PermissionViewSales}, true},
+	ScopeWriteSales:     {[]Permission{PermissionRecordSales}, false},
+	ScopeReadExpenses:   {[]Permission{PermissionManageExpenses}, true},
+	ScopeWriteExpenses:  {[]Permission{PermissionRecordExpenses}, false},
+	ScopeReadInventory:  {[]Permission{PermissionViewProducts}, true},
+	ScopeWriteInventory: {[]Permission{PermissionManageProducts, PermissionAdjustStock}, false},
+	ScopeReadReports:    {[]Permission{PermissionViewReports}, true},
+	ScopeExport:         {[]Permission{PermissionExportData}, false},
+}
+
+// IsValidAPIKeyScope reports whether s is a known scope.
+func IsValidAPIKeyScope(s APIKeyScope) bool {
+	_, ok := scopeGrants[s]
+	return ok
+}
+
+var (
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrInvalidAPIKey       = errors.New("invalid or revoked api key")
+	ErrAPIKeyNameRequired  = errors.New("name is required and must be at most 100 characters")
+	ErrInvalidAPIKeyScopes = errors.New("scopes must be a non-empty list of read:sales, write:sales, read:expenses, write:expenses, read:inventory, write:inventory, read:reports, export")
+	ErrAPIKeyScopeDenied   = errors.New("this api key's scopes do not allow this action")
+)
+
+// APIKey lets an integration act on one business without a user login. The
+// key itself is only shown when it is created; the database keeps its hash.
+// A key can never do more than the user who created it.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BusinessID primitive.ObjectID `bson:"business_id" json:"business_id"`
+	Name       string             `bson:"name" json:"name"`
+	// Hint is the start of the key, so owners can tell their keys apart
+	Hint       string             `bson:"hint" json:"hint"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	Scopes     []APIKeyScope      `bson:"scopes" json:"scopes"`
+	CreatedBy  primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+
+	// Key is filled in only on the response to creation.
+	Key string `bson:"-" json:"key,omitempty"`
+}
+
+// Allows reports whether the key's scopes grant permission p for a request
+// with the given HTTP method.
+func (k *APIKey) Allows(p Permission, method string) bool {
+	safe := method == "GET" || method == "HEAD"
+	for _, scope := range k.Scopes {
+		grant, ok := scopeGrants[scope]
+		if !ok || (grant.readOnly && !safe) {
+			continue
+		}
+		for _, granted := range grant.permissions {
+			if granted == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CreateAPIKeyRequest issues a key with a label and scopes.
+type CreateAPIKeyRequest struct {
+	Name   string        `json:"name"`
+	Scopes []APIKeyScope `json:"scopes"`
+}
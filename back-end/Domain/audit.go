@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEntityType names the kind of record an audit entry is about.
+type AuditEntityType string
+
+const (
+	AuditEntitySale          AuditEntityType = "sale"
+	AuditEntityExpense       AuditEntityType = "expense"
+	AuditEntityProduct       AuditEntityType = "product"
+	AuditEntitySupplier      AuditEntityType = "supplier"
+	AuditEntityPurchaseOrder AuditEntityType = "purchase_order"
+)
+
+// AuditAction names what was done to the record.
+type AuditAction string
+
+const (
+	AuditActionCreate      AuditAction = "create"
+	AuditActionUpdate      AuditAction = "update"
+	AuditActionVoid        AuditAction = "void"
+	AuditActionDelete      AuditAction = "delete"
+	AuditActionAdjustStock AuditAction = "adjust_stock"
+	AuditActionReceive     AuditAction = "receive"
+	AuditActionCancel      AuditAction = "cancel"
+)
+
+// AuditSource tells whether a change came through the API or a device sync.
+type AuditSource string
+
+const (
+	AuditSourceAPI  AuditSource = "api"
+	AuditSourceSync AuditSource = "sync"
+)
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 100
+)
+
+var ErrInvalidAuditRange = errors.New("start_date must not be after end_date")
+
+// auditIgnoredFields change on every write and would only add noise.
+var auditIgnoredFields = map[string]bool{
+	"updated_at": true,
+	"version":    true,
+}
+
+// AuditActor describes who made a change and from where.
+type AuditActor struct {
+	UserID    string
+	Role      BusinessRole
+	RequestID string
+	DeviceID  string
+	Source    AuditSource
+}
+
+// AuditChange is one field's value before and after a change. Before is nil
+// for created records.
+type AuditChange struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// AuditEntry is an append-only record of a change to business data.
+type AuditEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BusinessID primitive.ObjectID `bson:"business_id" json:"business_id"`
+	ActorID    string             `bson:"actor_id" json:"actor_id"`
+	ActorRole  BusinessRole       `bson:"actor_role,omitempty" json:"actor_role,omitempty"`
+	EntityType AuditEntityType    `bson:"entity_type" json:"entity_type"`
+	EntityID   string             `bson:"entity_id" json:"entity_id"`
+	Action     AuditAction        `bson:"action" json:"action"`
+	Changes    []AuditChange      `bson:"changes" json:"changes"`
+	Reason     string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	RequestID  string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	DeviceID   string             `bson:"device_id,omitempty" json:"device_id,omitempty"`
+	Source     AuditSource        `bson:"source" json:"source"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// NewAuditEntry records actor doing action to an entity, diffing the
+// record's state before and after. Either state may be nil.
+func NewAuditEntry(actor AuditActor, businessID primitive.ObjectID, entityType AuditEntityType, entityID string, action AuditAction, before, after interface{}) *AuditEntry {
+	source := actor.Source
+	if source == "" {
+		source = AuditSourceAPI
+	}
+	return &AuditEntry{
+		ID:         primitive.NewObjectID(),
+		BusinessID: businessID,
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Changes:    DiffAudit(before, after),
+		RequestID:  actor.RequestID,
+		DeviceID:   actor.DeviceID,
+		Source:     source,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// DiffAudit lists the top-level JSON fields that differ between before and
+// after, sorted by name.
+func DiffAudit(before, after interface{}) []AuditChange {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+
+	names := make([]string, 0, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []AuditChange{}
+	for _, name := range names {
+		if auditIgnoredFields[name] {
+			continue
+		}
+		if reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+		changes = append(changes, AuditChange{Field: name, Before: beforeFields[name], After: afterFields[name]})
+	}
+	return changes
+}
+
+func auditFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(raw, &fields)
+	return fields
+}
+
+// AuditQuery filters the audit log of one business.
+type AuditQuery struct {
+	BusinessID string
+	ActorID    string
+	EntityType AuditEntityType
+	EntityID   string
+	Action     AuditAction
+	Source     AuditSource
+	DeviceID   string
+	From       *time.Time
+	To         *time.Time
+	Page       int
+	Limit      int
+}
+
+// AuditList is a page of audit entries, newest first.
+type AuditList struct {
+	Entries    []AuditEntry       `json:"entries"`
+	Pagination PaginationMetadata `json:"pagination"`
+}
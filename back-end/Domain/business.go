@@ -2,6 +2,7 @@ package domain
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,17 +16,45 @@ const (
 	TierPremium SubscriptionTier = "PREMIUM"
 )
 
+// DeviceStatus represents whether a registered device may sync
+type DeviceStatus string
+
+const (
+	DeviceStatusActive  DeviceStatus = "active"
+	DeviceStatusRevoked DeviceStatus = "revoked"
+)
+
+// SyncDevice is a phone or tablet registered to sync data for a business
+type SyncDevice struct {
+	DeviceID     string             `bson:"device_id" json:"device_id"`
+	Name         string             `bson:"name" json:"name"`
+	Status       DeviceStatus       `bson:"status" json:"status"`
+	RegisteredBy primitive.ObjectID `bson:"registered_by" json:"registered_by"`
+	RegisteredAt time.Time          `bson:"registered_at" json:"registered_at"`
+	RevokedAt    *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// IsActive reports whether the device is allowed to sync
+func (d *SyncDevice) IsActive() bool {
+	return d.Status == DeviceStatusActive
+}
+
 // Business represents a shop or business entity
 type Business struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Name      string             `bson:"name" json:"name"`
-	Currency  string             `bson:"currency" json:"currency"`
-	Language  string             `bson:"language" json:"language"`
-	Timezone  string             `bson:"timezone" json:"timezone"`
-	Tier      SubscriptionTier   `bson:"tier" json:"tier"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name          string             `bson:"name" json:"name"`
+	Currency      string             `bson:"currency" json:"currency"`
+	Language      string             `bson:"language" json:"language"`
+	Timezone      string             `bson:"timezone" json:"timezone"`
+	Tier          SubscriptionTier   `bson:"tier" json:"tier"`
+	Devices       []SyncDevice       `bson:"sync_devices,omitempty" json:"sync_devices,omitempty"`
+	LegacyDevice  string             `bson:"sync_device_id,omitempty" json:"-"` // The one device that synced before the registry
+	SyncPolicy    SyncConflictPolicy `bson:"sync_conflict_policy,omitempty" json:"sync_conflict_policy,omitempty"`
+	CostingMethod CostingMethod      `bson:"costing_method,omitempty" json:"costing_method,omitempty"`
+	Role          BusinessRole       `bson:"-" json:"role,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
 // NewBusiness creates a new Business instance with default settings
@@ -69,6 +98,49 @@ func (b *Business) Validate() error {
 	return nil
 }
 
+// FindDevice returns the registered device with the given ID, or nil
+func (b *Business) FindDevice(deviceID string) *SyncDevice {
+	for i := range b.Devices {
+		if b.Devices[i].DeviceID == deviceID {
+			return &b.Devices[i]
+		}
+	}
+	return nil
+}
+
+// AdoptLegacyDevice adds the device a business synced with before the device
+// registry existed, so registering another device does not lock it out. It
+// does nothing once the registry has devices, and reports whether it adopted.
+func (b *Business) AdoptLegacyDevice(now time.Time) bool {
+	legacy := strings.TrimSpace(b.LegacyDevice)
+	if len(b.Devices) > 0 || legacy == "" {
+		return false
+	}
+	b.Devices = []SyncDevice{{
+		DeviceID:     legacy,
+		Status:       DeviceStatusActive,
+		RegisteredBy: b.UserID,
+		RegisteredAt: now,
+	}}
+	return true
+}
+
+// ConflictPolicy returns the sync conflict policy, defaulting to server wins
+func (b *Business) ConflictPolicy() SyncConflictPolicy {
+	if b.SyncPolicy == "" {
+		return SyncPolicyServerWins
+	}
+	return b.SyncPolicy
+}
+
+// Costing returns the costing method, defaulting to weighted average
+func (b *Business) Costing() CostingMethod {
+	if b.CostingMethod == "" {
+		return CostingWeightedAverage
+	}
+	return b.CostingMethod
+}
+
 type BusinessRepository interface {
 	FindByID(id string) (*Business, error)
 }
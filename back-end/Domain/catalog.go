@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// UnitOfMeasure is what one unit of a product's stock is. Stock is kept as
+// a decimal, so a product sold by weight or volume can hold 0.25 kg or
+// 1.5 litre.
+type UnitOfMeasure string
+
+const (
+	UnitPiece      UnitOfMeasure = "piece"
+	UnitKilogram   UnitOfMeasure = "kg"
+	UnitGram       UnitOfMeasure = "g"
+	UnitLitre      UnitOfMeasure = "litre"
+	UnitMillilitre UnitOfMeasure = "ml"
+	UnitMetre      UnitOfMeasure = "m"
+	UnitPack       UnitOfMeasure = "pack"
+	UnitBox        UnitOfMeasure = "box"
+)
+
+const (
+	MaxProductCodeLength = 64
+	MaxCategoryLength    = 50
+	MaxVariantAttributes = 5
+)
+
+var (
+	ErrInvalidUnit            = errors.New("unit must be one of piece, kg, g, litre, ml, m, pack, box")
+	ErrInvalidSKU             = errors.New("sku must be at most 64 characters")
+	ErrInvalidBarcode         = errors.New("barcode must be at most 64 characters without spaces")
+	ErrInvalidProductCategory = errors.New("category must be at most 50 characters")
+	ErrDuplicateSKU           = errors.New("another product of this business has this sku")
+	ErrDuplicateBarcode       = errors.New("another product of this business has this barcode")
+	ErrVariantAttributes      = errors.New("a variant needs 1 to 5 attributes, such as size or colour")
+	ErrNestedVariant          = errors.New("a variant cannot have variants of its own")
+	ErrProductHasVariants     = errors.New("the product has variants; delete them first")
+)
+
+// IsValidUnit reports whether u is a known unit of measure.
+func IsValidUnit(u UnitOfMeasure) bool {
+	switch u {
+	case UnitPiece, UnitKilogram, UnitGram, UnitLitre, UnitMillilitre, UnitMetre, UnitPack, UnitBox:
+		return true
+	}
+	return false
+}
+
+// ProductCategory is a category in use and how many products are in it.
+type ProductCategory struct {
+	Name     string `bson:"_id" json:"name"`
+	Products int    `bson:"products" json:"products"`
+}
+
+// NormalizeSKU trims a SKU and upper-cases it, so "ab-1" and "AB-1" are the
+// same SKU.
+func NormalizeSKU(sku string) string {
+	return strings.ToUpper(strings.TrimSpace(sku))
+}
+
+// NormalizeBarcode trims a scanned or typed barcode.
+func NormalizeBarcode(barcode string) string {
+	return strings.TrimSpace(barcode)
+}
+
+// NormalizeCategory trims a category and collapses runs of spaces.
+func NormalizeCategory(category string) string {
+	return strings.Join(strings.Fields(category), " ")
+}
+
+// Unit returns the product's unit of measure, a piece if none was set.
+func (p *Product) Unit() UnitOfMeasure {
+	if p.UnitOfMeasure == "" {
+		return UnitPiece
+	}
+	return p.UnitOfMeasure
+}
+
+// IsVariant reports whether the product is a variant of another.
+func (p *Product) IsVariant() bool {
+	return !p.ParentID.IsZero()
+}
+
+// NormalizeCatalog tidies the product's SKU, barcode, category and variant
+// attributes and checks them and its unit.
+func (p *Product) NormalizeCatalog() error {
+	p.SKU = NormalizeSKU(p.SKU)
+	p.Barcode = NormalizeBarcode(p.Barcode)
+	p.Category = NormalizeCategory(p.Category)
+	p.Attributes = normalizeAttributes(p.Attributes)
+
+	switch {
+	case len(p.SKU) > MaxProductCodeLength:
+		return ErrInvalidSKU
+	case len(p.Barcode) > MaxProductCodeLength || strings.ContainsAny(p.Barcode, " \t"):
+		return ErrInvalidBarcode
+	case len(p.Category) > MaxCategoryLength:
+		return ErrInvalidProductCategory
+	case p.UnitOfMeasure != "" && !IsValidUnit(p.UnitOfMeasure):
+		return ErrInvalidUnit
+	}
+	if p.IsVariant() && (len(p.Attributes) == 0 || len(p.Attributes) > MaxVariantAttributes) {
+		return ErrVariantAttributes
+	}
+	return nil
+}
+
+// normalizeAttributes lower-cases attribute names and drops empty ones, so
+// "Size" and "size " are the same attribute.
+func normalizeAttributes(attributes map[string]string) map[string]string {
+	if len(attributes) == 0 {
+		return nil
+	}
+	normalized := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key != "" && value != "" {
+			normalized[key] = value
+		}
+	}
+	return normalized
+}
+
+// VariantName names a variant after its parent and attribute values, such
+// as "T-shirt - Red / L" for colour Red and size L. Values follow the
+// attribute names in alphabetical order.
+func VariantName(parentName string, attributes map[string]string) string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, attributes[key])
+	}
+	return parentName + " - " + strings.Join(values, " / ")
+}
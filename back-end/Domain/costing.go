@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CostingMethod decides what the units leaving stock cost.
+type CostingMethod string
+
+const (
+	// CostingWeightedAverage costs units at the product's average unit cost
+	CostingWeightedAverage CostingMethod = "weighted_average"
+	// CostingFIFO costs units at the cost of the oldest stock still on hand
+	CostingFIFO CostingMethod = "fifo"
+)
+
+var ErrInvalidCostingMethod = errors.New("invalid costing method")
+
+// IsValidCostingMethod reports whether m is a known costing method.
+func IsValidCostingMethod(m CostingMethod) bool {
+	switch m {
+	case CostingWeightedAverage, CostingFIFO:
+		return true
+	}
+	return false
+}
+
+// CostLayer is one receipt of stock at a known unit cost. Remaining counts
+// the units of the receipt not yet sold or written off, oldest first.
+type CostLayer struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BusinessID primitive.ObjectID `bson:"business_id" json:"business_id"`
+	ProductID  primitive.ObjectID `bson:"product_id" json:"product_id"`
+	MovementID primitive.ObjectID `bson:"movement_id" json:"movement_id"`
+	UnitCost   decimal.Decimal    `bson:"unit_cost" json:"unit_cost"`
+	Quantity   decimal.Decimal    `bson:"quantity" json:"quantity"`
+	Remaining  decimal.Decimal    `bson:"remaining" json:"remaining"`
+	ReceivedAt time.Time          `bson:"received_at" json:"received_at"`
+}
+
+// CostLayerUse records the units a stock decrease took from one layer, so
+// the units can be put back if the decrease is undone.
+type CostLayerUse struct {
+	LayerID  primitive.ObjectID `bson:"layer_id" json:"layer_id"`
+	Quantity decimal.Decimal    `bson:"quantity" json:"quantity"`
+	UnitCost decimal.Decimal    `bson:"unit_cost" json:"unit_cost"`
+}
+
+// IssueCost is the cost of quantity units leaving stock. Under FIFO the units
+// taken from layers cost what the layers cost and any units no layer covered
+// cost the average; otherwise every unit costs the average.
+func IssueCost(method CostingMethod, quantity, averageCost decimal.Decimal, layers []CostLayerUse) decimal.Decimal {
+	if method != CostingFIFO {
+		return averageCost.Mul(quantity).Round(2)
+	}
+
+	cost := decimal.Zero
+	uncovered := quantity
+	for _, use := range layers {
+		cost = cost.Add(use.UnitCost.Mul(use.Quantity))
+		uncovered = uncovered.Sub(use.Quantity)
+	}
+	if uncovered.IsPositive() {
+		cost = cost.Add(averageCost.Mul(uncovered))
+	}
+	return cost.Round(2)
+}
+
+// GrossMargin is gross profit as a percentage of sales, rounded to two
+// places. It is zero when there were no sales.
+func GrossMargin(sales, costOfGoods decimal.Decimal) decimal.Decimal {
+	if sales.IsZero() {
+		return decimal.Zero
+	}
+	return sales.Sub(costOfGoods).Div(sales).Mul(decimal.NewFromInt(100)).Round(2)
+}
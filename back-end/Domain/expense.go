@@ -64,7 +64,13 @@ type Expense struct {
 	Amount     decimal.Decimal    `bson:"amount" json:"amount"`
 	Note       string             `bson:"note" json:"note"`
 	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  *time.Time         `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 	IsVoided   bool               `bson:"is_voided" json:"is_voided"`
+	Version    int64              `bson:"version" json:"version"`
+
+	// Set on the STOCK_PURCHASE expense a purchase order receipt records
+	SupplierID      *primitive.ObjectID `bson:"supplier_id,omitempty" json:"supplier_id,omitempty"`
+	PurchaseOrderID *primitive.ObjectID `bson:"purchase_order_id,omitempty" json:"purchase_order_id,omitempty"`
 }
 
 // NewExpense creates a new Expense instance
@@ -43,6 +43,7 @@ type ExportFilter struct {
 type ExportRepository interface {
 	Create(request *ExportRequest) error
 	GetByID(id string, businessID string) (*ExportRequest, error)
+	GetByFileURL(fileURL string) (*ExportRequest, error)
 	GetByBusiness(businessID string, limit, offset int) ([]ExportRequest, error)
 	UpdateStatus(id string, status ExportStatus, fileURL, errorMessage string) error
 	CountByBusiness(businessID string) (int64, error)
@@ -52,5 +53,6 @@ type ExportRepository interface {
 type ExportUsecases interface {
 	RequestExport(businessID, userID, exportType, format string, filters map[string]interface{}, fields []string) (*ExportRequest, error)
 	GetExportStatus(id, businessID string) (*ExportRequest, error)
+	GetExportByFilename(filename string) (*ExportRequest, error)
 	GetExportHistory(businessID string, page, limit int) ([]ExportRequest, int64, error)
 }
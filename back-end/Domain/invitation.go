@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InvitationStatus tracks an invitation from issue to use.
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationRevoked  InvitationStatus = "revoked"
+	// InvitationExpired is never stored; pending invitations past their
+	// expiry are reported as expired.
+	InvitationExpired InvitationStatus = "expired"
+)
+
+const (
+	DefaultInvitationTTL = 7 * 24 * time.Hour
+	MaxInvitationTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidPhone            = errors.New("invalid phone format")
+	ErrInvalidInvitationTTL    = errors.New("expires_in_hours must be between 1 and 720")
+	ErrInvitationNotFound      = errors.New("invitation not found")
+	ErrInvitationExpired       = errors.New("invitation has expired")
+	ErrInvitationUsed          = errors.New("invitation has already been used or revoked")
+	ErrInvitationPhoneMismatch = errors.New("invitation was issued to a different phone number")
+)
+
+// Invitation lets an owner bring a helper into a business. The code is only
+// shown when the invitation is created; the database keeps its hash.
+type Invitation struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	BusinessID primitive.ObjectID  `bson:"business_id" json:"business_id"`
+	Phone      string              `bson:"phone" json:"phone"`
+	Role       BusinessRole        `bson:"role" json:"role"`
+	CodeHash   string              `bson:"code_hash" json:"-"`
+	Status     InvitationStatus    `bson:"status" json:"status"`
+	InvitedBy  primitive.ObjectID  `bson:"invited_by" json:"invited_by"`
+	AcceptedBy *primitive.ObjectID `bson:"accepted_by,omitempty" json:"accepted_by,omitempty"`
+	ExpiresAt  time.Time           `bson:"expires_at" json:"expires_at"`
+	AcceptedAt *time.Time          `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time          `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+
+	// Code and Link are filled in only on the response to creation.
+	Code string `bson:"-" json:"code,omitempty"`
+	Link string `bson:"-" json:"link,omitempty"`
+}
+
+// StatusAt returns the invitation's status as seen at now.
+func (i *Invitation) StatusAt(now time.Time) InvitationStatus {
+	if i.Status == InvitationPending && !now.Before(i.ExpiresAt) {
+		return InvitationExpired
+	}
+	return i.Status
+}
+
+// CreateInvitationRequest invites a phone number to join with a role.
+type CreateInvitationRequest struct {
+	Phone          string       `json:"phone"`
+	Role           BusinessRole `json:"role"`
+	ExpiresInHours int          `json:"expires_in_hours"`
+}
+
+// AcceptInvitationRequest redeems an invitation for an existing account.
+type AcceptInvitationRequest struct {
+	Code string `json:"code"`
+}
+
+// InvitationPreview is what anyone holding a code may see before signing up.
+type InvitationPreview struct {
+	BusinessName string       `json:"business_name"`
+	Role         BusinessRole `json:"role"`
+	ExpiresAt    time.Time    `json:"expires_at"`
+}
@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BusinessRole is the part a user plays in a business. The user who created
+// a business is always its owner; staff join as managers or cashiers.
+type BusinessRole string
+
+const (
+	RoleOwner   BusinessRole = "owner"
+	RoleManager BusinessRole = "manager"
+	RoleCashier BusinessRole = "cashier"
+)
+
+// Permission names an action that can be granted to a role.
+type Permission string
+
+const (
+	PermissionViewBusiness   Permission = "business:view"
+	PermissionManageBusiness Permission = "business:manage"
+	PermissionViewMembers    Permission = "members:view"
+	PermissionManageMembers  Permission = "members:manage"
+	PermissionRecordSales    Permission = "sales:record"
+	PermissionViewSales      Permission = "sales:view"
+	PermissionEditSales      Permission = "sales:edit"
+	PermissionViewProducts   Permission = "products:view"
+	PermissionManageProducts Permission = "products:manage"
+	PermissionAdjustStock    Permission = "stock:adjust"
+	PermissionRecordExpenses Permission = "expenses:record"
+	PermissionManageExpenses Permission = "expenses:manage"
+	PermissionViewReports    Permission = "reports:view"
+	PermissionExportData     Permission = "data:export"
+	PermissionRestoreData    Permission = "data:restore"
+	PermissionSync           Permission = "sync:write"
+	PermissionManageSync     Permission = "sync:manage"
+	PermissionViewAudit      Permission = "audit:view"
+	PermissionManageAPIKeys  Permission = "api_keys:manage"
+	PermissionPurchaseStock  Permission = "purchases:manage"
+)
+
+// cashierPermissions covers working the till from a registered device.
+var cashierPermissions = []Permission{
+	PermissionViewBusiness,
+	PermissionRecordSales,
+	PermissionViewSales,
+	PermissionViewProducts,
+	PermissionRecordExpenses,
+	PermissionRestoreData,
+	PermissionSync,
+}
+
+// managerPermissions covers running the shop day to day. Only the owner may
+// change the business itself or who works there.
+var managerPermissions = append([]Permission{
+	PermissionViewMembers,
+	PermissionEditSales,
+	PermissionManageProducts,
+	PermissionAdjustStock,
+	PermissionPurchaseStock,
+	PermissionManageExpenses,
+	PermissionViewReports,
+	PermissionExportData,
+	PermissionManageSync,
+}, cashierPermissions...)
+
+var rolePermissions = map[BusinessRole]map[Permission]bool{
+	RoleOwner:   nil, // the owner may do everything
+	RoleManager: permissionSet(managerPermissions),
+	RoleCashier: permissionSet(cashierPermissions),
+}
+
+func permissionSet(permissions []Permission) map[Permission]bool {
+	set := make(map[Permission]bool, len(permissions))
+	for _, p := range permissions {
+		set[p] = true
+	}
+	return set
+}
+
+// IsValidBusinessRole reports whether r is a known role.
+func IsValidBusinessRole(r BusinessRole) bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants permission p.
+func (r BusinessRole) Can(p Permission) bool {
+	if r == RoleOwner {
+		return true
+	}
+	return rolePermissions[r][p]
+}
+
+// CanViewCosts reports whether the role may see purchase costs and cost of
+// goods, which give away the margins only reports show.
+func (r BusinessRole) CanViewCosts() bool {
+	return r.Can(PermissionViewReports)
+}
+
+// CanViewExpenses reports whether the role may read the expenses of the
+// business, not just record them.
+func (r BusinessRole) CanViewExpenses() bool {
+	return r.Can(PermissionManageExpenses)
+}
+
+// SyncPermission returns the permission needed to replay a sync transaction,
+// so offline devices cannot do more than the same user could online.
+func SyncPermission(t SyncTransactionType) Permission {
+	switch t {
+	case SyncTransactionTypeSale:
+		return PermissionRecordSales
+	case SyncTransactionTypeExpense:
+		return PermissionRecordExpenses
+	case SyncTransactionTypeProduct:
+		return PermissionManageProducts
+	case SyncTransactionTypeStockAdjustment:
+		return PermissionAdjustStock
+	case SyncTransactionTypeSaleVoid:
+		return PermissionEditSales
+	case SyncTransactionTypeExpenseUpdate:
+		return PermissionManageExpenses
+	}
+	return PermissionManageSync
+}
+
+var (
+	ErrBusinessNotFound  = errors.New("business not found")
+	ErrNotMember         = errors.New("user is not a member of this business")
+	ErrMemberNotFound    = errors.New("member not found")
+	ErrPhoneRequired     = errors.New("phone is required")
+	ErrUserNotRegistered = errors.New("no user is registered with this phone")
+	ErrAlreadyMember     = errors.New("user is already a member of this business")
+	ErrInvalidMemberRole = errors.New("role must be one of manager, cashier")
+	ErrOwnerMembership   = errors.New("the business owner's role cannot be changed or removed")
+	ErrPermissionDenied  = errors.New("your role does not allow this action")
+)
+
+// Membership gives a staff member a role in a business. Owners have no
+// membership record; ownership comes from Business.UserID.
+type Membership struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BusinessID primitive.ObjectID `bson:"business_id" json:"business_id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Role       BusinessRole       `bson:"role" json:"role"`
+	AddedBy    primitive.ObjectID `bson:"added_by" json:"added_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Member is a user's membership as shown in the member list.
+type Member struct {
+	UserID   string       `json:"user_id"`
+	Name     string       `json:"name"`
+	Phone    string       `json:"phone"`
+	Role     BusinessRole `json:"role"`
+	JoinedAt time.Time    `json:"joined_at"`
+}
+
+// AddMemberRequest adds an existing user to a business by phone number.
+type AddMemberRequest struct {
+	Phone string       `json:"phone"`
+	Role  BusinessRole `json:"role"`
+}
+
+// UpdateMemberRoleRequest changes a member's role.
+type UpdateMemberRoleRequest struct {
+	Role BusinessRole `json:"role"`
+}
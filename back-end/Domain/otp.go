@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OTPPurpose says what a one-time code may be used for. A code issued for
+// one purpose is never accepted for another.
+type OTPPurpose string
+
+const (
+	OTPPurposePasswordReset     OTPPurpose = "password_reset"
+	OTPPurposePhoneVerification OTPPurpose = "phone_verification"
+	// OTPPurposePhoneChange codes are sent to the new number, which is kept
+	// as the code's destination until it is confirmed
+	OTPPurposePhoneChange OTPPurpose = "phone_change"
+	OTPPurposeLogin       OTPPurpose = "login"
+)
+
+const (
+	OTPLength = 6
+	OTPTTL    = 10 * time.Minute
+	// OTPMaxAttempts is how many guesses a code allows before it is burned
+	OTPMaxAttempts = 5
+	// OTPResendInterval is the minimum gap between two codes for one purpose
+	OTPResendInterval = time.Minute
+)
+
+var (
+	ErrInvalidOTP          = errors.New("invalid or expired code")
+	ErrOTPAttemptsExceeded = errors.New("too many attempts; request a new code in a few minutes")
+	ErrPhoneNotVerified    = errors.New("phone number has not been verified")
+	ErrOTPResendTooSoon    = errors.New("a code was just sent to another number; wait a minute before requesting one for this number")
+)
+
+// OneTimeCode is a short-lived numeric code sent to a user. Only a hash of
+// the code is stored.
+type OneTimeCode struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Purpose     OTPPurpose         `bson:"purpose" json:"purpose"`
+	Destination string             `bson:"destination" json:"destination"`
+	CodeHash    string             `bson:"code_hash" json:"-"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt   time.Time          `bson:"expires_at" json:"expires_at"`
+	ConsumedAt  *time.Time         `bson:"consumed_at,omitempty" json:"consumed_at,omitempty"`
+}
+
+// UsableAt reports whether the code can still be tried at now.
+func (c *OneTimeCode) UsableAt(now time.Time) bool {
+	return c.ConsumedAt == nil && c.ExpiresAt.After(now) && c.Attempts < OTPMaxAttempts
+}
+
+// NotificationChannel is how a message reaches the user.
+type NotificationChannel string
+
+const (
+	NotificationSMS   NotificationChannel = "sms"
+	NotificationEmail NotificationChannel = "email"
+)
+
+// Notification is a message for one recipient.
+type Notification struct {
+	Channel NotificationChannel `json:"channel"`
+	To      string              `json:"to"`
+	Subject string              `json:"subject,omitempty"`
+	Body    string              `json:"body"`
+}
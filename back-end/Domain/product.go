@@ -1,27 +1,45 @@
 package domain
 
 import (
+	"errors"
 	"time"
 
 	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrInsufficientStock is wrapped by stock adjustments that would take a
+// product below zero.
+var ErrInsufficientStock = errors.New("insufficient stock")
 
 type Product struct {
 	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	BusinessID          primitive.ObjectID `bson:"business_id" json:"business_id"`
 	Name                string             `bson:"name" json:"name"`
+	SKU                 string             `bson:"sku,omitempty" json:"sku,omitempty"`
+	Barcode             string             `bson:"barcode,omitempty" json:"barcode,omitempty"`
+	Category            string             `bson:"category,omitempty" json:"category,omitempty"`
+	UnitOfMeasure       UnitOfMeasure      `bson:"unit,omitempty" json:"unit,omitempty"`
+	ParentID            primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"`   // Set on a variant: the product it is a variant of
+	Attributes          map[string]string  `bson:"attributes,omitempty" json:"attributes,omitempty"` // What sets a variant apart, such as size or colour
 	DefaultSellingPrice decimal.Decimal    `bson:"default_selling_price" json:"default_selling_price"`
-	StockQuantity       int                `bson:"stock_quantity" json:"stock_quantity"`
-	LowStockThreshold   int                `bson:"low_stock_threshold" json:"low_stock_threshold"`
+	UnitCost            decimal.Decimal    `bson:"unit_cost" json:"unit_cost"`           // Weighted-average purchase cost of the stock on hand
+	StockQuantity       decimal.Decimal    `bson:"stock_quantity" json:"stock_quantity"` // In the product's unit, fractional for weights and volumes
+	LowStockThreshold   decimal.Decimal    `bson:"low_stock_threshold" json:"low_stock_threshold"`
 	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at"`
+	Version             int64              `bson:"version" json:"version"`
 }
 
 // IsLowStock checks if the product stock is at or below the low stock threshold
 func (p *Product) IsLowStock() bool {
-	return p.StockQuantity <= p.LowStockThreshold
+	return p.StockQuantity.LessThanOrEqual(p.LowStockThreshold)
+}
+
+// HideCost clears the product's purchase cost, for a role that may not see
+// costs.
+func (p *Product) HideCost() {
+	p.UnitCost = decimal.Zero
 }
 
 // StockMovement represents history of stock changes
@@ -30,13 +48,24 @@ type StockMovement struct {
 	BusinessID  primitive.ObjectID  `bson:"business_id" json:"business_id"`
 	ProductID   primitive.ObjectID  `bson:"product_id" json:"product_id"`
 	Type        MovementType        `bson:"type" json:"type"`
-	Quantity    int                 `bson:"quantity" json:"quantity"`
+	Quantity    decimal.Decimal     `bson:"quantity" json:"quantity"`
 	Reason      string              `bson:"reason,omitempty" json:"reason,omitempty"`
 	ReferenceID *primitive.ObjectID `bson:"reference_id,omitempty" json:"reference_id,omitempty"` // Links to sale/expense ID
+	UnitCost    decimal.Decimal     `bson:"unit_cost,omitempty" json:"unit_cost"`                 // Purchase cost per unit received, or cost of goods per unit taken out
+	Cost        decimal.Decimal     `bson:"cost,omitempty" json:"cost"`
+	Layers      []CostLayerUse      `bson:"layers,omitempty" json:"-"` // FIFO layers a decrease took from
 	CreatedBy   primitive.ObjectID  `bson:"created_by" json:"created_by"`
 	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
 }
 
+// HideCost clears the purchase cost or cost of goods of the movement, for a
+// role that may not see costs.
+func (m *StockMovement) HideCost() {
+	m.UnitCost = decimal.Zero
+	m.Cost = decimal.Zero
+	m.Layers = nil
+}
+
 type MovementType string
 
 const (
@@ -50,13 +79,15 @@ const (
 
 // StockMovementResponse for API responses
 type StockMovementResponse struct {
-	ID          string       `json:"id"`
-	Type        MovementType `json:"type"`
-	Quantity    int          `json:"quantity"` // Positive for increase, negative for decrease
-	Reason      string       `json:"reason,omitempty"`
-	ReferenceID *string      `json:"reference_id,omitempty"` // Only present if linked to a transaction
-	CreatedBy   string       `json:"created_by"`
-	CreatedAt   time.Time    `json:"created_at"`
+	ID          string          `json:"id"`
+	Type        MovementType    `json:"type"`
+	Quantity    decimal.Decimal `json:"quantity"` // Positive for increase, negative for decrease
+	Reason      string          `json:"reason,omitempty"`
+	ReferenceID *string         `json:"reference_id,omitempty"` // Only present if linked to a transaction
+	UnitCost    decimal.Decimal `json:"unit_cost"`
+	Cost        decimal.Decimal `json:"cost"`
+	CreatedBy   string          `json:"created_by"`
+	CreatedAt   time.Time       `json:"created_at"`
 
 	// Optional: Include product info for list views
 	ProductID   string `json:"product_id,omitempty"`
@@ -65,36 +96,69 @@ type StockMovementResponse struct {
 
 // Request/Response structs
 type CreateProductRequest struct {
-	BusinessID          string  `json:"business_id" binding:"required"`
-	Name                string  `json:"name" binding:"required"`
-	DefaultSellingPrice float64 `json:"default_selling_price" binding:"required,gt=0"`
-	StockQuantity       int     `json:"stock_quantity" binding:"gte=0"`
-	LowStockThreshold   int     `json:"low_stock_threshold" binding:"gte=0"`
+	BusinessID          string        `json:"business_id" binding:"required"`
+	Name                string        `json:"name" binding:"required"`
+	SKU                 string        `json:"sku"`
+	Barcode             string        `json:"barcode"`
+	Category            string        `json:"category"`
+	Unit                UnitOfMeasure `json:"unit"` // piece if not given
+	DefaultSellingPrice float64       `json:"default_selling_price" binding:"required,gt=0"`
+	UnitCost            float64       `json:"unit_cost" binding:"gte=0"` // Purchase cost of the initial stock
+	StockQuantity       float64       `json:"stock_quantity" binding:"gte=0"`
+	LowStockThreshold   float64       `json:"low_stock_threshold" binding:"gte=0"`
 }
 
 type UpdateProductRequest struct {
-	BusinessID          string   `json:"business_id" binding:"required"`
-	Name                *string  `json:"name,omitempty"`
-	DefaultSellingPrice *float64 `json:"default_selling_price,omitempty" binding:"omitempty,gt=0"`
-	LowStockThreshold   *int     `json:"low_stock_threshold,omitempty" binding:"omitempty,gte=0"`
+	BusinessID          string         `json:"business_id" binding:"required"`
+	Name                *string        `json:"name,omitempty"`
+	SKU                 *string        `json:"sku,omitempty"`      // An empty string clears it
+	Barcode             *string        `json:"barcode,omitempty"`  // An empty string clears it
+	Category            *string        `json:"category,omitempty"` // An empty string clears it
+	Unit                *UnitOfMeasure `json:"unit,omitempty"`
+	DefaultSellingPrice *float64       `json:"default_selling_price,omitempty" binding:"omitempty,gt=0"`
+	LowStockThreshold   *float64       `json:"low_stock_threshold,omitempty" binding:"omitempty,gte=0"`
+}
+
+// CreateVariantRequest adds a variant, such as a size or colour, to a
+// product. The variant keeps its own stock; fields left out are taken from
+// the product.
+type CreateVariantRequest struct {
+	BusinessID          string            `json:"business_id" binding:"required"`
+	Attributes          map[string]string `json:"attributes" binding:"required"`
+	Name                string            `json:"name"` // Defaults to the product's name and the attribute values
+	SKU                 string            `json:"sku"`
+	Barcode             string            `json:"barcode"`
+	DefaultSellingPrice *float64          `json:"default_selling_price,omitempty" binding:"omitempty,gt=0"`
+	UnitCost            float64           `json:"unit_cost" binding:"gte=0"`
+	StockQuantity       float64           `json:"stock_quantity" binding:"gte=0"`
+	LowStockThreshold   *float64          `json:"low_stock_threshold,omitempty" binding:"omitempty,gte=0"`
 }
 
 type AdjustStockRequest struct {
 	BusinessID string       `json:"business_id" binding:"required"`
-	Quantity   int          `json:"quantity" binding:"required"`
+	Quantity   float64      `json:"quantity" binding:"required"`
 	Type       MovementType `json:"type" binding:"required"`
 	Reason     string       `json:"reason" binding:"required"`
+	UnitCost   *float64     `json:"unit_cost,omitempty" binding:"omitempty,gte=0"` // Purchase cost of stock received; purchases and returns only
 }
 
 type ProductResponse struct {
-	ID                  string          `json:"id"`
-	Name                string          `json:"name"`
-	DefaultSellingPrice decimal.Decimal `json:"default_selling_price"`
-	StockQuantity       int             `json:"stock_quantity"`
-	LowStockThreshold   int             `json:"low_stock_threshold"`
-	IsLowStock          bool            `json:"is_low_stock"`
-	CreatedAt           time.Time       `json:"created_at"`
-	UpdatedAt           time.Time       `json:"updated_at"`
+	ID                  string            `json:"id"`
+	Name                string            `json:"name"`
+	SKU                 string            `json:"sku,omitempty"`
+	Barcode             string            `json:"barcode,omitempty"`
+	Category            string            `json:"category,omitempty"`
+	Unit                UnitOfMeasure     `json:"unit"`
+	ParentID            string            `json:"parent_id,omitempty"`
+	Attributes          map[string]string `json:"attributes,omitempty"`
+	DefaultSellingPrice decimal.Decimal   `json:"default_selling_price"`
+	UnitCost            decimal.Decimal   `json:"unit_cost"`
+	StockQuantity       decimal.Decimal   `json:"stock_quantity"`
+	LowStockThreshold   decimal.Decimal   `json:"low_stock_threshold"`
+	IsLowStock          bool              `json:"is_low_stock"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+	Variants            []ProductResponse `json:"variants,omitempty"` // Only on a single product
 }
 
 type ProductListResponse struct {
@@ -111,7 +175,11 @@ type PaginationMetadata struct {
 
 // Query parameters for list products
 type ProductListQuery struct {
-	Search       string `form:"search"`
+	Search       string `form:"search"`  // Matches name, sku or barcode
+	Barcode      string `form:"barcode"` // Exact barcode, for scanners
+	SKU          string `form:"sku"`
+	Category     string `form:"category"`
+	ParentID     string `form:"parent_id"` // Variants of one product
 	LowStockOnly bool   `form:"low_stock_only"`
 	Page         int    `form:"page,default=1"`
 	Limit        int    `form:"limit,default=50"`
@@ -128,7 +196,16 @@ type ProductRepository interface {
 	FindSince(businessID string, since time.Time) ([]Product, error)
 	Update(product *Product) error
 	Delete(id string) error
-	AdjustStock(productID string, quantity int, movementType MovementType, reason string, referenceID *string, userID string) error
+	AdjustStock(productID string, quantity decimal.Decimal, movementType MovementType, reason string, referenceID *string, userID string) error
+	// ReceiveStock adds stock at a known unit cost, blending it into the
+	// product's average cost
+	ReceiveStock(productID string, quantity, unitCost decimal.Decimal, movementType MovementType, reason string, referenceID *string, userID string) error
+	// TakeStock removes stock like AdjustStock and returns its cost of goods
+	TakeStock(productID string, quantity decimal.Decimal, movementType MovementType, reason string, referenceID *string, userID string) (decimal.Decimal, error)
 	GetLowStock(businessID string) ([]Product, error)
+	// FindVariants lists the variants of a product
+	FindVariants(parentID string) ([]Product, error)
+	// GetCategories lists the categories in use by a business's products
+	GetCategories(businessID string) ([]ProductCategory, error)
 	GetStockHistory(productID string, limit int) ([]StockMovement, error)
 }
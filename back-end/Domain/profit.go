@@ -1,8 +1,13 @@
 package domain
 
-// ProfitSummaryResponse holds aggregated profit metrics for a period
+// ProfitSummaryResponse holds aggregated profit metrics for a period.
+// Gross profit is sales less the cost of the goods sold. Net profit stays
+// sales less expenses, so stock bought counts when it is paid for.
 type ProfitSummaryResponse struct {
 	TotalSales        float64 `json:"total_sales"`
+	CostOfGoods       float64 `json:"cost_of_goods"`
+	GrossProfit       float64 `json:"gross_profit"`
+	GrossMargin       float64 `json:"gross_margin"` // Percentage of sales
 	TotalExpenses     float64 `json:"total_expenses"`
 	NetProfit         float64 `json:"net_profit"`
 	Period            string  `json:"period,omitempty"`
@@ -12,6 +17,9 @@ type ProfitSummaryResponse struct {
 type ProfitTrendDataPoint struct {
 	Date          string  `json:"date"`
 	TotalSales    float64 `json:"total_sales"`
+	CostOfGoods   float64 `json:"cost_of_goods"`
+	GrossProfit   float64 `json:"gross_profit"`
+	GrossMargin   float64 `json:"gross_margin"`
 	TotalExpenses float64 `json:"total_expenses"`
 	NetProfit     float64 `json:"net_profit"`
 }
@@ -22,6 +30,12 @@ type ProfitTrendsResponse struct {
 	Period string                 `json:"period,omitempty"`
 }
 
+// ProfitByProductResponse holds the gross margin of each product sold in a period
+type ProfitByProductResponse struct {
+	Products []ProductMargin `json:"products"`
+	Period   string          `json:"period,omitempty"`
+}
+
 // ProfitCompareResponse compares two periods
 type ProfitCompareResponse struct {
 	Current   ProfitSummaryResponse `json:"current"`
@@ -0,0 +1,351 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PurchaseOrderStatus tracks a purchase order from draft to received.
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderDraft             PurchaseOrderStatus = "draft"
+	PurchaseOrderOrdered           PurchaseOrderStatus = "ordered"
+	PurchaseOrderPartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseOrderReceived          PurchaseOrderStatus = "received"
+	PurchaseOrderCancelled         PurchaseOrderStatus = "cancelled"
+)
+
+// MaxSupplierNameLength bounds a supplier's name.
+const MaxSupplierNameLength = 100
+
+var (
+	ErrSupplierNotFound          = errors.New("supplier not found")
+	ErrSupplierNameRequired      = errors.New("name is required and must be at most 100 characters")
+	ErrPurchaseOrderNotFound     = errors.New("purchase order not found")
+	ErrPurchaseOrderNoLines      = errors.New("a purchase order needs at least one line")
+	ErrInvalidPurchaseLine       = errors.New("invalid purchase order line")
+	ErrPurchaseOrderNotDraft     = errors.New("only draft purchase orders can be changed")
+	ErrPurchaseOrderNotOpen      = errors.New("purchase order is not open for receiving")
+	ErrPurchaseOrderConflict     = errors.New("purchase order was changed by another request")
+	ErrInvalidPurchaseOrderState = errors.New("invalid purchase order status change")
+	ErrNothingToReceive          = errors.New("nothing left to receive")
+	ErrOverReceipt               = errors.New("cannot receive more than is outstanding")
+	ErrInvalidPurchaseStatus     = errors.New("status must be one of draft, ordered, partially_received, received, cancelled")
+	ErrInvalidSpendPeriod        = errors.New("invalid supplier spend period")
+)
+
+// IsValidPurchaseOrderStatus reports whether s is a known status.
+func IsValidPurchaseOrderStatus(s PurchaseOrderStatus) bool {
+	switch s {
+	case PurchaseOrderDraft, PurchaseOrderOrdered, PurchaseOrderPartiallyReceived, PurchaseOrderReceived, PurchaseOrderCancelled:
+		return true
+	}
+	return false
+}
+
+// Supplier is someone a business buys stock from.
+type Supplier struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BusinessID primitive.ObjectID `bson:"business_id" json:"business_id"`
+	Name       string             `bson:"name" json:"name"`
+	Phone      string             `bson:"phone,omitempty" json:"phone,omitempty"`
+	Email      string             `bson:"email,omitempty" json:"email,omitempty"`
+	Note       string             `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// PurchaseOrderLine is one product ordered. Received counts the units that
+// have arrived so far.
+type PurchaseOrderLine struct {
+	ProductID primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Quantity  decimal.Decimal    `bson:"quantity" json:"quantity"`
+	UnitCost  decimal.Decimal    `bson:"unit_cost" json:"unit_cost"`
+	Received  decimal.Decimal    `bson:"received" json:"received"`
+}
+
+// Outstanding is the number of units still to arrive.
+func (l PurchaseOrderLine) Outstanding() decimal.Decimal {
+	return l.Quantity.Sub(l.Received)
+}
+
+// Total is what the line costs in full.
+func (l PurchaseOrderLine) Total() decimal.Decimal {
+	return l.UnitCost.Mul(l.Quantity).Round(2)
+}
+
+// PurchaseReceiptLine is the stock of one product that arrived in a receipt.
+type PurchaseReceiptLine struct {
+	ProductID  primitive.ObjectID `bson:"product_id" json:"product_id"`
+	Quantity   decimal.Decimal    `bson:"quantity" json:"quantity"`
+	UnitCost   decimal.Decimal    `bson:"unit_cost" json:"unit_cost"`
+	MovementID primitive.ObjectID `bson:"movement_id,omitempty" json:"movement_id,omitempty"`
+}
+
+// PurchaseReceipt is one delivery against an order. Receiving it brings the
+// stock in as purchase movements and records the STOCK_PURCHASE expense.
+type PurchaseReceipt struct {
+	ID         primitive.ObjectID    `bson:"_id" json:"id"`
+	Lines      []PurchaseReceiptLine `bson:"lines" json:"lines"`
+	Amount     decimal.Decimal       `bson:"amount" json:"amount"`
+	ExpenseID  primitive.ObjectID    `bson:"expense_id" json:"expense_id"`
+	ReceivedBy primitive.ObjectID    `bson:"received_by" json:"received_by"`
+	ReceivedAt time.Time             `bson:"received_at" json:"received_at"`
+}
+
+// PurchaseOrder is stock ordered from a supplier. It moves from draft to
+// ordered, then to received through one or more receipts; an order that
+// will not be filled can be cancelled.
+type PurchaseOrder struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	BusinessID  primitive.ObjectID  `bson:"business_id" json:"business_id"`
+	SupplierID  primitive.ObjectID  `bson:"supplier_id" json:"supplier_id"`
+	Status      PurchaseOrderStatus `bson:"status" json:"status"`
+	Lines       []PurchaseOrderLine `bson:"lines" json:"lines"`
+	Total       decimal.Decimal     `bson:"total" json:"total"`
+	Note        string              `bson:"note,omitempty" json:"note,omitempty"`
+	Receipts    []PurchaseReceipt   `bson:"receipts" json:"receipts"`
+	CreatedBy   primitive.ObjectID  `bson:"created_by" json:"created_by"`
+	OrderedAt   *time.Time          `bson:"ordered_at,omitempty" json:"ordered_at,omitempty"`
+	ReceivedAt  *time.Time          `bson:"received_at,omitempty" json:"received_at,omitempty"`
+	CancelledAt *time.Time          `bson:"cancelled_at,omitempty" json:"cancelled_at,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time           `bson:"updated_at" json:"updated_at"`
+	Version     int64               `bson:"version" json:"version"`
+}
+
+// NewPurchaseOrder starts a draft order for lines.
+func NewPurchaseOrder(businessID, supplierID primitive.ObjectID, lines []PurchaseOrderLine, note string, createdBy primitive.ObjectID) *PurchaseOrder {
+	now := time.Now()
+	order := &PurchaseOrder{
+		ID:         primitive.NewObjectID(),
+		BusinessID: businessID,
+		SupplierID: supplierID,
+		Status:     PurchaseOrderDraft,
+		Note:       note,
+		Receipts:   []PurchaseReceipt{},
+		CreatedBy:  createdBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Version:    1,
+	}
+	order.SetLines(lines)
+	return order
+}
+
+// SetLines replaces the order's lines and recomputes its total.
+func (o *PurchaseOrder) SetLines(lines []PurchaseOrderLine) {
+	o.Lines = lines
+	o.Total = decimal.Zero
+	for _, line := range lines {
+		o.Total = o.Total.Add(line.Total())
+	}
+}
+
+// Validate checks the order's lines. Each product may appear once, so a
+// receipt can name the line by its product.
+func (o *PurchaseOrder) Validate() error {
+	if len(o.Lines) == 0 {
+		return ErrPurchaseOrderNoLines
+	}
+	seen := make(map[primitive.ObjectID]bool, len(o.Lines))
+	for i, line := range o.Lines {
+		switch {
+		case line.ProductID.IsZero():
+			return fmt.Errorf("%w %d: product_id is required", ErrInvalidPurchaseLine, i+1)
+		case seen[line.ProductID]:
+			return fmt.Errorf("%w %d: product appears on another line", ErrInvalidPurchaseLine, i+1)
+		case !line.Quantity.IsPositive():
+			return fmt.Errorf("%w %d: quantity must be positive", ErrInvalidPurchaseLine, i+1)
+		case line.UnitCost.IsNegative():
+			return fmt.Errorf("%w %d: unit_cost cannot be negative", ErrInvalidPurchaseLine, i+1)
+		}
+		seen[line.ProductID] = true
+	}
+	return nil
+}
+
+// Line returns the index of the line for productID, or -1.
+func (o *PurchaseOrder) Line(productID primitive.ObjectID) int {
+	for i, line := range o.Lines {
+		if line.ProductID == productID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Place sends a draft order to the supplier.
+func (o *PurchaseOrder) Place(now time.Time) error {
+	if o.Status != PurchaseOrderDraft {
+		return fmt.Errorf("%w: cannot order a %s purchase order", ErrInvalidPurchaseOrderState, o.Status)
+	}
+	o.Status = PurchaseOrderOrdered
+	o.OrderedAt = &now
+	o.UpdatedAt = now
+	return nil
+}
+
+// Cancel closes an order that will not be filled. Stock already received
+// stays; only fully received and already cancelled orders cannot be
+// cancelled.
+func (o *PurchaseOrder) Cancel(now time.Time) error {
+	switch o.Status {
+	case PurchaseOrderDraft, PurchaseOrderOrdered, PurchaseOrderPartiallyReceived:
+	default:
+		return fmt.Errorf("%w: cannot cancel a %s purchase order", ErrInvalidPurchaseOrderState, o.Status)
+	}
+	o.Status = PurchaseOrderCancelled
+	o.CancelledAt = &now
+	o.UpdatedAt = now
+	return nil
+}
+
+// Receive books a delivery against the order. Each receipt line names an
+// ordered product and may not bring more than is outstanding for it. The
+// receipt is appended to the order, which becomes partially received or,
+// once nothing is outstanding, received.
+func (o *PurchaseOrder) Receive(lines []PurchaseReceiptLine, receivedBy primitive.ObjectID, now time.Time) (*PurchaseReceipt, error) {
+	if o.Status != PurchaseOrderOrdered && o.Status != PurchaseOrderPartiallyReceived {
+		return nil, ErrPurchaseOrderNotOpen
+	}
+	if len(lines) == 0 {
+		return nil, ErrNothingToReceive
+	}
+
+	received := make([]decimal.Decimal, len(o.Lines))
+	amount := decimal.Zero
+	for i, line := range lines {
+		index := o.Line(line.ProductID)
+		switch {
+		case index < 0:
+			return nil, fmt.Errorf("%w %d: product is not on this purchase order", ErrInvalidPurchaseLine, i+1)
+		case !line.Quantity.IsPositive():
+			return nil, fmt.Errorf("%w %d: quantity must be positive", ErrInvalidPurchaseLine, i+1)
+		case line.UnitCost.IsNegative():
+			return nil, fmt.Errorf("%w %d: unit_cost cannot be negative", ErrInvalidPurchaseLine, i+1)
+		}
+		received[index] = received[index].Add(line.Quantity)
+		if received[index].GreaterThan(o.Lines[index].Outstanding()) {
+			return nil, fmt.Errorf("%w on line %d: %s outstanding", ErrOverReceipt, i+1, o.Lines[index].Outstanding())
+		}
+		amount = amount.Add(line.UnitCost.Mul(line.Quantity))
+	}
+
+	outstanding := decimal.Zero
+	for i := range o.Lines {
+		o.Lines[i].Received = o.Lines[i].Received.Add(received[i])
+		outstanding = outstanding.Add(o.Lines[i].Outstanding())
+	}
+	o.Status = PurchaseOrderPartiallyReceived
+	if outstanding.IsZero() {
+		o.Status = PurchaseOrderReceived
+		o.ReceivedAt = &now
+	}
+	o.UpdatedAt = now
+
+	o.Receipts = append(o.Receipts, PurchaseReceipt{
+		ID:         primitive.NewObjectID(),
+		Lines:      lines,
+		Amount:     amount.Round(2),
+		ReceivedBy: receivedBy,
+		ReceivedAt: now,
+	})
+	return &o.Receipts[len(o.Receipts)-1], nil
+}
+
+// OutstandingLines is a receipt for everything still to arrive, at the
+// ordered costs.
+func (o *PurchaseOrder) OutstandingLines() []PurchaseReceiptLine {
+	var lines []PurchaseReceiptLine
+	for _, line := range o.Lines {
+		if line.Outstanding().IsPositive() {
+			lines = append(lines, PurchaseReceiptLine{ProductID: line.ProductID, Quantity: line.Outstanding(), UnitCost: line.UnitCost})
+		}
+	}
+	return lines
+}
+
+// SupplierSpend is what a business paid one supplier for stock in a period.
+type SupplierSpend struct {
+	SupplierID     primitive.ObjectID `bson:"_id" json:"supplier_id"`
+	SupplierName   string             `bson:"supplier_name" json:"supplier_name"`
+	Amount         decimal.Decimal    `bson:"amount" json:"amount"`
+	Receipts       int                `bson:"receipts" json:"receipts"`
+	LastReceivedAt time.Time          `bson:"last_received_at" json:"last_received_at"`
+}
+
+// SupplierSpendResponse lists spend per supplier, largest first.
+type SupplierSpendResponse struct {
+	Suppliers []SupplierSpend `json:"suppliers"`
+	Total     decimal.Decimal `json:"total"`
+	Period    string          `json:"period,omitempty"`
+}
+
+// SupplierRequest creates or updates a supplier.
+type SupplierRequest struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+	Note  string `json:"note"`
+}
+
+// Normalize trims the request and checks the name.
+func (r *SupplierRequest) Normalize() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Phone = strings.TrimSpace(r.Phone)
+	r.Email = strings.TrimSpace(r.Email)
+	r.Note = strings.TrimSpace(r.Note)
+	if r.Name == "" || len(r.Name) > MaxSupplierNameLength {
+		return ErrSupplierNameRequired
+	}
+	return nil
+}
+
+// PurchaseOrderLineRequest is one line of a purchase order request.
+type PurchaseOrderLineRequest struct {
+	ProductID string  `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+	UnitCost  float64 `json:"unit_cost"`
+}
+
+// PurchaseOrderRequest creates a draft order, or replaces a draft's
+// supplier, lines and note.
+type PurchaseOrderRequest struct {
+	SupplierID string                     `json:"supplier_id"`
+	Lines      []PurchaseOrderLineRequest `json:"lines"`
+	Note       string                     `json:"note"`
+}
+
+// ReceiveLineRequest is the stock of one product that arrived. UnitCost
+// defaults to the ordered cost.
+type ReceiveLineRequest struct {
+	ProductID string   `json:"product_id"`
+	Quantity  float64  `json:"quantity"`
+	UnitCost  *float64 `json:"unit_cost"`
+}
+
+// ReceivePurchaseOrderRequest books a delivery. With no lines, everything
+// still outstanding is received at the ordered costs.
+type ReceivePurchaseOrderRequest struct {
+	Lines []ReceiveLineRequest `json:"lines"`
+	Note  string               `json:"note"`
+}
+
+// PurchaseOrderQuery filters the purchase orders listed.
+type PurchaseOrderQuery struct {
+	Status     PurchaseOrderStatus `form:"status"`
+	SupplierID string              `form:"supplier_id"`
+}
+
+// SupplierSpendQuery picks the period of a supplier spend report.
+type SupplierSpendQuery struct {
+	StartDate string `form:"start_date"`
+	EndDate   string `form:"end_date"`
+}
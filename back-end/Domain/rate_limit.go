@@ -0,0 +1,70 @@
+package domain
+
+import "time"
+
+// RateLimitPolicy allows Limit hits per Window for one key.
+type RateLimitPolicy struct {
+	Limit  int
+	Window time.Duration
+}
+
+// LockoutPolicy locks an account after MaxFailures failed sign-ins within
+// Window. Each further lockout doubles the lock, from BaseLock up to MaxLock.
+type LockoutPolicy struct {
+	MaxFailures int
+	Window      time.Duration
+	BaseLock    time.Duration
+	MaxLock     time.Duration
+	// Memory is how long past lockouts keep counting towards the next one
+	Memory time.Duration
+}
+
+var (
+	// DefaultIPRateLimit bounds requests to the auth endpoints from one IP.
+	// It is generous because a whole shop's staff may share one address.
+	DefaultIPRateLimit = RateLimitPolicy{Limit: 100, Window: 15 * time.Minute}
+
+	// DefaultCodeSendLimit bounds the codes sent by SMS to one phone number,
+	// whichever IPs ask for them.
+	DefaultCodeSendLimit = RateLimitPolicy{Limit: 5, Window: time.Hour}
+
+	DefaultLockoutPolicy = LockoutPolicy{
+		MaxFailures: 5,
+		Window:      15 * time.Minute,
+		BaseLock:    time.Minute,
+		MaxLock:     time.Hour,
+		Memory:      24 * time.Hour,
+	}
+)
+
+// LockDuration is how long the account is locked for its lockouts-th lockout,
+// counting from zero.
+func (p LockoutPolicy) LockDuration(lockouts int) time.Duration {
+	lock := p.BaseLock
+	for i := 0; i < lockouts && lock < p.MaxLock; i++ {
+		lock *= 2
+	}
+	if lock > p.MaxLock {
+		lock = p.MaxLock
+	}
+	return lock
+}
+
+// RateLimitCounter is the stored state of one rate limit key, such as an IP
+// or a phone number.
+type RateLimitCounter struct {
+	Key         string     `bson:"_id" json:"key"`
+	Count       int        `bson:"count" json:"count"`
+	WindowStart time.Time  `bson:"window_start" json:"window_start"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	Lockouts    int        `bson:"lockouts" json:"lockouts"`
+	ExpiresAt   time.Time  `bson:"expires_at" json:"expires_at"`
+}
+
+// LockedAt reports how much longer the key stays locked at now, or zero.
+func (c *RateLimitCounter) LockedAt(now time.Time) time.Duration {
+	if c == nil || c.LockedUntil == nil || !c.LockedUntil.After(now) {
+		return 0
+	}
+	return c.LockedUntil.Sub(now)
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshTokenTTL is how long a refresh token can be exchanged for a new pair.
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; please log in again")
+)
+
+// RefreshToken is the server-side record of an issued refresh token. Every
+// login starts a new family; each refresh marks the presented token used and
+// issues its successor in the same family. Presenting a used token again means
+// it was stolen or replayed, so the whole family is revoked.
+type RefreshToken struct {
+	ID         primitive.ObjectID  `bson:"_id" json:"id"`
+	UserID     primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	FamilyID   primitive.ObjectID  `bson:"family_id" json:"family_id"`
+	ReplacedBy *primitive.ObjectID `bson:"replaced_by,omitempty" json:"replaced_by,omitempty"`
+	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
+	ExpiresAt  time.Time           `bson:"expires_at" json:"expires_at"`
+	UsedAt     *time.Time          `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	RevokedAt  *time.Time          `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// NewRefreshToken creates the next token of familyID for userID.
+func NewRefreshToken(userID, familyID primitive.ObjectID) *RefreshToken {
+	now := time.Now()
+	return &RefreshToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	}
+}
@@ -27,13 +27,43 @@ type TopProduct struct {
 	ProductID   *primitive.ObjectID `json:"product_id"`
 	ProductName string              `json:"product_name"`
 	TotalSales  decimal.Decimal     `json:"total_sales"`
-	Quantity    int                 `json:"quantity"`
+	CostOfGoods decimal.Decimal     `json:"cost_of_goods"`
+	GrossProfit decimal.Decimal     `json:"gross_profit"`
+	GrossMargin decimal.Decimal     `json:"gross_margin"` // Percentage of sales
+	Quantity    decimal.Decimal     `json:"quantity"`
+	Orders      int                 `json:"orders"` // Number of sales with the product in the basket
+}
+
+// ProductMargin is the gross margin one product made over a period. Lines
+// sold without a product are grouped under a nil ProductID.
+type ProductMargin struct {
+	ProductID   *primitive.ObjectID `json:"product_id"`
+	ProductName string              `json:"product_name"`
+	Quantity    decimal.Decimal     `json:"quantity"`
+	Sales       decimal.Decimal     `json:"sales"`
+	CostOfGoods decimal.Decimal     `json:"cost_of_goods"`
+	GrossProfit decimal.Decimal     `json:"gross_profit"`
+	GrossMargin decimal.Decimal     `json:"gross_margin"` // Percentage of sales
+}
+
+// NewProductMargin creates a ProductMargin and calculates its gross profit
+func NewProductMargin(productID *primitive.ObjectID, name string, quantity, sales, costOfGoods decimal.Decimal) ProductMargin {
+	return ProductMargin{
+		ProductID:   productID,
+		ProductName: name,
+		Quantity:    quantity,
+		Sales:       sales,
+		CostOfGoods: costOfGoods,
+		GrossProfit: sales.Sub(costOfGoods),
+		GrossMargin: GrossMargin(sales, costOfGoods),
+	}
 }
 
 // SalesReport represents sales analytics for a period
 type SalesReport struct {
 	TotalSales  decimal.Decimal `json:"total_sales"`
 	TotalOrders int             `json:"total_orders"`
+	TotalItems  int             `json:"total_items"` // Units sold across all sale lines
 	TopProducts []TopProduct    `json:"top_products"`
 	StartDate   time.Time       `json:"start_date"`
 	EndDate     time.Time       `json:"end_date"`
@@ -73,11 +103,16 @@ type ExpenseGroup struct {
 	Count       int             `json:"count"`
 }
 
-// ProfitSummary represents the calculated profit for a period
+// ProfitSummary represents the calculated profit for a period. Gross profit
+// is sales less the cost of the goods sold; profit is sales less expenses.
 type ProfitSummary struct {
 	TotalSales    decimal.Decimal `json:"total_sales"`
+	CostOfGoods   decimal.Decimal `json:"cost_of_goods"`
+	GrossProfit   decimal.Decimal `json:"gross_profit"`
+	GrossMargin   decimal.Decimal `json:"gross_margin"` // Percentage of sales
 	TotalExpenses decimal.Decimal `json:"total_expenses"`
 	Profit        decimal.Decimal `json:"profit"`
+	Products      []ProductMargin `json:"products"`
 	StartDate     time.Time       `json:"start_date"`
 	EndDate       time.Time       `json:"end_date"`
 	GroupBy       GroupBy         `json:"group_by,omitempty"`
@@ -86,18 +121,21 @@ type ProfitSummary struct {
 
 // ProfitGroup represents grouped profit data
 type ProfitGroup struct {
-	Period   string          `json:"period"`
-	Sales    decimal.Decimal `json:"sales"`
-	Expenses decimal.Decimal `json:"expenses"`
-	Profit   decimal.Decimal `json:"profit"`
+	Period      string          `json:"period"`
+	Sales       decimal.Decimal `json:"sales"`
+	CostOfGoods decimal.Decimal `json:"cost_of_goods"`
+	GrossProfit decimal.Decimal `json:"gross_profit"`
+	GrossMargin decimal.Decimal `json:"gross_margin"`
+	Expenses    decimal.Decimal `json:"expenses"`
+	Profit      decimal.Decimal `json:"profit"`
 }
 
 // InventoryItem represents inventory status for a product
 type InventoryItem struct {
 	ProductID         primitive.ObjectID `json:"product_id"`
 	ProductName       string             `json:"product_name"`
-	CurrentStock      int                `json:"current_stock"`
-	LowStockThreshold int                `json:"low_stock_threshold"`
+	CurrentStock      decimal.Decimal    `json:"current_stock"`
+	LowStockThreshold decimal.Decimal    `json:"low_stock_threshold"`
 	IsLowStock        bool               `json:"is_low_stock"`
 }
 
@@ -109,11 +147,14 @@ type InventoryReport struct {
 	GeneratedAt        time.Time       `json:"generated_at"`
 }
 
-// NewProfitSummary creates a new ProfitSummary and calculates profit
-func NewProfitSummary(sales, expenses decimal.Decimal, start, end time.Time) *ProfitSummary {
+// NewProfitSummary creates a new ProfitSummary and calculates gross profit and profit
+func NewProfitSummary(sales, costOfGoods, expenses decimal.Decimal, start, end time.Time) *ProfitSummary {
 	profit := sales.Sub(expenses)
 	return &ProfitSummary{
 		TotalSales:    sales,
+		CostOfGoods:   costOfGoods,
+		GrossProfit:   sales.Sub(costOfGoods),
+		GrossMargin:   GrossMargin(sales, costOfGoods),
 		TotalExpenses: expenses,
 		Profit:        profit,
 		StartDate:     start,
@@ -171,6 +212,7 @@ type ReportRepository interface {
 type SalesReportData struct {
 	TotalSales  decimal.Decimal
 	TotalOrders int
+	TotalItems  int
 	TopProducts []TopProduct
 	GroupedData []SalesGroup
 }
@@ -186,7 +228,9 @@ type ExpenseReportData struct {
 // ProfitReportData contains raw profit data
 type ProfitReportData struct {
 	TotalSales    decimal.Decimal
+	CostOfGoods   decimal.Decimal
 	TotalExpenses decimal.Decimal
+	Products      []ProductMargin
 	GroupedData   []ProfitGroup
 }
 
@@ -11,3 +11,20 @@ type RestoreResponse struct {
 	Since      *string   `json:"since,omitempty"`
 	RestoredAt time.Time `json:"restored_at"`
 }
+
+// RedactFor removes what role may not see: expenses unless it may read them,
+// and costs unless it may see costs.
+func (r *RestoreResponse) RedactFor(role BusinessRole) {
+	if !role.CanViewExpenses() {
+		r.Expenses = nil
+	}
+	if role.CanViewCosts() {
+		return
+	}
+	for i := range r.Sales {
+		r.Sales[i].HideCost()
+	}
+	for i := range r.Products {
+		r.Products[i].HideCost()
+	}
+}
@@ -2,34 +2,100 @@ package domain
 
 import (
 	"errors"
-	"math"
+	"fmt"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 
-// Sale represents a sales transaction
+// MaxSaleLines bounds the number of lines in one sale
+const MaxSaleLines = 200
+
+// SaleLine is one product of a sale, with its own quantity, price and discount
+type SaleLine struct {
+	ProductID *primitive.ObjectID `bson:"product_id,omitempty" json:"product_id,omitempty"` // Pointer for optional
+	Quantity  decimal.Decimal     `bson:"quantity" json:"quantity"`
+	UnitPrice decimal.Decimal     `bson:"unit_price" json:"unit_price"`
+	Discount  decimal.Decimal     `bson:"discount" json:"discount"`
+	Total     decimal.Decimal     `bson:"total" json:"total"`
+	Cost      decimal.Decimal     `bson:"cost" json:"cost"` // Cost of goods of the stock the line took
+}
+
+// NewSaleLine creates a SaleLine and calculates its total
+func NewSaleLine(productID *primitive.ObjectID, quantity, unitPrice, discount decimal.Decimal) SaleLine {
+	line := SaleLine{
+		ProductID: productID,
+		Quantity:  quantity,
+		UnitPrice: unitPrice,
+		Discount:  discount,
+	}
+	line.CalculateTotal()
+	return line
+}
+
+// CalculateTotal computes the line total: unit price times quantity, less
+// the line discount, rounded to cents
+func (l *SaleLine) CalculateTotal() decimal.Decimal {
+	l.Total = l.UnitPrice.Mul(l.Quantity).Sub(l.Discount).Round(2)
+	return l.Total
+}
+
+// Validate checks if the line data is valid
+func (l *SaleLine) Validate() error {
+	if l.UnitPrice.IsNegative() {
+		return errors.New("unit price cannot be negative")
+	}
+	if !l.Quantity.IsPositive() {
+		return errors.New("quantity must be positive")
+	}
+	if l.Discount.IsNegative() {
+		return errors.New("discount cannot be negative")
+	}
+	if l.Discount.GreaterThan(l.UnitPrice.Mul(l.Quantity)) {
+		return errors.New("discount cannot exceed the line amount")
+	}
+	expected := l.UnitPrice.Mul(l.Quantity).Sub(l.Discount).Round(2)
+	if !l.Total.Equal(expected) {
+		return errors.New("line total mismatch")
+	}
+	return nil
+}
+
+// Sale represents a sales transaction: a basket of one or more lines
 type Sale struct {
-	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	BusinessID primitive.ObjectID  `bson:"business_id" json:"business_id"`
-	ProductID  *primitive.ObjectID `bson:"product_id,omitempty" json:"product_id,omitempty"` // Pointer for optional
-	UnitPrice  float64             `bson:"unit_price" json:"unit_price"`
-	Quantity   int                 `bson:"quantity" json:"quantity"`
-	Total      float64             `bson:"total" json:"total"`
-	Note       string              `bson:"note,omitempty" json:"note,omitempty"`
-	CreatedAt  time.Time           `bson:"created_at" json:"created_at"`
-	IsVoided   bool                `bson:"is_voided" json:"is_voided"`
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BusinessID primitive.ObjectID `bson:"business_id" json:"business_id"`
+	Lines      []SaleLine         `bson:"lines" json:"lines"`
+	Total      decimal.Decimal    `bson:"total" json:"total"`
+	Note       string             `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  *time.Time         `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	IsVoided   bool               `bson:"is_voided" json:"is_voided"`
+	Version    int64              `bson:"version" json:"version"` // Bumped on every write; sync uses it to detect conflicts
+
+	// Sales recorded before baskets kept their single product on the sale
+	// itself. NormalizeLines moves it into Lines when such a sale is read.
+	LegacyProductID *primitive.ObjectID `bson:"product_id,omitempty" json:"-"`
+	LegacyUnitPrice decimal.Decimal     `bson:"unit_price,omitempty" json:"-"`
+	LegacyQuantity  decimal.Decimal     `bson:"quantity,omitempty" json:"-"`
+}
+
+// HideCost clears the cost of goods of every line, for a role that may not
+// see costs.
+func (s *Sale) HideCost() {
+	for i := range s.Lines {
+		s.Lines[i].Cost = decimal.Zero
+	}
 }
 
 // NewSale creates a new Sale instance and calculates the total
-func NewSale(businessID primitive.ObjectID, productID *primitive.ObjectID, unitPrice float64, quantity int, note string) *Sale {
+func NewSale(businessID primitive.ObjectID, lines []SaleLine, note string) *Sale {
 	sale := &Sale{
 		ID:         primitive.NewObjectID(),
 		BusinessID: businessID,
-		ProductID:  productID,
-		UnitPrice:  unitPrice,
-		Quantity:   quantity,
+		Lines:      lines,
 		Note:       note,
 		CreatedAt:  time.Now(),
 		IsVoided:   false,
@@ -38,25 +104,70 @@ func NewSale(businessID primitive.ObjectID, productID *primitive.ObjectID, unitP
 	return sale
 }
 
-// CalculateTotal computes the total amount based on unit price and quantity
-func (s *Sale) CalculateTotal() float64 {
-	s.Total = math.Round(s.UnitPrice*float64(s.Quantity)*100) / 100
+// CalculateTotal computes the sale total as the sum of its line totals
+func (s *Sale) CalculateTotal() decimal.Decimal {
+	total := decimal.Zero
+	for _, line := range s.Lines {
+		total = total.Add(line.Total)
+	}
+	s.Total = total
 	return s.Total
 }
 
+// CostOfGoods returns the cost of the stock taken by all lines
+func (s *Sale) CostOfGoods() decimal.Decimal {
+	cost := decimal.Zero
+	for _, line := range s.Lines {
+		cost = cost.Add(line.Cost)
+	}
+	return cost
+}
+
+// Quantity returns the number of units across all lines
+func (s *Sale) Quantity() decimal.Decimal {
+	quantity := decimal.Zero
+	for _, line := range s.Lines {
+		quantity = quantity.Add(line.Quantity)
+	}
+	return quantity
+}
+
+// NormalizeLines turns a sale recorded before baskets into a one-line sale.
+// Sales that already have lines are left alone.
+func (s *Sale) NormalizeLines() {
+	if len(s.Lines) > 0 || !s.LegacyQuantity.IsPositive() {
+		return
+	}
+	s.Lines = []SaleLine{{
+		ProductID: s.LegacyProductID,
+		Quantity:  s.LegacyQuantity,
+		UnitPrice: s.LegacyUnitPrice,
+		Total:     s.Total,
+	}}
+	s.LegacyProductID = nil
+	s.LegacyUnitPrice = decimal.Zero
+	s.LegacyQuantity = decimal.Zero
+}
+
 // Validate checks if the sale data is valid
 func (s *Sale) Validate() error {
 	if s.BusinessID.IsZero() {
 		return errors.New("business ID is required")
 	}
-	if s.UnitPrice < 0 {
-		return errors.New("unit price cannot be negative")
+	if len(s.Lines) == 0 {
+		return errors.New("a sale needs at least one line")
 	}
-	if s.Quantity <= 0 {
-		return errors.New("quantity must be positive")
+	if len(s.Lines) > MaxSaleLines {
+		return fmt.Errorf("a sale cannot have more than %d lines", MaxSaleLines)
+	}
+	expected := decimal.Zero
+	for i := range s.Lines {
+		if err := s.Lines[i].Validate(); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		expected = expected.Add(s.Lines[i].Total)
 	}
-	expected := math.Round(s.UnitPrice*float64(s.Quantity)*100) / 100
-	if s.Total != expected {
+	if !s.Total.Equal(expected) {
 		return errors.New("total amount mismatch")
 	}
 	return nil
@@ -66,13 +177,36 @@ func (s *Sale) Validate() error {
 // Request / Response DTOs
 // ──────────────────────────────────────────────
 
-// CreateSaleRequest is the payload for recording a new sale
+// SaleLineRequest is one line of a new sale
+type SaleLineRequest struct {
+	ProductID *string `json:"product_id,omitempty"`
+	UnitPrice float64 `json:"unit_price" binding:"required,gt=0"`
+	Quantity  float64 `json:"quantity"   binding:"required,gt=0"`
+	Discount  float64 `json:"discount,omitempty" binding:"gte=0"`
+}
+
+// CreateSaleRequest is the payload for recording a new sale. Clients that
+// predate baskets may send a single product_id, unit_price and quantity
+// instead of lines.
 type CreateSaleRequest struct {
-	BusinessID string  `json:"business_id" binding:"required"`
-	ProductID  *string `json:"product_id,omitempty"`
-	UnitPrice  float64 `json:"unit_price" binding:"required,gt=0"`
-	Quantity   int     `json:"quantity"   binding:"required,gt=0"`
-	Note       string  `json:"note,omitempty"`
+	BusinessID string            `json:"business_id" binding:"required"`
+	Lines      []SaleLineRequest `json:"lines,omitempty" binding:"omitempty,dive"`
+	ProductID  *string           `json:"product_id,omitempty"`
+	UnitPrice  float64           `json:"unit_price,omitempty" binding:"omitempty,gt=0"`
+	Quantity   float64           `json:"quantity,omitempty"   binding:"omitempty,gt=0"`
+	Note       string            `json:"note,omitempty"`
+}
+
+// LineRequests returns the lines of the request, reading a single-product
+// request as a one-line sale
+func (r CreateSaleRequest) LineRequests() []SaleLineRequest {
+	if len(r.Lines) > 0 {
+		return r.Lines
+	}
+	if r.ProductID == nil && r.UnitPrice == 0 && r.Quantity == 0 {
+		return nil
+	}
+	return []SaleLineRequest{{ProductID: r.ProductID, UnitPrice: r.UnitPrice, Quantity: r.Quantity}}
 }
 
 // UpdateSaleRequest is the payload for updating a sale (note only, before sync)
@@ -94,17 +228,27 @@ type SaleListQuery struct {
 	Order     string  `form:"order,default=desc"`
 }
 
+// SaleLineResponse is the API representation of a sale line
+type SaleLineResponse struct {
+	ProductID *string         `json:"product_id,omitempty"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	UnitPrice decimal.Decimal `json:"unit_price"`
+	Discount  decimal.Decimal `json:"discount"`
+	Total     decimal.Decimal `json:"total"`
+	Cost      decimal.Decimal `json:"cost"`
+}
+
 // SaleResponse is the API representation of a sale
 type SaleResponse struct {
-	ID         string    `json:"id"`
-	BusinessID string    `json:"business_id"`
-	ProductID  *string   `json:"product_id,omitempty"`
-	UnitPrice  float64   `json:"unit_price"`
-	Quantity   int       `json:"quantity"`
-	Total      float64   `json:"total"`
-	Note       string    `json:"note,omitempty"`
-	IsVoided   bool      `json:"is_voided"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID          string             `json:"id"`
+	BusinessID  string             `json:"business_id"`
+	Lines       []SaleLineResponse `json:"lines"`
+	Quantity    decimal.Decimal    `json:"quantity"`
+	Total       decimal.Decimal    `json:"total"`
+	CostOfGoods decimal.Decimal    `json:"cost_of_goods"`
+	Note        string             `json:"note,omitempty"`
+	IsVoided    bool               `json:"is_voided"`
+	CreatedAt   time.Time          `json:"created_at"`
 }
 
 // SaleListResponse is the paginated list of sales
@@ -117,6 +261,7 @@ type SaleListResponse struct {
 type SaleSummaryResponse struct {
 	TotalSales   int     `json:"total_sales"`
 	TotalRevenue float64 `json:"total_revenue"`
+	CostOfGoods  float64 `json:"cost_of_goods"`
 	VoidedCount  int     `json:"voided_count"`
 	Period       string  `json:"period,omitempty"`
 }
@@ -142,4 +287,5 @@ type SaleRepository interface {
 	UpdateNote(id string, note string) error
 	VoidSale(id string) error
 	GetSummary(businessID string, startDate, endDate time.Time) (*SaleSummaryResponse, error)
+	GetProductMargins(businessID string, startDate, endDate time.Time) ([]ProductMargin, error)
 }
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxDeviceNameLength bounds the device name a client reports at login.
+const MaxDeviceNameLength = 100
+
+// SessionTouchInterval limits last-used writes to one per session per minute,
+// so every authenticated request does not turn into a write.
+const SessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionClient describes the device a login or refresh came from.
+type SessionClient struct {
+	DeviceName string
+	IP         string
+	UserAgent  string
+}
+
+// Session is one signed-in device. Its ID is also the family ID of the
+// refresh tokens issued to that device, so ending the session ends the family.
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	DeviceName string             `bson:"device_name" json:"device_name"`
+	IP         string             `bson:"ip" json:"ip"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"-"`
+	// Current marks the session the listing request was made with
+	Current bool `bson:"-" json:"current"`
+}
+
+// NewSession starts a session for userID on the given device. It lasts as
+// long as the refresh token issued with it.
+func NewSession(userID primitive.ObjectID, client SessionClient) *Session {
+	now := time.Now()
+	deviceName := strings.TrimSpace(client.DeviceName)
+	if len(deviceName) > MaxDeviceNameLength {
+		deviceName = deviceName[:MaxDeviceNameLength]
+	}
+	return &Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		DeviceName: deviceName,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+	}
+}
+
+// ActiveAt reports whether the session can still be used at now.
+func (s *Session) ActiveAt(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+// SigningAlgorithm is the JWS algorithm a signing key is used with.
+type SigningAlgorithm string
+
+const (
+	SigningRS256 SigningAlgorithm = "RS256"
+	SigningEdDSA SigningAlgorithm = "EdDSA"
+)
+
+const (
+	// DefaultKeyRotation is how long a signing key signs new tokens before the
+	// next one takes over.
+	DefaultKeyRotation = 30 * 24 * time.Hour
+	// SigningKeyGrace is how long a retired key still verifies tokens. It
+	// covers the longest-lived token the key may have signed.
+	SigningKeyGrace = RefreshTokenTTL
+)
+
+// SigningKey is a JWT signing key shared by every API instance. Tokens name
+// the key that signed them in their kid header. The private key is stored
+// sealed, never in the clear.
+type SigningKey struct {
+	ID        string           `bson:"_id" json:"kid"`
+	Algorithm SigningAlgorithm `bson:"algorithm" json:"alg"`
+	// SealedPrivateKey is the PKCS #8 private key encrypted with a key derived
+	// from JWT_SECRET
+	SealedPrivateKey []byte    `bson:"sealed_private_key" json:"-"`
+	CreatedAt        time.Time `bson:"created_at" json:"created_at"`
+	// RetiresAt is when the key stops signing new tokens
+	RetiresAt time.Time `bson:"retires_at" json:"retires_at"`
+	// ExpiresAt is when the key stops verifying tokens and is deleted
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+}
+
+// NewSigningKey describes a key created at now that signs for one rotation
+// period.
+func NewSigningKey(id string, algorithm SigningAlgorithm, sealed []byte, rotation time.Duration, now time.Time) *SigningKey {
+	retiresAt := now.Add(rotation)
+	return &SigningKey{
+		ID:               id,
+		Algorithm:        algorithm,
+		SealedPrivateKey: sealed,
+		CreatedAt:        now,
+		RetiresAt:        retiresAt,
+		ExpiresAt:        retiresAt.Add(SigningKeyGrace),
+	}
+}
+
+// SignsAt reports whether the key may sign new tokens at now.
+func (k *SigningKey) SignsAt(now time.Time) bool {
+	return !now.Before(k.CreatedAt) && now.Before(k.RetiresAt)
+}
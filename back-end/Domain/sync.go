@@ -1,13 +1,86 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // SyncTransactionType identifies a transaction payload type in sync batches.
 type SyncTransactionType string
 
 const (
-	SyncTransactionTypeSale    SyncTransactionType = "sale"
-	SyncTransactionTypeExpense SyncTransactionType = "expense"
+	SyncTransactionTypeSale            SyncTransactionType = "sale"
+	SyncTransactionTypeExpense         SyncTransactionType = "expense"
+	SyncTransactionTypeProduct         SyncTransactionType = "product"
+	SyncTransactionTypeStockAdjustment SyncTransactionType = "stock_adjustment"
+	SyncTransactionTypeSaleVoid        SyncTransactionType = "sale_void"
+	SyncTransactionTypeExpenseUpdate   SyncTransactionType = "expense_update"
+)
+
+// IsValidSyncTransactionType reports whether t can be replayed by the sync batch endpoint.
+func IsValidSyncTransactionType(t SyncTransactionType) bool {
+	switch t {
+	case SyncTransactionTypeSale,
+		SyncTransactionTypeExpense,
+		SyncTransactionTypeProduct,
+		SyncTransactionTypeStockAdjustment,
+		SyncTransactionTypeSaleVoid,
+		SyncTransactionTypeExpenseUpdate:
+		return true
+	}
+	return false
+}
+
+// Sync device errors
+var (
+	ErrDeviceNotRegistered = errors.New("device not registered for business")
+	ErrDeviceRevoked       = errors.New("device has been revoked for business")
+	ErrDeviceRegistered    = errors.New("device already registered")
+	ErrDeviceNotFound      = errors.New("device not found")
+	ErrInvalidSyncCursor   = errors.New("invalid sync cursor")
+	ErrConflictNotFound    = errors.New("sync conflict not found")
+	ErrConflictResolved    = errors.New("sync conflict already resolved")
+	ErrInvalidResolution   = errors.New("resolution must be server or client")
+	ErrInvalidSyncPolicy   = errors.New("invalid sync conflict policy")
+	ErrSyncItemNotFound    = errors.New("sync item not found")
+)
+
+// SyncConflictPolicy decides which side wins when a device changes a record
+// that the server has changed since the device last saw it.
+type SyncConflictPolicy string
+
+const (
+	SyncPolicyServerWins     SyncConflictPolicy = "server_wins"
+	SyncPolicyClientWins     SyncConflictPolicy = "client_wins"
+	SyncPolicyLastWriterWins SyncConflictPolicy = "last_writer_wins"
+	SyncPolicyManual         SyncConflictPolicy = "manual"
+)
+
+// IsValidSyncConflictPolicy reports whether p is a known policy.
+func IsValidSyncConflictPolicy(p SyncConflictPolicy) bool {
+	switch p {
+	case SyncPolicyServerWins, SyncPolicyClientWins, SyncPolicyLastWriterWins, SyncPolicyManual:
+		return true
+	}
+	return false
+}
+
+// SyncConflictStatus tracks whether a conflict still needs a decision.
+type SyncConflictStatus string
+
+const (
+	SyncConflictOpen     SyncConflictStatus = "open"
+	SyncConflictResolved SyncConflictStatus = "resolved"
+)
+
+// SyncConflictResolution records which side's change was kept.
+type SyncConflictResolution string
+
+const (
+	SyncResolutionServer SyncConflictResolution = "server"
+	SyncResolutionClient SyncConflictResolution = "client"
 )
 
 // SyncBatchTransaction represents a single client-side transaction payload.
@@ -17,12 +90,19 @@ type SyncBatchTransaction struct {
 	Data    map[string]interface{} `json:"data"`
 }
 
-// SyncBatchRequest defines the offline batch sync request payload.
+// SyncBatchRequest defines the offline batch sync request payload. When Atomic
+// is set the batch is applied all-or-nothing. SchemaVersion selects how
+// transaction data is decoded; it defaults to SyncSchemaV1 for older builds.
 type SyncBatchRequest struct {
 	BusinessID    string                 `json:"business_id"`
 	DeviceID      string                 `json:"device_id"`
 	SyncTimestamp time.Time              `json:"sync_timestamp"`
+	SchemaVersion int                    `json:"schema_version"`
 	Transactions  []SyncBatchTransaction `json:"transactions"`
+	Atomic        bool                   `json:"atomic"`
+	UserID        string                 `json:"-"`
+	Role          BusinessRole           `json:"-"`
+	RequestID     string                 `json:"-"`
 }
 
 // SyncItemResult contains the processing result for a single local transaction.
@@ -30,14 +110,97 @@ type SyncItemResult struct {
 	LocalID  string `json:"local_id" bson:"local_id"`
 	ServerID string `json:"server_id,omitempty" bson:"server_id,omitempty"`
 	Status   string `json:"status" bson:"status"`
+	Code     string `json:"code,omitempty" bson:"code,omitempty"`
 	Message  string `json:"message,omitempty" bson:"message,omitempty"`
+
+	Conflict *SyncConflictInfo `json:"conflict,omitempty" bson:"conflict,omitempty"`
+	Errors   []SyncFieldError  `json:"errors,omitempty" bson:"errors,omitempty"`
+
+	RetryID     string     `json:"retry_id,omitempty" bson:"retry_id,omitempty"`
+	Attempt     int        `json:"attempt,omitempty" bson:"attempt,omitempty"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty" bson:"next_retry_at,omitempty"`
+
+	// Applied is what the item changed, for the audit log. It is neither
+	// stored nor returned to the device.
+	Applied *SyncAppliedChange `json:"-" bson:"-"`
+}
+
+// SyncAppliedChange is the record a sync mutation changed, as it was before
+// and after the change.
+type SyncAppliedChange struct {
+	EntityID string
+	Before   interface{}
+	After    interface{}
+}
+
+// SyncConflictInfo describes a version conflict found while replaying a
+// transaction, with the record as the server had it and the client's change.
+type SyncConflictInfo struct {
+	ConflictID    string                 `json:"conflict_id" bson:"conflict_id"`
+	Entity        ChangeEntity           `json:"entity" bson:"entity"`
+	EntityID      string                 `json:"entity_id" bson:"entity_id"`
+	ClientVersion int64                  `json:"client_version" bson:"client_version"`
+	ServerVersion int64                  `json:"server_version" bson:"server_version"`
+	Server        map[string]interface{} `json:"server" bson:"server"`
+	Client        map[string]interface{} `json:"client" bson:"client"`
+	Policy        SyncConflictPolicy     `json:"policy" bson:"policy"`
+	Resolution    SyncConflictResolution `json:"resolution,omitempty" bson:"resolution,omitempty"`
 }
 
+// SyncConflict is a stored conflict. Conflicts decided by a policy are stored
+// already resolved; under the manual policy they stay open until resolved.
+type SyncConflict struct {
+	ID         primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	BusinessID primitive.ObjectID  `json:"business_id" bson:"business_id"`
+	DeviceID   string              `json:"device_id" bson:"device_id"`
+	SyncID     string              `json:"sync_id" bson:"sync_id"`
+	LocalID    string              `json:"local_id" bson:"local_id"`
+	Type       SyncTransactionType `json:"type" bson:"type"`
+	// SchemaVersion is the payload schema the client change was sent with
+	SchemaVersion int                 `json:"schema_version,omitempty" bson:"schema_version,omitempty"`
+	Info          SyncConflictInfo    `json:"conflict" bson:"conflict"`
+	Status        SyncConflictStatus  `json:"status" bson:"status"`
+	ResolvedBy    *primitive.ObjectID `json:"resolved_by,omitempty" bson:"resolved_by,omitempty"`
+	ResolvedAt    *time.Time          `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+	CreatedAt     time.Time           `json:"created_at" bson:"created_at"`
+
+	// The user who sent the batch the conflict came from
+	UserID    string       `json:"-" bson:"user_id,omitempty"`
+	Role      BusinessRole `json:"-" bson:"role,omitempty"`
+	RequestID string       `json:"-" bson:"request_id,omitempty"`
+
+	// Applied is what resolving for the client changed; it is not stored
+	Applied *SyncAppliedChange `json:"-" bson:"-"`
+}
+
+// SyncConflictList is a page of stored conflicts.
+type SyncConflictList struct {
+	Data       []SyncConflict `json:"data"`
+	Pagination struct {
+		CurrentPage  int   `json:"current_page"`
+		TotalPages   int   `json:"total_pages"`
+		TotalRecords int64 `json:"total_records"`
+		PerPage      int   `json:"per_page"`
+	} `json:"pagination"`
+}
+
+// ResolveSyncConflictRequest decides an open conflict.
+type ResolveSyncConflictRequest struct {
+	BusinessID string                 `json:"business_id"`
+	Resolution SyncConflictResolution `json:"resolution"`
+}
+
+// SyncItemCodeInsufficientStock marks a synced sale rejected because the
+// product does not have enough stock left on the server.
+const SyncItemCodeInsufficientStock = "INSUFFICIENT_STOCK"
+
 // SyncSummary represents aggregate counts for a sync batch.
 type SyncSummary struct {
-	Total   int `json:"total" bson:"total"`
-	Success int `json:"success" bson:"success"`
-	Failed  int `json:"failed" bson:"failed"`
+	Total      int `json:"total" bson:"total"`
+	Success    int `json:"success" bson:"success"`
+	Failed     int `json:"failed" bson:"failed"`
+	Conflicts  int `json:"conflicts" bson:"conflicts"`
+	RolledBack int `json:"rolled_back,omitempty" bson:"rolled_back,omitempty"`
 }
 
 // SyncBatchResponse is returned by the sync batch endpoint.
@@ -47,6 +210,7 @@ type SyncBatchResponse struct {
 	Timestamp  time.Time        `json:"timestamp"`
 	Results    []SyncItemResult `json:"results"`
 	Summary    SyncSummary      `json:"summary"`
+	Cursor     int64            `json:"cursor"`
 	RetryAfter *int             `json:"retry_after_seconds,omitempty"`
 }
 
@@ -59,24 +223,175 @@ type SyncLog struct {
 	Status        string           `json:"status" bson:"status"`
 	Results       []SyncItemResult `json:"results" bson:"results"`
 	Summary       SyncSummary      `json:"summary" bson:"summary"`
+	Cursor        int64            `json:"cursor" bson:"cursor"`
+	Atomic        bool             `json:"atomic,omitempty" bson:"atomic,omitempty"`
+	Source        string           `json:"source,omitempty" bson:"source,omitempty"`
 	CreatedAt     time.Time        `json:"created_at" bson:"created_at"`
 }
 
+// SyncLogSourceRetry marks a sync log written by the background retry queue
+// rather than by a device batch.
+const SyncLogSourceRetry = "retry"
+
+// SyncDeviceStatus is the sync state of a single device, keyed by its cursor.
+type SyncDeviceStatus struct {
+	DeviceID      string       `json:"device_id"`
+	Name          string       `json:"name,omitempty"`
+	Status        DeviceStatus `json:"status,omitempty"`
+	Cursor        int64        `json:"cursor"`
+	LastSyncAt    *time.Time   `json:"last_sync_at,omitempty"`
+	LastSyncID    string       `json:"last_sync_id,omitempty"`
+	LastStatus    string       `json:"last_status,omitempty"`
+	TotalSynced   int64        `json:"total_synced"`
+	FailedLast24h int64        `json:"failed_last_24h"`
+}
+
 // SyncStatusResponse provides high-level sync state for a business/device.
 type SyncStatusResponse struct {
-	BusinessID    string    `json:"business_id"`
-	DeviceID      string    `json:"device_id"`
-	LastSyncAt    time.Time `json:"last_sync_at"`
-	LastSyncID    string    `json:"last_sync_id"`
-	LastStatus    string    `json:"last_status"`
-	PendingRetries int64    `json:"pending_retries"`
-	TotalSynced   int64     `json:"total_synced"`
-	FailedLast24h int64     `json:"failed_last_24h"`
+	BusinessID     string             `json:"business_id"`
+	DeviceID       string             `json:"device_id"`
+	LastSyncAt     time.Time          `json:"last_sync_at"`
+	LastSyncID     string             `json:"last_sync_id"`
+	LastStatus     string             `json:"last_status"`
+	PendingRetries int64              `json:"pending_retries"`
+	DeadLetters    int64              `json:"dead_letters"`
+	TotalSynced    int64              `json:"total_synced"`
+	FailedLast24h  int64              `json:"failed_last_24h"`
+	Devices        []SyncDeviceStatus `json:"devices"`
 }
 
 // SyncHistoryResponse is a paginated list of sync logs.
 type SyncHistoryResponse struct {
-	Data []SyncLog `json:"data"`
+	Data       []SyncLog `json:"data"`
+	Pagination struct {
+		CurrentPage  int   `json:"current_page"`
+		TotalPages   int   `json:"total_pages"`
+		TotalRecords int64 `json:"total_records"`
+		PerPage      int   `json:"per_page"`
+	} `json:"pagination"`
+}
+
+// SyncRetryState tracks a failed sync item through the server-side retry queue.
+type SyncRetryState string
+
+const (
+	SyncRetryPending    SyncRetryState = "pending"
+	SyncRetrySucceeded  SyncRetryState = "succeeded"
+	SyncRetryDeadLetter SyncRetryState = "dead_letter"
+	SyncRetryAcked      SyncRetryState = "acked"
+	SyncRetryDiscarded  SyncRetryState = "discarded"
+)
+
+// IsValidSyncRetryState reports whether s is a known retry state.
+func IsValidSyncRetryState(s SyncRetryState) bool {
+	switch s {
+	case SyncRetryPending, SyncRetrySucceeded, SyncRetryDeadLetter, SyncRetryAcked, SyncRetryDiscarded:
+		return true
+	}
+	return false
+}
+
+// finalSyncRetryStates are the states an item does not leave again.
+var finalSyncRetryStates = []SyncRetryState{SyncRetrySucceeded, SyncRetryDeadLetter, SyncRetryAcked, SyncRetryDiscarded}
+
+// FinalSyncRetryStates lists the states an item does not leave again: it
+// succeeded, ran out of attempts or was settled by hand.
+func FinalSyncRetryStates() []SyncRetryState {
+	return append([]SyncRetryState(nil), finalSyncRetryStates...)
+}
+
+// IsFinal reports whether an item in state s is done with the queue.
+func (s SyncRetryState) IsFinal() bool {
+	for _, final := range finalSyncRetryStates {
+		if s == final {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncRetryPolicy controls how failed sync items are retried. The delay
+// doubles after every failed attempt, up to MaxDelay; an item still failing
+// after MaxAttempts moves to the dead-letter state.
+type SyncRetryPolicy struct {
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts int
+}
+
+// DefaultSyncRetryPolicy is applied to every failed item of a non-atomic batch.
+var DefaultSyncRetryPolicy = SyncRetryPolicy{
+	BaseDelay:   30 * time.Second,
+	MaxDelay:    time.Hour,
+	MaxAttempts: 6,
+}
+
+// Backoff returns how long to wait after the given failed attempt (1-based).
+func (p SyncRetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// Exhausted reports whether no attempts are left after the given attempt.
+func (p SyncRetryPolicy) Exhausted(attempt int) bool {
+	return attempt >= p.MaxAttempts
+}
+
+// SyncRetryAttempt is one try at applying a queued item. The first attempt is
+// the device batch that failed; later ones are made by the retry queue.
+type SyncRetryAttempt struct {
+	Attempt     int       `json:"attempt" bson:"attempt"`
+	SyncID      string    `json:"sync_id" bson:"sync_id"`
+	Status      string    `json:"status" bson:"status"`
+	Code        string    `json:"code,omitempty" bson:"code,omitempty"`
+	Message     string    `json:"message,omitempty" bson:"message,omitempty"`
+	AttemptedAt time.Time `json:"attempted_at" bson:"attempted_at"`
+}
+
+// SyncRetryItem is a failed sync item kept server-side for retrying.
+type SyncRetryItem struct {
+	ID            primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	BusinessID    primitive.ObjectID     `json:"business_id" bson:"business_id"`
+	DeviceID      string                 `json:"device_id" bson:"device_id"`
+	LocalID       string                 `json:"local_id" bson:"local_id"`
+	Type          SyncTransactionType    `json:"type" bson:"type"`
+	Data          map[string]interface{} `json:"data" bson:"data"`
+	SchemaVersion int                    `json:"schema_version,omitempty" bson:"schema_version,omitempty"`
+	UserID        string                 `json:"-" bson:"user_id,omitempty"`
+	Role          BusinessRole           `json:"-" bson:"role,omitempty"`
+	RequestID     string                 `json:"-" bson:"request_id,omitempty"`
+	State         SyncRetryState         `json:"state" bson:"state"`
+	Attempts      int                    `json:"attempts" bson:"attempts"`
+	MaxAttempts   int                    `json:"max_attempts" bson:"max_attempts"`
+	NextAttemptAt *time.Time             `json:"next_attempt_at,omitempty" bson:"next_attempt_at,omitempty"`
+	LastError     string                 `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	ServerID      string                 `json:"server_id,omitempty" bson:"server_id,omitempty"`
+	Lineage       []SyncRetryAttempt     `json:"lineage" bson:"lineage"`
+	CreatedAt     time.Time              `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at" bson:"updated_at"`
+}
+
+// SyncRetryOutcome is one attempt the retry queue made at an item.
+type SyncRetryOutcome struct {
+	Item   SyncRetryItem
+	Result SyncItemResult
+}
+
+// SyncRetryList is a page of retry queue items.
+type SyncRetryList struct {
+	Data       []SyncRetryItem `json:"data"`
 	Pagination struct {
 		CurrentPage  int   `json:"current_page"`
 		TotalPages   int   `json:"total_pages"`
@@ -84,3 +399,128 @@ type SyncHistoryResponse struct {
 		PerPage      int   `json:"per_page"`
 	} `json:"pagination"`
 }
+
+// SyncRetryActionRequest acknowledges or discards queued items of a device.
+type SyncRetryActionRequest struct {
+	BusinessID string   `json:"business_id"`
+	DeviceID   string   `json:"device_id"`
+	LocalIDs   []string `json:"local_ids"`
+}
+
+// SyncRetryActionResponse reports which items changed state.
+type SyncRetryActionResponse struct {
+	Updated int64 `json:"updated"`
+}
+
+// SyncItemHistory is the lineage of one local_id: every sync log it appears
+// in, oldest first, and its retry queue entry if it ever failed.
+type SyncItemHistory struct {
+	BusinessID string         `json:"business_id"`
+	DeviceID   string         `json:"device_id"`
+	LocalID    string         `json:"local_id"`
+	Retry      *SyncRetryItem `json:"retry,omitempty"`
+	Logs       []SyncLog      `json:"logs"`
+}
+
+// ChangeEntity identifies the kind of record referenced by a change feed entry.
+type ChangeEntity string
+
+const (
+	ChangeEntitySale          ChangeEntity = "sale"
+	ChangeEntityExpense       ChangeEntity = "expense"
+	ChangeEntityProduct       ChangeEntity = "product"
+	ChangeEntityStockMovement ChangeEntity = "stock_movement"
+)
+
+// ChangeOperation is the kind of mutation recorded in the change feed.
+type ChangeOperation string
+
+const (
+	ChangeOperationUpsert ChangeOperation = "upsert"
+	ChangeOperationVoid   ChangeOperation = "void"
+	ChangeOperationDelete ChangeOperation = "delete"
+)
+
+// ChangeEntry is one append-only change feed record. Seq is a per-business
+// sequence number that orders every mutation made by devices or the dashboard.
+type ChangeEntry struct {
+	ID         primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	BusinessID primitive.ObjectID `json:"business_id" bson:"business_id"`
+	Seq        int64              `json:"seq" bson:"seq"`
+	Entity     ChangeEntity       `json:"entity" bson:"entity"`
+	EntityID   primitive.ObjectID `json:"entity_id" bson:"entity_id"`
+	Operation  ChangeOperation    `json:"operation" bson:"operation"`
+	ChangedAt  time.Time          `json:"changed_at" bson:"changed_at"`
+}
+
+// SyncPullCursor is where a device resumes pulling. Seq is the change feed
+// position. While a snapshot is paged, Snapshot names the entity being read
+// and After the last record sent, and Seq is the feed head the snapshot
+// started from, where the device switches to the feed once it is done.
+type SyncPullCursor struct {
+	Seq      int64
+	Snapshot ChangeEntity
+	After    primitive.ObjectID
+}
+
+// SyncPullRequest asks for every change made since an opaque server cursor.
+// An empty cursor starts a snapshot of the business, paged like the feed.
+type SyncPullRequest struct {
+	BusinessID string       `json:"business_id"`
+	DeviceID   string       `json:"device_id"`
+	Cursor     string       `json:"cursor"`
+	Limit      int          `json:"limit"`
+	Role       BusinessRole `json:"-"`
+}
+
+// SyncEntityRef points to a record that was voided or deleted on the server.
+type SyncEntityRef struct {
+	Entity ChangeEntity `json:"entity"`
+	ID     string       `json:"id"`
+}
+
+// SyncPullResponse carries server-side changes down to a device.
+type SyncPullResponse struct {
+	Sales          []Sale          `json:"sales"`
+	Expenses       []Expense       `json:"expenses"`
+	Products       []Product       `json:"products"`
+	StockMovements []StockMovement `json:"stock_movements"`
+	Voided         []SyncEntityRef `json:"voided"`
+	Deleted        []SyncEntityRef `json:"deleted"`
+	NextCursor     string          `json:"next_cursor"`
+	HasMore        bool            `json:"has_more"`
+	ServerTime     time.Time       `json:"server_time"`
+}
+
+// RedactFor removes what role may not see: expenses, and the voids and
+// deletions of expenses, unless it may read them, and costs unless it may see
+// costs.
+func (r *SyncPullResponse) RedactFor(role BusinessRole) {
+	if !role.CanViewExpenses() {
+		r.Expenses = []Expense{}
+		r.Voided = withoutEntity(r.Voided, ChangeEntityExpense)
+		r.Deleted = withoutEntity(r.Deleted, ChangeEntityExpense)
+	}
+	if role.CanViewCosts() {
+		return
+	}
+	for i := range r.Sales {
+		r.Sales[i].HideCost()
+	}
+	for i := range r.Products {
+		r.Products[i].HideCost()
+	}
+	for i := range r.StockMovements {
+		r.StockMovements[i].HideCost()
+	}
+}
+
+func withoutEntity(refs []SyncEntityRef, entity ChangeEntity) []SyncEntityRef {
+	kept := refs[:0]
+	for _, ref := range refs {
+		if ref.Entity != entity {
+			kept = append(kept, ref)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,92 @@
+package domain
+
+import "time"
+
+// SyncHealthQuery selects the window and devices a sync health report covers.
+type SyncHealthQuery struct {
+	BusinessID  string
+	DeviceID    string
+	From        time.Time
+	To          time.Time
+	SilentDays  int
+	TopFailures int
+}
+
+// SyncHealthReport aggregates sync_logs over a time window so sync complaints
+// can be diagnosed without querying Mongo by hand.
+type SyncHealthReport struct {
+	BusinessID    string             `json:"business_id"`
+	DeviceID      string             `json:"device_id,omitempty"`
+	From          time.Time          `json:"from"`
+	To            time.Time          `json:"to"`
+	Batches       int64              `json:"batches"`
+	RetryBatches  int64              `json:"retry_batches"`
+	Items         SyncHealthItems    `json:"items"`
+	SuccessRate   float64            `json:"success_rate"`
+	BatchSize     SyncBatchSizeStats `json:"batch_size"`
+	Lag           SyncLagStats       `json:"lag"`
+	TopFailures   []SyncFailureCount `json:"top_failures"`
+	SilentDevices []SyncSilentDevice `json:"silent_devices"`
+	Daily         []SyncHealthDay    `json:"daily"`
+}
+
+// SyncHealthItems totals item outcomes across every batch in the window.
+type SyncHealthItems struct {
+	Total      int64 `json:"total" bson:"total"`
+	Success    int64 `json:"success" bson:"success"`
+	Failed     int64 `json:"failed" bson:"failed"`
+	Conflicts  int64 `json:"conflicts" bson:"conflicts"`
+	RolledBack int64 `json:"rolled_back" bson:"rolled_back"`
+}
+
+// Rate returns the share of items that synced successfully, 0..1. An empty
+// window reports 1 so a quiet business does not look unhealthy.
+func (i SyncHealthItems) Rate() float64 {
+	if i.Total == 0 {
+		return 1
+	}
+	return float64(i.Success) / float64(i.Total)
+}
+
+// SyncBatchSizeStats describes how many transactions devices send per batch.
+type SyncBatchSizeStats struct {
+	Average float64 `json:"average" bson:"average"`
+	Min     int     `json:"min" bson:"min"`
+	Max     int     `json:"max" bson:"max"`
+}
+
+// SyncLagStats measures how long records waited on a device between being
+// created offline and reaching the server.
+type SyncLagStats struct {
+	Records        int64   `json:"records"`
+	AverageSeconds float64 `json:"average_seconds"`
+	MaxSeconds     float64 `json:"max_seconds"`
+}
+
+// SyncFailureCount groups failed items by their message.
+type SyncFailureCount struct {
+	Message    string    `json:"message" bson:"message"`
+	Code       string    `json:"code,omitempty" bson:"code,omitempty"`
+	Count      int64     `json:"count" bson:"count"`
+	Devices    int       `json:"devices" bson:"devices"`
+	LastSeenAt time.Time `json:"last_seen_at" bson:"last_seen_at"`
+}
+
+// SyncSilentDevice is an active device that has not synced for a while.
+type SyncSilentDevice struct {
+	DeviceID   string     `json:"device_id"`
+	Name       string     `json:"name,omitempty"`
+	LastSyncAt *time.Time `json:"last_sync_at,omitempty"`
+	SilentDays int        `json:"silent_days"`
+}
+
+// SyncHealthDay is one day of the report, in UTC.
+type SyncHealthDay struct {
+	Date         string  `json:"date" bson:"_id"`
+	Batches      int64   `json:"batches" bson:"batches"`
+	RetryBatches int64   `json:"retry_batches" bson:"retry_batches"`
+	Items        int64   `json:"items" bson:"items"`
+	Success      int64   `json:"success" bson:"success"`
+	Failed       int64   `json:"failed" bson:"failed"`
+	SuccessRate  float64 `json:"success_rate" bson:"-"`
+}
@@ -0,0 +1,568 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Sync payload schema versions. Version 1 is the loose format sent by app
+// builds that predate schema_version: amounts may be JSON numbers, expenses
+// may carry their note as "description" and unknown fields are ignored.
+// Version 2 is strict: amounts are decimal strings and unknown fields are
+// rejected.
+const (
+	SyncSchemaV1             = 1
+	SyncSchemaV2             = 2
+	CurrentSyncSchemaVersion = SyncSchemaV2
+)
+
+// ErrUnsupportedSchemaVersion is returned for a schema_version the server does not know.
+var ErrUnsupportedSchemaVersion = errors.New("unsupported schema_version")
+
+// IsSupportedSyncSchemaVersion reports whether the server can decode payloads
+// of the given schema version. Zero means the client sent none and is read
+// as SyncSchemaV1.
+func IsSupportedSyncSchemaVersion(version int) bool {
+	return version >= 0 && version <= CurrentSyncSchemaVersion
+}
+
+// SyncItemCodeValidationFailed marks a synced item rejected because its
+// payload did not match the schema; the item's Errors list the fields.
+const SyncItemCodeValidationFailed = "VALIDATION_FAILED"
+
+// Field error codes
+const (
+	SyncFieldRequired = "required"
+	SyncFieldInvalid  = "invalid"
+	SyncFieldUnknown  = "unknown_field"
+	SyncFieldTaken    = "taken"
+)
+
+// SyncFieldError points at one invalid field of a transaction payload.
+type SyncFieldError struct {
+	Path    string `json:"path" bson:"path"`
+	Code    string `json:"code" bson:"code"`
+	Message string `json:"message" bson:"message"`
+}
+
+// SyncValidationError lists every invalid field of a transaction payload.
+type SyncValidationError struct {
+	Fields []SyncFieldError
+}
+
+func (e *SyncValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		parts = append(parts, field.Path+": "+field.Message)
+	}
+	return "invalid payload: " + strings.Join(parts, "; ")
+}
+
+// CatalogSyncError points a catalog error of a synced product, such as an
+// invalid unit or a SKU another product has, at the payload field it is
+// about. Other errors are returned as they are.
+func CatalogSyncError(err error) error {
+	code := SyncFieldInvalid
+	var field string
+	switch {
+	case errors.Is(err, ErrInvalidSKU):
+		field = "sku"
+	case errors.Is(err, ErrDuplicateSKU):
+		field, code = "sku", SyncFieldTaken
+	case errors.Is(err, ErrInvalidBarcode):
+		field = "barcode"
+	case errors.Is(err, ErrDuplicateBarcode):
+		field, code = "barcode", SyncFieldTaken
+	case errors.Is(err, ErrInvalidProductCategory):
+		field = "category"
+	case errors.Is(err, ErrInvalidUnit):
+		field = "unit"
+	case errors.Is(err, ErrVariantAttributes):
+		field = "attributes"
+	case errors.Is(err, ErrNestedVariant):
+		field = "parent_id"
+	default:
+		return err
+	}
+	return &SyncValidationError{Fields: []SyncFieldError{{Path: "data." + field, Code: code, Message: err.Error()}}}
+}
+
+// syncPayload is implemented by every typed transaction payload.
+type syncPayload interface {
+	validate(v *payloadValidator)
+}
+
+// DecodeSyncPayload decodes transaction data into a typed payload for the
+// given schema version and validates it. Failures are returned as a
+// *SyncValidationError with a path for each offending field.
+func DecodeSyncPayload(version int, data map[string]interface{}, payload syncPayload) error {
+	if version < SyncSchemaV2 {
+		data = upgradeV1Payload(data)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return &SyncValidationError{Fields: []SyncFieldError{{Path: "data", Code: SyncFieldInvalid, Message: err.Error()}}}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if version >= SyncSchemaV2 {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(payload); err != nil {
+		return &SyncValidationError{Fields: []SyncFieldError{decodeFieldError(err)}}
+	}
+
+	v := &payloadValidator{}
+	payload.validate(v)
+	if len(v.fields) > 0 {
+		return &SyncValidationError{Fields: v.fields}
+	}
+	return nil
+}
+
+// upgradeV1Payload rewrites a v1 payload into the v2 shape.
+func upgradeV1Payload(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		out[key] = value
+	}
+	stringifyAmounts(out, "amount", "default_selling_price", "unit_cost")
+	if lines, ok := out["lines"].([]interface{}); ok {
+		upgraded := make([]interface{}, len(lines))
+		for i, line := range lines {
+			if fields, ok := line.(map[string]interface{}); ok {
+				copied := make(map[string]interface{}, len(fields))
+				for key, value := range fields {
+					copied[key] = value
+				}
+				stringifyAmounts(copied, "unit_price", "discount")
+				line = copied
+			}
+			upgraded[i] = line
+		}
+		out["lines"] = upgraded
+	}
+	if description, ok := out["description"]; ok {
+		out["note"] = description
+		delete(out, "description")
+	}
+	if category, ok := out["category"].(string); ok {
+		out["category"] = strings.ToUpper(strings.TrimSpace(category))
+	}
+	if movementType, ok := out["movement_type"].(string); ok {
+		out["movement_type"] = strings.ToLower(strings.TrimSpace(movementType))
+	}
+	return out
+}
+
+// stringifyAmounts rewrites the given numeric fields as decimal strings.
+func stringifyAmounts(data map[string]interface{}, keys ...string) {
+	for _, key := range keys {
+		switch value := data[key].(type) {
+		case float64:
+			data[key] = decimal.NewFromFloat(value).String()
+		case int:
+			data[key] = decimal.NewFromInt(int64(value)).String()
+		case int32:
+			data[key] = decimal.NewFromInt(int64(value)).String()
+		case int64:
+			data[key] = decimal.NewFromInt(value).String()
+		}
+	}
+}
+
+func decodeFieldError(err error) SyncFieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return SyncFieldError{Path: "data." + typeErr.Field, Code: SyncFieldInvalid, Message: "must be a " + jsonKind(typeErr.Type.Kind().String())}
+	}
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return SyncFieldError{Path: "data." + strings.Trim(name, `"`), Code: SyncFieldUnknown, Message: "unknown field"}
+	}
+	return SyncFieldError{Path: "data", Code: SyncFieldInvalid, Message: err.Error()}
+}
+
+func jsonKind(kind string) string {
+	switch kind {
+	case "string", "ptr":
+		return "string"
+	case "int", "int64":
+		return "whole number"
+	}
+	return kind
+}
+
+// SyncQuantity is a stock quantity in a payload. Devices may send it as a
+// JSON number or as a decimal string; either may be fractional, for products
+// sold by weight or volume.
+type SyncQuantity struct {
+	decimal.Decimal
+	malformed bool
+}
+
+// UnmarshalJSON reads a number or a decimal string. A value that is neither
+// is left for the validator to report against its field.
+func (q *SyncQuantity) UnmarshalJSON(data []byte) error {
+	q.malformed = q.Decimal.UnmarshalJSON(data) != nil
+	return nil
+}
+
+// payloadValidator collects field errors for a payload.
+type payloadValidator struct {
+	fields []SyncFieldError
+}
+
+func (v *payloadValidator) fail(field, code, message string) {
+	v.fields = append(v.fields, SyncFieldError{Path: "data." + field, Code: code, Message: message})
+}
+
+func (v *payloadValidator) required(field string, present bool) bool {
+	if !present {
+		v.fail(field, SyncFieldRequired, "is required")
+	}
+	return present
+}
+
+// amount parses a decimal string field. It returns zero after recording an
+// error when the value is missing, malformed or negative.
+func (v *payloadValidator) amount(field string, raw *string, required bool) decimal.Decimal {
+	if raw == nil {
+		if required {
+			v.fail(field, SyncFieldRequired, "is required")
+		}
+		return decimal.Zero
+	}
+	value, err := decimal.NewFromString(strings.TrimSpace(*raw))
+	if err != nil {
+		v.fail(field, SyncFieldInvalid, "must be a decimal string")
+		return decimal.Zero
+	}
+	if value.IsNegative() {
+		v.fail(field, SyncFieldInvalid, "cannot be negative")
+		return decimal.Zero
+	}
+	return value
+}
+
+// quantity checks that a quantity field holds a number. It returns false
+// after recording an error when it does not.
+func (v *payloadValidator) quantity(field string, q *SyncQuantity) bool {
+	if q.malformed {
+		v.fail(field, SyncFieldInvalid, "must be a number or a decimal string")
+		return false
+	}
+	return true
+}
+
+// timestamp parses an RFC 3339 string field.
+func (v *payloadValidator) timestamp(field string, raw *string, required bool) time.Time {
+	if raw == nil {
+		if required {
+			v.fail(field, SyncFieldRequired, "is required")
+		}
+		return time.Time{}
+	}
+	parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(*raw))
+	if err != nil {
+		v.fail(field, SyncFieldInvalid, "must be an RFC 3339 timestamp")
+		return time.Time{}
+	}
+	return parsed.UTC()
+}
+
+// ref checks that a server ID or a local ID is given. The server ID wins
+// when both are.
+func (v *payloadValidator) ref(ref SyncRef, idField, localField string) {
+	if !ref.IsSet() {
+		v.fail(idField, SyncFieldRequired, fmt.Sprintf("%s or %s is required", idField, localField))
+	}
+}
+
+// SyncRef points at a record by its server ID or by the local ID the same
+// device synced it under.
+type SyncRef struct {
+	ID      string
+	LocalID string
+}
+
+// IsSet reports whether either reference was given.
+func (r SyncRef) IsSet() bool {
+	return strings.TrimSpace(r.ID) != "" || strings.TrimSpace(r.LocalID) != ""
+}
+
+// SyncMutation carries the fields a device sends when changing an existing
+// record, used for conflict detection.
+type SyncMutation struct {
+	BaseVersion *int64  `json:"base_version,omitempty"`
+	UpdatedAt   *string `json:"updated_at,omitempty"`
+
+	Updated time.Time `json:"-"`
+}
+
+// ChangedAt returns when the device made its change, falling back to now.
+func (m SyncMutation) ChangedAt() time.Time {
+	if !m.Updated.IsZero() {
+		return m.Updated
+	}
+	return time.Now().UTC()
+}
+
+func (m *SyncMutation) validate(v *payloadValidator) {
+	if m.BaseVersion != nil && *m.BaseVersion < 0 {
+		v.fail("base_version", SyncFieldInvalid, "cannot be negative")
+	}
+	m.Updated = v.timestamp("updated_at", m.UpdatedAt, false)
+}
+
+// SaleSyncPayload is the data of a "sale" transaction. A basket is sent as
+// lines; a single-product sale may instead give its product, quantity and
+// amount at the top level.
+type SaleSyncPayload struct {
+	Lines          []SaleLineSyncPayload `json:"lines,omitempty"`
+	ProductID      string                `json:"product_id,omitempty"`
+	ProductLocalID string                `json:"product_local_id,omitempty"`
+	Quantity       *SyncQuantity         `json:"quantity"`
+	Amount         *string               `json:"amount"`
+	Note           string                `json:"note,omitempty"`
+	CreatedAt      *string               `json:"created_at"`
+
+	Total   decimal.Decimal `json:"-"`
+	Created time.Time       `json:"-"`
+}
+
+// Product returns the product reference, which is optional for sales.
+func (p *SaleSyncPayload) Product() SyncRef {
+	return SyncRef{ID: p.ProductID, LocalID: p.ProductLocalID}
+}
+
+func (p *SaleSyncPayload) validate(v *payloadValidator) {
+	if len(p.Lines) == 0 {
+		if v.required("quantity", p.Quantity != nil) && v.quantity("quantity", p.Quantity) && !p.Quantity.IsPositive() {
+			v.fail("quantity", SyncFieldInvalid, "must be greater than 0")
+		}
+		p.Total = v.amount("amount", p.Amount, true)
+		p.Created = v.timestamp("created_at", p.CreatedAt, true)
+		return
+	}
+
+	if p.Quantity != nil || p.Product().IsSet() {
+		v.fail("lines", SyncFieldInvalid, "cannot be combined with a top-level product or quantity")
+	}
+	if len(p.Lines) > MaxSaleLines {
+		v.fail("lines", SyncFieldInvalid, fmt.Sprintf("cannot have more than %d lines", MaxSaleLines))
+	}
+	total := decimal.Zero
+	for i := range p.Lines {
+		p.Lines[i].validate(v, fmt.Sprintf("lines[%d].", i))
+		total = total.Add(p.Lines[i].Total())
+	}
+	p.Total = total
+	if p.Amount != nil && !v.amount("amount", p.Amount, false).Equal(total) {
+		v.fail("amount", SyncFieldInvalid, "does not match the sum of the lines")
+	}
+	p.Created = v.timestamp("created_at", p.CreatedAt, true)
+}
+
+// SaleLineSyncPayload is one line of a synced sale.
+type SaleLineSyncPayload struct {
+	ProductID      string        `json:"product_id,omitempty"`
+	ProductLocalID string        `json:"product_local_id,omitempty"`
+	Quantity       *SyncQuantity `json:"quantity"`
+	UnitPrice      *string       `json:"unit_price"`
+	Discount       *string       `json:"discount,omitempty"`
+
+	Price         decimal.Decimal `json:"-"`
+	DiscountValue decimal.Decimal `json:"-"`
+	Units         decimal.Decimal `json:"-"`
+}
+
+// Product returns the product reference, which is optional for a line.
+func (l *SaleLineSyncPayload) Product() SyncRef {
+	return SyncRef{ID: l.ProductID, LocalID: l.ProductLocalID}
+}
+
+// Total returns the line total once the line has been validated.
+func (l *SaleLineSyncPayload) Total() decimal.Decimal {
+	line := NewSaleLine(nil, l.Units, l.Price, l.DiscountValue)
+	return line.Total
+}
+
+func (l *SaleLineSyncPayload) validate(v *payloadValidator, prefix string) {
+	if v.required(prefix+"quantity", l.Quantity != nil) && v.quantity(prefix+"quantity", l.Quantity) {
+		if !l.Quantity.IsPositive() {
+			v.fail(prefix+"quantity", SyncFieldInvalid, "must be greater than 0")
+		} else {
+			l.Units = l.Quantity.Decimal
+		}
+	}
+	l.Price = v.amount(prefix+"unit_price", l.UnitPrice, true)
+	l.DiscountValue = v.amount(prefix+"discount", l.Discount, false)
+	if l.DiscountValue.GreaterThan(l.Price.Mul(l.Units)) {
+		v.fail(prefix+"discount", SyncFieldInvalid, "cannot exceed the line amount")
+	}
+}
+
+// ExpenseSyncPayload is the data of an "expense" transaction.
+type ExpenseSyncPayload struct {
+	Category  string  `json:"category"`
+	Amount    *string `json:"amount"`
+	Note      string  `json:"note,omitempty"`
+	CreatedAt *string `json:"created_at"`
+
+	Value   decimal.Decimal `json:"-"`
+	Created time.Time       `json:"-"`
+}
+
+func (p *ExpenseSyncPayload) validate(v *payloadValidator) {
+	if v.required("category", strings.TrimSpace(p.Category) != "") && !IsValidExpenseCategory(p.Category) {
+		v.fail("category", SyncFieldInvalid, "is not a valid expense category")
+	}
+	p.Value = v.amount("amount", p.Amount, true)
+	p.Created = v.timestamp("created_at", p.CreatedAt, true)
+}
+
+// ProductSyncPayload is the data of a "product" transaction.
+type ProductSyncPayload struct {
+	Name                string            `json:"name"`
+	SKU                 string            `json:"sku,omitempty"`
+	Barcode             string            `json:"barcode,omitempty"`
+	Category            string            `json:"category,omitempty"`
+	Unit                UnitOfMeasure     `json:"unit,omitempty"`
+	ParentID            string            `json:"parent_id,omitempty"`       // Set on a variant: the product it is a variant of
+	ParentLocalID       string            `json:"parent_local_id,omitempty"` // Or the local_id that product was synced with
+	Attributes          map[string]string `json:"attributes,omitempty"`
+	DefaultSellingPrice *string           `json:"default_selling_price"`
+	UnitCost            *string           `json:"unit_cost,omitempty"` // Purchase cost of the initial stock
+	StockQuantity       SyncQuantity      `json:"stock_quantity,omitempty"`
+	LowStockThreshold   SyncQuantity      `json:"low_stock_threshold,omitempty"`
+	CreatedAt           *string           `json:"created_at"`
+
+	Price   decimal.Decimal `json:"-"`
+	Cost    decimal.Decimal `json:"-"`
+	Created time.Time       `json:"-"`
+}
+
+// Parent returns the product a variant belongs to. It is unset for a
+// product that is not a variant.
+func (p *ProductSyncPayload) Parent() SyncRef {
+	return SyncRef{ID: p.ParentID, LocalID: p.ParentLocalID}
+}
+
+func (p *ProductSyncPayload) validate(v *payloadValidator) {
+	v.required("name", strings.TrimSpace(p.Name) != "")
+	p.Price = v.amount("default_selling_price", p.DefaultSellingPrice, true)
+	if p.DefaultSellingPrice != nil && !p.Price.IsPositive() {
+		v.fail("default_selling_price", SyncFieldInvalid, "must be greater than 0")
+	}
+	p.Cost = v.amount("unit_cost", p.UnitCost, false)
+	if v.quantity("stock_quantity", &p.StockQuantity) && p.StockQuantity.IsNegative() {
+		v.fail("stock_quantity", SyncFieldInvalid, "cannot be negative")
+	}
+	if v.quantity("low_stock_threshold", &p.LowStockThreshold) && p.LowStockThreshold.IsNegative() {
+		v.fail("low_stock_threshold", SyncFieldInvalid, "cannot be negative")
+	}
+	p.Created = v.timestamp("created_at", p.CreatedAt, true)
+}
+
+// StockAdjustmentSyncPayload is the data of a "stock_adjustment" transaction.
+type StockAdjustmentSyncPayload struct {
+	SyncMutation
+	ProductID      string        `json:"product_id,omitempty"`
+	ProductLocalID string        `json:"product_local_id,omitempty"`
+	MovementType   MovementType  `json:"movement_type"`
+	Quantity       *SyncQuantity `json:"quantity"`
+	UnitCost       *string       `json:"unit_cost,omitempty"` // Purchase cost of stock received; purchases and returns only
+	Reason         string        `json:"reason,omitempty"`
+	CreatedAt      *string       `json:"created_at"`
+
+	Cost    *decimal.Decimal `json:"-"`
+	Created time.Time        `json:"-"`
+}
+
+// Product returns the adjusted product reference.
+func (p *StockAdjustmentSyncPayload) Product() SyncRef {
+	return SyncRef{ID: p.ProductID, LocalID: p.ProductLocalID}
+}
+
+func (p *StockAdjustmentSyncPayload) validate(v *payloadValidator) {
+	p.SyncMutation.validate(v)
+	v.ref(p.Product(), "product_id", "product_local_id")
+	if v.required("movement_type", p.MovementType != "") {
+		switch p.MovementType {
+		case MovementTypePurchase, MovementTypeSale, MovementTypeAdjust, MovementTypeDamage, MovementTypeTheft, MovementTypeReturn:
+		default:
+			v.fail("movement_type", SyncFieldInvalid, "is not a valid movement type")
+		}
+	}
+	if v.required("quantity", p.Quantity != nil) && v.quantity("quantity", p.Quantity) {
+		if p.Quantity.IsNegative() || (p.Quantity.IsZero() && p.MovementType != MovementTypeAdjust) {
+			v.fail("quantity", SyncFieldInvalid, "must be greater than 0")
+		}
+	}
+	if p.UnitCost != nil {
+		// Only stock coming in has a purchase cost
+		if p.MovementType != MovementTypePurchase && p.MovementType != MovementTypeReturn {
+			v.fail("unit_cost", SyncFieldInvalid, "only applies to purchase and return movements")
+		}
+		cost := v.amount("unit_cost", p.UnitCost, false)
+		p.Cost = &cost
+	}
+	p.Created = v.timestamp("created_at", p.CreatedAt, true)
+	if p.Updated.IsZero() {
+		p.Updated = p.Created
+	}
+}
+
+// SaleVoidSyncPayload is the data of a "sale_void" transaction.
+type SaleVoidSyncPayload struct {
+	SyncMutation
+	SaleID      string `json:"sale_id,omitempty"`
+	SaleLocalID string `json:"sale_local_id,omitempty"`
+}
+
+// Sale returns the voided sale reference.
+func (p *SaleVoidSyncPayload) Sale() SyncRef {
+	return SyncRef{ID: p.SaleID, LocalID: p.SaleLocalID}
+}
+
+func (p *SaleVoidSyncPayload) validate(v *payloadValidator) {
+	p.SyncMutation.validate(v)
+	v.ref(p.Sale(), "sale_id", "sale_local_id")
+}
+
+// ExpenseUpdateSyncPayload is the data of an "expense_update" transaction.
+// Only the fields present are changed.
+type ExpenseUpdateSyncPayload struct {
+	SyncMutation
+	ExpenseID      string  `json:"expense_id,omitempty"`
+	ExpenseLocalID string  `json:"expense_local_id,omitempty"`
+	Category       *string `json:"category,omitempty"`
+	Amount         *string `json:"amount,omitempty"`
+	Note           *string `json:"note,omitempty"`
+
+	Value decimal.Decimal `json:"-"`
+}
+
+// Expense returns the updated expense reference.
+func (p *ExpenseUpdateSyncPayload) Expense() SyncRef {
+	return SyncRef{ID: p.ExpenseID, LocalID: p.ExpenseLocalID}
+}
+
+func (p *ExpenseUpdateSyncPayload) validate(v *payloadValidator) {
+	p.SyncMutation.validate(v)
+	v.ref(p.Expense(), "expense_id", "expense_local_id")
+	if p.Category != nil && !IsValidExpenseCategory(*p.Category) {
+		v.fail("category", SyncFieldInvalid, "is not a valid expense category")
+	}
+	p.Value = v.amount("amount", p.Amount, false)
+	if p.Category == nil && p.Amount == nil && p.Note == nil {
+		v.fields = append(v.fields, SyncFieldError{Path: "data", Code: SyncFieldRequired, Message: "at least one of category, amount or note is required"})
+	}
+}
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Chunked upload errors
+var (
+	ErrUploadNotFound     = errors.New("sync upload not found or expired")
+	ErrUploadCommitted    = errors.New("sync upload already committed")
+	ErrUploadIncomplete   = errors.New("sync upload is missing chunks")
+	ErrInvalidChunkIndex  = errors.New("chunk index out of range")
+	ErrSyncUploadTooLarge = errors.New("sync upload exceeds the maximum number of transactions")
+	ErrUploadNotYours     = errors.New("sync upload was started by another user and device")
+)
+
+// SyncUploadStatus tracks a chunked upload from start to commit.
+type SyncUploadStatus string
+
+const (
+	SyncUploadOpen       SyncUploadStatus = "open"
+	SyncUploadCommitting SyncUploadStatus = "committing"
+	SyncUploadCommitted  SyncUploadStatus = "committed"
+)
+
+// SyncUpload is a resumable upload session. A device splits a large offline
+// backlog into chunks, uploads them in any order (re-sending a chunk replaces
+// it) and commits once every chunk has arrived. The committed transactions
+// are processed as a single sync batch.
+type SyncUpload struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BusinessID     primitive.ObjectID `json:"business_id" bson:"business_id"`
+	DeviceID       string             `json:"device_id" bson:"device_id"`
+	UserID         string             `json:"-" bson:"user_id"`
+	TotalChunks    int                `json:"total_chunks" bson:"total_chunks"`
+	ReceivedChunks []int              `json:"received_chunks" bson:"received_chunks"`
+	MissingChunks  []int              `json:"missing_chunks" bson:"-"`
+	Atomic         bool               `json:"atomic" bson:"atomic"`
+	SchemaVersion  int                `json:"schema_version" bson:"schema_version"`
+	SyncTimestamp  time.Time          `json:"sync_timestamp" bson:"sync_timestamp"`
+	Status         SyncUploadStatus   `json:"status" bson:"status"`
+	SyncID         string             `json:"sync_id,omitempty" bson:"sync_id,omitempty"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	ExpiresAt      time.Time          `json:"expires_at" bson:"expires_at"`
+}
+
+// Missing returns the chunk indexes (0-based) not received yet.
+func (u *SyncUpload) Missing() []int {
+	received := make(map[int]struct{}, len(u.ReceivedChunks))
+	for _, index := range u.ReceivedChunks {
+		received[index] = struct{}{}
+	}
+	missing := make([]int, 0)
+	for index := 0; index < u.TotalChunks; index++ {
+		if _, ok := received[index]; !ok {
+			missing = append(missing, index)
+		}
+	}
+	return missing
+}
+
+// StartedBy reports whether the upload was started by the user or from the
+// device. Only they may commit it.
+func (u *SyncUpload) StartedBy(userID, deviceID string) bool {
+	return (userID != "" && u.UserID == userID) || (deviceID != "" && u.DeviceID == deviceID)
+}
+
+// StartSyncUploadRequest opens a chunked upload session.
+type StartSyncUploadRequest struct {
+	BusinessID    string    `json:"business_id"`
+	DeviceID      string    `json:"device_id"`
+	TotalChunks   int       `json:"total_chunks"`
+	Atomic        bool      `json:"atomic"`
+	SchemaVersion int       `json:"schema_version"`
+	SyncTimestamp time.Time `json:"sync_timestamp"`
+	UserID        string    `json:"-"`
+}
+
+// SyncUploadChunkRequest carries one chunk of an upload session.
+type SyncUploadChunkRequest struct {
+	BusinessID   string                 `json:"business_id"`
+	Transactions []SyncBatchTransaction `json:"transactions"`
+	Role         BusinessRole           `json:"-"`
+}
+
+// CommitSyncUploadRequest asks for a complete upload to be processed. The
+// committer must be the user who started the upload or be on its device.
+type CommitSyncUploadRequest struct {
+	BusinessID string       `json:"business_id"`
+	DeviceID   string       `json:"device_id"`
+	UserID     string       `json:"-"`
+	Role       BusinessRole `json:"-"`
+	RequestID  string       `json:"-"`
+}
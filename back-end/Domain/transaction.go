@@ -26,6 +26,18 @@ type Transaction struct {
 	Category    *string         `json:"category"`
 	Description string          `json:"description"`
 	CreatedAt   time.Time       `json:"created_at"`
+	// Items are the lines of a sale; expenses have none
+	Items []TransactionItem `json:"items,omitempty"`
+}
+
+// TransactionItem is one line of a sale in the unified view
+type TransactionItem struct {
+	ProductID   *string         `json:"product_id"`
+	ProductName *string         `json:"product_name"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	UnitPrice   decimal.Decimal `json:"unit_price"`
+	Discount    decimal.Decimal `json:"discount"`
+	Total       decimal.Decimal `json:"total"`
 }
 
 // TransactionFilter contains filter options for transaction queries
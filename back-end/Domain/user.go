@@ -16,6 +16,13 @@ type User struct {
 	Name         string             `bson:"name" json:"name"`
 	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+
+	// PhoneVerified is set once the user proves they receive SMS on Phone
+	PhoneVerified   bool       `bson:"phone_verified" json:"phone_verified"`
+	PhoneVerifiedAt *time.Time `bson:"phone_verified_at,omitempty" json:"phone_verified_at,omitempty"`
+	// PendingInvitationID is the invitation the user registered with; it is
+	// redeemed once the phone is verified
+	PendingInvitationID *primitive.ObjectID `bson:"pending_invitation_id,omitempty" json:"-"`
 }
 
 // NewUser creates a new User instance
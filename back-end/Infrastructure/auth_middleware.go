@@ -1,15 +1,39 @@
 package infrastructure
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	domain "shop-ops/Domain"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(jwtService *JWTService, logger *Logger) gin.HandlerFunc {
+// SessionValidator reports whether a login session has been revoked, and
+// records that an active one was used.
+type SessionValidator interface {
+	IsSessionActive(sessionId string) (bool, error)
+}
+
+// APIKeyAuthenticator resolves a presented API key to its live record.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (*domain.APIKey, error)
+}
+
+// AuthMiddleware accepts either a user's access token or a business API key,
+// sent as "Authorization: Bearer" or in the X-API-Key header. A user token
+// sets "user_id"; an API key sets "api_key", its "business_id" and its
+// "api_key_scopes", and only gets a "user_id" once Authorizer.Require has
+// checked it against the route.
+func AuthMiddleware(jwtService *JWTService, sessions SessionValidator, apiKeys APIKeyAuthenticator, logger *Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			authenticateAPIKey(c, apiKeys, key, logger)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
@@ -23,6 +47,10 @@ func AuthMiddleware(jwtService *JWTService, logger *Logger) gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
+		if strings.HasPrefix(tokenString, domain.APIKeyPrefix) {
+			authenticateAPIKey(c, apiKeys, tokenString, logger)
+			return
+		}
 		token, err := jwtService.ValidateToken(tokenString)
 		if err != nil {
 			logger.Warn("AUTH", "Token validation error: %v", err)
@@ -55,7 +83,74 @@ func AuthMiddleware(jwtService *JWTService, logger *Logger) gin.HandlerFunc {
 			return
 		}
 
+		// Every access token belongs to a session, so it can be revoked
+		sessionID, _ := claims["sid"].(string)
+		if sessionID == "" {
+			logger.Warn("AUTH", "Rejected access token without a session for user %s", userID)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		// Tokens of a revoked session stop working before they expire
+		if sessions != nil {
+			active, err := sessions.IsSessionActive(sessionID)
+			if err != nil {
+				logger.Error("AUTH", "Session lookup failed: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session", "code": "SYS_001"})
+				return
+			}
+			if !active {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked", "code": "AUTH_001"})
+				return
+			}
+		}
+
 		c.Set("user_id", userID)
+		c.Set("session_id", sessionID)
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, plain string, logger *Logger) {
+	if apiKeys == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API keys are not accepted here"})
+		return
+	}
+
+	key, err := apiKeys.AuthenticateAPIKey(plain)
+	if errors.Is(err, domain.ErrInvalidAPIKey) {
+		logger.Warn("AUTH", "Rejected API key from %s", c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API key"})
+		return
+	}
+	if err != nil {
+		logger.Error("AUTH", "API key lookup failed: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key", "code": "SYS_001"})
+		return
+	}
+
+	c.Set("api_key", key)
+	c.Set("business_id", key.BusinessID.Hex())
+	c.Set("api_key_scopes", key.Scopes)
+	c.Next()
+}
+
+// APIKeyFromContext returns the API key the request authenticated with, or
+// nil for a user token.
+func APIKeyFromContext(c *gin.Context) *domain.APIKey {
+	key, _ := c.Get("api_key")
+	apiKey, _ := key.(*domain.APIKey)
+	return apiKey
+}
+
+// RequireUser keeps API keys off routes that act on the caller's own account
+// rather than on one business.
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if APIKeyFromContext(c) != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a user login", "code": "AUTH_003"})
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,191 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	domain "shop-ops/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BusinessAccess resolves the role a user holds in a business.
+type BusinessAccess interface {
+	ResolveRole(businessId string, userId string) (domain.BusinessRole, error)
+}
+
+// BusinessIDResolver finds the business a request acts on. It returns an
+// empty ID when the request does not name one.
+type BusinessIDResolver func(c *gin.Context) (string, error)
+
+// ResolveError lets a BusinessIDResolver choose the response sent when it
+// cannot find the business, e.g. 404 when the record a route names is missing.
+type ResolveError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *ResolveError) Error() string {
+	return e.Message
+}
+
+// Authorizer checks that the caller's role in the requested business grants
+// the permission a route needs. It runs after AuthMiddleware.
+type Authorizer struct {
+	access BusinessAccess
+	logger *Logger
+}
+
+func NewAuthorizer(access BusinessAccess, logger *Logger) *Authorizer {
+	return &Authorizer{access: access, logger: logger}
+}
+
+// Require returns a middleware that only lets the request through if the
+// caller's role grants permission. The business is looked up with the given
+// resolvers, or BusinessIDFromRequest if none are given. On success the
+// business ID and role are stored as "business_id" and "business_role".
+//
+// An API key acts as the user who created it, limited to its own business
+// and its scopes.
+func (a *Authorizer) Require(permission domain.Permission, resolvers ...BusinessIDResolver) gin.HandlerFunc {
+	if len(resolvers) == 0 {
+		resolvers = []BusinessIDResolver{BusinessIDFromRequest}
+	}
+
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		apiKey := APIKeyFromContext(c)
+		if apiKey != nil {
+			userID = apiKey.CreatedBy.Hex()
+		}
+		if userID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated", "code": "AUTH_001"})
+			return
+		}
+
+		businessID, err := resolveBusinessID(c, resolvers)
+		var resolveErr *ResolveError
+		if errors.As(err, &resolveErr) {
+			c.AbortWithStatusJSON(resolveErr.Status, gin.H{"error": resolveErr.Message, "code": resolveErr.Code})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VAL_001"})
+			return
+		}
+		if businessID == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "business_id is required", "code": "VAL_001"})
+			return
+		}
+		if apiKey != nil && businessID != apiKey.BusinessID.Hex() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key does not belong to this business", "code": "AUTH_003"})
+			return
+		}
+
+		role, err := a.access.ResolveRole(businessID, userID)
+		switch {
+		case errors.Is(err, domain.ErrBusinessNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Business not found", "code": "BIZ_001"})
+			return
+		case errors.Is(err, domain.ErrNotMember):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied", "code": "AUTH_003"})
+			return
+		case err != nil:
+			a.logger.Error("AUTHZ", "Failed to resolve role of user %s in business %s: %v", userID, businessID, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions", "code": "SYS_001"})
+			return
+		}
+
+		if !role.Can(permission) {
+			a.logger.Warn("AUTHZ", "User %s (%s) denied %s in business %s", userID, role, permission, businessID)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": domain.ErrPermissionDenied.Error(), "code": "AUTH_003"})
+			return
+		}
+		if apiKey != nil {
+			if !apiKey.Allows(permission, c.Request.Method) {
+				a.logger.Warn("AUTHZ", "API key %s denied %s %s in business %s", apiKey.ID.Hex(), c.Request.Method, permission, businessID)
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": domain.ErrAPIKeyScopeDenied.Error(), "code": "AUTH_003"})
+				return
+			}
+			c.Set("user_id", userID)
+		}
+
+		c.Set("business_id", businessID)
+		c.Set("business_role", string(role))
+		c.Next()
+	}
+}
+
+// BusinessRole returns the role stored by Authorizer.Require.
+func BusinessRole(c *gin.Context) domain.BusinessRole {
+	return domain.BusinessRole(c.GetString("business_role"))
+}
+
+func resolveBusinessID(c *gin.Context, resolvers []BusinessIDResolver) (string, error) {
+	for _, resolve := range resolvers {
+		businessID, err := resolve(c)
+		if err != nil || businessID != "" {
+			return businessID, err
+		}
+	}
+	return "", nil
+}
+
+// BusinessIDFromRequest reads the business ID from the :businessId path
+// parameter, the business_id or businessId query parameter, or the
+// business_id field of a JSON body. A request naming two different
+// businesses is rejected so the handler cannot act on one the caller was
+// not checked against.
+func BusinessIDFromRequest(c *gin.Context) (string, error) {
+	bodyID, err := businessIDFromBody(c)
+	if err != nil {
+		return "", err
+	}
+
+	businessID := ""
+	for _, id := range []string{c.Param("businessId"), c.Query("business_id"), c.Query("businessId"), bodyID} {
+		if id == "" {
+			continue
+		}
+		if businessID != "" && id != businessID {
+			return "", errors.New("request names more than one business")
+		}
+		businessID = id
+	}
+	return businessID, nil
+}
+
+// businessIDFromBody peeks at the JSON body and puts it back so the handler
+// can still bind it.
+func businessIDFromBody(c *gin.Context) (string, error) {
+	var peek struct {
+		BusinessID string `json:"business_id"`
+	}
+	if err := peekJSONBody(c, &peek); err != nil {
+		return "", err
+	}
+	return peek.BusinessID, nil
+}
+
+// peekJSONBody decodes the JSON body into v, if it is JSON at all, and puts
+// the body back for the handler.
+func peekJSONBody(c *gin.Context, v interface{}) error {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	if err != nil {
+		return errors.New("failed to read request body")
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) > 0 {
+		_ = json.Unmarshal(body, v)
+	}
+	return nil
+}
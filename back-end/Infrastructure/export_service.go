@@ -8,6 +8,8 @@ import (
 	Domain "shop-ops/Domain"
 	"strings"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 const defaultExportRetention = 7 * 24 * time.Hour
@@ -88,31 +90,37 @@ func (s *ExportService) GenerateSalesCSV(exportID string, sales []Domain.Sale) (
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	// Write headers
-	headers := []string{"ID", "Date", "Amount", "Product ID", "Quantity", "Description", "Created At", "Voided"}
+	// Write headers: one row per sale line, repeating the sale columns
+	headers := []string{"ID", "Date", "Amount", "Line", "Product ID", "Quantity", "Unit Price", "Discount", "Line Total", "Description", "Created At", "Voided"}
 	if err := writer.Write(headers); err != nil {
 		return "", fmt.Errorf("failed to write headers: %w", err)
 	}
 
 	// Write data
 	for _, sale := range sales {
-		productID := ""
-		if sale.ProductID != nil {
-			productID = sale.ProductID.Hex()
-		}
-
-		row := []string{
-			sale.ID.Hex(),
-			sale.CreatedAt.Format(time.RFC3339),
-			fmt.Sprintf("%.2f", sale.Total), // Assuming Total is the amount
-			productID,
-			fmt.Sprintf("%d", sale.Quantity),
-			sale.Note,
-			sale.CreatedAt.Format(time.RFC3339),
-			fmt.Sprintf("%t", sale.IsVoided),
-		}
-		if err := writer.Write(row); err != nil {
-			return "", fmt.Errorf("failed to write row: %w", err)
+		for i, line := range sale.Lines {
+			productID := ""
+			if line.ProductID != nil {
+				productID = line.ProductID.Hex()
+			}
+
+			row := []string{
+				sale.ID.Hex(),
+				sale.CreatedAt.Format(time.RFC3339),
+				sale.Total.StringFixed(2),
+				fmt.Sprintf("%d", i+1),
+				productID,
+				fmt.Sprintf("%d", line.Quantity),
+				line.UnitPrice.StringFixed(2),
+				line.Discount.StringFixed(2),
+				line.Total.StringFixed(2),
+				sale.Note,
+				sale.CreatedAt.Format(time.RFC3339),
+				fmt.Sprintf("%t", sale.IsVoided),
+			}
+			if err := writer.Write(row); err != nil {
+				return "", fmt.Errorf("failed to write row: %w", err)
+			}
 		}
 	}
 
@@ -181,7 +189,7 @@ func (s *ExportService) GenerateTransactionsCSV(exportID string, transactions []
 	defer writer.Flush()
 
 	// Write headers
-	headers := []string{"Date", "Type", "Amount", "Category", "Product", "Description", "Created At"}
+	headers := []string{"Date", "Type", "Amount", "Category", "Product", "Items", "Description", "Created At"}
 	if err := writer.Write(headers); err != nil {
 		return "", fmt.Errorf("failed to write headers: %w", err)
 	}
@@ -196,6 +204,22 @@ func (s *ExportService) GenerateTransactionsCSV(exportID string, transactions []
 		if txn.ProductName != nil {
 			productName = *txn.ProductName
 		}
+		// A basket lists the products of all its lines
+		items := ""
+		if len(txn.Items) > 0 {
+			names := make([]string, 0, len(txn.Items))
+			quantity := decimal.Zero
+			for _, item := range txn.Items {
+				if item.ProductName != nil {
+					names = append(names, *item.ProductName)
+				}
+				quantity = quantity.Add(item.Quantity)
+			}
+			if productName == "" {
+				productName = strings.Join(names, "; ")
+			}
+			items = quantity.String()
+		}
 
 		row := []string{
 			txn.Date.Format("2006-01-02"), // Simplified Date
@@ -203,6 +227,7 @@ func (s *ExportService) GenerateTransactionsCSV(exportID string, transactions []
 			txn.Amount.String(),
 			category,
 			productName,
+			items,
 			txn.Description,
 			txn.CreatedAt.Format(time.RFC3339),
 		}
@@ -232,7 +257,7 @@ func (s *ExportService) GenerateInventoryCSV(exportID string, products []Domain.
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	headers := []string{"ID", "Name", "Default Selling Price", "Stock Quantity", "Low Stock Threshold", "Low Stock", "Created At", "Updated At"}
+	headers := []string{"ID", "Name", "SKU", "Barcode", "Category", "Unit", "Default Selling Price", "Stock Quantity", "Low Stock Threshold", "Low Stock", "Created At", "Updated At"}
 	if err := writer.Write(headers); err != nil {
 		return "", fmt.Errorf("failed to write headers: %w", err)
 	}
@@ -241,6 +266,10 @@ func (s *ExportService) GenerateInventoryCSV(exportID string, products []Domain.
 		row := []string{
 			product.ID.Hex(),
 			product.Name,
+			product.SKU,
+			product.Barcode,
+			product.Category,
+			string(product.Unit()),
 			product.DefaultSellingPrice.String(),
 			fmt.Sprintf("%d", product.StockQuantity),
 			fmt.Sprintf("%d", product.LowStockThreshold),
@@ -277,13 +306,16 @@ func (s *ExportService) GenerateProfitCSV(exportID string, summary *Domain.Profi
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	headers := []string{"Total Sales", "Total Expenses", "Net Profit", "Period"}
+	headers := []string{"Total Sales", "Cost of Goods", "Gross Profit", "Gross Margin %", "Total Expenses", "Net Profit", "Period"}
 	if err := writer.Write(headers); err != nil {
 		return "", fmt.Errorf("failed to write headers: %w", err)
 	}
 
 	row := []string{
 		fmt.Sprintf("%.2f", summary.TotalSales),
+		fmt.Sprintf("%.2f", summary.CostOfGoods),
+		fmt.Sprintf("%.2f", summary.GrossProfit),
+		fmt.Sprintf("%.2f", summary.GrossMargin),
 		fmt.Sprintf("%.2f", summary.TotalExpenses),
 		fmt.Sprintf("%.2f", summary.NetProfit),
 		summary.Period,
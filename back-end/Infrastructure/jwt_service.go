@@ -1,56 +1,436 @@
 package infrastructure
 
 import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math/big"
 	"os"
+	"sort"
+	"sync"
 	"time"
 
+	domain "shop-ops/Domain"
+
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// SigningKeyStore keeps the signing keys shared by every API instance.
+type SigningKeyStore interface {
+	Save(key *domain.SigningKey) error
+	FindUnexpired(now time.Time) ([]*domain.SigningKey, error)
+}
+
+// JWTConfig configures token signing.
+type JWTConfig struct {
+	// Algorithm is used for newly generated keys
+	Algorithm domain.SigningAlgorithm
+	// Secret seals the private keys stored in the database
+	Secret string
+	// Rotation is how long each key signs before a new one is generated
+	Rotation   time.Duration
+	Production bool
+}
+
+// minSecretLength is the shortest JWT_SECRET accepted in production.
+const minSecretLength = 32
+
+// developmentSecret seals keys when JWT_SECRET is unset outside production.
+const developmentSecret = "shop-ops-development-secret"
+
+// sampleSecrets are the documented example values, which are not secret.
+var sampleSecrets = map[string]bool{
+	"default_secret_please_change":               true,
+	"your_super_secret_key_change_in_production": true,
+	developmentSecret:                            true,
+}
+
+// unknownKeyReloadInterval limits how often a token with an unknown kid
+// makes the service reload keys, so forged kids cannot flood the database.
+const unknownKeyReloadInterval = 30 * time.Second
+
+// JWTConfigFromEnv reads JWT_ALGORITHM, JWT_SECRET and JWT_KEY_ROTATION.
+// GIN_MODE=release counts as production, where a missing or sample
+// JWT_SECRET is refused.
+func JWTConfigFromEnv() (JWTConfig, error) {
+	config := JWTConfig{
+		Algorithm:  domain.SigningAlgorithm(os.Getenv("JWT_ALGORITHM")),
+		Secret:     os.Getenv("JWT_SECRET"),
+		Rotation:   domain.DefaultKeyRotation,
+		Production: os.Getenv("GIN_MODE") == gin.ReleaseMode,
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = domain.SigningEdDSA
+	}
+	if value := os.Getenv("JWT_KEY_ROTATION"); value != "" {
+		rotation, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("invalid JWT_KEY_ROTATION %q: %v", value, err)
+		}
+		config.Rotation = rotation
+	}
+	return config, config.Validate()
+}
+
+func (c JWTConfig) Validate() error {
+	if c.Algorithm != domain.SigningRS256 && c.Algorithm != domain.SigningEdDSA {
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q; use RS256 or EdDSA", c.Algorithm)
+	}
+	if c.Rotation <= 0 {
+		return errors.New("JWT_KEY_ROTATION must be positive")
+	}
+	if c.Production && weakSecret(c.Secret) {
+		return fmt.Errorf("JWT_SECRET must be a random value of at least %d characters in production", minSecretLength)
+	}
+	return nil
+}
+
+func weakSecret(secret string) bool {
+	return len(secret) < minSecretLength || sampleSecrets[secret]
+}
+
+// JWTService signs tokens with the newest active key and names it in the
+// token's kid header. Retired keys keep verifying the tokens they signed
+// until those have expired.
 type JWTService struct {
-	secretKey string
-	issuer    string
+	store   SigningKeyStore
+	config  JWTConfig
+	sealKey []byte
+	issuer  string
+	logger  *Logger
+
+	// refreshMu serializes Refresh so one instance never generates two keys
+	// at once
+	refreshMu sync.Mutex
+	mu        sync.RWMutex
+	keys      map[string]*jwtKey
+	signing   *jwtKey
+	loadedAt  time.Time
+}
+
+type jwtKey struct {
+	meta    *domain.SigningKey
+	method  jwt.SigningMethod
+	private crypto.Signer
 }
 
-func NewJWTService() *JWTService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default_secret_please_change"
+// NewJWTService loads the stored keys, generating the first one if there are
+// none.
+func NewJWTService(store SigningKeyStore, config JWTConfig, logger *Logger) (*JWTService, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
-	return &JWTService{
-		secretKey: secret,
-		issuer:    "shop-ops",
+
+	secret := config.Secret
+	if weakSecret(secret) {
+		logger.Warn("AUTH", "JWT_SECRET is unset or weak; this is only acceptable in development")
+		if secret == "" {
+			secret = developmentSecret
+		}
+	}
+	sealKey := sha256.Sum256([]byte(secret))
+
+	s := &JWTService{
+		store:   store,
+		config:  config,
+		sealKey: sealKey[:],
+		issuer:  "shop-ops",
+		logger:  logger,
 	}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-func (s *JWTService) GenerateToken(userId string) (string, error) {
+// Refresh reloads the shared keys, picking up keys made by other instances,
+// and generates a new signing key once the current one has retired.
+func (s *JWTService) Refresh() error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	now := time.Now()
+	stored, err := s.store.FindUnexpired(now)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*jwtKey, len(stored))
+	var signing *jwtKey
+	for _, meta := range stored {
+		key, err := s.open(meta)
+		if err != nil {
+			s.logger.Error("AUTH", "Skipping signing key %s: %v", meta.ID, err)
+			continue
+		}
+		keys[meta.ID] = key
+		// Stored keys come newest first
+		if signing == nil && meta.Algorithm == s.config.Algorithm && meta.SignsAt(now) {
+			signing = key
+		}
+	}
+
+	if signing == nil {
+		signing, err = s.generate(now)
+		if err != nil {
+			return err
+		}
+		keys[signing.meta.ID] = signing
+		s.logger.Info("AUTH", "Generated %s signing key %s", signing.meta.Algorithm, signing.meta.ID)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.signing = signing
+	s.loadedAt = now
+	s.mu.Unlock()
+	return nil
+}
+
+// StartRotation refreshes the keys every interval in the background.
+func (s *JWTService) StartRotation(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.Refresh(); err != nil {
+				s.logger.Error("AUTH", "Failed to refresh signing keys: %v", err)
+			}
+		}
+	}()
+}
+
+// GenerateToken signs an access token for the session sessionId.
+func (s *JWTService) GenerateToken(userId, sessionId string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userId,
 		"exp":     time.Now().Add(time.Hour * 1).Unix(), // 1 hour access token
 		"iss":     s.issuer,
 		"type":    "access",
+		"sid":     sessionId,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	return s.sign(claims)
 }
 
-func (s *JWTService) GenerateRefreshToken(userId string) (string, error) {
+// GenerateRefreshToken signs a refresh token whose jti and fid claims point at
+// the server-side record tokenId of login family familyId.
+func (s *JWTService) GenerateRefreshToken(userId, tokenId, familyId string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userId,
 		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days refresh token
 		"iss":     s.issuer,
 		"type":    "refresh",
+		"jti":     tokenId,
+		"fid":     familyId,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	return s.sign(claims)
+}
+
+func (s *JWTService) sign(claims jwt.MapClaims) (string, error) {
+	s.mu.RLock()
+	key := s.signing
+	s.mu.RUnlock()
+
+	// The rotation loop normally replaces a retired key first
+	if !key.meta.SignsAt(time.Now()) {
+		if err := s.Refresh(); err != nil {
+			s.logger.Error("AUTH", "Failed to rotate signing key: %v", err)
+		} else {
+			s.mu.RLock()
+			key = s.signing
+			s.mu.RUnlock()
+		}
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.meta.ID
+	return token.SignedString(key.private)
 }
 
 func (s *JWTService) ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+	return jwt.Parse(tokenString, s.verificationKey,
+		jwt.WithValidMethods([]string{string(domain.SigningRS256), string(domain.SigningEdDSA)}))
+}
+
+// verificationKey finds the public key named by the token's kid, reloading
+// the keys once in case another instance has just generated it.
+func (s *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no key id")
+	}
+
+	key, stale := s.lookup(kid)
+	if key == nil && stale {
+		if err := s.Refresh(); err != nil {
+			s.logger.Error("AUTH", "Failed to reload signing keys: %v", err)
+		}
+		key, _ = s.lookup(kid)
+	}
+	if key == nil || !time.Now().Before(key.meta.ExpiresAt) {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.private.Public(), nil
+}
+
+// lookup returns the key kid, and whether the keys are old enough to reload.
+func (s *JWTService) lookup(kid string) (*jwtKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[kid], time.Since(s.loadedAt) >= unknownKeyReloadInterval
+}
+
+func (s *JWTService) generate(now time.Time) (*jwtKey, error) {
+	var private crypto.Signer
+	var err error
+	switch s.config.Algorithm {
+	case domain.SigningRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	kid := hex.EncodeToString(id)
+	sealed, err := s.seal(der, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := domain.NewSigningKey(kid, s.config.Algorithm, sealed, s.config.Rotation, now)
+	if err := s.store.Save(meta); err != nil {
+		return nil, err
+	}
+	return newJWTKey(meta, private)
+}
+
+func (s *JWTService) open(meta *domain.SigningKey) (*jwtKey, error) {
+	der, err := s.unseal(meta.SealedPrivateKey, meta.ID)
+	if err != nil {
+		return nil, errors.New("cannot decrypt key; was JWT_SECRET changed?")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported key type")
+	}
+	return newJWTKey(meta, private)
+}
+
+func newJWTKey(meta *domain.SigningKey, private crypto.Signer) (*jwtKey, error) {
+	key := &jwtKey{meta: meta, private: private}
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+	}
+	if key.method == nil || key.method.Alg() != string(meta.Algorithm) {
+		return nil, fmt.Errorf("key does not match algorithm %s", meta.Algorithm)
+	}
+	return key, nil
+}
+
+// seal encrypts a private key with AES-GCM, binding it to its kid.
+func (s *JWTService) seal(plaintext []byte, kid string) ([]byte, error) {
+	gcm, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, []byte(kid)), nil
+}
+
+func (s *JWTService) unseal(sealed []byte, kid string) ([]byte, error) {
+	gcm, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed key is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, []byte(kid))
+}
+
+func (s *JWTService) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.sealKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// JWK is the public half of a signing key, as published in the JWKS.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public keys of every key that still verifies tokens,
+// newest first, so other services can check tokens without a shared secret.
+func (s *JWTService) JWKS() JWKSet {
+	s.mu.RLock()
+	keys := make([]*jwtKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].meta.CreatedAt.After(keys[j].meta.CreatedAt) })
+
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		jwk := JWK{KeyID: key.meta.ID, Use: "sig", Algorithm: key.method.Alg()}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
 		}
-		return []byte(s.secretKey), nil
-	})
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
 }
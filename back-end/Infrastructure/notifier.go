@@ -0,0 +1,51 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	domain "shop-ops/Domain"
+)
+
+// LogNotifier is the development Notifier: instead of sending SMS or email it
+// appends each message as a JSON line to an outbox file, or logs it when no
+// file is configured. It must not be used in production, since codes end up
+// in plain text.
+type LogNotifier struct {
+	path   string
+	logger *Logger
+	mu     sync.Mutex
+}
+
+func NewLogNotifier(path string, logger *Logger) *LogNotifier {
+	return &LogNotifier{path: path, logger: logger}
+}
+
+func (n *LogNotifier) Send(message domain.Notification) error {
+	if n.path == "" {
+		n.logger.Info("NOTIFY", "%s to %s: %s", message.Channel, message.To, message.Body)
+		return nil
+	}
+
+	line, err := json.Marshal(struct {
+		domain.Notification
+		SentAt time.Time `json:"sent_at"`
+	}{message, time.Now()})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,193 @@
+package infrastructure
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitStore keeps counters shared by every API instance.
+type RateLimitStore interface {
+	Hit(key string, window time.Duration, expiresAt time.Time, now time.Time) (*domain.RateLimitCounter, error)
+	Find(key string) (*domain.RateLimitCounter, error)
+	Lock(key string, until time.Time, expiresAt time.Time) error
+	ClearFailures(key string) error
+	Release(key string) error
+}
+
+// RateLimiter throttles the auth endpoints per client IP and locks accounts
+// after repeated failed sign-ins. If the store is unavailable requests are
+// let through, so an outage of the limiter does not lock everyone out.
+type RateLimiter struct {
+	store   RateLimitStore
+	ip      domain.RateLimitPolicy
+	lockout domain.LockoutPolicy
+	logger  *Logger
+}
+
+func NewRateLimiter(store RateLimitStore, ip domain.RateLimitPolicy, lockout domain.LockoutPolicy, logger *Logger) *RateLimiter {
+	return &RateLimiter{store: store, ip: ip, lockout: lockout, logger: logger}
+}
+
+// LimitIP allows each client IP the configured number of requests per window
+// across the routes sharing scope.
+func (l *RateLimiter) LimitIP(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		ip := c.ClientIP()
+		counter, err := l.store.Hit("ip:"+scope+":"+ip, l.ip.Window, now.Add(l.ip.Window), now)
+		if err != nil {
+			l.logger.Error("RATELIMIT", "Failed to count request from %s: %v", ip, err)
+			c.Next()
+			return
+		}
+
+		if counter.Count > l.ip.Limit {
+			if counter.Count == l.ip.Limit+1 {
+				l.logger.Warn("RATELIMIT", "IP %s exceeded %d %s requests per %s", ip, l.ip.Limit, scope, l.ip.Window)
+			}
+			tooManyRequests(c, counter.WindowStart.Add(l.ip.Window).Sub(now))
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitAccount allows each phone (or email) named by the body the policy's
+// number of requests per window across the routes sharing scope, whichever
+// IPs they come from. It keeps codes from being sent to one number without
+// end.
+func (l *RateLimiter) LimitAccount(scope string, policy domain.RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		account := bodyAccount(c)
+		if account == "" {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		counter, err := l.store.Hit("send:"+scope+":"+account, policy.Window, now.Add(policy.Window), now)
+		if err != nil {
+			l.logger.Error("RATELIMIT", "Failed to count %s request for %s: %v", scope, maskAccount(account), err)
+			c.Next()
+			return
+		}
+
+		if counter.Count > policy.Limit {
+			if counter.Count == policy.Limit+1 {
+				l.logger.Warn("RATELIMIT", "%s exceeded %d %s requests per %s (last from %s)", maskAccount(account), policy.Limit, scope, policy.Window, c.ClientIP())
+			}
+			tooManyRequests(c, counter.WindowStart.Add(policy.Window).Sub(now))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GuardAccount locks the account named by the body's phone (or email) after
+// too many failed attempts, whichever IPs they come from. Every attempt is
+// counted before the handler runs, so parallel guesses cannot all slip in
+// ahead of the lock. A response with one of failureStatuses keeps the count,
+// a 2xx response clears it (though not the lockouts already served) and any
+// other response gives the attempt back.
+func (l *RateLimiter) GuardAccount(failureStatuses ...int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		account := bodyAccount(c)
+		if account == "" {
+			c.Next()
+			return
+		}
+		key := "account:" + account
+
+		now := time.Now()
+		counter, err := l.store.Hit(key, l.lockout.Window, now.Add(l.lockout.Memory), now)
+		if err != nil {
+			l.logger.Error("RATELIMIT", "Failed to count attempt for %s: %v", maskAccount(account), err)
+			c.Next()
+			return
+		}
+		if wait := counter.LockedAt(now); wait > 0 {
+			tooManyRequests(c, wait)
+			return
+		}
+		if counter.Count > l.lockout.MaxFailures {
+			// Raced the attempt that is about to lock the account
+			tooManyRequests(c, l.lockout.LockDuration(counter.Lockouts))
+			return
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= 200 && status < 300 {
+			if err := l.store.ClearFailures(key); err != nil {
+				l.logger.Error("RATELIMIT", "Failed to clear failures of %s: %v", maskAccount(account), err)
+			}
+			return
+		}
+		for _, failure := range failureStatuses {
+			if status == failure {
+				if counter.Count == l.lockout.MaxFailures {
+					l.lock(key, account, counter, c.ClientIP())
+				}
+				return
+			}
+		}
+		if err := l.store.Release(key); err != nil {
+			l.logger.Error("RATELIMIT", "Failed to give back attempt of %s: %v", maskAccount(account), err)
+		}
+	}
+}
+
+func (l *RateLimiter) lock(key, account string, counter *domain.RateLimitCounter, ip string) {
+	now := time.Now()
+	lock := l.lockout.LockDuration(counter.Lockouts)
+	if err := l.store.Lock(key, now.Add(lock), now.Add(lock+l.lockout.Memory)); err != nil {
+		l.logger.Error("RATELIMIT", "Failed to lock %s: %v", maskAccount(account), err)
+		return
+	}
+	l.logger.Warn("SECURITY", "Locked %s for %s after %d failed attempts (lockout #%d, last from %s)",
+		maskAccount(account), lock, counter.Count, counter.Lockouts+1, ip)
+}
+
+// bodyAccount reads the phone, or else the email, the JSON body names.
+func bodyAccount(c *gin.Context) string {
+	var peek struct {
+		Phone string `json:"phone"`
+		Email string `json:"email"`
+	}
+	_ = peekJSONBody(c, &peek)
+	account := strings.TrimSpace(peek.Phone)
+	if account == "" {
+		account = strings.ToLower(strings.TrimSpace(peek.Email))
+	}
+	return account
+}
+
+// tooManyRequests rejects the request with 429 and a Retry-After header in
+// whole seconds.
+func tooManyRequests(c *gin.Context, wait time.Duration) {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":               "Too many attempts, please try again later",
+		"code":                "RATE_001",
+		"retry_after_seconds": seconds,
+	})
+}
+
+// maskAccount hides most of a phone number or email in logs.
+func maskAccount(account string) string {
+	if len(account) <= 6 {
+		return "***"
+	}
+	return account[:4] + strings.Repeat("*", len(account)-6) + account[len(account)-2:]
+}
@@ -0,0 +1,53 @@
+package infrastructure
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DecompressBody returns a Gin middleware that transparently decodes gzip or
+// zstd request bodies, as announced by the Content-Encoding header. The
+// decoded body is capped at maxBytes so a small compressed payload cannot
+// expand without bound.
+func DecompressBody(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+
+		var body io.ReadCloser
+		switch encoding {
+		case "", "identity":
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+			c.Next()
+			return
+		case "gzip", "x-gzip":
+			reader, err := gzip.NewReader(c.Request.Body)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid gzip body", "details": err.Error(), "code": "VAL_001"})
+				return
+			}
+			body = reader
+		case "zstd":
+			decoder, err := zstd.NewReader(c.Request.Body, zstd.WithDecoderMaxMemory(uint64(maxBytes)))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid zstd body", "details": err.Error(), "code": "VAL_001"})
+				return
+			}
+			body = decoder.IOReadCloser()
+		default:
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported Content-Encoding, use gzip or zstd", "code": "VAL_001"})
+			return
+		}
+		defer body.Close()
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, body, maxBytes)
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+		c.Next()
+	}
+}
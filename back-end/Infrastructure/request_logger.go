@@ -62,11 +62,11 @@ func RequestLogger(logger *Logger) gin.HandlerFunc {
 		// Choose log level based on status code
 		switch {
 		case status >= 500:
-			logger.Error("HTTP", msg)
+			logger.Error("HTTP", "%s", msg)
 		case status >= 400:
-			logger.Warn("HTTP", msg)
+			logger.Warn("HTTP", "%s", msg)
 		default:
-			logger.Info("HTTP", msg)
+			logger.Info("HTTP", "%s", msg)
 		}
 	}
 }
@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type APIKeyRepository interface {
+	Save(key *domain.APIKey) error
+	FindByHash(keyHash string) (*domain.APIKey, error)
+	FindByBusiness(businessId string) ([]*domain.APIKey, error)
+	Touch(id primitive.ObjectID, at time.Time) error
+	Revoke(businessId string, keyId string, at time.Time) error
+}
+
+type apiKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAPIKeyRepository(db *mongo.Database) APIKeyRepository {
+	repo := &apiKeyRepository{
+		collection: db.Collection("api_keys"),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *apiKeyRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+}
+
+func (r *apiKeyRepository) Save(key *domain.APIKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, key)
+	return err
+}
+
+func (r *apiKeyRepository) FindByHash(keyHash string) (*domain.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var key domain.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"key_hash": keyHash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) FindByBusiness(businessId string) ([]*domain.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"business_id": bID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []*domain.APIKey{}
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Touch records that the key was used at the given time, unless it was
+// already recorded within APIKeyTouchInterval.
+func (r *apiKeyRepository) Touch(id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id": id,
+		"$or": bson.A{
+			bson.M{"last_used_at": nil},
+			bson.M{"last_used_at": bson.M{"$lt": at.Add(-domain.APIKeyTouchInterval)}},
+		},
+	}
+	_, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_used_at": at}})
+	return err
+}
+
+// Revoke disables a key for good. Revoking a revoked key is a no-op.
+func (r *apiKeyRepository) Revoke(businessId string, keyId string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return domain.ErrAPIKeyNotFound
+	}
+	kID, err := primitive.ObjectIDFromHex(keyId)
+	if err != nil {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	filter := bson.M{"_id": kID, "business_id": bID}
+	result, err := r.collection.UpdateOne(ctx, filter, []bson.M{
+		{"$set": bson.M{"revoked_at": bson.M{"$ifNull": bson.A{"$revoked_at", at}}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
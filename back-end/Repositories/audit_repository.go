@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository is append-only: entries are never updated or deleted.
+type AuditRepository interface {
+	Append(entry *domain.AuditEntry) error
+	Find(query domain.AuditQuery) ([]domain.AuditEntry, int64, error)
+}
+
+type auditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(db *mongo.Database) AuditRepository {
+	repo := &auditRepository{
+		collection: db.Collection("audit_log"),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *auditRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "entity_type", Value: 1}, {Key: "entity_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+}
+
+func (r *auditRepository) Append(entry *domain.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (r *auditRepository) Find(query domain.AuditQuery) ([]domain.AuditEntry, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	businessID, err := primitive.ObjectIDFromHex(query.BusinessID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	filter := bson.M{"business_id": businessID}
+	if query.ActorID != "" {
+		filter["actor_id"] = query.ActorID
+	}
+	if query.EntityType != "" {
+		filter["entity_type"] = query.EntityType
+	}
+	if query.EntityID != "" {
+		filter["entity_id"] = query.EntityID
+	}
+	if query.Action != "" {
+		filter["action"] = query.Action
+	}
+	if query.Source != "" {
+		filter["source"] = query.Source
+	}
+	if query.DeviceID != "" {
+		filter["device_id"] = query.DeviceID
+	}
+	if query.From != nil || query.To != nil {
+		createdAt := bson.M{}
+		if query.From != nil {
+			createdAt["$gte"] = *query.From
+		}
+		if query.To != nil {
+			createdAt["$lte"] = *query.To
+		}
+		filter["created_at"] = createdAt
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((query.Page - 1) * query.Limit)).
+		SetLimit(int64(query.Limit))
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []domain.AuditEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
@@ -15,8 +15,14 @@ type BusinessRepository interface {
 	Save(business *domain.Business) error
 	FindByID(id string) (*domain.Business, error)
 	FindByUserId(userId string) ([]*domain.Business, error)
+	FindByIDs(ids []primitive.ObjectID) ([]*domain.Business, error)
 	Update(business *domain.Business) error
 	FindByNameAndUserId(name string, userId string) (*domain.Business, error)
+	AdoptLegacyDevice(businessId primitive.ObjectID, device domain.SyncDevice) error
+	AddDevice(businessId primitive.ObjectID, device domain.SyncDevice) error
+	ReactivateDevice(businessId primitive.ObjectID, device domain.SyncDevice) error
+	RevokeDevice(businessId primitive.ObjectID, deviceId string, at time.Time) error
+	UpdateSyncPolicy(businessId primitive.ObjectID, policy domain.SyncConflictPolicy, at time.Time) error
 }
 
 type businessRepository struct {
@@ -79,12 +85,40 @@ func (r *businessRepository) FindByUserId(userId string) ([]*domain.Business, er
 	return businesses, nil
 }
 
+func (r *businessRepository) FindByIDs(ids []primitive.ObjectID) ([]*domain.Business, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var businesses []*domain.Business
+	if err = cursor.All(ctx, &businesses); err != nil {
+		return nil, err
+	}
+	return businesses, nil
+}
+
+// Update saves the business's own settings. The device registry and sync
+// policy are left alone; they have their own updates so that concurrent
+// changes to them are not overwritten.
 func (r *businessRepository) Update(business *domain.Business) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	filter := bson.M{"_id": business.ID}
-	update := bson.M{"$set": business}
+	update := bson.M{"$set": bson.M{
+		"name":           business.Name,
+		"currency":       business.Currency,
+		"language":       business.Language,
+		"timezone":       business.Timezone,
+		"tier":           business.Tier,
+		"costing_method": business.CostingMethod,
+		"updated_at":     business.UpdatedAt,
+	}}
 
 	_, err := r.collection.UpdateOne(ctx, filter, update)
 	return err
@@ -109,3 +143,88 @@ func (r *businessRepository) FindByNameAndUserId(name string, userId string) (*d
 	}
 	return &business, nil
 }
+
+// AdoptLegacyDevice seeds an empty device registry with the business's
+// legacy sync device. It does nothing once the registry has devices.
+func (r *businessRepository) AdoptLegacyDevice(businessId primitive.ObjectID, device domain.SyncDevice) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": businessId, "$or": bson.A{bson.M{"sync_devices": bson.M{"$exists": false}}, bson.M{"sync_devices": bson.M{"$size": 0}}}}
+	update := bson.M{"$set": bson.M{"sync_devices": []domain.SyncDevice{device}, "updated_at": device.RegisteredAt}}
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// AddDevice appends device to the registry. It fails with
+// ErrDeviceRegistered if a device with the same ID is already there.
+func (r *businessRepository) AddDevice(businessId primitive.ObjectID, device domain.SyncDevice) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": businessId, "sync_devices.device_id": bson.M{"$ne": device.DeviceID}}
+	update := bson.M{
+		"$push": bson.M{"sync_devices": device},
+		"$set":  bson.M{"updated_at": device.RegisteredAt},
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrDeviceRegistered
+	}
+	return nil
+}
+
+// ReactivateDevice registers a revoked device again. It fails with
+// ErrDeviceRegistered if the device is active by then.
+func (r *businessRepository) ReactivateDevice(businessId primitive.ObjectID, device domain.SyncDevice) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": businessId, "sync_devices": bson.M{"$elemMatch": bson.M{"device_id": device.DeviceID, "status": domain.DeviceStatusRevoked}}}
+	update := bson.M{
+		"$set": bson.M{
+			"sync_devices.$.name":          device.Name,
+			"sync_devices.$.status":        domain.DeviceStatusActive,
+			"sync_devices.$.registered_by": device.RegisteredBy,
+			"sync_devices.$.registered_at": device.RegisteredAt,
+			"updated_at":                   device.RegisteredAt,
+		},
+		"$unset": bson.M{"sync_devices.$.revoked_at": ""},
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrDeviceRegistered
+	}
+	return nil
+}
+
+// RevokeDevice stops an active device from syncing. Revoking a device that
+// is already revoked does nothing.
+func (r *businessRepository) RevokeDevice(businessId primitive.ObjectID, deviceId string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": businessId, "sync_devices": bson.M{"$elemMatch": bson.M{"device_id": deviceId, "status": domain.DeviceStatusActive}}}
+	update := bson.M{"$set": bson.M{
+		"sync_devices.$.status":     domain.DeviceStatusRevoked,
+		"sync_devices.$.revoked_at": at,
+		"updated_at":                at,
+	}}
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *businessRepository) UpdateSyncPolicy(businessId primitive.ObjectID, policy domain.SyncConflictPolicy, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"sync_conflict_policy": policy, "updated_at": at}}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": businessId}, update)
+	return err
+}
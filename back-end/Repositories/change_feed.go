@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeFeedSettleWindow is how long a change may sit in the outbox before a
+// reader publishes it in place of the writer, which has failed or died.
+const changeFeedSettleWindow = 5 * time.Second
+
+// changeFeed appends mutation records to the "change_log" collection so that
+// devices can pull every server-side change with POST /sync/pull.
+//
+// Outside a transaction a change goes to the "change_outbox" collection
+// first, then gets the next sequence number of its business and is moved to
+// the log. A change whose move fails stays in the outbox, where any instance
+// publishes it later, so a restart cannot lose it. Readers only skip a gap in
+// the sequence once no change of the business is left in the outbox, since
+// until then the gap may be a change still being published.
+type changeFeed struct {
+	entries  *mongo.Collection
+	outbox   *mongo.Collection
+	counters *mongo.Collection
+}
+
+func newChangeFeed(db *mongo.Database) *changeFeed {
+	return &changeFeed{
+		entries:  db.Collection("change_log"),
+		outbox:   db.Collection("change_outbox"),
+		counters: db.Collection("counters"),
+	}
+}
+
+func (f *changeFeed) ensureIndexes(ctx context.Context) {
+	_, _ = f.entries.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "business_id", Value: 1}, {Key: "seq", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	_, _ = f.outbox.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "changed_at", Value: 1}},
+	})
+}
+
+// record appends a change entry. The underlying mutation has already been
+// applied, so a failure is not returned: a change that reached the outbox is
+// published by a later read of the feed, so devices still pull it.
+//
+// Inside a transaction the entry is written directly, since a failed write
+// aborts the transaction and the mutation with it.
+func (f *changeFeed) record(ctx context.Context, businessID primitive.ObjectID, entity domain.ChangeEntity, entityID primitive.ObjectID, op domain.ChangeOperation) {
+	entry := domain.ChangeEntry{
+		BusinessID: businessID,
+		Entity:     entity,
+		EntityID:   entityID,
+		Operation:  op,
+	}
+	if mongo.SessionFromContext(ctx) != nil {
+		if err := f.write(ctx, entry); err != nil {
+			fmt.Printf("WARNING: failed to record change feed entry for %s %s: %v\n", entity, entityID.Hex(), err)
+		}
+		return
+	}
+
+	entry.ID = primitive.NewObjectID()
+	entry.ChangedAt = time.Now().UTC()
+	if _, err := f.outbox.InsertOne(ctx, entry); err != nil {
+		fmt.Printf("WARNING: failed to record change feed entry for %s %s: %v\n", entity, entityID.Hex(), err)
+		return
+	}
+	if err := f.publish(ctx, entry); err != nil {
+		fmt.Printf("WARNING: change feed entry for %s %s is left in the outbox: %v\n", entity, entityID.Hex(), err)
+	}
+}
+
+// publish moves a change from the outbox to the log. The entry keeps the
+// outbox ID, so a change published twice, by its writer and a reader, is
+// only logged once; the sequence number the loser took is left as a gap.
+func (f *changeFeed) publish(ctx context.Context, entry domain.ChangeEntry) error {
+	err := f.write(ctx, entry)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	_, err = f.outbox.DeleteOne(ctx, bson.M{"_id": entry.ID})
+	return err
+}
+
+// backfill publishes the changes of a business left in the outbox for
+// longer than changeFeedSettleWindow. Each gets a new sequence number, since
+// readers may already have skipped the one it took before.
+func (f *changeFeed) backfill(ctx context.Context, businessID primitive.ObjectID) {
+	filter := bson.M{"business_id": businessID, "changed_at": bson.M{"$lt": time.Now().UTC().Add(-changeFeedSettleWindow)}}
+	opts := options.Find().SetSort(bson.D{{Key: "changed_at", Value: 1}}).SetLimit(100)
+	cursor, err := f.outbox.Find(ctx, filter, opts)
+	if err != nil {
+		fmt.Printf("WARNING: failed to read the change feed outbox: %v\n", err)
+		return
+	}
+	var left []domain.ChangeEntry
+	if err := cursor.All(ctx, &left); err != nil {
+		fmt.Printf("WARNING: failed to read the change feed outbox: %v\n", err)
+		return
+	}
+
+	for i, entry := range left {
+		if err := f.publish(ctx, entry); err != nil {
+			fmt.Printf("WARNING: %d change feed entries are still unpublished: %v\n", len(left)-i, err)
+			return
+		}
+	}
+}
+
+// write stores entry under the next sequence number of its business.
+func (f *changeFeed) write(ctx context.Context, entry domain.ChangeEntry) error {
+	seq, err := f.nextSeq(ctx, entry.BusinessID)
+	if err != nil {
+		return fmt.Errorf("failed to allocate sequence: %w", err)
+	}
+	entry.Seq = seq
+	entry.ChangedAt = time.Now().UTC()
+	_, err = f.entries.InsertOne(ctx, entry)
+	return err
+}
+
+func (f *changeFeed) nextSeq(ctx context.Context, businessID primitive.ObjectID) (int64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := f.counters.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": "change_log:" + businessID.Hex()},
+		bson.M{"$inc": bson.M{"seq": int64(1)}},
+		opts,
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// head returns the highest sequence number allocated for a business.
+func (f *changeFeed) head(ctx context.Context, businessID primitive.ObjectID) (int64, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := f.counters.FindOne(ctx, bson.M{"_id": "change_log:" + businessID.Hex()}).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+// since returns up to limit entries after the given sequence number, and the
+// sequence number the reader has read through. While a change of the
+// business is in the outbox it stops at the first gap, so a reader never
+// advances past a change that is still being published.
+//
+// The head is read before the outbox. A change numbered at or below it
+// reached the outbox before it was numbered, so it is either still there or
+// already in the log; entries past the head are left for the next read.
+func (f *changeFeed) since(ctx context.Context, businessID primitive.ObjectID, after int64, limit int) ([]domain.ChangeEntry, int64, bool, error) {
+	f.backfill(ctx, businessID)
+
+	head, err := f.head(ctx, businessID)
+	if err != nil {
+		return nil, after, false, err
+	}
+	unpublished, err := f.outbox.CountDocuments(ctx, bson.M{"business_id": businessID}, options.Count().SetLimit(1))
+	if err != nil {
+		return nil, after, false, err
+	}
+
+	filter := bson.M{"business_id": businessID, "seq": bson.M{"$gt": after, "$lte": head}}
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(int64(limit + 1))
+	cursor, err := f.entries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, after, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []domain.ChangeEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, after, false, err
+	}
+
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+
+	expected := after + 1
+	for i, entry := range entries {
+		if entry.Seq != expected && unpublished > 0 {
+			return entries[:i], expected - 1, true, nil
+		}
+		expected = entry.Seq + 1
+	}
+	switch {
+	case hasMore:
+		return entries, expected - 1, true, nil
+	case unpublished > 0 && expected <= head:
+		// The gap at the end may still be filled
+		return entries, expected - 1, true, nil
+	}
+	return entries, head, false, nil
+}
@@ -44,19 +44,28 @@ type ExpenseRepository interface {
 // MongoExpenseRepository
 type MongoExpenseRepository struct {
 	collection *mongo.Collection
+	changes    *changeFeed
 }
 
 // NewExpenseRepository
 func NewExpenseRepository(db *mongo.Database) ExpenseRepository {
 	return &MongoExpenseRepository{
 		collection: db.Collection("expenses"),
+		changes:    newChangeFeed(db),
 	}
 }
 
 // Create inserts a new expense into the database
 func (r *MongoExpenseRepository) Create(ctx context.Context, expense *domain.Expense) error {
+	if expense.Version == 0 {
+		expense.Version = 1
+	}
 	_, err := r.collection.InsertOne(ctx, expense)
-	return err
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, expense.BusinessID, domain.ChangeEntityExpense, expense.ID, domain.ChangeOperationUpsert)
+	return nil
 }
 
 // GetByID r
@@ -167,6 +176,9 @@ func (r *MongoExpenseRepository) GetByBusinessID(ctx context.Context, filter Exp
 
 // Update replaces an existing expense
 func (r *MongoExpenseRepository) Update(ctx context.Context, expense *domain.Expense) error {
+	now := time.Now()
+	expense.UpdatedAt = &now
+	expense.Version++
 	result, err := r.collection.ReplaceOne(
 		ctx,
 		bson.M{"_id": expense.ID},
@@ -178,6 +190,7 @@ func (r *MongoExpenseRepository) Update(ctx context.Context, expense *domain.Exp
 	if result.MatchedCount == 0 {
 		return domain.ErrExpenseNotFound
 	}
+	r.changes.record(ctx, expense.BusinessID, domain.ChangeEntityExpense, expense.ID, domain.ChangeOperationUpsert)
 	return nil
 }
 
@@ -185,16 +198,23 @@ func (r *MongoExpenseRepository) Update(ctx context.Context, expense *domain.Exp
 func (r *MongoExpenseRepository) Void(ctx context.Context, id primitive.ObjectID) error {
 	update := bson.M{
 		"$set": bson.M{
-			"is_voided": true,
+			"is_voided":  true,
+			"updated_at": time.Now(),
 		},
+		"$inc": bson.M{"version": 1},
 	}
-	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
-	if err != nil {
-		return err
+	var voided struct {
+		BusinessID primitive.ObjectID `bson:"business_id"`
 	}
-	if result.MatchedCount == 0 {
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"business_id": 1})
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&voided)
+	if err == mongo.ErrNoDocuments {
 		return domain.ErrExpenseNotFound
 	}
+	if err != nil {
+		return err
+	}
+	r.changes.record(ctx, voided.BusinessID, domain.ChangeEntityExpense, id, domain.ChangeOperationVoid)
 	return nil
 }
 
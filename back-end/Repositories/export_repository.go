@@ -90,6 +90,23 @@ func (r *ExportRepository) GetByID(id string, businessID string) (*Domain.Export
 	return &request, nil
 }
 
+// GetByFileURL retrieves the export request whose file is at fileURL
+func (r *ExportRepository) GetByFileURL(fileURL string) (*Domain.ExportRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var request Domain.ExportRequest
+	err := r.collection.FindOne(ctx, bson.M{"file_url": fileURL}).Decode(&request)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find export request: %w", err)
+	}
+
+	return &request, nil
+}
+
 // GetByBusiness retrieves paginated export requests for a business
 func (r *ExportRepository) GetByBusiness(businessID string, limit, offset int) ([]Domain.ExportRequest, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -3,9 +3,12 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	Domain "shop-ops/Domain"
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -13,15 +16,71 @@ import (
 )
 
 type InventoryRepository struct {
+	db                  *mongo.Database
 	productsCollection  *mongo.Collection
 	movementsCollection *mongo.Collection
+	changes             *changeFeed
+	ledger              *stockLedger
+	topology            *topology
 }
 
 func NewInventoryRepository(db *mongo.Database) Domain.ProductRepository {
-	return &InventoryRepository{
+	repo := &InventoryRepository{
+		db:                  db,
 		productsCollection:  db.Collection("products"),
 		movementsCollection: db.Collection("stock_movements"),
+		changes:             newChangeFeed(db),
+		ledger:              newStockLedger(db),
+		topology:            newTopology(db),
 	}
+	repo.ensureIndexes()
+	return repo
+}
+
+// ensureIndexes keeps SKUs and barcodes unique within a business. Products
+// without one are left out of the index, so any number of them may exist.
+func (r *InventoryRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.productsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "sku", Value: 1}},
+			Options: options.Index().
+				SetName(skuIndex).
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"sku": bson.M{"$type": "string"}}),
+		},
+		{
+			Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "barcode", Value: 1}},
+			Options: options.Index().
+				SetName(barcodeIndex).
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"barcode": bson.M{"$type": "string"}}),
+		},
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "category", Value: 1}}},
+		{Keys: bson.D{{Key: "parent_id", Value: 1}}},
+	})
+}
+
+const (
+	skuIndex     = "business_sku"
+	barcodeIndex = "business_barcode"
+)
+
+// catalogConflict turns a duplicate key error on the SKU or barcode index
+// into the matching domain error.
+func catalogConflict(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	switch {
+	case strings.Contains(err.Error(), barcodeIndex):
+		return Domain.ErrDuplicateBarcode
+	case strings.Contains(err.Error(), skuIndex):
+		return Domain.ErrDuplicateSKU
+	}
+	return nil
 }
 
 func (r *InventoryRepository) Create(product *Domain.Product) error {
@@ -30,34 +89,41 @@ func (r *InventoryRepository) Create(product *Domain.Product) error {
 
 	product.CreatedAt = time.Now()
 	product.UpdatedAt = time.Now()
-
-	result, err := r.productsCollection.InsertOne(ctx, product)
-	if err != nil {
-		return fmt.Errorf("failed to create product: %w", err)
+	product.Version = 1
+	if product.ID.IsZero() {
+		product.ID = primitive.NewObjectID()
 	}
 
-	product.ID = result.InsertedID.(primitive.ObjectID)
-
-	// Create initial stock movement if stock > 0
-	if product.StockQuantity > 0 {
-		movement := Domain.StockMovement{
-			BusinessID:  product.BusinessID,
-			ProductID:   product.ID,
-			Type:        Domain.MovementTypePurchase,
-			Quantity:    product.StockQuantity,
-			Reason:      "Initial stock",
-			CreatedBy:   product.BusinessID, // Using BusinessID as fallback
-			CreatedAt:   time.Now(),
+	create := func(ctx context.Context) error {
+		_, err := r.productsCollection.InsertOne(ctx, product)
+		if conflict := catalogConflict(err); conflict != nil {
+			return conflict
 		}
-
-		_, err = r.movementsCollection.InsertOne(ctx, movement)
 		if err != nil {
-			// Log error but don't fail product creation
-			fmt.Printf("Failed to create stock movement: %v\n", err)
+			return fmt.Errorf("failed to create product: %w", err)
 		}
+
+		// Create the initial stock movement, using BusinessID as the creator
+		// since no user is known here. A product whose stock has no movement
+		// is not kept.
+		if _, err := r.ledger.openingStock(ctx, *product, product.BusinessID, nil); err != nil {
+			if mongo.SessionFromContext(ctx) == nil {
+				if _, undoErr := r.productsCollection.DeleteOne(ctx, bson.M{"_id": product.ID}); undoErr != nil {
+					fmt.Printf("WARNING: product %s was created without its initial stock movement: %v\n", product.ID.Hex(), undoErr)
+				}
+			}
+			return err
+		}
+		r.changes.record(ctx, product.BusinessID, Domain.ChangeEntityProduct, product.ID, Domain.ChangeOperationUpsert)
+		return nil
 	}
 
-	return nil
+	// Insert the product and its opening stock together where the server
+	// allows it; elsewhere the product is removed again if the stock fails
+	if r.topology.supportsTransactions() {
+		return withTransaction(ctx, r.db, create)
+	}
+	return create(ctx)
 }
 
 func (r *InventoryRepository) FindByID(id string) (*Domain.Product, error) {
@@ -93,12 +159,38 @@ func (r *InventoryRepository) FindByBusinessID(businessID string, query Domain.P
 	// Build filter
 	filter := bson.M{"business_id": objBusinessID}
 
-	// Search by name
+	// Search by name, SKU or barcode
 	if query.Search != "" {
-		filter["name"] = bson.M{
+		search := bson.M{
 			"$regex":   query.Search,
 			"$options": "i",
 		}
+		filter["$or"] = bson.A{
+			bson.M{"name": search},
+			bson.M{"sku": search},
+			bson.M{"barcode": search},
+		}
+	}
+
+	// Exact lookups, such as a scanned barcode
+	if query.Barcode != "" {
+		filter["barcode"] = Domain.NormalizeBarcode(query.Barcode)
+	}
+	if query.SKU != "" {
+		filter["sku"] = Domain.NormalizeSKU(query.SKU)
+	}
+	if query.Category != "" {
+		filter["category"] = bson.M{
+			"$regex":   "^" + regexp.QuoteMeta(Domain.NormalizeCategory(query.Category)) + "$",
+			"$options": "i",
+		}
+	}
+	if query.ParentID != "" {
+		objParentID, err := primitive.ObjectIDFromHex(query.ParentID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid parent ID: %w", err)
+		}
+		filter["parent_id"] = objParentID
 	}
 
 	// Low stock filter
@@ -160,20 +252,51 @@ func (r *InventoryRepository) Update(product *Domain.Product) error {
 
 	product.UpdatedAt = time.Now()
 
+	set := bson.M{
+		"name":                  product.Name,
+		"default_selling_price": product.DefaultSellingPrice,
+		"low_stock_threshold":   product.LowStockThreshold,
+		"updated_at":            product.UpdatedAt,
+	}
+	// Cleared catalog fields are removed rather than stored empty, so an
+	// empty SKU or barcode never takes a place in the unique indexes
+	unset := bson.M{}
+	catalog := map[string]string{
+		"sku":      product.SKU,
+		"barcode":  product.Barcode,
+		"category": product.Category,
+		"unit":     string(product.UnitOfMeasure),
+	}
+	for field, value := range catalog {
+		if value == "" {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+	if len(product.Attributes) > 0 {
+		set["attributes"] = product.Attributes
+	} else {
+		unset["attributes"] = ""
+	}
+
 	update := bson.M{
-		"$set": bson.M{
-			"name":                   product.Name,
-			"default_selling_price":  product.DefaultSellingPrice,
-			"low_stock_threshold":    product.LowStockThreshold,
-			"updated_at":             product.UpdatedAt,
-		},
+		"$set": set,
+		"$inc": bson.M{"version": 1},
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
 	}
 
 	_, err := r.productsCollection.UpdateByID(ctx, product.ID, update)
+	if conflict := catalogConflict(err); conflict != nil {
+		return conflict
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update product: %w", err)
 	}
 
+	r.changes.record(ctx, product.BusinessID, Domain.ChangeEntityProduct, product.ID, Domain.ChangeOperationUpsert)
 	return nil
 }
 
@@ -186,93 +309,86 @@ func (r *InventoryRepository) Delete(id string) error {
 		return fmt.Errorf("invalid product ID: %w", err)
 	}
 
-	// Hard delete for products
-	_, err = r.productsCollection.DeleteOne(ctx, bson.M{"_id": objID})
-	return err
-}
-
-func (r *InventoryRepository) AdjustStock(productID string, quantity int, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	objProductID, err := primitive.ObjectIDFromHex(productID)
-	if err != nil {
-		return fmt.Errorf("invalid product ID: %w", err)
+	// Hard delete for products; the change feed keeps a tombstone for devices
+	var deleted struct {
+		BusinessID primitive.ObjectID `bson:"business_id"`
 	}
-
-	objUserID, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		return fmt.Errorf("invalid user ID: %w", err)
+	err = r.productsCollection.FindOneAndDelete(ctx, bson.M{"_id": objID}).Decode(&deleted)
+	if err == mongo.ErrNoDocuments {
+		return nil
 	}
-
-	// Get current product
-	var product Domain.Product
-	err = r.productsCollection.FindOne(ctx, bson.M{"_id": objProductID}).Decode(&product)
 	if err != nil {
-		return fmt.Errorf("failed to find product: %w", err)
+		return err
 	}
 
-	// Calculate new stock based on movement type
-	var newStock int
-	var quantityChange int
+	r.changes.record(ctx, deleted.BusinessID, Domain.ChangeEntityProduct, objID, Domain.ChangeOperationDelete)
+	return nil
+}
+
+func (r *InventoryRepository) AdjustStock(productID string, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
+	_, err := r.applyMovement(productID, quantity, movementType, reason, referenceID, userID, nil)
+	return err
+}
 
+// ReceiveStock adds stock at unitCost, blending it into the product's
+// average cost and keeping it as a cost layer for FIFO.
+func (r *InventoryRepository) ReceiveStock(productID string, quantity, unitCost decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
 	switch movementType {
 	case Domain.MovementTypePurchase, Domain.MovementTypeReturn:
-		// Purchase and Return increase stock
-		newStock = product.StockQuantity + quantity
-		quantityChange = quantity // Positive
-	case Domain.MovementTypeSale, Domain.MovementTypeDamage, Domain.MovementTypeTheft:
-		// Sale, Damage, Theft decrease stock
-		newStock = product.StockQuantity - quantity
-		if newStock < 0 {
-			return fmt.Errorf("insufficient stock. Available: %d, Required: %d", product.StockQuantity, quantity)
-		}
-		quantityChange = -quantity // Negative
-	case Domain.MovementTypeAdjust:
-		// Adjust can set to any value - quantity becomes the new stock
-		newStock = quantity
-		quantityChange = quantity - product.StockQuantity // Calculate the change (could be positive or negative)
 	default:
-		return fmt.Errorf("invalid movement type: %s", movementType)
+		return fmt.Errorf("cannot receive stock as %s", movementType)
 	}
+	_, err := r.applyMovement(productID, quantity, movementType, reason, referenceID, userID, &unitCost)
+	return err
+}
 
-	// Update product stock
-	update := bson.M{
-		"$set": bson.M{
-			"stock_quantity": newStock,
-			"updated_at":     time.Now(),
-		},
+// TakeStock removes stock and returns the cost of the goods taken, as
+// recorded on the movement.
+func (r *InventoryRepository) TakeStock(productID string, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) (decimal.Decimal, error) {
+	movement, err := r.applyMovement(productID, quantity, movementType, reason, referenceID, userID, nil)
+	if err != nil {
+		return decimal.Zero, err
 	}
+	return movement.Cost, nil
+}
+
+func (r *InventoryRepository) applyMovement(productID string, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string, unitCost *decimal.Decimal) (Domain.StockMovement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	_, err = r.productsCollection.UpdateByID(ctx, objProductID, update)
+	var movement Domain.StockMovement
+	objProductID, err := primitive.ObjectIDFromHex(productID)
 	if err != nil {
-		return fmt.Errorf("failed to update product stock: %w", err)
+		return movement, fmt.Errorf("invalid product ID: %w", err)
 	}
 
-	// Create stock movement record
-	movement := Domain.StockMovement{
-		BusinessID:  product.BusinessID,
-		ProductID:   objProductID,
-		Type:        movementType,
-		Quantity:    quantityChange, // Positive for increase, negative for decrease
-		Reason:      reason,
-		CreatedBy:   objUserID,
-		CreatedAt:   time.Now(),
+	objUserID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return movement, fmt.Errorf("invalid user ID: %w", err)
 	}
 
+	var objReferenceID *primitive.ObjectID
 	if referenceID != nil {
-		objReferenceID, err := primitive.ObjectIDFromHex(*referenceID)
+		id, err := primitive.ObjectIDFromHex(*referenceID)
 		if err == nil {
-			movement.ReferenceID = &objReferenceID
+			objReferenceID = &id
 		}
 	}
 
-	_, err = r.movementsCollection.InsertOne(ctx, movement)
-	if err != nil {
-		return fmt.Errorf("failed to create stock movement: %w", err)
+	adjust := func(ctx context.Context) error {
+		var err error
+		movement, err = r.ledger.adjust(ctx, objProductID, quantity, movementType, reason, objReferenceID, objUserID, unitCost, nil)
+		return err
 	}
 
-	return nil
+	// Commit the stock change and its movement together where the server
+	// allows it; elsewhere the ledger undoes the change if the movement fails
+	if r.topology.supportsTransactions() {
+		err = withTransaction(ctx, r.db, adjust)
+	} else {
+		err = adjust(ctx)
+	}
+	return movement, err
 }
 
 func (r *InventoryRepository) GetLowStock(businessID string) ([]Domain.Product, error) {
@@ -306,6 +422,68 @@ func (r *InventoryRepository) GetLowStock(businessID string) ([]Domain.Product,
 	return products, nil
 }
 
+// FindVariants returns the variants of a product, by name
+func (r *InventoryRepository) FindVariants(parentID string) ([]Domain.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objParentID, err := primitive.ObjectIDFromHex(parentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid product ID: %w", err)
+	}
+
+	opts := options.Find().SetSort(bson.M{"name": 1})
+	cursor, err := r.productsCollection.Find(ctx, bson.M{"parent_id": objParentID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find variants: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var variants []Domain.Product
+	if err := cursor.All(ctx, &variants); err != nil {
+		return nil, fmt.Errorf("failed to decode variants: %w", err)
+	}
+
+	return variants, nil
+}
+
+// GetCategories returns the categories a business's products are in, with
+// how many products each holds
+func (r *InventoryRepository) GetCategories(businessID string) ([]Domain.ProductCategory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objBusinessID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid business ID: %w", err)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"business_id": objBusinessID,
+			"category":    bson.M{"$type": "string", "$ne": ""},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$category",
+			"products": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	cursor, err := r.productsCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate categories: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	categories := []Domain.ProductCategory{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, fmt.Errorf("failed to decode categories: %w", err)
+	}
+
+	return categories, nil
+}
+
 func (r *InventoryRepository) GetStockHistory(productID string, limit int) ([]Domain.StockMovement, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -318,6 +496,7 @@ func (r *InventoryRepository) GetStockHistory(productID string, limit int) ([]Do
 	if limit <= 0 {
 		limit = 50
 	}
+	r.ledger.recoverPending(ctx, objProductID)
 
 	opts := options.Find().
 		SetSort(bson.M{"created_at": -1}).
@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type InvitationRepository interface {
+	Save(invitation *domain.Invitation) error
+	FindByID(id primitive.ObjectID) (*domain.Invitation, error)
+	FindByCodeHash(codeHash string) (*domain.Invitation, error)
+	FindByBusiness(businessId string) ([]*domain.Invitation, error)
+	MarkAccepted(id primitive.ObjectID, userId primitive.ObjectID, at time.Time) error
+	Revoke(businessId string, invitationId string, at time.Time) error
+}
+
+type invitationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewInvitationRepository(db *mongo.Database) InvitationRepository {
+	repo := &invitationRepository{
+		collection: db.Collection("business_invitations"),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *invitationRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "code_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+}
+
+func (r *invitationRepository) Save(invitation *domain.Invitation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, invitation)
+	return err
+}
+
+func (r *invitationRepository) FindByID(id primitive.ObjectID) (*domain.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var invitation domain.Invitation
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) FindByCodeHash(codeHash string) (*domain.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var invitation domain.Invitation
+	err := r.collection.FindOne(ctx, bson.M{"code_hash": codeHash}).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) FindByBusiness(businessId string) ([]*domain.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"business_id": bID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invitations := []*domain.Invitation{}
+	if err = cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// MarkAccepted claims a pending, unexpired invitation. It fails with
+// ErrInvitationUsed if another request got there first.
+func (r *invitationRepository) MarkAccepted(id primitive.ObjectID, userId primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "status": domain.InvitationPending, "expires_at": bson.M{"$gt": at}}
+	update := bson.M{"$set": bson.M{"status": domain.InvitationAccepted, "accepted_by": userId, "accepted_at": at}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrInvitationUsed
+	}
+	return nil
+}
+
+// Revoke cancels a pending invitation. Invitations that were already
+// accepted or revoked report ErrInvitationUsed.
+func (r *invitationRepository) Revoke(businessId string, invitationId string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return domain.ErrInvitationNotFound
+	}
+	iID, err := primitive.ObjectIDFromHex(invitationId)
+	if err != nil {
+		return domain.ErrInvitationNotFound
+	}
+
+	filter := bson.M{"_id": iID, "business_id": bID}
+	pending := bson.M{"_id": iID, "business_id": bID, "status": domain.InvitationPending}
+	update := bson.M{"$set": bson.M{"status": domain.InvitationRevoked, "revoked_at": at}}
+	result, err := r.collection.UpdateOne(ctx, pending, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrInvitationNotFound
+	}
+	return domain.ErrInvitationUsed
+}
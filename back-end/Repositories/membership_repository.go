@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MembershipRepository interface {
+	Save(membership *domain.Membership) error
+	FindByBusinessAndUser(businessId string, userId string) (*domain.Membership, error)
+	FindByBusiness(businessId string) ([]*domain.Membership, error)
+	FindByUser(userId string) ([]*domain.Membership, error)
+	UpdateRole(businessId string, userId string, role domain.BusinessRole) error
+	Delete(businessId string, userId string) error
+}
+
+type membershipRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMembershipRepository(db *mongo.Database) MembershipRepository {
+	repo := &membershipRepository{
+		collection: db.Collection("business_members"),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *membershipRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "business_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+}
+
+func (r *membershipRepository) Save(membership *domain.Membership) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, membership)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrAlreadyMember
+	}
+	return err
+}
+
+func (r *membershipRepository) FindByBusinessAndUser(businessId string, userId string) (*domain.Membership, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter, err := membershipFilter(businessId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var membership domain.Membership
+	err = r.collection.FindOne(ctx, filter).Decode(&membership)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (r *membershipRepository) FindByBusiness(businessId string) ([]*domain.Membership, error) {
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, err
+	}
+	return r.find(bson.M{"business_id": bID})
+}
+
+func (r *membershipRepository) FindByUser(userId string) ([]*domain.Membership, error) {
+	uID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+	return r.find(bson.M{"user_id": uID})
+}
+
+func (r *membershipRepository) find(filter bson.M) ([]*domain.Membership, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	memberships := []*domain.Membership{}
+	if err = cursor.All(ctx, &memberships); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+func (r *membershipRepository) UpdateRole(businessId string, userId string, role domain.BusinessRole) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter, err := membershipFilter(businessId, userId)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrMemberNotFound
+	}
+	return nil
+}
+
+func (r *membershipRepository) Delete(businessId string, userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter, err := membershipFilter(businessId, userId)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrMemberNotFound
+	}
+	return nil
+}
+
+func membershipFilter(businessId string, userId string) (bson.M, error) {
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, err
+	}
+	uID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"business_id": bID, "user_id": uID}, nil
+}
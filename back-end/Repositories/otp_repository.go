@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OTPRepository interface {
+	Replace(code *domain.OneTimeCode) error
+	FindLatest(userId primitive.ObjectID, purpose domain.OTPPurpose) (*domain.OneTimeCode, error)
+	RecordAttempt(id primitive.ObjectID, at time.Time) error
+	Consume(id primitive.ObjectID, at time.Time) error
+}
+
+type otpRepository struct {
+	collection *mongo.Collection
+}
+
+func NewOTPRepository(db *mongo.Database) OTPRepository {
+	repo := &otpRepository{
+		collection: db.Collection("one_time_codes"),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *otpRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// At most one live code per user and purpose
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+}
+
+// Replace stores code, discarding any earlier code of the same user and
+// purpose so only the newest one works.
+func (r *otpRepository) Replace(code *domain.OneTimeCode) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": code.UserID, "purpose": code.Purpose}
+	_, err := r.collection.ReplaceOne(ctx, filter, code, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *otpRepository) FindLatest(userId primitive.ObjectID, purpose domain.OTPPurpose) (*domain.OneTimeCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var code domain.OneTimeCode
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userId, "purpose": purpose}).Decode(&code)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+// RecordAttempt counts one guess against the code before it is checked, so
+// parallel guesses cannot exceed the limit. It reports
+// ErrOTPAttemptsExceeded once the code is used up.
+func (r *otpRepository) RecordAttempt(id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":         id,
+		"consumed_at": nil,
+		"expires_at":  bson.M{"$gt": at},
+		"attempts":    bson.M{"$lt": domain.OTPMaxAttempts},
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"attempts": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrOTPAttemptsExceeded
+	}
+	return nil
+}
+
+// Consume marks the code used. A code consumed concurrently reports
+// ErrInvalidOTP.
+func (r *otpRepository) Consume(id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "consumed_at": nil}, bson.M{"$set": bson.M{"consumed_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrInvalidOTP
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PurchasingRepository keeps suppliers and the purchase orders placed with
+// them.
+type PurchasingRepository interface {
+	CreateSupplier(supplier *domain.Supplier) error
+	FindSupplier(businessId string, supplierId string) (*domain.Supplier, error)
+	ListSuppliers(businessId string) ([]*domain.Supplier, error)
+	UpdateSupplier(supplier *domain.Supplier) error
+	CreateOrder(order *domain.PurchaseOrder) error
+	FindOrder(businessId string, orderId string) (*domain.PurchaseOrder, error)
+	ListOrders(businessId string, query domain.PurchaseOrderQuery) ([]*domain.PurchaseOrder, error)
+	SaveOrder(order *domain.PurchaseOrder) error
+	ReceiveOrder(order *domain.PurchaseOrder, expense *domain.Expense) error
+	SupplierSpend(businessId string, start, end time.Time) ([]domain.SupplierSpend, error)
+}
+
+type purchasingRepository struct {
+	db        *mongo.Database
+	suppliers *mongo.Collection
+	orders    *mongo.Collection
+	expenses  *mongo.Collection
+	changes   *changeFeed
+	ledger    *stockLedger
+	topology  *topology
+}
+
+func NewPurchasingRepository(db *mongo.Database) PurchasingRepository {
+	repo := &purchasingRepository{
+		db:        db,
+		suppliers: db.Collection("suppliers"),
+		orders:    db.Collection("purchase_orders"),
+		expenses:  db.Collection("expenses"),
+		changes:   newChangeFeed(db),
+		ledger:    newStockLedger(db),
+		topology:  newTopology(db),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *purchasingRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.suppliers.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "name", Value: 1}},
+	})
+	_, _ = r.orders.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "supplier_id", Value: 1}}},
+	})
+	_, _ = r.expenses.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "supplier_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+}
+
+func (r *purchasingRepository) CreateSupplier(supplier *domain.Supplier) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.suppliers.InsertOne(ctx, supplier)
+	return err
+}
+
+func (r *purchasingRepository) FindSupplier(businessId string, supplierId string) (*domain.Supplier, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter, ok := businessScoped(businessId, supplierId)
+	if !ok {
+		return nil, nil
+	}
+
+	var supplier domain.Supplier
+	err := r.suppliers.FindOne(ctx, filter).Decode(&supplier)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+func (r *purchasingRepository) ListSuppliers(businessId string) ([]*domain.Supplier, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.suppliers.Find(ctx, bson.M{"business_id": bID}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	suppliers := []*domain.Supplier{}
+	if err = cursor.All(ctx, &suppliers); err != nil {
+		return nil, err
+	}
+	return suppliers, nil
+}
+
+func (r *purchasingRepository) UpdateSupplier(supplier *domain.Supplier) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := r.suppliers.ReplaceOne(ctx, bson.M{"_id": supplier.ID, "business_id": supplier.BusinessID}, supplier)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrSupplierNotFound
+	}
+	return nil
+}
+
+func (r *purchasingRepository) CreateOrder(order *domain.PurchaseOrder) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.orders.InsertOne(ctx, order)
+	return err
+}
+
+func (r *purchasingRepository) FindOrder(businessId string, orderId string) (*domain.PurchaseOrder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter, ok := businessScoped(businessId, orderId)
+	if !ok {
+		return nil, nil
+	}
+
+	var order domain.PurchaseOrder
+	err := r.orders.FindOne(ctx, filter).Decode(&order)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *purchasingRepository) ListOrders(businessId string, query domain.PurchaseOrderQuery) ([]*domain.PurchaseOrder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"business_id": bID}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	if query.SupplierID != "" {
+		supplierID, err := primitive.ObjectIDFromHex(query.SupplierID)
+		if err != nil {
+			return []*domain.PurchaseOrder{}, nil
+		}
+		filter["supplier_id"] = supplierID
+	}
+
+	cursor, err := r.orders.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	orders := []*domain.PurchaseOrder{}
+	if err = cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// SaveOrder writes back an order read at order.Version. It fails with
+// ErrPurchaseOrderConflict if the order changed since.
+func (r *purchasingRepository) SaveOrder(order *domain.PurchaseOrder) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return r.saveOrder(ctx, order)
+}
+
+func (r *purchasingRepository) saveOrder(ctx context.Context, order *domain.PurchaseOrder) error {
+	read := order.Version
+	order.Version++
+	result, err := r.orders.ReplaceOne(ctx, bson.M{"_id": order.ID, "version": read}, order)
+	if err != nil {
+		order.Version = read
+		return err
+	}
+	if result.MatchedCount == 0 {
+		order.Version = read
+		return domain.ErrPurchaseOrderConflict
+	}
+	return nil
+}
+
+// ReceiveOrder books the order's latest receipt: each line comes into stock
+// as a purchase movement at its unit cost, the receipt's amount is recorded
+// as expense, and the order is saved with the receipt. Where the server
+// allows it all of this commits together; elsewhere the stock and the
+// expense are taken back if a later step fails.
+func (r *purchasingRepository) ReceiveOrder(order *domain.PurchaseOrder, expense *domain.Expense) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if len(order.Receipts) == 0 {
+		return domain.ErrNothingToReceive
+	}
+	receipt := &order.Receipts[len(order.Receipts)-1]
+	if expense.Version == 0 {
+		expense.Version = 1
+	}
+
+	version := order.Version
+	receive := func(ctx context.Context) error {
+		// A retried transaction starts over from the order as it was read
+		order.Version = version
+		var moved []domain.StockMovement
+		undo := func() {
+			if mongo.SessionFromContext(ctx) != nil {
+				return
+			}
+			for _, movement := range moved {
+				if err := r.ledger.revert(ctx, movement); err != nil {
+					fmt.Printf("WARNING: failed to take back stock movement %s of purchase order %s: %v\n", movement.ID.Hex(), order.ID.Hex(), err)
+				}
+			}
+		}
+
+		for i := range receipt.Lines {
+			line := &receipt.Lines[i]
+			movement, err := r.ledger.adjust(ctx, line.ProductID, line.Quantity, domain.MovementTypePurchase, "Purchase order received", &order.ID, receipt.ReceivedBy, &line.UnitCost, nil)
+			if err != nil {
+				undo()
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+			line.MovementID = movement.ID
+			moved = append(moved, movement)
+		}
+
+		if _, err := r.expenses.InsertOne(ctx, expense); err != nil {
+			undo()
+			return fmt.Errorf("failed to record purchase expense: %w", err)
+		}
+
+		if err := r.saveOrder(ctx, order); err != nil {
+			undo()
+			if mongo.SessionFromContext(ctx) == nil {
+				if _, delErr := r.expenses.DeleteOne(ctx, bson.M{"_id": expense.ID}); delErr != nil {
+					fmt.Printf("WARNING: failed to remove purchase expense %s: %v\n", expense.ID.Hex(), delErr)
+				}
+			}
+			return err
+		}
+
+		r.changes.record(ctx, expense.BusinessID, domain.ChangeEntityExpense, expense.ID, domain.ChangeOperationUpsert)
+		return nil
+	}
+
+	if r.topology.supportsTransactions() {
+		return withTransaction(ctx, r.db, receive)
+	}
+	return receive(ctx)
+}
+
+// SupplierSpend totals the stock purchase expenses of each supplier between
+// start and end, largest first. Voided expenses are left out.
+func (r *purchasingRepository) SupplierSpend(businessId string, start, end time.Time) ([]domain.SupplierSpend, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"business_id": bID,
+			"supplier_id": bson.M{"$exists": true, "$ne": nil},
+			"is_voided":   false,
+			"created_at":  bson.M{"$gte": start, "$lte": end},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":              "$supplier_id",
+			"amount":           bson.M{"$sum": "$amount"},
+			"receipts":         bson.M{"$sum": 1},
+			"last_received_at": bson.M{"$max": "$created_at"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "amount", Value: -1}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "suppliers",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "supplier",
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"supplier_name": bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$supplier.name", 0}}, ""}},
+		}}},
+		{{Key: "$project", Value: bson.M{"supplier": 0}}},
+	}
+
+	cursor, err := r.expenses.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	spend := []domain.SupplierSpend{}
+	if err = cursor.All(ctx, &spend); err != nil {
+		return nil, err
+	}
+	return spend, nil
+}
+
+// businessScoped filters for the record id of the business. It reports
+// false if either id is malformed, which can match nothing.
+func businessScoped(businessId string, id string) (bson.M, bool) {
+	bID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, false
+	}
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, false
+	}
+	return bson.M{"_id": objID, "business_id": bID}, true
+}
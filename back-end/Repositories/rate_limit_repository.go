@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitRepository keeps rate limit counters in Mongo so every API
+// instance sees the same counts.
+type RateLimitRepository interface {
+	Hit(key string, window time.Duration, expiresAt time.Time, now time.Time) (*domain.RateLimitCounter, error)
+	Find(key string) (*domain.RateLimitCounter, error)
+	Lock(key string, until time.Time, expiresAt time.Time) error
+	ClearFailures(key string) error
+	Release(key string) error
+}
+
+type rateLimitRepository struct {
+	collection *mongo.Collection
+}
+
+func NewRateLimitRepository(db *mongo.Database) RateLimitRepository {
+	repo := &rateLimitRepository{
+		collection: db.Collection("rate_limits"),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *rateLimitRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+}
+
+// Hit counts one hit against key in a fixed window and returns the updated
+// counter. A hit after the window has passed starts a new window, and hits
+// while the key is locked are not counted. The update is a single atomic
+// pipeline, so concurrent hits are all counted.
+func (r *rateLimitRepository) Hit(key string, window time.Duration, expiresAt time.Time, now time.Time) (*domain.RateLimitCounter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	inWindow := bson.D{{Key: "$gt", Value: bson.A{"$window_start", now.Add(-window)}}}
+	locked := bson.D{{Key: "$gt", Value: bson.A{"$locked_until", now}}}
+	counted := bson.D{{Key: "$cond", Value: bson.A{inWindow, bson.D{{Key: "$add", Value: bson.A{"$count", 1}}}, 1}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "count", Value: bson.D{{Key: "$cond", Value: bson.A{locked, "$count", counted}}}},
+		{Key: "window_start", Value: bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$or", Value: bson.A{locked, inWindow}}}, "$window_start", now}}}},
+		{Key: "lockouts", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$lockouts", 0}}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$max", Value: bson.A{"$expires_at", expiresAt}}}},
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter domain.RateLimitCounter
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&counter); err != nil {
+		return nil, err
+	}
+	return &counter, nil
+}
+
+func (r *rateLimitRepository) Find(key string) (*domain.RateLimitCounter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var counter domain.RateLimitCounter
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&counter)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &counter, nil
+}
+
+// Lock locks key until the given time, counts the lockout and clears the
+// failure count.
+func (r *rateLimitRepository) Lock(key string, until time.Time, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{"locked_until": until, "count": 0, "expires_at": expiresAt},
+		"$inc": bson.M{"lockouts": 1},
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// ClearFailures zeroes the count of key but keeps its lockout history, so the
+// next lockout is still longer than the last.
+func (r *rateLimitRepository) ClearFailures(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"count": 0}})
+	return err
+}
+
+// Release takes back one hit counted against key.
+func (r *rateLimitRepository) Release(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key, "count": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"count": -1}})
+	return err
+}
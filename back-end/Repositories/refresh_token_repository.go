@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RefreshTokenRepository interface {
+	Save(token *domain.RefreshToken) error
+	FindByID(id string) (*domain.RefreshToken, error)
+	MarkUsed(id primitive.ObjectID, replacedBy primitive.ObjectID, at time.Time) error
+	RevokeFamily(familyId primitive.ObjectID, at time.Time) error
+	RevokeAllForUser(userId string, at time.Time) error
+}
+
+type refreshTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewRefreshTokenRepository(db *mongo.Database) RefreshTokenRepository {
+	repo := &refreshTokenRepository{
+		collection: db.Collection("refresh_tokens"),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *refreshTokenRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "revoked_at", Value: 1}}},
+		{
+			// Expired tokens can no longer be used or replayed
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+}
+
+func (r *refreshTokenRepository) Save(token *domain.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func (r *refreshTokenRepository) FindByID(id string) (*domain.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var token domain.RefreshToken
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes a token exactly once. A token that was already used or
+// revoked reports ErrRefreshTokenReused, so two concurrent refreshes with the
+// same token cannot both succeed.
+func (r *refreshTokenRepository) MarkUsed(id primitive.ObjectID, replacedBy primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "used_at": nil, "revoked_at": nil}
+	update := bson.M{"$set": bson.M{"used_at": at, "replaced_by": replacedBy}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrRefreshTokenReused
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyId primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"family_id": familyId, "revoked_at": nil}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at}})
+	return err
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(userId string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	uID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"user_id": uID, "revoked_at": nil}
+	_, err = r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at}})
+	return err
+}
@@ -44,13 +44,15 @@ func (r *ReportRepository) GetSalesReportData(businessID primitive.ObjectID, dat
 		"is_voided": bson.M{"$ne": true},
 	}
 
-	// Aggregate total sales and orders
+	// Aggregate total sales, orders and items sold
 	totalPipeline := mongo.Pipeline{
 		{{Key: "$match", Value: matchStage}},
+		{{Key: "$set", Value: bson.M{"lines": saleLinesExpr}}},
 		{{Key: "$group", Value: bson.M{
 			"_id":          nil,
 			"total_sales":  bson.M{"$sum": "$total"},
 			"total_orders": bson.M{"$sum": 1},
+			"total_items":  bson.M{"$sum": bson.M{"$sum": "$lines.quantity"}},
 		}}},
 	}
 
@@ -61,8 +63,9 @@ func (r *ReportRepository) GetSalesReportData(businessID primitive.ObjectID, dat
 	defer cursor.Close(ctx)
 
 	var totalResult struct {
-		TotalSales  float64 `bson:"total_sales"`
-		TotalOrders int     `bson:"total_orders"`
+		TotalSales  decimal.Decimal `bson:"total_sales"`
+		TotalOrders int             `bson:"total_orders"`
+		TotalItems  int             `bson:"total_items"`
 	}
 	if cursor.Next(ctx) {
 		if err := cursor.Decode(&totalResult); err != nil {
@@ -70,17 +73,33 @@ func (r *ReportRepository) GetSalesReportData(businessID primitive.ObjectID, dat
 		}
 	}
 
-	// Aggregate top products
+	// Aggregate top products across the lines of every basket
 	topProductsPipeline := mongo.Pipeline{
 		{{Key: "$match", Value: matchStage}},
+		{{Key: "$set", Value: bson.M{"lines": saleLinesExpr}}},
+		{{Key: "$unwind", Value: "$lines"}},
 		{{Key: "$group", Value: bson.M{
-			"_id":          "$product_id",
-			"product_name": bson.M{"$first": "$product_name"}, // Assuming product_name is stored
-			"total_sales":  bson.M{"$sum": "$total"},
-			"quantity":     bson.M{"$sum": "$quantity"},
+			"_id":         "$lines.product_id",
+			"total_sales":   bson.M{"$sum": "$lines.total"},
+			"cost_of_goods": bson.M{"$sum": "$lines.cost"},
+			"quantity":      bson.M{"$sum": "$lines.quantity"},
+			"sale_ids":      bson.M{"$addToSet": "$_id"},
 		}}},
 		{{Key: "$sort", Value: bson.M{"total_sales": -1}}},
 		{{Key: "$limit", Value: 10}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "products",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "product",
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"product_name": bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$product.name", 0}}, ""}},
+			"total_sales":   1,
+			"cost_of_goods": 1,
+			"quantity":      1,
+			"orders":        bson.M{"$size": "$sale_ids"},
+		}}},
 	}
 
 	cursor, err = r.salesCollection.Aggregate(ctx, topProductsPipeline)
@@ -94,8 +113,10 @@ func (r *ReportRepository) GetSalesReportData(businessID primitive.ObjectID, dat
 		var result struct {
 			ProductID   *primitive.ObjectID `bson:"_id"`
 			ProductName string              `bson:"product_name"`
-			TotalSales  float64             `bson:"total_sales"`
-			Quantity    int                 `bson:"quantity"`
+			TotalSales  decimal.Decimal     `bson:"total_sales"`
+			CostOfGoods decimal.Decimal     `bson:"cost_of_goods"`
+			Quantity    decimal.Decimal     `bson:"quantity"`
+			Orders      int                 `bson:"orders"`
 		}
 		if err := cursor.Decode(&result); err != nil {
 			return nil, fmt.Errorf("failed to decode top product: %w", err)
@@ -103,8 +124,12 @@ func (r *ReportRepository) GetSalesReportData(businessID primitive.ObjectID, dat
 		topProducts = append(topProducts, Domain.TopProduct{
 			ProductID:   result.ProductID,
 			ProductName: result.ProductName,
-			TotalSales:  decimal.NewFromFloat(result.TotalSales),
+			TotalSales:  result.TotalSales,
+			CostOfGoods: result.CostOfGoods,
+			GrossProfit: result.TotalSales.Sub(result.CostOfGoods),
+			GrossMargin: Domain.GrossMargin(result.TotalSales, result.CostOfGoods),
 			Quantity:    result.Quantity,
+			Orders:      result.Orders,
 		})
 	}
 
@@ -120,24 +145,25 @@ func (r *ReportRepository) GetSalesReportData(businessID primitive.ObjectID, dat
 
 		for cursor.Next(ctx) {
 			var result struct {
-				Period     string  `bson:"_id"`
-				TotalSales float64 `bson:"total_sales"`
-				Orders     int     `bson:"orders"`
+				Period     string          `bson:"_id"`
+				TotalSales decimal.Decimal `bson:"total_sales"`
+				Orders     int             `bson:"orders"`
 			}
 			if err := cursor.Decode(&result); err != nil {
 				return nil, fmt.Errorf("failed to decode grouped sales: %w", err)
 			}
 			groupedData = append(groupedData, Domain.SalesGroup{
 				Period:     result.Period,
-				TotalSales: decimal.NewFromFloat(result.TotalSales),
+				TotalSales: result.TotalSales,
 				Orders:     result.Orders,
 			})
 		}
 	}
 
 	return &Domain.SalesReportData{
-		TotalSales:  decimal.NewFromFloat(totalResult.TotalSales),
+		TotalSales:  totalResult.TotalSales,
 		TotalOrders: totalResult.TotalOrders,
+		TotalItems:  totalResult.TotalItems,
 		TopProducts: topProducts,
 		GroupedData: groupedData,
 	}, nil
@@ -269,26 +295,34 @@ func (r *ReportRepository) GetProfitReportData(businessID primitive.ObjectID, da
 	salesPipeline := mongo.Pipeline{
 		{{Key: "$match", Value: salesMatch}},
 		{{Key: "$group", Value: bson.M{
-			"_id":         nil,
-			"total_sales": bson.M{"$sum": "$total"},
+			"_id":           nil,
+			"total_sales":   bson.M{"$sum": "$total"},
+			"cost_of_goods": bson.M{"$sum": bson.M{"$sum": "$lines.cost"}},
 		}}},
 	}
 	cursor, err := r.salesCollection.Aggregate(ctx, salesPipeline)
 	if err != nil {
 		return nil, fmt.Errorf("failed to aggregate sales for profit: %w", err)
 	}
-	var salesTotal decimal.Decimal
+	var salesTotal, costOfGoods decimal.Decimal
 	if cursor.Next(ctx) {
 		var result struct {
-			TotalSales float64 `bson:"total_sales"`
+			TotalSales  decimal.Decimal `bson:"total_sales"`
+			CostOfGoods decimal.Decimal `bson:"cost_of_goods"`
 		}
 		if err := cursor.Decode(&result); err != nil {
 			return nil, fmt.Errorf("failed to decode sales total: %w", err)
 		}
-		salesTotal = decimal.NewFromFloat(result.TotalSales)
+		salesTotal = result.TotalSales
+		costOfGoods = result.CostOfGoods
 	}
 	cursor.Close(ctx)
 
+	products, err := aggregateProductMargins(ctx, r.salesCollection, salesMatch)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get expenses total
 	expensesMatch := bson.M{
 		"business_id": businessID,
@@ -327,15 +361,18 @@ func (r *ReportRepository) GetProfitReportData(businessID primitive.ObjectID, da
 		salesGroupPipeline := r.buildGroupedPipeline(salesMatch, string(groupBy), "sales")
 		sCursor, sErr := r.salesCollection.Aggregate(ctx, salesGroupPipeline)
 		var salesMap = make(map[string]decimal.Decimal)
+		var costMap = make(map[string]decimal.Decimal)
 		if sErr == nil {
 			defer sCursor.Close(ctx)
 			for sCursor.Next(ctx) {
 				var res struct {
-					Period string          `bson:"_id"`
-					Sales  decimal.Decimal `bson:"total_sales"`
+					Period      string          `bson:"_id"`
+					Sales       decimal.Decimal `bson:"total_sales"`
+					CostOfGoods decimal.Decimal `bson:"cost_of_goods"`
 				}
 				if sCursor.Decode(&res) == nil {
 					salesMap[res.Period] = res.Sales
+					costMap[res.Period] = res.CostOfGoods
 				}
 			}
 		}
@@ -362,12 +399,16 @@ func (r *ReportRepository) GetProfitReportData(businessID primitive.ObjectID, da
 
 		for p := range periods {
 			s := salesMap[p]
+			c := costMap[p]
 			e := expMap[p]
 			groupedData = append(groupedData, Domain.ProfitGroup{
-				Period:   p,
-				Sales:    s,
-				Expenses: e,
-				Profit:   s.Sub(e),
+				Period:      p,
+				Sales:       s,
+				CostOfGoods: c,
+				GrossProfit: s.Sub(c),
+				GrossMargin: Domain.GrossMargin(s, c),
+				Expenses:    e,
+				Profit:      s.Sub(e),
 			})
 		}
 		sort.Slice(groupedData, func(i, j int) bool {
@@ -377,7 +418,9 @@ func (r *ReportRepository) GetProfitReportData(businessID primitive.ObjectID, da
 
 	return &Domain.ProfitReportData{
 		TotalSales:    salesTotal,
+		CostOfGoods:   costOfGoods,
 		TotalExpenses: expensesTotal,
+		Products:      products,
 		GroupedData:   groupedData,
 	}, nil
 }
@@ -412,8 +455,8 @@ func (r *ReportRepository) GetInventoryReportData(businessID primitive.ObjectID)
 		var result struct {
 			ID                primitive.ObjectID `bson:"_id"`
 			Name              string             `bson:"name"`
-			StockQuantity     int                `bson:"stock_quantity"`
-			LowStockThreshold int                `bson:"low_stock_threshold"`
+			StockQuantity     decimal.Decimal    `bson:"stock_quantity"`
+			LowStockThreshold decimal.Decimal    `bson:"low_stock_threshold"`
 			IsLowStock        bool               `bson:"is_low_stock"`
 		}
 		if err := cursor.Decode(&result); err != nil {
@@ -427,7 +470,7 @@ func (r *ReportRepository) GetInventoryReportData(businessID primitive.ObjectID)
 			LowStockThreshold: result.LowStockThreshold,
 			IsLowStock:        result.IsLowStock,
 		}
-		if result.StockQuantity == 0 {
+		if result.StockQuantity.IsZero() {
 			outOfStock = append(outOfStock, item)
 		} else if result.IsLowStock {
 			lowStock = append(lowStock, item)
@@ -468,13 +511,18 @@ func (r *ReportRepository) buildGroupedPipeline(matchStage bson.M, groupBy, coll
 		countFieldName = "count"
 	}
 
+	group := bson.M{
+		"_id":           groupID,
+		amountFieldName: bson.M{"$sum": sumField},
+		countFieldName:  bson.M{"$sum": 1},
+	}
+	if collectionType == "sales" {
+		group["cost_of_goods"] = bson.M{"$sum": bson.M{"$sum": "$lines.cost"}}
+	}
+
 	return mongo.Pipeline{
 		{{Key: "$match", Value: matchStage}},
-		{{Key: "$group", Value: bson.M{
-			"_id":           groupID,
-			amountFieldName: bson.M{"$sum": sumField},
-			countFieldName:  bson.M{"$sum": 1},
-		}}},
+		{{Key: "$group", Value: group}},
 		{{Key: "$sort", Value: bson.M{"_id": 1}}},
 	}
 }
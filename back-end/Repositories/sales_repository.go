@@ -7,6 +7,7 @@ import (
 
 	Domain "shop-ops/Domain"
 
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,12 +17,14 @@ import (
 // SalesRepository handles data access for sales transactions
 type SalesRepository struct {
 	collection *mongo.Collection
+	changes    *changeFeed
 }
 
 // NewSalesRepository creates a new SalesRepository backed by the "sales" collection
 func NewSalesRepository(db *mongo.Database) Domain.SaleRepository {
 	return &SalesRepository{
 		collection: db.Collection("sales"),
+		changes:    newChangeFeed(db),
 	}
 }
 
@@ -30,12 +33,17 @@ func (r *SalesRepository) Create(sale *Domain.Sale) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if sale.Version == 0 {
+		sale.Version = 1
+	}
+
 	result, err := r.collection.InsertOne(ctx, sale)
 	if err != nil {
 		return fmt.Errorf("failed to create sale: %w", err)
 	}
 
 	sale.ID = result.InsertedID.(primitive.ObjectID)
+	r.changes.record(ctx, sale.BusinessID, Domain.ChangeEntitySale, sale.ID, Domain.ChangeOperationUpsert)
 	return nil
 }
 
@@ -58,6 +66,7 @@ func (r *SalesRepository) FindByID(id string) (*Domain.Sale, error) {
 		return nil, fmt.Errorf("failed to find sale: %w", err)
 	}
 
+	sale.NormalizeLines()
 	return &sale, nil
 }
 
@@ -96,10 +105,10 @@ func (r *SalesRepository) FindByBusinessID(businessID string, query Domain.SaleL
 		}
 	}
 
-	// Optional: filter by product
+	// Optional: filter by product on any line
 	if query.ProductID != "" {
 		if objProductID, err := primitive.ObjectIDFromHex(query.ProductID); err == nil {
-			filter["product_id"] = objProductID
+			filter["$or"] = saleProductFilter(objProductID)
 		}
 	}
 
@@ -158,6 +167,7 @@ func (r *SalesRepository) FindByBusinessID(businessID string, query Domain.SaleL
 	if err := cursor.All(ctx, &sales); err != nil {
 		return nil, 0, fmt.Errorf("failed to decode sales: %w", err)
 	}
+	normalizeSaleLines(sales)
 
 	return sales, total, nil
 }
@@ -174,18 +184,18 @@ func (r *SalesRepository) UpdateNote(id string, note string) error {
 
 	update := bson.M{
 		"$set": bson.M{
-			"note": note,
+			"note":       note,
+			"updated_at": time.Now(),
 		},
+		"$inc": bson.M{"version": 1},
 	}
 
-	result, err := r.collection.UpdateByID(ctx, objID, update)
+	businessID, err := r.updateSale(ctx, objID, update)
 	if err != nil {
 		return fmt.Errorf("failed to update sale: %w", err)
 	}
-	if result.MatchedCount == 0 {
-		return fmt.Errorf("sale not found")
-	}
 
+	r.changes.record(ctx, businessID, Domain.ChangeEntitySale, objID, Domain.ChangeOperationUpsert)
 	return nil
 }
 
@@ -201,21 +211,38 @@ func (r *SalesRepository) VoidSale(id string) error {
 
 	update := bson.M{
 		"$set": bson.M{
-			"is_voided": true,
+			"is_voided":  true,
+			"updated_at": time.Now(),
 		},
+		"$inc": bson.M{"version": 1},
 	}
 
-	result, err := r.collection.UpdateByID(ctx, objID, update)
+	businessID, err := r.updateSale(ctx, objID, update)
 	if err != nil {
 		return fmt.Errorf("failed to void sale: %w", err)
 	}
-	if result.MatchedCount == 0 {
-		return fmt.Errorf("sale not found")
-	}
 
+	r.changes.record(ctx, businessID, Domain.ChangeEntitySale, objID, Domain.ChangeOperationVoid)
 	return nil
 }
 
+// updateSale applies an update and returns the owning business ID so the
+// change can be recorded in the change feed
+func (r *SalesRepository) updateSale(ctx context.Context, id primitive.ObjectID, update bson.M) (primitive.ObjectID, error) {
+	var updated struct {
+		BusinessID primitive.ObjectID `bson:"business_id"`
+	}
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"business_id": 1})
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, fmt.Errorf("sale not found")
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return updated.BusinessID, nil
+}
+
 // GetSummary returns aggregated sales totals for the given period
 func (r *SalesRepository) GetSummary(businessID string, startDate, endDate time.Time) (*Domain.SaleSummaryResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -240,26 +267,47 @@ func (r *SalesRepository) GetSummary(businessID string, startDate, endDate time.
 	activeCount, _ := r.collection.CountDocuments(ctx, activeFilter)
 	voidedCount, _ := r.collection.CountDocuments(ctx, voidedFilter)
 
-	// Sum totals from the active sales
-	activeCursor, err := r.collection.Find(ctx, activeFilter)
-	totalRevenue := 0.0
+	// Sum totals and cost of goods from the active sales
+	activeCursor, err := r.collection.Find(ctx, activeFilter, options.Find().SetProjection(bson.M{"total": 1, "lines.cost": 1}))
+	totalRevenue := decimal.Zero
+	costOfGoods := decimal.Zero
 	if err == nil {
 		defer activeCursor.Close(ctx)
 		var sales []Domain.Sale
 		if err := activeCursor.All(ctx, &sales); err == nil {
 			for _, s := range sales {
-				totalRevenue += s.Total
+				totalRevenue = totalRevenue.Add(s.Total)
+				costOfGoods = costOfGoods.Add(s.CostOfGoods())
 			}
 		}
 	}
 
 	return &Domain.SaleSummaryResponse{
 		TotalSales:   int(activeCount),
-		TotalRevenue: totalRevenue,
+		TotalRevenue: totalRevenue.InexactFloat64(),
+		CostOfGoods:  costOfGoods.InexactFloat64(),
 		VoidedCount:  int(voidedCount),
 	}, nil
 }
 
+// GetProductMargins returns the gross margin of each product sold in the
+// given period, best selling first
+func (r *SalesRepository) GetProductMargins(businessID string, startDate, endDate time.Time) ([]Domain.ProductMargin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objBusinessID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid business ID: %w", err)
+	}
+
+	return aggregateProductMargins(ctx, r.collection, bson.M{
+		"business_id": objBusinessID,
+		"is_voided":   bson.M{"$ne": true},
+		"created_at":  bson.M{"$gte": startDate, "$lte": endDate},
+	})
+}
+
 // FindAllByBusinessID returns all non-voided sales for a business (for full restore)
 func (r *SalesRepository) FindAllByBusinessID(businessID string) ([]Domain.Sale, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -285,6 +333,7 @@ func (r *SalesRepository) FindAllByBusinessID(businessID string) ([]Domain.Sale,
 	if err := cursor.All(ctx, &sales); err != nil {
 		return nil, fmt.Errorf("failed to decode sales: %w", err)
 	}
+	normalizeSaleLines(sales)
 
 	return sales, nil
 }
@@ -315,6 +364,81 @@ func (r *SalesRepository) FindSince(businessID string, since time.Time) ([]Domai
 	if err := cursor.All(ctx, &sales); err != nil {
 		return nil, fmt.Errorf("failed to decode sales: %w", err)
 	}
+	normalizeSaleLines(sales)
 
 	return sales, nil
 }
+
+// saleProductFilter matches sales with a line for the product, including
+// sales recorded before baskets that keep the product on the sale itself
+func saleProductFilter(productID primitive.ObjectID) bson.A {
+	return bson.A{
+		bson.M{"lines.product_id": productID},
+		bson.M{"product_id": productID},
+	}
+}
+
+// saleLinesExpr is an aggregation expression for the lines of a sale. A sale
+// recorded before baskets is read as a single line.
+var saleLinesExpr = bson.M{"$ifNull": bson.A{"$lines", bson.A{bson.M{
+	"product_id": "$product_id",
+	"quantity":   "$quantity",
+	"unit_price": "$unit_price",
+	"total":      "$total",
+}}}}
+
+// aggregateProductMargins sums the lines of the sales matching match by
+// product, best selling first. Sales recorded before cost tracking count
+// with no cost of goods.
+func aggregateProductMargins(ctx context.Context, sales *mongo.Collection, match bson.M) ([]Domain.ProductMargin, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$set", Value: bson.M{"lines": saleLinesExpr}}},
+		{{Key: "$unwind", Value: "$lines"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$lines.product_id",
+			"sales":         bson.M{"$sum": "$lines.total"},
+			"cost_of_goods": bson.M{"$sum": "$lines.cost"},
+			"quantity":      bson.M{"$sum": "$lines.quantity"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "sales", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "products",
+			"localField":   "_id",
+			"foreignField": "_id",
+			"as":           "product",
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"product_name": bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$product.name", 0}}, ""}},
+		}}},
+	}
+
+	cursor, err := sales.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate product margins: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var margins []Domain.ProductMargin
+	for cursor.Next(ctx) {
+		var result struct {
+			ProductID   *primitive.ObjectID `bson:"_id"`
+			ProductName string              `bson:"product_name"`
+			Sales       decimal.Decimal     `bson:"sales"`
+			CostOfGoods decimal.Decimal     `bson:"cost_of_goods"`
+			Quantity    decimal.Decimal     `bson:"quantity"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, fmt.Errorf("failed to decode product margin: %w", err)
+		}
+		margins = append(margins, Domain.NewProductMargin(result.ProductID, result.ProductName, result.Quantity, result.Sales, result.CostOfGoods))
+	}
+	return margins, nil
+}
+
+// normalizeSaleLines gives sales recorded before baskets their single line
+func normalizeSaleLines(sales []Domain.Sale) {
+	for i := range sales {
+		sales[i].NormalizeLines()
+	}
+}
@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SessionRepository interface {
+	Save(session *domain.Session) error
+	FindByID(id string) (*domain.Session, error)
+	FindActiveByUser(userId string, now time.Time) ([]*domain.Session, error)
+	Rotate(id primitive.ObjectID, client domain.SessionClient, expiresAt time.Time, at time.Time) error
+	Touch(id primitive.ObjectID, at time.Time) error
+	Revoke(userId string, sessionId string, at time.Time) error
+	RevokeAllForUser(userId string, at time.Time) (int64, error)
+}
+
+type sessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSessionRepository(db *mongo.Database) SessionRepository {
+	repo := &sessionRepository{
+		collection: db.Collection("sessions"),
+	}
+	repo.ensureIndexes()
+	return repo
+}
+
+func (r *sessionRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_used_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+}
+
+func (r *sessionRepository) Save(session *domain.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+func (r *sessionRepository) FindByID(id string) (*domain.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var session domain.Session
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindActiveByUser lists the user's unrevoked, unexpired sessions, most
+// recently used first.
+func (r *sessionRepository) FindActiveByUser(userId string, now time.Time) ([]*domain.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	uID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"user_id": uID, "revoked_at": nil, "expires_at": bson.M{"$gt": now}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []*domain.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Rotate records that the session's refresh token was rotated from client.
+func (r *sessionRepository) Rotate(id primitive.ObjectID, client domain.SessionClient, expiresAt time.Time, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"last_used_at": at, "expires_at": expiresAt}
+	if client.IP != "" {
+		set["ip"] = client.IP
+	}
+	if client.UserAgent != "" {
+		set["user_agent"] = client.UserAgent
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "revoked_at": nil}, bson.M{"$set": set})
+	return err
+}
+
+// Touch records that an access token of the session was used at the given
+// time, unless it was already recorded within SessionTouchInterval.
+func (r *sessionRepository) Touch(id primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":          id,
+		"revoked_at":   nil,
+		"last_used_at": bson.M{"$lt": at.Add(-domain.SessionTouchInterval)},
+	}
+	_, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_used_at": at}})
+	return err
+}
+
+// Revoke ends one of the user's sessions. Sessions of other users, and ones
+// already ended, report ErrSessionNotFound.
+func (r *sessionRepository) Revoke(userId string, sessionId string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	uID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return domain.ErrSessionNotFound
+	}
+	sID, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return domain.ErrSessionNotFound
+	}
+
+	filter := bson.M{"_id": sID, "user_id": uID, "revoked_at": nil}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeAllForUser(userId string, at time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	uID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return 0, err
+	}
+
+	filter := bson.M{"user_id": uID, "revoked_at": nil, "expires_at": bson.M{"$gt": at}}
+	result, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,562 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	Domain "shop-ops/Domain"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// stockLedger applies stock movements to products. The inventory and sync
+// repositories share it so both follow the same stock rules, and it runs on
+// the caller's context so a sync batch can use it inside a session transaction.
+//
+// The ledger also keeps the cost of stock. Stock received at a known cost is
+// blended into the product's average unit cost and kept as a cost layer;
+// stock taken out is costed by the business's costing method and records its
+// cost of goods on the movement.
+type stockLedger struct {
+	products   *mongo.Collection
+	movements  *mongo.Collection
+	journal    *mongo.Collection
+	layers     *mongo.Collection
+	businesses *mongo.Collection
+	changes    *changeFeed
+}
+
+// pendingMovementGrace is how long a journaled movement may take to be
+// recorded before it is taken for abandoned.
+const pendingMovementGrace = time.Minute
+
+func newStockLedger(db *mongo.Database) *stockLedger {
+	l := &stockLedger{
+		products:   db.Collection("products"),
+		movements:  db.Collection("stock_movements"),
+		journal:    db.Collection("stock_movement_journal"),
+		layers:     db.Collection("cost_layers"),
+		businesses: db.Collection("businesses"),
+		changes:    newChangeFeed(db),
+	}
+	l.ensureIndexes()
+	return l
+}
+
+func (l *stockLedger) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, _ = l.layers.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "remaining", Value: 1}, {Key: "received_at", Value: 1}}},
+		{Keys: bson.D{{Key: "movement_id", Value: 1}}},
+	})
+	_, _ = l.journal.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+}
+
+// adjust moves stock and records the movement. Fields in extra are stored on
+// the movement document, overriding the defaults. unitCost, if known, is the
+// cost of each unit a purchase or return brings in. It returns the movement
+// as recorded.
+//
+// The stock changes in one conditional write, so concurrent adjustments never
+// overwrite each other and a decrease cannot take stock below zero. Inside the
+// caller's transaction the movement commits with the stock change. Outside
+// one, the movement is journaled first and the stock change marks the product
+// with it, so a change whose movement was never recorded can be finished
+// later by recoverPending.
+func (l *stockLedger) adjust(ctx context.Context, productID primitive.ObjectID, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *primitive.ObjectID, userID primitive.ObjectID, unitCost *decimal.Decimal, extra bson.M) (Domain.StockMovement, error) {
+	pending := pendingMovement{
+		ID:          primitive.NewObjectID(),
+		ProductID:   productID,
+		Type:        movementType,
+		Quantity:    quantity,
+		Reason:      reason,
+		ReferenceID: referenceID,
+		CreatedBy:   userID,
+		UnitCost:    unitCost,
+		Extra:       extra,
+		CreatedAt:   time.Now(),
+	}
+	if mongo.SessionFromContext(ctx) != nil {
+		return l.apply(ctx, pending, false)
+	}
+
+	l.recoverPending(ctx, productID)
+	if _, err := l.journal.InsertOne(ctx, pending); err != nil {
+		return Domain.StockMovement{}, fmt.Errorf("failed to journal stock movement: %w", err)
+	}
+	recorded, err := l.apply(ctx, pending, true)
+	if err != nil {
+		return Domain.StockMovement{}, err
+	}
+	l.settle(ctx, pending.ID, productID)
+	return recorded, nil
+}
+
+// pendingMovement is a stock movement as asked for, before the stock moves.
+// Outside a transaction it is kept in the journal until its movement is
+// recorded.
+type pendingMovement struct {
+	ID          primitive.ObjectID  `bson:"_id"`
+	ProductID   primitive.ObjectID  `bson:"product_id"`
+	Type        Domain.MovementType `bson:"type"`
+	Quantity    decimal.Decimal     `bson:"quantity"`
+	Reason      string              `bson:"reason"`
+	ReferenceID *primitive.ObjectID `bson:"reference_id,omitempty"`
+	CreatedBy   primitive.ObjectID  `bson:"created_by"`
+	UnitCost    *decimal.Decimal    `bson:"unit_cost,omitempty"`
+	Extra       bson.M              `bson:"extra,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at"`
+}
+
+// stockMarker is left on a product by a journaled stock change until its
+// movement is recorded. It keeps the stock from before the change.
+type stockMarker struct {
+	MovementID  primitive.ObjectID `bson:"movement_id"`
+	StockBefore decimal.Decimal    `bson:"stock_before"`
+}
+
+// change is how much the stock moved, given the stock before: positive for
+// an increase, negative for a decrease.
+func (p pendingMovement) change(stockBefore decimal.Decimal) decimal.Decimal {
+	switch p.Type {
+	case Domain.MovementTypeSale, Domain.MovementTypeDamage, Domain.MovementTypeTheft:
+		return p.Quantity.Neg()
+	case Domain.MovementTypeAdjust:
+		return p.Quantity.Sub(stockBefore)
+	}
+	return p.Quantity
+}
+
+// stockUpdate is the conditional write that moves the product's stock. A
+// marked update also leaves a stockMarker for the movement on the product.
+func (p pendingMovement) stockUpdate(marked bool) (bson.M, mongo.Pipeline, error) {
+	filter := bson.M{"_id": p.ProductID}
+	set := bson.M{
+		"version":    bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		"updated_at": p.CreatedAt,
+	}
+
+	switch p.Type {
+	case Domain.MovementTypePurchase, Domain.MovementTypeReturn:
+		// Purchase and Return increase stock
+		set["stock_quantity"] = bson.M{"$add": bson.A{"$stock_quantity", p.Quantity}}
+		if p.UnitCost != nil {
+			set["unit_cost"] = receivedUnitCost(p.Quantity, *p.UnitCost)
+		}
+	case Domain.MovementTypeSale, Domain.MovementTypeDamage, Domain.MovementTypeTheft:
+		// Sale, Damage, Theft decrease stock, but only if enough is left
+		filter["stock_quantity"] = bson.M{"$gte": p.Quantity}
+		set["stock_quantity"] = bson.M{"$add": bson.A{"$stock_quantity", p.Quantity.Neg()}}
+	case Domain.MovementTypeAdjust:
+		// Adjust can set to any value - quantity becomes the new stock
+		set["stock_quantity"] = p.Quantity
+	default:
+		return nil, nil, fmt.Errorf("invalid movement type: %s", p.Type)
+	}
+
+	if marked {
+		// Every field of one $set stage reads the product as it was, so the
+		// marker keeps the stock from before this change
+		marker := bson.M{"movement_id": p.ID, "stock_before": "$stock_quantity"}
+		set["pending_movements"] = bson.M{"$concatArrays": bson.A{
+			bson.M{"$ifNull": bson.A{"$pending_movements", bson.A{}}},
+			bson.A{marker},
+		}}
+	}
+	return filter, mongo.Pipeline{{{Key: "$set", Value: set}}}, nil
+}
+
+// apply moves the stock for a pending movement and records the movement. A
+// journaled movement whose record cannot be written has its stock change
+// undone, or, failing that, is left in the journal to be recovered.
+func (l *stockLedger) apply(ctx context.Context, pending pendingMovement, journaled bool) (Domain.StockMovement, error) {
+	filter, update, err := pending.stockUpdate(journaled)
+	if err != nil {
+		return Domain.StockMovement{}, err
+	}
+
+	// The product as it was before the write tells how much really changed
+	var before Domain.Product
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err = l.products.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		if journaled {
+			l.dropJournaled(ctx, pending.ID)
+		}
+		return Domain.StockMovement{}, l.explainMiss(ctx, pending.ProductID, pending.Quantity)
+	}
+	if err != nil {
+		// A journaled change that went through after all is recovered later
+		return Domain.StockMovement{}, fmt.Errorf("failed to update product stock: %w", err)
+	}
+
+	l.changes.record(ctx, before.BusinessID, Domain.ChangeEntityProduct, pending.ProductID, Domain.ChangeOperationUpsert)
+
+	recorded, movement := l.describe(ctx, pending, before, true)
+	if recorded.Quantity.IsPositive() && pending.UnitCost != nil {
+		l.addLayer(ctx, recorded)
+	}
+
+	if _, err := l.movements.InsertOne(ctx, movement); err != nil {
+		if journaled {
+			l.undo(ctx, recorded)
+		}
+		return Domain.StockMovement{}, fmt.Errorf("failed to create stock movement: %w", err)
+	}
+
+	l.changes.record(ctx, before.BusinessID, Domain.ChangeEntityStockMovement, recorded.ID, Domain.ChangeOperationUpsert)
+	return recorded, nil
+}
+
+// describe builds the movement a pending movement made, given the product as
+// it was before the stock moved, and the document to store for it. Stock
+// taken out is costed from the cost layers it takes, when takeLayers is set,
+// or else at the product's average cost.
+func (l *stockLedger) describe(ctx context.Context, pending pendingMovement, before Domain.Product, takeLayers bool) (Domain.StockMovement, bson.M) {
+	quantityChange := pending.change(before.StockQuantity)
+	recorded := Domain.StockMovement{
+		ID:          pending.ID,
+		BusinessID:  before.BusinessID,
+		ProductID:   pending.ProductID,
+		Type:        pending.Type,
+		Quantity:    quantityChange,
+		Reason:      pending.Reason,
+		ReferenceID: pending.ReferenceID,
+		CreatedBy:   pending.CreatedBy,
+		CreatedAt:   pending.CreatedAt,
+	}
+	switch {
+	case quantityChange.IsNegative():
+		taken := quantityChange.Neg()
+		if takeLayers {
+			recorded.Layers = l.consumeLayers(ctx, pending.ProductID, taken)
+		}
+		recorded.Cost = Domain.IssueCost(l.costingMethod(ctx, before.BusinessID), taken, before.UnitCost, recorded.Layers)
+		recorded.UnitCost = recorded.Cost.Div(taken).Round(4)
+	case quantityChange.IsPositive() && pending.UnitCost != nil:
+		recorded.UnitCost = *pending.UnitCost
+		recorded.Cost = pending.UnitCost.Mul(quantityChange).Round(2)
+	}
+
+	// Create stock movement record
+	movement := bson.M{
+		"_id":         recorded.ID,
+		"business_id": recorded.BusinessID,
+		"product_id":  recorded.ProductID,
+		"type":        recorded.Type,
+		"quantity":    recorded.Quantity,
+		"reason":      recorded.Reason,
+		"created_by":  recorded.CreatedBy,
+		"created_at":  recorded.CreatedAt,
+	}
+	if recorded.ReferenceID != nil {
+		movement["reference_id"] = *recorded.ReferenceID
+	}
+	if !recorded.UnitCost.IsZero() || !recorded.Cost.IsZero() {
+		movement["unit_cost"] = recorded.UnitCost
+		movement["cost"] = recorded.Cost
+	}
+	if len(recorded.Layers) > 0 {
+		movement["layers"] = recorded.Layers
+	}
+	for key, value := range pending.Extra {
+		movement[key] = value
+	}
+	return recorded, movement
+}
+
+// undo takes back the stock change of a journaled movement that could not be
+// recorded. The change and its marker go in one write; if that fails the
+// marker stays and recoverPending records the movement instead.
+func (l *stockLedger) undo(ctx context.Context, recorded Domain.StockMovement) {
+	result, err := l.products.UpdateOne(ctx,
+		bson.M{"_id": recorded.ProductID, "pending_movements.movement_id": recorded.ID},
+		bson.M{
+			"$inc":  bson.M{"stock_quantity": recorded.Quantity.Neg(), "version": 1},
+			"$set":  bson.M{"updated_at": time.Now()},
+			"$pull": bson.M{"pending_movements": bson.M{"movement_id": recorded.ID}},
+		},
+	)
+	// Recovery costs the movement without layers, so the layers go back either way
+	l.undoLayers(ctx, recorded)
+	if err != nil || result.MatchedCount == 0 {
+		fmt.Printf("WARNING: stock change of movement %s on product %s is left to be recovered: %v\n", recorded.ID.Hex(), recorded.ProductID.Hex(), err)
+		return
+	}
+	l.changes.record(ctx, recorded.BusinessID, Domain.ChangeEntityProduct, recorded.ProductID, Domain.ChangeOperationUpsert)
+	l.dropJournaled(ctx, recorded.ID)
+}
+
+// settle clears the marker and journal entry of a recorded movement. A
+// failure here is harmless: recovery finds the movement already recorded.
+func (l *stockLedger) settle(ctx context.Context, movementID, productID primitive.ObjectID) {
+	_, err := l.products.UpdateByID(ctx, productID, bson.M{"$pull": bson.M{"pending_movements": bson.M{"movement_id": movementID}}})
+	if err != nil {
+		fmt.Printf("WARNING: failed to clear stock marker %s on product %s: %v\n", movementID.Hex(), productID.Hex(), err)
+		return
+	}
+	l.dropJournaled(ctx, movementID)
+}
+
+func (l *stockLedger) dropJournaled(ctx context.Context, movementID primitive.ObjectID) {
+	if _, err := l.journal.DeleteOne(ctx, bson.M{"_id": movementID}); err != nil {
+		fmt.Printf("WARNING: failed to remove journaled stock movement %s: %v\n", movementID.Hex(), err)
+	}
+}
+
+// recoverPending finishes the journaled movements of a product that were
+// left behind, by a crash or a failed write, for longer than a request can
+// take. A movement whose stock change went through, as its marker on the
+// product shows, is recorded, costed at the product's average cost; one
+// whose stock never moved is dropped.
+func (l *stockLedger) recoverPending(ctx context.Context, productID primitive.ObjectID) {
+	var left []pendingMovement
+	filter := bson.M{"product_id": productID, "created_at": bson.M{"$lt": time.Now().Add(-pendingMovementGrace)}}
+	if err := findAll(ctx, l.journal, filter, &left); err != nil {
+		fmt.Printf("WARNING: failed to read journaled stock movements of product %s: %v\n", productID.Hex(), err)
+		return
+	}
+	if len(left) == 0 {
+		return
+	}
+
+	var product struct {
+		BusinessID primitive.ObjectID `bson:"business_id"`
+		UnitCost   decimal.Decimal    `bson:"unit_cost"`
+		Markers    []stockMarker      `bson:"pending_movements"`
+	}
+	err := l.products.FindOne(ctx, bson.M{"_id": productID}).Decode(&product)
+	if err != nil && err != mongo.ErrNoDocuments {
+		fmt.Printf("WARNING: failed to read product %s to recover its stock movements: %v\n", productID.Hex(), err)
+		return
+	}
+
+	for _, pending := range left {
+		var marker *stockMarker
+		for i := range product.Markers {
+			if product.Markers[i].MovementID == pending.ID {
+				marker = &product.Markers[i]
+			}
+		}
+		if marker == nil {
+			l.dropJournaled(ctx, pending.ID)
+			continue
+		}
+
+		before := Domain.Product{ID: productID, BusinessID: product.BusinessID, StockQuantity: marker.StockBefore, UnitCost: product.UnitCost}
+		recorded, movement := l.describe(ctx, pending, before, false)
+		_, err := l.movements.InsertOne(ctx, movement)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			fmt.Printf("WARNING: failed to recover stock movement %s of product %s: %v\n", pending.ID.Hex(), productID.Hex(), err)
+			continue
+		}
+		if err == nil {
+			if recorded.Quantity.IsPositive() && pending.UnitCost != nil {
+				l.addLayer(ctx, recorded)
+			}
+			l.changes.record(ctx, recorded.BusinessID, Domain.ChangeEntityStockMovement, recorded.ID, Domain.ChangeOperationUpsert)
+			fmt.Printf("WARNING: recovered stock movement %s of product %s (%s)\n", recorded.ID.Hex(), productID.Hex(), recorded.Quantity)
+		}
+		l.settle(ctx, pending.ID, productID)
+	}
+}
+
+// openingStock records the movement for the stock a new product starts with,
+// and a cost layer when its cost is known. Fields in extra are stored on the
+// movement document. The product's stock is already set, so nothing is
+// moved; a product without stock needs no movement.
+func (l *stockLedger) openingStock(ctx context.Context, product Domain.Product, userID primitive.ObjectID, extra bson.M) (Domain.StockMovement, error) {
+	if !product.StockQuantity.IsPositive() {
+		return Domain.StockMovement{}, nil
+	}
+
+	recorded := Domain.StockMovement{
+		ID:         primitive.NewObjectID(),
+		BusinessID: product.BusinessID,
+		ProductID:  product.ID,
+		Type:       Domain.MovementTypePurchase,
+		Quantity:   product.StockQuantity,
+		Reason:     "Initial stock",
+		UnitCost:   product.UnitCost,
+		Cost:       product.UnitCost.Mul(product.StockQuantity).Round(2),
+		CreatedBy:  userID,
+		CreatedAt:  time.Now(),
+	}
+	movement := bson.M{
+		"_id":         recorded.ID,
+		"business_id": recorded.BusinessID,
+		"product_id":  recorded.ProductID,
+		"type":        recorded.Type,
+		"quantity":    recorded.Quantity,
+		"reason":      recorded.Reason,
+		"created_by":  recorded.CreatedBy,
+		"created_at":  recorded.CreatedAt,
+	}
+	if recorded.UnitCost.IsPositive() {
+		movement["unit_cost"] = recorded.UnitCost
+		movement["cost"] = recorded.Cost
+	}
+	for key, value := range extra {
+		movement[key] = value
+	}
+
+	if _, err := l.movements.InsertOne(ctx, movement); err != nil {
+		return Domain.StockMovement{}, fmt.Errorf("failed to create stock movement: %w", err)
+	}
+	l.changes.record(ctx, product.BusinessID, Domain.ChangeEntityStockMovement, recorded.ID, Domain.ChangeOperationUpsert)
+	if recorded.UnitCost.IsPositive() {
+		l.addLayer(ctx, recorded)
+	}
+	return recorded, nil
+}
+
+// receivedUnitCost is the product's unit cost after quantity units come in
+// at unitCost: the average of the stock already on hand and the stock
+// received. A product with no cost yet takes the new cost as it is.
+func receivedUnitCost(quantity, unitCost decimal.Decimal) bson.M {
+	onHand := bson.M{"$max": bson.A{"$stock_quantity", 0}}
+	current := bson.M{"$ifNull": bson.A{"$unit_cost", decimal.Zero}}
+	blended := bson.M{"$round": bson.A{
+		bson.M{"$divide": bson.A{
+			bson.M{"$add": bson.A{bson.M{"$multiply": bson.A{onHand, current}}, unitCost.Mul(quantity)}},
+			bson.M{"$add": bson.A{onHand, quantity}},
+		}},
+		4,
+	}}
+	unknown := bson.M{"$or": bson.A{
+		bson.M{"$eq": bson.A{current, decimal.Zero}},
+		bson.M{"$eq": bson.A{onHand, 0}},
+	}}
+	return bson.M{"$cond": bson.A{unknown, unitCost, blended}}
+}
+
+// costingMethod reads the costing method of a business.
+func (l *stockLedger) costingMethod(ctx context.Context, businessID primitive.ObjectID) Domain.CostingMethod {
+	var business Domain.Business
+	opts := options.FindOne().SetProjection(bson.M{"costing_method": 1})
+	if err := l.businesses.FindOne(ctx, bson.M{"_id": businessID}, opts).Decode(&business); err != nil {
+		return Domain.CostingWeightedAverage
+	}
+	return business.Costing()
+}
+
+// addLayer keeps the stock a movement received as a cost layer.
+func (l *stockLedger) addLayer(ctx context.Context, movement Domain.StockMovement) {
+	layer := Domain.CostLayer{
+		BusinessID: movement.BusinessID,
+		ProductID:  movement.ProductID,
+		MovementID: movement.ID,
+		UnitCost:   movement.UnitCost,
+		Quantity:   movement.Quantity,
+		Remaining:  movement.Quantity,
+		ReceivedAt: movement.CreatedAt,
+	}
+	if _, err := l.layers.InsertOne(ctx, layer); err != nil {
+		fmt.Printf("WARNING: failed to record cost layer for product %s: %v\n", movement.ProductID.Hex(), err)
+	}
+}
+
+// consumeLayers takes quantity units from the product's oldest cost layers
+// and returns what it took from each. Units no layer covers are left out.
+// Layers are kept for every business, whatever its costing method, so a
+// business can switch to FIFO at any time.
+func (l *stockLedger) consumeLayers(ctx context.Context, productID primitive.ObjectID, quantity decimal.Decimal) []Domain.CostLayerUse {
+	var uses []Domain.CostLayerUse
+	opts := options.FindOne().SetSort(bson.D{{Key: "received_at", Value: 1}, {Key: "_id", Value: 1}})
+	for quantity.IsPositive() {
+		var layer Domain.CostLayer
+		err := l.layers.FindOne(ctx, bson.M{"product_id": productID, "remaining": bson.M{"$gt": 0}}, opts).Decode(&layer)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			fmt.Printf("WARNING: failed to read cost layers of product %s: %v\n", productID.Hex(), err)
+			break
+		}
+
+		// Another decrease may empty the layer first; then try the next one
+		take := decimal.Min(layer.Remaining, quantity)
+		result, err := l.layers.UpdateOne(ctx,
+			bson.M{"_id": layer.ID, "remaining": bson.M{"$gte": take}},
+			bson.M{"$inc": bson.M{"remaining": take.Neg()}},
+		)
+		if err != nil {
+			fmt.Printf("WARNING: failed to take from cost layer %s: %v\n", layer.ID.Hex(), err)
+			break
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		uses = append(uses, Domain.CostLayerUse{LayerID: layer.ID, Quantity: take, UnitCost: layer.UnitCost})
+		quantity = quantity.Sub(take)
+	}
+	return uses
+}
+
+// undoLayers puts back the cost layer units a movement took, or removes the
+// layer it added.
+func (l *stockLedger) undoLayers(ctx context.Context, movement Domain.StockMovement) {
+	for _, use := range movement.Layers {
+		if _, err := l.layers.UpdateByID(ctx, use.LayerID, bson.M{"$inc": bson.M{"remaining": use.Quantity}}); err != nil {
+			fmt.Printf("WARNING: failed to return units to cost layer %s: %v\n", use.LayerID.Hex(), err)
+		}
+	}
+	if movement.Quantity.IsPositive() {
+		if _, err := l.layers.DeleteOne(ctx, bson.M{"movement_id": movement.ID}); err != nil {
+			fmt.Printf("WARNING: failed to remove cost layer of movement %s: %v\n", movement.ID.Hex(), err)
+		}
+	}
+}
+
+// explainMiss tells why a guarded stock update matched nothing.
+func (l *stockLedger) explainMiss(ctx context.Context, productID primitive.ObjectID, quantity decimal.Decimal) error {
+	var product Domain.Product
+	err := l.products.FindOne(ctx, bson.M{"_id": productID}).Decode(&product)
+	if err != nil {
+		return fmt.Errorf("failed to find product: %w", err)
+	}
+	return fmt.Errorf("%w. Available: %s, Required: %s", Domain.ErrInsufficientStock, product.StockQuantity, quantity)
+}
+
+// moveStock changes a product's stock by delta without recording a movement.
+func (l *stockLedger) moveStock(ctx context.Context, businessID, productID primitive.ObjectID, delta decimal.Decimal) error {
+	_, err := l.products.UpdateByID(ctx, productID, bson.M{
+		"$inc": bson.M{"stock_quantity": delta, "version": 1},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	l.changes.record(ctx, businessID, Domain.ChangeEntityProduct, productID, Domain.ChangeOperationUpsert)
+	return nil
+}
+
+// revert undoes a movement: the movement is removed and the product's stock
+// moved back by the recorded quantity. The movement goes first, and only the
+// call that removes it moves the stock, so a revert run twice, by a retry or
+// by two instances recovering the same batch, moves the stock once.
+func (l *stockLedger) revert(ctx context.Context, movement Domain.StockMovement) error {
+	result, err := l.movements.DeleteOne(ctx, bson.M{"_id": movement.ID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount != 1 {
+		return nil
+	}
+	l.changes.record(ctx, movement.BusinessID, Domain.ChangeEntityStockMovement, movement.ID, Domain.ChangeOperationDelete)
+
+	if err := l.moveStock(ctx, movement.BusinessID, movement.ProductID, movement.Quantity.Neg()); err != nil {
+		return fmt.Errorf("stock movement %s was removed but the stock of product %s was not moved back: %w", movement.ID.Hex(), movement.ProductID.Hex(), err)
+	}
+	l.undoLayers(ctx, movement)
+	return nil
+}
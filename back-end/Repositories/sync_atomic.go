@@ -0,0 +1,348 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	journalStatePending    = "pending"
+	journalStateRecovering = "recovering"
+	journalStateCommitted  = "committed"
+	journalStateRolledBack = "rolled_back"
+
+	// journalStaleAfter is how long a pending journal may go untouched before
+	// recovery assumes the process applying its batch has died. The batch
+	// touches its journal before every item and before it is logged.
+	journalStaleAfter = 5 * time.Minute
+
+	// journalRecoveryLease is how long a claim on a journal keeps other
+	// instances from recovering it. A claim left by a recovery that died
+	// runs out and the journal is claimed again.
+	journalRecoveryLease = 2 * time.Minute
+)
+
+var (
+	errAtomicBatchRejected = errors.New("atomic sync batch rejected")
+	errJournalTakenOver    = errors.New("sync batch took too long and was rolled back by recovery")
+)
+
+// syncJournal records an atomic batch applied without a session transaction.
+// Every document the batch inserts carries its sync_id; documents it modifies
+// are saved here first so the batch can be rolled back after a crash.
+type syncJournal struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	BusinessID   primitive.ObjectID `bson:"business_id"`
+	DeviceID     string             `bson:"device_id"`
+	State        string             `bson:"state"`
+	BeforeImages []journalImage     `bson:"before_images"`
+	StartedAt    time.Time          `bson:"started_at"`
+	TouchedAt    time.Time          `bson:"touched_at"`
+	LeaseUntil   *time.Time         `bson:"lease_until,omitempty"`
+	FinishedAt   *time.Time         `bson:"finished_at,omitempty"`
+}
+
+type journalImage struct {
+	Entity domain.ChangeEntity `bson:"entity"`
+	ID     primitive.ObjectID  `bson:"id"`
+	Doc    bson.Raw            `bson:"doc"`
+}
+
+// applyInTransaction applies an atomic batch inside a session transaction. The
+// sync log is written in the same transaction so a batch is never committed
+// without it.
+func (r *MongoSyncRepository) applyInTransaction(ctx context.Context, batch *syncBatch, transactions []domain.SyncBatchTransaction, response *domain.SyncBatchResponse, logDoc func() bson.M) error {
+	session, err := r.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	initial := *response
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		// The callback is retried on transient errors, so start from a clean slate
+		*response = initial
+		response.Results = make([]domain.SyncItemResult, 0, len(transactions))
+
+		r.applyTransactions(sc, batch, transactions, response)
+		if !fullyApplied(response) {
+			return nil, errAtomicBatchRejected
+		}
+		return nil, r.insertSyncLog(sc, logDoc)
+	})
+	if err == errAtomicBatchRejected {
+		markRolledBack(response)
+		return r.insertSyncLog(ctx, logDoc)
+	}
+	return err
+}
+
+// applyJournaled applies an atomic batch on a standalone server, where
+// transactions are not available. Changes are compensated if any
+// transaction fails, and a crash is recovered from the journal on restart.
+func (r *MongoSyncRepository) applyJournaled(ctx context.Context, batch *syncBatch, transactions []domain.SyncBatchTransaction, response *domain.SyncBatchResponse, logDoc func() bson.M) error {
+	journalID, err := primitive.ObjectIDFromHex(batch.syncID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	journal := syncJournal{
+		ID:           journalID,
+		BusinessID:   batch.businessID,
+		DeviceID:     batch.deviceID,
+		State:        journalStatePending,
+		BeforeImages: []journalImage{},
+		StartedAt:    now,
+		TouchedAt:    now,
+	}
+	if _, err := r.journal.InsertOne(ctx, journal); err != nil {
+		return err
+	}
+
+	batch.journaled = true
+	r.applyTransactions(ctx, batch, transactions, response)
+
+	// A batch recovery took over is rolled back even if every item went
+	// through; touching the journal last keeps recovery off it until the
+	// batch is logged
+	takenOver := r.touchJournal(ctx, journalID) != nil
+
+	state := journalStateCommitted
+	if takenOver || !fullyApplied(response) {
+		if err := r.rollbackBatch(ctx, journalID); err != nil {
+			return err
+		}
+		markRolledBack(response)
+		state = journalStateRolledBack
+	}
+
+	if err := r.insertSyncLog(ctx, logDoc); err != nil {
+		return err
+	}
+	return r.finishJournal(ctx, journalID, state)
+}
+
+// touchJournal marks a journaled batch as still being applied, so recovery
+// leaves it alone. It fails once recovery has claimed the journal, and the
+// batch must then stop.
+func (r *MongoSyncRepository) touchJournal(ctx context.Context, journalID primitive.ObjectID) error {
+	result, err := r.journal.UpdateOne(ctx,
+		bson.M{"_id": journalID, "state": journalStatePending},
+		bson.M{"$set": bson.M{"touched_at": time.Now().UTC()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errJournalTakenOver
+	}
+	return nil
+}
+
+// captureBeforeImage saves the record a mutation is about to change.
+func (r *MongoSyncRepository) captureBeforeImage(ctx context.Context, batch *syncBatch, tx domain.SyncBatchTransaction) {
+	var collection *mongo.Collection
+	var entity domain.ChangeEntity
+	var ref domain.SyncRef
+
+	switch tx.Type {
+	case domain.SyncTransactionTypeSaleVoid:
+		var payload domain.SaleVoidSyncPayload
+		if domain.DecodeSyncPayload(batch.schemaVersion, tx.Data, &payload) != nil {
+			return
+		}
+		collection, entity, ref = r.sales, domain.ChangeEntitySale, payload.Sale()
+	case domain.SyncTransactionTypeExpenseUpdate:
+		var payload domain.ExpenseUpdateSyncPayload
+		if domain.DecodeSyncPayload(batch.schemaVersion, tx.Data, &payload) != nil {
+			return
+		}
+		collection, entity, ref = r.expenses, domain.ChangeEntityExpense, payload.Expense()
+	default:
+		// Inserts and stock movements are undone through their sync_id
+		return
+	}
+
+	id, err := r.resolveSyncedRef(ctx, collection, batch.businessID, batch.deviceID, ref, "id", "local_id")
+	if err != nil {
+		return
+	}
+	doc, err := collection.FindOne(ctx, bson.M{"_id": id, "business_id": batch.businessID}).Raw()
+	if err != nil {
+		return
+	}
+
+	journalID, _ := primitive.ObjectIDFromHex(batch.syncID)
+	image := journalImage{Entity: entity, ID: id, Doc: doc}
+	if _, err := r.journal.UpdateByID(ctx, journalID, bson.M{"$push": bson.M{"before_images": image}}); err != nil {
+		fmt.Printf("WARNING: failed to journal before-image for %s %s: %v\n", entity, id.Hex(), err)
+	}
+}
+
+// rollbackBatch undoes everything a journaled batch wrote: stock movements are
+// reverted, modified records restored and inserted records removed.
+func (r *MongoSyncRepository) rollbackBatch(ctx context.Context, journalID primitive.ObjectID) error {
+	var journal syncJournal
+	if err := r.journal.FindOne(ctx, bson.M{"_id": journalID}).Decode(&journal); err != nil {
+		return err
+	}
+	syncID := journalID.Hex()
+
+	var movements []domain.StockMovement
+	if err := findAll(ctx, r.movements, bson.M{"sync_id": syncID}, &movements); err != nil {
+		return err
+	}
+	for i := len(movements) - 1; i >= 0; i-- {
+		if err := r.ledger.revert(ctx, movements[i]); err != nil {
+			return err
+		}
+	}
+
+	for i := len(journal.BeforeImages) - 1; i >= 0; i-- {
+		image := journal.BeforeImages[i]
+		collection := r.sales
+		if image.Entity == domain.ChangeEntityExpense {
+			collection = r.expenses
+		}
+		if _, err := collection.ReplaceOne(ctx, bson.M{"_id": image.ID}, image.Doc); err != nil {
+			return err
+		}
+		r.changes.record(ctx, journal.BusinessID, image.Entity, image.ID, domain.ChangeOperationUpsert)
+	}
+
+	inserted := []struct {
+		collection *mongo.Collection
+		entity     domain.ChangeEntity
+	}{
+		{r.sales, domain.ChangeEntitySale},
+		{r.expenses, domain.ChangeEntityExpense},
+		{r.products, domain.ChangeEntityProduct},
+	}
+	for _, target := range inserted {
+		var docs []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := findAll(ctx, target.collection, bson.M{"sync_id": syncID}, &docs); err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			continue
+		}
+		if _, err := target.collection.DeleteMany(ctx, bson.M{"sync_id": syncID}); err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			r.changes.record(ctx, journal.BusinessID, target.entity, doc.ID, domain.ChangeOperationDelete)
+		}
+	}
+
+	if _, err := r.operations.DeleteMany(ctx, bson.M{"sync_id": syncID}); err != nil {
+		return err
+	}
+	_, err := r.conflicts.DeleteMany(ctx, bson.M{"sync_id": syncID})
+	return err
+}
+
+func (r *MongoSyncRepository) finishJournal(ctx context.Context, journalID primitive.ObjectID, state string) error {
+	_, err := r.journal.UpdateByID(ctx, journalID, bson.M{"$set": bson.M{"state": state, "finished_at": time.Now().UTC()}})
+	return err
+}
+
+// RecoverJournals finishes journaled batches left pending by a crash. A batch
+// whose sync log was written is complete; any other is rolled back. Only
+// journals untouched for journalStaleAfter are taken, so it runs at startup
+// and again periodically to catch batches cut short by a quick restart.
+//
+// Every instance runs it, so each journal is claimed before it is recovered
+// and only one instance works on it at a time. A rollback cut short is safe
+// to run again: each movement is reverted only by the run that removes it.
+func (r *MongoSyncRepository) RecoverJournals(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	for {
+		journal, err := r.claimJournal(ctx)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim pending sync journal: %w", err)
+		}
+
+		logged, err := r.syncLogs.CountDocuments(ctx, bson.M{"_id": journal.ID})
+		if err != nil {
+			fmt.Printf("WARNING: failed to recover sync journal %s: %v\n", journal.ID.Hex(), err)
+			continue
+		}
+		if logged > 0 {
+			_ = r.finishJournal(ctx, journal.ID, journalStateCommitted)
+			continue
+		}
+		if err := r.rollbackBatch(ctx, journal.ID); err != nil {
+			fmt.Printf("WARNING: failed to roll back sync journal %s: %v\n", journal.ID.Hex(), err)
+			continue
+		}
+		_ = r.finishJournal(ctx, journal.ID, journalStateRolledBack)
+	}
+}
+
+// claimJournal takes the next journal to recover: a pending one left
+// untouched for journalStaleAfter, or one whose recovery lease ran out. The
+// claim moves it to recovering until the lease ends.
+func (r *MongoSyncRepository) claimJournal(ctx context.Context) (syncJournal, error) {
+	now := time.Now().UTC()
+	stale := now.Add(-journalStaleAfter)
+	filter := bson.M{"$or": bson.A{
+		bson.M{"state": journalStatePending, "touched_at": bson.M{"$lt": stale}},
+		// Journals written before batches touched them
+		bson.M{"state": journalStatePending, "touched_at": bson.M{"$exists": false}, "started_at": bson.M{"$lt": stale}},
+		bson.M{"state": journalStateRecovering, "lease_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{"$set": bson.M{"state": journalStateRecovering, "lease_until": now.Add(journalRecoveryLease)}}
+
+	var journal syncJournal
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.journal.FindOneAndUpdate(ctx, filter, update, opts).Decode(&journal)
+	return journal, err
+}
+
+// fullyApplied reports whether every transaction of a batch took effect.
+func fullyApplied(response *domain.SyncBatchResponse) bool {
+	return response.Summary.Failed == 0 && response.Summary.Conflicts == 0
+}
+
+// markRolledBack rewrites the results of an atomic batch that was undone.
+// Conflicts stored by the batch were undone with it, so their IDs are dropped.
+func markRolledBack(response *domain.SyncBatchResponse) {
+	response.Status = syncStatusRolledBack
+	for i := range response.Results {
+		result := &response.Results[i]
+		result.Applied = nil
+		if result.Conflict != nil {
+			conflict := *result.Conflict
+			conflict.ConflictID = ""
+			result.Conflict = &conflict
+		}
+		if result.Status != "success" {
+			continue
+		}
+		result.Status = syncStatusRolledBack
+		result.ServerID = ""
+		result.Message = "rolled back: atomic batch had failures"
+		response.Summary.Success--
+		response.Summary.RolledBack++
+	}
+	if response.Summary.Failed == 0 {
+		// Held-back conflicts count against an atomic batch
+		response.Summary.Failed = response.Summary.Conflicts
+	}
+}
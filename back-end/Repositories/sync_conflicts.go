@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// conflictPolicy reads the business's sync conflict policy.
+func (r *MongoSyncRepository) conflictPolicy(ctx context.Context, businessID primitive.ObjectID) (domain.SyncConflictPolicy, error) {
+	var business domain.Business
+	opts := options.FindOne().SetProjection(bson.M{"sync_conflict_policy": 1})
+	if err := r.business.FindOne(ctx, bson.M{"_id": businessID}, opts).Decode(&business); err != nil {
+		return "", err
+	}
+	return business.ConflictPolicy(), nil
+}
+
+// checkConflict compares the version a device based its change on with the
+// server's current version. Mutations without a base_version are applied
+// as before. On a mismatch the conflict is stored and the batch policy
+// decides whether the client's change is applied.
+func (r *MongoSyncRepository) checkConflict(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction, mutation domain.SyncMutation, entity domain.ChangeEntity, entityID primitive.ObjectID, serverVersion int64, serverUpdatedAt time.Time, server interface{}) (bool, *domain.SyncConflictInfo, error) {
+	if b.force {
+		return true, nil, nil
+	}
+	if mutation.BaseVersion == nil || *mutation.BaseVersion == serverVersion {
+		return true, nil, nil
+	}
+	baseVersion := *mutation.BaseVersion
+
+	apply := false
+	switch b.policy {
+	case domain.SyncPolicyClientWins:
+		apply = true
+	case domain.SyncPolicyLastWriterWins:
+		apply = mutation.ChangedAt().After(serverUpdatedAt)
+	}
+
+	now := time.Now().UTC()
+	conflict := domain.SyncConflict{
+		ID:            primitive.NewObjectID(),
+		BusinessID:    b.businessID,
+		DeviceID:      b.deviceID,
+		SyncID:        b.syncID,
+		LocalID:       tx.LocalID,
+		Type:          tx.Type,
+		SchemaVersion: b.schemaVersion,
+		Info: domain.SyncConflictInfo{
+			Entity:        entity,
+			EntityID:      entityID.Hex(),
+			ClientVersion: baseVersion,
+			ServerVersion: serverVersion,
+			Server:        snapshot(server),
+			Client:        tx.Data,
+			Policy:        b.policy,
+		},
+		Status:    domain.SyncConflictOpen,
+		CreatedAt: now,
+		UserID:    b.userID,
+		Role:      b.role,
+		RequestID: b.requestID,
+	}
+	conflict.Info.ConflictID = conflict.ID.Hex()
+
+	if b.policy != domain.SyncPolicyManual {
+		conflict.Status = domain.SyncConflictResolved
+		conflict.ResolvedAt = &now
+		conflict.Info.Resolution = domain.SyncResolutionServer
+		if apply {
+			conflict.Info.Resolution = domain.SyncResolutionClient
+		}
+	}
+
+	if _, err := r.conflicts.InsertOne(ctx, conflict); err != nil {
+		return false, nil, err
+	}
+	return apply, &conflict.Info, nil
+}
+
+// ListConflicts returns stored conflicts for a business, newest first.
+func (r *MongoSyncRepository) ListConflicts(ctx context.Context, businessID string, status domain.SyncConflictStatus, page, limit int) (*domain.SyncConflictList, error) {
+	businessObjID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return nil, errors.New("invalid business_id")
+	}
+
+	filter := bson.M{"business_id": businessObjID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	total, err := r.conflicts.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := r.conflicts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	response := &domain.SyncConflictList{Data: []domain.SyncConflict{}}
+	if err := cursor.All(ctx, &response.Data); err != nil {
+		return nil, err
+	}
+
+	response.Pagination.CurrentPage = page
+	response.Pagination.PerPage = limit
+	response.Pagination.TotalRecords = total
+	response.Pagination.TotalPages = int(math.Ceil(float64(total) / float64(limit)))
+	return response, nil
+}
+
+// ResolveConflict decides an open conflict. Choosing the client re-applies the
+// device's change on top of the current server record.
+func (r *MongoSyncRepository) ResolveConflict(ctx context.Context, businessID, conflictID, userID string, resolution domain.SyncConflictResolution) (*domain.SyncConflict, error) {
+	businessObjID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return nil, errors.New("invalid business_id")
+	}
+	conflictObjID, err := primitive.ObjectIDFromHex(conflictID)
+	if err != nil {
+		return nil, domain.ErrConflictNotFound
+	}
+
+	// Claim the conflict first so two resolutions cannot both apply
+	now := time.Now().UTC()
+	set := bson.M{
+		"status":              domain.SyncConflictResolved,
+		"conflict.resolution": resolution,
+		"resolved_at":         now,
+	}
+	if actor := syncActor(userID); !actor.IsZero() {
+		set["resolved_by"] = actor
+	}
+	var conflict domain.SyncConflict
+	err = r.conflicts.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": conflictObjID, "business_id": businessObjID, "status": domain.SyncConflictOpen},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&conflict)
+	if err == mongo.ErrNoDocuments {
+		count, countErr := r.conflicts.CountDocuments(ctx, bson.M{"_id": conflictObjID, "business_id": businessObjID})
+		if countErr != nil {
+			return nil, countErr
+		}
+		if count == 0 {
+			return nil, domain.ErrConflictNotFound
+		}
+		return nil, domain.ErrConflictResolved
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if resolution == domain.SyncResolutionClient {
+		batch := &syncBatch{
+			businessID:    businessObjID,
+			deviceID:      conflict.DeviceID,
+			userID:        userID,
+			syncID:        conflict.SyncID,
+			policy:        domain.SyncPolicyClientWins,
+			schemaVersion: conflict.SchemaVersion,
+			force:         true,
+		}
+		tx := domain.SyncBatchTransaction{LocalID: conflict.LocalID, Type: conflict.Type, Data: conflict.Info.Client}
+		if _, _, applyErr := r.processSingleTransaction(ctx, batch, tx); applyErr != nil {
+			// Reopen so the conflict can be decided again
+			_, _ = r.conflicts.UpdateByID(ctx, conflictObjID, bson.M{
+				"$set":   bson.M{"status": domain.SyncConflictOpen},
+				"$unset": bson.M{"conflict.resolution": "", "resolved_at": "", "resolved_by": ""},
+			})
+			return nil, applyErr
+		}
+		conflict.Applied = batch.applied
+	}
+
+	return &conflict, nil
+}
+
+// snapshot converts a record to its JSON shape so it can be stored and returned
+// alongside the client's payload.
+func snapshot(record interface{}) map[string]interface{} {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// lastWrite returns when a record was last changed.
+func lastWrite(updatedAt *time.Time, createdAt time.Time) time.Time {
+	if updatedAt != nil {
+		return *updatedAt
+	}
+	return createdAt
+}
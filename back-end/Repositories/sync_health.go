@@ -0,0 +1,275 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetHealth aggregates the sync_logs of a business over a time window.
+func (r *MongoSyncRepository) GetHealth(ctx context.Context, query domain.SyncHealthQuery) (*domain.SyncHealthReport, error) {
+	businessObjID, err := primitive.ObjectIDFromHex(query.BusinessID)
+	if err != nil {
+		return nil, errors.New("invalid business_id")
+	}
+
+	match := bson.M{
+		"business_id": businessObjID,
+		"created_at":  bson.M{"$gte": query.From, "$lte": query.To},
+	}
+	if query.DeviceID != "" {
+		match["device_id"] = query.DeviceID
+	}
+
+	report := &domain.SyncHealthReport{
+		BusinessID:    query.BusinessID,
+		DeviceID:      query.DeviceID,
+		From:          query.From,
+		To:            query.To,
+		TopFailures:   []domain.SyncFailureCount{},
+		SilentDevices: []domain.SyncSilentDevice{},
+		Daily:         []domain.SyncHealthDay{},
+	}
+
+	if err := r.healthTotals(ctx, match, report); err != nil {
+		return nil, err
+	}
+	report.SuccessRate = report.Items.Rate()
+
+	if report.TopFailures, err = r.healthTopFailures(ctx, match, query.TopFailures); err != nil {
+		return nil, err
+	}
+	if report.Daily, err = r.healthDaily(ctx, match); err != nil {
+		return nil, err
+	}
+	if report.Lag, err = r.healthLag(ctx, businessObjID, query); err != nil {
+		return nil, err
+	}
+	if report.SilentDevices, err = r.silentDevices(ctx, businessObjID, query); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// healthTotals fills in batch counts, item outcomes and batch sizes. Retry
+// logs count towards item outcomes but not batch sizes, since the queue
+// always replays a single item.
+func (r *MongoSyncRepository) healthTotals(ctx context.Context, match bson.M, report *domain.SyncHealthReport) error {
+	isRetry := bson.M{"$eq": bson.A{"$source", domain.SyncLogSourceRetry}}
+	deviceSize := bson.M{"$cond": bson.A{isRetry, nil, "$summary.total"}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           nil,
+			"batches":       bson.M{"$sum": bson.M{"$cond": bson.A{isRetry, 0, 1}}},
+			"retry_batches": bson.M{"$sum": bson.M{"$cond": bson.A{isRetry, 1, 0}}},
+			"total":         bson.M{"$sum": "$summary.total"},
+			"success":       bson.M{"$sum": "$summary.success"},
+			"failed":        bson.M{"$sum": "$summary.failed"},
+			"conflicts":     bson.M{"$sum": "$summary.conflicts"},
+			"rolled_back":   bson.M{"$sum": "$summary.rolled_back"},
+			"average":       bson.M{"$avg": deviceSize},
+			"min":           bson.M{"$min": deviceSize},
+			"max":           bson.M{"$max": deviceSize},
+		}}},
+	}
+	cursor, err := r.syncLogs.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		return cursor.Err()
+	}
+	var agg struct {
+		Batches                   int64 `bson:"batches"`
+		RetryBatches              int64 `bson:"retry_batches"`
+		domain.SyncHealthItems    `bson:",inline"`
+		domain.SyncBatchSizeStats `bson:",inline"`
+	}
+	if err := cursor.Decode(&agg); err != nil {
+		return err
+	}
+	report.Batches = agg.Batches
+	report.RetryBatches = agg.RetryBatches
+	report.Items = agg.SyncHealthItems
+	report.BatchSize = agg.SyncBatchSizeStats
+	return nil
+}
+
+// healthTopFailures groups failed items by message, most frequent first.
+func (r *MongoSyncRepository) healthTopFailures(ctx context.Context, match bson.M, limit int) ([]domain.SyncFailureCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$match", Value: bson.M{"summary.failed": bson.M{"$gt": 0}}}},
+		{{Key: "$unwind", Value: "$results"}},
+		{{Key: "$match", Value: bson.M{"results.status": "failed"}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          bson.M{"message": "$results.message", "code": "$results.code"},
+			"count":        bson.M{"$sum": 1},
+			"devices":      bson.M{"$addToSet": "$device_id"},
+			"last_seen_at": bson.M{"$max": "$created_at"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "last_seen_at", Value: -1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{
+			"_id":          0,
+			"message":      "$_id.message",
+			"code":         "$_id.code",
+			"count":        1,
+			"devices":      bson.M{"$size": "$devices"},
+			"last_seen_at": 1,
+		}}},
+	}
+	cursor, err := r.syncLogs.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	failures := []domain.SyncFailureCount{}
+	if err := cursor.All(ctx, &failures); err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// healthDaily buckets batches and item outcomes per UTC day. Retry logs are
+// counted apart from batches, as in healthTotals, so the days add up to the
+// totals.
+func (r *MongoSyncRepository) healthDaily(ctx context.Context, match bson.M) ([]domain.SyncHealthDay, error) {
+	isRetry := bson.M{"$eq": bson.A{"$source", domain.SyncLogSourceRetry}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+			"batches":       bson.M{"$sum": bson.M{"$cond": bson.A{isRetry, 0, 1}}},
+			"retry_batches": bson.M{"$sum": bson.M{"$cond": bson.A{isRetry, 1, 0}}},
+			"items":         bson.M{"$sum": "$summary.total"},
+			"success":       bson.M{"$sum": "$summary.success"},
+			"failed":        bson.M{"$sum": "$summary.failed"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+	cursor, err := r.syncLogs.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	days := []domain.SyncHealthDay{}
+	if err := cursor.All(ctx, &days); err != nil {
+		return nil, err
+	}
+	for i := range days {
+		days[i].SuccessRate = domain.SyncHealthItems{Total: days[i].Items, Success: days[i].Success}.Rate()
+	}
+	return days, nil
+}
+
+// healthLag measures the gap between created_at, stamped on the device, and
+// synced_at, stamped when the record reached the server, for every record
+// synced in the window. Device clocks running ahead count as no lag.
+func (r *MongoSyncRepository) healthLag(ctx context.Context, businessID primitive.ObjectID, query domain.SyncHealthQuery) (domain.SyncLagStats, error) {
+	match := bson.M{
+		"business_id": businessID,
+		"synced_at":   bson.M{"$gte": query.From, "$lte": query.To},
+	}
+	if query.DeviceID != "" {
+		match["device_id"] = query.DeviceID
+	}
+	lagMillis := bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$synced_at", "$created_at"}}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"records": bson.M{"$sum": 1},
+			"total":   bson.M{"$sum": lagMillis},
+			"max":     bson.M{"$max": lagMillis},
+		}}},
+	}
+
+	var stats domain.SyncLagStats
+	var totalMillis, maxMillis float64
+	for _, collection := range []*mongo.Collection{r.sales, r.expenses, r.products, r.movements} {
+		cursor, err := collection.Aggregate(ctx, pipeline)
+		if err != nil {
+			return stats, err
+		}
+		var agg struct {
+			Records int64   `bson:"records"`
+			Total   float64 `bson:"total"`
+			Max     float64 `bson:"max"`
+		}
+		if cursor.Next(ctx) {
+			if err := cursor.Decode(&agg); err != nil {
+				fmt.Printf("WARNING: failed to decode sync lag for %s: %v\n", collection.Name(), err)
+			}
+		}
+		cursor.Close(ctx)
+
+		stats.Records += agg.Records
+		totalMillis += agg.Total
+		if agg.Max > maxMillis {
+			maxMillis = agg.Max
+		}
+	}
+
+	if stats.Records > 0 {
+		stats.AverageSeconds = totalMillis / float64(stats.Records) / 1000
+	}
+	stats.MaxSeconds = maxMillis / 1000
+	return stats, nil
+}
+
+// silentDevices lists active devices that have not synced for at least
+// query.SilentDays. Devices that never synced count from their registration.
+func (r *MongoSyncRepository) silentDevices(ctx context.Context, businessID primitive.ObjectID, query domain.SyncHealthQuery) ([]domain.SyncSilentDevice, error) {
+	var business struct {
+		Devices []domain.SyncDevice `bson:"sync_devices"`
+	}
+	if err := r.business.FindOne(ctx, bson.M{"_id": businessID}).Decode(&business); err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	statuses, err := r.getDeviceStatuses(ctx, businessID, query.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	lastSync := make(map[string]*time.Time, len(statuses))
+	for _, status := range statuses {
+		lastSync[status.DeviceID] = status.LastSyncAt
+	}
+
+	now := time.Now().UTC()
+	threshold := time.Duration(query.SilentDays) * 24 * time.Hour
+	silent := []domain.SyncSilentDevice{}
+	for _, device := range business.Devices {
+		if !device.IsActive() || (query.DeviceID != "" && device.DeviceID != query.DeviceID) {
+			continue
+		}
+		since := device.RegisteredAt
+		last := lastSync[device.DeviceID]
+		if last != nil {
+			since = *last
+		}
+		if now.Sub(since) < threshold {
+			continue
+		}
+		silent = append(silent, domain.SyncSilentDevice{
+			DeviceID:   device.DeviceID,
+			Name:       device.Name,
+			LastSyncAt: last,
+			SilentDays: int(now.Sub(since).Hours() / 24),
+		})
+	}
+	return silent, nil
+}
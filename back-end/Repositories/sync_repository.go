@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
 	domain "shop-ops/Domain"
 	"strings"
@@ -13,11 +14,13 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 const (
 	syncStatusCompleted      = "completed"
 	syncStatusPartialSuccess = "partial_success"
+	syncStatusRolledBack     = "rolled_back"
 )
 
 // SyncRepository provides synchronization persistence operations.
@@ -25,27 +28,68 @@ type SyncRepository interface {
 	ProcessBatch(ctx context.Context, req domain.SyncBatchRequest) (*domain.SyncBatchResponse, error)
 	GetStatus(ctx context.Context, businessID, deviceID string) (*domain.SyncStatusResponse, error)
 	GetHistory(ctx context.Context, businessID string, page, limit int) (*domain.SyncHistoryResponse, error)
+	GetHealth(ctx context.Context, query domain.SyncHealthQuery) (*domain.SyncHealthReport, error)
+	PullChanges(ctx context.Context, businessID, deviceID string, cursor *domain.SyncPullCursor, limit int) (*domain.SyncPullResponse, domain.SyncPullCursor, error)
+	ListConflicts(ctx context.Context, businessID string, status domain.SyncConflictStatus, page, limit int) (*domain.SyncConflictList, error)
+	ResolveConflict(ctx context.Context, businessID, conflictID, userID string, resolution domain.SyncConflictResolution) (*domain.SyncConflict, error)
+	GetItemHistory(ctx context.Context, businessID, deviceID, localID string) (*domain.SyncItemHistory, error)
+	ListRetries(ctx context.Context, businessID, deviceID string, state domain.SyncRetryState, page, limit int) (*domain.SyncRetryList, error)
+	UpdateRetryState(ctx context.Context, businessID, deviceID string, localIDs []string, from []domain.SyncRetryState, to domain.SyncRetryState) (int64, error)
+	ProcessRetries(ctx context.Context, now time.Time, limit int, authorize RetryAuthorizer) ([]domain.SyncRetryOutcome, error)
+	RecoverJournals(ctx context.Context) error
+	CreateUpload(ctx context.Context, upload *domain.SyncUpload) error
+	GetUpload(ctx context.Context, businessID, uploadID string) (*domain.SyncUpload, error)
+	SaveUploadChunk(ctx context.Context, businessID, uploadID string, index int, transactions []domain.SyncBatchTransaction) (*domain.SyncUpload, error)
+	ClaimUpload(ctx context.Context, businessID, uploadID, userID, deviceID string) (*domain.SyncUpload, []domain.SyncBatchTransaction, error)
+	ReleaseUpload(ctx context.Context, uploadID, syncID string) error
 }
 
 // MongoSyncRepository is a MongoDB implementation of SyncRepository.
 type MongoSyncRepository struct {
-	db        *mongo.Database
-	syncLogs  *mongo.Collection
-	sales     *mongo.Collection
-	expenses  *mongo.Collection
-	business  *mongo.Collection
+	db           *mongo.Database
+	syncLogs     *mongo.Collection
+	sales        *mongo.Collection
+	expenses     *mongo.Collection
+	products     *mongo.Collection
+	movements    *mongo.Collection
+	operations   *mongo.Collection
+	conflicts    *mongo.Collection
+	retries      *mongo.Collection
+	uploads      *mongo.Collection
+	uploadChunks *mongo.Collection
+	business     *mongo.Collection
+	journal      *mongo.Collection
+	counters     *mongo.Collection
+	changes      *changeFeed
+	ledger       *stockLedger
+	topology     *topology
 }
 
 // NewSyncRepository creates a SyncRepository backed by MongoDB.
 func NewSyncRepository(db *mongo.Database) SyncRepository {
 	repo := &MongoSyncRepository{
-		db:       db,
-		syncLogs: db.Collection("sync_logs"),
-		sales:    db.Collection("sales"),
-		expenses: db.Collection("expenses"),
-		business: db.Collection("businesses"),
+		db:           db,
+		syncLogs:     db.Collection("sync_logs"),
+		sales:        db.Collection("sales"),
+		expenses:     db.Collection("expenses"),
+		products:     db.Collection("products"),
+		movements:    db.Collection("stock_movements"),
+		operations:   db.Collection("sync_operations"),
+		conflicts:    db.Collection("sync_conflicts"),
+		retries:      db.Collection("sync_retries"),
+		uploads:      db.Collection("sync_uploads"),
+		uploadChunks: db.Collection("sync_upload_chunks"),
+		journal:      db.Collection("sync_journal", options.Collection().SetWriteConcern(writeconcern.Journaled())),
+		business:     db.Collection("businesses"),
+		counters:     db.Collection("counters"),
+		changes:      newChangeFeed(db),
+		ledger:       newStockLedger(db),
+		topology:     newTopology(db),
 	}
 	repo.ensureIndexes()
+	if err := repo.RecoverJournals(context.Background()); err != nil {
+		fmt.Printf("WARNING: %v\n", err)
+	}
 	return repo
 }
 
@@ -56,6 +100,7 @@ func (r *MongoSyncRepository) ensureIndexes() {
 	_, _ = r.syncLogs.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "created_at", Value: -1}}},
 		{Keys: bson.D{{Key: "device_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "device_id", Value: 1}, {Key: "cursor", Value: -1}}},
 	})
 
 	_, _ = r.sales.Indexes().CreateOne(ctx, mongo.IndexModel{
@@ -65,6 +110,66 @@ func (r *MongoSyncRepository) ensureIndexes() {
 	_, _ = r.expenses.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "device_id", Value: 1}, {Key: "local_id", Value: 1}},
 	})
+
+	_, _ = r.products.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "device_id", Value: 1}, {Key: "local_id", Value: 1}},
+	})
+
+	_, _ = r.movements.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "device_id", Value: 1}, {Key: "local_id", Value: 1}},
+	})
+
+	// Sync lag for the health report is measured over synced_at
+	for _, collection := range []*mongo.Collection{r.sales, r.expenses, r.products, r.movements} {
+		_, _ = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "business_id", Value: 1}, {Key: "synced_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		})
+	}
+
+	_, _ = r.operations.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "business_id", Value: 1}, {Key: "device_id", Value: 1}, {Key: "local_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	_, _ = r.conflicts.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+
+	_, _ = r.retries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "business_id", Value: 1}, {Key: "device_id", Value: 1}, {Key: "local_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+	})
+
+	_, _ = r.syncLogs.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "business_id", Value: 1}, {Key: "device_id", Value: 1}, {Key: "results.local_id", Value: 1}},
+	})
+
+	_, _ = r.uploads.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	_, _ = r.uploadChunks.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "upload_id", Value: 1}, {Key: "index", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+
+	_, _ = r.journal.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "touched_at", Value: 1}}},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "lease_until", Value: 1}}},
+	})
+
+	r.changes.ensureIndexes(ctx)
 }
 
 // ProcessBatch syncs multiple offline transactions.
@@ -75,11 +180,31 @@ func (r *MongoSyncRepository) ProcessBatch(ctx context.Context, req domain.SyncB
 		return nil, errors.New("invalid business_id")
 	}
 
-	if err := r.ensureDeviceOwnership(ctx, businessObjID, req.DeviceID); err != nil {
+	if err := r.ensureDeviceRegistered(ctx, businessObjID, req.DeviceID); err != nil {
+		return nil, err
+	}
+
+	policy, err := r.conflictPolicy(ctx, businessObjID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.nextDeviceCursor(ctx, businessObjID, req.DeviceID)
+	if err != nil {
 		return nil, err
 	}
 
 	syncObjectID := primitive.NewObjectID()
+	batch := &syncBatch{
+		businessID:    businessObjID,
+		deviceID:      req.DeviceID,
+		userID:        req.UserID,
+		role:          req.Role,
+		requestID:     req.RequestID,
+		syncID:        syncObjectID.Hex(),
+		policy:        policy,
+		schemaVersion: req.SchemaVersion,
+	}
 	response := &domain.SyncBatchResponse{
 		SyncID:    syncObjectID.Hex(),
 		Status:    syncStatusCompleted,
@@ -88,6 +213,7 @@ func (r *MongoSyncRepository) ProcessBatch(ctx context.Context, req domain.SyncB
 		Summary: domain.SyncSummary{
 			Total: len(req.Transactions),
 		},
+		Cursor: cursor,
 	}
 
 	syncTimestamp := req.SyncTimestamp
@@ -95,10 +221,63 @@ func (r *MongoSyncRepository) ProcessBatch(ctx context.Context, req domain.SyncB
 		syncTimestamp = response.Timestamp
 	}
 
-	for _, tx := range req.Transactions {
+	logDoc := func() bson.M {
+		return bson.M{
+			"_id":            syncObjectID,
+			"business_id":    businessObjID,
+			"device_id":      req.DeviceID,
+			"sync_timestamp": syncTimestamp,
+			"status":         response.Status,
+			"results":        response.Results,
+			"summary":        response.Summary,
+			"cursor":         cursor,
+			"atomic":         req.Atomic,
+			"schema_version": req.SchemaVersion,
+			"created_at":     response.Timestamp,
+		}
+	}
+
+	switch {
+	case !req.Atomic:
+		r.applyTransactions(ctx, batch, req.Transactions, response)
+		r.queueRetries(ctx, batch, req.Transactions, response)
+		err = r.insertSyncLog(ctx, logDoc)
+	case r.topology.supportsTransactions():
+		err = r.applyInTransaction(ctx, batch, req.Transactions, response, logDoc)
+	default:
+		err = r.applyJournaled(ctx, batch, req.Transactions, response, logDoc)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Summary.Failed > 0 {
+		retryAfterSeconds := int(domain.DefaultSyncRetryPolicy.Backoff(1).Seconds())
+		response.RetryAfter = &retryAfterSeconds
+	}
+
+	return response, nil
+}
+
+// applyTransactions replays each transaction of a batch and fills in the
+// per-item results and summary.
+func (r *MongoSyncRepository) applyTransactions(ctx context.Context, batch *syncBatch, transactions []domain.SyncBatchTransaction, response *domain.SyncBatchResponse) {
+	for _, tx := range transactions {
 		result := domain.SyncItemResult{LocalID: tx.LocalID}
 
-		alreadySynced, existingServerID, checkErr := r.findExistingSynced(ctx, businessObjID, req.DeviceID, tx.LocalID, tx.Type)
+		if batch.journaled {
+			// Keep recovery off the journal while the batch is applied, and
+			// stop once it has taken the batch over
+			journalID, _ := primitive.ObjectIDFromHex(batch.syncID)
+			if err := r.touchJournal(ctx, journalID); err != nil {
+				failItem(&result, err)
+				response.Summary.Failed++
+				response.Results = append(response.Results, result)
+				continue
+			}
+		}
+
+		alreadySynced, existingServerID, checkErr := r.findExistingSynced(ctx, batch.businessID, batch.deviceID, tx.LocalID, tx.Type)
 		if checkErr != nil {
 			result.Status = "failed"
 			result.Message = checkErr.Error()
@@ -115,14 +294,30 @@ func (r *MongoSyncRepository) ProcessBatch(ctx context.Context, req domain.SyncB
 			continue
 		}
 
-		serverID, processErr := r.processSingleTransaction(ctx, businessObjID, req.DeviceID, tx)
-		if processErr != nil {
-			result.Status = "failed"
-			result.Message = processErr.Error()
+		if batch.journaled {
+			r.captureBeforeImage(ctx, batch, tx)
+		}
+
+		serverID, conflict, processErr := r.processSingleTransaction(ctx, batch, tx)
+		switch {
+		case processErr != nil:
+			failItem(&result, processErr)
 			response.Summary.Failed++
-		} else {
+		case conflict != nil && conflict.Resolution != domain.SyncResolutionClient:
+			result.Status = "conflict"
+			result.ServerID = serverID
+			result.Conflict = conflict
+			if conflict.Resolution == domain.SyncResolutionServer {
+				result.Message = "record changed on server (server wins)"
+			} else {
+				result.Message = "record changed on server, awaiting manual resolution"
+			}
+			response.Summary.Conflicts++
+		default:
 			result.Status = "success"
 			result.ServerID = serverID
+			result.Conflict = conflict
+			result.Applied = batch.applied
 			response.Summary.Success++
 		}
 		response.Results = append(response.Results, result)
@@ -131,29 +326,29 @@ func (r *MongoSyncRepository) ProcessBatch(ctx context.Context, req domain.SyncB
 	if response.Summary.Failed > 0 {
 		response.Status = syncStatusPartialSuccess
 	}
+}
 
-	logDoc := bson.M{
-		"_id":            syncObjectID,
-		"business_id":    businessObjID,
-		"device_id":      req.DeviceID,
-		"sync_timestamp": syncTimestamp,
-		"status":         response.Status,
-		"results":        response.Results,
-		"summary":        response.Summary,
-		"created_at":     response.Timestamp,
-	}
-	if _, insertErr := r.syncLogs.InsertOne(ctx, logDoc); insertErr != nil {
-		return nil, insertErr
-	}
+// failItem marks a result failed, carrying the item code and field errors the
+// device needs to fix the transaction.
+func failItem(result *domain.SyncItemResult, err error) {
+	result.Status = "failed"
+	result.Message = err.Error()
 
-	if response.Summary.Failed > 0 {
-		retryAfterSeconds := 30
-		response.RetryAfter = &retryAfterSeconds
+	var validationErr *domain.SyncValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		result.Code = domain.SyncItemCodeValidationFailed
+		result.Errors = validationErr.Fields
+	case errors.Is(err, domain.ErrInsufficientStock):
+		result.Code = domain.SyncItemCodeInsufficientStock
 	}
-
-	return response, nil
 }
 
+// insertSyncLog writes the log entry for a batch once its results are final.
+func (r *MongoSyncRepository) insertSyncLog(ctx context.Context, logDoc func() bson.M) error {
+	_, err := r.syncLogs.InsertOne(ctx, logDoc())
+	return err
+}
 
 // GetStatus returns the latest synchronization state for a business.
 func (r *MongoSyncRepository) GetStatus(ctx context.Context, businessID, deviceID string) (*domain.SyncStatusResponse, error) {
@@ -180,11 +375,17 @@ func (r *MongoSyncRepository) GetStatus(ctx context.Context, businessID, deviceI
 		} `bson:"summary"`
 	}
 
+	devices, err := r.getDeviceStatuses(ctx, businessObjID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
 	err = r.syncLogs.FindOne(ctx, query, opts).Decode(&latest)
 	if err == mongo.ErrNoDocuments {
 		return &domain.SyncStatusResponse{
 			BusinessID: businessID,
 			DeviceID:   deviceID,
+			Devices:    devices,
 		}, nil
 	}
 	if err != nil {
@@ -193,34 +394,27 @@ func (r *MongoSyncRepository) GetStatus(ctx context.Context, businessID, deviceI
 
 	dayAgo := time.Now().UTC().Add(-24 * time.Hour)
 	failedLast24h, err := r.syncLogs.CountDocuments(ctx, bson.M{
-		"business_id": businessObjID,
-		"created_at":  bson.M{"$gte": dayAgo},
+		"business_id":    businessObjID,
+		"created_at":     bson.M{"$gte": dayAgo},
 		"summary.failed": bson.M{"$gt": 0},
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	pendingRetryAgg := mongo.Pipeline{
-		{{Key: "$match", Value: bson.M{"business_id": businessObjID, "created_at": bson.M{"$gte": dayAgo}}}},
-		{{Key: "$unwind", Value: "$results"}},
-		{{Key: "$match", Value: bson.M{"results.status": "failed"}}},
-		{{Key: "$group", Value: bson.M{"_id": nil, "count": bson.M{"$sum": 1}}}},
+	retryQuery := bson.M{"business_id": businessObjID}
+	if strings.TrimSpace(deviceID) != "" {
+		retryQuery["device_id"] = deviceID
 	}
-	pendingCursor, err := r.syncLogs.Aggregate(ctx, pendingRetryAgg)
+	retryQuery["state"] = domain.SyncRetryPending
+	pendingRetries, err := r.retries.CountDocuments(ctx, retryQuery)
 	if err != nil {
 		return nil, err
 	}
-	defer pendingCursor.Close(ctx)
-
-	var pendingRetries int64
-	if pendingCursor.Next(ctx) {
-		var agg struct {
-			Count int64 `bson:"count"`
-		}
-		if decodeErr := pendingCursor.Decode(&agg); decodeErr == nil {
-			pendingRetries = agg.Count
-		}
+	retryQuery["state"] = domain.SyncRetryDeadLetter
+	deadLetters, err := r.retries.CountDocuments(ctx, retryQuery)
+	if err != nil {
+		return nil, err
 	}
 
 	totalSyncedAgg := mongo.Pipeline{
@@ -244,17 +438,111 @@ func (r *MongoSyncRepository) GetStatus(ctx context.Context, businessID, deviceI
 	}
 
 	return &domain.SyncStatusResponse{
-		BusinessID:    businessID,
-		DeviceID:      latest.DeviceID,
-		LastSyncAt:    latest.CreatedAt,
-		LastSyncID:    latest.ID.Hex(),
-		LastStatus:    latest.Status,
+		BusinessID:     businessID,
+		DeviceID:       latest.DeviceID,
+		LastSyncAt:     latest.CreatedAt,
+		LastSyncID:     latest.ID.Hex(),
+		LastStatus:     latest.Status,
 		PendingRetries: pendingRetries,
-		TotalSynced:   totalSynced,
-		FailedLast24h: failedLast24h,
+		DeadLetters:    deadLetters,
+		TotalSynced:    totalSynced,
+		FailedLast24h:  failedLast24h,
+		Devices:        devices,
 	}, nil
 }
 
+// getDeviceStatuses merges the business device registry with the latest
+// sync_log of each device. Devices that synced before the registry existed
+// are still reported, without a name or registry status.
+func (r *MongoSyncRepository) getDeviceStatuses(ctx context.Context, businessID primitive.ObjectID, deviceID string) ([]domain.SyncDeviceStatus, error) {
+	var business struct {
+		Devices []domain.SyncDevice `bson:"sync_devices"`
+	}
+	if err := r.business.FindOne(ctx, bson.M{"_id": businessID}).Decode(&business); err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	match := bson.M{"business_id": businessID}
+	if strings.TrimSpace(deviceID) != "" {
+		match["device_id"] = deviceID
+	}
+	dayAgo := time.Now().UTC().Add(-24 * time.Hour)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          "$device_id",
+			"last_sync_id": bson.M{"$first": "$_id"},
+			"last_status":  bson.M{"$first": "$status"},
+			"last_sync_at": bson.M{"$first": "$created_at"},
+			"cursor":       bson.M{"$max": "$cursor"},
+			"total_synced": bson.M{"$sum": "$summary.success"},
+			"failed_last_24h": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$and": bson.A{
+					bson.M{"$gte": bson.A{"$created_at", dayAgo}},
+					bson.M{"$gt": bson.A{"$summary.failed", 0}},
+				}},
+				1,
+				0,
+			}}},
+		}}},
+	}
+	cursor, err := r.syncLogs.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	byDevice := make(map[string]*domain.SyncDeviceStatus)
+	order := make([]string, 0)
+	for _, device := range business.Devices {
+		if deviceID != "" && device.DeviceID != deviceID {
+			continue
+		}
+		byDevice[device.DeviceID] = &domain.SyncDeviceStatus{
+			DeviceID: device.DeviceID,
+			Name:     device.Name,
+			Status:   device.Status,
+		}
+		order = append(order, device.DeviceID)
+	}
+
+	for cursor.Next(ctx) {
+		var agg struct {
+			DeviceID      string             `bson:"_id"`
+			LastSyncID    primitive.ObjectID `bson:"last_sync_id"`
+			LastStatus    string             `bson:"last_status"`
+			LastSyncAt    time.Time          `bson:"last_sync_at"`
+			Cursor        int64              `bson:"cursor"`
+			TotalSynced   int64              `bson:"total_synced"`
+			FailedLast24h int64              `bson:"failed_last_24h"`
+		}
+		if err := cursor.Decode(&agg); err != nil {
+			continue
+		}
+		status, ok := byDevice[agg.DeviceID]
+		if !ok {
+			status = &domain.SyncDeviceStatus{DeviceID: agg.DeviceID}
+			byDevice[agg.DeviceID] = status
+			order = append(order, agg.DeviceID)
+		}
+		lastSyncAt := agg.LastSyncAt
+		status.LastSyncAt = &lastSyncAt
+		status.LastSyncID = agg.LastSyncID.Hex()
+		status.LastStatus = agg.LastStatus
+		status.Cursor = agg.Cursor
+		status.TotalSynced = agg.TotalSynced
+		status.FailedLast24h = agg.FailedLast24h
+	}
+
+	devices := make([]domain.SyncDeviceStatus, 0, len(order))
+	for _, id := range order {
+		devices = append(devices, *byDevice[id])
+	}
+	return devices, nil
+}
+
 // GetHistory returns paginated synchronization logs for a business.
 func (r *MongoSyncRepository) GetHistory(ctx context.Context, businessID string, page, limit int) (*domain.SyncHistoryResponse, error) {
 	businessObjID, err := primitive.ObjectIDFromHex(businessID)
@@ -276,17 +564,38 @@ func (r *MongoSyncRepository) GetHistory(ctx context.Context, businessID string,
 	}
 	defer cursor.Close(ctx)
 
+	logs := decodeSyncLogs(ctx, cursor)
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	resp := &domain.SyncHistoryResponse{Data: logs}
+	resp.Pagination.CurrentPage = page
+	resp.Pagination.TotalPages = totalPages
+	resp.Pagination.TotalRecords = total
+	resp.Pagination.PerPage = limit
+	return resp, nil
+}
+
+// decodeSyncLogs converts stored sync log documents into their API shape,
+// skipping any that cannot be decoded.
+func decodeSyncLogs(ctx context.Context, cursor *mongo.Cursor) []domain.SyncLog {
 	logs := make([]domain.SyncLog, 0)
 	for cursor.Next(ctx) {
 		var doc struct {
-			ID            primitive.ObjectID   `bson:"_id"`
-			BusinessID    primitive.ObjectID   `bson:"business_id"`
-			DeviceID      string               `bson:"device_id"`
-			SyncTimestamp time.Time            `bson:"sync_timestamp"`
-			Status        string               `bson:"status"`
+			ID            primitive.ObjectID      `bson:"_id"`
+			BusinessID    primitive.ObjectID      `bson:"business_id"`
+			DeviceID      string                  `bson:"device_id"`
+			SyncTimestamp time.Time               `bson:"sync_timestamp"`
+			Status        string                  `bson:"status"`
 			Results       []domain.SyncItemResult `bson:"results"`
-			Summary       domain.SyncSummary   `bson:"summary"`
-			CreatedAt     time.Time            `bson:"created_at"`
+			Summary       domain.SyncSummary      `bson:"summary"`
+			Cursor        int64                   `bson:"cursor"`
+			Atomic        bool                    `bson:"atomic"`
+			Source        string                  `bson:"source"`
+			CreatedAt     time.Time               `bson:"created_at"`
 		}
 		if err := cursor.Decode(&doc); err != nil {
 			continue
@@ -299,246 +608,894 @@ func (r *MongoSyncRepository) GetHistory(ctx context.Context, businessID string,
 			Status:        doc.Status,
 			Results:       doc.Results,
 			Summary:       doc.Summary,
+			Cursor:        doc.Cursor,
+			Atomic:        doc.Atomic,
+			Source:        doc.Source,
 			CreatedAt:     doc.CreatedAt,
 		})
 	}
+	return logs
+}
 
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-	if totalPages < 1 {
-		totalPages = 1
+// PullChanges returns server-side changes for a device. Without a cursor, or
+// with a snapshot cursor, the current state of the business is returned as a
+// snapshot, a page of at most limit records at a time; otherwise only the
+// records touched by change feed entries past the cursor are loaded. The
+// returned cursor is the position the device should resume from.
+func (r *MongoSyncRepository) PullChanges(ctx context.Context, businessID, deviceID string, cursor *domain.SyncPullCursor, limit int) (*domain.SyncPullResponse, domain.SyncPullCursor, error) {
+	businessObjID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return nil, domain.SyncPullCursor{}, errors.New("invalid business_id")
 	}
 
-	resp := &domain.SyncHistoryResponse{Data: logs}
-	resp.Pagination.CurrentPage = page
-	resp.Pagination.TotalPages = totalPages
-	resp.Pagination.TotalRecords = total
-	resp.Pagination.PerPage = limit
-	return resp, nil
+	if err := r.ensureDeviceRegistered(ctx, businessObjID, deviceID); err != nil {
+		return nil, domain.SyncPullCursor{}, err
+	}
+
+	response := &domain.SyncPullResponse{
+		Sales:          []domain.Sale{},
+		Expenses:       []domain.Expense{},
+		Products:       []domain.Product{},
+		StockMovements: []domain.StockMovement{},
+		Voided:         []domain.SyncEntityRef{},
+		Deleted:        []domain.SyncEntityRef{},
+		ServerTime:     time.Now().UTC(),
+	}
+
+	if cursor == nil {
+		// Read the head first so that anything written during the snapshot is
+		// replayed once it is done rather than lost.
+		head, err := r.changes.head(ctx, businessObjID)
+		if err != nil {
+			return nil, domain.SyncPullCursor{}, err
+		}
+		cursor = &domain.SyncPullCursor{Seq: head, Snapshot: snapshotEntities[0]}
+	}
+	if cursor.Snapshot != "" {
+		next, err := r.loadSnapshot(ctx, businessObjID, *cursor, limit, response)
+		if err != nil {
+			return nil, domain.SyncPullCursor{}, err
+		}
+		return response, next, nil
+	}
+
+	entries, through, hasMore, err := r.changes.since(ctx, businessObjID, cursor.Seq, limit)
+	if err != nil {
+		return nil, domain.SyncPullCursor{}, err
+	}
+	response.HasMore = hasMore
+
+	latest := make(map[domain.ChangeEntity]map[primitive.ObjectID]domain.ChangeOperation)
+	for _, entry := range entries {
+		if latest[entry.Entity] == nil {
+			latest[entry.Entity] = make(map[primitive.ObjectID]domain.ChangeOperation)
+		}
+		latest[entry.Entity][entry.EntityID] = entry.Operation
+	}
+
+	for entity, ops := range latest {
+		upserts := make([]primitive.ObjectID, 0, len(ops))
+		for id, op := range ops {
+			switch op {
+			case domain.ChangeOperationVoid:
+				response.Voided = append(response.Voided, domain.SyncEntityRef{Entity: entity, ID: id.Hex()})
+			case domain.ChangeOperationDelete:
+				response.Deleted = append(response.Deleted, domain.SyncEntityRef{Entity: entity, ID: id.Hex()})
+			default:
+				upserts = append(upserts, id)
+			}
+		}
+		if len(upserts) == 0 {
+			continue
+		}
+		if err := r.loadChanged(ctx, entity, bson.M{"_id": bson.M{"$in": upserts}}, response); err != nil {
+			return nil, domain.SyncPullCursor{}, err
+		}
+	}
+
+	return response, domain.SyncPullCursor{Seq: through}, nil
+}
+
+// snapshotEntities are the entities a snapshot sends, in order.
+var snapshotEntities = []domain.ChangeEntity{
+	domain.ChangeEntitySale,
+	domain.ChangeEntityExpense,
+	domain.ChangeEntityProduct,
+	domain.ChangeEntityStockMovement,
+}
+
+// loadSnapshot loads the next page of a snapshot: up to limit records, in
+// _id order, starting after the cursor's record and going on to the next
+// entity when one runs out. It returns the cursor to resume from, which is
+// the feed head the snapshot started from once every entity is sent.
+func (r *MongoSyncRepository) loadSnapshot(ctx context.Context, businessID primitive.ObjectID, cursor domain.SyncPullCursor, limit int, response *domain.SyncPullResponse) (domain.SyncPullCursor, error) {
+	start := -1
+	for i, entity := range snapshotEntities {
+		if entity == cursor.Snapshot {
+			start = i
+		}
+	}
+	if start < 0 {
+		return domain.SyncPullCursor{}, domain.ErrInvalidSyncCursor
+	}
+
+	after := cursor.After
+	for _, entity := range snapshotEntities[start:] {
+		filter := bson.M{"business_id": businessID}
+		if entity == domain.ChangeEntitySale || entity == domain.ChangeEntityExpense {
+			filter["is_voided"] = false
+		}
+		if !after.IsZero() {
+			filter["_id"] = bson.M{"$gt": after}
+		}
+
+		var page []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		opts := options.Find().SetProjection(bson.M{"_id": 1}).SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit + 1))
+		if err := findAll(ctx, r.snapshotCollection(entity), filter, &page, opts); err != nil {
+			return domain.SyncPullCursor{}, err
+		}
+
+		more := len(page) > limit
+		if more {
+			page = page[:limit]
+		}
+		if len(page) > 0 {
+			ids := make([]primitive.ObjectID, len(page))
+			for i, doc := range page {
+				ids[i] = doc.ID
+			}
+			if err := r.loadChanged(ctx, entity, bson.M{"_id": bson.M{"$in": ids}}, response); err != nil {
+				return domain.SyncPullCursor{}, err
+			}
+			after = ids[len(ids)-1]
+		}
+
+		limit -= len(page)
+		if more || limit == 0 {
+			response.HasMore = true
+			return domain.SyncPullCursor{Seq: cursor.Seq, Snapshot: entity, After: after}, nil
+		}
+		after = primitive.NilObjectID
+	}
+	return domain.SyncPullCursor{Seq: cursor.Seq}, nil
 }
 
-func (r *MongoSyncRepository) ensureDeviceOwnership(ctx context.Context, businessID primitive.ObjectID, deviceID string) error {
+func (r *MongoSyncRepository) snapshotCollection(entity domain.ChangeEntity) *mongo.Collection {
+	switch entity {
+	case domain.ChangeEntitySale:
+		return r.sales
+	case domain.ChangeEntityExpense:
+		return r.expenses
+	case domain.ChangeEntityProduct:
+		return r.products
+	}
+	return r.movements
+}
+
+func (r *MongoSyncRepository) loadChanged(ctx context.Context, entity domain.ChangeEntity, filter bson.M, response *domain.SyncPullResponse) error {
+	switch entity {
+	case domain.ChangeEntitySale:
+		var sales []domain.Sale
+		if err := findAll(ctx, r.sales, filter, &sales); err != nil {
+			return err
+		}
+		normalizeSaleLines(sales)
+		response.Sales = append(response.Sales, sales...)
+	case domain.ChangeEntityExpense:
+		var expenses []domain.Expense
+		if err := findAll(ctx, r.expenses, filter, &expenses); err != nil {
+			return err
+		}
+		response.Expenses = append(response.Expenses, expenses...)
+	case domain.ChangeEntityProduct:
+		var products []domain.Product
+		if err := findAll(ctx, r.products, filter, &products); err != nil {
+			return err
+		}
+		response.Products = append(response.Products, products...)
+	case domain.ChangeEntityStockMovement:
+		var movements []domain.StockMovement
+		if err := findAll(ctx, r.movements, filter, &movements); err != nil {
+			return err
+		}
+		response.StockMovements = append(response.StockMovements, movements...)
+	}
+	return nil
+}
+
+func findAll(ctx context.Context, collection *mongo.Collection, filter bson.M, results interface{}, opts ...*options.FindOptions) error {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, results)
+}
+
+// ensureDeviceRegistered checks that the device is an active member of the
+// business device registry. Businesses without a registry (created before
+// multi-device sync) are bootstrapped with their legacy sync_device_id, or
+// with the calling device when no device has synced yet.
+func (r *MongoSyncRepository) ensureDeviceRegistered(ctx context.Context, businessID primitive.ObjectID, deviceID string) error {
 	var existing struct {
-		SyncDeviceID string `bson:"sync_device_id"`
+		UserID       primitive.ObjectID  `bson:"user_id"`
+		SyncDeviceID string              `bson:"sync_device_id"`
+		Devices      []domain.SyncDevice `bson:"sync_devices"`
 	}
 	err := r.business.FindOne(ctx, bson.M{"_id": businessID}).Decode(&existing)
 	if err != nil {
 		return err
 	}
 
-	if strings.TrimSpace(existing.SyncDeviceID) != "" && existing.SyncDeviceID != deviceID {
-		return errors.New("device conflict for business")
+	if len(existing.Devices) == 0 {
+		bootstrapID := strings.TrimSpace(existing.SyncDeviceID)
+		if bootstrapID == "" {
+			bootstrapID = deviceID
+		}
+		now := time.Now().UTC()
+		existing.Devices = []domain.SyncDevice{{
+			DeviceID:     bootstrapID,
+			Status:       domain.DeviceStatusActive,
+			RegisteredBy: existing.UserID,
+			RegisteredAt: now,
+		}}
+		result, err := r.business.UpdateOne(
+			ctx,
+			bson.M{"_id": businessID, "$or": bson.A{bson.M{"sync_devices": bson.M{"$exists": false}}, bson.M{"sync_devices": bson.M{"$size": 0}}}},
+			bson.M{"$set": bson.M{"sync_devices": existing.Devices, "updated_at": now}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			// Another device registered first; check against the list it wrote
+			var current struct {
+				Devices []domain.SyncDevice `bson:"sync_devices"`
+			}
+			if err := r.business.FindOne(ctx, bson.M{"_id": businessID}).Decode(&current); err != nil {
+				return err
+			}
+			existing.Devices = current.Devices
+		}
 	}
 
-	_, err = r.business.UpdateOne(
-		ctx,
-		bson.M{"_id": businessID, "$or": bson.A{bson.M{"sync_device_id": bson.M{"$exists": false}}, bson.M{"sync_device_id": ""}, bson.M{"sync_device_id": deviceID}}},
-		bson.M{"$set": bson.M{"sync_device_id": deviceID, "updated_at": time.Now().UTC()}},
-	)
+	for _, device := range existing.Devices {
+		if device.DeviceID != deviceID {
+			continue
+		}
+		if !device.IsActive() {
+			return domain.ErrDeviceRevoked
+		}
+		return nil
+	}
+	return domain.ErrDeviceNotRegistered
+}
+
+// nextDeviceCursor allocates the next per-device sync cursor from a counter,
+// so concurrent batches never share one. A device without a counter yet has
+// it seeded from the highest cursor recorded in sync_logs.
+func (r *MongoSyncRepository) nextDeviceCursor(ctx context.Context, businessID primitive.ObjectID, deviceID string) (int64, error) {
+	counterID := "sync_cursor:" + businessID.Hex() + ":" + deviceID
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	inc := bson.M{"$inc": bson.M{"seq": int64(1)}}
+
+	err := r.counters.FindOneAndUpdate(ctx, bson.M{"_id": counterID}, inc, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&counter)
+	if err == nil {
+		return counter.Seq, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	var last struct {
+		Cursor int64 `bson:"cursor"`
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "cursor", Value: -1}})
+	err = r.syncLogs.FindOne(ctx, bson.M{"business_id": businessID, "device_id": deviceID}, opts).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+	// $max keeps the seed idempotent when two batches seed at once
+	_, err = r.counters.UpdateOne(ctx, bson.M{"_id": counterID}, bson.M{"$max": bson.M{"seq": last.Cursor}}, options.Update().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return 0, err
+	}
+
+	err = r.counters.FindOneAndUpdate(ctx, bson.M{"_id": counterID}, inc, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&counter)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return counter.Seq, nil
 }
 
 func (r *MongoSyncRepository) findExistingSynced(ctx context.Context, businessID primitive.ObjectID, deviceID, localID string, txType domain.SyncTransactionType) (bool, string, error) {
 	query := bson.M{"business_id": businessID, "device_id": deviceID, "local_id": localID}
 	var existing struct {
-		ID primitive.ObjectID `bson:"_id"`
+		ID       primitive.ObjectID `bson:"_id"`
+		ServerID string             `bson:"server_id"`
 	}
 
+	// A receipt exists for applied mutations and for any change held back by a conflict.
+	err := r.operations.FindOne(ctx, query).Decode(&existing)
+	if err == nil {
+		return true, existing.ServerID, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return false, "", err
+	}
+
+	var collection *mongo.Collection
 	switch txType {
 	case domain.SyncTransactionTypeSale:
-		err := r.sales.FindOne(ctx, query).Decode(&existing)
-		if err == mongo.ErrNoDocuments {
-			return false, "", nil
-		}
-		if err != nil {
-			return false, "", err
+		collection = r.sales
+	case domain.SyncTransactionTypeExpense:
+		collection = r.expenses
+	case domain.SyncTransactionTypeProduct:
+		collection = r.products
+	case domain.SyncTransactionTypeStockAdjustment:
+		collection = r.movements
+	case domain.SyncTransactionTypeSaleVoid, domain.SyncTransactionTypeExpenseUpdate:
+		return false, "", nil
+	default:
+		return false, "", errors.New("unsupported transaction type")
+	}
+
+	err = collection.FindOne(ctx, query).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	if existing.ServerID != "" {
+		return true, existing.ServerID, nil
+	}
+	return true, existing.ID.Hex(), nil
+}
+
+// syncBatch carries the state shared by every transaction replayed in a batch.
+type syncBatch struct {
+	businessID primitive.ObjectID
+	deviceID   string
+	userID     string
+	role       domain.BusinessRole
+	requestID  string
+	syncID     string
+	policy     domain.SyncConflictPolicy
+	// schemaVersion selects how transaction data is decoded
+	schemaVersion int
+	// force skips the version check; set when a conflict is resolved for the client
+	force bool
+	// journaled records before-images so an atomic batch can be rolled back
+	journaled bool
+	// applied is what the last transaction changed, for the audit log
+	applied *domain.SyncAppliedChange
+}
+
+func (r *MongoSyncRepository) processSingleTransaction(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction) (string, *domain.SyncConflictInfo, error) {
+	b.applied = nil
+	switch tx.Type {
+	case domain.SyncTransactionTypeSale:
+		var payload domain.SaleSyncPayload
+		if err := domain.DecodeSyncPayload(b.schemaVersion, tx.Data, &payload); err != nil {
+			return "", nil, err
 		}
-		return true, existing.ID.Hex(), nil
+		serverID, err := r.syncSale(ctx, b, tx, &payload)
+		return serverID, nil, err
 	case domain.SyncTransactionTypeExpense:
-		err := r.expenses.FindOne(ctx, query).Decode(&existing)
-		if err == mongo.ErrNoDocuments {
-			return false, "", nil
+		var payload domain.ExpenseSyncPayload
+		if err := domain.DecodeSyncPayload(b.schemaVersion, tx.Data, &payload); err != nil {
+			return "", nil, err
 		}
-		if err != nil {
-			return false, "", err
+		serverID, err := r.syncExpense(ctx, b, tx, &payload)
+		return serverID, nil, err
+	case domain.SyncTransactionTypeProduct:
+		var payload domain.ProductSyncPayload
+		if err := domain.DecodeSyncPayload(b.schemaVersion, tx.Data, &payload); err != nil {
+			return "", nil, err
+		}
+		serverID, err := r.syncProduct(ctx, b, tx, &payload)
+		return serverID, nil, err
+	case domain.SyncTransactionTypeStockAdjustment:
+		var payload domain.StockAdjustmentSyncPayload
+		if err := domain.DecodeSyncPayload(b.schemaVersion, tx.Data, &payload); err != nil {
+			return "", nil, err
 		}
-		return true, existing.ID.Hex(), nil
+		return r.syncStockAdjustment(ctx, b, tx, &payload)
+	case domain.SyncTransactionTypeSaleVoid:
+		var payload domain.SaleVoidSyncPayload
+		if err := domain.DecodeSyncPayload(b.schemaVersion, tx.Data, &payload); err != nil {
+			return "", nil, err
+		}
+		return r.syncSaleVoid(ctx, b, tx, &payload)
+	case domain.SyncTransactionTypeExpenseUpdate:
+		var payload domain.ExpenseUpdateSyncPayload
+		if err := domain.DecodeSyncPayload(b.schemaVersion, tx.Data, &payload); err != nil {
+			return "", nil, err
+		}
+		return r.syncExpenseUpdate(ctx, b, tx, &payload)
 	default:
-		return false, "", errors.New("unsupported transaction type")
+		return "", nil, errors.New("unsupported transaction type")
 	}
 }
 
-func (r *MongoSyncRepository) processSingleTransaction(ctx context.Context, businessID primitive.ObjectID, deviceID string, tx domain.SyncBatchTransaction) (string, error) {
-	createdAt, err := parseTimeField(tx.Data, "created_at")
+func (r *MongoSyncRepository) syncSale(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction, p *domain.SaleSyncPayload) (string, error) {
+	lines, err := r.syncedSaleLines(ctx, b, p)
 	if err != nil {
 		return "", err
 	}
+	saleID := primitive.NewObjectID()
 
-	switch tx.Type {
-	case domain.SyncTransactionTypeSale:
-		amount, err := parseAmountField(tx.Data, "amount")
-		if err != nil {
-			return "", err
+	// Take the stock out first, line by line, so a sale the shop could not
+	// have made is rejected before it is recorded.
+	var taken []domain.StockMovement
+	for i := range lines {
+		line := &lines[i]
+		if line.ProductID == nil {
+			continue
 		}
-		quantity, err := parseIntField(tx.Data, "quantity")
+		movement, err := r.ledger.adjust(ctx, *line.ProductID, line.Quantity, domain.MovementTypeSale, "Sale transaction", &saleID, syncActor(b.userID), nil, bson.M{"sync_id": b.syncID})
 		if err != nil {
+			r.revertSaleStock(ctx, saleID, taken)
+			if len(lines) > 1 {
+				return "", fmt.Errorf("line %d: %w", i+1, err)
+			}
 			return "", err
 		}
-		if quantity <= 0 {
-			return "", errors.New("sale quantity must be > 0")
-		}
+		line.Cost = movement.Cost
+		taken = append(taken, movement)
+	}
 
-		var productID *primitive.ObjectID
-		if productIDRaw, ok := tx.Data["product_id"]; ok {
-			productIDStr, ok := productIDRaw.(string)
-			if ok && strings.TrimSpace(productIDStr) != "" {
-				parsedProductID, parseErr := primitive.ObjectIDFromHex(productIDStr)
-				if parseErr != nil {
-					return "", errors.New("invalid product_id")
-				}
-				productID = &parsedProductID
-			}
-		}
+	doc := bson.M{
+		"_id":         saleID,
+		"business_id": b.businessID,
+		"lines":       lines,
+		"total":       p.Total,
+		"created_at":  p.Created,
+		"is_voided":   false,
+		"version":     1,
+		"local_id":    tx.LocalID,
+		"device_id":   b.deviceID,
+		"sync_id":     b.syncID,
+		"synced_at":   time.Now().UTC(),
+	}
+	if p.Note != "" {
+		doc["note"] = p.Note
+	}
+	if _, err := r.sales.InsertOne(ctx, doc); err != nil {
+		r.revertSaleStock(ctx, saleID, taken)
+		return "", err
+	}
+	r.changes.record(ctx, b.businessID, domain.ChangeEntitySale, saleID, domain.ChangeOperationUpsert)
+	return saleID.Hex(), nil
+}
 
-		unitPrice := amount.Div(decimal.NewFromInt(int64(quantity)))
-		saleID := primitive.NewObjectID()
-		doc := bson.M{
-			"_id":        saleID,
-			"business_id": businessID,
-			"product_id": productID,
-			"unit_price": unitPrice,
-			"quantity":   quantity,
-			"total":      amount,
-			"created_at": createdAt,
-			"is_voided":  false,
-			"local_id":   tx.LocalID,
-			"device_id":  deviceID,
-			"synced_at":  time.Now().UTC(),
-		}
-		if _, err := r.sales.InsertOne(ctx, doc); err != nil {
-			return "", err
+// syncedSaleLines builds the lines of a synced sale, resolving each product
+// and checking it belongs to the business. A single-product payload becomes
+// one line.
+func (r *MongoSyncRepository) syncedSaleLines(ctx context.Context, b *syncBatch, p *domain.SaleSyncPayload) ([]domain.SaleLine, error) {
+	if len(p.Lines) == 0 {
+		productID, err := r.syncedSaleProduct(ctx, b, p.Product())
+		if err != nil {
+			return nil, err
 		}
-		return saleID.Hex(), nil
+		quantity := p.Quantity.Decimal
+		unitPrice := p.Total.Div(quantity)
+		return []domain.SaleLine{{ProductID: productID, Quantity: quantity, UnitPrice: unitPrice, Total: p.Total}}, nil
+	}
 
-	case domain.SyncTransactionTypeExpense:
-		amount, err := parseAmountField(tx.Data, "amount")
+	lines := make([]domain.SaleLine, 0, len(p.Lines))
+	for i := range p.Lines {
+		line := &p.Lines[i]
+		productID, err := r.syncedSaleProduct(ctx, b, line.Product())
 		if err != nil {
-			return "", err
-		}
-		categoryRaw, ok := tx.Data["category"].(string)
-		if !ok || strings.TrimSpace(categoryRaw) == "" {
-			return "", errors.New("expense category is required")
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
 		}
-		category := strings.ToUpper(strings.TrimSpace(categoryRaw))
-		if !domain.IsValidExpenseCategory(category) {
-			return "", errors.New("invalid expense category")
+		lines = append(lines, domain.NewSaleLine(productID, line.Units, line.Price, line.DiscountValue))
+	}
+	return lines, nil
+}
+
+// syncedSaleProduct resolves the optional product of a synced sale line.
+func (r *MongoSyncRepository) syncedSaleProduct(ctx context.Context, b *syncBatch, ref domain.SyncRef) (*primitive.ObjectID, error) {
+	if !ref.IsSet() {
+		return nil, nil
+	}
+	productID, err := r.resolveSyncedRef(ctx, r.products, b.businessID, b.deviceID, ref, "product_id", "product_local_id")
+	if err != nil {
+		return nil, err
+	}
+	var product domain.Product
+	err = r.products.FindOne(ctx, bson.M{"_id": productID}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("specified product does not exist")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find product: %w", err)
+	}
+	if product.BusinessID != b.businessID {
+		return nil, errors.New("product does not belong to this business")
+	}
+	return &productID, nil
+}
+
+// revertSaleStock puts back stock taken for a sale that was not recorded.
+func (r *MongoSyncRepository) revertSaleStock(ctx context.Context, saleID primitive.ObjectID, taken []domain.StockMovement) {
+	for i := len(taken) - 1; i >= 0; i-- {
+		if err := r.ledger.revert(ctx, taken[i]); err != nil {
+			fmt.Printf("WARNING: failed to return stock for unsynced sale %s: %v\n", saleID.Hex(), err)
 		}
+	}
+}
+
+func (r *MongoSyncRepository) syncExpense(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction, p *domain.ExpenseSyncPayload) (string, error) {
+	expenseID := primitive.NewObjectID()
+	doc := bson.M{
+		"_id":         expenseID,
+		"business_id": b.businessID,
+		"category":    p.Category,
+		"amount":      p.Value,
+		"note":        p.Note,
+		"created_at":  p.Created,
+		"is_voided":   false,
+		"version":     1,
+		"local_id":    tx.LocalID,
+		"device_id":   b.deviceID,
+		"sync_id":     b.syncID,
+		"synced_at":   time.Now().UTC(),
+	}
+	if _, err := r.expenses.InsertOne(ctx, doc); err != nil {
+		return "", err
+	}
+	r.changes.record(ctx, b.businessID, domain.ChangeEntityExpense, expenseID, domain.ChangeOperationUpsert)
+	return expenseID.Hex(), nil
+}
 
-		note := ""
-		if description, ok := tx.Data["description"].(string); ok {
-			note = description
-		} else if altNote, ok := tx.Data["note"].(string); ok {
-			note = altNote
+// syncProduct replays a product created offline, including its opening stock movement.
+func (r *MongoSyncRepository) syncProduct(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction, p *domain.ProductSyncPayload) (string, error) {
+	createdAt := p.Created
+	product := domain.Product{
+		ID:                  primitive.NewObjectID(),
+		BusinessID:          b.businessID,
+		Name:                strings.TrimSpace(p.Name),
+		SKU:                 p.SKU,
+		Barcode:             p.Barcode,
+		Category:            p.Category,
+		UnitOfMeasure:       p.Unit,
+		Attributes:          p.Attributes,
+		DefaultSellingPrice: p.Price,
+		UnitCost:            p.Cost,
+		StockQuantity:       p.StockQuantity.Decimal,
+		LowStockThreshold:   p.LowStockThreshold.Decimal,
+	}
+	if p.Parent().IsSet() {
+		parent, err := r.syncedParent(ctx, b, p.Parent())
+		if err != nil {
+			return "", domain.CatalogSyncError(err)
 		}
+		// A variant is sold and counted like its product
+		product.ParentID = parent.ID
+		product.Category = parent.Category
+		product.UnitOfMeasure = parent.UnitOfMeasure
+	}
+	if err := product.NormalizeCatalog(); err != nil {
+		return "", domain.CatalogSyncError(err)
+	}
+	productID := product.ID
 
-		expenseID := primitive.NewObjectID()
-		doc := bson.M{
-			"_id":         expenseID,
-			"business_id": businessID,
-			"category":    category,
-			"amount":      amount,
-			"note":        note,
-			"created_at":  createdAt,
-			"is_voided":   false,
-			"local_id":    tx.LocalID,
-			"device_id":   deviceID,
-			"synced_at":   time.Now().UTC(),
+	doc := bson.M{
+		"_id":                   productID,
+		"business_id":           b.businessID,
+		"name":                  product.Name,
+		"default_selling_price": product.DefaultSellingPrice,
+		"unit_cost":             product.UnitCost,
+		"stock_quantity":        product.StockQuantity,
+		"low_stock_threshold":   product.LowStockThreshold,
+		"created_at":            createdAt,
+		"updated_at":            time.Now().UTC(),
+		"version":               1,
+		"local_id":              tx.LocalID,
+		"device_id":             b.deviceID,
+		"sync_id":               b.syncID,
+		"synced_at":             time.Now().UTC(),
+	}
+	// Empty codes are left out so the unique indexes skip them
+	for field, value := range map[string]string{"sku": product.SKU, "barcode": product.Barcode, "category": product.Category, "unit": string(product.UnitOfMeasure)} {
+		if value != "" {
+			doc[field] = value
 		}
-		if _, err := r.expenses.InsertOne(ctx, doc); err != nil {
-			return "", err
+	}
+	if product.IsVariant() {
+		doc["parent_id"] = product.ParentID
+		doc["attributes"] = product.Attributes
+	}
+	_, err := r.products.InsertOne(ctx, doc)
+	if conflict := catalogConflict(err); conflict != nil {
+		return "", domain.CatalogSyncError(conflict)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The opening stock goes through the ledger like a product created over
+	// HTTP. A product whose stock has no movement is not kept: the item fails
+	// so the device sends it again.
+	if _, err := r.ledger.openingStock(ctx, product, syncActor(b.userID), bson.M{"created_at": createdAt, "sync_id": b.syncID}); err != nil {
+		if mongo.SessionFromContext(ctx) == nil {
+			if _, undoErr := r.products.DeleteOne(ctx, bson.M{"_id": productID}); undoErr != nil {
+				fmt.Printf("WARNING: product %s was synced without its initial stock movement: %v\n", productID.Hex(), undoErr)
+			}
 		}
-		return expenseID.Hex(), nil
+		return "", err
 	}
+	r.changes.record(ctx, b.businessID, domain.ChangeEntityProduct, productID, domain.ChangeOperationUpsert)
 
-	return "", errors.New("unsupported transaction type")
+	return productID.Hex(), nil
 }
 
-func parseAmountField(data map[string]interface{}, key string) (decimal.Decimal, error) {
-	raw, exists := data[key]
-	if !exists {
-		return decimal.Zero, errors.New(key + " is required")
+// syncedParent resolves the product a synced variant belongs to and checks it
+// is a product of the business that is not itself a variant.
+func (r *MongoSyncRepository) syncedParent(ctx context.Context, b *syncBatch, ref domain.SyncRef) (*domain.Product, error) {
+	parentID, err := r.resolveSyncedRef(ctx, r.products, b.businessID, b.deviceID, ref, "parent_id", "parent_local_id")
+	if err != nil {
+		return nil, err
 	}
-	switch v := raw.(type) {
-	case float64:
-		if v < 0 {
-			return decimal.Zero, errors.New(key + " cannot be negative")
+	var parent domain.Product
+	err = r.products.FindOne(ctx, bson.M{"_id": parentID, "business_id": b.businessID}).Decode(&parent)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("parent product not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find product: %w", err)
+	}
+	if parent.IsVariant() {
+		return nil, domain.ErrNestedVariant
+	}
+	return &parent, nil
+}
+
+// syncStockAdjustment replays an AdjustStock call made offline. The product may
+// be referenced by its server id or by the local_id it was synced with.
+func (r *MongoSyncRepository) syncStockAdjustment(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction, p *domain.StockAdjustmentSyncPayload) (string, *domain.SyncConflictInfo, error) {
+	productID, err := r.resolveSyncedRef(ctx, r.products, b.businessID, b.deviceID, p.Product(), "product_id", "product_local_id")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var product domain.Product
+	if err := r.products.FindOne(ctx, bson.M{"_id": productID, "business_id": b.businessID}).Decode(&product); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", nil, errors.New("product not found")
 		}
-		return decimal.NewFromFloat(v), nil
-	case int:
-		if v < 0 {
-			return decimal.Zero, errors.New(key + " cannot be negative")
+		return "", nil, err
+	}
+
+	apply, conflict, err := r.checkConflict(ctx, b, tx, p.SyncMutation, domain.ChangeEntityProduct, productID, product.Version, product.UpdatedAt, product)
+	if err != nil {
+		return "", nil, err
+	}
+	if !apply {
+		if err := r.recordOperation(ctx, b, tx, productID); err != nil {
+			return "", nil, err
 		}
-		return decimal.NewFromInt(int64(v)), nil
-	case int32:
-		if v < 0 {
-			return decimal.Zero, errors.New(key + " cannot be negative")
+		return productID.Hex(), conflict, nil
+	}
+
+	movement, err := r.ledger.adjust(ctx, productID, p.Quantity.Decimal, p.MovementType, p.Reason, nil, syncActor(b.userID), p.Cost, bson.M{
+		"created_at": p.Created,
+		"local_id":   tx.LocalID,
+		"device_id":  b.deviceID,
+		"sync_id":    b.syncID,
+		"synced_at":  time.Now().UTC(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	after := product
+	if err := r.products.FindOne(ctx, bson.M{"_id": productID}).Decode(&after); err != nil {
+		after = product
+	}
+	b.applied = &domain.SyncAppliedChange{EntityID: productID.Hex(), Before: product, After: after}
+
+	if b.force {
+		// Point the receipt left by the held-back change at the new movement
+		if err := r.recordOperation(ctx, b, tx, movement.ID); err != nil {
+			return "", nil, err
 		}
-		return decimal.NewFromInt(int64(v)), nil
-	case int64:
-		if v < 0 {
-			return decimal.Zero, errors.New(key + " cannot be negative")
+	}
+	return movement.ID.Hex(), conflict, nil
+}
+
+// syncSaleVoid replays a sale void. Stock is only returned when the sale
+// actually took stock out, mirroring the online VoidSale flow.
+func (r *MongoSyncRepository) syncSaleVoid(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction, p *domain.SaleVoidSyncPayload) (string, *domain.SyncConflictInfo, error) {
+	saleID, err := r.resolveSyncedRef(ctx, r.sales, b.businessID, b.deviceID, p.Sale(), "sale_id", "sale_local_id")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var current domain.Sale
+	err = r.sales.FindOne(ctx, bson.M{"_id": saleID, "business_id": b.businessID}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		return "", nil, errors.New("sale not found")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if current.IsVoided {
+		return "", nil, errors.New("sale is already voided")
+	}
+	current.NormalizeLines()
+
+	apply, conflict, err := r.checkConflict(ctx, b, tx, p.SyncMutation, domain.ChangeEntitySale, saleID, current.Version, lastWrite(current.UpdatedAt, current.CreatedAt), current)
+	if err != nil {
+		return "", nil, err
+	}
+	if !apply {
+		if err := r.recordOperation(ctx, b, tx, saleID); err != nil {
+			return "", nil, err
 		}
-		return decimal.NewFromInt(v), nil
-	case string:
-		d, err := decimal.NewFromString(v)
-		if err != nil {
-			return decimal.Zero, errors.New("invalid " + key)
+		return saleID.Hex(), conflict, nil
+	}
+
+	var voided domain.Sale
+	err = r.sales.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": saleID, "business_id": b.businessID, "is_voided": false},
+		bson.M{"$set": bson.M{"is_voided": true, "updated_at": time.Now().UTC()}, "$inc": bson.M{"version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&voided)
+	if err == mongo.ErrNoDocuments {
+		return "", nil, errors.New("sale is already voided")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	voided.NormalizeLines()
+	b.applied = &domain.SyncAppliedChange{EntityID: saleID.Hex(), Before: current, After: voided}
+	r.changes.record(ctx, b.businessID, domain.ChangeEntitySale, saleID, domain.ChangeOperationVoid)
+
+	// Return the stock each line took, as recorded by its movements, at the
+	// cost it was taken out at
+	var taken []domain.StockMovement
+	if err := findAll(ctx, r.movements, bson.M{"reference_id": saleID, "type": domain.MovementTypeSale}, &taken); err != nil {
+		fmt.Printf("WARNING: failed to find stock taken by voided sale %s: %v\n", saleID.Hex(), err)
+	}
+	for _, movement := range taken {
+		var unitCost *decimal.Decimal
+		if !movement.UnitCost.IsZero() {
+			unitCost = &movement.UnitCost
 		}
-		if d.LessThan(decimal.Zero) {
-			return decimal.Zero, errors.New(key + " cannot be negative")
+		_, err := r.ledger.adjust(ctx, movement.ProductID, movement.Quantity.Neg(), domain.MovementTypeReturn, "Sale voided – stock returned", &saleID, syncActor(b.userID), unitCost, bson.M{"sync_id": b.syncID})
+		if err != nil {
+			fmt.Printf("WARNING: failed to reverse inventory for voided sale %s: %v\n", saleID.Hex(), err)
 		}
-		return d, nil
-	default:
-		return decimal.Zero, errors.New("invalid " + key)
 	}
+
+	if err := r.recordOperation(ctx, b, tx, saleID); err != nil {
+		return "", nil, err
+	}
+	return saleID.Hex(), conflict, nil
 }
 
-func parseIntField(data map[string]interface{}, key string) (int, error) {
-	raw, exists := data[key]
-	if !exists {
-		return 0, errors.New(key + " is required")
+// syncExpenseUpdate replays an expense edit made offline.
+func (r *MongoSyncRepository) syncExpenseUpdate(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction, p *domain.ExpenseUpdateSyncPayload) (string, *domain.SyncConflictInfo, error) {
+	expenseID, err := r.resolveSyncedRef(ctx, r.expenses, b.businessID, b.deviceID, p.Expense(), "expense_id", "expense_local_id")
+	if err != nil {
+		return "", nil, err
+	}
+
+	set := bson.M{}
+	if p.Amount != nil {
+		set["amount"] = p.Value
+	}
+	if p.Category != nil {
+		set["category"] = *p.Category
+	}
+	if p.Note != nil {
+		set["note"] = *p.Note
+	}
+
+	var expense domain.Expense
+	err = r.expenses.FindOne(ctx, bson.M{"_id": expenseID, "business_id": b.businessID}).Decode(&expense)
+	if err == mongo.ErrNoDocuments {
+		return "", nil, domain.ErrExpenseNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if expense.IsVoided {
+		return "", nil, domain.ErrCannotUpdateVoided
+	}
+
+	apply, conflict, err := r.checkConflict(ctx, b, tx, p.SyncMutation, domain.ChangeEntityExpense, expenseID, expense.Version, lastWrite(expense.UpdatedAt, expense.CreatedAt), expense)
+	if err != nil {
+		return "", nil, err
 	}
-	switch v := raw.(type) {
-	case float64:
-		if v != math.Trunc(v) {
-			return 0, errors.New(key + " must be a whole number")
+	if apply {
+		set["updated_at"] = time.Now().UTC()
+		update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+		var updated domain.Expense
+		err := r.expenses.FindOneAndUpdate(ctx, bson.M{"_id": expenseID, "is_voided": false}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			return "", nil, domain.ErrCannotUpdateVoided
 		}
-		return int(v), nil
-	case int:
-		return v, nil
-	case int32:
-		return int(v), nil
-	case int64:
-		return int(v), nil
-	default:
-		return 0, errors.New("invalid " + key)
+		if err != nil {
+			return "", nil, err
+		}
+		b.applied = &domain.SyncAppliedChange{EntityID: expenseID.Hex(), Before: expense, After: updated}
+		r.changes.record(ctx, b.businessID, domain.ChangeEntityExpense, expenseID, domain.ChangeOperationUpsert)
 	}
+
+	if err := r.recordOperation(ctx, b, tx, expenseID); err != nil {
+		return "", nil, err
+	}
+	return expenseID.Hex(), conflict, nil
+}
+
+// recordOperation stores a receipt for a mutation so a replayed local_id is
+// reported as already_synced instead of being applied twice. Receipts are
+// upserted because a conflict resolved for the client re-applies the change.
+func (r *MongoSyncRepository) recordOperation(ctx context.Context, b *syncBatch, tx domain.SyncBatchTransaction, targetID primitive.ObjectID) error {
+	_, err := r.operations.UpdateOne(
+		ctx,
+		bson.M{"business_id": b.businessID, "device_id": b.deviceID, "local_id": tx.LocalID},
+		bson.M{"$set": bson.M{
+			"type":       tx.Type,
+			"server_id":  targetID.Hex(),
+			"sync_id":    b.syncID,
+			"applied_at": time.Now().UTC(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
 }
 
-func parseTimeField(data map[string]interface{}, key string) (time.Time, error) {
-	raw, exists := data[key]
-	if !exists {
-		return time.Time{}, errors.New(key + " is required")
+// resolveSyncedRef returns the server id of ref, looking up the record this
+// device synced under ref.LocalID when no server id is given. idKey and
+// localKey name the payload fields in error messages.
+func (r *MongoSyncRepository) resolveSyncedRef(ctx context.Context, collection *mongo.Collection, businessID primitive.ObjectID, deviceID string, ref domain.SyncRef, idKey, localKey string) (primitive.ObjectID, error) {
+	if raw := strings.TrimSpace(ref.ID); raw != "" {
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			return primitive.NilObjectID, errors.New("invalid " + idKey)
+		}
+		return id, nil
+	}
+
+	localID := strings.TrimSpace(ref.LocalID)
+	if localID == "" {
+		return primitive.NilObjectID, errors.New(idKey + " or " + localKey + " is required")
+	}
+
+	var existing struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := collection.FindOne(ctx, bson.M{
+		"business_id": businessID,
+		"device_id":   deviceID,
+		"local_id":    localID,
+	}).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, errors.New(localKey + " has not been synced")
 	}
-	str, ok := raw.(string)
-	if !ok || strings.TrimSpace(str) == "" {
-		return time.Time{}, errors.New("invalid " + key)
+	if err != nil {
+		return primitive.NilObjectID, err
 	}
-	parsed, err := time.Parse(time.RFC3339, str)
+	return existing.ID, nil
+}
+
+// syncActor converts the authenticated user id into the created_by value used
+// on stock movements. Unknown ids fall back to the zero id.
+func syncActor(userID string) primitive.ObjectID {
+	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return time.Time{}, errors.New("invalid " + key + " format")
+		return primitive.NilObjectID
 	}
-	return parsed.UTC(), nil
+	return id
 }
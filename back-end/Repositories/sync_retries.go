@@ -0,0 +1,455 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// retryLease is how long a claimed retry is hidden from other workers while
+// it is being attempted.
+const retryLease = 2 * time.Minute
+
+// RetryAuthorizer returns the role the user of a queued item holds now. An
+// error wrapping domain.ErrPermissionDenied dead-letters the item; any other
+// error fails the attempt and leaves the item queued.
+type RetryAuthorizer func(item *domain.SyncRetryItem) (domain.BusinessRole, error)
+
+// queueRetries stores the failed items of a non-atomic batch in the retry
+// queue and settles queued items the device has now synced itself. Items of
+// atomic batches are not queued: retrying them one by one would break the
+// all-or-nothing guarantee, so the device resubmits the batch instead.
+func (r *MongoSyncRepository) queueRetries(ctx context.Context, batch *syncBatch, transactions []domain.SyncBatchTransaction, response *domain.SyncBatchResponse) {
+	byLocalID := make(map[string]domain.SyncBatchTransaction, len(transactions))
+	for _, tx := range transactions {
+		byLocalID[tx.LocalID] = tx
+	}
+
+	queued, err := r.openRetries(ctx, batch.businessID, batch.deviceID, transactions)
+	if err != nil {
+		fmt.Printf("WARNING: failed to load queued retries: %v\n", err)
+	}
+
+	now := time.Now().UTC()
+	for i := range response.Results {
+		result := &response.Results[i]
+		if result.Status != "failed" {
+			if _, ok := queued[result.LocalID]; ok {
+				r.settleRetry(ctx, batch.businessID, batch.deviceID, result.LocalID, batch.syncID, *result, now)
+			}
+			continue
+		}
+		if result.Code == domain.SyncItemCodeValidationFailed {
+			// A malformed payload fails the same way on every attempt
+			continue
+		}
+		item, err := r.enqueueRetry(ctx, batch, byLocalID[result.LocalID], *result, now)
+		if err != nil {
+			fmt.Printf("WARNING: failed to queue retry for %s: %v\n", result.LocalID, err)
+			continue
+		}
+		result.RetryID = item.ID.Hex()
+		result.Attempt = item.Attempts
+		result.NextRetryAt = item.NextAttemptAt
+	}
+}
+
+// openRetries returns the local IDs of a batch that are still waiting in the
+// retry queue.
+func (r *MongoSyncRepository) openRetries(ctx context.Context, businessID primitive.ObjectID, deviceID string, transactions []domain.SyncBatchTransaction) (map[string]struct{}, error) {
+	localIDs := make([]string, 0, len(transactions))
+	for _, tx := range transactions {
+		localIDs = append(localIDs, tx.LocalID)
+	}
+
+	var open []struct {
+		LocalID string `bson:"local_id"`
+	}
+	filter := bson.M{
+		"business_id": businessID,
+		"device_id":   deviceID,
+		"local_id":    bson.M{"$in": localIDs},
+		"state":       bson.M{"$in": bson.A{domain.SyncRetryPending, domain.SyncRetryDeadLetter}},
+	}
+	if err := findAll(ctx, r.retries, filter, &open); err != nil {
+		return nil, err
+	}
+
+	queued := make(map[string]struct{}, len(open))
+	for _, item := range open {
+		queued[item.LocalID] = struct{}{}
+	}
+	return queued, nil
+}
+
+// enqueueRetry records a failed attempt for an item, creating its queue entry
+// on the first failure. A device resending an item that is already queued
+// counts as another attempt. An item in a final state, such as acked or
+// dead-lettered, is returned as it is and never queued again.
+func (r *MongoSyncRepository) enqueueRetry(ctx context.Context, batch *syncBatch, tx domain.SyncBatchTransaction, result domain.SyncItemResult, now time.Time) (*domain.SyncRetryItem, error) {
+	policy := domain.DefaultSyncRetryPolicy
+	filter := bson.M{"business_id": batch.businessID, "device_id": batch.deviceID, "local_id": tx.LocalID}
+
+	var existing domain.SyncRetryItem
+	err := r.retries.FindOne(ctx, filter).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err == nil && existing.State.IsFinal() {
+		return &existing, nil
+	}
+
+	attempt := existing.Attempts + 1
+	update := bson.M{
+		"$set": bson.M{
+			"type":           tx.Type,
+			"data":           tx.Data,
+			"schema_version": batch.schemaVersion,
+			"user_id":        batch.userID,
+			"role":           batch.role,
+			"request_id":     batch.requestID,
+			"last_error":     result.Message,
+			"updated_at":     now,
+		},
+		"$inc":         bson.M{"attempts": 1},
+		"$push":        bson.M{"lineage": retryAttempt(attempt, batch.syncID, result, now)},
+		"$setOnInsert": bson.M{"max_attempts": policy.MaxAttempts, "created_at": now},
+	}
+	scheduleNext(update, policy, attempt, now)
+
+	// The state guard keeps an item that reached a final state meanwhile
+	// from being revived; the upsert then clashes with it on the unique index
+	guarded := bson.M{"state": bson.M{"$nin": domain.FinalSyncRetryStates()}}
+	for key, value := range filter {
+		guarded[key] = value
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var item domain.SyncRetryItem
+	err = r.retries.FindOneAndUpdate(ctx, guarded, update, opts).Decode(&item)
+	if mongo.IsDuplicateKeyError(err) {
+		err = r.retries.FindOne(ctx, filter).Decode(&item)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// scheduleNext sets the state after a failed attempt: pending with a backoff
+// delay, or dead-lettered once the attempts are used up.
+func scheduleNext(update bson.M, policy domain.SyncRetryPolicy, attempt int, now time.Time) {
+	set := update["$set"].(bson.M)
+	if policy.Exhausted(attempt) {
+		set["state"] = domain.SyncRetryDeadLetter
+		update["$unset"] = bson.M{"next_attempt_at": ""}
+		return
+	}
+	set["state"] = domain.SyncRetryPending
+	set["next_attempt_at"] = now.Add(policy.Backoff(attempt))
+}
+
+// settleRetry marks a pending queue entry as succeeded once the item has been
+// applied, whether by the device resending it or by the retry queue.
+func (r *MongoSyncRepository) settleRetry(ctx context.Context, businessID primitive.ObjectID, deviceID, localID, syncID string, result domain.SyncItemResult, now time.Time) {
+	filter := bson.M{
+		"business_id": businessID,
+		"device_id":   deviceID,
+		"local_id":    localID,
+		"state":       bson.M{"$in": bson.A{domain.SyncRetryPending, domain.SyncRetryDeadLetter}},
+	}
+	var existing struct {
+		Attempts int `bson:"attempts"`
+	}
+	if err := r.retries.FindOne(ctx, filter).Decode(&existing); err != nil {
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"state":      domain.SyncRetrySucceeded,
+			"server_id":  result.ServerID,
+			"updated_at": now,
+		},
+		"$unset": bson.M{"next_attempt_at": ""},
+		"$inc":   bson.M{"attempts": 1},
+		"$push":  bson.M{"lineage": retryAttempt(existing.Attempts+1, syncID, result, now)},
+	}
+	if _, err := r.retries.UpdateOne(ctx, filter, update); err != nil {
+		fmt.Printf("WARNING: failed to settle retry for %s: %v\n", localID, err)
+	}
+}
+
+// ProcessRetries attempts up to limit queued items that are due. Each attempt
+// is written to sync_logs as a retry so it shows up in the item's history.
+// authorize, when set, resolves the user's role again before each attempt.
+// It returns the outcome of every item attempted.
+func (r *MongoSyncRepository) ProcessRetries(ctx context.Context, now time.Time, limit int, authorize RetryAuthorizer) ([]domain.SyncRetryOutcome, error) {
+	outcomes := make([]domain.SyncRetryOutcome, 0)
+	for len(outcomes) < limit {
+		item, err := r.claimRetry(ctx, now)
+		if err == mongo.ErrNoDocuments {
+			return outcomes, nil
+		}
+		if err != nil {
+			return outcomes, err
+		}
+		result, err := r.attemptRetry(ctx, item, now, authorize)
+		if err != nil {
+			return outcomes, err
+		}
+		outcomes = append(outcomes, domain.SyncRetryOutcome{Item: *item, Result: result})
+	}
+	return outcomes, nil
+}
+
+// claimRetry leases the next due item so concurrent workers skip it.
+func (r *MongoSyncRepository) claimRetry(ctx context.Context, now time.Time) (*domain.SyncRetryItem, error) {
+	filter := bson.M{"state": domain.SyncRetryPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(retryLease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var item domain.SyncRetryItem
+	if err := r.retries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// attemptRetry applies a queued item once. The item runs with the role its
+// user holds at the time of the attempt, not the one stored when it was
+// queued, and is dead-lettered once that role no longer allows it.
+func (r *MongoSyncRepository) attemptRetry(ctx context.Context, item *domain.SyncRetryItem, now time.Time, authorize RetryAuthorizer) (domain.SyncItemResult, error) {
+	syncObjectID := primitive.NewObjectID()
+	attempt := item.Attempts + 1
+	tx := domain.SyncBatchTransaction{LocalID: item.LocalID, Type: item.Type, Data: item.Data}
+	result := domain.SyncItemResult{LocalID: item.LocalID, RetryID: item.ID.Hex(), Attempt: attempt}
+
+	var conflict *domain.SyncConflictInfo
+	var applied *domain.SyncAppliedChange
+	var serverID string
+	var err error
+	alreadySynced := false
+
+	if err = r.ensureDeviceRegistered(ctx, item.BusinessID, item.DeviceID); err == nil {
+		alreadySynced, serverID, err = r.findExistingSynced(ctx, item.BusinessID, item.DeviceID, item.LocalID, item.Type)
+	}
+	if err == nil && !alreadySynced && authorize != nil {
+		item.Role, err = authorize(item)
+	}
+	if err == nil && !alreadySynced {
+		var policy domain.SyncConflictPolicy
+		if policy, err = r.conflictPolicy(ctx, item.BusinessID); err == nil {
+			batch := &syncBatch{
+				businessID:    item.BusinessID,
+				deviceID:      item.DeviceID,
+				userID:        item.UserID,
+				role:          item.Role,
+				requestID:     item.RequestID,
+				syncID:        syncObjectID.Hex(),
+				policy:        policy,
+				schemaVersion: item.SchemaVersion,
+			}
+			serverID, conflict, err = r.processSingleTransaction(ctx, batch, tx)
+			applied = batch.applied
+		}
+	}
+
+	summary := domain.SyncSummary{Total: 1}
+	switch {
+	case err != nil:
+		failItem(&result, err)
+		summary.Failed++
+	case alreadySynced:
+		result.Status = "already_synced"
+		result.ServerID = serverID
+		summary.Success++
+	case conflict != nil && conflict.Resolution != domain.SyncResolutionClient:
+		result.Status = "conflict"
+		result.ServerID = serverID
+		result.Conflict = conflict
+		summary.Conflicts++
+	default:
+		result.Status = "success"
+		result.ServerID = serverID
+		result.Conflict = conflict
+		result.Applied = applied
+		summary.Success++
+	}
+
+	if result.Status == "failed" {
+		policy := domain.DefaultSyncRetryPolicy
+		if item.MaxAttempts > 0 {
+			policy.MaxAttempts = item.MaxAttempts
+		}
+		if errors.Is(err, domain.ErrPermissionDenied) {
+			// Retrying cannot succeed without the permission, so this
+			// attempt is the last one
+			policy.MaxAttempts = attempt
+		}
+		update := bson.M{
+			"$set":  bson.M{"last_error": result.Message, "updated_at": now},
+			"$inc":  bson.M{"attempts": 1},
+			"$push": bson.M{"lineage": retryAttempt(attempt, syncObjectID.Hex(), result, now)},
+		}
+		scheduleNext(update, policy, attempt, now)
+		if _, err := r.retries.UpdateByID(ctx, item.ID, update); err != nil {
+			return result, err
+		}
+		if !policy.Exhausted(attempt) {
+			next := now.Add(policy.Backoff(attempt))
+			result.NextRetryAt = &next
+		}
+	} else {
+		r.settleRetry(ctx, item.BusinessID, item.DeviceID, item.LocalID, syncObjectID.Hex(), result, now)
+	}
+
+	status := syncStatusCompleted
+	if summary.Failed > 0 {
+		status = syncStatusPartialSuccess
+	}
+	_, err = r.syncLogs.InsertOne(ctx, bson.M{
+		"_id":            syncObjectID,
+		"business_id":    item.BusinessID,
+		"device_id":      item.DeviceID,
+		"sync_timestamp": now,
+		"status":         status,
+		"results":        []domain.SyncItemResult{result},
+		"summary":        summary,
+		"source":         domain.SyncLogSourceRetry,
+		"created_at":     now,
+	})
+	return result, err
+}
+
+func retryAttempt(attempt int, syncID string, result domain.SyncItemResult, now time.Time) domain.SyncRetryAttempt {
+	return domain.SyncRetryAttempt{
+		Attempt:     attempt,
+		SyncID:      syncID,
+		Status:      result.Status,
+		Code:        result.Code,
+		Message:     result.Message,
+		AttemptedAt: now,
+	}
+}
+
+// ListRetries returns queued items for a business, optionally narrowed to a
+// device and a state.
+func (r *MongoSyncRepository) ListRetries(ctx context.Context, businessID, deviceID string, state domain.SyncRetryState, page, limit int) (*domain.SyncRetryList, error) {
+	businessObjID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return nil, errors.New("invalid business_id")
+	}
+
+	query := bson.M{"business_id": businessObjID}
+	if deviceID != "" {
+		query["device_id"] = deviceID
+	}
+	if state != "" {
+		query["state"] = state
+	}
+
+	total, err := r.retries.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := int64((page - 1) * limit)
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetSkip(skip).SetLimit(int64(limit))
+	items := make([]domain.SyncRetryItem, 0)
+	cursor, err := r.retries.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	resp := &domain.SyncRetryList{Data: items}
+	resp.Pagination.CurrentPage = page
+	resp.Pagination.TotalPages = totalPages
+	resp.Pagination.TotalRecords = total
+	resp.Pagination.PerPage = limit
+	return resp, nil
+}
+
+// UpdateRetryState moves a device's queued items from one of the given states
+// to another. Items in any other state are left alone.
+func (r *MongoSyncRepository) UpdateRetryState(ctx context.Context, businessID, deviceID string, localIDs []string, from []domain.SyncRetryState, to domain.SyncRetryState) (int64, error) {
+	businessObjID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return 0, errors.New("invalid business_id")
+	}
+
+	filter := bson.M{
+		"business_id": businessObjID,
+		"device_id":   deviceID,
+		"local_id":    bson.M{"$in": localIDs},
+		"state":       bson.M{"$in": from},
+	}
+	update := bson.M{
+		"$set":   bson.M{"state": to, "updated_at": time.Now().UTC()},
+		"$unset": bson.M{"next_attempt_at": ""},
+	}
+	result, err := r.retries.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// GetItemHistory returns the lineage of a single local_id: every sync log
+// that carried it, oldest first, and its retry queue entry.
+func (r *MongoSyncRepository) GetItemHistory(ctx context.Context, businessID, deviceID, localID string) (*domain.SyncItemHistory, error) {
+	businessObjID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return nil, errors.New("invalid business_id")
+	}
+
+	query := bson.M{"business_id": businessObjID, "device_id": deviceID, "results.local_id": localID}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetProjection(bson.M{"results": bson.M{"$elemMatch": bson.M{"local_id": localID}}, "business_id": 1, "device_id": 1, "sync_timestamp": 1, "status": 1, "summary": 1, "cursor": 1, "atomic": 1, "source": 1, "created_at": 1})
+	cursor, err := r.syncLogs.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	history := &domain.SyncItemHistory{
+		BusinessID: businessID,
+		DeviceID:   deviceID,
+		LocalID:    localID,
+		Logs:       decodeSyncLogs(ctx, cursor),
+	}
+
+	var item domain.SyncRetryItem
+	err = r.retries.FindOne(ctx, bson.M{"business_id": businessObjID, "device_id": deviceID, "local_id": localID}).Decode(&item)
+	switch {
+	case err == nil:
+		history.Retry = &item
+	case err != mongo.ErrNoDocuments:
+		return nil, err
+	}
+
+	if len(history.Logs) == 0 && history.Retry == nil {
+		return nil, domain.ErrSyncItemNotFound
+	}
+	return history, nil
+}
@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// syncUploadTTL is how long an upload session may stay open before it and
+// its chunks are dropped.
+const syncUploadTTL = 24 * time.Hour
+
+// syncUploadClaimTimeout is how long a commit may hold an upload before
+// another commit is allowed to take it over.
+const syncUploadClaimTimeout = 10 * time.Minute
+
+type syncUploadChunk struct {
+	UploadID     primitive.ObjectID            `bson:"upload_id"`
+	Index        int                           `bson:"index"`
+	Transactions []domain.SyncBatchTransaction `bson:"transactions"`
+	ReceivedAt   time.Time                     `bson:"received_at"`
+	ExpiresAt    time.Time                     `bson:"expires_at"`
+	// Claimed freezes the chunk while a commit reads it
+	Claimed bool `bson:"claimed,omitempty"`
+}
+
+// CreateUpload opens a chunked upload session for a registered device.
+func (r *MongoSyncRepository) CreateUpload(ctx context.Context, upload *domain.SyncUpload) error {
+	if err := r.ensureDeviceRegistered(ctx, upload.BusinessID, upload.DeviceID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	upload.ID = primitive.NewObjectID()
+	upload.Status = domain.SyncUploadOpen
+	upload.ReceivedChunks = []int{}
+	upload.CreatedAt = now
+	upload.ExpiresAt = now.Add(syncUploadTTL)
+
+	_, err := r.uploads.InsertOne(ctx, upload)
+	return err
+}
+
+// GetUpload returns an upload session that has not expired.
+func (r *MongoSyncRepository) GetUpload(ctx context.Context, businessID, uploadID string) (*domain.SyncUpload, error) {
+	filter, err := uploadFilter(businessID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	var upload domain.SyncUpload
+	err = r.uploads.FindOne(ctx, filter).Decode(&upload)
+	if err == mongo.ErrNoDocuments {
+		return nil, domain.ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// SaveUploadChunk stores chunk index of an open upload. Sending the same
+// index again replaces the earlier copy, so an interrupted chunk can simply
+// be resent. A chunk claimed by a commit is never replaced, even by a request
+// that found the upload still open.
+func (r *MongoSyncRepository) SaveUploadChunk(ctx context.Context, businessID, uploadID string, index int, transactions []domain.SyncBatchTransaction) (*domain.SyncUpload, error) {
+	upload, err := r.GetUpload(ctx, businessID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Status != domain.SyncUploadOpen {
+		return nil, domain.ErrUploadCommitted
+	}
+	if index < 0 || index >= upload.TotalChunks {
+		return nil, domain.ErrInvalidChunkIndex
+	}
+
+	chunk := syncUploadChunk{
+		UploadID:     upload.ID,
+		Index:        index,
+		Transactions: transactions,
+		ReceivedAt:   time.Now().UTC(),
+		ExpiresAt:    upload.ExpiresAt,
+	}
+	// A claimed chunk does not match, so the upsert collides with it instead
+	_, err = r.uploadChunks.ReplaceOne(ctx,
+		bson.M{"upload_id": upload.ID, "index": index, "claimed": bson.M{"$ne": true}},
+		chunk,
+		options.Replace().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, domain.ErrUploadCommitted
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"_id": upload.ID, "status": domain.SyncUploadOpen}
+	update := bson.M{"$addToSet": bson.M{"received_chunks": index}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.uploads.FindOneAndUpdate(ctx, filter, update, opts).Decode(upload)
+	if err == mongo.ErrNoDocuments {
+		return nil, domain.ErrUploadCommitted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// ClaimUpload moves a complete upload into the committing state, so a second
+// commit cannot process it again, and returns its transactions in chunk order.
+// Only the user who started the upload, or its device, may claim it.
+func (r *MongoSyncRepository) ClaimUpload(ctx context.Context, businessID, uploadID, userID, deviceID string) (*domain.SyncUpload, []domain.SyncBatchTransaction, error) {
+	filter, err := uploadFilter(businessID, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	existing, err := r.GetUpload(ctx, businessID, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !existing.StartedBy(userID, deviceID) {
+		return nil, nil, domain.ErrUploadNotYours
+	}
+	// A commit interrupted by a crash is picked up again once its claim is stale
+	now := time.Now().UTC()
+	filter["$or"] = bson.A{
+		bson.M{"status": domain.SyncUploadOpen},
+		bson.M{"status": domain.SyncUploadCommitting, "claimed_at": bson.M{"$lt": now.Add(-syncUploadClaimTimeout)}},
+	}
+	update := bson.M{"$set": bson.M{"status": domain.SyncUploadCommitting, "claimed_at": now}}
+
+	var upload domain.SyncUpload
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.uploads.FindOneAndUpdate(ctx, filter, update, opts).Decode(&upload)
+	if err == mongo.ErrNoDocuments {
+		if _, getErr := r.GetUpload(ctx, businessID, uploadID); getErr != nil {
+			return nil, nil, getErr
+		}
+		return nil, nil, domain.ErrUploadCommitted
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(upload.Missing()) > 0 {
+		_ = r.ReleaseUpload(ctx, upload.ID.Hex(), "")
+		return nil, nil, domain.ErrUploadIncomplete
+	}
+
+	// Chunk writes that checked the upload before the claim may still be in
+	// flight; claiming the chunks stops them from changing what is read below
+	_, err = r.uploadChunks.UpdateMany(ctx, bson.M{"upload_id": upload.ID}, bson.M{"$set": bson.M{"claimed": true}})
+	if err != nil {
+		_ = r.ReleaseUpload(ctx, upload.ID.Hex(), "")
+		return nil, nil, err
+	}
+
+	var chunks []syncUploadChunk
+	findOpts := options.Find().SetSort(bson.D{{Key: "index", Value: 1}})
+	cursor, err := r.uploadChunks.Find(ctx, bson.M{"upload_id": upload.ID}, findOpts)
+	if err != nil {
+		_ = r.ReleaseUpload(ctx, upload.ID.Hex(), "")
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &chunks); err != nil {
+		_ = r.ReleaseUpload(ctx, upload.ID.Hex(), "")
+		return nil, nil, err
+	}
+
+	transactions := make([]domain.SyncBatchTransaction, 0)
+	for _, chunk := range chunks {
+		transactions = append(transactions, chunk.Transactions...)
+	}
+	return &upload, transactions, nil
+}
+
+// ReleaseUpload finishes a claimed upload. With a sync ID the upload is marked
+// committed and its chunks are dropped; without one it is reopened so the
+// device can fix it and commit again.
+func (r *MongoSyncRepository) ReleaseUpload(ctx context.Context, uploadID, syncID string) error {
+	id, err := primitive.ObjectIDFromHex(uploadID)
+	if err != nil {
+		return domain.ErrUploadNotFound
+	}
+
+	if syncID == "" {
+		// The chunks are freed while the upload still rejects new ones
+		_, err = r.uploadChunks.UpdateMany(ctx, bson.M{"upload_id": id}, bson.M{"$unset": bson.M{"claimed": ""}})
+		if err != nil {
+			return err
+		}
+		_, err = r.uploads.UpdateOne(ctx,
+			bson.M{"_id": id, "status": domain.SyncUploadCommitting},
+			bson.M{"$set": bson.M{"status": domain.SyncUploadOpen}},
+		)
+		return err
+	}
+
+	_, err = r.uploads.UpdateOne(ctx,
+		bson.M{"_id": id, "status": domain.SyncUploadCommitting},
+		bson.M{"$set": bson.M{"status": domain.SyncUploadCommitted, "sync_id": syncID}},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = r.uploadChunks.DeleteMany(ctx, bson.M{"upload_id": id})
+	return err
+}
+
+func uploadFilter(businessID, uploadID string) (bson.M, error) {
+	businessObjID, err := primitive.ObjectIDFromHex(businessID)
+	if err != nil {
+		return nil, errors.New("invalid business_id")
+	}
+	uploadObjID, err := primitive.ObjectIDFromHex(uploadID)
+	if err != nil {
+		return nil, domain.ErrUploadNotFound
+	}
+	return bson.M{
+		"_id":         uploadObjID,
+		"business_id": businessObjID,
+		"expires_at":  bson.M{"$gt": time.Now().UTC()},
+	}, nil
+}
@@ -122,9 +122,9 @@ func (r *MongoTransactionRepository) getSalesTransactions(ctx context.Context, f
 		matchStage["created_at"] = dateFilter
 	}
 
-	// Product filter
+	// Product filter: any line of the basket
 	if filter.ProductID != nil {
-		matchStage["product_id"] = filter.ProductID
+		matchStage["$or"] = saleProductFilter(*filter.ProductID)
 	}
 
 	// Amount filter
@@ -145,29 +145,24 @@ func (r *MongoTransactionRepository) getSalesTransactions(ctx context.Context, f
 		return nil, 0, err
 	}
 
-	// Aggregation pipeline with product lookup
+	// Aggregation pipeline with a lookup of every line's product. Sales
+	// recorded before baskets are read as a single line.
 	pipeline := mongo.Pipeline{
 		{{Key: "$match", Value: matchStage}},
+		{{Key: "$set", Value: bson.M{"lines": saleLinesExpr}}},
 		{{Key: "$lookup", Value: bson.M{
 			"from":         "products",
-			"localField":   "product_id",
+			"localField":   "lines.product_id",
 			"foreignField": "_id",
-			"as":           "product",
-		}}},
-		{{Key: "$unwind", Value: bson.M{
-			"path":                       "$product",
-			"preserveNullAndEmptyArrays": true,
+			"as":           "products",
 		}}},
 		{{Key: "$project", Value: bson.M{
-			"_id":          1,
-			"type":         bson.M{"$literal": "sale"},
-			"date":         "$created_at",
-			"amount":       "$total",
-			"product_id":   1,
-			"product_name": "$product.name",
-			"category":     bson.M{"$literal": nil},
-			"description":  bson.M{"$concat": bson.A{"Sale of ", bson.M{"$ifNull": bson.A{"$product.name", "product"}}}},
-			"created_at":   1,
+			"_id":        1,
+			"date":       "$created_at",
+			"amount":     "$total",
+			"lines":      1,
+			"products":   bson.M{"_id": 1, "name": 1},
+			"created_at": 1,
 		}}},
 	}
 
@@ -180,37 +175,61 @@ func (r *MongoTransactionRepository) getSalesTransactions(ctx context.Context, f
 	var transactions []*domain.Transaction
 	for cursor.Next(ctx) {
 		var result struct {
-			ID          primitive.ObjectID  `bson:"_id"`
-			Type        string              `bson:"type"`
-			Date        primitive.DateTime  `bson:"date"`
-			Amount      float64             `bson:"amount"`
-			ProductID   *primitive.ObjectID `bson:"product_id"`
-			ProductName *string             `bson:"product_name"`
-			Category    *string             `bson:"category"`
-			Description string              `bson:"description"`
-			CreatedAt   primitive.DateTime  `bson:"created_at"`
+			ID       primitive.ObjectID `bson:"_id"`
+			Date     primitive.DateTime `bson:"date"`
+			Amount   decimal.Decimal    `bson:"amount"`
+			Lines    []domain.SaleLine  `bson:"lines"`
+			Products []struct {
+				ID   primitive.ObjectID `bson:"_id"`
+				Name string             `bson:"name"`
+			} `bson:"products"`
+			CreatedAt primitive.DateTime `bson:"created_at"`
 		}
 		if err := cursor.Decode(&result); err != nil {
 			continue
 		}
 
-		var productIDStr *string
-		if result.ProductID != nil {
-			str := result.ProductID.Hex()
-			productIDStr = &str
+		names := make(map[primitive.ObjectID]string, len(result.Products))
+		for _, product := range result.Products {
+			names[product.ID] = product.Name
 		}
 
-		transactions = append(transactions, &domain.Transaction{
-			ID:          result.ID.Hex(),
-			Type:        domain.TransactionTypeSale,
-			Date:        result.Date.Time(),
-			Amount:      decimalFromFloat(result.Amount),
-			ProductID:   productIDStr,
-			ProductName: result.ProductName,
-			Category:    result.Category,
-			Description: result.Description,
-			CreatedAt:   result.CreatedAt.Time(),
-		})
+		txn := &domain.Transaction{
+			ID:        result.ID.Hex(),
+			Type:      domain.TransactionTypeSale,
+			Date:      result.Date.Time(),
+			Amount:    result.Amount,
+			CreatedAt: result.CreatedAt.Time(),
+			Items:     make([]domain.TransactionItem, 0, len(result.Lines)),
+		}
+		described := make([]string, 0, len(result.Lines))
+		for _, line := range result.Lines {
+			item := domain.TransactionItem{
+				Quantity:  line.Quantity,
+				UnitPrice: line.UnitPrice,
+				Discount:  line.Discount,
+				Total:     line.Total,
+			}
+			label := "product"
+			if line.ProductID != nil {
+				id := line.ProductID.Hex()
+				item.ProductID = &id
+				if name, ok := names[*line.ProductID]; ok {
+					item.ProductName = &name
+					label = name
+				}
+			}
+			txn.Items = append(txn.Items, item)
+			described = append(described, label)
+		}
+		// A single-line sale keeps its product on the transaction itself
+		if len(txn.Items) == 1 {
+			txn.ProductID = txn.Items[0].ProductID
+			txn.ProductName = txn.Items[0].ProductName
+		}
+		txn.Description = "Sale of " + strings.Join(described, ", ")
+
+		transactions = append(transactions, txn)
 	}
 
 	// Apply search filter if provided
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// topology remembers whether the server supports multi-document
+// transactions. Only an answer the server gave is kept: a check that fails is
+// made again on the next call, so one timed-out request cannot turn
+// transactions off for the life of the process.
+type topology struct {
+	db *mongo.Database
+
+	mu            sync.Mutex
+	known         bool
+	transactional bool
+}
+
+func newTopology(db *mongo.Database) *topology {
+	return &topology{db: db}
+}
+
+// supportsTransactions reports whether the server is a replica set member or
+// mongos. The check runs on its own context, not the caller's. While the
+// server cannot be asked it reports false, and callers take the path that
+// works on any deployment.
+func (t *topology) supportsTransactions() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.known {
+		return t.transactional
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	transactional, err := transactionsSupported(ctx, t.db)
+	if err != nil {
+		return false
+	}
+	t.known, t.transactional = true, transactional
+	return transactional
+}
+
+// transactionsSupported asks the server whether it is a replica set member or
+// mongos, the deployments where multi-document transactions are available.
+func transactionsSupported(ctx context.Context, db *mongo.Database) (bool, error) {
+	var hello bson.M
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+	_, replicaSet := hello["setName"]
+	return replicaSet || hello["msg"] == "isdbgrid", nil
+}
+
+// withTransaction runs fn in a session transaction. fn is retried on
+// transient errors, so it must not keep state between attempts.
+func withTransaction(ctx context.Context, db *mongo.Database, fn func(ctx context.Context) error) error {
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	Domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
+	usecases "shop-ops/Usecases"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Mock APIKeyRepository ---
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Save(key *Domain.APIKey) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) FindByHash(keyHash string) (*Domain.APIKey, error) {
+	args := m.Called(keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) FindByBusiness(businessId string) ([]*Domain.APIKey, error) {
+	args := m.Called(businessId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Touch(id primitive.ObjectID, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) Revoke(businessId string, keyId string, at time.Time) error {
+	args := m.Called(businessId, keyId, at)
+	return args.Error(0)
+}
+
+// --- Tests ---
+
+func TestCreateAPIKey(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	ownerID := primitive.NewObjectID()
+
+	t.Run("Success - returns the key once and stores its hash", func(t *testing.T) {
+		repo := new(MockAPIKeyRepository)
+		var saved *Domain.APIKey
+		repo.On("Save", mock.AnythingOfType("*domain.APIKey")).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*Domain.APIKey)
+		}).Return(nil).Once()
+
+		uc := usecases.NewAPIKeyUseCases(repo)
+		req := &Domain.CreateAPIKeyRequest{
+			Name:   " Bookkeeper ",
+			Scopes: []Domain.APIKeyScope{Domain.ScopeReadSales, Domain.ScopeExport, Domain.ScopeReadSales},
+		}
+		key, err := uc.CreateAPIKey(businessID.Hex(), ownerID.Hex(), req)
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(key.Key, Domain.APIKeyPrefix))
+		assert.Equal(t, "Bookkeeper", key.Name)
+		assert.Equal(t, []Domain.APIKeyScope{Domain.ScopeReadSales, Domain.ScopeExport}, key.Scopes)
+		assert.Equal(t, ownerID, key.CreatedBy)
+		assert.True(t, strings.HasPrefix(key.Key, key.Hint))
+		assert.NotEqual(t, key.Key, saved.KeyHash)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Rejects unknown scopes", func(t *testing.T) {
+		uc := usecases.NewAPIKeyUseCases(new(MockAPIKeyRepository))
+		req := &Domain.CreateAPIKeyRequest{Name: "POS", Scopes: []Domain.APIKeyScope{"admin"}}
+		_, err := uc.CreateAPIKey(businessID.Hex(), ownerID.Hex(), req)
+		assert.ErrorIs(t, err, Domain.ErrInvalidAPIKeyScopes)
+	})
+
+	t.Run("Requires a scope", func(t *testing.T) {
+		uc := usecases.NewAPIKeyUseCases(new(MockAPIKeyRepository))
+		_, err := uc.CreateAPIKey(businessID.Hex(), ownerID.Hex(), &Domain.CreateAPIKeyRequest{Name: "POS"})
+		assert.ErrorIs(t, err, Domain.ErrInvalidAPIKeyScopes)
+	})
+}
+
+func TestAPIKeyAllows(t *testing.T) {
+	key := &Domain.APIKey{Scopes: []Domain.APIKeyScope{Domain.ScopeReadExpenses, Domain.ScopeWriteSales}}
+
+	assert.True(t, key.Allows(Domain.PermissionManageExpenses, http.MethodGet))
+	// A read scope never allows changes, even where one permission covers both
+	assert.False(t, key.Allows(Domain.PermissionManageExpenses, http.MethodPatch))
+	assert.True(t, key.Allows(Domain.PermissionRecordSales, http.MethodPost))
+	assert.False(t, key.Allows(Domain.PermissionViewSales, http.MethodGet))
+	assert.False(t, key.Allows(Domain.PermissionManageAPIKeys, http.MethodPost))
+}
+
+func setupAPIKeyRouter(apiKeyRepo *MockAPIKeyRepository, business *Domain.Business) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := infrastructure.NewLogger("error", "")
+
+	businessRepo := new(MockBusinessRepository)
+	businessRepo.On("FindByID", business.ID.Hex()).Return(business, nil)
+	businessRepo.On("FindByID", mock.Anything).Return(nil, nil)
+	membershipRepo := new(MockMembershipRepository)
+	membershipRepo.On("FindByBusinessAndUser", mock.Anything, mock.Anything).Return(nil, nil)
+	authorizer := infrastructure.NewAuthorizer(usecases.NewMembershipUseCases(membershipRepo, businessRepo, nil), logger)
+
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id"), "business_id": c.GetString("business_id")})
+	}
+
+	r := gin.New()
+	protected := r.Group("/")
+	protected.Use(infrastructure.AuthMiddleware(nil, nil, usecases.NewAPIKeyUseCases(apiKeyRepo), logger))
+	protected.GET("/users/me", infrastructure.RequireUser(), ok)
+	protected.GET("/sales", authorizer.Require(Domain.PermissionViewSales), ok)
+	protected.POST("/sales", authorizer.Require(Domain.PermissionRecordSales), ok)
+	protected.GET("/expenses", authorizer.Require(Domain.PermissionManageExpenses), ok)
+	return r
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	ownerID := primitive.NewObjectID()
+	business := &Domain.Business{ID: primitive.NewObjectID(), UserID: ownerID, Name: "Test Shop"}
+	key := &Domain.APIKey{
+		ID:         primitive.NewObjectID(),
+		BusinessID: business.ID,
+		Scopes:     []Domain.APIKeyScope{Domain.ScopeReadSales},
+		CreatedBy:  ownerID,
+	}
+	const plain = Domain.APIKeyPrefix + "test-key"
+
+	newRepo := func(found *Domain.APIKey) *MockAPIKeyRepository {
+		repo := new(MockAPIKeyRepository)
+		repo.On("FindByHash", mock.Anything).Return(found, nil)
+		repo.On("Touch", mock.Anything, mock.Anything).Return(nil)
+		return repo
+	}
+	send := func(r *gin.Engine, method, path, header, value string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Scoped key acts as its creator in its business", func(t *testing.T) {
+		repo := newRepo(key)
+		r := setupAPIKeyRouter(repo, business)
+
+		w := send(r, http.MethodGet, "/sales?business_id="+business.ID.Hex(), "X-API-Key", plain)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), ownerID.Hex())
+		repo.AssertCalled(t, "Touch", key.ID, mock.Anything)
+
+		// Bearer works as well
+		w = send(r, http.MethodGet, "/sales?business_id="+business.ID.Hex(), "Authorization", "Bearer "+plain)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Out of scope", func(t *testing.T) {
+		r := setupAPIKeyRouter(newRepo(key), business)
+
+		w := send(r, http.MethodPost, "/sales?business_id="+business.ID.Hex(), "X-API-Key", plain)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = send(r, http.MethodGet, "/expenses?businessId="+business.ID.Hex(), "X-API-Key", plain)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Other business", func(t *testing.T) {
+		r := setupAPIKeyRouter(newRepo(key), business)
+
+		w := send(r, http.MethodGet, "/sales?business_id="+primitive.NewObjectID().Hex(), "X-API-Key", plain)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("User-only routes", func(t *testing.T) {
+		r := setupAPIKeyRouter(newRepo(key), business)
+
+		w := send(r, http.MethodGet, "/users/me", "X-API-Key", plain)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("A key used within the last minute is not touched again", func(t *testing.T) {
+		recent := time.Now().Add(-10 * time.Second)
+		used := *key
+		used.LastUsedAt = &recent
+		repo := newRepo(&used)
+		r := setupAPIKeyRouter(repo, business)
+
+		w := send(r, http.MethodGet, "/sales?business_id="+business.ID.Hex(), "X-API-Key", plain)
+		assert.Equal(t, http.StatusOK, w.Code)
+		repo.AssertNotCalled(t, "Touch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoked key", func(t *testing.T) {
+		revokedAt := time.Now()
+		revoked := *key
+		revoked.RevokedAt = &revokedAt
+		r := setupAPIKeyRouter(newRepo(&revoked), business)
+
+		w := send(r, http.MethodGet, "/sales?business_id="+business.ID.Hex(), "X-API-Key", plain)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
+	usecases "shop-ops/Usecases"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Mocks ---
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Append(entry *domain.AuditEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) Find(query domain.AuditQuery) ([]domain.AuditEntry, int64, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]domain.AuditEntry), args.Get(1).(int64), args.Error(2)
+}
+
+type MockAuditRecorder struct {
+	mock.Mock
+}
+
+func (m *MockAuditRecorder) Record(entry *domain.AuditEntry) {
+	m.Called(entry)
+}
+
+// --- Tests ---
+
+func TestDiffAudit(t *testing.T) {
+	updated := time.Now()
+	before := &domain.Expense{Category: domain.ExpenseRent, Note: "March", UpdatedAt: &updated}
+
+	t.Run("Create lists every field", func(t *testing.T) {
+		changes := domain.DiffAudit(nil, before)
+
+		fields := map[string]bool{}
+		for _, change := range changes {
+			assert.Nil(t, change.Before)
+			fields[change.Field] = true
+		}
+		assert.True(t, fields["category"])
+		assert.True(t, fields["note"])
+		assert.False(t, fields["updated_at"])
+	})
+
+	t.Run("Update lists only changed fields", func(t *testing.T) {
+		after := *before
+		after.Note = "April"
+		later := updated.Add(time.Hour)
+		after.UpdatedAt = &later
+
+		changes := domain.DiffAudit(before, &after)
+
+		assert.Equal(t, []domain.AuditChange{{Field: "note", Before: "March", After: "April"}}, changes)
+	})
+}
+
+func TestListAuditEntries(t *testing.T) {
+	businessID := primitive.NewObjectID().Hex()
+
+	t.Run("Applies paging defaults and cap", func(t *testing.T) {
+		repo := new(MockAuditRepository)
+		uc := usecases.NewAuditUseCases(repo)
+
+		repo.On("Find", mock.MatchedBy(func(q domain.AuditQuery) bool {
+			return q.Page == 1 && q.Limit == domain.MaxAuditPageSize
+		})).Return([]domain.AuditEntry{{Action: domain.AuditActionVoid}}, int64(250), nil).Once()
+
+		list, err := uc.ListEntries(domain.AuditQuery{BusinessID: businessID, Limit: 1000})
+
+		assert.NoError(t, err)
+		assert.Len(t, list.Entries, 1)
+		assert.Equal(t, 3, list.Pagination.TotalPages)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("Rejects inverted range", func(t *testing.T) {
+		repo := new(MockAuditRepository)
+		uc := usecases.NewAuditUseCases(repo)
+		from := time.Now()
+		to := from.Add(-24 * time.Hour)
+
+		_, err := uc.ListEntries(domain.AuditQuery{BusinessID: businessID, From: &from, To: &to})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidAuditRange)
+		repo.AssertNotCalled(t, "Find")
+	})
+}
+
+func TestSyncBatch_RecordsAppliedTransactions(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	recorder := new(MockAuditRecorder)
+	uc := usecases.NewSyncUseCases(mockRepo, recorder, nil)
+	productID := primitive.NewObjectID().Hex()
+
+	req := domain.SyncBatchRequest{
+		BusinessID: primitive.NewObjectID().Hex(),
+		DeviceID:   "device_1",
+		UserID:     "user_1",
+		RequestID:  "req-1",
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "a1", Type: domain.SyncTransactionTypeStockAdjustment, Data: map[string]interface{}{"product_id": productID}},
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{}},
+		},
+	}
+
+	mockRepo.On("ProcessBatch", mock.Anything, req).Return(&domain.SyncBatchResponse{
+		Status: "partial",
+		Results: []domain.SyncItemResult{
+			{LocalID: "a1", ServerID: "adj_1", Status: "success"},
+			{LocalID: "s1", Status: "failed"},
+		},
+	}, nil).Once()
+	recorder.On("Record", mock.MatchedBy(func(e *domain.AuditEntry) bool {
+		return e.Source == domain.AuditSourceSync && e.DeviceID == "device_1" && e.RequestID == "req-1" &&
+			e.EntityType == domain.AuditEntityProduct && e.EntityID == productID && e.Action == domain.AuditActionAdjustStock
+	})).Once()
+
+	_, err := uc.SyncBatch(req)
+
+	assert.NoError(t, err)
+	recorder.AssertNumberOfCalls(t, "Record", 1)
+	recorder.AssertExpectations(t)
+}
+
+func TestSyncRetriesAndResolutions_AreAudited(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	expenseID := primitive.NewObjectID().Hex()
+	before := map[string]interface{}{"amount": "10", "note": "taxi"}
+	after := map[string]interface{}{"amount": "90", "note": "taxi"}
+
+	t.Run("An item applied by the retry queue is audited as the batch's user and device", func(t *testing.T) {
+		mockRepo := new(MockSyncRepository)
+		recorder := new(MockAuditRecorder)
+		uc := usecases.NewSyncUseCases(mockRepo, recorder, nil)
+
+		item := domain.SyncRetryItem{BusinessID: businessID, DeviceID: "device_1", LocalID: "u1", Type: domain.SyncTransactionTypeExpenseUpdate, UserID: "user_1", Role: domain.RoleCashier, RequestID: "req-1"}
+		mockRepo.On("ProcessRetries", mock.Anything, mock.Anything, 100, mock.Anything).Return([]domain.SyncRetryOutcome{
+			{Item: item, Result: domain.SyncItemResult{LocalID: "u1", Status: "success", Applied: &domain.SyncAppliedChange{EntityID: expenseID, Before: before, After: after}}},
+			{Item: item, Result: domain.SyncItemResult{LocalID: "u2", Status: "failed"}},
+		}, nil).Once()
+		recorder.On("Record", mock.MatchedBy(func(e *domain.AuditEntry) bool {
+			return e.ActorID == "user_1" && e.ActorRole == domain.RoleCashier && e.DeviceID == "device_1" && e.RequestID == "req-1" &&
+				e.EntityID == expenseID && e.Action == domain.AuditActionUpdate &&
+				len(e.Changes) == 1 && e.Changes[0].Field == "amount"
+		})).Once()
+
+		processed, err := uc.ProcessRetries(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, processed)
+		recorder.AssertNumberOfCalls(t, "Record", 1)
+		recorder.AssertExpectations(t)
+	})
+
+	t.Run("A conflict resolved for the client is audited with its change", func(t *testing.T) {
+		mockRepo := new(MockSyncRepository)
+		recorder := new(MockAuditRecorder)
+		uc := usecases.NewSyncUseCases(mockRepo, recorder, nil)
+
+		conflict := &domain.SyncConflict{
+			ID: primitive.NewObjectID(), BusinessID: businessID, DeviceID: "device_1", LocalID: "u1",
+			Type: domain.SyncTransactionTypeExpenseUpdate, UserID: "user_1", Role: domain.RoleCashier,
+			Info:    domain.SyncConflictInfo{EntityID: expenseID},
+			Applied: &domain.SyncAppliedChange{EntityID: expenseID, Before: before, After: after},
+		}
+		mockRepo.On("ResolveConflict", mock.Anything, businessID.Hex(), conflict.ID.Hex(), "owner_1", domain.SyncResolutionClient).Return(conflict, nil).Once()
+		recorder.On("Record", mock.MatchedBy(func(e *domain.AuditEntry) bool {
+			return e.ActorID == "user_1" && e.DeviceID == "device_1" && e.Source == domain.AuditSourceSync &&
+				e.EntityID == expenseID && len(e.Changes) == 1 && e.Reason != ""
+		})).Once()
+
+		_, err := uc.ResolveConflict(conflict.ID.Hex(), "owner_1", domain.ResolveSyncConflictRequest{BusinessID: businessID.Hex(), Resolution: domain.SyncResolutionClient})
+
+		assert.NoError(t, err)
+		recorder.AssertExpectations(t)
+	})
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(infrastructure.RequestIDMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, infrastructure.RequestID(c))
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(infrastructure.RequestIDHeader, "abc-123")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Body.String())
+	assert.Equal(t, "abc-123", w.Header().Get(infrastructure.RequestIDHeader))
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(infrastructure.RequestIDHeader, "not valid\n")
+	r.ServeHTTP(w, req)
+	assert.NotEqual(t, "not valid\n", w.Body.String())
+	assert.NotEmpty(t, w.Body.String())
+	assert.Equal(t, w.Body.String(), w.Header().Get(infrastructure.RequestIDHeader))
+}
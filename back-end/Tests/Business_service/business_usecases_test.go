@@ -39,6 +39,14 @@ func (m *MockBusinessRepository) FindByUserId(userId string) ([]*domain.Business
 	return args.Get(0).([]*domain.Business), args.Error(1)
 }
 
+func (m *MockBusinessRepository) FindByIDs(ids []primitive.ObjectID) ([]*domain.Business, error) {
+	args := m.Called(ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Business), args.Error(1)
+}
+
 func (m *MockBusinessRepository) Update(business *domain.Business) error {
 	args := m.Called(business)
 	return args.Error(0)
@@ -52,6 +60,31 @@ func (m *MockBusinessRepository) FindByNameAndUserId(name string, userId string)
 	return args.Get(0).(*domain.Business), args.Error(1)
 }
 
+func (m *MockBusinessRepository) AdoptLegacyDevice(businessId primitive.ObjectID, device domain.SyncDevice) error {
+	args := m.Called(businessId, device)
+	return args.Error(0)
+}
+
+func (m *MockBusinessRepository) AddDevice(businessId primitive.ObjectID, device domain.SyncDevice) error {
+	args := m.Called(businessId, device)
+	return args.Error(0)
+}
+
+func (m *MockBusinessRepository) ReactivateDevice(businessId primitive.ObjectID, device domain.SyncDevice) error {
+	args := m.Called(businessId, device)
+	return args.Error(0)
+}
+
+func (m *MockBusinessRepository) RevokeDevice(businessId primitive.ObjectID, deviceId string, at time.Time) error {
+	args := m.Called(businessId, deviceId, at)
+	return args.Error(0)
+}
+
+func (m *MockBusinessRepository) UpdateSyncPolicy(businessId primitive.ObjectID, policy domain.SyncConflictPolicy, at time.Time) error {
+	args := m.Called(businessId, policy, at)
+	return args.Error(0)
+}
+
 // --- Tests ---
 
 func TestCreateBusiness(t *testing.T) {
@@ -212,3 +245,233 @@ func TestUpdateBusiness(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestRegisterDevice(t *testing.T) {
+	mockRepo := new(MockBusinessRepository)
+	uc := usecases.NewBusinessUseCases(mockRepo)
+
+	userID := primitive.NewObjectID()
+	businessID := primitive.NewObjectID().Hex()
+
+	t.Run("Success", func(t *testing.T) {
+		business := &domain.Business{
+			ID:     primitive.NewObjectID(),
+			UserID: userID,
+			Devices: []domain.SyncDevice{
+				{DeviceID: "phone-1", Status: domain.DeviceStatusActive},
+			},
+		}
+
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+		mockRepo.On("AddDevice", business.ID, mock.MatchedBy(func(d domain.SyncDevice) bool {
+			return d.DeviceID == "phone-2" && d.Name == "Counter" && d.IsActive() && d.RegisteredBy == userID
+		})).Return(nil).Once()
+
+		device, err := uc.RegisterDevice(businessID, userID.Hex(), &usecases.RegisterDeviceRequest{DeviceID: "phone-2", Name: "Counter"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "phone-2", device.DeviceID)
+		assert.Equal(t, domain.DeviceStatusActive, device.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Adopts Legacy Device", func(t *testing.T) {
+		business := &domain.Business{
+			ID:           primitive.NewObjectID(),
+			UserID:       userID,
+			LegacyDevice: "phone-1",
+		}
+
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+		mockRepo.On("AdoptLegacyDevice", business.ID, mock.MatchedBy(func(d domain.SyncDevice) bool {
+			return d.DeviceID == "phone-1" && d.IsActive()
+		})).Return(nil).Once()
+		mockRepo.On("AddDevice", business.ID, mock.MatchedBy(func(d domain.SyncDevice) bool {
+			return d.DeviceID == "phone-2"
+		})).Return(nil).Once()
+
+		device, err := uc.RegisterDevice(businessID, userID.Hex(), &usecases.RegisterDeviceRequest{DeviceID: "phone-2"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "phone-2", device.DeviceID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Already Registered", func(t *testing.T) {
+		business := &domain.Business{
+			ID:     primitive.NewObjectID(),
+			UserID: userID,
+			Devices: []domain.SyncDevice{
+				{DeviceID: "phone-1", Status: domain.DeviceStatusActive},
+			},
+		}
+
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+
+		device, err := uc.RegisterDevice(businessID, userID.Hex(), &usecases.RegisterDeviceRequest{DeviceID: "phone-1"})
+
+		assert.Error(t, err)
+		assert.Nil(t, device)
+		assert.Equal(t, "device already registered", err.Error())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Loses Race To Another Registration", func(t *testing.T) {
+		business := &domain.Business{ID: primitive.NewObjectID(), UserID: userID}
+
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+		mockRepo.On("AddDevice", business.ID, mock.Anything).Return(domain.ErrDeviceRegistered).Once()
+
+		device, err := uc.RegisterDevice(businessID, userID.Hex(), &usecases.RegisterDeviceRequest{DeviceID: "phone-2"})
+
+		assert.ErrorIs(t, err, domain.ErrDeviceRegistered)
+		assert.Nil(t, device)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Reactivates Revoked Device", func(t *testing.T) {
+		revokedAt := time.Now()
+		business := &domain.Business{
+			ID:     primitive.NewObjectID(),
+			UserID: userID,
+			Devices: []domain.SyncDevice{
+				{DeviceID: "phone-1", Name: "Counter", Status: domain.DeviceStatusRevoked, RevokedAt: &revokedAt},
+			},
+		}
+
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+		mockRepo.On("ReactivateDevice", business.ID, mock.MatchedBy(func(d domain.SyncDevice) bool {
+			return d.DeviceID == "phone-1" && d.Name == "Counter" && d.IsActive()
+		})).Return(nil).Once()
+
+		device, err := uc.RegisterDevice(businessID, userID.Hex(), &usecases.RegisterDeviceRequest{DeviceID: "phone-1"})
+
+		assert.NoError(t, err)
+		assert.True(t, device.IsActive())
+		assert.Nil(t, device.RevokedAt)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		business := &domain.Business{ID: primitive.NewObjectID(), UserID: userID}
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+
+		device, err := uc.RegisterDevice(businessID, primitive.NewObjectID().Hex(), &usecases.RegisterDeviceRequest{DeviceID: "phone-2"})
+
+		assert.Error(t, err)
+		assert.Nil(t, device)
+		assert.Equal(t, "unauthorized", err.Error())
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestListDevices(t *testing.T) {
+	mockRepo := new(MockBusinessRepository)
+	uc := usecases.NewBusinessUseCases(mockRepo)
+
+	userID := primitive.NewObjectID()
+	businessID := primitive.NewObjectID().Hex()
+
+	t.Run("Adopts Legacy Device", func(t *testing.T) {
+		business := &domain.Business{ID: primitive.NewObjectID(), UserID: userID, LegacyDevice: "phone-1"}
+
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+		mockRepo.On("AdoptLegacyDevice", business.ID, mock.MatchedBy(func(d domain.SyncDevice) bool {
+			return d.DeviceID == "phone-1"
+		})).Return(nil).Once()
+
+		devices, err := uc.ListDevices(businessID, userID.Hex())
+
+		assert.NoError(t, err)
+		assert.Len(t, devices, 1)
+		assert.Equal(t, "phone-1", devices[0].DeviceID)
+		assert.True(t, devices[0].IsActive())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("No Devices", func(t *testing.T) {
+		business := &domain.Business{ID: primitive.NewObjectID(), UserID: userID}
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+
+		devices, err := uc.ListDevices(businessID, userID.Hex())
+
+		assert.NoError(t, err)
+		assert.Empty(t, devices)
+		assert.NotNil(t, devices)
+	})
+}
+
+func TestRevokeDevice(t *testing.T) {
+	mockRepo := new(MockBusinessRepository)
+	uc := usecases.NewBusinessUseCases(mockRepo)
+
+	userID := primitive.NewObjectID()
+	businessID := primitive.NewObjectID().Hex()
+
+	t.Run("Success", func(t *testing.T) {
+		business := &domain.Business{
+			ID:     primitive.NewObjectID(),
+			UserID: userID,
+			Devices: []domain.SyncDevice{
+				{DeviceID: "phone-1", Status: domain.DeviceStatusActive},
+				{DeviceID: "phone-2", Status: domain.DeviceStatusActive},
+			},
+		}
+
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+		mockRepo.On("RevokeDevice", business.ID, "phone-2", mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		err := uc.RevokeDevice(businessID, userID.Hex(), "phone-2")
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Device Not Found", func(t *testing.T) {
+		business := &domain.Business{ID: primitive.NewObjectID(), UserID: userID}
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+
+		err := uc.RevokeDevice(businessID, userID.Hex(), "phone-9")
+
+		assert.Error(t, err)
+		assert.Equal(t, "device not found", err.Error())
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestUpdateSyncPolicy(t *testing.T) {
+	mockRepo := new(MockBusinessRepository)
+	uc := usecases.NewBusinessUseCases(mockRepo)
+
+	userID := primitive.NewObjectID()
+	businessID := primitive.NewObjectID().Hex()
+
+	t.Run("Success", func(t *testing.T) {
+		business := &domain.Business{ID: primitive.NewObjectID(), UserID: userID}
+
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+		mockRepo.On("UpdateSyncPolicy", business.ID, domain.SyncPolicyManual, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		updated, err := uc.UpdateSyncPolicy(businessID, userID.Hex(), &usecases.UpdateSyncPolicyRequest{Policy: domain.SyncPolicyManual})
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SyncPolicyManual, updated.ConflictPolicy())
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Policy", func(t *testing.T) {
+		business := &domain.Business{ID: primitive.NewObjectID(), UserID: userID}
+		mockRepo.On("FindByID", businessID).Return(business, nil).Once()
+
+		updated, err := uc.UpdateSyncPolicy(businessID, userID.Hex(), &usecases.UpdateSyncPolicyRequest{Policy: "first_wins"})
+
+		assert.Nil(t, updated)
+		assert.Equal(t, domain.ErrInvalidSyncPolicy, err)
+		mockRepo.AssertNotCalled(t, "UpdateSyncPolicy")
+	})
+
+	t.Run("Defaults To Server Wins", func(t *testing.T) {
+		business := &domain.Business{}
+		assert.Equal(t, domain.SyncPolicyServerWins, business.ConflictPolicy())
+	})
+}
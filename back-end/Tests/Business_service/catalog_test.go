@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	Domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+	usecases "shop-ops/Usecases"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNormalizeCatalog(t *testing.T) {
+	t.Run("Codes and category are tidied", func(t *testing.T) {
+		product := &Domain.Product{SKU: " tee-01 ", Barcode: " 5901234123457 ", Category: "  Soft   drinks ", UnitOfMeasure: Domain.UnitLitre}
+
+		assert.NoError(t, product.NormalizeCatalog())
+		assert.Equal(t, "TEE-01", product.SKU)
+		assert.Equal(t, "5901234123457", product.Barcode)
+		assert.Equal(t, "Soft drinks", product.Category)
+		assert.Equal(t, Domain.UnitLitre, product.Unit())
+	})
+
+	t.Run("A product without a unit is counted in pieces", func(t *testing.T) {
+		product := &Domain.Product{}
+
+		assert.NoError(t, product.NormalizeCatalog())
+		assert.Equal(t, Domain.UnitPiece, product.Unit())
+	})
+
+	t.Run("Unknown units and barcodes with spaces are refused", func(t *testing.T) {
+		assert.ErrorIs(t, (&Domain.Product{UnitOfMeasure: "bucket"}).NormalizeCatalog(), Domain.ErrInvalidUnit)
+		assert.ErrorIs(t, (&Domain.Product{Barcode: "590 123"}).NormalizeCatalog(), Domain.ErrInvalidBarcode)
+	})
+
+	t.Run("A variant needs an attribute", func(t *testing.T) {
+		variant := &Domain.Product{ParentID: primitive.NewObjectID(), Attributes: map[string]string{" Size ": " "}}
+
+		assert.ErrorIs(t, variant.NormalizeCatalog(), Domain.ErrVariantAttributes)
+	})
+}
+
+func TestVariantName(t *testing.T) {
+	name := Domain.VariantName("T-shirt", map[string]string{"size": "L", "colour": "Red"})
+
+	assert.Equal(t, "T-shirt - Red / L", name)
+}
+
+func TestCreateVariant(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	parent := &Domain.Product{
+		ID: primitive.NewObjectID(), BusinessID: businessID, Name: "T-shirt", Category: "Clothing",
+		DefaultSellingPrice: decimal.RequireFromString("12.50"), LowStockThreshold: decimal.NewFromInt(3),
+	}
+	parentHex := parent.ID.Hex()
+	actor := Domain.AuditActor{UserID: primitive.NewObjectID().Hex()}
+
+	t.Run("A variant takes what it leaves out from its product", func(t *testing.T) {
+		productRepo := new(MockProductRepository)
+		productRepo.On("FindByID", parentHex).Return(parent, nil)
+		productRepo.On("Create", mock.AnythingOfType("*domain.Product")).Return(nil).Once()
+		uc := usecases.NewInventoryUseCase(productRepo, new(MockBusinessRepository), nil)
+
+		variant, err := uc.CreateVariant(parentHex, businessID.Hex(), actor, Domain.CreateVariantRequest{
+			BusinessID: businessID.Hex(), Attributes: map[string]string{"Size": "L"}, SKU: "tee-l", StockQuantity: 5,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "T-shirt - L", variant.Name)
+		assert.Equal(t, "TEE-L", variant.SKU)
+		assert.Equal(t, "Clothing", variant.Category)
+		assert.Equal(t, parentHex, variant.ParentID)
+		assert.Equal(t, "12.5", variant.DefaultSellingPrice.String())
+		assert.Equal(t, "3", variant.LowStockThreshold.String())
+		assert.Equal(t, map[string]string{"size": "L"}, variant.Attributes)
+		productRepo.AssertExpectations(t)
+	})
+
+	t.Run("A variant cannot have variants", func(t *testing.T) {
+		variant := &Domain.Product{ID: primitive.NewObjectID(), BusinessID: businessID, ParentID: parent.ID, Name: "T-shirt - L"}
+		productRepo := new(MockProductRepository)
+		productRepo.On("FindByID", variant.ID.Hex()).Return(variant, nil)
+		uc := usecases.NewInventoryUseCase(productRepo, new(MockBusinessRepository), nil)
+
+		_, err := uc.CreateVariant(variant.ID.Hex(), businessID.Hex(), actor, Domain.CreateVariantRequest{
+			BusinessID: businessID.Hex(), Attributes: map[string]string{"colour": "Red"},
+		})
+
+		assert.ErrorIs(t, err, Domain.ErrNestedVariant)
+		productRepo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+
+	t.Run("A product with variants cannot be deleted", func(t *testing.T) {
+		productRepo := new(MockProductRepository)
+		productRepo.On("FindByID", parentHex).Return(parent, nil)
+		productRepo.On("FindVariants", parentHex).Return([]Domain.Product{{ID: primitive.NewObjectID(), ParentID: parent.ID}}, nil)
+		uc := usecases.NewInventoryUseCase(productRepo, new(MockBusinessRepository), nil)
+
+		err := uc.DeleteProduct(parentHex, businessID.Hex(), actor)
+
+		assert.ErrorIs(t, err, Domain.ErrProductHasVariants)
+		productRepo.AssertNotCalled(t, "Delete", mock.Anything)
+	})
+}
+
+func TestCatalogCodesAreUniquePerBusiness(t *testing.T) {
+	db := openStockTestDB(t)
+	repo := repositories.NewInventoryRepository(db)
+	businessID := primitive.NewObjectID()
+
+	first := &Domain.Product{BusinessID: businessID, Name: "Cola", SKU: "COLA-1", Barcode: "5449000000996"}
+	assert.NoError(t, repo.Create(first))
+
+	t.Run("A second product with the SKU is refused", func(t *testing.T) {
+		err := repo.Create(&Domain.Product{BusinessID: businessID, Name: "Cola zero", SKU: "COLA-1"})
+		assert.ErrorIs(t, err, Domain.ErrDuplicateSKU)
+	})
+
+	t.Run("A second product with the barcode is refused", func(t *testing.T) {
+		err := repo.Create(&Domain.Product{BusinessID: businessID, Name: "Cola can", Barcode: "5449000000996"})
+		assert.ErrorIs(t, err, Domain.ErrDuplicateBarcode)
+	})
+
+	t.Run("Another business may use the same codes", func(t *testing.T) {
+		err := repo.Create(&Domain.Product{BusinessID: primitive.NewObjectID(), Name: "Cola", SKU: "COLA-1", Barcode: "5449000000996"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Products without codes do not clash", func(t *testing.T) {
+		assert.NoError(t, repo.Create(&Domain.Product{BusinessID: businessID, Name: "Bread"}))
+		assert.NoError(t, repo.Create(&Domain.Product{BusinessID: businessID, Name: "Milk"}))
+	})
+
+	t.Run("A scanned barcode finds its product", func(t *testing.T) {
+		products, total, err := repo.FindByBusinessID(businessID.Hex(), Domain.ProductListQuery{Barcode: " 5449000000996 "})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		if assert.Len(t, products, 1) {
+			assert.Equal(t, first.ID, products[0].ID)
+		}
+	})
+}
+
+func TestCatalogSyncError(t *testing.T) {
+	err := Domain.CatalogSyncError(Domain.ErrDuplicateBarcode)
+
+	fields := fieldErrors(t, err)
+	if assert.Len(t, fields, 1) {
+		assert.Equal(t, "data.barcode", fields[0].Path)
+		assert.Equal(t, Domain.SyncFieldTaken, fields[0].Code)
+	}
+	assert.Equal(t, Domain.ErrInsufficientStock, Domain.CatalogSyncError(Domain.ErrInsufficientStock))
+}
+
+func TestSyncBatch_SyncedProductsFollowCatalogRules(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := primitive.NewObjectID()
+	_, err := db.Collection("businesses").InsertOne(ctx, bson.M{
+		"_id":          businessID,
+		"sync_devices": bson.A{bson.M{"device_id": "device_1", "status": Domain.DeviceStatusActive, "registered_at": time.Now().UTC()}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, repositories.NewInventoryRepository(db).Create(&Domain.Product{BusinessID: businessID, Name: "Cola", SKU: "COLA-1"}))
+
+	response, err := repositories.NewSyncRepository(db).ProcessBatch(ctx, Domain.SyncBatchRequest{
+		BusinessID:    businessID.Hex(),
+		DeviceID:      "device_1",
+		SchemaVersion: Domain.SyncSchemaV2,
+		Transactions: []Domain.SyncBatchTransaction{
+			{LocalID: "p1", Type: Domain.SyncTransactionTypeProduct, Data: map[string]interface{}{
+				"name": "Cola zero", "sku": "cola-1", "default_selling_price": "2.00", "created_at": "2026-03-01T10:00:00Z",
+			}},
+			{LocalID: "p2", Type: Domain.SyncTransactionTypeProduct, Data: map[string]interface{}{
+				"name": "Juice", "sku": " juice-1 ", "category": " Soft   drinks ", "unit": "litre", "default_selling_price": "3.00", "created_at": "2026-03-01T10:00:00Z",
+			}},
+		},
+	})
+
+	assert.NoError(t, err)
+	if !assert.NotNil(t, response) || !assert.Len(t, response.Results, 2) {
+		return
+	}
+	assert.Equal(t, "failed", response.Results[0].Status)
+	assert.Equal(t, Domain.SyncItemCodeValidationFailed, response.Results[0].Code)
+	assert.Empty(t, response.Results[0].RetryID)
+
+	product, err := repositories.NewInventoryRepository(db).FindByID(response.Results[1].ServerID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, product) {
+		assert.Equal(t, "JUICE-1", product.SKU)
+		assert.Equal(t, "Soft drinks", product.Category)
+		assert.Equal(t, Domain.UnitLitre, product.Unit())
+	}
+}
@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	Domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+	usecases "shop-ops/Usecases"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIssueCost(t *testing.T) {
+	average := decimal.RequireFromString("2.5")
+	layers := []Domain.CostLayerUse{
+		{Quantity: decimal.NewFromInt(3), UnitCost: decimal.NewFromInt(2)},
+		{Quantity: decimal.NewFromInt(2), UnitCost: decimal.NewFromInt(3)},
+	}
+
+	t.Run("Weighted average costs every unit at the average", func(t *testing.T) {
+		cost := Domain.IssueCost(Domain.CostingWeightedAverage, decimal.NewFromInt(6), average, layers)
+		assert.Equal(t, "15", cost.String())
+	})
+
+	t.Run("FIFO costs units at their layers and the rest at the average", func(t *testing.T) {
+		cost := Domain.IssueCost(Domain.CostingFIFO, decimal.NewFromInt(6), average, layers)
+		assert.Equal(t, "14.5", cost.String())
+	})
+}
+
+func TestGrossMargin(t *testing.T) {
+	summary := Domain.NewProfitSummary(decimal.NewFromInt(200), decimal.NewFromInt(150), decimal.NewFromInt(30), time.Now(), time.Now())
+
+	assert.Equal(t, "50", summary.GrossProfit.String())
+	assert.Equal(t, "25", summary.GrossMargin.String())
+	assert.Equal(t, "170", summary.Profit.String())
+	assert.True(t, Domain.GrossMargin(decimal.Zero, decimal.NewFromInt(5)).IsZero())
+}
+
+func TestAdjustStock_UnitCost(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	product := &Domain.Product{ID: primitive.NewObjectID(), BusinessID: businessID, Name: "Flour", StockQuantity: decimal.NewFromInt(4)}
+	productHex := product.ID.Hex()
+	actor := Domain.AuditActor{UserID: primitive.NewObjectID().Hex()}
+	unitCost := 1.25
+
+	t.Run("A purchase at cost is received at that cost", func(t *testing.T) {
+		productRepo := new(MockProductRepository)
+		productRepo.On("FindByID", productHex).Return(product, nil)
+		productRepo.On("ReceiveStock", productHex, decimalOf("10"), decimalOf("1.25"), Domain.MovementTypePurchase, "Restock", mock.Anything, actor.UserID).Return(nil).Once()
+		uc := usecases.NewInventoryUseCase(productRepo, new(MockBusinessRepository), nil)
+
+		err := uc.AdjustStock(productHex, businessID.Hex(), actor, Domain.AdjustStockRequest{
+			BusinessID: businessID.Hex(), Quantity: 10, Type: Domain.MovementTypePurchase, Reason: "Restock", UnitCost: &unitCost,
+		})
+
+		assert.NoError(t, err)
+		productRepo.AssertExpectations(t)
+	})
+
+	t.Run("A cost on stock going out is refused", func(t *testing.T) {
+		productRepo := new(MockProductRepository)
+		productRepo.On("FindByID", productHex).Return(product, nil)
+		uc := usecases.NewInventoryUseCase(productRepo, new(MockBusinessRepository), nil)
+
+		err := uc.AdjustStock(productHex, businessID.Hex(), actor, Domain.AdjustStockRequest{
+			BusinessID: businessID.Hex(), Quantity: 1, Type: Domain.MovementTypeDamage, Reason: "Spilled", UnitCost: &unitCost,
+		})
+
+		assert.Error(t, err)
+		productRepo.AssertNotCalled(t, "AdjustStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestProfitSummary_GrossMargin(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	salesRepo := new(MockSaleRepository)
+	expenseRepo := new(MockExpenseRepository)
+	salesRepo.On("GetSummary", businessID.Hex(), mock.Anything, mock.Anything).
+		Return(&Domain.SaleSummaryResponse{TotalSales: 4, TotalRevenue: 120, CostOfGoods: 90}, nil)
+	expenseRepo.On("GetSummaryByCategory", mock.Anything, businessID, mock.Anything, mock.Anything).
+		Return(map[Domain.ExpenseCategory]decimal.Decimal{}, decimal.NewFromInt(100), nil)
+	uc := usecases.NewProfitUseCase(salesRepo, expenseRepo, new(MockBusinessRepository))
+
+	summary, err := uc.GetSummary(businessID.Hex(), Domain.ProfitQuery{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 90.0, summary.CostOfGoods)
+	assert.Equal(t, 30.0, summary.GrossProfit)
+	assert.Equal(t, 25.0, summary.GrossMargin)
+	assert.Equal(t, 20.0, summary.NetProfit)
+}
+
+// --- Costing against MongoDB ---
+
+func TestReceiveStock_BlendsAverageCost(t *testing.T) {
+	repo := repositories.NewInventoryRepository(openStockTestDB(t))
+	product := &Domain.Product{BusinessID: primitive.NewObjectID(), Name: "Rice", StockQuantity: decimal.NewFromInt(10), UnitCost: decimal.NewFromInt(2)}
+	assert.NoError(t, repo.Create(product))
+	userID := primitive.NewObjectID().Hex()
+
+	assert.NoError(t, repo.ReceiveStock(product.ID.Hex(), decimal.NewFromInt(30), decimal.NewFromInt(4), Domain.MovementTypePurchase, "Restock", nil, userID))
+	cost, err := repo.TakeStock(product.ID.Hex(), decimal.NewFromInt(4), Domain.MovementTypeSale, "Sale transaction", nil, userID)
+
+	assert.NoError(t, err)
+	after, _ := repo.FindByID(product.ID.Hex())
+	assert.Equal(t, "3.5", after.UnitCost.String())
+	assert.Equal(t, "14", cost.String())
+	assert.Equal(t, "36", assertStockMatchesLedger(t, repo, product.ID.Hex()).String())
+}
+
+func TestTakeStock_FIFOCostsOldestStockFirst(t *testing.T) {
+	db := openStockTestDB(t)
+	repo := repositories.NewInventoryRepository(db)
+	businessID := primitive.NewObjectID()
+	_, err := db.Collection("businesses").InsertOne(context.Background(), bson.M{"_id": businessID, "costing_method": Domain.CostingFIFO})
+	assert.NoError(t, err)
+	product := &Domain.Product{BusinessID: businessID, Name: "Oil", StockQuantity: decimal.NewFromInt(5), UnitCost: decimal.NewFromInt(2)}
+	assert.NoError(t, repo.Create(product))
+	userID := primitive.NewObjectID().Hex()
+
+	assert.NoError(t, repo.ReceiveStock(product.ID.Hex(), decimal.NewFromInt(5), decimal.NewFromInt(4), Domain.MovementTypePurchase, "Restock", nil, userID))
+	first, err := repo.TakeStock(product.ID.Hex(), decimal.NewFromInt(7), Domain.MovementTypeSale, "Sale transaction", nil, userID)
+	assert.NoError(t, err)
+	second, err := repo.TakeStock(product.ID.Hex(), decimal.NewFromInt(3), Domain.MovementTypeSale, "Sale transaction", nil, userID)
+	assert.NoError(t, err)
+
+	// 5 units at 2 and 2 at 4, then the last 3 at 4
+	assert.Equal(t, "18", first.String())
+	assert.Equal(t, "12", second.String())
+}
+
+func TestTakeStock_FractionalQuantities(t *testing.T) {
+	db := openStockTestDB(t)
+	repo := repositories.NewInventoryRepository(db)
+	businessID := primitive.NewObjectID()
+	_, err := db.Collection("businesses").InsertOne(context.Background(), bson.M{"_id": businessID, "costing_method": Domain.CostingFIFO})
+	assert.NoError(t, err)
+	product := &Domain.Product{BusinessID: businessID, Name: "Flour", UnitOfMeasure: Domain.UnitKilogram, StockQuantity: decimal.RequireFromString("2.5"), UnitCost: decimal.NewFromInt(4)}
+	assert.NoError(t, repo.Create(product))
+	userID := primitive.NewObjectID().Hex()
+
+	assert.NoError(t, repo.ReceiveStock(product.ID.Hex(), decimal.RequireFromString("1.5"), decimal.NewFromInt(6), Domain.MovementTypePurchase, "Restock", nil, userID))
+	cost, err := repo.TakeStock(product.ID.Hex(), decimal.NewFromInt(3), Domain.MovementTypeSale, "Sale transaction", nil, userID)
+	assert.NoError(t, err)
+	// 2.5 kg at 4 and 0.5 kg at 6
+	assert.Equal(t, "13", cost.String())
+
+	// Only 1 kg is left
+	_, err = repo.TakeStock(product.ID.Hex(), decimal.RequireFromString("1.25"), Domain.MovementTypeSale, "Sale transaction", nil, userID)
+	assert.ErrorIs(t, err, Domain.ErrInsufficientStock)
+	assert.Equal(t, "1", assertStockMatchesLedger(t, repo, product.ID.Hex()).String())
+}
+
+func TestSyncBatch_SyncedProductsAndSalesAreCosted(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := primitive.NewObjectID()
+	_, err := db.Collection("businesses").InsertOne(ctx, bson.M{
+		"_id":          businessID,
+		"sync_devices": bson.A{bson.M{"device_id": "device_1", "status": Domain.DeviceStatusActive, "registered_at": time.Now().UTC()}},
+	})
+	assert.NoError(t, err)
+
+	response, err := repositories.NewSyncRepository(db).ProcessBatch(ctx, Domain.SyncBatchRequest{
+		BusinessID:    businessID.Hex(),
+		DeviceID:      "device_1",
+		SchemaVersion: Domain.SyncSchemaV2,
+		Transactions: []Domain.SyncBatchTransaction{
+			{LocalID: "p1", Type: Domain.SyncTransactionTypeProduct, Data: map[string]interface{}{
+				"name": "Rice", "default_selling_price": "5.00", "unit_cost": "2.50", "stock_quantity": 10, "created_at": "2026-03-01T10:00:00Z",
+			}},
+			{LocalID: "s1", Type: Domain.SyncTransactionTypeSale, Data: map[string]interface{}{
+				"product_local_id": "p1", "quantity": 4, "amount": "20.00", "created_at": "2026-03-01T11:00:00Z",
+			}},
+		},
+	})
+
+	assert.NoError(t, err)
+	if !assert.NotNil(t, response) || !assert.Len(t, response.Results, 2) {
+		return
+	}
+	saleID, err := primitive.ObjectIDFromHex(response.Results[1].ServerID)
+	assert.NoError(t, err)
+	var sale Domain.Sale
+	assert.NoError(t, db.Collection("sales").FindOne(ctx, bson.M{"_id": saleID}).Decode(&sale))
+	assert.Equal(t, "10", sale.CostOfGoods().String())
+	layers, err := db.Collection("cost_layers").CountDocuments(ctx, bson.M{"business_id": businessID})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), layers)
+}
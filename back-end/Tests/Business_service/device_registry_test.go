@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+	usecases "shop-ops/Usecases"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRegisterDevice_ConcurrentRegistrationsAreAllKept(t *testing.T) {
+	db := openStockTestDB(t)
+	repo := repositories.NewBusinessRepository(db)
+	uc := usecases.NewBusinessUseCases(repo)
+
+	business := domain.NewBusiness(primitive.NewObjectID(), "Corner Shop", "ETB", "", "")
+	business.LegacyDevice = "phone-0"
+	assert.NoError(t, repo.Save(business))
+	businessID := business.ID.Hex()
+	owner := business.UserID.Hex()
+
+	const phones = 12
+	errs := runConcurrently(phones+1, func(i int) error {
+		if i == phones {
+			// A business edit racing the registrations must not drop any of them
+			_, err := uc.Update(businessID, owner, &usecases.UpdateBusinessRequest{Name: "Corner Shop & Cafe"})
+			return err
+		}
+		_, err := uc.RegisterDevice(businessID, owner, &usecases.RegisterDeviceRequest{DeviceID: fmt.Sprintf("phone-%d", i+1)})
+		return err
+	})
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	stored, err := repo.FindByID(businessID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Corner Shop & Cafe", stored.Name)
+	assert.Len(t, stored.Devices, phones+1)
+	for i := 0; i <= phones; i++ {
+		device := stored.FindDevice(fmt.Sprintf("phone-%d", i))
+		if assert.NotNil(t, device, "phone-%d", i) {
+			assert.True(t, device.IsActive())
+		}
+	}
+
+	// Registering one phone twice at once lets exactly one through
+	errs = runConcurrently(4, func(int) error {
+		_, err := uc.RegisterDevice(businessID, owner, &usecases.RegisterDeviceRequest{DeviceID: "tablet"})
+		return err
+	})
+	registered := 0
+	for _, err := range errs {
+		if err == nil {
+			registered++
+		} else {
+			assert.ErrorIs(t, err, domain.ErrDeviceRegistered)
+		}
+	}
+	assert.Equal(t, 1, registered)
+
+	assert.NoError(t, uc.RevokeDevice(businessID, owner, "phone-3"))
+	stored, err = repo.FindByID(businessID)
+	assert.NoError(t, err)
+	assert.Len(t, stored.Devices, phones+2)
+	if device := stored.FindDevice("phone-3"); assert.NotNil(t, device) {
+		assert.Equal(t, domain.DeviceStatusRevoked, device.Status)
+		assert.NotNil(t, device.RevokedAt)
+	}
+}
+
+func TestSyncFirstDevice_OnlyOneOfConcurrentDevicesIsAdmitted(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := primitive.NewObjectID()
+	_, err := db.Collection("businesses").InsertOne(ctx, bson.M{"_id": businessID, "user_id": primitive.NewObjectID()})
+	assert.NoError(t, err)
+	repo := repositories.NewSyncRepository(db)
+
+	// Every device finds the business without devices; only one may become
+	// its first device
+	const devices = 8
+	errs := runConcurrently(devices, func(i int) error {
+		upload := &domain.SyncUpload{BusinessID: businessID, DeviceID: fmt.Sprintf("phone-%d", i), TotalChunks: 1}
+		return repo.CreateUpload(ctx, upload)
+	})
+
+	admitted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			admitted++
+		case errors.Is(err, domain.ErrDeviceNotRegistered):
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	assert.Equal(t, 1, admitted)
+
+	var stored struct {
+		Devices []domain.SyncDevice `bson:"sync_devices"`
+	}
+	assert.NoError(t, db.Collection("businesses").FindOne(ctx, bson.M{"_id": businessID}).Decode(&stored))
+	assert.Len(t, stored.Devices, 1)
+}
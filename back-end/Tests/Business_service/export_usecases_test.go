@@ -2,6 +2,7 @@ package tests
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,8 +15,10 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// MockExportRepository implements Domain.ExportRepository for testing
+// MockExportRepository implements Domain.ExportRepository for testing. The
+// export runs in its own goroutine, so the map is guarded.
 type MockExportRepository struct {
+	mu       sync.Mutex
 	requests map[string]*Domain.ExportRequest
 }
 
@@ -26,6 +29,9 @@ func NewMockExportRepository() *MockExportRepository {
 }
 
 func (m *MockExportRepository) Create(request *Domain.ExportRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	request.ID = primitive.NewObjectID().Hex()
 	request.CreatedAt = time.Now()
 	request.UpdatedAt = time.Now()
@@ -34,6 +40,9 @@ func (m *MockExportRepository) Create(request *Domain.ExportRequest) error {
 }
 
 func (m *MockExportRepository) GetByID(id, businessID string) (*Domain.ExportRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	req, ok := m.requests[id]
 	if !ok || req.BusinessID != businessID {
 		return nil, nil
@@ -41,7 +50,22 @@ func (m *MockExportRepository) GetByID(id, businessID string) (*Domain.ExportReq
 	return req, nil
 }
 
+func (m *MockExportRepository) GetByFileURL(fileURL string) (*Domain.ExportRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, req := range m.requests {
+		if req.FileURL == fileURL {
+			return req, nil
+		}
+	}
+	return nil, nil
+}
+
 func (m *MockExportRepository) GetByBusiness(businessID string, limit, offset int) ([]Domain.ExportRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	var result []Domain.ExportRequest
 	for _, req := range m.requests {
 		if req.BusinessID == businessID {
@@ -52,6 +76,9 @@ func (m *MockExportRepository) GetByBusiness(businessID string, limit, offset in
 }
 
 func (m *MockExportRepository) CountByBusiness(businessID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	count := 0
 	for _, req := range m.requests {
 		if req.BusinessID == businessID {
@@ -62,6 +89,9 @@ func (m *MockExportRepository) CountByBusiness(businessID string) (int64, error)
 }
 
 func (m *MockExportRepository) UpdateStatus(id string, status Domain.ExportStatus, fileURL, errorMessage string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if req, ok := m.requests[id]; ok {
 		req.Status = status
 		req.FileURL = fileURL
@@ -99,6 +129,9 @@ func (m *MockSalesRepo) FindSince(businessID string, since time.Time) ([]Domain.
 func (m *MockSalesRepo) GetSummary(businessID string, startDate, endDate time.Time) (*Domain.SaleSummaryResponse, error) {
 	return &Domain.SaleSummaryResponse{}, nil
 }
+func (m *MockSalesRepo) GetProductMargins(businessID string, startDate, endDate time.Time) ([]Domain.ProductMargin, error) {
+	return []Domain.ProductMargin{}, nil
+}
 
 // Minimal mock product repo
 type MockProductRepo struct{}
@@ -116,12 +149,24 @@ func (m *MockProductRepo) FindSince(businessID string, since time.Time) ([]Domai
 }
 func (m *MockProductRepo) Update(product *Domain.Product) error { return nil }
 func (m *MockProductRepo) Delete(id string) error               { return nil }
-func (m *MockProductRepo) AdjustStock(productID string, quantity int, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
+func (m *MockProductRepo) AdjustStock(productID string, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
+	return nil
+}
+func (m *MockProductRepo) ReceiveStock(productID string, quantity, unitCost decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
 	return nil
 }
+func (m *MockProductRepo) TakeStock(productID string, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) (decimal.Decimal, error) {
+	return decimal.Zero, nil
+}
 func (m *MockProductRepo) GetLowStock(businessID string) ([]Domain.Product, error) {
 	return []Domain.Product{}, nil
 }
+func (m *MockProductRepo) FindVariants(parentID string) ([]Domain.Product, error) {
+	return []Domain.Product{}, nil
+}
+func (m *MockProductRepo) GetCategories(businessID string) ([]Domain.ProductCategory, error) {
+	return []Domain.ProductCategory{}, nil
+}
 func (m *MockProductRepo) GetStockHistory(productID string, limit int) ([]Domain.StockMovement, error) {
 	return []Domain.StockMovement{}, nil
 }
@@ -212,3 +257,30 @@ func TestExportUsecases_GetExportStatus(t *testing.T) {
 		assert.Nil(t, fetched)
 	})
 }
+
+func TestExportUsecases_GetExportByFilename(t *testing.T) {
+	mockExportRepo := NewMockExportRepository()
+	uc := usecases.NewExportUsecases(mockExportRepo, nil, &MockSalesRepo{}, &MockProductRepo{}, &MockExpenseRepo{}, &MockTransactionRepo{})
+
+	businessID := primitive.NewObjectID().Hex()
+	done := &Domain.ExportRequest{BusinessID: businessID, Status: Domain.ExportStatusCompleted, FileURL: "download/sales_export_1.csv"}
+	pending := &Domain.ExportRequest{BusinessID: businessID, Status: Domain.ExportStatusPending, FileURL: "download/sales_export_2.csv"}
+	mockExportRepo.Create(done)
+	mockExportRepo.Create(pending)
+
+	t.Run("A completed export is found by its file", func(t *testing.T) {
+		fetched, err := uc.GetExportByFilename("sales_export_1.csv")
+		assert.NoError(t, err)
+		if assert.NotNil(t, fetched) {
+			assert.Equal(t, businessID, fetched.BusinessID)
+		}
+	})
+
+	t.Run("Unfinished exports and other paths are not found", func(t *testing.T) {
+		for _, filename := range []string{"sales_export_2.csv", "../sales_export_1.csv", "unknown.csv"} {
+			fetched, err := uc.GetExportByFilename(filename)
+			assert.NoError(t, err)
+			assert.Nil(t, fetched, filename)
+		}
+	})
+}
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	usecases "shop-ops/Usecases"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Mock ---
+
+type MockInvitationRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvitationRepository) Save(invitation *domain.Invitation) error {
+	args := m.Called(invitation)
+	return args.Error(0)
+}
+
+func (m *MockInvitationRepository) FindByID(id primitive.ObjectID) (*domain.Invitation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) FindByCodeHash(codeHash string) (*domain.Invitation, error) {
+	args := m.Called(codeHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) FindByBusiness(businessId string) ([]*domain.Invitation, error) {
+	args := m.Called(businessId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) MarkAccepted(id primitive.ObjectID, userId primitive.ObjectID, at time.Time) error {
+	args := m.Called(id, userId, at)
+	return args.Error(0)
+}
+
+func (m *MockInvitationRepository) Revoke(businessId string, invitationId string, at time.Time) error {
+	args := m.Called(businessId, invitationId, at)
+	return args.Error(0)
+}
+
+func inviteCodeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// --- Tests ---
+
+func TestCreateInvitation(t *testing.T) {
+	ownerID := primitive.NewObjectID()
+	business := &domain.Business{ID: primitive.NewObjectID(), UserID: ownerID, Name: "Corner Shop"}
+	businessId := business.ID.Hex()
+
+	t.Run("Success", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		businessRepo := new(MockBusinessRepository)
+		userRepo := new(MockMemberUserRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, new(MockMembershipRepository), businessRepo, userRepo, "https://shop-ops.app/join")
+
+		businessRepo.On("FindByID", businessId).Return(business, nil).Once()
+		userRepo.On("FindByPhone", "+251911000000").Return(nil, nil).Once()
+		invitationRepo.On("Save", mock.AnythingOfType("*domain.Invitation")).Return(nil).Once()
+
+		invitation, err := uc.CreateInvitation(businessId, ownerID.Hex(), &domain.CreateInvitationRequest{
+			Phone: "+251911000000", Role: domain.RoleCashier, ExpiresInHours: 48,
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, invitation.Code, 8)
+		assert.Equal(t, inviteCodeHash(invitation.Code), invitation.CodeHash)
+		assert.Equal(t, "https://shop-ops.app/join?code="+invitation.Code, invitation.Link)
+		assert.Equal(t, domain.InvitationPending, invitation.Status)
+		assert.WithinDuration(t, time.Now().Add(48*time.Hour), invitation.ExpiresAt, time.Minute)
+		invitationRepo.AssertExpectations(t)
+	})
+
+	t.Run("Rejects invalid input", func(t *testing.T) {
+		uc := usecases.NewInvitationUseCases(new(MockInvitationRepository), new(MockMembershipRepository), new(MockBusinessRepository), new(MockMemberUserRepository), "")
+
+		_, err := uc.CreateInvitation(businessId, ownerID.Hex(), &domain.CreateInvitationRequest{Phone: "+251911000000", Role: domain.RoleOwner})
+		assert.ErrorIs(t, err, domain.ErrInvalidMemberRole)
+
+		_, err = uc.CreateInvitation(businessId, ownerID.Hex(), &domain.CreateInvitationRequest{Phone: "0911000000", Role: domain.RoleCashier})
+		assert.ErrorIs(t, err, domain.ErrInvalidPhone)
+
+		_, err = uc.CreateInvitation(businessId, ownerID.Hex(), &domain.CreateInvitationRequest{Phone: "+251911000000", Role: domain.RoleCashier, ExpiresInHours: 1000})
+		assert.ErrorIs(t, err, domain.ErrInvalidInvitationTTL)
+	})
+
+	t.Run("Existing member", func(t *testing.T) {
+		businessRepo := new(MockBusinessRepository)
+		userRepo := new(MockMemberUserRepository)
+		membershipRepo := new(MockMembershipRepository)
+		invitationRepo := new(MockInvitationRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, membershipRepo, businessRepo, userRepo, "")
+		staff := &domain.User{ID: primitive.NewObjectID(), Phone: "+251911000000"}
+
+		businessRepo.On("FindByID", businessId).Return(business, nil).Once()
+		userRepo.On("FindByPhone", staff.Phone).Return(staff, nil).Once()
+		membershipRepo.On("FindByBusinessAndUser", businessId, staff.ID.Hex()).Return(&domain.Membership{Role: domain.RoleCashier}, nil).Once()
+
+		_, err := uc.CreateInvitation(businessId, ownerID.Hex(), &domain.CreateInvitationRequest{Phone: staff.Phone, Role: domain.RoleManager})
+
+		assert.ErrorIs(t, err, domain.ErrAlreadyMember)
+		invitationRepo.AssertNotCalled(t, "Save")
+	})
+}
+
+func TestValidateInvitation(t *testing.T) {
+	pending := func() *domain.Invitation {
+		return &domain.Invitation{
+			ID:         primitive.NewObjectID(),
+			BusinessID: primitive.NewObjectID(),
+			Phone:      "+251911000000",
+			Role:       domain.RoleCashier,
+			Status:     domain.InvitationPending,
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}
+	}
+
+	t.Run("Normalizes the code", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, nil, nil, nil, "")
+		invitation := pending()
+
+		invitationRepo.On("FindByCodeHash", inviteCodeHash("ABCD2345")).Return(invitation, nil).Once()
+
+		found, err := uc.ValidateInvitation(" abcd-2345 ", "+251911000000")
+
+		assert.NoError(t, err)
+		assert.Equal(t, invitation.ID, found.ID)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, nil, nil, nil, "")
+		invitation := pending()
+		invitation.ExpiresAt = time.Now().Add(-time.Minute)
+
+		invitationRepo.On("FindByCodeHash", inviteCodeHash("ABCD2345")).Return(invitation, nil).Once()
+
+		_, err := uc.ValidateInvitation("ABCD2345", "+251911000000")
+
+		assert.ErrorIs(t, err, domain.ErrInvitationExpired)
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, nil, nil, nil, "")
+		invitation := pending()
+		invitation.Status = domain.InvitationRevoked
+
+		invitationRepo.On("FindByCodeHash", inviteCodeHash("ABCD2345")).Return(invitation, nil).Once()
+
+		_, err := uc.ValidateInvitation("ABCD2345", "+251911000000")
+
+		assert.ErrorIs(t, err, domain.ErrInvitationUsed)
+	})
+
+	t.Run("Different phone", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, nil, nil, nil, "")
+
+		invitationRepo.On("FindByCodeHash", inviteCodeHash("ABCD2345")).Return(pending(), nil).Once()
+
+		_, err := uc.ValidateInvitation("ABCD2345", "+251922000000")
+
+		assert.ErrorIs(t, err, domain.ErrInvitationPhoneMismatch)
+	})
+}
+
+func TestRedeemInvitation(t *testing.T) {
+	invitationRepo := new(MockInvitationRepository)
+	membershipRepo := new(MockMembershipRepository)
+	uc := usecases.NewInvitationUseCases(invitationRepo, membershipRepo, nil, nil, "")
+	invitation := &domain.Invitation{
+		ID:         primitive.NewObjectID(),
+		BusinessID: primitive.NewObjectID(),
+		Role:       domain.RoleManager,
+		InvitedBy:  primitive.NewObjectID(),
+		Phone:      "+251911000000",
+		Status:     domain.InvitationPending,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	user := &domain.User{ID: primitive.NewObjectID(), Phone: "+251911000000", PhoneVerified: true}
+
+	t.Run("Joins the business", func(t *testing.T) {
+		membershipRepo.On("FindByBusinessAndUser", invitation.BusinessID.Hex(), user.ID.Hex()).Return(nil, nil).Once()
+		membershipRepo.On("Save", mock.MatchedBy(func(m *domain.Membership) bool {
+			return m.BusinessID == invitation.BusinessID && m.UserID == user.ID && m.Role == domain.RoleManager && m.AddedBy == invitation.InvitedBy
+		})).Return(nil).Once()
+		invitationRepo.On("MarkAccepted", invitation.ID, user.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		err := uc.Redeem(invitation, user)
+
+		assert.NoError(t, err)
+		invitationRepo.AssertExpectations(t)
+		membershipRepo.AssertExpectations(t)
+	})
+
+	t.Run("Failed membership leaves the invitation pending", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		membershipRepo := new(MockMembershipRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, membershipRepo, nil, nil, "")
+
+		membershipRepo.On("FindByBusinessAndUser", invitation.BusinessID.Hex(), user.ID.Hex()).Return(nil, nil).Once()
+		membershipRepo.On("Save", mock.AnythingOfType("*domain.Membership")).Return(errors.New("db down")).Once()
+
+		err := uc.Redeem(invitation, user)
+
+		assert.EqualError(t, err, "db down")
+		invitationRepo.AssertNotCalled(t, "MarkAccepted", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Lost claim removes the membership", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		membershipRepo := new(MockMembershipRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, membershipRepo, nil, nil, "")
+
+		membershipRepo.On("FindByBusinessAndUser", invitation.BusinessID.Hex(), user.ID.Hex()).Return(nil, nil).Once()
+		membershipRepo.On("Save", mock.AnythingOfType("*domain.Membership")).Return(nil).Once()
+		invitationRepo.On("MarkAccepted", invitation.ID, user.ID, mock.AnythingOfType("time.Time")).Return(domain.ErrInvitationUsed).Once()
+		membershipRepo.On("Delete", invitation.BusinessID.Hex(), user.ID.Hex()).Return(nil).Once()
+
+		err := uc.Redeem(invitation, user)
+
+		assert.ErrorIs(t, err, domain.ErrInvitationUsed)
+		membershipRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unverified phone cannot claim it", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		membershipRepo := new(MockMembershipRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, membershipRepo, nil, nil, "")
+		claimant := &domain.User{ID: primitive.NewObjectID(), Phone: invitation.Phone}
+
+		err := uc.Redeem(invitation, claimant)
+
+		assert.ErrorIs(t, err, domain.ErrPhoneNotVerified)
+		membershipRepo.AssertNotCalled(t, "Save", mock.Anything)
+		invitationRepo.AssertNotCalled(t, "MarkAccepted", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("By ID checks the invitation again", func(t *testing.T) {
+		invitationRepo := new(MockInvitationRepository)
+		uc := usecases.NewInvitationUseCases(invitationRepo, new(MockMembershipRepository), nil, nil, "")
+		revoked := *invitation
+		revoked.Status = domain.InvitationRevoked
+
+		invitationRepo.On("FindByID", invitation.ID).Return(&revoked, nil).Once()
+		assert.ErrorIs(t, uc.RedeemByID(invitation.ID, user), domain.ErrInvitationUsed)
+
+		moved := &domain.User{ID: user.ID, Phone: "+251911000009", PhoneVerified: true}
+		invitationRepo.On("FindByID", invitation.ID).Return(invitation, nil).Once()
+		assert.ErrorIs(t, uc.RedeemByID(invitation.ID, moved), domain.ErrInvitationPhoneMismatch)
+	})
+}
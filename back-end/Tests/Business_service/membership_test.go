@@ -0,0 +1,363 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
+	usecases "shop-ops/Usecases"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Mocks ---
+
+type MockMembershipRepository struct {
+	mock.Mock
+}
+
+func (m *MockMembershipRepository) Save(membership *domain.Membership) error {
+	args := m.Called(membership)
+	return args.Error(0)
+}
+
+func (m *MockMembershipRepository) FindByBusinessAndUser(businessId string, userId string) (*domain.Membership, error) {
+	args := m.Called(businessId, userId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Membership), args.Error(1)
+}
+
+func (m *MockMembershipRepository) FindByBusiness(businessId string) ([]*domain.Membership, error) {
+	args := m.Called(businessId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Membership), args.Error(1)
+}
+
+func (m *MockMembershipRepository) FindByUser(userId string) ([]*domain.Membership, error) {
+	args := m.Called(userId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Membership), args.Error(1)
+}
+
+func (m *MockMembershipRepository) UpdateRole(businessId string, userId string, role domain.BusinessRole) error {
+	args := m.Called(businessId, userId, role)
+	return args.Error(0)
+}
+
+func (m *MockMembershipRepository) Delete(businessId string, userId string) error {
+	args := m.Called(businessId, userId)
+	return args.Error(0)
+}
+
+type MockMemberUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockMemberUserRepository) Save(user *domain.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockMemberUserRepository) FindById(id string) (*domain.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockMemberUserRepository) FindByPhone(phone string) (*domain.User, error) {
+	args := m.Called(phone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockMemberUserRepository) FindByEmail(email string) (*domain.User, error) {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockMemberUserRepository) Update(user *domain.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+// --- Roles ---
+
+func TestBusinessRole_Permissions(t *testing.T) {
+	assert.True(t, domain.RoleOwner.Can(domain.PermissionManageMembers))
+	assert.True(t, domain.RoleOwner.Can(domain.PermissionManageBusiness))
+
+	assert.True(t, domain.RoleManager.Can(domain.PermissionEditSales))
+	assert.True(t, domain.RoleManager.Can(domain.PermissionViewReports))
+	assert.True(t, domain.RoleManager.Can(domain.PermissionRecordSales))
+	assert.False(t, domain.RoleManager.Can(domain.PermissionManageMembers))
+	assert.False(t, domain.RoleManager.Can(domain.PermissionManageBusiness))
+
+	assert.True(t, domain.RoleCashier.Can(domain.PermissionRecordSales))
+	assert.True(t, domain.RoleCashier.Can(domain.PermissionSync))
+	assert.False(t, domain.RoleCashier.Can(domain.PermissionEditSales))
+	assert.False(t, domain.RoleCashier.Can(domain.PermissionManageProducts))
+	assert.False(t, domain.RoleCashier.Can(domain.PermissionViewReports))
+
+	assert.False(t, domain.BusinessRole("").Can(domain.PermissionViewBusiness))
+	assert.False(t, domain.IsValidBusinessRole("admin"))
+}
+
+func TestSyncPermission(t *testing.T) {
+	assert.Equal(t, domain.PermissionRecordSales, domain.SyncPermission(domain.SyncTransactionTypeSale))
+	assert.Equal(t, domain.PermissionEditSales, domain.SyncPermission(domain.SyncTransactionTypeSaleVoid))
+	assert.Equal(t, domain.PermissionAdjustStock, domain.SyncPermission(domain.SyncTransactionTypeStockAdjustment))
+}
+
+// --- Use cases ---
+
+func TestResolveRole(t *testing.T) {
+	ownerID := primitive.NewObjectID()
+	business := &domain.Business{ID: primitive.NewObjectID(), UserID: ownerID}
+	businessId := business.ID.Hex()
+
+	t.Run("Owner", func(t *testing.T) {
+		businessRepo := new(MockBusinessRepository)
+		membershipRepo := new(MockMembershipRepository)
+		uc := usecases.NewMembershipUseCases(membershipRepo, businessRepo, nil)
+
+		businessRepo.On("FindByID", businessId).Return(business, nil).Once()
+
+		role, err := uc.ResolveRole(businessId, ownerID.Hex())
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.RoleOwner, role)
+		membershipRepo.AssertNotCalled(t, "FindByBusinessAndUser")
+	})
+
+	t.Run("Staff", func(t *testing.T) {
+		businessRepo := new(MockBusinessRepository)
+		membershipRepo := new(MockMembershipRepository)
+		uc := usecases.NewMembershipUseCases(membershipRepo, businessRepo, nil)
+		staffID := primitive.NewObjectID().Hex()
+
+		businessRepo.On("FindByID", businessId).Return(business, nil).Once()
+		membershipRepo.On("FindByBusinessAndUser", businessId, staffID).Return(&domain.Membership{Role: domain.RoleCashier}, nil).Once()
+
+		role, err := uc.ResolveRole(businessId, staffID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.RoleCashier, role)
+	})
+
+	t.Run("Stranger", func(t *testing.T) {
+		businessRepo := new(MockBusinessRepository)
+		membershipRepo := new(MockMembershipRepository)
+		uc := usecases.NewMembershipUseCases(membershipRepo, businessRepo, nil)
+
+		businessRepo.On("FindByID", businessId).Return(business, nil).Once()
+		membershipRepo.On("FindByBusinessAndUser", businessId, mock.Anything).Return(nil, nil).Once()
+
+		_, err := uc.ResolveRole(businessId, primitive.NewObjectID().Hex())
+
+		assert.ErrorIs(t, err, domain.ErrNotMember)
+	})
+
+	t.Run("Invalid business ID", func(t *testing.T) {
+		uc := usecases.NewMembershipUseCases(new(MockMembershipRepository), new(MockBusinessRepository), nil)
+
+		_, err := uc.ResolveRole("not-an-id", ownerID.Hex())
+
+		assert.ErrorIs(t, err, domain.ErrBusinessNotFound)
+	})
+}
+
+func TestAddMember(t *testing.T) {
+	ownerID := primitive.NewObjectID()
+	business := &domain.Business{ID: primitive.NewObjectID(), UserID: ownerID}
+	businessId := business.ID.Hex()
+
+	t.Run("Success", func(t *testing.T) {
+		businessRepo := new(MockBusinessRepository)
+		membershipRepo := new(MockMembershipRepository)
+		userRepo := new(MockMemberUserRepository)
+		uc := usecases.NewMembershipUseCases(membershipRepo, businessRepo, userRepo)
+		staff := &domain.User{ID: primitive.NewObjectID(), Name: "Abebe", Phone: "+251911000000", PhoneVerified: true}
+
+		businessRepo.On("FindByID", businessId).Return(business, nil).Once()
+		userRepo.On("FindByPhone", staff.Phone).Return(staff, nil).Once()
+		membershipRepo.On("Save", mock.MatchedBy(func(m *domain.Membership) bool {
+			return m.BusinessID == business.ID && m.UserID == staff.ID && m.Role == domain.RoleCashier && m.AddedBy == ownerID
+		})).Return(nil).Once()
+
+		member, err := uc.AddMember(businessId, ownerID.Hex(), &domain.AddMemberRequest{Phone: staff.Phone, Role: domain.RoleCashier})
+
+		assert.NoError(t, err)
+		assert.Equal(t, staff.ID.Hex(), member.UserID)
+		assert.Equal(t, domain.RoleCashier, member.Role)
+		membershipRepo.AssertExpectations(t)
+	})
+
+	t.Run("Owner role cannot be granted", func(t *testing.T) {
+		uc := usecases.NewMembershipUseCases(new(MockMembershipRepository), new(MockBusinessRepository), new(MockMemberUserRepository))
+
+		_, err := uc.AddMember(businessId, ownerID.Hex(), &domain.AddMemberRequest{Phone: "+251911000000", Role: domain.RoleOwner})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidMemberRole)
+	})
+
+	t.Run("Unregistered phone", func(t *testing.T) {
+		businessRepo := new(MockBusinessRepository)
+		userRepo := new(MockMemberUserRepository)
+		uc := usecases.NewMembershipUseCases(new(MockMembershipRepository), businessRepo, userRepo)
+
+		businessRepo.On("FindByID", businessId).Return(business, nil).Once()
+		userRepo.On("FindByPhone", "+251911000001").Return(nil, nil).Once()
+
+		_, err := uc.AddMember(businessId, ownerID.Hex(), &domain.AddMemberRequest{Phone: "+251911000001", Role: domain.RoleManager})
+
+		assert.ErrorIs(t, err, domain.ErrUserNotRegistered)
+	})
+
+	t.Run("Unverified phone", func(t *testing.T) {
+		businessRepo := new(MockBusinessRepository)
+		membershipRepo := new(MockMembershipRepository)
+		userRepo := new(MockMemberUserRepository)
+		uc := usecases.NewMembershipUseCases(membershipRepo, businessRepo, userRepo)
+		claimant := &domain.User{ID: primitive.NewObjectID(), Phone: "+251911000002"}
+
+		businessRepo.On("FindByID", businessId).Return(business, nil).Once()
+		userRepo.On("FindByPhone", claimant.Phone).Return(claimant, nil).Once()
+
+		_, err := uc.AddMember(businessId, ownerID.Hex(), &domain.AddMemberRequest{Phone: claimant.Phone, Role: domain.RoleCashier})
+
+		assert.ErrorIs(t, err, domain.ErrPhoneNotVerified)
+		membershipRepo.AssertNotCalled(t, "Save", mock.Anything)
+	})
+}
+
+func TestRemoveMember_RefusesOwner(t *testing.T) {
+	ownerID := primitive.NewObjectID()
+	business := &domain.Business{ID: primitive.NewObjectID(), UserID: ownerID}
+	businessRepo := new(MockBusinessRepository)
+	membershipRepo := new(MockMembershipRepository)
+	uc := usecases.NewMembershipUseCases(membershipRepo, businessRepo, nil)
+
+	businessRepo.On("FindByID", business.ID.Hex()).Return(business, nil).Once()
+
+	err := uc.RemoveMember(business.ID.Hex(), ownerID.Hex())
+
+	assert.ErrorIs(t, err, domain.ErrOwnerMembership)
+	membershipRepo.AssertNotCalled(t, "Delete")
+}
+
+func TestListBusinesses_IncludesMemberships(t *testing.T) {
+	userID := primitive.NewObjectID()
+	owned := &domain.Business{ID: primitive.NewObjectID(), UserID: userID}
+	joined := &domain.Business{ID: primitive.NewObjectID(), UserID: primitive.NewObjectID()}
+	businessRepo := new(MockBusinessRepository)
+	membershipRepo := new(MockMembershipRepository)
+	uc := usecases.NewMembershipUseCases(membershipRepo, businessRepo, nil)
+
+	businessRepo.On("FindByUserId", userID.Hex()).Return([]*domain.Business{owned}, nil).Once()
+	membershipRepo.On("FindByUser", userID.Hex()).Return([]*domain.Membership{{BusinessID: joined.ID, Role: domain.RoleManager}}, nil).Once()
+	businessRepo.On("FindByIDs", []primitive.ObjectID{joined.ID}).Return([]*domain.Business{joined}, nil).Once()
+
+	businesses, err := uc.ListBusinesses(userID.Hex())
+
+	assert.NoError(t, err)
+	assert.Len(t, businesses, 2)
+	assert.Equal(t, domain.RoleOwner, businesses[0].Role)
+	assert.Equal(t, domain.RoleManager, businesses[1].Role)
+}
+
+// --- Authorization middleware ---
+
+func setupAuthorizerRouter(role domain.BusinessRole, business *domain.Business, userID string, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	businessRepo := new(MockBusinessRepository)
+	membershipRepo := new(MockMembershipRepository)
+	businessRepo.On("FindByID", business.ID.Hex()).Return(business, nil)
+	membershipRepo.On("FindByBusinessAndUser", business.ID.Hex(), userID).Return(&domain.Membership{Role: role}, nil)
+	access := usecases.NewMembershipUseCases(membershipRepo, businessRepo, nil)
+	authorizer := infrastructure.NewAuthorizer(access, infrastructure.NewLogger("error", ""))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	r.DELETE("/sales/:saleId", authorizer.Require(domain.PermissionEditSales), handler)
+	r.POST("/sales", authorizer.Require(domain.PermissionRecordSales), handler)
+	return r
+}
+
+func TestAuthorizer_Require(t *testing.T) {
+	business := &domain.Business{ID: primitive.NewObjectID(), UserID: primitive.NewObjectID()}
+	businessId := business.ID.Hex()
+	cashierID := primitive.NewObjectID().Hex()
+	ok := func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusOK, gin.H{"role": infrastructure.BusinessRole(c), "body": string(body)})
+	}
+
+	t.Run("Cashier cannot void a sale", func(t *testing.T) {
+		router := setupAuthorizerRouter(domain.RoleCashier, business, cashierID, ok)
+		req, _ := http.NewRequest("DELETE", "/sales/abc?business_id="+businessId, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		var body map[string]string
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.Equal(t, "AUTH_003", body["code"])
+	})
+
+	t.Run("Cashier records a sale and the body reaches the handler", func(t *testing.T) {
+		router := setupAuthorizerRouter(domain.RoleCashier, business, cashierID, ok)
+		payload := `{"business_id":"` + businessId + `","total":10}`
+		req, _ := http.NewRequest("POST", "/sales", bytes.NewBufferString(payload))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]string
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.Equal(t, "cashier", body["role"])
+		assert.Equal(t, payload, body["body"])
+	})
+
+	t.Run("Request naming two businesses is rejected", func(t *testing.T) {
+		router := setupAuthorizerRouter(domain.RoleCashier, business, cashierID, ok)
+		payload := `{"business_id":"` + primitive.NewObjectID().Hex() + `"}`
+		req, _ := http.NewRequest("POST", "/sales?business_id="+businessId, bytes.NewBufferString(payload))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Missing business ID", func(t *testing.T) {
+		router := setupAuthorizerRouter(domain.RoleCashier, business, cashierID, ok)
+		req, _ := http.NewRequest("POST", "/sales", bytes.NewBufferString(`{}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
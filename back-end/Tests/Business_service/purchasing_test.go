@@ -0,0 +1,248 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+	usecases "shop-ops/Usecases"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// --- Mocks ---
+
+type MockPurchasingRepository struct {
+	mock.Mock
+}
+
+func (m *MockPurchasingRepository) CreateSupplier(supplier *domain.Supplier) error {
+	args := m.Called(supplier)
+	return args.Error(0)
+}
+
+func (m *MockPurchasingRepository) FindSupplier(businessId string, supplierId string) (*domain.Supplier, error) {
+	args := m.Called(businessId, supplierId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Supplier), args.Error(1)
+}
+
+func (m *MockPurchasingRepository) ListSuppliers(businessId string) ([]*domain.Supplier, error) {
+	args := m.Called(businessId)
+	return args.Get(0).([]*domain.Supplier), args.Error(1)
+}
+
+func (m *MockPurchasingRepository) UpdateSupplier(supplier *domain.Supplier) error {
+	args := m.Called(supplier)
+	return args.Error(0)
+}
+
+func (m *MockPurchasingRepository) CreateOrder(order *domain.PurchaseOrder) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+func (m *MockPurchasingRepository) FindOrder(businessId string, orderId string) (*domain.PurchaseOrder, error) {
+	args := m.Called(businessId, orderId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PurchaseOrder), args.Error(1)
+}
+
+func (m *MockPurchasingRepository) ListOrders(businessId string, query domain.PurchaseOrderQuery) ([]*domain.PurchaseOrder, error) {
+	args := m.Called(businessId, query)
+	return args.Get(0).([]*domain.PurchaseOrder), args.Error(1)
+}
+
+func (m *MockPurchasingRepository) SaveOrder(order *domain.PurchaseOrder) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+func (m *MockPurchasingRepository) ReceiveOrder(order *domain.PurchaseOrder, expense *domain.Expense) error {
+	args := m.Called(order, expense)
+	return args.Error(0)
+}
+
+func (m *MockPurchasingRepository) SupplierSpend(businessId string, start, end time.Time) ([]domain.SupplierSpend, error) {
+	args := m.Called(businessId, start, end)
+	return args.Get(0).([]domain.SupplierSpend), args.Error(1)
+}
+
+// --- Tests ---
+
+func newOrderedPurchaseOrder(lines ...domain.PurchaseOrderLine) *domain.PurchaseOrder {
+	order := domain.NewPurchaseOrder(primitive.NewObjectID(), primitive.NewObjectID(), lines, "", primitive.NewObjectID())
+	_ = order.Place(time.Now())
+	return order
+}
+
+func TestPurchaseOrder_Receive(t *testing.T) {
+	rice, oil := primitive.NewObjectID(), primitive.NewObjectID()
+	lines := func() []domain.PurchaseOrderLine {
+		return []domain.PurchaseOrderLine{
+			{ProductID: rice, Quantity: decimal.NewFromInt(10), UnitCost: decimal.RequireFromString("1.5")},
+			{ProductID: oil, Quantity: decimal.NewFromInt(4), UnitCost: decimal.NewFromInt(3)},
+		}
+	}
+
+	t.Run("Partial receipts move the order to received", func(t *testing.T) {
+		order := newOrderedPurchaseOrder(lines()...)
+		assert.Equal(t, "27", order.Total.String())
+
+		receipt, err := order.Receive([]domain.PurchaseReceiptLine{{ProductID: rice, Quantity: decimal.NewFromInt(6), UnitCost: decimal.RequireFromString("1.5")}}, primitive.NewObjectID(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, "9", receipt.Amount.String())
+		assert.Equal(t, domain.PurchaseOrderPartiallyReceived, order.Status)
+		assert.Equal(t, "4", order.Lines[0].Outstanding().String())
+
+		_, err = order.Receive(order.OutstandingLines(), primitive.NewObjectID(), time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, domain.PurchaseOrderReceived, order.Status)
+		assert.NotNil(t, order.ReceivedAt)
+		assert.Len(t, order.Receipts, 2)
+		assert.Equal(t, "18", order.Receipts[1].Amount.String())
+	})
+
+	t.Run("More than is outstanding is refused", func(t *testing.T) {
+		order := newOrderedPurchaseOrder(lines()...)
+
+		_, err := order.Receive([]domain.PurchaseReceiptLine{
+			{ProductID: oil, Quantity: decimal.NewFromInt(3), UnitCost: decimal.NewFromInt(3)},
+			{ProductID: oil, Quantity: decimal.NewFromInt(2), UnitCost: decimal.NewFromInt(3)},
+		}, primitive.NewObjectID(), time.Now())
+
+		assert.ErrorIs(t, err, domain.ErrOverReceipt)
+		assert.Equal(t, domain.PurchaseOrderOrdered, order.Status)
+		assert.True(t, order.Lines[1].Received.IsZero())
+	})
+
+	t.Run("A draft cannot be received", func(t *testing.T) {
+		order := domain.NewPurchaseOrder(primitive.NewObjectID(), primitive.NewObjectID(), lines(), "", primitive.NewObjectID())
+
+		_, err := order.Receive(order.OutstandingLines(), primitive.NewObjectID(), time.Now())
+
+		assert.ErrorIs(t, err, domain.ErrPurchaseOrderNotOpen)
+	})
+}
+
+func TestPurchaseOrder_Transitions(t *testing.T) {
+	line := domain.PurchaseOrderLine{ProductID: primitive.NewObjectID(), Quantity: decimal.NewFromInt(1), UnitCost: decimal.NewFromInt(2)}
+
+	order := newOrderedPurchaseOrder(line)
+	assert.ErrorIs(t, order.Place(time.Now()), domain.ErrInvalidPurchaseOrderState)
+
+	_, err := order.Receive(order.OutstandingLines(), primitive.NewObjectID(), time.Now())
+	assert.NoError(t, err)
+	assert.ErrorIs(t, order.Cancel(time.Now()), domain.ErrInvalidPurchaseOrderState)
+
+	draft := domain.NewPurchaseOrder(primitive.NewObjectID(), primitive.NewObjectID(), []domain.PurchaseOrderLine{line, line}, "", primitive.NewObjectID())
+	assert.ErrorIs(t, draft.Validate(), domain.ErrInvalidPurchaseLine)
+	assert.NoError(t, draft.Cancel(time.Now()))
+	assert.Equal(t, domain.PurchaseOrderCancelled, draft.Status)
+}
+
+func TestReceivePurchaseOrder_RecordsLinkedExpense(t *testing.T) {
+	productID := primitive.NewObjectID()
+	order := newOrderedPurchaseOrder(domain.PurchaseOrderLine{ProductID: productID, Quantity: decimal.NewFromInt(10), UnitCost: decimal.NewFromInt(2)})
+	businessHex, orderHex := order.BusinessID.Hex(), order.ID.Hex()
+	supplier := &domain.Supplier{ID: order.SupplierID, BusinessID: order.BusinessID, Name: "Mama Rice"}
+	actor := domain.AuditActor{UserID: primitive.NewObjectID().Hex()}
+	unitCost := 2.5
+
+	repo := new(MockPurchasingRepository)
+	repo.On("FindOrder", businessHex, orderHex).Return(order, nil)
+	repo.On("FindSupplier", businessHex, order.SupplierID.Hex()).Return(supplier, nil)
+	repo.On("ReceiveOrder", order, mock.MatchedBy(func(e *domain.Expense) bool {
+		return e.Category == domain.ExpenseStockPurchase &&
+			e.Amount.Equal(decimal.NewFromInt(10)) &&
+			*e.SupplierID == order.SupplierID &&
+			*e.PurchaseOrderID == order.ID
+	})).Return(nil).Once()
+	audit := new(MockAuditRecorder)
+	audit.On("Record", mock.MatchedBy(func(e *domain.AuditEntry) bool { return e.Action == domain.AuditActionReceive })).Once()
+	uc := usecases.NewPurchasingUseCases(repo, new(MockProductRepository), audit)
+
+	received, err := uc.ReceivePurchaseOrder(businessHex, orderHex, actor, &domain.ReceivePurchaseOrderRequest{
+		Lines: []domain.ReceiveLineRequest{{ProductID: productID.Hex(), Quantity: 4, UnitCost: &unitCost}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.PurchaseOrderPartiallyReceived, received.Status)
+	assert.Equal(t, "2.5", received.Receipts[0].Lines[0].UnitCost.String())
+	assert.False(t, received.Receipts[0].ExpenseID.IsZero())
+	repo.AssertExpectations(t)
+	audit.AssertExpectations(t)
+}
+
+func TestCreatePurchaseOrder_RejectsOtherBusinessProduct(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	supplier := &domain.Supplier{ID: primitive.NewObjectID(), BusinessID: businessID, Name: "Wholesaler"}
+	product := &domain.Product{ID: primitive.NewObjectID(), BusinessID: primitive.NewObjectID(), Name: "Sugar"}
+
+	repo := new(MockPurchasingRepository)
+	repo.On("FindSupplier", businessID.Hex(), supplier.ID.Hex()).Return(supplier, nil)
+	productRepo := new(MockProductRepository)
+	productRepo.On("FindByID", product.ID.Hex()).Return(product, nil)
+	uc := usecases.NewPurchasingUseCases(repo, productRepo, nil)
+
+	_, err := uc.CreatePurchaseOrder(businessID.Hex(), domain.AuditActor{}, &domain.PurchaseOrderRequest{
+		SupplierID: supplier.ID.Hex(),
+		Lines:      []domain.PurchaseOrderLineRequest{{ProductID: product.ID.Hex(), Quantity: 5, UnitCost: 1}},
+	})
+
+	assert.ErrorIs(t, err, domain.ErrInvalidPurchaseLine)
+	repo.AssertNotCalled(t, "CreateOrder", mock.Anything)
+}
+
+// --- Purchasing against MongoDB ---
+
+func TestReceiveOrder_StocksProductAndReportsSpend(t *testing.T) {
+	db := openStockTestDB(t)
+	inventory := repositories.NewInventoryRepository(db)
+	purchasing := repositories.NewPurchasingRepository(db)
+	uc := usecases.NewPurchasingUseCases(purchasing, inventory, nil)
+	actor := domain.AuditActor{UserID: primitive.NewObjectID().Hex()}
+
+	product := &domain.Product{BusinessID: primitive.NewObjectID(), Name: "Beans", StockQuantity: decimal.NewFromInt(2), UnitCost: decimal.NewFromInt(1)}
+	assert.NoError(t, inventory.Create(product))
+	businessHex := product.BusinessID.Hex()
+	supplier, err := uc.CreateSupplier(businessHex, actor, &domain.SupplierRequest{Name: "Farm Co"})
+	assert.NoError(t, err)
+	order, err := uc.CreatePurchaseOrder(businessHex, actor, &domain.PurchaseOrderRequest{
+		SupplierID: supplier.ID.Hex(),
+		Lines:      []domain.PurchaseOrderLineRequest{{ProductID: product.ID.Hex(), Quantity: 8, UnitCost: 2}},
+	})
+	assert.NoError(t, err)
+	_, err = uc.PlacePurchaseOrder(businessHex, order.ID.Hex(), actor)
+	assert.NoError(t, err)
+
+	_, err = uc.ReceivePurchaseOrder(businessHex, order.ID.Hex(), actor, &domain.ReceivePurchaseOrderRequest{
+		Lines: []domain.ReceiveLineRequest{{ProductID: product.ID.Hex(), Quantity: 3}},
+	})
+	assert.NoError(t, err)
+	order, err = uc.ReceivePurchaseOrder(businessHex, order.ID.Hex(), actor, &domain.ReceivePurchaseOrderRequest{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, domain.PurchaseOrderReceived, order.Status)
+	assert.Equal(t, "10", assertStockMatchesLedger(t, inventory, product.ID.Hex()).String())
+	expenses, err := db.Collection("expenses").CountDocuments(context.Background(), bson.M{"purchase_order_id": order.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), expenses)
+
+	spend, err := uc.GetSupplierSpend(businessHex, domain.SupplierSpendQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, spend.Suppliers, 1)
+	assert.Equal(t, "Farm Co", spend.Suppliers[0].SupplierName)
+	assert.Equal(t, 2, spend.Suppliers[0].Receipts)
+	assert.Equal(t, "16", spend.Total.String())
+}
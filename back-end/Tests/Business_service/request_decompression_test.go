@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	infrastructure "shop-ops/Infrastructure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDecompressionRouter(maxBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/echo", infrastructure.DecompressBody(maxBytes), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+func TestDecompressBody(t *testing.T) {
+	payload := []byte(`{"business_id":"biz_123","transactions":[]}`)
+
+	t.Run("Plain body passes through", func(t *testing.T) {
+		r := setupDecompressionRouter(1 << 20)
+		req, _ := http.NewRequest(http.MethodPost, "/echo", bytes.NewReader(payload))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, string(payload), w.Body.String())
+	})
+
+	t.Run("Gzip body is decoded", func(t *testing.T) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write(payload)
+		_ = gz.Close()
+
+		r := setupDecompressionRouter(1 << 20)
+		req, _ := http.NewRequest(http.MethodPost, "/echo", &buf)
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, string(payload), w.Body.String())
+	})
+
+	t.Run("Zstd body is decoded", func(t *testing.T) {
+		encoder, _ := zstd.NewWriter(nil)
+		compressed := encoder.EncodeAll(payload, nil)
+		_ = encoder.Close()
+
+		r := setupDecompressionRouter(1 << 20)
+		req, _ := http.NewRequest(http.MethodPost, "/echo", bytes.NewReader(compressed))
+		req.Header.Set("Content-Encoding", "zstd")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, string(payload), w.Body.String())
+	})
+
+	t.Run("Decoded body is capped", func(t *testing.T) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write(bytes.Repeat([]byte("a"), 4096))
+		_ = gz.Close()
+
+		r := setupDecompressionRouter(1024)
+		req, _ := http.NewRequest(http.MethodPost, "/echo", &buf)
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("Unsupported encoding is rejected", func(t *testing.T) {
+		r := setupDecompressionRouter(1 << 20)
+		req, _ := http.NewRequest(http.MethodPost, "/echo", bytes.NewReader(payload))
+		req.Header.Set("Content-Encoding", "br")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+}
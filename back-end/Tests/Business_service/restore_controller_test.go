@@ -9,6 +9,7 @@ import (
 
 	Domain "shop-ops/Domain"
 	"shop-ops/Delivery/controllers"
+	infrastructure "shop-ops/Infrastructure"
 	usecases "shop-ops/Usecases"
 
 	"github.com/gin-gonic/gin"
@@ -23,66 +24,32 @@ type MockRestoreUseCases struct {
 	mock.Mock
 }
 
-func (m *MockRestoreUseCases) FullRestore(businessID string, include []string) (*Domain.RestoreResponse, error) {
-	args := m.Called(businessID, include)
+func (m *MockRestoreUseCases) FullRestore(businessID string, role Domain.BusinessRole, include []string) (*Domain.RestoreResponse, error) {
+	args := m.Called(businessID, role, include)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*Domain.RestoreResponse), args.Error(1)
 }
 
-func (m *MockRestoreUseCases) IncrementalRestore(businessID string, since time.Time, include []string) (*Domain.RestoreResponse, error) {
-	args := m.Called(businessID, since, include)
+func (m *MockRestoreUseCases) IncrementalRestore(businessID string, role Domain.BusinessRole, since time.Time, include []string) (*Domain.RestoreResponse, error) {
+	args := m.Called(businessID, role, since, include)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*Domain.RestoreResponse), args.Error(1)
 }
 
-// --- Mock BusinessUseCases for RestoreController ---
-
-type MockRestoreBusinessUseCases struct {
-	mock.Mock
-}
-
-func (m *MockRestoreBusinessUseCases) Create(userId string, req *usecases.CreateBusinessRequest) (*Domain.Business, error) {
-	args := m.Called(userId, req)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*Domain.Business), args.Error(1)
-}
-
-func (m *MockRestoreBusinessUseCases) GetByUserId(userId string) ([]*Domain.Business, error) {
-	args := m.Called(userId)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).([]*Domain.Business), args.Error(1)
-}
-
-func (m *MockRestoreBusinessUseCases) GetById(businessId string) (*Domain.Business, error) {
-	args := m.Called(businessId)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*Domain.Business), args.Error(1)
-}
-
-func (m *MockRestoreBusinessUseCases) Update(businessId string, userId string, req *usecases.UpdateBusinessRequest) (*Domain.Business, error) {
-	args := m.Called(businessId, userId, req)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*Domain.Business), args.Error(1)
-}
-
 // --- Helper ---
 
-func setupRestoreRouter(restoreUC *MockRestoreUseCases, businessUC *MockRestoreBusinessUseCases) *gin.Engine {
+func setupRestoreRouter(restoreUC *MockRestoreUseCases, businessRepo *MockBusinessRepository) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	controller := controllers.NewRestoreController(restoreUC, businessUC)
+	controller := controllers.NewRestoreController(restoreUC)
+	membershipRepo := new(MockMembershipRepository)
+	membershipRepo.On("FindByBusinessAndUser", mock.Anything, mock.Anything).Return(nil, nil)
+	access := usecases.NewMembershipUseCases(membershipRepo, businessRepo, nil)
+	authorizer := infrastructure.NewAuthorizer(access, infrastructure.NewLogger("error", ""))
 
 	protected := r.Group("/")
 	protected.Use(func(c *gin.Context) {
@@ -96,8 +63,8 @@ func setupRestoreRouter(restoreUC *MockRestoreUseCases, businessUC *MockRestoreB
 
 	restoreGroup := protected.Group("/businesses/:businessId/restore")
 	{
-		restoreGroup.GET("", controller.FullRestore)
-		restoreGroup.GET("/incremental", controller.IncrementalRestore)
+		restoreGroup.GET("", authorizer.Require(Domain.PermissionRestoreData), controller.FullRestore)
+		restoreGroup.GET("/incremental", authorizer.Require(Domain.PermissionRestoreData), controller.IncrementalRestore)
 	}
 
 	return r
@@ -116,7 +83,7 @@ func TestRestoreControllerFullRestore(t *testing.T) {
 
 	t.Run("Success - Full Restore", func(t *testing.T) {
 		mockRestoreUC := new(MockRestoreUseCases)
-		mockBusinessUC := new(MockRestoreBusinessUseCases)
+		mockBusinessRepo := new(MockBusinessRepository)
 
 		restoreResp := &Domain.RestoreResponse{
 			Sales:      []Domain.Sale{{ID: primitive.NewObjectID(), BusinessID: businessID}},
@@ -125,10 +92,10 @@ func TestRestoreControllerFullRestore(t *testing.T) {
 			RestoredAt: time.Now(),
 		}
 
-		mockBusinessUC.On("GetById", businessID.Hex()).Return(business, nil).Once()
-		mockRestoreUC.On("FullRestore", businessID.Hex(), []string(nil)).Return(restoreResp, nil).Once()
+		mockBusinessRepo.On("FindByID", businessID.Hex()).Return(business, nil).Once()
+		mockRestoreUC.On("FullRestore", businessID.Hex(), Domain.RoleOwner, []string(nil)).Return(restoreResp, nil).Once()
 
-		router := setupRestoreRouter(mockRestoreUC, mockBusinessUC)
+		router := setupRestoreRouter(mockRestoreUC, mockBusinessRepo)
 		req, _ := http.NewRequest("GET", "/businesses/"+businessID.Hex()+"/restore", nil)
 		req.Header.Set("X-Test-User-ID", userID.Hex())
 		w := httptest.NewRecorder()
@@ -143,22 +110,22 @@ func TestRestoreControllerFullRestore(t *testing.T) {
 		assert.Len(t, resp.Expenses, 1)
 		assert.Len(t, resp.Products, 1)
 		mockRestoreUC.AssertExpectations(t)
-		mockBusinessUC.AssertExpectations(t)
+		mockBusinessRepo.AssertExpectations(t)
 	})
 
 	t.Run("Success - With Include Filter", func(t *testing.T) {
 		mockRestoreUC := new(MockRestoreUseCases)
-		mockBusinessUC := new(MockRestoreBusinessUseCases)
+		mockBusinessRepo := new(MockBusinessRepository)
 
 		restoreResp := &Domain.RestoreResponse{
 			Sales:      []Domain.Sale{{ID: primitive.NewObjectID(), BusinessID: businessID}},
 			RestoredAt: time.Now(),
 		}
 
-		mockBusinessUC.On("GetById", businessID.Hex()).Return(business, nil).Once()
-		mockRestoreUC.On("FullRestore", businessID.Hex(), []string{"sales"}).Return(restoreResp, nil).Once()
+		mockBusinessRepo.On("FindByID", businessID.Hex()).Return(business, nil).Once()
+		mockRestoreUC.On("FullRestore", businessID.Hex(), Domain.RoleOwner, []string{"sales"}).Return(restoreResp, nil).Once()
 
-		router := setupRestoreRouter(mockRestoreUC, mockBusinessUC)
+		router := setupRestoreRouter(mockRestoreUC, mockBusinessRepo)
 		req, _ := http.NewRequest("GET", "/businesses/"+businessID.Hex()+"/restore?include=sales", nil)
 		req.Header.Set("X-Test-User-ID", userID.Hex())
 		w := httptest.NewRecorder()
@@ -170,9 +137,9 @@ func TestRestoreControllerFullRestore(t *testing.T) {
 
 	t.Run("Unauthorized - No Auth", func(t *testing.T) {
 		mockRestoreUC := new(MockRestoreUseCases)
-		mockBusinessUC := new(MockRestoreBusinessUseCases)
+		mockBusinessRepo := new(MockBusinessRepository)
 
-		router := setupRestoreRouter(mockRestoreUC, mockBusinessUC)
+		router := setupRestoreRouter(mockRestoreUC, mockBusinessRepo)
 		req, _ := http.NewRequest("GET", "/businesses/"+businessID.Hex()+"/restore", nil)
 		// No X-Test-User-ID header
 		w := httptest.NewRecorder()
@@ -183,12 +150,12 @@ func TestRestoreControllerFullRestore(t *testing.T) {
 
 	t.Run("Forbidden - Non-owner", func(t *testing.T) {
 		mockRestoreUC := new(MockRestoreUseCases)
-		mockBusinessUC := new(MockRestoreBusinessUseCases)
+		mockBusinessRepo := new(MockBusinessRepository)
 		otherUserID := primitive.NewObjectID()
 
-		mockBusinessUC.On("GetById", businessID.Hex()).Return(business, nil).Once()
+		mockBusinessRepo.On("FindByID", businessID.Hex()).Return(business, nil).Once()
 
-		router := setupRestoreRouter(mockRestoreUC, mockBusinessUC)
+		router := setupRestoreRouter(mockRestoreUC, mockBusinessRepo)
 		req, _ := http.NewRequest("GET", "/businesses/"+businessID.Hex()+"/restore", nil)
 		req.Header.Set("X-Test-User-ID", otherUserID.Hex())
 		w := httptest.NewRecorder()
@@ -211,7 +178,7 @@ func TestRestoreControllerIncrementalRestore(t *testing.T) {
 
 	t.Run("Success - Incremental Restore", func(t *testing.T) {
 		mockRestoreUC := new(MockRestoreUseCases)
-		mockBusinessUC := new(MockRestoreBusinessUseCases)
+		mockBusinessRepo := new(MockBusinessRepository)
 
 		restoreResp := &Domain.RestoreResponse{
 			Sales:      []Domain.Sale{{ID: primitive.NewObjectID(), BusinessID: businessID}},
@@ -219,10 +186,10 @@ func TestRestoreControllerIncrementalRestore(t *testing.T) {
 			RestoredAt: time.Now(),
 		}
 
-		mockBusinessUC.On("GetById", businessID.Hex()).Return(business, nil).Once()
-		mockRestoreUC.On("IncrementalRestore", businessID.Hex(), sinceTime, []string(nil)).Return(restoreResp, nil).Once()
+		mockBusinessRepo.On("FindByID", businessID.Hex()).Return(business, nil).Once()
+		mockRestoreUC.On("IncrementalRestore", businessID.Hex(), Domain.RoleOwner, sinceTime, []string(nil)).Return(restoreResp, nil).Once()
 
-		router := setupRestoreRouter(mockRestoreUC, mockBusinessUC)
+		router := setupRestoreRouter(mockRestoreUC, mockBusinessRepo)
 		req, _ := http.NewRequest("GET", "/businesses/"+businessID.Hex()+"/restore/incremental?since="+sinceStr, nil)
 		req.Header.Set("X-Test-User-ID", userID.Hex())
 		w := httptest.NewRecorder()
@@ -230,14 +197,16 @@ func TestRestoreControllerIncrementalRestore(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		mockRestoreUC.AssertExpectations(t)
-		mockBusinessUC.AssertExpectations(t)
+		mockBusinessRepo.AssertExpectations(t)
 	})
 
 	t.Run("Bad Request - Missing since", func(t *testing.T) {
 		mockRestoreUC := new(MockRestoreUseCases)
-		mockBusinessUC := new(MockRestoreBusinessUseCases)
+		mockBusinessRepo := new(MockBusinessRepository)
+
+		mockBusinessRepo.On("FindByID", businessID.Hex()).Return(business, nil).Once()
 
-		router := setupRestoreRouter(mockRestoreUC, mockBusinessUC)
+		router := setupRestoreRouter(mockRestoreUC, mockBusinessRepo)
 		req, _ := http.NewRequest("GET", "/businesses/"+businessID.Hex()+"/restore/incremental", nil)
 		req.Header.Set("X-Test-User-ID", userID.Hex())
 		w := httptest.NewRecorder()
@@ -251,9 +220,11 @@ func TestRestoreControllerIncrementalRestore(t *testing.T) {
 
 	t.Run("Bad Request - Invalid since format", func(t *testing.T) {
 		mockRestoreUC := new(MockRestoreUseCases)
-		mockBusinessUC := new(MockRestoreBusinessUseCases)
+		mockBusinessRepo := new(MockBusinessRepository)
+
+		mockBusinessRepo.On("FindByID", businessID.Hex()).Return(business, nil).Once()
 
-		router := setupRestoreRouter(mockRestoreUC, mockBusinessUC)
+		router := setupRestoreRouter(mockRestoreUC, mockBusinessRepo)
 		req, _ := http.NewRequest("GET", "/businesses/"+businessID.Hex()+"/restore/incremental?since=not-a-date", nil)
 		req.Header.Set("X-Test-User-ID", userID.Hex())
 		w := httptest.NewRecorder()
@@ -267,7 +238,7 @@ func TestRestoreControllerIncrementalRestore(t *testing.T) {
 
 	t.Run("Success - With include filter", func(t *testing.T) {
 		mockRestoreUC := new(MockRestoreUseCases)
-		mockBusinessUC := new(MockRestoreBusinessUseCases)
+		mockBusinessRepo := new(MockBusinessRepository)
 
 		restoreResp := &Domain.RestoreResponse{
 			Products:   []Domain.Product{{ID: primitive.NewObjectID(), BusinessID: businessID, Name: "Widget"}},
@@ -275,10 +246,10 @@ func TestRestoreControllerIncrementalRestore(t *testing.T) {
 			RestoredAt: time.Now(),
 		}
 
-		mockBusinessUC.On("GetById", businessID.Hex()).Return(business, nil).Once()
-		mockRestoreUC.On("IncrementalRestore", businessID.Hex(), sinceTime, []string{"products"}).Return(restoreResp, nil).Once()
+		mockBusinessRepo.On("FindByID", businessID.Hex()).Return(business, nil).Once()
+		mockRestoreUC.On("IncrementalRestore", businessID.Hex(), Domain.RoleOwner, sinceTime, []string{"products"}).Return(restoreResp, nil).Once()
 
-		router := setupRestoreRouter(mockRestoreUC, mockBusinessUC)
+		router := setupRestoreRouter(mockRestoreUC, mockBusinessRepo)
 		req, _ := http.NewRequest("GET", "/businesses/"+businessID.Hex()+"/restore/incremental?since="+sinceStr+"&include=products", nil)
 		req.Header.Set("X-Test-User-ID", userID.Hex())
 		w := httptest.NewRecorder()
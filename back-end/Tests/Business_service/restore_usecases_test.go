@@ -74,6 +74,14 @@ func (m *MockSaleRepository) GetSummary(businessID string, startDate, endDate ti
 	return args.Get(0).(*Domain.SaleSummaryResponse), args.Error(1)
 }
 
+func (m *MockSaleRepository) GetProductMargins(businessID string, startDate, endDate time.Time) ([]Domain.ProductMargin, error) {
+	args := m.Called(businessID, startDate, endDate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Domain.ProductMargin), args.Error(1)
+}
+
 // --- Mock ExpenseRepository ---
 
 type MockExpenseRepository struct {
@@ -179,11 +187,21 @@ func (m *MockProductRepository) Delete(id string) error {
 	return args.Error(0)
 }
 
-func (m *MockProductRepository) AdjustStock(productID string, quantity int, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
+func (m *MockProductRepository) AdjustStock(productID string, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
 	args := m.Called(productID, quantity, movementType, reason, referenceID, userID)
 	return args.Error(0)
 }
 
+func (m *MockProductRepository) ReceiveStock(productID string, quantity, unitCost decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) error {
+	args := m.Called(productID, quantity, unitCost, movementType, reason, referenceID, userID)
+	return args.Error(0)
+}
+
+func (m *MockProductRepository) TakeStock(productID string, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID *string, userID string) (decimal.Decimal, error) {
+	args := m.Called(productID, quantity, movementType, reason, referenceID, userID)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
 func (m *MockProductRepository) GetLowStock(businessID string) ([]Domain.Product, error) {
 	args := m.Called(businessID)
 	if args.Get(0) == nil {
@@ -192,6 +210,22 @@ func (m *MockProductRepository) GetLowStock(businessID string) ([]Domain.Product
 	return args.Get(0).([]Domain.Product), args.Error(1)
 }
 
+func (m *MockProductRepository) FindVariants(parentID string) ([]Domain.Product, error) {
+	args := m.Called(parentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Domain.Product), args.Error(1)
+}
+
+func (m *MockProductRepository) GetCategories(businessID string) ([]Domain.ProductCategory, error) {
+	args := m.Called(businessID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Domain.ProductCategory), args.Error(1)
+}
+
 func (m *MockProductRepository) GetStockHistory(productID string, limit int) ([]Domain.StockMovement, error) {
 	args := m.Called(productID, limit)
 	if args.Get(0) == nil {
@@ -206,7 +240,7 @@ func TestRestoreFullRestore(t *testing.T) {
 	businessID := primitive.NewObjectID()
 
 	sales := []Domain.Sale{
-		{ID: primitive.NewObjectID(), BusinessID: businessID, UnitPrice: 10, Quantity: 2, Total: 20},
+		{ID: primitive.NewObjectID(), BusinessID: businessID, Lines: []Domain.SaleLine{Domain.NewSaleLine(nil, decimal.NewFromInt(2), decimal.NewFromInt(10), decimal.Zero)}, Total: decimal.NewFromInt(20)},
 	}
 	expenses := []*Domain.Expense{
 		{ID: primitive.NewObjectID(), BusinessID: businessID, Amount: decimal.NewFromFloat(50)},
@@ -225,7 +259,7 @@ func TestRestoreFullRestore(t *testing.T) {
 		mockProducts.On("FindAllByBusinessID", businessID.Hex()).Return(products, nil).Once()
 
 		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
-		result, err := uc.FullRestore(businessID.Hex(), nil)
+		result, err := uc.FullRestore(businessID.Hex(), Domain.RoleOwner, nil)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -247,7 +281,7 @@ func TestRestoreFullRestore(t *testing.T) {
 		mockSales.On("FindAllByBusinessID", businessID.Hex()).Return(sales, nil).Once()
 
 		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
-		result, err := uc.FullRestore(businessID.Hex(), []string{"sales"})
+		result, err := uc.FullRestore(businessID.Hex(), Domain.RoleOwner, []string{"sales"})
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -268,7 +302,7 @@ func TestRestoreFullRestore(t *testing.T) {
 		mockProducts.On("FindAllByBusinessID", businessID.Hex()).Return(products, nil).Once()
 
 		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
-		result, err := uc.FullRestore(businessID.Hex(), []string{"sales", "products"})
+		result, err := uc.FullRestore(businessID.Hex(), Domain.RoleOwner, []string{"sales", "products"})
 
 		assert.NoError(t, err)
 		assert.Len(t, result.Sales, 1)
@@ -289,7 +323,7 @@ func TestRestoreFullRestore(t *testing.T) {
 		mockProducts.On("FindAllByBusinessID", businessID.Hex()).Return([]Domain.Product{}, nil).Once()
 
 		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
-		result, err := uc.FullRestore(businessID.Hex(), nil)
+		result, err := uc.FullRestore(businessID.Hex(), Domain.RoleOwner, nil)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -298,6 +332,28 @@ func TestRestoreFullRestore(t *testing.T) {
 		assert.Len(t, result.Products, 0)
 	})
 
+	t.Run("Cashier gets no expenses or costs", func(t *testing.T) {
+		mockSales := new(MockSaleRepository)
+		mockExpenses := new(MockExpenseRepository)
+		mockProducts := new(MockProductRepository)
+
+		costed := []Domain.Sale{{ID: primitive.NewObjectID(), BusinessID: businessID, Lines: []Domain.SaleLine{{Quantity: decimal.NewFromInt(2), UnitPrice: decimal.NewFromInt(10), Total: decimal.NewFromInt(20), Cost: decimal.NewFromInt(12)}}, Total: decimal.NewFromInt(20)}}
+		priced := []Domain.Product{{ID: primitive.NewObjectID(), BusinessID: businessID, Name: "Widget", DefaultSellingPrice: decimal.NewFromInt(10), UnitCost: decimal.NewFromInt(6)}}
+		mockSales.On("FindAllByBusinessID", businessID.Hex()).Return(costed, nil).Once()
+		mockProducts.On("FindAllByBusinessID", businessID.Hex()).Return(priced, nil).Once()
+
+		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
+		result, err := uc.FullRestore(businessID.Hex(), Domain.RoleCashier, nil)
+
+		assert.NoError(t, err)
+		assert.Nil(t, result.Expenses)
+		mockExpenses.AssertNotCalled(t, "GetAllByBusinessID")
+		assert.True(t, result.Sales[0].Lines[0].Cost.IsZero())
+		assert.Equal(t, "20", result.Sales[0].Total.String())
+		assert.True(t, result.Products[0].UnitCost.IsZero())
+		assert.Equal(t, "10", result.Products[0].DefaultSellingPrice.String())
+	})
+
 	t.Run("Sales repo error propagation", func(t *testing.T) {
 		mockSales := new(MockSaleRepository)
 		mockExpenses := new(MockExpenseRepository)
@@ -306,7 +362,7 @@ func TestRestoreFullRestore(t *testing.T) {
 		mockSales.On("FindAllByBusinessID", businessID.Hex()).Return(nil, assert.AnError).Once()
 
 		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
-		result, err := uc.FullRestore(businessID.Hex(), nil)
+		result, err := uc.FullRestore(businessID.Hex(), Domain.RoleOwner, nil)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
@@ -319,7 +375,7 @@ func TestRestoreIncrementalRestore(t *testing.T) {
 	since := time.Now().Add(-24 * time.Hour)
 
 	sales := []Domain.Sale{
-		{ID: primitive.NewObjectID(), BusinessID: businessID, UnitPrice: 25, Quantity: 1, Total: 25},
+		{ID: primitive.NewObjectID(), BusinessID: businessID, Lines: []Domain.SaleLine{Domain.NewSaleLine(nil, decimal.NewFromInt(1), decimal.NewFromInt(25), decimal.Zero)}, Total: decimal.NewFromInt(25)},
 	}
 	expenses := []*Domain.Expense{
 		{ID: primitive.NewObjectID(), BusinessID: businessID, Amount: decimal.NewFromFloat(100)},
@@ -338,7 +394,7 @@ func TestRestoreIncrementalRestore(t *testing.T) {
 		mockProducts.On("FindSince", businessID.Hex(), since).Return(products, nil).Once()
 
 		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
-		result, err := uc.IncrementalRestore(businessID.Hex(), since, nil)
+		result, err := uc.IncrementalRestore(businessID.Hex(), Domain.RoleOwner, since, nil)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, result)
@@ -360,7 +416,7 @@ func TestRestoreIncrementalRestore(t *testing.T) {
 		mockExpenses.On("GetSince", mock.Anything, businessID, since).Return(expenses, nil).Once()
 
 		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
-		result, err := uc.IncrementalRestore(businessID.Hex(), since, []string{"expenses"})
+		result, err := uc.IncrementalRestore(businessID.Hex(), Domain.RoleOwner, since, []string{"expenses"})
 
 		assert.NoError(t, err)
 		assert.Nil(t, result.Sales)
@@ -378,7 +434,7 @@ func TestRestoreIncrementalRestore(t *testing.T) {
 		mockProducts.On("FindSince", businessID.Hex(), since).Return(nil, assert.AnError).Once()
 
 		uc := usecases.NewRestoreUseCases(mockSales, mockExpenses, mockProducts)
-		result, err := uc.IncrementalRestore(businessID.Hex(), since, []string{"products"})
+		result, err := uc.IncrementalRestore(businessID.Hex(), Domain.RoleOwner, since, []string{"products"})
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	Domain "shop-ops/Domain"
+	usecases "shop-ops/Usecases"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSaleLines_TotalsWithDiscounts(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	lines := []Domain.SaleLine{
+		Domain.NewSaleLine(nil, decimal.NewFromInt(3), decimal.RequireFromString("0.10"), decimal.Zero),
+		Domain.NewSaleLine(nil, decimal.NewFromInt(2), decimal.RequireFromString("12.50"), decimal.RequireFromString("5")),
+	}
+
+	sale := Domain.NewSale(businessID, lines, "")
+
+	assert.Equal(t, "0.3", sale.Lines[0].Total.String())
+	assert.Equal(t, "20", sale.Lines[1].Total.String())
+	assert.Equal(t, "20.3", sale.Total.String())
+	assert.Equal(t, "5", sale.Quantity().String())
+	assert.NoError(t, sale.Validate())
+}
+
+func TestSaleLines_WeighedQuantities(t *testing.T) {
+	lines := []Domain.SaleLine{
+		Domain.NewSaleLine(nil, decimal.RequireFromString("0.25"), decimal.RequireFromString("12.00"), decimal.Zero),
+		Domain.NewSaleLine(nil, decimal.RequireFromString("1.5"), decimal.RequireFromString("2.40"), decimal.RequireFromString("0.10")),
+	}
+
+	sale := Domain.NewSale(primitive.NewObjectID(), lines, "")
+
+	assert.Equal(t, "3", sale.Lines[0].Total.String())
+	assert.Equal(t, "3.5", sale.Lines[1].Total.String())
+	assert.Equal(t, "1.75", sale.Quantity().String())
+	assert.NoError(t, sale.Validate())
+}
+
+func TestSaleValidate_RejectsBadLines(t *testing.T) {
+	businessID := primitive.NewObjectID()
+
+	t.Run("No lines", func(t *testing.T) {
+		sale := Domain.NewSale(businessID, nil, "")
+		assert.Error(t, sale.Validate())
+	})
+
+	t.Run("Discount above the line amount", func(t *testing.T) {
+		sale := Domain.NewSale(businessID, []Domain.SaleLine{
+			Domain.NewSaleLine(nil, decimal.NewFromInt(1), decimal.NewFromInt(5), decimal.Zero),
+			Domain.NewSaleLine(nil, decimal.NewFromInt(1), decimal.NewFromInt(5), decimal.NewFromInt(6)),
+		}, "")
+		err := sale.Validate()
+		assert.EqualError(t, err, "line 2: discount cannot exceed the line amount")
+	})
+
+	t.Run("Total that does not match the lines", func(t *testing.T) {
+		sale := Domain.NewSale(businessID, []Domain.SaleLine{Domain.NewSaleLine(nil, decimal.NewFromInt(1), decimal.NewFromInt(5), decimal.Zero)}, "")
+		sale.Total = decimal.NewFromInt(6)
+		assert.EqualError(t, sale.Validate(), "total amount mismatch")
+	})
+}
+
+func TestSaleNormalizeLines_ReadsLegacySaleAsOneLine(t *testing.T) {
+	productID := primitive.NewObjectID()
+	sale := Domain.Sale{
+		BusinessID:      primitive.NewObjectID(),
+		Total:           decimal.NewFromInt(20),
+		LegacyProductID: &productID,
+		LegacyUnitPrice: decimal.NewFromInt(10),
+		LegacyQuantity:  decimal.NewFromInt(2),
+	}
+
+	sale.NormalizeLines()
+
+	assert.Len(t, sale.Lines, 1)
+	assert.Equal(t, &productID, sale.Lines[0].ProductID)
+	assert.Equal(t, "2", sale.Lines[0].Quantity.String())
+	assert.Equal(t, "20", sale.Lines[0].Total.String())
+	assert.Nil(t, sale.LegacyProductID)
+	assert.NoError(t, sale.Validate())
+}
+
+func TestCreateSale_Basket(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	business := &Domain.Business{ID: businessID, UserID: primitive.NewObjectID(), Name: "Test Shop"}
+	bread := &Domain.Product{ID: primitive.NewObjectID(), BusinessID: businessID, Name: "Bread", StockQuantity: decimal.NewFromInt(10)}
+	milk := &Domain.Product{ID: primitive.NewObjectID(), BusinessID: businessID, Name: "Milk", StockQuantity: decimal.NewFromInt(1)}
+	breadHex, milkHex := bread.ID.Hex(), milk.ID.Hex()
+	actor := Domain.AuditActor{UserID: primitive.NewObjectID().Hex()}
+	req := Domain.CreateSaleRequest{
+		BusinessID: businessID.Hex(),
+		Lines: []Domain.SaleLineRequest{
+			{ProductID: &breadHex, UnitPrice: 1.2, Quantity: 3},
+			{ProductID: &milkHex, UnitPrice: 0.9, Quantity: 2, Discount: 0.3},
+			{UnitPrice: 2, Quantity: 1},
+		},
+	}
+
+	setup := func() (*MockSaleRepository, *MockProductRepository, usecases.SalesUseCase) {
+		salesRepo := new(MockSaleRepository)
+		productRepo := new(MockProductRepository)
+		businessRepo := new(MockBusinessRepository)
+		businessRepo.On("FindByID", businessID.Hex()).Return(business, nil)
+		productRepo.On("FindByID", breadHex).Return(bread, nil)
+		productRepo.On("FindByID", milkHex).Return(milk, nil)
+		return salesRepo, productRepo, usecases.NewSalesUseCase(salesRepo, productRepo, businessRepo, nil)
+	}
+
+	t.Run("Takes stock per line and totals with decimals", func(t *testing.T) {
+		salesRepo, productRepo, uc := setup()
+		productRepo.On("TakeStock", breadHex, decimalOf("3"), Domain.MovementTypeSale, mock.Anything, mock.Anything, actor.UserID).Return(decimal.RequireFromString("2.4"), nil).Once()
+		productRepo.On("TakeStock", milkHex, decimalOf("2"), Domain.MovementTypeSale, mock.Anything, mock.Anything, actor.UserID).Return(decimal.RequireFromString("1.1"), nil).Once()
+		salesRepo.On("Create", mock.AnythingOfType("*domain.Sale")).Return(nil).Once()
+
+		resp, err := uc.CreateSale(businessID.Hex(), actor, req)
+
+		assert.NoError(t, err)
+		assert.Len(t, resp.Lines, 3)
+		assert.Equal(t, "1.5", resp.Lines[1].Total.String())
+		assert.Equal(t, "7.1", resp.Total.String())
+		assert.Equal(t, "6", resp.Quantity.String())
+		assert.Equal(t, "1.1", resp.Lines[1].Cost.String())
+		assert.Equal(t, "3.5", resp.CostOfGoods.String())
+		productRepo.AssertExpectations(t)
+	})
+
+	t.Run("A short line returns the lines already taken", func(t *testing.T) {
+		salesRepo, productRepo, uc := setup()
+		productRepo.On("TakeStock", breadHex, decimalOf("3"), Domain.MovementTypeSale, mock.Anything, mock.Anything, actor.UserID).Return(decimal.RequireFromString("2.4"), nil).Once()
+		productRepo.On("TakeStock", milkHex, decimalOf("2"), Domain.MovementTypeSale, mock.Anything, mock.Anything, actor.UserID).
+			Return(decimal.Zero, fmt.Errorf("%w. Available: 1, Required: 2", Domain.ErrInsufficientStock)).Once()
+		productRepo.On("ReceiveStock", breadHex, decimalOf("3"), decimalOf("0.8"), Domain.MovementTypeReturn, mock.Anything, mock.Anything, actor.UserID).Return(nil).Once()
+
+		_, err := uc.CreateSale(businessID.Hex(), actor, req)
+
+		assert.ErrorIs(t, err, Domain.ErrInsufficientStock)
+		assert.Contains(t, err.Error(), "line 2")
+		salesRepo.AssertNotCalled(t, "Create", mock.Anything)
+		productRepo.AssertExpectations(t)
+	})
+}
+
+func TestVoidSale_ReturnsStockPerLine(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	sale := Domain.NewSale(businessID, []Domain.SaleLine{
+		Domain.NewSaleLine(&first, decimal.NewFromInt(2), decimal.NewFromInt(3), decimal.Zero),
+		Domain.NewSaleLine(nil, decimal.NewFromInt(1), decimal.NewFromInt(1), decimal.Zero),
+		Domain.NewSaleLine(&second, decimal.NewFromInt(4), decimal.NewFromInt(1), decimal.Zero),
+	}, "")
+	sale.Lines[0].Cost = decimal.RequireFromString("3.5")
+	actor := Domain.AuditActor{UserID: primitive.NewObjectID().Hex()}
+
+	salesRepo := new(MockSaleRepository)
+	productRepo := new(MockProductRepository)
+	salesRepo.On("FindByID", sale.ID.Hex()).Return(sale, nil)
+	salesRepo.On("VoidSale", sale.ID.Hex()).Return(nil).Once()
+	// A line with a cost of goods comes back at that cost; one without, as before
+	productRepo.On("ReceiveStock", first.Hex(), decimalOf("2"), decimalOf("1.75"), Domain.MovementTypeReturn, mock.Anything, mock.Anything, actor.UserID).Return(nil).Once()
+	productRepo.On("AdjustStock", second.Hex(), decimalOf("4"), Domain.MovementTypeReturn, mock.Anything, mock.Anything, actor.UserID).Return(nil).Once()
+	uc := usecases.NewSalesUseCase(salesRepo, productRepo, new(MockBusinessRepository), nil)
+
+	err := uc.VoidSale(sale.ID.Hex(), businessID.Hex(), actor)
+
+	assert.NoError(t, err)
+	productRepo.AssertExpectations(t)
+}
+
+// decimalOf matches a decimal argument by value, whatever its exponent
+func decimalOf(value string) interface{} {
+	want := decimal.RequireFromString(value)
+	return mock.MatchedBy(func(d decimal.Decimal) bool { return d.Equal(want) })
+}
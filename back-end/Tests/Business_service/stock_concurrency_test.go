@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	Domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
+	repositories "shop-ops/Repositories"
+	usecases "shop-ops/Usecases"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// --- Concurrency harness ---
+//
+// These tests hammer one product from many goroutines against a real MongoDB
+// and check the stock against its movement history. Set MONGO_TEST_URI (e.g.
+// mongodb://localhost:27017) to run them; each run uses a throwaway database.
+// Locally they are skipped without it; in CI (where CI is set) they fail.
+
+func openStockTestDB(t *testing.T) *mongo.Database {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		skipWithoutMongo(t, "MONGO_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, infrastructure.NewMongoClientOptions(uri))
+	if err != nil || client.Ping(ctx, nil) != nil {
+		skipWithoutMongo(t, fmt.Sprintf("MongoDB at %s is not reachable", uri))
+	}
+
+	db := client.Database(fmt.Sprintf("shopops_stock_test_%s", primitive.NewObjectID().Hex()))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	return db
+}
+
+// skipWithoutMongo skips a test that needs MongoDB, except in CI, where the
+// harness must run.
+func skipWithoutMongo(t *testing.T, reason string) {
+	if os.Getenv("CI") != "" {
+		t.Fatal(reason + " (the MongoDB harness is required in CI)")
+	}
+	t.Skip(reason)
+}
+
+// runConcurrently starts n workers at once and collects their errors.
+func runConcurrently(n int, work func(i int) error) []error {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = work(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+// assertStockMatchesLedger checks that the product's stock equals the sum of
+// its recorded movements, i.e. no update was lost or recorded twice.
+func assertStockMatchesLedger(t *testing.T, repo Domain.ProductRepository, productID string) decimal.Decimal {
+	product, err := repo.FindByID(productID)
+	assert.NoError(t, err)
+	movements, err := repo.GetStockHistory(productID, 100000)
+	assert.NoError(t, err)
+
+	sum := decimal.Zero
+	for _, m := range movements {
+		sum = sum.Add(m.Quantity)
+	}
+	assert.Equal(t, product.StockQuantity.String(), sum.String(), "stock must equal the sum of its movements")
+	return product.StockQuantity
+}
+
+func newStockTestProduct(t *testing.T, repo Domain.ProductRepository, stock int64) string {
+	product := &Domain.Product{
+		BusinessID:    primitive.NewObjectID(),
+		Name:          "Widget",
+		StockQuantity: decimal.NewFromInt(stock),
+	}
+	assert.NoError(t, repo.Create(product))
+	return product.ID.Hex()
+}
+
+func TestCreateProduct_NotKeptWithoutItsOpeningStock(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	// A validator no movement passes makes the opening stock fail
+	validator := bson.M{"quantity": bson.M{"$lt": 0}}
+	assert.NoError(t, db.CreateCollection(ctx, "stock_movements", options.CreateCollection().SetValidator(validator)))
+	repo := repositories.NewInventoryRepository(db)
+
+	product := &Domain.Product{BusinessID: primitive.NewObjectID(), Name: "Widget", StockQuantity: decimal.NewFromInt(5)}
+	assert.Error(t, repo.Create(product))
+
+	count, err := db.Collection("products").CountDocuments(ctx, bson.M{"business_id": product.BusinessID})
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestAdjustStock_ConcurrentSalesNeverOversell(t *testing.T) {
+	repo := repositories.NewInventoryRepository(openStockTestDB(t))
+	productID := newStockTestProduct(t, repo, 100)
+	userID := primitive.NewObjectID().Hex()
+
+	// 60 sales of 2 compete for 100 units: exactly 50 may succeed
+	errs := runConcurrently(60, func(i int) error {
+		return repo.AdjustStock(productID, decimal.NewFromInt(2), Domain.MovementTypeSale, "Sale transaction", nil, userID)
+	})
+
+	succeeded, refused := 0, 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, Domain.ErrInsufficientStock):
+			refused++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	assert.Equal(t, 50, succeeded)
+	assert.Equal(t, 10, refused)
+	assert.Equal(t, "0", assertStockMatchesLedger(t, repo, productID).String())
+}
+
+func TestAdjustStock_NoLostUpdates(t *testing.T) {
+	repo := repositories.NewInventoryRepository(openStockTestDB(t))
+	productID := newStockTestProduct(t, repo, 1000)
+	userID := primitive.NewObjectID().Hex()
+
+	// Interleave 100 purchases of 3 with 100 sales of 5
+	errs := runConcurrently(200, func(i int) error {
+		if i%2 == 0 {
+			return repo.AdjustStock(productID, decimal.NewFromInt(3), Domain.MovementTypePurchase, "Restock", nil, userID)
+		}
+		return repo.AdjustStock(productID, decimal.NewFromInt(5), Domain.MovementTypeSale, "Sale transaction", nil, userID)
+	})
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, decimal.NewFromInt(1000+100*3-100*5).String(), assertStockMatchesLedger(t, repo, productID).String())
+}
+
+func TestAdjustStock_RecoversMovementsLeftBehind(t *testing.T) {
+	db := openStockTestDB(t)
+	repo := repositories.NewInventoryRepository(db)
+	productID := newStockTestProduct(t, repo, 10)
+	objProductID, _ := primitive.ObjectIDFromHex(productID)
+	ctx := context.Background()
+	longAgo := time.Now().Add(-time.Hour)
+
+	// A sale of 3 moved the stock, then the process died before recording it;
+	// a damage of 2 was journaled but never moved the stock
+	sold, lost := primitive.NewObjectID(), primitive.NewObjectID()
+	_, err := db.Collection("products").UpdateByID(ctx, objProductID, bson.M{
+		"$inc":  bson.M{"stock_quantity": -3},
+		"$push": bson.M{"pending_movements": bson.M{"movement_id": sold, "stock_before": 10}},
+	})
+	assert.NoError(t, err)
+	_, err = db.Collection("stock_movement_journal").InsertMany(ctx, []interface{}{
+		bson.M{"_id": sold, "product_id": objProductID, "type": Domain.MovementTypeSale, "quantity": 3, "reason": "Sale transaction", "created_by": primitive.NewObjectID(), "created_at": longAgo},
+		bson.M{"_id": lost, "product_id": objProductID, "type": Domain.MovementTypeDamage, "quantity": 2, "reason": "Broken", "created_by": primitive.NewObjectID(), "created_at": longAgo},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "7", assertStockMatchesLedger(t, repo, productID).String())
+	var recovered Domain.StockMovement
+	assert.NoError(t, db.Collection("stock_movements").FindOne(ctx, bson.M{"_id": sold}).Decode(&recovered))
+	assert.Equal(t, -3, recovered.Quantity)
+	assert.Equal(t, Domain.MovementTypeSale, recovered.Type)
+
+	// Later adjustments leave nothing behind
+	assert.NoError(t, repo.AdjustStock(productID, decimal.NewFromInt(1), Domain.MovementTypeSale, "Sale transaction", nil, primitive.NewObjectID().Hex()))
+	left, err := db.Collection("stock_movement_journal").CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.Zero(t, left)
+	var product bson.M
+	assert.NoError(t, db.Collection("products").FindOne(ctx, bson.M{"_id": objProductID}).Decode(&product))
+	assert.Empty(t, product["pending_movements"])
+	assert.Equal(t, "6", assertStockMatchesLedger(t, repo, productID).String())
+}
+
+// --- CreateSale ordering ---
+
+func TestCreateSale_TakesStockBeforeSaving(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	productID := primitive.NewObjectID()
+	productHex := productID.Hex()
+	business := &Domain.Business{ID: businessID, UserID: primitive.NewObjectID(), Name: "Test Shop"}
+	product := &Domain.Product{ID: productID, BusinessID: businessID, Name: "Widget", StockQuantity: decimal.NewFromInt(1)}
+	actor := Domain.AuditActor{UserID: primitive.NewObjectID().Hex()}
+	req := Domain.CreateSaleRequest{BusinessID: businessID.Hex(), ProductID: &productHex, UnitPrice: 10, Quantity: 2}
+
+	setup := func() (*MockSaleRepository, *MockProductRepository, usecases.SalesUseCase) {
+		salesRepo := new(MockSaleRepository)
+		productRepo := new(MockProductRepository)
+		businessRepo := new(MockBusinessRepository)
+		businessRepo.On("FindByID", businessID.Hex()).Return(business, nil)
+		productRepo.On("FindByID", productHex).Return(product, nil)
+		return salesRepo, productRepo, usecases.NewSalesUseCase(salesRepo, productRepo, businessRepo, nil)
+	}
+
+	t.Run("Insufficient stock saves no sale", func(t *testing.T) {
+		salesRepo, productRepo, uc := setup()
+		productRepo.On("TakeStock", productHex, decimalOf("2"), Domain.MovementTypeSale, mock.Anything, mock.Anything, actor.UserID).
+			Return(decimal.Zero, fmt.Errorf("%w. Available: 1, Required: 2", Domain.ErrInsufficientStock)).Once()
+
+		_, err := uc.CreateSale(businessID.Hex(), actor, req)
+
+		assert.ErrorIs(t, err, Domain.ErrInsufficientStock)
+		salesRepo.AssertNotCalled(t, "Create", mock.Anything)
+	})
+
+	t.Run("Stock is returned when the sale cannot be saved", func(t *testing.T) {
+		salesRepo, productRepo, uc := setup()
+		productRepo.On("TakeStock", productHex, decimalOf("2"), Domain.MovementTypeSale, mock.Anything, mock.Anything, actor.UserID).Return(decimal.Zero, nil).Once()
+		salesRepo.On("Create", mock.AnythingOfType("*domain.Sale")).Return(errors.New("write failed")).Once()
+		productRepo.On("AdjustStock", productHex, decimalOf("2"), Domain.MovementTypeReturn, mock.Anything, mock.Anything, actor.UserID).Return(nil).Once()
+
+		_, err := uc.CreateSale(businessID.Hex(), actor, req)
+
+		assert.Error(t, err)
+		productRepo.AssertExpectations(t)
+	})
+}
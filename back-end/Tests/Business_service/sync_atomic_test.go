@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestAtomicBatch_RolledBackConflictsAreNotReported(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := primitive.NewObjectID()
+	expenseID := primitive.NewObjectID()
+	now := time.Now().UTC()
+
+	_, err := db.Collection("businesses").InsertOne(ctx, bson.M{
+		"_id":                  businessID,
+		"sync_conflict_policy": domain.SyncPolicyManual,
+		"sync_devices":         bson.A{bson.M{"device_id": "device_1", "status": domain.DeviceStatusActive, "registered_at": now}},
+	})
+	assert.NoError(t, err)
+	_, err = db.Collection("expenses").InsertOne(ctx, bson.M{
+		"_id": expenseID, "business_id": businessID, "category": "transport", "amount": "10",
+		"version": 3, "is_voided": false, "created_at": now,
+	})
+	assert.NoError(t, err)
+
+	repo := repositories.NewSyncRepository(db)
+	response, err := repo.ProcessBatch(ctx, domain.SyncBatchRequest{
+		BusinessID:    businessID.Hex(),
+		DeviceID:      "device_1",
+		SchemaVersion: domain.SyncSchemaV2,
+		Atomic:        true,
+		Transactions: []domain.SyncBatchTransaction{{
+			LocalID: "u1",
+			Type:    domain.SyncTransactionTypeExpenseUpdate,
+			Data:    map[string]interface{}{"expense_id": expenseID.Hex(), "amount": "90", "base_version": 1},
+		}},
+	})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, response) && assert.Len(t, response.Results, 1) && assert.NotNil(t, response.Results[0].Conflict) {
+		assert.Empty(t, response.Results[0].Conflict.ConflictID)
+	}
+	stored, err := db.Collection("sync_conflicts").CountDocuments(ctx, bson.M{"business_id": businessID})
+	assert.NoError(t, err)
+	assert.Zero(t, stored)
+}
+
+// atomicTestBusiness stores a business with one registered device, a product
+// and an expense for the atomic batch tests.
+func atomicTestBusiness(t *testing.T, db *mongo.Database) (businessID, productID, expenseID primitive.ObjectID) {
+	ctx := context.Background()
+	businessID, productID, expenseID = primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	now := time.Now().UTC()
+
+	_, err := db.Collection("businesses").InsertOne(ctx, bson.M{
+		"_id":          businessID,
+		"sync_devices": bson.A{bson.M{"device_id": "device_1", "status": domain.DeviceStatusActive, "registered_at": now}},
+	})
+	assert.NoError(t, err)
+	_, err = db.Collection("products").InsertOne(ctx, bson.M{
+		"_id": productID, "business_id": businessID, "name": "Widget", "stock_quantity": 10, "version": 1, "created_at": now,
+	})
+	assert.NoError(t, err)
+	_, err = db.Collection("expenses").InsertOne(ctx, bson.M{
+		"_id": expenseID, "business_id": businessID, "category": "transport", "amount": "10",
+		"version": 1, "is_voided": false, "created_at": now,
+	})
+	assert.NoError(t, err)
+	return businessID, productID, expenseID
+}
+
+// stockOf reads a product's stock. Stock moved by the ledger is stored as a
+// Decimal128, so it is read through the product rather than a bson.M.
+func stockOf(t *testing.T, db *mongo.Database, productID primitive.ObjectID) string {
+	var product domain.Product
+	assert.NoError(t, db.Collection("products").FindOne(context.Background(), bson.M{"_id": productID}).Decode(&product))
+	return product.StockQuantity.String()
+}
+
+// assertBatchUndone checks that nothing a batch wrote is left: the stock is
+// back, its movements and inserted records are gone and the expense is as
+// it was before the batch.
+func assertBatchUndone(t *testing.T, db *mongo.Database, businessID, productID primitive.ObjectID, expenseBefore bson.M) {
+	ctx := context.Background()
+
+	assert.Equal(t, "10", stockOf(t, db, productID))
+
+	movements, err := db.Collection("stock_movements").CountDocuments(ctx, bson.M{"product_id": productID})
+	assert.NoError(t, err)
+	assert.Zero(t, movements)
+	sales, err := db.Collection("sales").CountDocuments(ctx, bson.M{"business_id": businessID})
+	assert.NoError(t, err)
+	assert.Zero(t, sales)
+	expenses, err := db.Collection("expenses").CountDocuments(ctx, bson.M{"business_id": businessID})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, expenses)
+
+	var expense bson.M
+	assert.NoError(t, db.Collection("expenses").FindOne(ctx, bson.M{"_id": expenseBefore["_id"]}).Decode(&expense))
+	assert.Equal(t, expenseBefore, expense)
+}
+
+// On a standalone server the batch is journaled and compensated; on a
+// replica set it runs in a transaction. Either way it is all or nothing.
+func TestAtomicBatch_FailingItemUndoesTheWholeBatch(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID, productID, expenseID := atomicTestBusiness(t, db)
+	var expenseBefore bson.M
+	assert.NoError(t, db.Collection("expenses").FindOne(ctx, bson.M{"_id": expenseID}).Decode(&expenseBefore))
+
+	repo := repositories.NewSyncRepository(db)
+	response, err := repo.ProcessBatch(ctx, domain.SyncBatchRequest{
+		BusinessID:    businessID.Hex(),
+		DeviceID:      "device_1",
+		SchemaVersion: domain.SyncSchemaV2,
+		Atomic:        true,
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{
+				"product_id": productID.Hex(), "quantity": 3, "amount": "30", "created_at": "2026-03-01T10:00:00Z",
+			}},
+			{LocalID: "u1", Type: domain.SyncTransactionTypeExpenseUpdate, Data: map[string]interface{}{
+				"expense_id": expenseID.Hex(), "amount": "90", "base_version": 1,
+			}},
+			{LocalID: "e1", Type: domain.SyncTransactionTypeExpense, Data: map[string]interface{}{
+				"category": "transport", "amount": "5", "created_at": "2026-03-01T10:05:00Z",
+			}},
+			// Only 7 are left after the first sale
+			{LocalID: "s2", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{
+				"product_id": productID.Hex(), "quantity": 8, "amount": "80", "created_at": "2026-03-01T10:10:00Z",
+			}},
+		},
+	})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, response) && assert.Len(t, response.Results, 4) {
+		assert.Equal(t, "rolled_back", response.Status)
+		for _, result := range response.Results[:3] {
+			assert.Equal(t, "rolled_back", result.Status, result.LocalID)
+			assert.Empty(t, result.ServerID, result.LocalID)
+		}
+		assert.Equal(t, "failed", response.Results[3].Status)
+		assert.Equal(t, 3, response.Summary.RolledBack)
+		assert.Equal(t, 1, response.Summary.Failed)
+	}
+	assertBatchUndone(t, db, businessID, productID, expenseBefore)
+
+	receipts, err := db.Collection("sync_operations").CountDocuments(ctx, bson.M{"business_id": businessID})
+	assert.NoError(t, err)
+	assert.Zero(t, receipts)
+	var journal bson.M
+	if err := db.Collection("sync_journal").FindOne(ctx, bson.M{"_id": mustObjectID(t, response.SyncID)}).Decode(&journal); err == nil {
+		assert.Equal(t, "rolled_back", journal["state"])
+	}
+}
+
+// A batch cut short by a crash leaves its journal pending. Recovery undoes
+// it once, even when two instances recover at the same time.
+func TestRecoverJournals_RollsBackPendingBatchOnce(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID, productID, expenseID := atomicTestBusiness(t, db)
+	longAgo := time.Now().UTC().Add(-time.Hour)
+	syncID := primitive.NewObjectID()
+
+	var expenseBefore bson.M
+	assert.NoError(t, db.Collection("expenses").FindOne(ctx, bson.M{"_id": expenseID}).Decode(&expenseBefore))
+	image, err := bson.Marshal(expenseBefore)
+	assert.NoError(t, err)
+
+	// The batch sold 3, updated the expense and added a sale, then died
+	_, err = db.Collection("sync_journal").InsertOne(ctx, bson.M{
+		"_id": syncID, "business_id": businessID, "device_id": "device_1", "state": "pending",
+		"before_images": bson.A{bson.M{"entity": domain.ChangeEntityExpense, "id": expenseID, "doc": bson.Raw(image)}},
+		"started_at":    longAgo, "touched_at": longAgo,
+	})
+	assert.NoError(t, err)
+	_, err = db.Collection("products").UpdateByID(ctx, productID, bson.M{"$inc": bson.M{"stock_quantity": -3}})
+	assert.NoError(t, err)
+	_, err = db.Collection("stock_movements").InsertOne(ctx, bson.M{
+		"_id": primitive.NewObjectID(), "business_id": businessID, "product_id": productID, "type": domain.MovementTypeSale,
+		"quantity": -3, "reason": "Sale transaction", "sync_id": syncID.Hex(), "created_at": longAgo,
+	})
+	assert.NoError(t, err)
+	_, err = db.Collection("expenses").UpdateByID(ctx, expenseID, bson.M{"$set": bson.M{"amount": "90"}, "$inc": bson.M{"version": 1}})
+	assert.NoError(t, err)
+	_, err = db.Collection("sales").InsertOne(ctx, bson.M{"business_id": businessID, "total": "30", "sync_id": syncID.Hex(), "created_at": longAgo})
+	assert.NoError(t, err)
+
+	repos := []repositories.SyncRepository{repositories.NewSyncRepository(db), repositories.NewSyncRepository(db)}
+	for _, err := range runConcurrently(len(repos), func(i int) error { return repos[i].RecoverJournals(ctx) }) {
+		assert.NoError(t, err)
+	}
+
+	assertBatchUndone(t, db, businessID, productID, expenseBefore)
+	var journal bson.M
+	assert.NoError(t, db.Collection("sync_journal").FindOne(ctx, bson.M{"_id": syncID}).Decode(&journal))
+	assert.Equal(t, "rolled_back", journal["state"])
+}
+
+func TestRecoverJournals_LeavesBatchInProgressAlone(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	syncID := primitive.NewObjectID()
+
+	// Started long ago, but still being applied
+	_, err := db.Collection("sync_journal").InsertOne(ctx, bson.M{
+		"_id": syncID, "business_id": primitive.NewObjectID(), "device_id": "device_1", "state": "pending",
+		"before_images": bson.A{}, "started_at": time.Now().UTC().Add(-time.Hour), "touched_at": time.Now().UTC(),
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, repositories.NewSyncRepository(db).RecoverJournals(ctx))
+
+	var journal bson.M
+	assert.NoError(t, db.Collection("sync_journal").FindOne(ctx, bson.M{"_id": syncID}).Decode(&journal))
+	assert.Equal(t, "pending", journal["state"])
+}
+
+func mustObjectID(t *testing.T, hex string) primitive.ObjectID {
+	id, err := primitive.ObjectIDFromHex(hex)
+	assert.NoError(t, err)
+	return id
+}
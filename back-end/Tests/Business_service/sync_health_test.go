@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetHealth_DaysAddUpToTotalsWithRetries(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := primitive.NewObjectID()
+	day := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	_, err := db.Collection("sync_logs").InsertMany(ctx, []interface{}{
+		bson.M{
+			"_id": primitive.NewObjectID(), "business_id": businessID, "device_id": "device_1",
+			"status": "partial_success", "summary": domain.SyncSummary{Total: 3, Success: 2, Failed: 1}, "created_at": day,
+		},
+		bson.M{
+			"_id": primitive.NewObjectID(), "business_id": businessID, "device_id": "device_1", "source": domain.SyncLogSourceRetry,
+			"status": "completed", "summary": domain.SyncSummary{Total: 1, Success: 1}, "created_at": day.Add(time.Hour),
+		},
+	})
+	assert.NoError(t, err)
+
+	repo := repositories.NewSyncRepository(db)
+	report, err := repo.GetHealth(ctx, domain.SyncHealthQuery{
+		BusinessID:  businessID.Hex(),
+		From:        day.Add(-time.Hour),
+		To:          day.Add(24 * time.Hour),
+		SilentDays:  7,
+		TopFailures: 10,
+	})
+
+	assert.NoError(t, err)
+	if !assert.NotNil(t, report) || !assert.Len(t, report.Daily, 1) {
+		return
+	}
+	assert.EqualValues(t, 1, report.Batches)
+	assert.EqualValues(t, 1, report.RetryBatches)
+	assert.Equal(t, report.Batches, report.Daily[0].Batches)
+	assert.Equal(t, report.RetryBatches, report.Daily[0].RetryBatches)
+	assert.Equal(t, report.Items.Total, report.Daily[0].Items)
+	assert.Equal(t, report.Items.Success, report.Daily[0].Success)
+}
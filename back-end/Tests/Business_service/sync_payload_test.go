@@ -0,0 +1,269 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	domain "shop-ops/Domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fieldErrors(t *testing.T, err error) []domain.SyncFieldError {
+	t.Helper()
+	var validationErr *domain.SyncValidationError
+	assert.True(t, errors.As(err, &validationErr), "expected a validation error, got %v", err)
+	return validationErr.Fields
+}
+
+func TestDecodeSyncPayload_V1UpgradesLegacyFields(t *testing.T) {
+	data := map[string]interface{}{
+		"category":    "rent",
+		"amount":      1500.5,
+		"description": "March rent",
+		"created_at":  "2026-03-01T10:00:00Z",
+		"legacy_flag": true,
+	}
+
+	var payload domain.ExpenseSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV1, data, &payload)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "RENT", payload.Category)
+	assert.Equal(t, "1500.5", payload.Value.String())
+	assert.Equal(t, "March rent", payload.Note)
+	assert.Equal(t, 2026, payload.Created.Year())
+}
+
+func TestDecodeSyncPayload_MissingVersionReadsAsV1(t *testing.T) {
+	data := map[string]interface{}{"quantity": 2.0, "amount": 300.0, "created_at": "2026-03-01T10:00:00Z"}
+
+	var payload domain.SaleSyncPayload
+	err := domain.DecodeSyncPayload(0, data, &payload)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2", payload.Quantity.String())
+	assert.Equal(t, "300", payload.Total.String())
+}
+
+func TestDecodeSyncPayload_V1UpgradesNumericUnitCost(t *testing.T) {
+	t.Run("Product", func(t *testing.T) {
+		data := map[string]interface{}{
+			"name": "Cola", "default_selling_price": 2.5, "unit_cost": 1.25, "stock_quantity": 12.0, "created_at": "2026-03-01T10:00:00Z",
+		}
+
+		var payload domain.ProductSyncPayload
+		err := domain.DecodeSyncPayload(domain.SyncSchemaV1, data, &payload)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1.25", payload.Cost.String())
+	})
+
+	t.Run("Stock adjustment", func(t *testing.T) {
+		data := map[string]interface{}{
+			"product_id": "p1", "movement_type": "PURCHASE", "quantity": 6.0, "unit_cost": 3.0, "created_at": "2026-03-01T10:00:00Z",
+		}
+
+		var payload domain.StockAdjustmentSyncPayload
+		err := domain.DecodeSyncPayload(domain.SyncSchemaV1, data, &payload)
+
+		assert.NoError(t, err)
+		if assert.NotNil(t, payload.Cost) {
+			assert.Equal(t, "3", payload.Cost.String())
+		}
+	})
+}
+
+func TestDecodeSyncPayload_V2RejectsUnknownFields(t *testing.T) {
+	data := map[string]interface{}{
+		"quantity":    1,
+		"amount":      "10.00",
+		"created_at":  "2026-03-01T10:00:00Z",
+		"description": "old field name",
+	}
+
+	var payload domain.SaleSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload)
+
+	fields := fieldErrors(t, err)
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "data.description", fields[0].Path)
+	assert.Equal(t, domain.SyncFieldUnknown, fields[0].Code)
+}
+
+func TestDecodeSyncPayload_V2RequiresDecimalStrings(t *testing.T) {
+	data := map[string]interface{}{"quantity": 1, "amount": 10.5, "created_at": "2026-03-01T10:00:00Z"}
+
+	var payload domain.SaleSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload)
+
+	fields := fieldErrors(t, err)
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "data.amount", fields[0].Path)
+	assert.Equal(t, domain.SyncFieldInvalid, fields[0].Code)
+}
+
+func TestDecodeSyncPayload_ReportsEveryInvalidField(t *testing.T) {
+	data := map[string]interface{}{
+		"quantity":   0,
+		"amount":     "-5",
+		"created_at": "yesterday",
+	}
+
+	var payload domain.SaleSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload)
+
+	fields := fieldErrors(t, err)
+	paths := make([]string, 0, len(fields))
+	for _, field := range fields {
+		paths = append(paths, field.Path)
+	}
+	assert.ElementsMatch(t, []string{"data.quantity", "data.amount", "data.created_at"}, paths)
+}
+
+func TestDecodeSyncPayload_MutationNeedsReference(t *testing.T) {
+	var payload domain.SaleVoidSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV2, map[string]interface{}{"base_version": 3}, &payload)
+
+	fields := fieldErrors(t, err)
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "data.sale_id", fields[0].Path)
+	assert.Equal(t, domain.SyncFieldRequired, fields[0].Code)
+}
+
+func TestDecodeSyncPayload_ExpenseUpdateNeedsAChange(t *testing.T) {
+	var payload domain.ExpenseUpdateSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV2, map[string]interface{}{"expense_local_id": "e1"}, &payload)
+
+	fields := fieldErrors(t, err)
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "data", fields[0].Path)
+	assert.Equal(t, domain.SyncFieldRequired, fields[0].Code)
+}
+
+func TestDecodeSyncPayload_StockAdjustmentUsesCreatedAtAsChangeTime(t *testing.T) {
+	data := map[string]interface{}{
+		"product_id":    "64b7f0c2e4b0a1a2b3c4d5e6",
+		"movement_type": "damage",
+		"quantity":      2,
+		"base_version":  4,
+		"created_at":    "2026-03-01T10:00:00Z",
+	}
+
+	var payload domain.StockAdjustmentSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), *payload.BaseVersion)
+	assert.Equal(t, payload.Created, payload.ChangedAt())
+}
+
+func TestDecodeSyncPayload_UnitCostOnlyForStockComingIn(t *testing.T) {
+	data := map[string]interface{}{
+		"product_id":    "64b7f0c2e4b0a1a2b3c4d5e6",
+		"movement_type": "damage",
+		"quantity":      2,
+		"unit_cost":     "1.20",
+		"created_at":    "2026-03-01T10:00:00Z",
+	}
+
+	var payload domain.StockAdjustmentSyncPayload
+	fields := fieldErrors(t, domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload))
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "data.unit_cost", fields[0].Path)
+
+	data["movement_type"] = "purchase"
+	payload = domain.StockAdjustmentSyncPayload{}
+	assert.NoError(t, domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload))
+	assert.Equal(t, "1.2", payload.Cost.String())
+}
+
+func TestDecodeSyncPayload_SaleLinesSumToTotal(t *testing.T) {
+	data := map[string]interface{}{
+		"lines": []interface{}{
+			map[string]interface{}{"product_local_id": "p-1", "quantity": 2, "unit_price": "4.50"},
+			map[string]interface{}{"quantity": 1, "unit_price": "10.00", "discount": "1.00"},
+		},
+		"amount":     "18.00",
+		"created_at": "2026-03-01T10:00:00Z",
+	}
+
+	var payload domain.SaleSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload)
+
+	assert.NoError(t, err)
+	assert.Len(t, payload.Lines, 2)
+	assert.Equal(t, "p-1", payload.Lines[0].Product().LocalID)
+	assert.Equal(t, "18", payload.Total.String())
+}
+
+func TestDecodeSyncPayload_SaleLineErrorsPointAtTheLine(t *testing.T) {
+	data := map[string]interface{}{
+		"lines": []interface{}{
+			map[string]interface{}{"quantity": 1, "unit_price": "5.00"},
+			map[string]interface{}{"quantity": 0, "unit_price": "5.00", "discount": "9.00"},
+		},
+		"amount":     "50.00",
+		"created_at": "2026-03-01T10:00:00Z",
+	}
+
+	var payload domain.SaleSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload)
+
+	paths := []string{}
+	for _, field := range fieldErrors(t, err) {
+		paths = append(paths, field.Path)
+	}
+	assert.Equal(t, []string{"data.lines[1].quantity", "data.lines[1].discount", "data.amount"}, paths)
+}
+
+func TestDecodeSyncPayload_FractionalQuantities(t *testing.T) {
+	t.Run("Numbers and decimal strings are read", func(t *testing.T) {
+		data := map[string]interface{}{
+			"lines": []interface{}{
+				map[string]interface{}{"quantity": "0.25", "unit_price": "12.00"},
+				map[string]interface{}{"quantity": 1.5, "unit_price": "2.00"},
+			},
+			"created_at": "2026-03-01T10:00:00Z",
+		}
+
+		var payload domain.SaleSyncPayload
+		err := domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "0.25", payload.Lines[0].Units.String())
+		assert.Equal(t, "1.5", payload.Lines[1].Units.String())
+		assert.Equal(t, "6", payload.Total.String())
+	})
+
+	t.Run("A quantity that is not a number points at its field", func(t *testing.T) {
+		data := map[string]interface{}{
+			"product_local_id": "p-1",
+			"movement_type":    "damage",
+			"quantity":         "half",
+			"created_at":       "2026-03-01T10:00:00Z",
+		}
+
+		var payload domain.StockAdjustmentSyncPayload
+		err := domain.DecodeSyncPayload(domain.SyncSchemaV2, data, &payload)
+
+		fields := fieldErrors(t, err)
+		if assert.Len(t, fields, 1) {
+			assert.Equal(t, "data.quantity", fields[0].Path)
+			assert.Equal(t, domain.SyncFieldInvalid, fields[0].Code)
+		}
+	})
+}
+
+func TestDecodeSyncPayload_V1UpgradesSaleLineAmounts(t *testing.T) {
+	data := map[string]interface{}{
+		"lines":      []interface{}{map[string]interface{}{"quantity": 3.0, "unit_price": 2.5}},
+		"created_at": "2026-03-01T10:00:00Z",
+	}
+
+	var payload domain.SaleSyncPayload
+	err := domain.DecodeSyncPayload(domain.SyncSchemaV1, data, &payload)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "7.5", payload.Total.String())
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func pullTestBusiness(t *testing.T, db *mongo.Database) primitive.ObjectID {
+	businessID := primitive.NewObjectID()
+	_, err := db.Collection("businesses").InsertOne(context.Background(), bson.M{
+		"_id":          businessID,
+		"sync_devices": bson.A{bson.M{"device_id": "device_1", "status": domain.DeviceStatusActive, "registered_at": time.Now().UTC()}},
+	})
+	assert.NoError(t, err)
+	return businessID
+}
+
+func TestPullChanges_PagesTheSnapshot(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := pullTestBusiness(t, db)
+	now := time.Now().UTC()
+
+	want := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		id := primitive.NewObjectID()
+		_, err := db.Collection("expenses").InsertOne(ctx, bson.M{"_id": id, "business_id": businessID, "category": "transport", "amount": "10", "version": 1, "is_voided": false, "created_at": now})
+		assert.NoError(t, err)
+		want[id.Hex()] = true
+	}
+	for i := 0; i < 2; i++ {
+		id := primitive.NewObjectID()
+		_, err := db.Collection("products").InsertOne(ctx, bson.M{"_id": id, "business_id": businessID, "name": "Widget", "stock_quantity": 0, "version": 1, "created_at": now})
+		assert.NoError(t, err)
+		want[id.Hex()] = true
+	}
+
+	repo := repositories.NewSyncRepository(db)
+	got := map[string]bool{}
+	var cursor *domain.SyncPullCursor
+	for pages := 0; ; pages++ {
+		if !assert.Less(t, pages, 5, "the snapshot must end") {
+			return
+		}
+		response, next, err := repo.PullChanges(ctx, businessID.Hex(), "device_1", cursor, 2)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.LessOrEqual(t, len(response.Expenses)+len(response.Products), 2)
+		for _, expense := range response.Expenses {
+			got[expense.ID.Hex()] = true
+		}
+		for _, product := range response.Products {
+			got[product.ID.Hex()] = true
+		}
+		cursor = &next
+		if !response.HasMore {
+			break
+		}
+	}
+
+	assert.Equal(t, want, got)
+	assert.Empty(t, cursor.Snapshot, "a finished snapshot resumes from the feed")
+}
+
+func TestPullChanges_WaitsForChangesStillBeingPublished(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := pullTestBusiness(t, db)
+	repo := repositories.NewSyncRepository(db)
+	counter := "change_log:" + businessID.Hex()
+	longAgo := time.Now().UTC().Add(-time.Hour)
+
+	_, start, err := repo.PullChanges(ctx, businessID.Hex(), "device_1", nil, 10)
+	assert.NoError(t, err)
+
+	// A writer took sequence 1 but has not logged its change yet, while a
+	// second writer, slow to start, logged sequence 2 long after
+	slow, fast := primitive.NewObjectID(), primitive.NewObjectID()
+	_, err = db.Collection("change_outbox").InsertOne(ctx, bson.M{"_id": slow, "business_id": businessID, "entity": domain.ChangeEntityExpense, "entity_id": primitive.NewObjectID(), "operation": domain.ChangeOperationDelete, "changed_at": time.Now().UTC()})
+	assert.NoError(t, err)
+	_, err = db.Collection("counters").UpdateOne(ctx, bson.M{"_id": counter}, bson.M{"$set": bson.M{"seq": int64(2)}}, options.Update().SetUpsert(true))
+	assert.NoError(t, err)
+	_, err = db.Collection("change_log").InsertOne(ctx, bson.M{"_id": fast, "business_id": businessID, "seq": int64(2), "entity": domain.ChangeEntityExpense, "entity_id": primitive.NewObjectID(), "operation": domain.ChangeOperationDelete, "changed_at": longAgo})
+	assert.NoError(t, err)
+
+	response, next, err := repo.PullChanges(ctx, businessID.Hex(), "device_1", &start, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Deleted, "the reader must not pass the gap at sequence 1")
+	assert.True(t, response.HasMore)
+	assert.Equal(t, start.Seq, next.Seq)
+
+	// Once the first writer is done both changes are pulled
+	_, err = db.Collection("change_log").InsertOne(ctx, bson.M{"_id": slow, "business_id": businessID, "seq": int64(1), "entity": domain.ChangeEntityExpense, "entity_id": primitive.NewObjectID(), "operation": domain.ChangeOperationDelete, "changed_at": time.Now().UTC()})
+	assert.NoError(t, err)
+	_, err = db.Collection("change_outbox").DeleteOne(ctx, bson.M{"_id": slow})
+	assert.NoError(t, err)
+
+	response, next, err = repo.PullChanges(ctx, businessID.Hex(), "device_1", &start, 10)
+	assert.NoError(t, err)
+	assert.Len(t, response.Deleted, 2)
+	assert.False(t, response.HasMore)
+	assert.EqualValues(t, 2, next.Seq)
+}
+
+func TestPullChanges_PublishesChangesLeftInTheOutbox(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := pullTestBusiness(t, db)
+	repo := repositories.NewSyncRepository(db)
+
+	_, start, err := repo.PullChanges(ctx, businessID.Hex(), "device_1", nil, 10)
+	assert.NoError(t, err)
+
+	// The process that made this change died before it reached the log
+	expenseID := primitive.NewObjectID()
+	_, err = db.Collection("change_outbox").InsertOne(ctx, bson.M{
+		"_id": primitive.NewObjectID(), "business_id": businessID, "entity": domain.ChangeEntityExpense,
+		"entity_id": expenseID, "operation": domain.ChangeOperationDelete, "changed_at": time.Now().UTC().Add(-time.Hour),
+	})
+	assert.NoError(t, err)
+
+	response, _, err := repo.PullChanges(ctx, businessID.Hex(), "device_1", &start, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.SyncEntityRef{{Entity: domain.ChangeEntityExpense, ID: expenseID.Hex()}}, response.Deleted)
+	left, err := db.Collection("change_outbox").CountDocuments(ctx, bson.M{"business_id": businessID})
+	assert.NoError(t, err)
+	assert.Zero(t, left)
+}
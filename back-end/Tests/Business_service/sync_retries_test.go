@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSyncRetryState_IsFinal(t *testing.T) {
+	assert.False(t, domain.SyncRetryPending.IsFinal())
+	for _, state := range []domain.SyncRetryState{domain.SyncRetrySucceeded, domain.SyncRetryDeadLetter, domain.SyncRetryAcked, domain.SyncRetryDiscarded} {
+		assert.True(t, state.IsFinal(), state)
+	}
+}
+
+func TestSyncBatch_FailedResendDoesNotReviveFinalRetry(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID := primitive.NewObjectID()
+	now := time.Now().UTC()
+	_, err := db.Collection("businesses").InsertOne(ctx, bson.M{
+		"_id":          businessID,
+		"sync_devices": bson.A{bson.M{"device_id": "device_1", "status": domain.DeviceStatusActive, "registered_at": now}},
+	})
+	assert.NoError(t, err)
+	_, err = db.Collection("sync_retries").InsertOne(ctx, bson.M{
+		"business_id": businessID, "device_id": "device_1", "local_id": "a1",
+		"state": domain.SyncRetryAcked, "attempts": 6, "created_at": now, "updated_at": now,
+	})
+	assert.NoError(t, err)
+
+	response, err := repositories.NewSyncRepository(db).ProcessBatch(ctx, domain.SyncBatchRequest{
+		BusinessID:    businessID.Hex(),
+		DeviceID:      "device_1",
+		SchemaVersion: domain.SyncSchemaV2,
+		Transactions: []domain.SyncBatchTransaction{{
+			LocalID: "a1",
+			Type:    domain.SyncTransactionTypeStockAdjustment,
+			Data: map[string]interface{}{
+				"product_id": primitive.NewObjectID().Hex(), "movement_type": "damage", "quantity": 1, "created_at": "2026-03-01T10:00:00Z",
+			},
+		}},
+	})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, response) && assert.Len(t, response.Results, 1) {
+		assert.Equal(t, "failed", response.Results[0].Status)
+		assert.Nil(t, response.Results[0].NextRetryAt)
+	}
+	var item domain.SyncRetryItem
+	assert.NoError(t, db.Collection("sync_retries").FindOne(ctx, bson.M{"business_id": businessID, "local_id": "a1"}).Decode(&item))
+	assert.Equal(t, domain.SyncRetryAcked, item.State)
+	assert.Equal(t, 6, item.Attempts)
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSyncSale_LowersStock(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID, productID, _ := atomicTestBusiness(t, db)
+
+	repo := repositories.NewSyncRepository(db)
+	response, err := repo.ProcessBatch(ctx, domain.SyncBatchRequest{
+		BusinessID:    businessID.Hex(),
+		DeviceID:      "device_1",
+		SchemaVersion: domain.SyncSchemaV2,
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{
+				"product_id": productID.Hex(), "quantity": 4, "amount": "40", "created_at": "2026-03-01T10:00:00Z",
+			}},
+		},
+	})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, response) && assert.Len(t, response.Results, 1) {
+		assert.Equal(t, "success", response.Results[0].Status)
+		assert.NotEmpty(t, response.Results[0].ServerID)
+	}
+
+	assert.Equal(t, "6", stockOf(t, db, productID))
+	movements, err := db.Collection("stock_movements").CountDocuments(ctx, bson.M{"product_id": productID, "type": domain.MovementTypeSale})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, movements)
+}
+
+func TestSyncSale_ShortStockFailsTheItem(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	businessID, productID, _ := atomicTestBusiness(t, db)
+
+	repo := repositories.NewSyncRepository(db)
+	response, err := repo.ProcessBatch(ctx, domain.SyncBatchRequest{
+		BusinessID:    businessID.Hex(),
+		DeviceID:      "device_1",
+		SchemaVersion: domain.SyncSchemaV2,
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{
+				"product_id": productID.Hex(), "quantity": 2, "amount": "20", "created_at": "2026-03-01T10:00:00Z",
+			}},
+			// Only 8 are left after the first sale
+			{LocalID: "s2", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{
+				"product_id": productID.Hex(), "quantity": 9, "amount": "90", "created_at": "2026-03-01T10:05:00Z",
+			}},
+		},
+	})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, response) && assert.Len(t, response.Results, 2) {
+		assert.Equal(t, "success", response.Results[0].Status)
+		short := response.Results[1]
+		assert.Equal(t, "s2", short.LocalID)
+		assert.Equal(t, "failed", short.Status)
+		assert.Equal(t, domain.SyncItemCodeInsufficientStock, short.Code)
+		assert.Empty(t, short.ServerID)
+	}
+
+	assert.Equal(t, "8", stockOf(t, db, productID))
+	sales, err := db.Collection("sales").CountDocuments(ctx, bson.M{"business_id": businessID})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, sales)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSaveUploadChunk_ClaimedChunkIsNotReplaced(t *testing.T) {
+	db := openStockTestDB(t)
+	ctx := context.Background()
+	repo := repositories.NewSyncRepository(db)
+	businessID := primitive.NewObjectID()
+	_, err := db.Collection("businesses").InsertOne(ctx, bson.M{
+		"_id":          businessID,
+		"sync_devices": bson.A{bson.M{"device_id": "device_1", "status": domain.DeviceStatusActive, "registered_at": time.Now().UTC()}},
+	})
+	assert.NoError(t, err)
+
+	upload := &domain.SyncUpload{BusinessID: businessID, DeviceID: "device_1", TotalChunks: 1}
+	assert.NoError(t, repo.CreateUpload(ctx, upload))
+	uploadID := upload.ID.Hex()
+	first := []domain.SyncBatchTransaction{{LocalID: "first", Type: domain.SyncTransactionTypeSale}}
+	second := []domain.SyncBatchTransaction{{LocalID: "second", Type: domain.SyncTransactionTypeSale}}
+
+	_, err = repo.SaveUploadChunk(ctx, businessID.Hex(), uploadID, 0, first)
+	assert.NoError(t, err)
+	_, _, err = repo.ClaimUpload(ctx, businessID.Hex(), uploadID, "user_2", "device_2")
+	assert.ErrorIs(t, err, domain.ErrUploadNotYours)
+	_, transactions, err := repo.ClaimUpload(ctx, businessID.Hex(), uploadID, "", "device_1")
+	assert.NoError(t, err)
+	if assert.Len(t, transactions, 1) {
+		assert.Equal(t, "first", transactions[0].LocalID)
+	}
+
+	// A resend that found the upload open before the claim must not change
+	// what the commit read
+	_, err = db.Collection("sync_uploads").UpdateOne(ctx, bson.M{"_id": upload.ID}, bson.M{"$set": bson.M{"status": domain.SyncUploadOpen}})
+	assert.NoError(t, err)
+	_, err = repo.SaveUploadChunk(ctx, businessID.Hex(), uploadID, 0, second)
+	assert.ErrorIs(t, err, domain.ErrUploadCommitted)
+	var chunk struct {
+		Transactions []domain.SyncBatchTransaction `bson:"transactions"`
+	}
+	assert.NoError(t, db.Collection("sync_upload_chunks").FindOne(ctx, bson.M{"upload_id": upload.ID, "index": 0}).Decode(&chunk))
+	if assert.Len(t, chunk.Transactions, 1) {
+		assert.Equal(t, "first", chunk.Transactions[0].LocalID)
+	}
+
+	// Once the commit gives the upload back, the chunk can be resent
+	_, err = db.Collection("sync_uploads").UpdateOne(ctx, bson.M{"_id": upload.ID}, bson.M{"$set": bson.M{"status": domain.SyncUploadCommitting}})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.ReleaseUpload(ctx, uploadID, ""))
+	_, err = repo.SaveUploadChunk(ctx, businessID.Hex(), uploadID, 0, second)
+	assert.NoError(t, err)
+	_, transactions, err = repo.ClaimUpload(ctx, businessID.Hex(), uploadID, "", "device_1")
+	assert.NoError(t, err)
+	if assert.Len(t, transactions, 1) {
+		assert.Equal(t, "second", transactions[0].LocalID)
+	}
+}
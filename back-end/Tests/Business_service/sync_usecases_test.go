@@ -9,8 +9,10 @@ import (
 	repositories "shop-ops/Repositories"
 	usecases "shop-ops/Usecases"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // --- Mock ---
@@ -43,6 +45,106 @@ func (m *MockSyncRepository) GetHistory(ctx context.Context, businessID string,
 	return args.Get(0).(*domain.SyncHistoryResponse), args.Error(1)
 }
 
+func (m *MockSyncRepository) GetHealth(ctx context.Context, query domain.SyncHealthQuery) (*domain.SyncHealthReport, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SyncHealthReport), args.Error(1)
+}
+
+func (m *MockSyncRepository) PullChanges(ctx context.Context, businessID, deviceID string, cursor *domain.SyncPullCursor, limit int) (*domain.SyncPullResponse, domain.SyncPullCursor, error) {
+	args := m.Called(ctx, businessID, deviceID, cursor, limit)
+	if args.Get(0) == nil {
+		return nil, domain.SyncPullCursor{}, args.Error(2)
+	}
+	return args.Get(0).(*domain.SyncPullResponse), args.Get(1).(domain.SyncPullCursor), args.Error(2)
+}
+
+func (m *MockSyncRepository) ListConflicts(ctx context.Context, businessID string, status domain.SyncConflictStatus, page, limit int) (*domain.SyncConflictList, error) {
+	args := m.Called(ctx, businessID, status, page, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SyncConflictList), args.Error(1)
+}
+
+func (m *MockSyncRepository) ResolveConflict(ctx context.Context, businessID, conflictID, userID string, resolution domain.SyncConflictResolution) (*domain.SyncConflict, error) {
+	args := m.Called(ctx, businessID, conflictID, userID, resolution)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SyncConflict), args.Error(1)
+}
+
+func (m *MockSyncRepository) GetItemHistory(ctx context.Context, businessID, deviceID, localID string) (*domain.SyncItemHistory, error) {
+	args := m.Called(ctx, businessID, deviceID, localID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SyncItemHistory), args.Error(1)
+}
+
+func (m *MockSyncRepository) ListRetries(ctx context.Context, businessID, deviceID string, state domain.SyncRetryState, page, limit int) (*domain.SyncRetryList, error) {
+	args := m.Called(ctx, businessID, deviceID, state, page, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SyncRetryList), args.Error(1)
+}
+
+func (m *MockSyncRepository) UpdateRetryState(ctx context.Context, businessID, deviceID string, localIDs []string, from []domain.SyncRetryState, to domain.SyncRetryState) (int64, error) {
+	args := m.Called(ctx, businessID, deviceID, localIDs, from, to)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSyncRepository) ProcessRetries(ctx context.Context, now time.Time, limit int, authorize repositories.RetryAuthorizer) ([]domain.SyncRetryOutcome, error) {
+	args := m.Called(ctx, now, limit, authorize)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SyncRetryOutcome), args.Error(1)
+}
+
+func (m *MockSyncRepository) RecoverJournals(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockSyncRepository) CreateUpload(ctx context.Context, upload *domain.SyncUpload) error {
+	args := m.Called(ctx, upload)
+	return args.Error(0)
+}
+
+func (m *MockSyncRepository) GetUpload(ctx context.Context, businessID, uploadID string) (*domain.SyncUpload, error) {
+	args := m.Called(ctx, businessID, uploadID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SyncUpload), args.Error(1)
+}
+
+func (m *MockSyncRepository) SaveUploadChunk(ctx context.Context, businessID, uploadID string, index int, transactions []domain.SyncBatchTransaction) (*domain.SyncUpload, error) {
+	args := m.Called(ctx, businessID, uploadID, index, transactions)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SyncUpload), args.Error(1)
+}
+
+func (m *MockSyncRepository) ClaimUpload(ctx context.Context, businessID, uploadID, userID, deviceID string) (*domain.SyncUpload, []domain.SyncBatchTransaction, error) {
+	args := m.Called(ctx, businessID, uploadID, userID, deviceID)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*domain.SyncUpload), args.Get(1).([]domain.SyncBatchTransaction), args.Error(2)
+}
+
+func (m *MockSyncRepository) ReleaseUpload(ctx context.Context, uploadID, syncID string) error {
+	args := m.Called(ctx, uploadID, syncID)
+	return args.Error(0)
+}
+
 // Compile-time interface check
 var _ repositories.SyncRepository = (*MockSyncRepository)(nil)
 
@@ -50,7 +152,7 @@ var _ repositories.SyncRepository = (*MockSyncRepository)(nil)
 
 func TestSyncBatch_EmptyBusinessID(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	req := domain.SyncBatchRequest{
 		BusinessID: "",
@@ -70,7 +172,7 @@ func TestSyncBatch_EmptyBusinessID(t *testing.T) {
 
 func TestSyncBatch_EmptyDeviceID(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	req := domain.SyncBatchRequest{
 		BusinessID: "biz_123",
@@ -90,7 +192,7 @@ func TestSyncBatch_EmptyDeviceID(t *testing.T) {
 
 func TestSyncBatch_EmptyTransactions(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	req := domain.SyncBatchRequest{
 		BusinessID:   "biz_123",
@@ -108,7 +210,7 @@ func TestSyncBatch_EmptyTransactions(t *testing.T) {
 
 func TestSyncBatch_ExceedsMaxBatchSize(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	txns := make([]domain.SyncBatchTransaction, 1001)
 	for i := range txns {
@@ -139,7 +241,7 @@ func TestSyncBatch_ExceedsMaxBatchSize(t *testing.T) {
 
 func TestSyncBatch_DuplicateLocalID(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	req := domain.SyncBatchRequest{
 		BusinessID: "biz_123",
@@ -160,7 +262,7 @@ func TestSyncBatch_DuplicateLocalID(t *testing.T) {
 
 func TestSyncBatch_InvalidTransactionType(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	req := domain.SyncBatchRequest{
 		BusinessID: "biz_123",
@@ -174,13 +276,142 @@ func TestSyncBatch_InvalidTransactionType(t *testing.T) {
 
 	assert.Nil(t, result)
 	assert.Error(t, err)
-	assert.Equal(t, "transaction type must be sale or expense", err.Error())
+	assert.Equal(t, "transaction type must be one of sale, expense, product, stock_adjustment, sale_void, expense_update", err.Error())
+	mockRepo.AssertNotCalled(t, "ProcessBatch")
+}
+
+func TestSyncBatch_AcceptsInventoryAndMutationTypes(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.SyncBatchRequest{
+		BusinessID: "biz_123",
+		DeviceID:   "device_1",
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "p1", Type: domain.SyncTransactionTypeProduct, Data: map[string]interface{}{}},
+			{LocalID: "a1", Type: domain.SyncTransactionTypeStockAdjustment, Data: map[string]interface{}{}},
+			{LocalID: "v1", Type: domain.SyncTransactionTypeSaleVoid, Data: map[string]interface{}{}},
+			{LocalID: "u1", Type: domain.SyncTransactionTypeExpenseUpdate, Data: map[string]interface{}{}},
+		},
+	}
+
+	mockRepo.On("ProcessBatch", mock.Anything, req).Return(&domain.SyncBatchResponse{Status: "completed"}, nil).Once()
+
+	result, err := uc.SyncBatch(req)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSyncBatch_RejectsTypesOutsideRole(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.SyncBatchRequest{
+		BusinessID: "biz_123",
+		DeviceID:   "device_1",
+		Role:       domain.RoleCashier,
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{}},
+			{LocalID: "v1", Type: domain.SyncTransactionTypeSaleVoid, Data: map[string]interface{}{}},
+		},
+	}
+
+	result, err := uc.SyncBatch(req)
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	assert.Contains(t, err.Error(), "cashier cannot sync sale_void transactions")
 	mockRepo.AssertNotCalled(t, "ProcessBatch")
 }
 
+func TestSyncBatch_AtomicRolledBack(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.SyncBatchRequest{
+		BusinessID: "biz_123",
+		DeviceID:   "device_1",
+		Atomic:     true,
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{}},
+			{LocalID: "s2", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{}},
+		},
+	}
+
+	expected := &domain.SyncBatchResponse{
+		Status: "rolled_back",
+		Results: []domain.SyncItemResult{
+			{LocalID: "s1", Status: "rolled_back"},
+			{LocalID: "s2", Status: "failed", Code: domain.SyncItemCodeInsufficientStock},
+		},
+		Summary: domain.SyncSummary{Total: 2, Failed: 1, RolledBack: 1},
+	}
+	mockRepo.On("ProcessBatch", mock.Anything, req).Return(expected, nil).Once()
+
+	result, err := uc.SyncBatch(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "rolled_back", result.Status)
+	assert.Equal(t, 1, result.Summary.RolledBack)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSyncBatch_UnsupportedSchemaVersion(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.SyncBatchRequest{
+		BusinessID:    "biz_123",
+		DeviceID:      "device_1",
+		SchemaVersion: domain.CurrentSyncSchemaVersion + 1,
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{}},
+		},
+	}
+
+	result, err := uc.SyncBatch(req)
+
+	assert.Nil(t, result)
+	assert.Equal(t, domain.ErrUnsupportedSchemaVersion, err)
+	mockRepo.AssertNotCalled(t, "ProcessBatch")
+}
+
+func TestSyncBatch_PassesSchemaVersionThrough(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.SyncBatchRequest{
+		BusinessID:    "biz_123",
+		DeviceID:      "device_1",
+		SchemaVersion: domain.SyncSchemaV2,
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale, Data: map[string]interface{}{"amount": 10}},
+		},
+	}
+	expected := &domain.SyncBatchResponse{
+		Status: "partial_success",
+		Results: []domain.SyncItemResult{{
+			LocalID: "s1",
+			Status:  "failed",
+			Code:    domain.SyncItemCodeValidationFailed,
+			Errors:  []domain.SyncFieldError{{Path: "data.amount", Code: domain.SyncFieldInvalid, Message: "must be a string"}},
+		}},
+		Summary: domain.SyncSummary{Total: 1, Failed: 1},
+	}
+	mockRepo.On("ProcessBatch", mock.Anything, req).Return(expected, nil).Once()
+
+	result, err := uc.SyncBatch(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "data.amount", result.Results[0].Errors[0].Path)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestSyncBatch_EmptyLocalID(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	req := domain.SyncBatchRequest{
 		BusinessID: "biz_123",
@@ -200,7 +431,7 @@ func TestSyncBatch_EmptyLocalID(t *testing.T) {
 
 func TestSyncBatch_ValidRequest(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	req := domain.SyncBatchRequest{
 		BusinessID: "biz_123",
@@ -235,11 +466,52 @@ func TestSyncBatch_ValidRequest(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// --- GetHealth Tests ---
+
+func TestGetHealth_DefaultsWindowAndSilentDays(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	report := &domain.SyncHealthReport{BusinessID: "biz_123", SuccessRate: 1}
+	mockRepo.On("GetHealth", mock.Anything, mock.MatchedBy(func(q domain.SyncHealthQuery) bool {
+		window := q.To.Sub(q.From)
+		return q.BusinessID == "biz_123" && q.SilentDays == 7 && q.TopFailures == 10 && window == 30*24*time.Hour
+	})).Return(report, nil).Once()
+
+	result, err := uc.GetHealth(domain.SyncHealthQuery{BusinessID: "biz_123"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, report, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetHealth_RejectsInvalidWindow(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+	now := time.Now().UTC()
+
+	_, err := uc.GetHealth(domain.SyncHealthQuery{BusinessID: "biz_123", From: now, To: now.Add(-time.Hour)})
+	assert.EqualError(t, err, "from must be before to")
+
+	_, err = uc.GetHealth(domain.SyncHealthQuery{BusinessID: "biz_123", From: now.AddDate(-2, 0, 0), To: now})
+	assert.EqualError(t, err, "the report window cannot exceed 366 days")
+
+	_, err = uc.GetHealth(domain.SyncHealthQuery{BusinessID: "biz_123", SilentDays: -1})
+	assert.EqualError(t, err, "silent_days must be between 1 and 365")
+
+	mockRepo.AssertNotCalled(t, "GetHealth")
+}
+
+func TestSyncHealthItems_Rate(t *testing.T) {
+	assert.Equal(t, 1.0, domain.SyncHealthItems{}.Rate())
+	assert.Equal(t, 0.75, domain.SyncHealthItems{Total: 4, Success: 3}.Rate())
+}
+
 // --- GetStatus Tests ---
 
 func TestGetStatus_EmptyBusinessID(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	result, err := uc.GetStatus("", "device_1")
 
@@ -251,7 +523,7 @@ func TestGetStatus_EmptyBusinessID(t *testing.T) {
 
 func TestGetStatus_ValidRequest(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	expectedResp := &domain.SyncStatusResponse{
 		BusinessID:     "biz_123",
@@ -279,7 +551,7 @@ func TestGetStatus_ValidRequest(t *testing.T) {
 
 func TestGetHistory_EmptyBusinessID(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	result, err := uc.GetHistory("", 1, 20)
 
@@ -291,7 +563,7 @@ func TestGetHistory_EmptyBusinessID(t *testing.T) {
 
 func TestGetHistory_NormalizesPageAndLimit(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	expectedResp := &domain.SyncHistoryResponse{
 		Data: []domain.SyncLog{},
@@ -309,7 +581,7 @@ func TestGetHistory_NormalizesPageAndLimit(t *testing.T) {
 
 func TestGetHistory_CapsLimitAt100(t *testing.T) {
 	mockRepo := new(MockSyncRepository)
-	uc := usecases.NewSyncUseCases(mockRepo)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
 
 	expectedResp := &domain.SyncHistoryResponse{
 		Data: []domain.SyncLog{},
@@ -325,6 +597,152 @@ func TestGetHistory_CapsLimitAt100(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// --- Pull Tests ---
+
+func TestPull_EmptyDeviceID(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	result, err := uc.Pull(domain.SyncPullRequest{BusinessID: "biz_123"})
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	assert.Equal(t, "device_id is required", err.Error())
+	mockRepo.AssertNotCalled(t, "PullChanges")
+}
+
+func TestPull_InvalidCursor(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	result, err := uc.Pull(domain.SyncPullRequest{BusinessID: "biz_123", DeviceID: "device_1", Cursor: "not-a-cursor"})
+
+	assert.Nil(t, result)
+	assert.Equal(t, domain.ErrInvalidSyncCursor, err)
+	mockRepo.AssertNotCalled(t, "PullChanges")
+}
+
+func TestPull_SnapshotThenResumeFromCursor(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+	lastSale := primitive.NewObjectID()
+
+	// Empty cursor requests a snapshot with the default limit
+	mockRepo.On("PullChanges", mock.Anything, "biz_123", "device_1", (*domain.SyncPullCursor)(nil), 500).
+		Return(&domain.SyncPullResponse{HasMore: true}, domain.SyncPullCursor{Seq: 42, Snapshot: domain.ChangeEntitySale, After: lastSale}, nil).Once()
+
+	first, err := uc.Pull(domain.SyncPullRequest{BusinessID: "biz_123", DeviceID: "device_1"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.True(t, first.HasMore)
+	assert.False(t, first.ServerTime.IsZero())
+
+	// The snapshot cursor resumes the snapshot after the last sale sent
+	mockRepo.On("PullChanges", mock.Anything, "biz_123", "device_1", &domain.SyncPullCursor{Seq: 42, Snapshot: domain.ChangeEntitySale, After: lastSale}, 500).
+		Return(&domain.SyncPullResponse{}, domain.SyncPullCursor{Seq: 42}, nil).Once()
+
+	second, err := uc.Pull(domain.SyncPullRequest{BusinessID: "biz_123", DeviceID: "device_1", Cursor: first.NextCursor})
+	assert.NoError(t, err)
+	assert.False(t, second.HasMore)
+
+	// Once the snapshot is done the cursor resumes the feed after sequence 42,
+	// and the limit is capped
+	mockRepo.On("PullChanges", mock.Anything, "biz_123", "device_1", &domain.SyncPullCursor{Seq: 42}, 1000).
+		Return(&domain.SyncPullResponse{HasMore: true}, domain.SyncPullCursor{Seq: 50}, nil).Once()
+
+	third, err := uc.Pull(domain.SyncPullRequest{BusinessID: "biz_123", DeviceID: "device_1", Cursor: second.NextCursor, Limit: 5000})
+	assert.NoError(t, err)
+	assert.True(t, third.HasMore)
+	assert.NotEqual(t, second.NextCursor, third.NextCursor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPull_LeavesOutExpensesAndCostsForCashiers(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+	expenseID, saleID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	pulled := func() *domain.SyncPullResponse {
+		return &domain.SyncPullResponse{
+			Sales:          []domain.Sale{{Lines: []domain.SaleLine{{Quantity: decimal.NewFromInt(1), Total: decimal.NewFromInt(10), Cost: decimal.NewFromInt(6)}}}},
+			Expenses:       []domain.Expense{{ID: expenseID}},
+			Products:       []domain.Product{{Name: "Widget", UnitCost: decimal.NewFromInt(6)}},
+			StockMovements: []domain.StockMovement{{Quantity: decimal.NewFromInt(-1), UnitCost: decimal.NewFromInt(6), Cost: decimal.NewFromInt(6)}},
+			Voided:         []domain.SyncEntityRef{{Entity: domain.ChangeEntityExpense, ID: expenseID.Hex()}, {Entity: domain.ChangeEntitySale, ID: saleID.Hex()}},
+			Deleted:        []domain.SyncEntityRef{},
+		}
+	}
+	mockRepo.On("PullChanges", mock.Anything, "biz_123", "device_1", (*domain.SyncPullCursor)(nil), 500).Return(pulled(), domain.SyncPullCursor{Seq: 1}, nil).Once()
+	mockRepo.On("PullChanges", mock.Anything, "biz_123", "device_2", (*domain.SyncPullCursor)(nil), 500).Return(pulled(), domain.SyncPullCursor{Seq: 1}, nil).Once()
+
+	cashier, err := uc.Pull(domain.SyncPullRequest{BusinessID: "biz_123", DeviceID: "device_1", Role: domain.RoleCashier})
+	assert.NoError(t, err)
+	assert.Empty(t, cashier.Expenses)
+	assert.Equal(t, []domain.SyncEntityRef{{Entity: domain.ChangeEntitySale, ID: saleID.Hex()}}, cashier.Voided)
+	assert.True(t, cashier.Sales[0].Lines[0].Cost.IsZero())
+	assert.True(t, cashier.Products[0].UnitCost.IsZero())
+	assert.True(t, cashier.StockMovements[0].Cost.IsZero())
+
+	manager, err := uc.Pull(domain.SyncPullRequest{BusinessID: "biz_123", DeviceID: "device_2", Role: domain.RoleManager})
+	assert.NoError(t, err)
+	assert.Len(t, manager.Expenses, 1)
+	assert.Len(t, manager.Voided, 2)
+	assert.Equal(t, "6", manager.Products[0].UnitCost.String())
+}
+
+// --- Conflict Tests ---
+
+func TestListConflicts_InvalidStatus(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	result, err := uc.ListConflicts("biz_123", "pending", 1, 20)
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	assert.Equal(t, "status must be open or resolved", err.Error())
+	mockRepo.AssertNotCalled(t, "ListConflicts")
+}
+
+func TestListConflicts_DefaultsPagination(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	expectedResp := &domain.SyncConflictList{Data: []domain.SyncConflict{}}
+	mockRepo.On("ListConflicts", mock.Anything, "biz_123", domain.SyncConflictOpen, 1, 20).Return(expectedResp, nil).Once()
+
+	result, err := uc.ListConflicts("biz_123", domain.SyncConflictOpen, 0, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedResp, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResolveConflict_InvalidResolution(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	result, err := uc.ResolveConflict("conflict_1", "user_1", domain.ResolveSyncConflictRequest{BusinessID: "biz_123", Resolution: "both"})
+
+	assert.Nil(t, result)
+	assert.Equal(t, domain.ErrInvalidResolution, err)
+	mockRepo.AssertNotCalled(t, "ResolveConflict")
+}
+
+func TestResolveConflict_ClientWins(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	resolved := &domain.SyncConflict{Status: domain.SyncConflictResolved}
+	mockRepo.On("ResolveConflict", mock.Anything, "biz_123", "conflict_1", "user_1", domain.SyncResolutionClient).Return(resolved, nil).Once()
+
+	result, err := uc.ResolveConflict("conflict_1", "user_1", domain.ResolveSyncConflictRequest{BusinessID: "biz_123", Resolution: domain.SyncResolutionClient})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.SyncConflictResolved, result.Status)
+	mockRepo.AssertExpectations(t)
+}
+
 // --- Helpers ---
 
 func itoa(n int) string {
@@ -338,3 +756,315 @@ func itoa(n int) string {
 	}
 	return s
 }
+
+// --- Retry queue Tests ---
+
+func TestSyncRetryPolicy_BackoffDoublesUpToMax(t *testing.T) {
+	policy := domain.SyncRetryPolicy{BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute, MaxAttempts: 6}
+
+	assert.Equal(t, 30*time.Second, policy.Backoff(1))
+	assert.Equal(t, 60*time.Second, policy.Backoff(2))
+	assert.Equal(t, 120*time.Second, policy.Backoff(3))
+	assert.Equal(t, 240*time.Second, policy.Backoff(4))
+	assert.Equal(t, 5*time.Minute, policy.Backoff(5))
+	assert.Equal(t, 5*time.Minute, policy.Backoff(50))
+	assert.False(t, policy.Exhausted(5))
+	assert.True(t, policy.Exhausted(6))
+}
+
+func TestListRetries_InvalidState(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	result, err := uc.ListRetries("biz_123", "", domain.SyncRetryState("waiting"), 1, 20)
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "ListRetries")
+}
+
+func TestListRetries_NormalizesPageAndLimit(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	expected := &domain.SyncRetryList{Data: []domain.SyncRetryItem{}}
+	mockRepo.On("ListRetries", mock.Anything, "biz_123", "device_1", domain.SyncRetryDeadLetter, 1, 100).Return(expected, nil).Once()
+
+	result, err := uc.ListRetries("biz_123", "device_1", domain.SyncRetryDeadLetter, 0, 500)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAckRetries_OnlyFinishedItems(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.SyncRetryActionRequest{BusinessID: "biz_123", DeviceID: "device_1", LocalIDs: []string{"s1", "s2"}}
+	from := []domain.SyncRetryState{domain.SyncRetrySucceeded, domain.SyncRetryDeadLetter}
+	mockRepo.On("UpdateRetryState", mock.Anything, "biz_123", "device_1", req.LocalIDs, from, domain.SyncRetryAcked).Return(int64(2), nil).Once()
+
+	result, err := uc.AckRetries(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.Updated)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDiscardRetries_PendingAndDeadLetter(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.SyncRetryActionRequest{BusinessID: "biz_123", DeviceID: "device_1", LocalIDs: []string{"s1"}}
+	from := []domain.SyncRetryState{domain.SyncRetryPending, domain.SyncRetryDeadLetter}
+	mockRepo.On("UpdateRetryState", mock.Anything, "biz_123", "device_1", req.LocalIDs, from, domain.SyncRetryDiscarded).Return(int64(1), nil).Once()
+
+	result, err := uc.DiscardRetries(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result.Updated)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDiscardRetries_EmptyLocalIDs(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	result, err := uc.DiscardRetries(domain.SyncRetryActionRequest{BusinessID: "biz_123", DeviceID: "device_1"})
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	assert.Equal(t, "local_ids cannot be empty", err.Error())
+	mockRepo.AssertNotCalled(t, "UpdateRetryState")
+}
+
+func TestProcessRetries_DrainsInChunks(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	mockRepo.On("ProcessRetries", mock.Anything, mock.Anything, 100, mock.Anything).Return(make([]domain.SyncRetryOutcome, 100), nil).Once()
+	mockRepo.On("ProcessRetries", mock.Anything, mock.Anything, 100, mock.Anything).Return(make([]domain.SyncRetryOutcome, 7), nil).Once()
+
+	processed, err := uc.ProcessRetries(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 107, processed)
+	mockRepo.AssertExpectations(t)
+}
+
+type MockSyncRoleResolver struct {
+	mock.Mock
+}
+
+func (m *MockSyncRoleResolver) ResolveRole(businessId string, userId string) (domain.BusinessRole, error) {
+	args := m.Called(businessId, userId)
+	return args.Get(0).(domain.BusinessRole), args.Error(1)
+}
+
+func TestProcessRetries_ResolvesTheRoleBeforeEachAttempt(t *testing.T) {
+	businessID := primitive.NewObjectID()
+	mockRepo := new(MockSyncRepository)
+	roles := new(MockSyncRoleResolver)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, roles)
+
+	var authorize repositories.RetryAuthorizer
+	mockRepo.On("ProcessRetries", mock.Anything, mock.Anything, 100, mock.Anything).Run(func(args mock.Arguments) {
+		authorize = args.Get(3).(repositories.RetryAuthorizer)
+	}).Return([]domain.SyncRetryOutcome{}, nil).Once()
+	_, err := uc.ProcessRetries(context.Background())
+	assert.NoError(t, err)
+
+	item := func(userID string, txType domain.SyncTransactionType) *domain.SyncRetryItem {
+		return &domain.SyncRetryItem{BusinessID: businessID, UserID: userID, Role: domain.RoleManager, Type: txType}
+	}
+
+	t.Run("The current role is used", func(t *testing.T) {
+		roles.On("ResolveRole", businessID.Hex(), "user_1").Return(domain.RoleCashier, nil).Once()
+
+		role, err := authorize(item("user_1", domain.SyncTransactionTypeSale))
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.RoleCashier, role)
+	})
+
+	t.Run("A type the current role may not sync is denied", func(t *testing.T) {
+		roles.On("ResolveRole", businessID.Hex(), "user_2").Return(domain.RoleCashier, nil).Once()
+
+		_, err := authorize(item("user_2", domain.SyncTransactionTypeProduct))
+
+		assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	})
+
+	t.Run("A removed member is denied", func(t *testing.T) {
+		roles.On("ResolveRole", businessID.Hex(), "user_3").Return(domain.BusinessRole(""), domain.ErrNotMember).Once()
+
+		_, err := authorize(item("user_3", domain.SyncTransactionTypeSale))
+
+		assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	})
+
+	roles.AssertExpectations(t)
+}
+
+func TestGetItemHistory_RequiresLocalID(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	result, err := uc.GetItemHistory("biz_123", "device_1", " ")
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "GetItemHistory")
+}
+
+// --- Chunked upload Tests ---
+
+func TestStartUpload_InvalidTotalChunks(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.StartSyncUploadRequest{BusinessID: primitive.NewObjectID().Hex(), DeviceID: "device_1", TotalChunks: 0}
+	result, err := uc.StartUpload(req)
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	assert.Equal(t, "total_chunks must be between 1 and 500", err.Error())
+	mockRepo.AssertNotCalled(t, "CreateUpload")
+}
+
+func TestStartUpload_ReportsAllChunksMissing(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.StartSyncUploadRequest{BusinessID: primitive.NewObjectID().Hex(), DeviceID: "device_1", TotalChunks: 3, UserID: "user_1"}
+	mockRepo.On("CreateUpload", mock.Anything, mock.MatchedBy(func(u *domain.SyncUpload) bool {
+		return u.DeviceID == "device_1" && u.TotalChunks == 3 && u.UserID == "user_1"
+	})).Return(nil).Once()
+
+	result, err := uc.StartUpload(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, result.MissingChunks)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUploadChunk_RejectsDuplicateLocalIDs(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	req := domain.SyncUploadChunkRequest{
+		BusinessID: "biz_123",
+		Transactions: []domain.SyncBatchTransaction{
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale},
+			{LocalID: "s1", Type: domain.SyncTransactionTypeSale},
+		},
+	}
+	result, err := uc.UploadChunk("upload_1", 0, req)
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "SaveUploadChunk")
+}
+
+func TestUploadChunk_ReturnsMissingChunks(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	txs := []domain.SyncBatchTransaction{{LocalID: "s1", Type: domain.SyncTransactionTypeSale}}
+	saved := &domain.SyncUpload{TotalChunks: 3, ReceivedChunks: []int{2, 0}}
+	mockRepo.On("SaveUploadChunk", mock.Anything, "biz_123", "upload_1", 2, txs).Return(saved, nil).Once()
+
+	result, err := uc.UploadChunk("upload_1", 2, domain.SyncUploadChunkRequest{BusinessID: "biz_123", Transactions: txs})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, result.MissingChunks)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCommitUpload_ProcessesAllChunksAsOneBatch(t *testing.T) {
+	businessID := primitive.NewObjectID().Hex()
+	mockRepo := new(MockSyncRepository)
+	roles := new(MockSyncRoleResolver)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, roles)
+
+	upload := &domain.SyncUpload{DeviceID: "device_1", UserID: "user_1", Atomic: true, TotalChunks: 2, ReceivedChunks: []int{0, 1}}
+	txs := []domain.SyncBatchTransaction{
+		{LocalID: "s1", Type: domain.SyncTransactionTypeSale},
+		{LocalID: "e1", Type: domain.SyncTransactionTypeExpense},
+	}
+	// Another cashier on the same till commits; the batch still runs as the
+	// user who uploaded it
+	mockRepo.On("ClaimUpload", mock.Anything, businessID, "upload_1", "user_2", "device_1").Return(upload, txs, nil).Once()
+	roles.On("ResolveRole", businessID, "user_1").Return(domain.RoleManager, nil).Once()
+	mockRepo.On("ProcessBatch", mock.Anything, domain.SyncBatchRequest{
+		BusinessID:   businessID,
+		DeviceID:     "device_1",
+		Transactions: txs,
+		Atomic:       true,
+		UserID:       "user_1",
+		Role:         domain.RoleManager,
+	}).Return(&domain.SyncBatchResponse{SyncID: "sync_1", Status: "completed"}, nil).Once()
+	mockRepo.On("ReleaseUpload", mock.Anything, "upload_1", "sync_1").Return(nil).Once()
+
+	result, err := uc.CommitUpload("upload_1", domain.CommitSyncUploadRequest{BusinessID: businessID, DeviceID: "device_1", UserID: "user_2", Role: domain.RoleCashier})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "sync_1", result.SyncID)
+	mockRepo.AssertExpectations(t)
+	roles.AssertExpectations(t)
+}
+
+func TestCommitUpload_ChecksTheUploadersCurrentRole(t *testing.T) {
+	businessID := primitive.NewObjectID().Hex()
+	mockRepo := new(MockSyncRepository)
+	roles := new(MockSyncRoleResolver)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, roles)
+
+	upload := &domain.SyncUpload{DeviceID: "device_1", UserID: "user_1", TotalChunks: 1, ReceivedChunks: []int{0}}
+	txs := []domain.SyncBatchTransaction{{LocalID: "p1", Type: domain.SyncTransactionTypeProduct}}
+	mockRepo.On("ClaimUpload", mock.Anything, businessID, "upload_1", "user_1", "").Return(upload, txs, nil).Once()
+	// The uploader was a manager when the chunk arrived and is a cashier now
+	roles.On("ResolveRole", businessID, "user_1").Return(domain.RoleCashier, nil).Once()
+	mockRepo.On("ReleaseUpload", mock.Anything, "upload_1", "").Return(nil).Once()
+
+	result, err := uc.CommitUpload("upload_1", domain.CommitSyncUploadRequest{BusinessID: businessID, UserID: "user_1", Role: domain.RoleManager})
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	mockRepo.AssertNotCalled(t, "ProcessBatch")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCommitUpload_ReopensOnDuplicateAcrossChunks(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	upload := &domain.SyncUpload{DeviceID: "device_1", TotalChunks: 2, ReceivedChunks: []int{0, 1}}
+	txs := []domain.SyncBatchTransaction{
+		{LocalID: "s1", Type: domain.SyncTransactionTypeSale},
+		{LocalID: "s1", Type: domain.SyncTransactionTypeSale},
+	}
+	mockRepo.On("ClaimUpload", mock.Anything, "biz_123", "upload_1", "", "").Return(upload, txs, nil).Once()
+	mockRepo.On("ReleaseUpload", mock.Anything, "upload_1", "").Return(nil).Once()
+
+	result, err := uc.CommitUpload("upload_1", domain.CommitSyncUploadRequest{BusinessID: "biz_123"})
+
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "ProcessBatch")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCommitUpload_Incomplete(t *testing.T) {
+	mockRepo := new(MockSyncRepository)
+	uc := usecases.NewSyncUseCases(mockRepo, nil, nil)
+
+	mockRepo.On("ClaimUpload", mock.Anything, "biz_123", "upload_1", "", "").Return(nil, nil, domain.ErrUploadIncomplete).Once()
+
+	result, err := uc.CommitUpload("upload_1", domain.CommitSyncUploadRequest{BusinessID: "biz_123"})
+
+	assert.Nil(t, result)
+	assert.Equal(t, domain.ErrUploadIncomplete, err)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySigningKeyStore mimics the Mongo repository in memory.
+type memorySigningKeyStore struct {
+	mu   sync.Mutex
+	keys []*domain.SigningKey
+}
+
+func (s *memorySigningKeyStore) Save(key *domain.SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append([]*domain.SigningKey{key}, s.keys...)
+	return nil
+}
+
+func (s *memorySigningKeyStore) FindUnexpired(now time.Time) ([]*domain.SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []*domain.SigningKey
+	for _, key := range s.keys {
+		if key.ExpiresAt.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+func newTestJWTService(t *testing.T, store *memorySigningKeyStore, algorithm domain.SigningAlgorithm) *infrastructure.JWTService {
+	config := infrastructure.JWTConfig{Algorithm: algorithm, Secret: testJWTSecret, Rotation: time.Hour}
+	service, err := infrastructure.NewJWTService(store, config, infrastructure.NewLogger("error", ""))
+	assert.NoError(t, err)
+	return service
+}
+
+func TestJWTService_SignAndValidate(t *testing.T) {
+	for _, algorithm := range []domain.SigningAlgorithm{domain.SigningEdDSA, domain.SigningRS256} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			store := &memorySigningKeyStore{}
+			service := newTestJWTService(t, store, algorithm)
+			assert.Len(t, store.keys, 1)
+
+			signed, err := service.GenerateToken("user-1", "session-1")
+			assert.NoError(t, err)
+
+			token, err := service.ValidateToken(signed)
+			assert.NoError(t, err)
+			assert.True(t, token.Valid)
+			assert.Equal(t, string(algorithm), token.Method.Alg())
+			assert.Equal(t, store.keys[0].ID, token.Header["kid"])
+
+			jwks := service.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, store.keys[0].ID, jwks.Keys[0].KeyID)
+			assert.Equal(t, string(algorithm), jwks.Keys[0].Algorithm)
+		})
+	}
+}
+
+func TestJWTService_SharedKeysAcrossInstances(t *testing.T) {
+	store := &memorySigningKeyStore{}
+	first := newTestJWTService(t, store, domain.SigningEdDSA)
+	second := newTestJWTService(t, store, domain.SigningEdDSA)
+
+	// The second instance reuses the stored key instead of making its own
+	assert.Len(t, store.keys, 1)
+
+	signed, _ := first.GenerateToken("user-1", "session-1")
+	_, err := second.ValidateToken(signed)
+	assert.NoError(t, err)
+
+	t.Run("Keys sealed with another secret are not usable", func(t *testing.T) {
+		config := infrastructure.JWTConfig{Algorithm: domain.SigningEdDSA, Secret: "fedcba9876543210fedcba9876543210", Rotation: time.Hour}
+		other, err := infrastructure.NewJWTService(store, config, infrastructure.NewLogger("error", ""))
+		assert.NoError(t, err)
+
+		_, err = other.ValidateToken(signed)
+		assert.Error(t, err)
+	})
+}
+
+func TestJWTService_Rotation(t *testing.T) {
+	store := &memorySigningKeyStore{}
+	service := newTestJWTService(t, store, domain.SigningEdDSA)
+	oldToken, _ := service.GenerateToken("user-1", "session-1")
+	oldKid := store.keys[0].ID
+
+	// Retire the key as if its rotation period had passed
+	store.keys[0].RetiresAt = time.Now().Add(-time.Second)
+	assert.NoError(t, service.Refresh())
+	assert.Len(t, store.keys, 2)
+
+	newToken, _ := service.GenerateToken("user-1", "session-1")
+	token, err := service.ValidateToken(newToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldKid, token.Header["kid"])
+
+	// Tokens signed by the retired key still verify during its grace period
+	_, err = service.ValidateToken(oldToken)
+	assert.NoError(t, err)
+	assert.Len(t, service.JWKS().Keys, 2)
+}
+
+func TestJWTService_RejectsForeignTokens(t *testing.T) {
+	service := newTestJWTService(t, &memorySigningKeyStore{}, domain.SigningEdDSA)
+
+	t.Run("HS256 with the old shared secret", func(t *testing.T) {
+		claims := jwt.MapClaims{"user_id": "user-1", "type": "access", "exp": time.Now().Add(time.Hour).Unix()}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = "anything"
+		signed, _ := token.SignedString([]byte(testJWTSecret))
+
+		_, err := service.ValidateToken(signed)
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown key id", func(t *testing.T) {
+		other := newTestJWTService(t, &memorySigningKeyStore{}, domain.SigningEdDSA)
+		signed, _ := other.GenerateToken("user-1", "session-1")
+
+		_, err := service.ValidateToken(signed)
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthMiddleware_RequiresSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := newTestJWTService(t, &memorySigningKeyStore{}, domain.SigningEdDSA)
+	r := gin.New()
+	r.GET("/me", infrastructure.AuthMiddleware(service, nil, nil, infrastructure.NewLogger("error", "")), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id")})
+	})
+	get := func(sessionID string) int {
+		signed, err := service.GenerateToken("user-1", sessionID)
+		assert.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get("session-1"))
+	assert.Equal(t, http.StatusUnauthorized, get(""))
+}
+
+func TestJWTConfig_Validate(t *testing.T) {
+	config := infrastructure.JWTConfig{Algorithm: domain.SigningEdDSA, Rotation: time.Hour, Production: true}
+
+	for _, secret := range []string{"", "short", "your_super_secret_key_change_in_production"} {
+		config.Secret = secret
+		assert.Error(t, config.Validate(), "secret %q", secret)
+	}
+
+	config.Secret = testJWTSecret
+	assert.NoError(t, config.Validate())
+
+	config.Algorithm = "HS256"
+	assert.Error(t, config.Validate())
+
+	// Development may run without a secret
+	config = infrastructure.JWTConfig{Algorithm: domain.SigningEdDSA, Rotation: time.Hour}
+	assert.NoError(t, config.Validate())
+}
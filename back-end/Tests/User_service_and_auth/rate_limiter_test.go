@@ -0,0 +1,285 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "shop-ops/Domain"
+	infrastructure "shop-ops/Infrastructure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryRateLimitStore mimics the Mongo repository in memory.
+type memoryRateLimitStore struct {
+	mu       sync.Mutex
+	counters map[string]*domain.RateLimitCounter
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{counters: map[string]*domain.RateLimitCounter{}}
+}
+
+func (s *memoryRateLimitStore) Hit(key string, window time.Duration, expiresAt time.Time, now time.Time) (*domain.RateLimitCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counters[key]
+	if !ok {
+		c = &domain.RateLimitCounter{Key: key}
+		s.counters[key] = c
+	}
+	locked := c.LockedUntil != nil && c.LockedUntil.After(now)
+	if !locked && c.WindowStart.After(now.Add(-window)) {
+		c.Count++
+	} else if !locked {
+		c.Count = 1
+		c.WindowStart = now
+	}
+	if expiresAt.After(c.ExpiresAt) {
+		c.ExpiresAt = expiresAt
+	}
+	snapshot := *c
+	return &snapshot, nil
+}
+
+func (s *memoryRateLimitStore) Find(key string) (*domain.RateLimitCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counters[key]
+	if !ok {
+		return nil, nil
+	}
+	snapshot := *c
+	return &snapshot, nil
+}
+
+func (s *memoryRateLimitStore) Lock(key string, until time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counters[key]
+	if !ok {
+		c = &domain.RateLimitCounter{Key: key}
+		s.counters[key] = c
+	}
+	c.LockedUntil = &until
+	c.Count = 0
+	c.Lockouts++
+	c.ExpiresAt = expiresAt
+	return nil
+}
+
+func (s *memoryRateLimitStore) ClearFailures(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.counters[key]; ok {
+		c.Count = 0
+	}
+	return nil
+}
+
+func (s *memoryRateLimitStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.counters[key]; ok && c.Count > 0 {
+		c.Count--
+	}
+	return nil
+}
+
+func newRateLimitedRouter(store *memoryRateLimitStore, ip domain.RateLimitPolicy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	limiter := infrastructure.NewRateLimiter(store, ip, domain.DefaultLockoutPolicy, infrastructure.NewLogger("error", ""))
+
+	r := gin.New()
+	auth := r.Group("/auth")
+	auth.Use(limiter.LimitIP("auth"))
+	auth.POST("/login", limiter.GuardAccount(http.StatusUnauthorized), func(c *gin.Context) {
+		var req struct {
+			Phone    string `json:"phone"`
+			Password string `json:"password"`
+		}
+		_ = c.ShouldBindJSON(&req)
+		if req.Password != "correct" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials", "code": "AUTH_002"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"phone": req.Phone})
+	})
+	return r
+}
+
+func login(r *gin.Engine, ip, phone, password string) *httptest.ResponseRecorder {
+	body := `{"phone":"` + phone + `","password":"` + password + `"}`
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter_LimitIP(t *testing.T) {
+	store := newMemoryRateLimitStore()
+	r := newRateLimitedRouter(store, domain.RateLimitPolicy{Limit: 3, Window: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		w := login(r, "10.0.0.1", "+251911000001", "correct")
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := login(r, "10.0.0.1", "+251911000001", "correct")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "RATE_001")
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Other clients are unaffected
+	w = login(r, "10.0.0.2", "+251911000001", "correct")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimiter_GuardAccount(t *testing.T) {
+	t.Run("Locks the phone after repeated failures from any IP", func(t *testing.T) {
+		store := newMemoryRateLimitStore()
+		r := newRateLimitedRouter(store, domain.DefaultIPRateLimit)
+
+		for i := 0; i < domain.DefaultLockoutPolicy.MaxFailures; i++ {
+			ip := "10.0.0." + string(rune('1'+i))
+			w := login(r, ip, "+251911000001", "wrong")
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+
+		// Even the right password is refused while locked
+		w := login(r, "10.0.0.9", "+251911000001", "correct")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+		// Other accounts are unaffected
+		w = login(r, "10.0.0.9", "+251911000002", "correct")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("A successful login clears earlier failures", func(t *testing.T) {
+		store := newMemoryRateLimitStore()
+		r := newRateLimitedRouter(store, domain.DefaultIPRateLimit)
+
+		for i := 0; i < domain.DefaultLockoutPolicy.MaxFailures-1; i++ {
+			login(r, "10.0.0.1", "+251911000001", "wrong")
+		}
+		assert.Equal(t, http.StatusOK, login(r, "10.0.0.1", "+251911000001", "correct").Code)
+		assert.Equal(t, http.StatusUnauthorized, login(r, "10.0.0.1", "+251911000001", "wrong").Code)
+
+		counter, _ := store.Find("account:+251911000001")
+		assert.Equal(t, 1, counter.Count)
+	})
+
+	t.Run("Parallel guesses cannot outrun the lock", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		store := newMemoryRateLimitStore()
+		limiter := infrastructure.NewRateLimiter(store, domain.DefaultIPRateLimit, domain.DefaultLockoutPolicy, infrastructure.NewLogger("error", ""))
+		gate := make(chan struct{})
+		var reached sync.WaitGroup
+		var mu sync.Mutex
+		guesses := 0
+		r := gin.New()
+		r.POST("/auth/login", limiter.GuardAccount(http.StatusUnauthorized), func(c *gin.Context) {
+			mu.Lock()
+			guesses++
+			mu.Unlock()
+			reached.Done()
+			<-gate
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials", "code": "AUTH_002"})
+		})
+
+		// Every guess is in flight before any of them fails
+		reached.Add(domain.DefaultLockoutPolicy.MaxFailures)
+		var done sync.WaitGroup
+		codes := make(chan int, 20)
+		for i := 0; i < 20; i++ {
+			done.Add(1)
+			go func() {
+				defer done.Done()
+				codes <- login(r, "10.0.0.1", "+251911000001", "wrong").Code
+			}()
+		}
+		reached.Wait()
+		close(gate)
+		done.Wait()
+		close(codes)
+
+		refused := 0
+		for code := range codes {
+			if code == http.StatusTooManyRequests {
+				refused++
+			}
+		}
+		assert.Equal(t, domain.DefaultLockoutPolicy.MaxFailures, guesses)
+		assert.Equal(t, 20-domain.DefaultLockoutPolicy.MaxFailures, refused)
+
+		counter, _ := store.Find("account:+251911000001")
+		assert.Positive(t, counter.LockedAt(time.Now()))
+	})
+
+	t.Run("A successful login keeps the lockout history", func(t *testing.T) {
+		store := newMemoryRateLimitStore()
+		r := newRateLimitedRouter(store, domain.DefaultIPRateLimit)
+		key := "account:+251911000001"
+
+		for i := 0; i < domain.DefaultLockoutPolicy.MaxFailures; i++ {
+			login(r, "10.0.0.1", "+251911000001", "wrong")
+		}
+		// Let the first lock run out
+		past := time.Now().Add(-time.Second)
+		store.counters[key].LockedUntil = &past
+		assert.Equal(t, http.StatusOK, login(r, "10.0.0.1", "+251911000001", "correct").Code)
+
+		for i := 0; i < domain.DefaultLockoutPolicy.MaxFailures; i++ {
+			login(r, "10.0.0.1", "+251911000001", "wrong")
+		}
+		w := login(r, "10.0.0.1", "+251911000001", "correct")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "120", w.Header().Get("Retry-After"))
+	})
+}
+
+func TestRateLimiter_LimitAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := infrastructure.NewRateLimiter(newMemoryRateLimitStore(), domain.DefaultIPRateLimit, domain.DefaultLockoutPolicy, infrastructure.NewLogger("error", ""))
+	r := gin.New()
+	r.POST("/auth/otp/request", limiter.LimitAccount("otp_login", domain.RateLimitPolicy{Limit: 2, Window: time.Minute}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "sent"})
+	})
+	requestCode := func(ip, phone string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/auth/otp/request", strings.NewReader(`{"phone":"`+phone+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, requestCode("10.0.0.1", "+251911000001").Code)
+	assert.Equal(t, http.StatusOK, requestCode("10.0.0.2", "+251911000001").Code)
+
+	// A new IP does not buy the phone more codes
+	w := requestCode("10.0.0.3", "+251911000001")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Other phones are unaffected
+	assert.Equal(t, http.StatusOK, requestCode("10.0.0.3", "+251911000002").Code)
+}
+
+func TestLockoutPolicy_LockDuration(t *testing.T) {
+	policy := domain.DefaultLockoutPolicy
+
+	assert.Equal(t, time.Minute, policy.LockDuration(0))
+	assert.Equal(t, 2*time.Minute, policy.LockDuration(1))
+	assert.Equal(t, 32*time.Minute, policy.LockDuration(5))
+	assert.Equal(t, time.Hour, policy.LockDuration(6))
+	assert.Equal(t, time.Hour, policy.LockDuration(20))
+}
@@ -2,7 +2,9 @@ package tests
 
 import (
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	domain "shop-ops/Domain"
 	usecases "shop-ops/Usecases"
@@ -71,13 +73,13 @@ type MockJWTService struct {
 	mock.Mock
 }
 
-func (m *MockJWTService) GenerateToken(userId string) (string, error) {
-	args := m.Called(userId)
+func (m *MockJWTService) GenerateToken(userId, sessionId string) (string, error) {
+	args := m.Called(userId, sessionId)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockJWTService) GenerateRefreshToken(userId string) (string, error) {
-	args := m.Called(userId)
+func (m *MockJWTService) GenerateRefreshToken(userId, tokenId, familyId string) (string, error) {
+	args := m.Called(userId, tokenId, familyId)
 	return args.String(0), args.Error(1)
 }
 
@@ -89,13 +91,157 @@ func (m *MockJWTService) ValidateToken(tokenString string) (*jwt.Token, error) {
 	return args.Get(0).(*jwt.Token), args.Error(1)
 }
 
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Save(token *domain.RefreshToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) FindByID(id string) (*domain.RefreshToken, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) MarkUsed(id primitive.ObjectID, replacedBy primitive.ObjectID, at time.Time) error {
+	args := m.Called(id, replacedBy, at)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(familyId primitive.ObjectID, at time.Time) error {
+	args := m.Called(familyId, at)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeAllForUser(userId string, at time.Time) error {
+	args := m.Called(userId, at)
+	return args.Error(0)
+}
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Save(session *domain.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) FindByID(id string) (*domain.Session, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) FindActiveByUser(userId string, now time.Time) ([]*domain.Session, error) {
+	args := m.Called(userId, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) Rotate(id primitive.ObjectID, client domain.SessionClient, expiresAt time.Time, at time.Time) error {
+	args := m.Called(id, client, expiresAt, at)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) Touch(id primitive.ObjectID, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) Revoke(userId string, sessionId string, at time.Time) error {
+	args := m.Called(userId, sessionId, at)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) RevokeAllForUser(userId string, at time.Time) (int64, error) {
+	args := m.Called(userId, at)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockOTPRepository struct {
+	mock.Mock
+}
+
+func (m *MockOTPRepository) Replace(code *domain.OneTimeCode) error {
+	args := m.Called(code)
+	return args.Error(0)
+}
+
+func (m *MockOTPRepository) FindLatest(userId primitive.ObjectID, purpose domain.OTPPurpose) (*domain.OneTimeCode, error) {
+	args := m.Called(userId, purpose)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OneTimeCode), args.Error(1)
+}
+
+func (m *MockOTPRepository) RecordAttempt(id primitive.ObjectID, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *MockOTPRepository) Consume(id primitive.ObjectID, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Send(message domain.Notification) error {
+	args := m.Called(message)
+	return args.Error(0)
+}
+
+type MockInvitationRedeemer struct {
+	mock.Mock
+}
+
+func (m *MockInvitationRedeemer) ValidateInvitation(code string, phone string) (*domain.Invitation, error) {
+	args := m.Called(code, phone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRedeemer) RedeemByID(invitationId primitive.ObjectID, user *domain.User) error {
+	args := m.Called(invitationId, user)
+	return args.Error(0)
+}
+
 // --- Tests ---
 
+// expectCodeSent sets up one code of purpose being sent by SMS to phone.
+func expectCodeSent(mockOTP *MockOTPRepository, mockPwd *MockPasswordService, mockNotifier *MockNotifier, purpose domain.OTPPurpose, phone string) {
+	mockOTP.On("FindLatest", mock.Anything, purpose).Return(nil, nil).Once()
+	mockPwd.On("Hash", mock.MatchedBy(func(code string) bool { return len(code) == domain.OTPLength })).Return("hashed_code", nil).Once()
+	mockOTP.On("Replace", mock.MatchedBy(func(c *domain.OneTimeCode) bool {
+		return c.Purpose == purpose && c.Destination == phone
+	})).Return(nil).Once()
+	mockNotifier.On("Send", mock.MatchedBy(func(n domain.Notification) bool {
+		return n.Channel == domain.NotificationSMS && n.To == phone
+	})).Return(nil).Once()
+}
+
 func TestRegister(t *testing.T) {
 	mockRepo := new(MockUserRepository)
+	mockOTP := new(MockOTPRepository)
 	mockPwd := new(MockPasswordService)
 	mockJWT := new(MockJWTService)
-	uc := usecases.NewUserUseCases(mockRepo, mockPwd, mockJWT)
+	mockNotifier := new(MockNotifier)
+	uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, mockJWT, mockNotifier, nil)
 
 	req := &usecases.RegisterRequest{
 		Name:     "Test User",
@@ -109,11 +255,14 @@ func TestRegister(t *testing.T) {
 		mockRepo.On("FindByEmail", req.Email).Return(nil, nil).Once()
 		mockPwd.On("Hash", req.Password).Return("hashed_password", nil).Once()
 		mockRepo.On("Save", mock.AnythingOfType("*domain.User")).Return(nil).Once()
+		expectCodeSent(mockOTP, mockPwd, mockNotifier, domain.OTPPurposePhoneVerification, req.Phone)
 
 		user, err := uc.Register(req)
 
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
+		assert.False(t, user.PhoneVerified)
+		mockNotifier.AssertExpectations(t)
 		assert.Equal(t, req.Name, user.Name)
 		assert.Equal(t, req.Phone, user.Phone)
 		assert.Equal(t, "hashed_password", user.PasswordHash)
@@ -134,11 +283,106 @@ func TestRegister(t *testing.T) {
 	})
 }
 
+func TestRegisterWithInvitation(t *testing.T) {
+	req := &usecases.RegisterRequest{
+		Name:       "Helper",
+		Phone:      "+251911000000",
+		Password:   "password123",
+		InviteCode: "ABCD2345",
+	}
+	invitation := &domain.Invitation{ID: primitive.NewObjectID(), Phone: req.Phone, Role: domain.RoleCashier}
+
+	t.Run("Joins once the phone is verified", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		mockNotifier := new(MockNotifier)
+		mockInvites := new(MockInvitationRedeemer)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), mockNotifier, mockInvites)
+
+		mockRepo.On("FindByPhone", req.Phone).Return(nil, nil).Once()
+		mockInvites.On("ValidateInvitation", req.InviteCode, req.Phone).Return(invitation, nil).Once()
+		mockPwd.On("Hash", req.Password).Return("hashed_password", nil).Once()
+		mockRepo.On("Save", mock.MatchedBy(func(u *domain.User) bool {
+			return u.PendingInvitationID != nil && *u.PendingInvitationID == invitation.ID
+		})).Return(nil).Once()
+		expectCodeSent(mockOTP, mockPwd, mockNotifier, domain.OTPPurposePhoneVerification, req.Phone)
+
+		user, err := uc.Register(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, req.Phone, user.Phone)
+		// An unverified phone proves nothing, so the invitation waits
+		mockInvites.AssertNotCalled(t, "RedeemByID", mock.Anything, mock.Anything)
+
+		code := &domain.OneTimeCode{ID: primitive.NewObjectID(), Destination: req.Phone, CodeHash: "hashed_code", ExpiresAt: time.Now().Add(domain.OTPTTL)}
+		mockRepo.On("FindByPhone", req.Phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposePhoneVerification).Return(code, nil).Once()
+		mockOTP.On("RecordAttempt", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockPwd.On("Compare", "123456", "hashed_code").Return(true).Once()
+		mockOTP.On("Consume", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockRepo.On("Update", mock.MatchedBy(func(u *domain.User) bool {
+			return u.PhoneVerified && u.PendingInvitationID == nil
+		})).Return(nil).Once()
+		mockInvites.On("RedeemByID", invitation.ID, mock.MatchedBy(func(u *domain.User) bool {
+			return u.ID == user.ID && u.PhoneVerified
+		})).Return(nil).Once()
+
+		verified, err := uc.VerifyPhone(req.Phone, "123456")
+
+		assert.NoError(t, err)
+		assert.True(t, verified.PhoneVerified)
+		mockInvites.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Verification survives a lapsed invitation", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		mockInvites := new(MockInvitationRedeemer)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), nil, mockInvites)
+		user := &domain.User{ID: primitive.NewObjectID(), Phone: req.Phone, PendingInvitationID: &invitation.ID}
+		code := &domain.OneTimeCode{ID: primitive.NewObjectID(), Destination: req.Phone, CodeHash: "hashed_code", ExpiresAt: time.Now().Add(domain.OTPTTL)}
+
+		mockRepo.On("FindByPhone", req.Phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposePhoneVerification).Return(code, nil).Once()
+		mockOTP.On("RecordAttempt", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockPwd.On("Compare", "123456", "hashed_code").Return(true).Once()
+		mockOTP.On("Consume", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockRepo.On("Update", mock.AnythingOfType("*domain.User")).Return(nil).Once()
+		mockInvites.On("RedeemByID", invitation.ID, user).Return(domain.ErrInvitationExpired).Once()
+
+		verified, err := uc.VerifyPhone(req.Phone, "123456")
+
+		assert.NoError(t, err)
+		assert.True(t, verified.PhoneVerified)
+		assert.Nil(t, verified.PendingInvitationID)
+	})
+
+	t.Run("Bad code creates no account", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockInvites := new(MockInvitationRedeemer)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, new(MockPasswordService), new(MockJWTService), nil, mockInvites)
+
+		mockRepo.On("FindByPhone", req.Phone).Return(nil, nil).Once()
+		mockInvites.On("ValidateInvitation", req.InviteCode, req.Phone).Return(nil, domain.ErrInvitationExpired).Once()
+
+		user, err := uc.Register(req)
+
+		assert.ErrorIs(t, err, domain.ErrInvitationExpired)
+		assert.Nil(t, user)
+		mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+	})
+}
+
 func TestLogin(t *testing.T) {
 	mockRepo := new(MockUserRepository)
+	mockTokens := new(MockRefreshTokenRepository)
+	mockSessions := new(MockSessionRepository)
 	mockPwd := new(MockPasswordService)
 	mockJWT := new(MockJWTService)
-	uc := usecases.NewUserUseCases(mockRepo, mockPwd, mockJWT)
+	uc := usecases.NewUserUseCases(mockRepo, mockTokens, mockSessions, nil, mockPwd, mockJWT, nil, nil)
 
 	phone := "1234567890"
 	password := "password123"
@@ -154,10 +398,19 @@ func TestLogin(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		mockRepo.On("FindByPhone", phone).Return(user, nil).Once()
 		mockPwd.On("Compare", password, hashedPassword).Return(true).Once()
-		mockJWT.On("GenerateToken", userID.Hex()).Return("access_token", nil).Once()
-		mockJWT.On("GenerateRefreshToken", userID.Hex()).Return("refresh_token", nil).Once()
+		var session *domain.Session
+		mockSessions.On("Save", mock.MatchedBy(func(s *domain.Session) bool {
+			session = s
+			return s.UserID == userID && s.DeviceName == "Pixel 7" && s.IP == "10.0.0.1"
+		})).Return(nil).Once()
+		mockJWT.On("GenerateToken", userID.Hex(), mock.Anything).Return("access_token", nil).Once()
+		mockJWT.On("GenerateRefreshToken", userID.Hex(), mock.Anything, mock.Anything).Return("refresh_token", nil).Once()
+		mockTokens.On("Save", mock.MatchedBy(func(rt *domain.RefreshToken) bool {
+			// The session ID names the refresh token family
+			return rt.UserID == userID && rt.FamilyID == session.ID && rt.UsedAt == nil
+		})).Return(nil).Once()
 
-		resp, err := uc.Login(phone, password)
+		resp, err := uc.Login(phone, password, domain.SessionClient{DeviceName: "Pixel 7", IP: "10.0.0.1"})
 
 		assert.NoError(t, err)
 		assert.NotNil(t, resp)
@@ -166,12 +419,14 @@ func TestLogin(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 		mockPwd.AssertExpectations(t)
 		mockJWT.AssertExpectations(t)
+		mockTokens.AssertExpectations(t)
+		mockSessions.AssertExpectations(t)
 	})
 
 	t.Run("Invalid Credentials (User Not Found)", func(t *testing.T) {
 		mockRepo.On("FindByPhone", phone).Return(nil, nil).Once()
 
-		resp, err := uc.Login(phone, password)
+		resp, err := uc.Login(phone, password, domain.SessionClient{DeviceName: "Pixel 7", IP: "10.0.0.1"})
 
 		assert.Error(t, err)
 		assert.Nil(t, resp)
@@ -183,7 +438,7 @@ func TestLogin(t *testing.T) {
 		mockRepo.On("FindByPhone", phone).Return(user, nil).Once()
 		mockPwd.On("Compare", password, hashedPassword).Return(false).Once()
 
-		resp, err := uc.Login(phone, password)
+		resp, err := uc.Login(phone, password, domain.SessionClient{DeviceName: "Pixel 7", IP: "10.0.0.1"})
 
 		assert.Error(t, err)
 		assert.Nil(t, resp)
@@ -192,11 +447,203 @@ func TestLogin(t *testing.T) {
 	})
 }
 
+func TestRefreshToken(t *testing.T) {
+	userID := primitive.NewObjectID()
+	user := &domain.User{ID: userID}
+
+	// refreshJWT is what ValidateToken returns for a token pointing at stored
+	refreshJWT := func(stored *domain.RefreshToken) *jwt.Token {
+		return &jwt.Token{Valid: true, Claims: jwt.MapClaims{
+			"user_id": userID.Hex(),
+			"type":    "refresh",
+			"jti":     stored.ID.Hex(),
+			"fid":     stored.FamilyID.Hex(),
+		}}
+	}
+
+	t.Run("Rotates within the family", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockTokens := new(MockRefreshTokenRepository)
+		mockSessions := new(MockSessionRepository)
+		mockJWT := new(MockJWTService)
+		uc := usecases.NewUserUseCases(mockRepo, mockTokens, mockSessions, nil, new(MockPasswordService), mockJWT, nil, nil)
+		stored := domain.NewRefreshToken(userID, primitive.NewObjectID())
+
+		mockJWT.On("ValidateToken", "old_refresh").Return(refreshJWT(stored), nil).Once()
+		mockTokens.On("FindByID", stored.ID.Hex()).Return(stored, nil).Once()
+		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
+		mockTokens.On("MarkUsed", stored.ID, mock.AnythingOfType("primitive.ObjectID"), mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockSessions.On("Rotate", stored.FamilyID, mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockJWT.On("GenerateToken", userID.Hex(), stored.FamilyID.Hex()).Return("access_token", nil).Once()
+		mockJWT.On("GenerateRefreshToken", userID.Hex(), mock.Anything, stored.FamilyID.Hex()).Return("new_refresh", nil).Once()
+		mockTokens.On("Save", mock.MatchedBy(func(rt *domain.RefreshToken) bool {
+			return rt.FamilyID == stored.FamilyID && rt.ID != stored.ID
+		})).Return(nil).Once()
+
+		resp, err := uc.RefreshToken("old_refresh", domain.SessionClient{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "new_refresh", resp.RefreshToken)
+		mockTokens.AssertExpectations(t)
+		mockJWT.AssertExpectations(t)
+	})
+
+	t.Run("Reuse revokes the family", func(t *testing.T) {
+		mockTokens := new(MockRefreshTokenRepository)
+		mockSessions := new(MockSessionRepository)
+		mockJWT := new(MockJWTService)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), mockTokens, mockSessions, nil, new(MockPasswordService), mockJWT, nil, nil)
+		stored := domain.NewRefreshToken(userID, primitive.NewObjectID())
+		usedAt := time.Now().Add(-time.Minute)
+		stored.UsedAt = &usedAt
+
+		mockJWT.On("ValidateToken", "old_refresh").Return(refreshJWT(stored), nil).Once()
+		mockTokens.On("FindByID", stored.ID.Hex()).Return(stored, nil).Once()
+		mockSessions.On("Revoke", userID.Hex(), stored.FamilyID.Hex(), mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockTokens.On("RevokeFamily", stored.FamilyID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		resp, err := uc.RefreshToken("old_refresh", domain.SessionClient{})
+
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+		assert.Nil(t, resp)
+		mockTokens.AssertExpectations(t)
+		mockJWT.AssertNotCalled(t, "GenerateToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoked token", func(t *testing.T) {
+		mockTokens := new(MockRefreshTokenRepository)
+		mockSessions := new(MockSessionRepository)
+		mockJWT := new(MockJWTService)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), mockTokens, mockSessions, nil, new(MockPasswordService), mockJWT, nil, nil)
+		stored := domain.NewRefreshToken(userID, primitive.NewObjectID())
+		revokedAt := time.Now()
+		stored.RevokedAt = &revokedAt
+
+		mockJWT.On("ValidateToken", "old_refresh").Return(refreshJWT(stored), nil).Once()
+		mockTokens.On("FindByID", stored.ID.Hex()).Return(stored, nil).Once()
+
+		_, err := uc.RefreshToken("old_refresh", domain.SessionClient{})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
+		mockTokens.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Logout revokes the family", func(t *testing.T) {
+		mockTokens := new(MockRefreshTokenRepository)
+		mockSessions := new(MockSessionRepository)
+		mockJWT := new(MockJWTService)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), mockTokens, mockSessions, nil, new(MockPasswordService), mockJWT, nil, nil)
+		stored := domain.NewRefreshToken(userID, primitive.NewObjectID())
+
+		mockJWT.On("ValidateToken", "refresh").Return(refreshJWT(stored), nil).Once()
+		mockTokens.On("FindByID", stored.ID.Hex()).Return(stored, nil).Once()
+		// Already ended from the dashboard; logging out still succeeds
+		mockSessions.On("Revoke", userID.Hex(), stored.FamilyID.Hex(), mock.AnythingOfType("time.Time")).Return(domain.ErrSessionNotFound).Once()
+		mockTokens.On("RevokeFamily", stored.FamilyID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		err := uc.Logout("refresh")
+
+		assert.NoError(t, err)
+		mockTokens.AssertExpectations(t)
+		mockSessions.AssertExpectations(t)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	userID := primitive.NewObjectID().Hex()
+	current := &domain.Session{ID: primitive.NewObjectID()}
+	phone := &domain.Session{ID: primitive.NewObjectID()}
+
+	t.Run("Lists and flags the current session", func(t *testing.T) {
+		mockSessions := new(MockSessionRepository)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), new(MockRefreshTokenRepository), mockSessions, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
+
+		mockSessions.On("FindActiveByUser", userID, mock.AnythingOfType("time.Time")).Return([]*domain.Session{phone, current}, nil).Once()
+
+		sessions, err := uc.ListSessions(userID, current.ID.Hex())
+
+		assert.NoError(t, err)
+		assert.False(t, sessions[0].Current)
+		assert.True(t, sessions[1].Current)
+	})
+
+	t.Run("Revoke ends the refresh token family", func(t *testing.T) {
+		mockTokens := new(MockRefreshTokenRepository)
+		mockSessions := new(MockSessionRepository)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), mockTokens, mockSessions, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
+
+		mockSessions.On("Revoke", userID, phone.ID.Hex(), mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockTokens.On("RevokeFamily", phone.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		err := uc.RevokeSession(userID, phone.ID.Hex())
+
+		assert.NoError(t, err)
+		mockTokens.AssertExpectations(t)
+	})
+
+	t.Run("Revoke another user's session", func(t *testing.T) {
+		mockTokens := new(MockRefreshTokenRepository)
+		mockSessions := new(MockSessionRepository)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), mockTokens, mockSessions, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
+
+		mockSessions.On("Revoke", userID, phone.ID.Hex(), mock.AnythingOfType("time.Time")).Return(domain.ErrSessionNotFound).Once()
+
+		err := uc.RevokeSession(userID, phone.ID.Hex())
+
+		assert.ErrorIs(t, err, domain.ErrSessionNotFound)
+		mockTokens.AssertNotCalled(t, "RevokeFamily", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Revoked session is inactive", func(t *testing.T) {
+		mockSessions := new(MockSessionRepository)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), nil, mockSessions, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
+		revokedAt := time.Now()
+		revoked := &domain.Session{ID: primitive.NewObjectID(), ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}
+
+		mockSessions.On("FindByID", revoked.ID.Hex()).Return(revoked, nil).Once()
+
+		active, err := uc.IsSessionActive(revoked.ID.Hex())
+
+		assert.NoError(t, err)
+		assert.False(t, active)
+		mockSessions.AssertNotCalled(t, "Touch", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Active session records its use", func(t *testing.T) {
+		mockSessions := new(MockSessionRepository)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), nil, mockSessions, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
+		session := &domain.Session{ID: primitive.NewObjectID(), LastUsedAt: time.Now().Add(-time.Hour), ExpiresAt: time.Now().Add(time.Hour)}
+
+		mockSessions.On("FindByID", session.ID.Hex()).Return(session, nil).Once()
+		mockSessions.On("Touch", session.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		active, err := uc.IsSessionActive(session.ID.Hex())
+
+		assert.NoError(t, err)
+		assert.True(t, active)
+		mockSessions.AssertExpectations(t)
+	})
+
+	t.Run("Recently used session is not written again", func(t *testing.T) {
+		mockSessions := new(MockSessionRepository)
+		uc := usecases.NewUserUseCases(new(MockUserRepository), nil, mockSessions, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
+		session := &domain.Session{ID: primitive.NewObjectID(), LastUsedAt: time.Now().Add(-time.Second), ExpiresAt: time.Now().Add(time.Hour)}
+
+		mockSessions.On("FindByID", session.ID.Hex()).Return(session, nil).Once()
+
+		active, err := uc.IsSessionActive(session.ID.Hex())
+
+		assert.NoError(t, err)
+		assert.True(t, active)
+		mockSessions.AssertNotCalled(t, "Touch", mock.Anything, mock.Anything)
+	})
+}
+
 func TestGetProfile(t *testing.T) {
 	mockRepo := new(MockUserRepository)
 	mockPwd := new(MockPasswordService)
 	mockJWT := new(MockJWTService)
-	uc := usecases.NewUserUseCases(mockRepo, mockPwd, mockJWT)
+	uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, mockPwd, mockJWT, nil, nil)
 
 	userID := primitive.NewObjectID().Hex()
 	user := &domain.User{Name: "Test User"}
@@ -231,7 +678,7 @@ func TestUpdateProfile(t *testing.T) {
 
 	t.Run("Success", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		uc := usecases.NewUserUseCases(mockRepo, new(MockPasswordService), new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
 		user := &domain.User{ID: userID, Name: "Old Name", Email: "old@example.com"}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
@@ -250,7 +697,7 @@ func TestUpdateProfile(t *testing.T) {
 
 	t.Run("Duplicate Email", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		uc := usecases.NewUserUseCases(mockRepo, new(MockPasswordService), new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
 		user := &domain.User{ID: userID, Name: "Old Name", Email: "old@example.com"}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
@@ -268,7 +715,7 @@ func TestUpdateProfile(t *testing.T) {
 
 	t.Run("User Not Found", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		uc := usecases.NewUserUseCases(mockRepo, new(MockPasswordService), new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
 
 		mockRepo.On("FindById", userID.Hex()).Return(nil, nil).Once()
 
@@ -288,7 +735,7 @@ func TestChangePassword(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		mockPwd := new(MockPasswordService)
-		uc := usecases.NewUserUseCases(mockRepo, mockPwd, new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, mockPwd, new(MockJWTService), nil, nil)
 		user := &domain.User{ID: userID, PasswordHash: hashedPassword}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
@@ -312,7 +759,7 @@ func TestChangePassword(t *testing.T) {
 	t.Run("Wrong Current Password", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		mockPwd := new(MockPasswordService)
-		uc := usecases.NewUserUseCases(mockRepo, mockPwd, new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, mockPwd, new(MockJWTService), nil, nil)
 		user := &domain.User{ID: userID, PasswordHash: hashedPassword}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
@@ -332,7 +779,7 @@ func TestChangePassword(t *testing.T) {
 	t.Run("New Password Too Short", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		mockPwd := new(MockPasswordService)
-		uc := usecases.NewUserUseCases(mockRepo, mockPwd, new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, mockPwd, new(MockJWTService), nil, nil)
 		user := &domain.User{ID: userID, PasswordHash: hashedPassword}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
@@ -351,7 +798,7 @@ func TestChangePassword(t *testing.T) {
 
 	t.Run("User Not Found", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		uc := usecases.NewUserUseCases(mockRepo, new(MockPasswordService), new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
 
 		mockRepo.On("FindById", userID.Hex()).Return(nil, nil).Once()
 
@@ -371,18 +818,19 @@ func TestChangePhone(t *testing.T) {
 	userID := primitive.NewObjectID()
 	hashedPassword := "hashed_password"
 
-	t.Run("Success", func(t *testing.T) {
+	t.Run("Success sends a code to the new phone", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
 		mockPwd := new(MockPasswordService)
-		uc := usecases.NewUserUseCases(mockRepo, mockPwd, new(MockJWTService))
+		mockNotifier := new(MockNotifier)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), mockNotifier, nil)
 		user := &domain.User{ID: userID, Phone: "+2341234567890", PasswordHash: hashedPassword}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
 		mockPwd.On("Compare", "MyPassword1!", hashedPassword).Return(true).Once()
 		mockRepo.On("FindByPhone", "+2349087654321").Return(nil, nil).Once()
-		mockRepo.On("Update", mock.MatchedBy(func(u *domain.User) bool {
-			return u.Phone == "+2349087654321" && !u.UpdatedAt.IsZero()
-		})).Return(nil).Once()
+		mockOTP.On("FindLatest", userID, domain.OTPPurposePhoneChange).Return(nil, nil).Once()
+		expectCodeSent(mockOTP, mockPwd, mockNotifier, domain.OTPPurposePhoneChange, "+2349087654321")
 
 		req := &usecases.ChangePhoneRequest{
 			CurrentPassword: "MyPassword1!",
@@ -390,16 +838,72 @@ func TestChangePhone(t *testing.T) {
 		}
 		result, err := uc.ChangePhone(userID.Hex(), req)
 
+		assert.NoError(t, err)
+		// The phone only changes once the code is confirmed
+		assert.Equal(t, "+2341234567890", result.Phone)
+		mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+		mockNotifier.AssertExpectations(t)
+		mockPwd.AssertExpectations(t)
+	})
+
+	t.Run("Another number right after the first is refused, not silently skipped", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		mockNotifier := new(MockNotifier)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), mockNotifier, nil)
+		user := &domain.User{ID: userID, Phone: "+2341234567890", PasswordHash: hashedPassword}
+		sent := &domain.OneTimeCode{
+			ID: primitive.NewObjectID(), Purpose: domain.OTPPurposePhoneChange, Destination: "+2349087654321",
+			CreatedAt: time.Now().Add(-10 * time.Second), ExpiresAt: time.Now().Add(domain.OTPTTL),
+		}
+
+		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
+		mockPwd.On("Compare", "MyPassword1!", hashedPassword).Return(true).Once()
+		mockRepo.On("FindByPhone", "+2349011112222").Return(nil, nil).Once()
+		mockOTP.On("FindLatest", userID, domain.OTPPurposePhoneChange).Return(sent, nil).Once()
+
+		req := &usecases.ChangePhoneRequest{
+			CurrentPassword: "MyPassword1!",
+			NewPhone:        "+2349011112222",
+		}
+		result, err := uc.ChangePhone(userID.Hex(), req)
+
+		assert.ErrorIs(t, err, domain.ErrOTPResendTooSoon)
+		assert.Nil(t, result)
+		mockOTP.AssertNotCalled(t, "Replace", mock.Anything)
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything)
+	})
+
+	t.Run("Confirm applies the new phone", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), new(MockNotifier), nil)
+		user := &domain.User{ID: userID, Phone: "+2341234567890"}
+		code := &domain.OneTimeCode{ID: primitive.NewObjectID(), Destination: "+2349087654321", CodeHash: "hashed_code", ExpiresAt: time.Now().Add(domain.OTPTTL)}
+
+		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
+		mockOTP.On("FindLatest", userID, domain.OTPPurposePhoneChange).Return(code, nil).Once()
+		mockOTP.On("RecordAttempt", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockPwd.On("Compare", "654321", "hashed_code").Return(true).Once()
+		mockOTP.On("Consume", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockRepo.On("FindByPhone", "+2349087654321").Return(nil, nil).Once()
+		mockRepo.On("Update", mock.MatchedBy(func(u *domain.User) bool {
+			return u.Phone == "+2349087654321" && u.PhoneVerified && u.PhoneVerifiedAt != nil
+		})).Return(nil).Once()
+
+		result, err := uc.ConfirmPhoneChange(userID.Hex(), "654321")
+
 		assert.NoError(t, err)
 		assert.Equal(t, "+2349087654321", result.Phone)
 		mockRepo.AssertExpectations(t)
-		mockPwd.AssertExpectations(t)
 	})
 
 	t.Run("Wrong Current Password", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		mockPwd := new(MockPasswordService)
-		uc := usecases.NewUserUseCases(mockRepo, mockPwd, new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, mockPwd, new(MockJWTService), nil, nil)
 		user := &domain.User{ID: userID, Phone: "+2341234567890", PasswordHash: hashedPassword}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
@@ -420,7 +924,7 @@ func TestChangePhone(t *testing.T) {
 	t.Run("Invalid Phone Format", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		mockPwd := new(MockPasswordService)
-		uc := usecases.NewUserUseCases(mockRepo, mockPwd, new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, mockPwd, new(MockJWTService), nil, nil)
 		user := &domain.User{ID: userID, Phone: "+2341234567890", PasswordHash: hashedPassword}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
@@ -441,7 +945,7 @@ func TestChangePhone(t *testing.T) {
 	t.Run("Duplicate Phone", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		mockPwd := new(MockPasswordService)
-		uc := usecases.NewUserUseCases(mockRepo, mockPwd, new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, mockPwd, new(MockJWTService), nil, nil)
 		user := &domain.User{ID: userID, Phone: "+2341234567890", PasswordHash: hashedPassword}
 
 		mockRepo.On("FindById", userID.Hex()).Return(user, nil).Once()
@@ -463,7 +967,7 @@ func TestChangePhone(t *testing.T) {
 
 	t.Run("User Not Found", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		uc := usecases.NewUserUseCases(mockRepo, new(MockPasswordService), new(MockJWTService))
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, nil, new(MockPasswordService), new(MockJWTService), nil, nil)
 
 		mockRepo.On("FindById", userID.Hex()).Return(nil, nil).Once()
 
@@ -479,3 +983,209 @@ func TestChangePhone(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestForgotPassword(t *testing.T) {
+	user := &domain.User{ID: primitive.NewObjectID(), Phone: "+251911000000", Email: "owner@example.com"}
+
+	t.Run("Sends a code by SMS", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		mockNotifier := new(MockNotifier)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), mockNotifier, nil)
+
+		var sentCode string
+		mockRepo.On("FindByPhone", user.Phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposePasswordReset).Return(nil, nil).Once()
+		mockPwd.On("Hash", mock.MatchedBy(func(code string) bool {
+			sentCode = code
+			return len(code) == domain.OTPLength
+		})).Return("hashed_code", nil).Once()
+		mockOTP.On("Replace", mock.MatchedBy(func(c *domain.OneTimeCode) bool {
+			return c.UserID == user.ID && c.CodeHash == "hashed_code" && c.Attempts == 0
+		})).Return(nil).Once()
+		mockNotifier.On("Send", mock.MatchedBy(func(n domain.Notification) bool {
+			return n.Channel == domain.NotificationSMS && n.To == user.Phone && strings.Contains(n.Body, sentCode)
+		})).Return(nil).Once()
+
+		err := uc.ForgotPassword(&usecases.ForgotPasswordRequest{Phone: user.Phone})
+
+		assert.NoError(t, err)
+		mockOTP.AssertExpectations(t)
+		mockNotifier.AssertExpectations(t)
+	})
+
+	t.Run("A new code keeps the guesses already made", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		mockNotifier := new(MockNotifier)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), mockNotifier, nil)
+		earlier := &domain.OneTimeCode{ID: primitive.NewObjectID(), Attempts: 3, CreatedAt: time.Now().Add(-2 * domain.OTPResendInterval), ExpiresAt: time.Now().Add(time.Minute)}
+
+		mockRepo.On("FindByPhone", user.Phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposePasswordReset).Return(earlier, nil).Once()
+		mockPwd.On("Hash", mock.Anything).Return("hashed_code", nil).Once()
+		mockOTP.On("Replace", mock.MatchedBy(func(c *domain.OneTimeCode) bool {
+			return c.ID == earlier.ID && c.Attempts == 3
+		})).Return(nil).Once()
+		mockNotifier.On("Send", mock.Anything).Return(nil).Once()
+
+		err := uc.ForgotPassword(&usecases.ForgotPasswordRequest{Phone: user.Phone})
+
+		assert.NoError(t, err)
+		mockOTP.AssertExpectations(t)
+	})
+
+	t.Run("A burned code is not replaced before it expires", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockNotifier := new(MockNotifier)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, new(MockPasswordService), new(MockJWTService), mockNotifier, nil)
+		burned := &domain.OneTimeCode{ID: primitive.NewObjectID(), Attempts: domain.OTPMaxAttempts, CreatedAt: time.Now().Add(-5 * time.Minute), ExpiresAt: time.Now().Add(5 * time.Minute)}
+
+		mockRepo.On("FindByPhone", user.Phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposePasswordReset).Return(burned, nil).Once()
+
+		err := uc.ForgotPassword(&usecases.ForgotPasswordRequest{Phone: user.Phone})
+
+		assert.NoError(t, err)
+		mockOTP.AssertNotCalled(t, "Replace", mock.Anything)
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything)
+	})
+
+	t.Run("Unknown account looks the same", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockNotifier := new(MockNotifier)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, new(MockOTPRepository), new(MockPasswordService), new(MockJWTService), mockNotifier, nil)
+
+		mockRepo.On("FindByEmail", "nobody@example.com").Return(nil, nil).Once()
+
+		err := uc.ForgotPassword(&usecases.ForgotPasswordRequest{Email: "nobody@example.com"})
+
+		assert.NoError(t, err)
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	req := &usecases.ResetPasswordRequest{Phone: "+251911000000", Code: "123456", NewPassword: "NewPassword1!"}
+	pending := func() *domain.OneTimeCode {
+		return &domain.OneTimeCode{ID: primitive.NewObjectID(), CodeHash: "hashed_code", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(domain.OTPTTL)}
+	}
+
+	t.Run("Success signs out everywhere", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockTokens := new(MockRefreshTokenRepository)
+		mockSessions := new(MockSessionRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		uc := usecases.NewUserUseCases(mockRepo, mockTokens, mockSessions, mockOTP, mockPwd, new(MockJWTService), new(MockNotifier), nil)
+		user := &domain.User{ID: primitive.NewObjectID(), Phone: req.Phone, PasswordHash: "old_hash"}
+		code := pending()
+
+		mockRepo.On("FindByPhone", req.Phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposePasswordReset).Return(code, nil).Once()
+		mockOTP.On("RecordAttempt", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockPwd.On("Compare", req.Code, "hashed_code").Return(true).Once()
+		mockOTP.On("Consume", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockPwd.On("Hash", req.NewPassword).Return("new_hash", nil).Once()
+		mockRepo.On("Update", mock.MatchedBy(func(u *domain.User) bool { return u.PasswordHash == "new_hash" })).Return(nil).Once()
+		mockSessions.On("RevokeAllForUser", user.ID.Hex(), mock.AnythingOfType("time.Time")).Return(int64(2), nil).Once()
+		mockTokens.On("RevokeAllForUser", user.ID.Hex(), mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		err := uc.ResetPassword(req)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+		mockOTP.AssertExpectations(t)
+		mockSessions.AssertExpectations(t)
+	})
+
+	t.Run("Wrong code", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), new(MockNotifier), nil)
+		user := &domain.User{ID: primitive.NewObjectID(), Phone: req.Phone}
+		code := pending()
+
+		mockRepo.On("FindByPhone", req.Phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposePasswordReset).Return(code, nil).Once()
+		mockOTP.On("RecordAttempt", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockPwd.On("Compare", req.Code, "hashed_code").Return(false).Once()
+
+		err := uc.ResetPassword(req)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidOTP)
+		mockOTP.AssertNotCalled(t, "Consume", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+	})
+
+	t.Run("Attempts used up", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, mockPwd, new(MockJWTService), new(MockNotifier), nil)
+		user := &domain.User{ID: primitive.NewObjectID(), Phone: req.Phone}
+		code := pending()
+		code.Attempts = domain.OTPMaxAttempts
+
+		mockRepo.On("FindByPhone", req.Phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposePasswordReset).Return(code, nil).Once()
+
+		err := uc.ResetPassword(req)
+
+		assert.ErrorIs(t, err, domain.ErrOTPAttemptsExceeded)
+		mockPwd.AssertNotCalled(t, "Compare", mock.Anything, mock.Anything)
+	})
+}
+
+func TestLoginWithOTP(t *testing.T) {
+	phone := "+251911000000"
+
+	t.Run("Verified phone signs in", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockTokens := new(MockRefreshTokenRepository)
+		mockSessions := new(MockSessionRepository)
+		mockOTP := new(MockOTPRepository)
+		mockPwd := new(MockPasswordService)
+		mockJWT := new(MockJWTService)
+		uc := usecases.NewUserUseCases(mockRepo, mockTokens, mockSessions, mockOTP, mockPwd, mockJWT, new(MockNotifier), nil)
+		user := &domain.User{ID: primitive.NewObjectID(), Phone: phone, PhoneVerified: true}
+		code := &domain.OneTimeCode{ID: primitive.NewObjectID(), CodeHash: "hashed_code", ExpiresAt: time.Now().Add(domain.OTPTTL)}
+
+		mockRepo.On("FindByPhone", phone).Return(user, nil).Once()
+		mockOTP.On("FindLatest", user.ID, domain.OTPPurposeLogin).Return(code, nil).Once()
+		mockOTP.On("RecordAttempt", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockPwd.On("Compare", "123456", "hashed_code").Return(true).Once()
+		mockOTP.On("Consume", code.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		mockSessions.On("Save", mock.AnythingOfType("*domain.Session")).Return(nil).Once()
+		mockJWT.On("GenerateToken", user.ID.Hex(), mock.Anything).Return("access_token", nil).Once()
+		mockJWT.On("GenerateRefreshToken", user.ID.Hex(), mock.Anything, mock.Anything).Return("refresh_token", nil).Once()
+		mockTokens.On("Save", mock.AnythingOfType("*domain.RefreshToken")).Return(nil).Once()
+
+		resp, err := uc.LoginWithOTP(phone, "123456", domain.SessionClient{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "access_token", resp.Token)
+		mockOTP.AssertExpectations(t)
+	})
+
+	t.Run("Unverified phone gets no code", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockOTP := new(MockOTPRepository)
+		mockNotifier := new(MockNotifier)
+		uc := usecases.NewUserUseCases(mockRepo, nil, nil, mockOTP, new(MockPasswordService), new(MockJWTService), mockNotifier, nil)
+		user := &domain.User{ID: primitive.NewObjectID(), Phone: phone}
+
+		mockRepo.On("FindByPhone", phone).Return(user, nil).Twice()
+
+		assert.NoError(t, uc.RequestLoginCode(phone))
+		_, err := uc.LoginWithOTP(phone, "123456", domain.SessionClient{})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidOTP)
+		mockNotifier.AssertNotCalled(t, "Send", mock.Anything)
+		mockOTP.AssertNotCalled(t, "FindLatest", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,140 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// apiKeyHintLength is how much of a key stays visible in key lists.
+const apiKeyHintLength = len(domain.APIKeyPrefix) + 6
+
+type APIKeyUseCases interface {
+	CreateAPIKey(businessId string, actorId string, req *domain.CreateAPIKeyRequest) (*domain.APIKey, error)
+	ListAPIKeys(businessId string) ([]*domain.APIKey, error)
+	RevokeAPIKey(businessId string, keyId string) error
+	AuthenticateAPIKey(key string) (*domain.APIKey, error)
+}
+
+type apiKeyUseCases struct {
+	apiKeyRepo repositories.APIKeyRepository
+}
+
+func NewAPIKeyUseCases(apiKeyRepo repositories.APIKeyRepository) APIKeyUseCases {
+	return &apiKeyUseCases{apiKeyRepo: apiKeyRepo}
+}
+
+// CreateAPIKey issues a key for the business. The plain key is only returned
+// here.
+func (a *apiKeyUseCases) CreateAPIKey(businessId string, actorId string, req *domain.CreateAPIKeyRequest) (*domain.APIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > domain.MaxAPIKeyNameLength {
+		return nil, domain.ErrAPIKeyNameRequired
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	businessID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, domain.ErrBusinessNotFound
+	}
+	actorID, _ := primitive.ObjectIDFromHex(actorId)
+
+	plain, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &domain.APIKey{
+		ID:         primitive.NewObjectID(),
+		BusinessID: businessID,
+		Name:       name,
+		Hint:       plain[:apiKeyHintLength],
+		KeyHash:    hashAPIKey(plain),
+		Scopes:     scopes,
+		CreatedBy:  actorID,
+		CreatedAt:  time.Now(),
+	}
+	if err := a.apiKeyRepo.Save(key); err != nil {
+		return nil, err
+	}
+
+	key.Key = plain
+	return key, nil
+}
+
+// ListAPIKeys returns every key of the business, revoked ones included,
+// newest first.
+func (a *apiKeyUseCases) ListAPIKeys(businessId string) ([]*domain.APIKey, error) {
+	return a.apiKeyRepo.FindByBusiness(businessId)
+}
+
+func (a *apiKeyUseCases) RevokeAPIKey(businessId string, keyId string) error {
+	return a.apiKeyRepo.Revoke(businessId, keyId, time.Now())
+}
+
+// AuthenticateAPIKey finds the live key matching the presented one and
+// records its use.
+func (a *apiKeyUseCases) AuthenticateAPIKey(plain string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(plain, domain.APIKeyPrefix) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	key, err := a.apiKeyRepo.FindByHash(hashAPIKey(plain))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.RevokedAt != nil {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= domain.APIKeyTouchInterval {
+		if err := a.apiKeyRepo.Touch(key.ID, now); err != nil {
+			fmt.Printf("WARNING: failed to record use of api key %s: %v\n", key.ID.Hex(), err)
+		}
+	}
+	return key, nil
+}
+
+// normalizeScopes checks the requested scopes and drops duplicates.
+func normalizeScopes(requested []domain.APIKeyScope) ([]domain.APIKeyScope, error) {
+	if len(requested) == 0 {
+		return nil, domain.ErrInvalidAPIKeyScopes
+	}
+	seen := make(map[domain.APIKeyScope]bool, len(requested))
+	scopes := make([]domain.APIKeyScope, 0, len(requested))
+	for _, scope := range requested {
+		if !domain.IsValidAPIKeyScope(scope) {
+			return nil, domain.ErrInvalidAPIKeyScopes
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func newAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return domain.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashAPIKey needs no salt or slow hash: keys are 256 random bits, so the
+// hash cannot be brute-forced, and a plain digest can be looked up directly.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditRecorder appends entries to the audit log. A failed write is reported
+// but does not undo the change being audited.
+type AuditRecorder interface {
+	Record(entry *domain.AuditEntry)
+}
+
+type AuditUseCases interface {
+	AuditRecorder
+	ListEntries(query domain.AuditQuery) (*domain.AuditList, error)
+}
+
+type auditUseCases struct {
+	auditRepo repositories.AuditRepository
+}
+
+func NewAuditUseCases(auditRepo repositories.AuditRepository) AuditUseCases {
+	return &auditUseCases{auditRepo: auditRepo}
+}
+
+func (a *auditUseCases) Record(entry *domain.AuditEntry) {
+	if err := a.auditRepo.Append(entry); err != nil {
+		fmt.Printf("WARNING: failed to write audit entry for %s %s %s: %v\n", entry.Action, entry.EntityType, entry.EntityID, err)
+	}
+}
+
+// ListEntries returns a page of the business's audit log, newest first.
+func (a *auditUseCases) ListEntries(query domain.AuditQuery) (*domain.AuditList, error) {
+	if _, err := primitive.ObjectIDFromHex(query.BusinessID); err != nil {
+		return nil, errors.New("invalid business_id")
+	}
+	if query.From != nil && query.To != nil && query.From.After(*query.To) {
+		return nil, domain.ErrInvalidAuditRange
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = domain.DefaultAuditPageSize
+	}
+	if query.Limit > domain.MaxAuditPageSize {
+		query.Limit = domain.MaxAuditPageSize
+	}
+
+	entries, total, err := a.auditRepo.Find(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AuditList{
+		Entries: entries,
+		Pagination: domain.PaginationMetadata{
+			Page:       query.Page,
+			Limit:      query.Limit,
+			Total:      int(total),
+			TotalPages: (int(total) + query.Limit - 1) / query.Limit,
+		},
+	}, nil
+}
+
+// recordAudit hands entry to recorder when auditing is configured.
+func recordAudit(recorder AuditRecorder, entry *domain.AuditEntry) {
+	if recorder != nil {
+		recorder.Record(entry)
+	}
+}
@@ -2,6 +2,7 @@ package usecases
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	domain "shop-ops/Domain"
@@ -18,9 +19,19 @@ type CreateBusinessRequest struct {
 }
 
 type UpdateBusinessRequest struct {
+	Name          string               `json:"name"`
+	Currency      string               `json:"currency"`
+	Language      string               `json:"language"`
+	CostingMethod domain.CostingMethod `json:"costing_method"`
+}
+
+type RegisterDeviceRequest struct {
+	DeviceID string `json:"device_id"`
 	Name     string `json:"name"`
-	Currency string `json:"currency"`
-	Language string `json:"language"`
+}
+
+type UpdateSyncPolicyRequest struct {
+	Policy domain.SyncConflictPolicy `json:"policy"`
 }
 
 type BusinessUseCases interface {
@@ -28,6 +39,10 @@ type BusinessUseCases interface {
 	GetByUserId(userId string) ([]*domain.Business, error)
 	GetById(businessId string) (*domain.Business, error)
 	Update(businessId string, userId string, req *UpdateBusinessRequest) (*domain.Business, error)
+	RegisterDevice(businessId string, userId string, req *RegisterDeviceRequest) (*domain.SyncDevice, error)
+	ListDevices(businessId string, userId string) ([]domain.SyncDevice, error)
+	RevokeDevice(businessId string, userId string, deviceId string) error
+	UpdateSyncPolicy(businessId string, userId string, req *UpdateSyncPolicyRequest) (*domain.Business, error)
 }
 
 type businessUseCases struct {
@@ -102,6 +117,12 @@ func (b *businessUseCases) Update(businessId string, userId string, req *UpdateB
 	if req.Language != "" {
 		business.Language = req.Language
 	}
+	if req.CostingMethod != "" {
+		if !domain.IsValidCostingMethod(req.CostingMethod) {
+			return nil, domain.ErrInvalidCostingMethod
+		}
+		business.CostingMethod = req.CostingMethod
+	}
 	business.UpdatedAt = time.Now()
 
 	if err := b.businessRepo.Update(business); err != nil {
@@ -110,3 +131,123 @@ func (b *businessUseCases) Update(businessId string, userId string, req *UpdateB
 
 	return business, nil
 }
+
+func (b *businessUseCases) RegisterDevice(businessId string, userId string, req *RegisterDeviceRequest) (*domain.SyncDevice, error) {
+	business, err := b.findOwnedBusiness(businessId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceID := strings.TrimSpace(req.DeviceID)
+	if deviceID == "" {
+		return nil, errors.New("device_id is required")
+	}
+
+	now := time.Now()
+	if err := b.adoptLegacyDevice(business, now); err != nil {
+		return nil, err
+	}
+	device := business.FindDevice(deviceID)
+	if device != nil && device.IsActive() {
+		return nil, domain.ErrDeviceRegistered
+	}
+
+	registered := domain.SyncDevice{
+		DeviceID:     deviceID,
+		Name:         req.Name,
+		Status:       domain.DeviceStatusActive,
+		RegisteredBy: business.UserID,
+		RegisteredAt: now,
+	}
+	if device != nil {
+		// Re-registering a revoked device reactivates it
+		if registered.Name == "" {
+			registered.Name = device.Name
+		}
+		err = b.businessRepo.ReactivateDevice(business.ID, registered)
+	} else {
+		err = b.businessRepo.AddDevice(business.ID, registered)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &registered, nil
+}
+
+func (b *businessUseCases) ListDevices(businessId string, userId string) ([]domain.SyncDevice, error) {
+	business, err := b.findOwnedBusiness(businessId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.adoptLegacyDevice(business, time.Now()); err != nil {
+		return nil, err
+	}
+	if business.Devices == nil {
+		return []domain.SyncDevice{}, nil
+	}
+	return business.Devices, nil
+}
+
+func (b *businessUseCases) RevokeDevice(businessId string, userId string, deviceId string) error {
+	business, err := b.findOwnedBusiness(businessId, userId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := b.adoptLegacyDevice(business, now); err != nil {
+		return err
+	}
+	device := business.FindDevice(deviceId)
+	if device == nil {
+		return domain.ErrDeviceNotFound
+	}
+	if !device.IsActive() {
+		return nil
+	}
+
+	return b.businessRepo.RevokeDevice(business.ID, deviceId, now)
+}
+
+func (b *businessUseCases) UpdateSyncPolicy(businessId string, userId string, req *UpdateSyncPolicyRequest) (*domain.Business, error) {
+	business, err := b.findOwnedBusiness(businessId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.IsValidSyncConflictPolicy(req.Policy) {
+		return nil, domain.ErrInvalidSyncPolicy
+	}
+
+	business.SyncPolicy = req.Policy
+	business.UpdatedAt = time.Now()
+
+	if err := b.businessRepo.UpdateSyncPolicy(business.ID, business.SyncPolicy, business.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return business, nil
+}
+
+// adoptLegacyDevice moves the device a business synced with before the
+// registry existed into the registry, so every registry change sees it.
+func (b *businessUseCases) adoptLegacyDevice(business *domain.Business, now time.Time) error {
+	if !business.AdoptLegacyDevice(now) {
+		return nil
+	}
+	return b.businessRepo.AdoptLegacyDevice(business.ID, business.Devices[0])
+}
+
+// findOwnedBusiness loads a business and verifies the caller owns it
+func (b *businessUseCases) findOwnedBusiness(businessId string, userId string) (*domain.Business, error) {
+	business, err := b.businessRepo.FindByID(businessId)
+	if err != nil {
+		return nil, err
+	}
+	if business == nil {
+		return nil, errors.New("business not found")
+	}
+	if business.UserID.Hex() != userId {
+		return nil, errors.New("unauthorized")
+	}
+	return business, nil
+}
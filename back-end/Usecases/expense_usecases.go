@@ -66,17 +66,19 @@ type ExpenseFilter struct {
 // ExpenseUseCases conatins the business logic for expenses
 type ExpenseUseCases struct {
 	expenseRepo repositories.ExpenseRepository
+	audit       AuditRecorder
 }
 
 // NewExpenseUseCases creates a new ExpenseUseCases instance
-func NewExpenseUseCases(expenseRepo repositories.ExpenseRepository) *ExpenseUseCases {
+func NewExpenseUseCases(expenseRepo repositories.ExpenseRepository, audit AuditRecorder) *ExpenseUseCases {
 	return &ExpenseUseCases{
 		expenseRepo: expenseRepo,
+		audit:       audit,
 	}
 }
 
 // RecordExpense records a new expense
-func (uc *ExpenseUseCases) RecordExpense(req RecordExpenseRequest) (*domain.Expense, error) {
+func (uc *ExpenseUseCases) RecordExpense(actor domain.AuditActor, req RecordExpenseRequest) (*domain.Expense, error) {
 	//validate category
 	if !domain.IsValidExpenseCategory(string(req.Category)) {
 		return nil, domain.ErrInvalidCategory
@@ -100,6 +102,8 @@ func (uc *ExpenseUseCases) RecordExpense(req RecordExpenseRequest) (*domain.Expe
 	if err := uc.expenseRepo.Create(ctx, expense); err != nil {
 		return nil, err
 	}
+
+	recordAudit(uc.audit, domain.NewAuditEntry(actor, expense.BusinessID, domain.AuditEntityExpense, expense.ID.Hex(), domain.AuditActionCreate, nil, expense))
 	return expense, nil
 }
 
@@ -170,7 +174,7 @@ func (uc *ExpenseUseCases) GetExpenseById(expenseId primitive.ObjectID) (*domain
 }
 
 // UpdateExpense updates an existing expense that hasn't been voided or synced
-func (uc *ExpenseUseCases) UpdateExpense(expenseId primitive.ObjectID, req UpdateExpenseRequest) (*domain.Expense, error) {
+func (uc *ExpenseUseCases) UpdateExpense(expenseId primitive.ObjectID, actor domain.AuditActor, req UpdateExpenseRequest) (*domain.Expense, error) {
 	ctx := context.Background()
 
 	expense, err := uc.expenseRepo.GetByID(ctx, expenseId)
@@ -181,6 +185,7 @@ func (uc *ExpenseUseCases) UpdateExpense(expenseId primitive.ObjectID, req Updat
 	if expense.IsVoided {
 		return nil, domain.ErrCannotUpdateVoided
 	}
+	before := *expense
 
 	// Update individual fields if provided
 	if req.Category != nil {
@@ -205,11 +210,12 @@ func (uc *ExpenseUseCases) UpdateExpense(expenseId primitive.ObjectID, req Updat
 		return nil, err
 	}
 
+	recordAudit(uc.audit, domain.NewAuditEntry(actor, expense.BusinessID, domain.AuditEntityExpense, expenseId.Hex(), domain.AuditActionUpdate, &before, expense))
 	return expense, nil
 }
 
 // VoidExpense voids an expense (soft delete)
-func (uc *ExpenseUseCases) VoidExpense(expenseId primitive.ObjectID) error {
+func (uc *ExpenseUseCases) VoidExpense(expenseId primitive.ObjectID, actor domain.AuditActor) error {
 	ctx := context.Background()
 
 	expense, err := uc.expenseRepo.GetByID(ctx, expenseId)
@@ -221,7 +227,14 @@ func (uc *ExpenseUseCases) VoidExpense(expenseId primitive.ObjectID) error {
 		return nil
 	}
 
-	return uc.expenseRepo.Void(ctx, expenseId)
+	if err := uc.expenseRepo.Void(ctx, expenseId); err != nil {
+		return err
+	}
+
+	voided := *expense
+	voided.IsVoided = true
+	recordAudit(uc.audit, domain.NewAuditEntry(actor, expense.BusinessID, domain.AuditEntityExpense, expenseId.Hex(), domain.AuditActionVoid, expense, &voided))
+	return nil
 }
 
 // GetExpensesByCategory retrieves expense summary by category
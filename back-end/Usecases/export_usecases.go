@@ -3,15 +3,21 @@ package usecases
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	Domain "shop-ops/Domain"
 	Infrastructure "shop-ops/Infrastructure"
 	Repositories "shop-ops/Repositories"
 
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// exportDownloadPrefix is the path a completed export's file is served under.
+// It has no leading slash so clients appending to base_url don't get //download
+const exportDownloadPrefix = "download/"
+
 type ExportUsecasesImpl struct {
 	exportRepo      Domain.ExportRepository
 	exportService   *Infrastructure.ExportService
@@ -107,6 +113,19 @@ func (uc *ExportUsecasesImpl) GetExportStatus(id, businessID string) (*Domain.Ex
 	return uc.exportRepo.GetByID(id, businessID)
 }
 
+// GetExportByFilename finds the completed export a download link points at,
+// so its business can be checked before the file is served.
+func (uc *ExportUsecasesImpl) GetExportByFilename(filename string) (*Domain.ExportRequest, error) {
+	if filename == "" || filename != filepath.Base(filename) {
+		return nil, nil
+	}
+	request, err := uc.exportRepo.GetByFileURL(exportDownloadPrefix + filename)
+	if err != nil || request == nil || request.Status != Domain.ExportStatusCompleted {
+		return nil, err
+	}
+	return request, nil
+}
+
 func (uc *ExportUsecasesImpl) GetExportHistory(businessID string, page, limit int) ([]Domain.ExportRequest, int64, error) {
 	if page < 1 {
 		page = 1
@@ -269,6 +288,9 @@ func (uc *ExportUsecasesImpl) generateExport(req *Domain.ExportRequest) {
 		totalExpenses, _ := totalExpensesDecimal.Float64()
 		summary := &Domain.ProfitSummaryResponse{
 			TotalSales:    salesSummary.TotalRevenue,
+			CostOfGoods:   salesSummary.CostOfGoods,
+			GrossProfit:   salesSummary.TotalRevenue - salesSummary.CostOfGoods,
+			GrossMargin:   Domain.GrossMargin(decimal.NewFromFloat(salesSummary.TotalRevenue), decimal.NewFromFloat(salesSummary.CostOfGoods)).InexactFloat64(),
 			TotalExpenses: totalExpenses,
 			NetProfit:     salesSummary.TotalRevenue - totalExpenses,
 			Period:        fmt.Sprintf("%s to %s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02")),
@@ -280,7 +302,6 @@ func (uc *ExportUsecasesImpl) generateExport(req *Domain.ExportRequest) {
 	if err != nil {
 		uc.exportRepo.UpdateStatus(req.ID, Domain.ExportStatusFailed, "", err.Error())
 	} else {
-		// Return without leading slash so clients appending to base_url don't get //download
-		uc.exportRepo.UpdateStatus(req.ID, Domain.ExportStatusCompleted, "download/"+fileURL, "")
+		uc.exportRepo.UpdateStatus(req.ID, Domain.ExportStatusCompleted, exportDownloadPrefix+fileURL, "")
 	}
 }
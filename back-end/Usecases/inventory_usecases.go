@@ -10,32 +10,37 @@ import (
 )
 
 type InventoryUseCase interface {
-	CreateProduct(businessID, userID string, req Domain.CreateProductRequest) (*Domain.ProductResponse, error)
+	CreateProduct(businessID string, actor Domain.AuditActor, req Domain.CreateProductRequest) (*Domain.ProductResponse, error)
 	GetProductByID(id, businessID string) (*Domain.ProductResponse, error)
 	GetProducts(businessID string, query Domain.ProductListQuery) (*Domain.ProductListResponse, error)
-	UpdateProduct(id, businessID, userID string, req Domain.UpdateProductRequest) (*Domain.ProductResponse, error)
-	DeleteProduct(id, businessID, userID string) error
-	AdjustStock(id, businessID, userID string, req Domain.AdjustStockRequest) error
+	UpdateProduct(id, businessID string, actor Domain.AuditActor, req Domain.UpdateProductRequest) (*Domain.ProductResponse, error)
+	DeleteProduct(id, businessID string, actor Domain.AuditActor) error
+	AdjustStock(id, businessID string, actor Domain.AuditActor, req Domain.AdjustStockRequest) error
 	GetLowStock(businessID string) ([]Domain.ProductResponse, error)
 	GetStockHistory(productID, businessID string, limit int) ([]Domain.StockMovementResponse, error)
+	CreateVariant(parentID, businessID string, actor Domain.AuditActor, req Domain.CreateVariantRequest) (*Domain.ProductResponse, error)
+	GetCategories(businessID string) ([]Domain.ProductCategory, error)
 }
 
 type inventoryUseCase struct {
 	inventoryRepo Domain.ProductRepository
 	businessRepo  Domain.BusinessRepository
+	audit         AuditRecorder
 }
 
 func NewInventoryUseCase(
 	inventoryRepo Domain.ProductRepository,
 	businessRepo Domain.BusinessRepository,
+	audit AuditRecorder,
 ) InventoryUseCase {
 	return &inventoryUseCase{
 		inventoryRepo: inventoryRepo,
 		businessRepo:  businessRepo,
+		audit:         audit,
 	}
 }
 
-func (uc *inventoryUseCase) CreateProduct(businessID, userID string, req Domain.CreateProductRequest) (*Domain.ProductResponse, error) {
+func (uc *inventoryUseCase) CreateProduct(businessID string, actor Domain.AuditActor, req Domain.CreateProductRequest) (*Domain.ProductResponse, error) {
 	// Validate business exists
 	business, err := uc.businessRepo.FindByID(businessID)
 	if err != nil {
@@ -50,7 +55,7 @@ func (uc *inventoryUseCase) CreateProduct(businessID, userID string, req Domain.
 		return nil, fmt.Errorf("invalid business ID: %w", err)
 	}
 
-	_, err = primitive.ObjectIDFromHex(userID)
+	_, err = primitive.ObjectIDFromHex(actor.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid user ID: %w", err)
 	}
@@ -58,19 +63,50 @@ func (uc *inventoryUseCase) CreateProduct(businessID, userID string, req Domain.
 	product := &Domain.Product{
 		BusinessID:          objBusinessID,
 		Name:                req.Name,
+		SKU:                 req.SKU,
+		Barcode:             req.Barcode,
+		Category:            req.Category,
+		UnitOfMeasure:       req.Unit,
 		DefaultSellingPrice: decimal.NewFromFloat(req.DefaultSellingPrice),
-		StockQuantity:       req.StockQuantity,
-		LowStockThreshold:   req.LowStockThreshold,
+		UnitCost:            decimal.NewFromFloat(req.UnitCost),
+		StockQuantity:       decimal.NewFromFloat(req.StockQuantity),
+		LowStockThreshold:   decimal.NewFromFloat(req.LowStockThreshold),
+	}
+	if err := product.NormalizeCatalog(); err != nil {
+		return nil, err
 	}
 
 	if err := uc.inventoryRepo.Create(product); err != nil {
 		return nil, fmt.Errorf("failed to create product: %w", err)
 	}
 
+	recordAudit(uc.audit, Domain.NewAuditEntry(actor, product.BusinessID, Domain.AuditEntityProduct, product.ID.Hex(), Domain.AuditActionCreate, nil, product))
+
 	return uc.toProductResponse(product), nil
 }
 
 func (uc *inventoryUseCase) GetProductByID(id, businessID string) (*Domain.ProductResponse, error) {
+	product, err := uc.findProduct(id, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := uc.toProductResponse(product)
+	if !product.IsVariant() {
+		variants, err := uc.inventoryRepo.FindVariants(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get variants: %w", err)
+		}
+		for i := range variants {
+			response.Variants = append(response.Variants, *uc.toProductResponse(&variants[i]))
+		}
+	}
+
+	return response, nil
+}
+
+// findProduct loads a product and checks it belongs to the business
+func (uc *inventoryUseCase) findProduct(id, businessID string) (*Domain.Product, error) {
 	product, err := uc.inventoryRepo.FindByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find product: %w", err)
@@ -84,7 +120,7 @@ func (uc *inventoryUseCase) GetProductByID(id, businessID string) (*Domain.Produ
 		return nil, fmt.Errorf("access denied: product does not belong to this business")
 	}
 
-	return uc.toProductResponse(product), nil
+	return product, nil
 }
 
 func (uc *inventoryUseCase) GetProducts(businessID string, query Domain.ProductListQuery) (*Domain.ProductListResponse, error) {
@@ -121,8 +157,8 @@ func (uc *inventoryUseCase) GetProducts(businessID string, query Domain.ProductL
 	return response, nil
 }
 
-func (uc *inventoryUseCase) UpdateProduct(id, businessID, userID string, req Domain.UpdateProductRequest) (*Domain.ProductResponse, error) {
-	_, err := uc.GetProductByID(id, businessID)
+func (uc *inventoryUseCase) UpdateProduct(id, businessID string, actor Domain.AuditActor, req Domain.UpdateProductRequest) (*Domain.ProductResponse, error) {
+	_, err := uc.findProduct(id, businessID)
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +169,8 @@ func (uc *inventoryUseCase) UpdateProduct(id, businessID, userID string, req Dom
 		return nil, err
 	}
 
+	before := *fullProduct
+
 	// Update fields
 	if req.Name != nil {
 		fullProduct.Name = *req.Name
@@ -141,29 +179,67 @@ func (uc *inventoryUseCase) UpdateProduct(id, businessID, userID string, req Dom
 		fullProduct.DefaultSellingPrice = decimal.NewFromFloat(*req.DefaultSellingPrice)
 	}
 	if req.LowStockThreshold != nil {
-		fullProduct.LowStockThreshold = *req.LowStockThreshold
+		fullProduct.LowStockThreshold = decimal.NewFromFloat(*req.LowStockThreshold)
+	}
+	if req.SKU != nil {
+		fullProduct.SKU = *req.SKU
+	}
+	if req.Barcode != nil {
+		fullProduct.Barcode = *req.Barcode
+	}
+	if req.Category != nil {
+		fullProduct.Category = *req.Category
+	}
+	if req.Unit != nil {
+		fullProduct.UnitOfMeasure = *req.Unit
+	}
+	if err := fullProduct.NormalizeCatalog(); err != nil {
+		return nil, err
 	}
 
 	if err := uc.inventoryRepo.Update(fullProduct); err != nil {
 		return nil, fmt.Errorf("failed to update product: %w", err)
 	}
 
+	recordAudit(uc.audit, Domain.NewAuditEntry(actor, fullProduct.BusinessID, Domain.AuditEntityProduct, id, Domain.AuditActionUpdate, &before, fullProduct))
+
 	return uc.toProductResponse(fullProduct), nil
 }
 
-func (uc *inventoryUseCase) DeleteProduct(id, businessID, userID string) error {
+func (uc *inventoryUseCase) DeleteProduct(id, businessID string, actor Domain.AuditActor) error {
 	// Verify product exists and belongs to business
-	_, err := uc.GetProductByID(id, businessID)
+	_, err := uc.findProduct(id, businessID)
+	if err != nil {
+		return err
+	}
+
+	product, err := uc.inventoryRepo.FindByID(id)
 	if err != nil {
 		return err
 	}
 
-	return uc.inventoryRepo.Delete(id)
+	// Variants would be left without their product
+	if !product.IsVariant() {
+		variants, err := uc.inventoryRepo.FindVariants(id)
+		if err != nil {
+			return fmt.Errorf("failed to get variants: %w", err)
+		}
+		if len(variants) > 0 {
+			return Domain.ErrProductHasVariants
+		}
+	}
+
+	if err := uc.inventoryRepo.Delete(id); err != nil {
+		return err
+	}
+
+	recordAudit(uc.audit, Domain.NewAuditEntry(actor, product.BusinessID, Domain.AuditEntityProduct, id, Domain.AuditActionDelete, product, nil))
+	return nil
 }
 
-func (uc *inventoryUseCase) AdjustStock(id, businessID, userID string, req Domain.AdjustStockRequest) error {
+func (uc *inventoryUseCase) AdjustStock(id, businessID string, actor Domain.AuditActor, req Domain.AdjustStockRequest) error {
 	// Verify product exists and belongs to business
-	_, err := uc.GetProductByID(id, businessID)
+	_, err := uc.findProduct(id, businessID)
 	if err != nil {
 		return err
 	}
@@ -183,19 +259,46 @@ func (uc *inventoryUseCase) AdjustStock(id, businessID, userID string, req Domai
 		return fmt.Errorf("quantity must be greater than 0")
 	}
 
+	// Only stock coming in has a purchase cost
+	if req.UnitCost != nil && req.Type != Domain.MovementTypePurchase && req.Type != Domain.MovementTypeReturn {
+		return fmt.Errorf("unit_cost only applies to purchase and return movements")
+	}
+
+	before, err := uc.inventoryRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
 	// For manual adjustments, no reference ID needed
-	return uc.inventoryRepo.AdjustStock(
-		id,
-		req.Quantity,
-		req.Type,
-		req.Reason,
-		nil, // referenceID (optional)
-		userID,
-	)
+	quantity := decimal.NewFromFloat(req.Quantity)
+	if req.UnitCost != nil {
+		err = uc.inventoryRepo.ReceiveStock(id, quantity, decimal.NewFromFloat(*req.UnitCost), req.Type, req.Reason, nil, actor.UserID)
+	} else {
+		err = uc.inventoryRepo.AdjustStock(
+			id,
+			quantity,
+			req.Type,
+			req.Reason,
+			nil, // referenceID (optional)
+			actor.UserID,
+		)
+	}
+	if err != nil {
+		return err
+	}
+
+	after, err := uc.inventoryRepo.FindByID(id)
+	if err != nil || after == nil {
+		after = before
+	}
+	entry := Domain.NewAuditEntry(actor, before.BusinessID, Domain.AuditEntityProduct, id, Domain.AuditActionAdjustStock, before, after)
+	entry.Reason = req.Reason
+	recordAudit(uc.audit, entry)
+	return nil
 }
 
 // Helper method for other usecases to call (like sales, expenses)
-func (uc *inventoryUseCase) AdjustStockWithReference(id string, quantity int, movementType Domain.MovementType, reason string, referenceID string, userID string) error {
+func (uc *inventoryUseCase) AdjustStockWithReference(id string, quantity decimal.Decimal, movementType Domain.MovementType, reason string, referenceID string, userID string) error {
 	return uc.inventoryRepo.AdjustStock(
 		id,
 		quantity,
@@ -222,7 +325,7 @@ func (uc *inventoryUseCase) GetLowStock(businessID string) ([]Domain.ProductResp
 
 func (uc *inventoryUseCase) GetStockHistory(productID, businessID string, limit int) ([]Domain.StockMovementResponse, error) {
 	// Verify product exists and belongs to business
-	product, err := uc.GetProductByID(productID, businessID)
+	product, err := uc.findProduct(productID, businessID)
 	if err != nil {
 		return nil, err
 	}
@@ -239,6 +342,8 @@ func (uc *inventoryUseCase) GetStockHistory(productID, businessID string, limit
 			Type:        movement.Type,
 			Quantity:    movement.Quantity,
 			Reason:      movement.Reason,
+			UnitCost:    movement.UnitCost,
+			Cost:        movement.Cost,
 			CreatedBy:   movement.CreatedBy.Hex(),
 			CreatedAt:   movement.CreatedAt,
 			ProductID:   productID,
@@ -256,17 +361,87 @@ func (uc *inventoryUseCase) GetStockHistory(productID, businessID string, limit
 	return responses, nil
 }
 
+// CreateVariant adds a variant of a product with its own stock. Whatever
+// the request leaves out, the variant takes from its product.
+func (uc *inventoryUseCase) CreateVariant(parentID, businessID string, actor Domain.AuditActor, req Domain.CreateVariantRequest) (*Domain.ProductResponse, error) {
+	parent, err := uc.findProduct(parentID, businessID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.IsVariant() {
+		return nil, Domain.ErrNestedVariant
+	}
+
+	_, err = primitive.ObjectIDFromHex(actor.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	variant := &Domain.Product{
+		BusinessID:          parent.BusinessID,
+		ParentID:            parent.ID,
+		Attributes:          req.Attributes,
+		Name:                req.Name,
+		SKU:                 req.SKU,
+		Barcode:             req.Barcode,
+		Category:            parent.Category,
+		UnitOfMeasure:       parent.UnitOfMeasure,
+		DefaultSellingPrice: parent.DefaultSellingPrice,
+		UnitCost:            decimal.NewFromFloat(req.UnitCost),
+		StockQuantity:       decimal.NewFromFloat(req.StockQuantity),
+		LowStockThreshold:   parent.LowStockThreshold,
+	}
+	if req.DefaultSellingPrice != nil {
+		variant.DefaultSellingPrice = decimal.NewFromFloat(*req.DefaultSellingPrice)
+	}
+	if req.LowStockThreshold != nil {
+		variant.LowStockThreshold = decimal.NewFromFloat(*req.LowStockThreshold)
+	}
+	if err := variant.NormalizeCatalog(); err != nil {
+		return nil, err
+	}
+	if variant.Name == "" {
+		variant.Name = Domain.VariantName(parent.Name, variant.Attributes)
+	}
+
+	if err := uc.inventoryRepo.Create(variant); err != nil {
+		return nil, fmt.Errorf("failed to create variant: %w", err)
+	}
+
+	recordAudit(uc.audit, Domain.NewAuditEntry(actor, variant.BusinessID, Domain.AuditEntityProduct, variant.ID.Hex(), Domain.AuditActionCreate, nil, variant))
+
+	return uc.toProductResponse(variant), nil
+}
+
+func (uc *inventoryUseCase) GetCategories(businessID string) ([]Domain.ProductCategory, error) {
+	categories, err := uc.inventoryRepo.GetCategories(businessID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	return categories, nil
+}
+
 func (uc *inventoryUseCase) toProductResponse(product *Domain.Product) *Domain.ProductResponse {
-	return &Domain.ProductResponse{
+	response := &Domain.ProductResponse{
 		ID:                  product.ID.Hex(),
 		Name:                product.Name,
+		SKU:                 product.SKU,
+		Barcode:             product.Barcode,
+		Category:            product.Category,
+		Unit:                product.Unit(),
+		Attributes:          product.Attributes,
 		DefaultSellingPrice: product.DefaultSellingPrice,
+		UnitCost:            product.UnitCost,
 		StockQuantity:       product.StockQuantity,
 		LowStockThreshold:   product.LowStockThreshold,
 		IsLowStock:          product.IsLowStock(),
 		CreatedAt:           product.CreatedAt,
 		UpdatedAt:           product.UpdatedAt,
 	}
+	if product.IsVariant() {
+		response.ParentID = product.ParentID.Hex()
+	}
+	return response
 }
 
 func (uc *inventoryUseCase) isValidMovementType(movementType Domain.MovementType) bool {
@@ -0,0 +1,318 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// inviteCodeAlphabet leaves out characters that are easy to misread.
+const (
+	inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	inviteCodeLength   = 8
+)
+
+var phonePattern = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+type InvitationUseCases interface {
+	CreateInvitation(businessId string, actorId string, req *domain.CreateInvitationRequest) (*domain.Invitation, error)
+	ListInvitations(businessId string) ([]*domain.Invitation, error)
+	RevokeInvitation(businessId string, invitationId string) error
+	PreviewInvitation(code string) (*domain.InvitationPreview, error)
+	AcceptInvitation(userId string, code string) (*domain.Business, error)
+	Redeem(invitation *domain.Invitation, user *domain.User) error
+	InvitationRedeemer
+}
+
+type invitationUseCases struct {
+	invitationRepo repositories.InvitationRepository
+	membershipRepo repositories.MembershipRepository
+	businessRepo   repositories.BusinessRepository
+	userRepo       repositories.UserRepository
+	linkBaseURL    string
+}
+
+// NewInvitationUseCases builds the invitation flow. When linkBaseURL is set,
+// new invitations carry a link of the form linkBaseURL?code=CODE.
+func NewInvitationUseCases(invitationRepo repositories.InvitationRepository, membershipRepo repositories.MembershipRepository, businessRepo repositories.BusinessRepository, userRepo repositories.UserRepository, linkBaseURL string) InvitationUseCases {
+	return &invitationUseCases{
+		invitationRepo: invitationRepo,
+		membershipRepo: membershipRepo,
+		businessRepo:   businessRepo,
+		userRepo:       userRepo,
+		linkBaseURL:    linkBaseURL,
+	}
+}
+
+// CreateInvitation issues a single-use code for req.Phone. The plain code is
+// only returned here.
+func (i *invitationUseCases) CreateInvitation(businessId string, actorId string, req *domain.CreateInvitationRequest) (*domain.Invitation, error) {
+	if !isStaffRole(req.Role) {
+		return nil, domain.ErrInvalidMemberRole
+	}
+	phone := strings.TrimSpace(req.Phone)
+	if phone == "" {
+		return nil, domain.ErrPhoneRequired
+	}
+	if !phonePattern.MatchString(phone) {
+		return nil, domain.ErrInvalidPhone
+	}
+	ttl := domain.DefaultInvitationTTL
+	if req.ExpiresInHours != 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+		if ttl < time.Hour || ttl > domain.MaxInvitationTTL {
+			return nil, domain.ErrInvalidInvitationTTL
+		}
+	}
+
+	business, err := i.businessRepo.FindByID(businessId)
+	if err != nil {
+		return nil, err
+	}
+	if business == nil {
+		return nil, domain.ErrBusinessNotFound
+	}
+
+	// Someone who already works here does not need an invitation
+	user, err := i.userRepo.FindByPhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		if user.ID == business.UserID {
+			return nil, domain.ErrAlreadyMember
+		}
+		membership, err := i.membershipRepo.FindByBusinessAndUser(businessId, user.ID.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if membership != nil {
+			return nil, domain.ErrAlreadyMember
+		}
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	actorID, _ := primitive.ObjectIDFromHex(actorId)
+	now := time.Now()
+	invitation := &domain.Invitation{
+		ID:         primitive.NewObjectID(),
+		BusinessID: business.ID,
+		Phone:      phone,
+		Role:       req.Role,
+		CodeHash:   hashInviteCode(code),
+		Status:     domain.InvitationPending,
+		InvitedBy:  actorID,
+		ExpiresAt:  now.Add(ttl),
+		CreatedAt:  now,
+	}
+	if err := i.invitationRepo.Save(invitation); err != nil {
+		return nil, err
+	}
+
+	invitation.Code = code
+	if i.linkBaseURL != "" {
+		invitation.Link = i.linkBaseURL + "?code=" + url.QueryEscape(code)
+	}
+	return invitation, nil
+}
+
+// ListInvitations returns every invitation of the business, newest first.
+func (i *invitationUseCases) ListInvitations(businessId string) ([]*domain.Invitation, error) {
+	invitations, err := i.invitationRepo.FindByBusiness(businessId)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, invitation := range invitations {
+		invitation.Status = invitation.StatusAt(now)
+	}
+	return invitations, nil
+}
+
+func (i *invitationUseCases) RevokeInvitation(businessId string, invitationId string) error {
+	return i.invitationRepo.Revoke(businessId, invitationId, time.Now())
+}
+
+// PreviewInvitation shows an invitee what they are joining before they sign up.
+func (i *invitationUseCases) PreviewInvitation(code string) (*domain.InvitationPreview, error) {
+	invitation, err := i.findUsable(code)
+	if err != nil {
+		return nil, err
+	}
+	business, err := i.businessRepo.FindByID(invitation.BusinessID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if business == nil {
+		return nil, domain.ErrInvitationNotFound
+	}
+
+	return &domain.InvitationPreview{
+		BusinessName: business.Name,
+		Role:         invitation.Role,
+		ExpiresAt:    invitation.ExpiresAt,
+	}, nil
+}
+
+// AcceptInvitation lets a user who already has an account join with a code.
+func (i *invitationUseCases) AcceptInvitation(userId string, code string) (*domain.Business, error) {
+	user, err := i.userRepo.FindById(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrInvitationNotFound
+	}
+
+	invitation, err := i.ValidateInvitation(code, user.Phone)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.Redeem(invitation, user); err != nil {
+		return nil, err
+	}
+
+	business, err := i.businessRepo.FindByID(invitation.BusinessID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if business == nil {
+		return nil, domain.ErrBusinessNotFound
+	}
+	business.Role = invitation.Role
+	return business, nil
+}
+
+// ValidateInvitation checks that code is a live invitation issued to phone.
+func (i *invitationUseCases) ValidateInvitation(code string, phone string) (*domain.Invitation, error) {
+	invitation, err := i.findUsable(code)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Phone != strings.TrimSpace(phone) {
+		return nil, domain.ErrInvitationPhoneMismatch
+	}
+	return invitation, nil
+}
+
+// RedeemByID redeems an invitation validated earlier, at registration. It is
+// checked again, since it may have expired or been revoked in the meantime.
+func (i *invitationUseCases) RedeemByID(invitationId primitive.ObjectID, user *domain.User) error {
+	invitation, err := i.invitationRepo.FindByID(invitationId)
+	if err != nil {
+		return err
+	}
+	if invitation == nil {
+		return domain.ErrInvitationNotFound
+	}
+	if err := usable(invitation); err != nil {
+		return err
+	}
+	if invitation.Phone != user.Phone {
+		return domain.ErrInvitationPhoneMismatch
+	}
+	return i.Redeem(invitation, user)
+}
+
+// Redeem uses up the invitation and gives user its role in the business.
+// Only a user who has proved they own the invited phone can redeem it.
+func (i *invitationUseCases) Redeem(invitation *domain.Invitation, user *domain.User) error {
+	if !user.PhoneVerified {
+		return domain.ErrPhoneNotVerified
+	}
+	businessId := invitation.BusinessID.Hex()
+	existing, err := i.membershipRepo.FindByBusinessAndUser(businessId, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return domain.ErrAlreadyMember
+	}
+
+	now := time.Now()
+	if err := i.membershipRepo.Save(&domain.Membership{
+		ID:         primitive.NewObjectID(),
+		BusinessID: invitation.BusinessID,
+		UserID:     user.ID,
+		Role:       invitation.Role,
+		AddedBy:    invitation.InvitedBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}); err != nil {
+		return err
+	}
+
+	// The claim settles who gets the invitation; whoever loses gives the seat back
+	if err := i.invitationRepo.MarkAccepted(invitation.ID, user.ID, now); err != nil {
+		if delErr := i.membershipRepo.Delete(businessId, user.ID.Hex()); delErr != nil {
+			fmt.Printf("WARNING: failed to remove membership of user %s in business %s: %v\n", user.ID.Hex(), businessId, delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (i *invitationUseCases) findUsable(code string) (*domain.Invitation, error) {
+	code = normalizeInviteCode(code)
+	if code == "" {
+		return nil, domain.ErrInvitationNotFound
+	}
+	invitation, err := i.invitationRepo.FindByCodeHash(hashInviteCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, domain.ErrInvitationNotFound
+	}
+	if err := usable(invitation); err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// usable reports why an invitation can no longer be redeemed, if it cannot.
+func usable(invitation *domain.Invitation) error {
+	switch invitation.StatusAt(time.Now()) {
+	case domain.InvitationPending:
+		return nil
+	case domain.InvitationExpired:
+		return domain.ErrInvitationExpired
+	default:
+		return domain.ErrInvitationUsed
+	}
+}
+
+func newInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for n, b := range buf {
+		buf[n] = inviteCodeAlphabet[int(b)%len(inviteCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// normalizeInviteCode accepts codes typed in lower case or with separators.
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashInviteCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,256 @@
+package usecases
+
+import (
+	"strings"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MembershipUseCases interface {
+	ResolveRole(businessId string, userId string) (domain.BusinessRole, error)
+	ListBusinesses(userId string) ([]*domain.Business, error)
+	ListMembers(businessId string) ([]domain.Member, error)
+	AddMember(businessId string, actorId string, req *domain.AddMemberRequest) (*domain.Member, error)
+	UpdateRole(businessId string, userId string, req *domain.UpdateMemberRoleRequest) (*domain.Member, error)
+	RemoveMember(businessId string, userId string) error
+}
+
+type membershipUseCases struct {
+	membershipRepo repositories.MembershipRepository
+	businessRepo   repositories.BusinessRepository
+	userRepo       repositories.UserRepository
+}
+
+func NewMembershipUseCases(membershipRepo repositories.MembershipRepository, businessRepo repositories.BusinessRepository, userRepo repositories.UserRepository) MembershipUseCases {
+	return &membershipUseCases{
+		membershipRepo: membershipRepo,
+		businessRepo:   businessRepo,
+		userRepo:       userRepo,
+	}
+}
+
+// ResolveRole returns the role a user holds in a business. The business's
+// creator is its owner; everyone else needs a membership.
+func (m *membershipUseCases) ResolveRole(businessId string, userId string) (domain.BusinessRole, error) {
+	if _, err := primitive.ObjectIDFromHex(businessId); err != nil {
+		return "", domain.ErrBusinessNotFound
+	}
+	business, err := m.businessRepo.FindByID(businessId)
+	if err != nil {
+		return "", err
+	}
+	if business == nil {
+		return "", domain.ErrBusinessNotFound
+	}
+	if business.UserID.Hex() == userId {
+		return domain.RoleOwner, nil
+	}
+
+	membership, err := m.membershipRepo.FindByBusinessAndUser(businessId, userId)
+	if err != nil {
+		return "", err
+	}
+	if membership == nil {
+		return "", domain.ErrNotMember
+	}
+	return membership.Role, nil
+}
+
+// ListBusinesses returns the businesses a user owns followed by those they
+// work in, each tagged with the user's role.
+func (m *membershipUseCases) ListBusinesses(userId string) ([]*domain.Business, error) {
+	owned, err := m.businessRepo.FindByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	businesses := make([]*domain.Business, 0, len(owned))
+	for _, business := range owned {
+		business.Role = domain.RoleOwner
+		businesses = append(businesses, business)
+	}
+
+	memberships, err := m.membershipRepo.FindByUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(memberships) == 0 {
+		return businesses, nil
+	}
+
+	roles := make(map[primitive.ObjectID]domain.BusinessRole, len(memberships))
+	ids := make([]primitive.ObjectID, 0, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.BusinessID] = membership.Role
+		ids = append(ids, membership.BusinessID)
+	}
+	joined, err := m.businessRepo.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, business := range joined {
+		business.Role = roles[business.ID]
+		businesses = append(businesses, business)
+	}
+	return businesses, nil
+}
+
+// ListMembers returns the owner followed by every staff member.
+func (m *membershipUseCases) ListMembers(businessId string) ([]domain.Member, error) {
+	business, err := m.businessRepo.FindByID(businessId)
+	if err != nil {
+		return nil, err
+	}
+	if business == nil {
+		return nil, domain.ErrBusinessNotFound
+	}
+
+	members := []domain.Member{}
+	if owner, _ := m.userRepo.FindById(business.UserID.Hex()); owner != nil {
+		members = append(members, newMember(owner, domain.RoleOwner, business.CreatedAt))
+	}
+
+	memberships, err := m.membershipRepo.FindByBusiness(businessId)
+	if err != nil {
+		return nil, err
+	}
+	for _, membership := range memberships {
+		user, err := m.userRepo.FindById(membership.UserID.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			continue
+		}
+		members = append(members, newMember(user, membership.Role, membership.CreatedAt))
+	}
+	return members, nil
+}
+
+// AddMember gives the user registered with req.Phone a role in the business.
+// The user must have verified that phone.
+func (m *membershipUseCases) AddMember(businessId string, actorId string, req *domain.AddMemberRequest) (*domain.Member, error) {
+	if !isStaffRole(req.Role) {
+		return nil, domain.ErrInvalidMemberRole
+	}
+	phone := strings.TrimSpace(req.Phone)
+	if phone == "" {
+		return nil, domain.ErrPhoneRequired
+	}
+
+	business, err := m.businessRepo.FindByID(businessId)
+	if err != nil {
+		return nil, err
+	}
+	if business == nil {
+		return nil, domain.ErrBusinessNotFound
+	}
+
+	user, err := m.userRepo.FindByPhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotRegistered
+	}
+	if user.ID == business.UserID {
+		return nil, domain.ErrAlreadyMember
+	}
+	// Anyone can register with any number; only its owner may be added by it
+	if !user.PhoneVerified {
+		return nil, domain.ErrPhoneNotVerified
+	}
+
+	actorID, _ := primitive.ObjectIDFromHex(actorId)
+	now := time.Now()
+	membership := &domain.Membership{
+		ID:         primitive.NewObjectID(),
+		BusinessID: business.ID,
+		UserID:     user.ID,
+		Role:       req.Role,
+		AddedBy:    actorID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := m.membershipRepo.Save(membership); err != nil {
+		return nil, err
+	}
+
+	member := newMember(user, membership.Role, membership.CreatedAt)
+	return &member, nil
+}
+
+// UpdateRole changes a staff member's role. The owner's role is fixed.
+func (m *membershipUseCases) UpdateRole(businessId string, userId string, req *domain.UpdateMemberRoleRequest) (*domain.Member, error) {
+	if !isStaffRole(req.Role) {
+		return nil, domain.ErrInvalidMemberRole
+	}
+	membership, err := m.findStaff(businessId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.membershipRepo.UpdateRole(businessId, userId, req.Role); err != nil {
+		return nil, err
+	}
+
+	user, err := m.userRepo.FindById(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrMemberNotFound
+	}
+	member := newMember(user, req.Role, membership.CreatedAt)
+	return &member, nil
+}
+
+// RemoveMember takes a staff member out of the business.
+func (m *membershipUseCases) RemoveMember(businessId string, userId string) error {
+	if _, err := m.findStaff(businessId, userId); err != nil {
+		return err
+	}
+	return m.membershipRepo.Delete(businessId, userId)
+}
+
+// findStaff loads a staff membership, refusing to touch the owner
+func (m *membershipUseCases) findStaff(businessId string, userId string) (*domain.Membership, error) {
+	business, err := m.businessRepo.FindByID(businessId)
+	if err != nil {
+		return nil, err
+	}
+	if business == nil {
+		return nil, domain.ErrBusinessNotFound
+	}
+	if business.UserID.Hex() == userId {
+		return nil, domain.ErrOwnerMembership
+	}
+
+	if _, err := primitive.ObjectIDFromHex(userId); err != nil {
+		return nil, domain.ErrMemberNotFound
+	}
+	membership, err := m.membershipRepo.FindByBusinessAndUser(businessId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, domain.ErrMemberNotFound
+	}
+	return membership, nil
+}
+
+func isStaffRole(role domain.BusinessRole) bool {
+	return role != domain.RoleOwner && domain.IsValidBusinessRole(role)
+}
+
+func newMember(user *domain.User, role domain.BusinessRole, joinedAt time.Time) domain.Member {
+	return domain.Member{
+		UserID:   user.ID.Hex(),
+		Name:     user.Name,
+		Phone:    user.Phone,
+		Role:     role,
+		JoinedAt: joinedAt,
+	}
+}
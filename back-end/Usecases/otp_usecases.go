@@ -0,0 +1,113 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	domain "shop-ops/Domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sendOTP issues a new code for purpose and sends it to destination. message
+// is a format string with one %s for the code. A code requested again within
+// OTPResendInterval is not resent; the earlier one still works.
+//
+// Guesses count per user and purpose, not per code: a new code keeps the
+// attempts of the one it replaces until that one would have expired, and a
+// code burned by wrong guesses is not replaced before then.
+func (u *userUseCases) sendOTP(user *domain.User, purpose domain.OTPPurpose, channel domain.NotificationChannel, destination string, message string) error {
+	now := time.Now()
+	latest, err := u.otpRepo.FindLatest(user.ID, purpose)
+	if err != nil {
+		return err
+	}
+	attempts := 0
+	if latest != nil && latest.ConsumedAt == nil && latest.ExpiresAt.After(now) {
+		if latest.Attempts >= domain.OTPMaxAttempts || now.Sub(latest.CreatedAt) < domain.OTPResendInterval {
+			return nil
+		}
+		attempts = latest.Attempts
+	}
+
+	code, err := generateOTP()
+	if err != nil {
+		return err
+	}
+	codeHash, err := u.pwdService.Hash(code)
+	if err != nil {
+		return err
+	}
+
+	// The new code takes the place of the old one, keeping its ID
+	id := primitive.NewObjectID()
+	if latest != nil {
+		id = latest.ID
+	}
+	otp := &domain.OneTimeCode{
+		ID:          id,
+		UserID:      user.ID,
+		Purpose:     purpose,
+		Destination: destination,
+		CodeHash:    codeHash,
+		Attempts:    attempts,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(domain.OTPTTL),
+	}
+	if err := u.otpRepo.Replace(otp); err != nil {
+		return err
+	}
+
+	notification := domain.Notification{
+		Channel: channel,
+		To:      destination,
+		Body:    fmt.Sprintf(message, code),
+	}
+	if channel == domain.NotificationEmail {
+		notification.Subject = "Your Shop Ops code"
+	}
+	return u.notifier.Send(notification)
+}
+
+// verifyOTP checks code against the user's latest code for purpose and
+// consumes it on success. Every check counts as an attempt, right or wrong.
+func (u *userUseCases) verifyOTP(user *domain.User, purpose domain.OTPPurpose, code string) (*domain.OneTimeCode, error) {
+	now := time.Now()
+	otp, err := u.otpRepo.FindLatest(user.ID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if otp == nil || !otp.UsableAt(now) {
+		if otp != nil && otp.ConsumedAt == nil && otp.Attempts >= domain.OTPMaxAttempts {
+			return nil, domain.ErrOTPAttemptsExceeded
+		}
+		return nil, domain.ErrInvalidOTP
+	}
+
+	if err := u.otpRepo.RecordAttempt(otp.ID, now); err != nil {
+		return nil, err
+	}
+	if !u.pwdService.Compare(strings.TrimSpace(code), otp.CodeHash) {
+		return nil, domain.ErrInvalidOTP
+	}
+	if err := u.otpRepo.Consume(otp.ID, now); err != nil {
+		return nil, err
+	}
+	return otp, nil
+}
+
+// generateOTP returns OTPLength random digits.
+func generateOTP() (string, error) {
+	var b strings.Builder
+	for i := 0; i < domain.OTPLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + n.Int64()))
+	}
+	return b.String(), nil
+}
@@ -9,6 +9,7 @@ import (
 	domain "shop-ops/Domain"
 	repositories "shop-ops/Repositories"
 
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -16,6 +17,7 @@ type ProfitUseCase interface {
 	GetSummary(businessID string, query domain.ProfitQuery) (*domain.ProfitSummaryResponse, error)
 	GetTrends(businessID string, query domain.ProfitQuery) (*domain.ProfitTrendsResponse, error)
 	GetComparison(businessID string, query domain.ProfitQuery) (*domain.ProfitCompareResponse, error)
+	GetProductMargins(businessID string, query domain.ProfitQuery) (*domain.ProfitByProductResponse, error)
 }
 
 type profitUseCase struct {
@@ -85,11 +87,19 @@ func (uc *profitUseCase) getSummaryForPeriod(businessID string, start, end time.
 	
 	grandTotalExpenses, _ := grandTotalDecimal.Float64()
 
-	// 3. Calculate Net Profit
+	// 3. Calculate Gross Profit from the cost of the goods sold
+	sales := decimal.NewFromFloat(salesSummary.TotalRevenue)
+	costOfGoods := decimal.NewFromFloat(salesSummary.CostOfGoods)
+	grossProfit := salesSummary.TotalRevenue - salesSummary.CostOfGoods
+
+	// 4. Calculate Net Profit
 	netProfit := salesSummary.TotalRevenue - grandTotalExpenses
 
 	return &domain.ProfitSummaryResponse{
 		TotalSales:    math.Round(salesSummary.TotalRevenue*100) / 100,
+		CostOfGoods:   math.Round(salesSummary.CostOfGoods*100) / 100,
+		GrossProfit:   math.Round(grossProfit*100) / 100,
+		GrossMargin:   domain.GrossMargin(sales, costOfGoods).InexactFloat64(),
 		TotalExpenses: math.Round(grandTotalExpenses*100) / 100,
 		NetProfit:     math.Round(netProfit*100) / 100,
 		Period:        fmt.Sprintf("%s to %s", start.Format("2006-01-02"), end.Format("2006-01-02")),
@@ -159,6 +169,9 @@ func (uc *profitUseCase) GetTrends(businessID string, query domain.ProfitQuery)
 		trends = append(trends, domain.ProfitTrendDataPoint{
 			Date:          dateLabel,
 			TotalSales:    summary.TotalSales,
+			CostOfGoods:   summary.CostOfGoods,
+			GrossProfit:   summary.GrossProfit,
+			GrossMargin:   summary.GrossMargin,
 			TotalExpenses: summary.TotalExpenses,
 			NetProfit:     summary.NetProfit,
 		})
@@ -210,3 +223,24 @@ func (uc *profitUseCase) GetComparison(businessID string, query domain.ProfitQue
 		ChangePct: math.Round(changePct*100) / 100,
 	}, nil
 }
+
+// GetProductMargins returns the gross margin of each product sold in the period
+func (uc *profitUseCase) GetProductMargins(businessID string, query domain.ProfitQuery) (*domain.ProfitByProductResponse, error) {
+	start, end, err := parseDateRange(query.StartDate, query.EndDate, 30) // Default 30 days
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := uc.salesRepo.GetProductMargins(businessID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product margins: %w", err)
+	}
+	if products == nil {
+		products = []domain.ProductMargin{}
+	}
+
+	return &domain.ProfitByProductResponse{
+		Products: products,
+		Period:   fmt.Sprintf("%s to %s", start.Format("2006-01-02"), end.Format("2006-01-02")),
+	}, nil
+}
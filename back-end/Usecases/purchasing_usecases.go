@@ -0,0 +1,331 @@
+package usecases
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	domain "shop-ops/Domain"
+	repositories "shop-ops/Repositories"
+
+	"github.com/shopspring/decimal"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PurchasingUseCases interface {
+	CreateSupplier(businessId string, actor domain.AuditActor, req *domain.SupplierRequest) (*domain.Supplier, error)
+	ListSuppliers(businessId string) ([]*domain.Supplier, error)
+	GetSupplier(businessId string, supplierId string) (*domain.Supplier, error)
+	UpdateSupplier(businessId string, supplierId string, actor domain.AuditActor, req *domain.SupplierRequest) (*domain.Supplier, error)
+	CreatePurchaseOrder(businessId string, actor domain.AuditActor, req *domain.PurchaseOrderRequest) (*domain.PurchaseOrder, error)
+	ListPurchaseOrders(businessId string, query domain.PurchaseOrderQuery) ([]*domain.PurchaseOrder, error)
+	GetPurchaseOrder(businessId string, orderId string) (*domain.PurchaseOrder, error)
+	UpdatePurchaseOrder(businessId string, orderId string, actor domain.AuditActor, req *domain.PurchaseOrderRequest) (*domain.PurchaseOrder, error)
+	PlacePurchaseOrder(businessId string, orderId string, actor domain.AuditActor) (*domain.PurchaseOrder, error)
+	ReceivePurchaseOrder(businessId string, orderId string, actor domain.AuditActor, req *domain.ReceivePurchaseOrderRequest) (*domain.PurchaseOrder, error)
+	CancelPurchaseOrder(businessId string, orderId string, actor domain.AuditActor) (*domain.PurchaseOrder, error)
+	GetSupplierSpend(businessId string, query domain.SupplierSpendQuery) (*domain.SupplierSpendResponse, error)
+}
+
+type purchasingUseCases struct {
+	purchasingRepo repositories.PurchasingRepository
+	productRepo    domain.ProductRepository
+	audit          AuditRecorder
+}
+
+func NewPurchasingUseCases(purchasingRepo repositories.PurchasingRepository, productRepo domain.ProductRepository, audit AuditRecorder) PurchasingUseCases {
+	return &purchasingUseCases{
+		purchasingRepo: purchasingRepo,
+		productRepo:    productRepo,
+		audit:          audit,
+	}
+}
+
+func (p *purchasingUseCases) CreateSupplier(businessId string, actor domain.AuditActor, req *domain.SupplierRequest) (*domain.Supplier, error) {
+	if err := req.Normalize(); err != nil {
+		return nil, err
+	}
+	businessID, err := primitive.ObjectIDFromHex(businessId)
+	if err != nil {
+		return nil, domain.ErrBusinessNotFound
+	}
+
+	now := time.Now()
+	supplier := &domain.Supplier{
+		ID:         primitive.NewObjectID(),
+		BusinessID: businessID,
+		Name:       req.Name,
+		Phone:      req.Phone,
+		Email:      req.Email,
+		Note:       req.Note,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := p.purchasingRepo.CreateSupplier(supplier); err != nil {
+		return nil, err
+	}
+
+	recordAudit(p.audit, domain.NewAuditEntry(actor, businessID, domain.AuditEntitySupplier, supplier.ID.Hex(), domain.AuditActionCreate, nil, supplier))
+	return supplier, nil
+}
+
+// ListSuppliers returns the suppliers of the business by name.
+func (p *purchasingUseCases) ListSuppliers(businessId string) ([]*domain.Supplier, error) {
+	return p.purchasingRepo.ListSuppliers(businessId)
+}
+
+func (p *purchasingUseCases) GetSupplier(businessId string, supplierId string) (*domain.Supplier, error) {
+	supplier, err := p.purchasingRepo.FindSupplier(businessId, supplierId)
+	if err != nil {
+		return nil, err
+	}
+	if supplier == nil {
+		return nil, domain.ErrSupplierNotFound
+	}
+	return supplier, nil
+}
+
+func (p *purchasingUseCases) UpdateSupplier(businessId string, supplierId string, actor domain.AuditActor, req *domain.SupplierRequest) (*domain.Supplier, error) {
+	if err := req.Normalize(); err != nil {
+		return nil, err
+	}
+	supplier, err := p.GetSupplier(businessId, supplierId)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *supplier
+	supplier.Name = req.Name
+	supplier.Phone = req.Phone
+	supplier.Email = req.Email
+	supplier.Note = req.Note
+	supplier.UpdatedAt = time.Now()
+	if err := p.purchasingRepo.UpdateSupplier(supplier); err != nil {
+		return nil, err
+	}
+
+	recordAudit(p.audit, domain.NewAuditEntry(actor, supplier.BusinessID, domain.AuditEntitySupplier, supplierId, domain.AuditActionUpdate, &before, supplier))
+	return supplier, nil
+}
+
+// CreatePurchaseOrder starts a draft order with a supplier of the business
+// for products of the business.
+func (p *purchasingUseCases) CreatePurchaseOrder(businessId string, actor domain.AuditActor, req *domain.PurchaseOrderRequest) (*domain.PurchaseOrder, error) {
+	supplier, err := p.GetSupplier(businessId, req.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := p.orderLines(businessId, req.Lines)
+	if err != nil {
+		return nil, err
+	}
+	createdBy, _ := primitive.ObjectIDFromHex(actor.UserID)
+
+	order := domain.NewPurchaseOrder(supplier.BusinessID, supplier.ID, lines, strings.TrimSpace(req.Note), createdBy)
+	if err := order.Validate(); err != nil {
+		return nil, err
+	}
+	if err := p.purchasingRepo.CreateOrder(order); err != nil {
+		return nil, err
+	}
+
+	recordAudit(p.audit, domain.NewAuditEntry(actor, order.BusinessID, domain.AuditEntityPurchaseOrder, order.ID.Hex(), domain.AuditActionCreate, nil, order))
+	return order, nil
+}
+
+// ListPurchaseOrders returns the business's orders, newest first.
+func (p *purchasingUseCases) ListPurchaseOrders(businessId string, query domain.PurchaseOrderQuery) ([]*domain.PurchaseOrder, error) {
+	if query.Status != "" && !domain.IsValidPurchaseOrderStatus(query.Status) {
+		return nil, domain.ErrInvalidPurchaseStatus
+	}
+	return p.purchasingRepo.ListOrders(businessId, query)
+}
+
+func (p *purchasingUseCases) GetPurchaseOrder(businessId string, orderId string) (*domain.PurchaseOrder, error) {
+	order, err := p.purchasingRepo.FindOrder(businessId, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, domain.ErrPurchaseOrderNotFound
+	}
+	return order, nil
+}
+
+// UpdatePurchaseOrder replaces the supplier, lines and note of a draft.
+func (p *purchasingUseCases) UpdatePurchaseOrder(businessId string, orderId string, actor domain.AuditActor, req *domain.PurchaseOrderRequest) (*domain.PurchaseOrder, error) {
+	order, err := p.GetPurchaseOrder(businessId, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != domain.PurchaseOrderDraft {
+		return nil, domain.ErrPurchaseOrderNotDraft
+	}
+	before := *order
+
+	supplier, err := p.GetSupplier(businessId, req.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := p.orderLines(businessId, req.Lines)
+	if err != nil {
+		return nil, err
+	}
+	order.SupplierID = supplier.ID
+	order.SetLines(lines)
+	order.Note = strings.TrimSpace(req.Note)
+	order.UpdatedAt = time.Now()
+	if err := order.Validate(); err != nil {
+		return nil, err
+	}
+	if err := p.purchasingRepo.SaveOrder(order); err != nil {
+		return nil, err
+	}
+
+	recordAudit(p.audit, domain.NewAuditEntry(actor, order.BusinessID, domain.AuditEntityPurchaseOrder, orderId, domain.AuditActionUpdate, &before, order))
+	return order, nil
+}
+
+// PlacePurchaseOrder marks a draft as sent to the supplier.
+func (p *purchasingUseCases) PlacePurchaseOrder(businessId string, orderId string, actor domain.AuditActor) (*domain.PurchaseOrder, error) {
+	return p.transition(businessId, orderId, actor, domain.AuditActionUpdate, (*domain.PurchaseOrder).Place)
+}
+
+// CancelPurchaseOrder closes an order that will not be filled.
+func (p *purchasingUseCases) CancelPurchaseOrder(businessId string, orderId string, actor domain.AuditActor) (*domain.PurchaseOrder, error) {
+	return p.transition(businessId, orderId, actor, domain.AuditActionCancel, (*domain.PurchaseOrder).Cancel)
+}
+
+func (p *purchasingUseCases) transition(businessId string, orderId string, actor domain.AuditActor, action domain.AuditAction, change func(*domain.PurchaseOrder, time.Time) error) (*domain.PurchaseOrder, error) {
+	order, err := p.GetPurchaseOrder(businessId, orderId)
+	if err != nil {
+		return nil, err
+	}
+	before := *order
+	if err := change(order, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := p.purchasingRepo.SaveOrder(order); err != nil {
+		return nil, err
+	}
+
+	recordAudit(p.audit, domain.NewAuditEntry(actor, order.BusinessID, domain.AuditEntityPurchaseOrder, orderId, action, &before, order))
+	return order, nil
+}
+
+// ReceivePurchaseOrder books a delivery against an ordered purchase order.
+// The stock comes in at the received costs and the delivery's amount is
+// recorded as a STOCK_PURCHASE expense linked to the supplier and order.
+func (p *purchasingUseCases) ReceivePurchaseOrder(businessId string, orderId string, actor domain.AuditActor, req *domain.ReceivePurchaseOrderRequest) (*domain.PurchaseOrder, error) {
+	order, err := p.GetPurchaseOrder(businessId, orderId)
+	if err != nil {
+		return nil, err
+	}
+	before := *order
+	before.Lines = append([]domain.PurchaseOrderLine(nil), order.Lines...)
+
+	lines := order.OutstandingLines()
+	if len(req.Lines) > 0 {
+		if lines, err = receiptLines(order, req.Lines); err != nil {
+			return nil, err
+		}
+	}
+	receivedBy, _ := primitive.ObjectIDFromHex(actor.UserID)
+	receipt, err := order.Receive(lines, receivedBy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	supplier, err := p.purchasingRepo.FindSupplier(businessId, order.SupplierID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		note = "Purchase order " + order.ID.Hex()
+		if supplier != nil {
+			note += " from " + supplier.Name
+		}
+	}
+	expense := domain.NewExpense(order.BusinessID, domain.ExpenseStockPurchase, receipt.Amount, note)
+	expense.CreatedAt = receipt.ReceivedAt
+	expense.SupplierID = &order.SupplierID
+	expense.PurchaseOrderID = &order.ID
+	receipt.ExpenseID = expense.ID
+
+	if err := p.purchasingRepo.ReceiveOrder(order, expense); err != nil {
+		return nil, err
+	}
+
+	recordAudit(p.audit, domain.NewAuditEntry(actor, order.BusinessID, domain.AuditEntityPurchaseOrder, orderId, domain.AuditActionReceive, &before, order))
+	return order, nil
+}
+
+// GetSupplierSpend reports what was spent with each supplier over a period,
+// the last 30 days by default.
+func (p *purchasingUseCases) GetSupplierSpend(businessId string, query domain.SupplierSpendQuery) (*domain.SupplierSpendResponse, error) {
+	start, end, err := parseDateRange(query.StartDate, query.EndDate, 30)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidSpendPeriod, err)
+	}
+
+	suppliers, err := p.purchasingRepo.SupplierSpend(businessId, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supplier spend: %w", err)
+	}
+	total := decimal.Zero
+	for _, spend := range suppliers {
+		total = total.Add(spend.Amount)
+	}
+
+	return &domain.SupplierSpendResponse{
+		Suppliers: suppliers,
+		Total:     total,
+		Period:    fmt.Sprintf("%s to %s", start.Format("2006-01-02"), end.Format("2006-01-02")),
+	}, nil
+}
+
+// orderLines turns requested lines into order lines, checking each product
+// belongs to the business.
+func (p *purchasingUseCases) orderLines(businessId string, requested []domain.PurchaseOrderLineRequest) ([]domain.PurchaseOrderLine, error) {
+	lines := make([]domain.PurchaseOrderLine, 0, len(requested))
+	for i, req := range requested {
+		productID, err := p.businessProduct(businessId, req.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("%w %d: %v", domain.ErrInvalidPurchaseLine, i+1, err)
+		}
+		lines = append(lines, domain.PurchaseOrderLine{
+			ProductID: productID,
+			Quantity:  decimal.NewFromFloat(req.Quantity),
+			UnitCost:  decimal.NewFromFloat(req.UnitCost),
+		})
+	}
+	return lines, nil
+}
+
+func (p *purchasingUseCases) businessProduct(businessId string, productId string) (primitive.ObjectID, error) {
+	product, err := p.productRepo.FindByID(productId)
+	if err != nil || product == nil || product.BusinessID.Hex() != businessId {
+		return primitive.NilObjectID, fmt.Errorf("product not found")
+	}
+	return product.ID, nil
+}
+
+// receiptLines turns requested receipt lines into lines of the order's
+// receipt. A line without a unit cost is received at the ordered cost.
+func receiptLines(order *domain.PurchaseOrder, requested []domain.ReceiveLineRequest) ([]domain.PurchaseReceiptLine, error) {
+	lines := make([]domain.PurchaseReceiptLine, 0, len(requested))
+	for i, req := range requested {
+		productID, err := primitive.ObjectIDFromHex(req.ProductID)
+		index := order.Line(productID)
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("%w %d: product is not on this purchase order", domain.ErrInvalidPurchaseLine, i+1)
+		}
+		unitCost := order.Lines[index].UnitCost
+		if req.UnitCost != nil {
+			unitCost = decimal.NewFromFloat(*req.UnitCost)
+		}
+		lines = append(lines, domain.PurchaseReceiptLine{ProductID: productID, Quantity: decimal.NewFromFloat(req.Quantity), UnitCost: unitCost})
+	}
+	return lines, nil
+}
@@ -67,6 +67,7 @@ func (u *ReportUsecases) GenerateSalesReport(businessID primitive.ObjectID, date
 		localFrom,
 		localTo,
 	)
+	report.TotalItems = data.TotalItems
 
 	if groupBy != "" {
 		report.GroupBy = groupBy
@@ -156,10 +157,12 @@ func (u *ReportUsecases) GenerateProfitReport(businessID primitive.ObjectID, dat
 	// Build domain object
 	report := Domain.NewProfitSummary(
 		data.TotalSales,
+		data.CostOfGoods,
 		data.TotalExpenses,
 		localFrom,
 		localTo,
 	)
+	report.Products = data.Products
 
 	if groupBy != "" {
 		report.GroupBy = groupBy
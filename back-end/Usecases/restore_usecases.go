@@ -13,8 +13,8 @@ import (
 
 // RestoreUseCases defines the business logic for data restore operations
 type RestoreUseCases interface {
-	FullRestore(businessID string, include []string) (*Domain.RestoreResponse, error)
-	IncrementalRestore(businessID string, since time.Time, include []string) (*Domain.RestoreResponse, error)
+	FullRestore(businessID string, role Domain.BusinessRole, include []string) (*Domain.RestoreResponse, error)
+	IncrementalRestore(businessID string, role Domain.BusinessRole, since time.Time, include []string) (*Domain.RestoreResponse, error)
 }
 
 type restoreUseCases struct {
@@ -51,7 +51,8 @@ func shouldInclude(include []string, entity string) bool {
 }
 
 // FullRestore fetches all data for a business, filtered by the include list
-func (uc *restoreUseCases) FullRestore(businessID string, include []string) (*Domain.RestoreResponse, error) {
+// and by what the caller's role may see
+func (uc *restoreUseCases) FullRestore(businessID string, role Domain.BusinessRole, include []string) (*Domain.RestoreResponse, error) {
 	response := &Domain.RestoreResponse{
 		RestoredAt: time.Now(),
 	}
@@ -64,7 +65,7 @@ func (uc *restoreUseCases) FullRestore(businessID string, include []string) (*Do
 		response.Sales = sales
 	}
 
-	if shouldInclude(include, "expenses") {
+	if shouldInclude(include, "expenses") && role.CanViewExpenses() {
 		objBusinessID, err := primitive.ObjectIDFromHex(businessID)
 		if err != nil {
 			return nil, fmt.Errorf("invalid business ID: %w", err)
@@ -89,11 +90,13 @@ func (uc *restoreUseCases) FullRestore(businessID string, include []string) (*Do
 		response.Products = products
 	}
 
+	response.RedactFor(role)
 	return response, nil
 }
 
 // IncrementalRestore fetches data modified since the given timestamp, filtered by the include list
-func (uc *restoreUseCases) IncrementalRestore(businessID string, since time.Time, include []string) (*Domain.RestoreResponse, error) {
+// and by what the caller's role may see
+func (uc *restoreUseCases) IncrementalRestore(businessID string, role Domain.BusinessRole, since time.Time, include []string) (*Domain.RestoreResponse, error) {
 	sinceStr := since.Format(time.RFC3339)
 	response := &Domain.RestoreResponse{
 		Since:      &sinceStr,
@@ -108,7 +111,7 @@ func (uc *restoreUseCases) IncrementalRestore(businessID string, since time.Time
 		response.Sales = sales
 	}
 
-	if shouldInclude(include, "expenses") {
+	if shouldInclude(include, "expenses") && role.CanViewExpenses() {
 		objBusinessID, err := primitive.ObjectIDFromHex(businessID)
 		if err != nil {
 			return nil, fmt.Errorf("invalid business ID: %w", err)
@@ -132,5 +135,6 @@ func (uc *restoreUseCases) IncrementalRestore(businessID string, since time.Time
 		response.Products = products
 	}
 
+	response.RedactFor(role)
 	return response, nil
 }
@@ -6,16 +6,17 @@ import (
 
 	Domain "shop-ops/Domain"
 
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // SalesUseCase defines business logic operations for sales
 type SalesUseCase interface {
-	CreateSale(businessID, userID string, req Domain.CreateSaleRequest) (*Domain.SaleResponse, error)
+	CreateSale(businessID string, actor Domain.AuditActor, req Domain.CreateSaleRequest) (*Domain.SaleResponse, error)
 	GetSales(businessID string, query Domain.SaleListQuery) (*Domain.SaleListResponse, error)
 	GetSaleByID(id, businessID string) (*Domain.SaleResponse, error)
-	UpdateSale(id, businessID string, req Domain.UpdateSaleRequest) (*Domain.SaleResponse, error)
-	VoidSale(id, businessID, userID string) error
+	UpdateSale(id, businessID string, actor Domain.AuditActor, req Domain.UpdateSaleRequest) (*Domain.SaleResponse, error)
+	VoidSale(id, businessID string, actor Domain.AuditActor) error
 	GetSalesSummary(businessID string, startDate, endDate string) (*Domain.SaleSummaryResponse, error)
 	GetSalesStats(businessID string) (*Domain.SaleStatsResponse, error)
 }
@@ -24,6 +25,7 @@ type salesUseCase struct {
 	salesRepo     Domain.SaleRepository
 	inventoryRepo Domain.ProductRepository
 	businessRepo  Domain.BusinessRepository
+	audit         AuditRecorder
 }
 
 // NewSalesUseCase constructs a SalesUseCase with all required dependencies
@@ -31,16 +33,19 @@ func NewSalesUseCase(
 	salesRepo Domain.SaleRepository,
 	inventoryRepo Domain.ProductRepository,
 	businessRepo Domain.BusinessRepository,
+	audit AuditRecorder,
 ) SalesUseCase {
 	return &salesUseCase{
 		salesRepo:     salesRepo,
 		inventoryRepo: inventoryRepo,
 		businessRepo:  businessRepo,
+		audit:         audit,
 	}
 }
 
-// CreateSale records a new sale and optionally decrements product stock
-func (uc *salesUseCase) CreateSale(businessID, userID string, req Domain.CreateSaleRequest) (*Domain.SaleResponse, error) {
+// CreateSale records a new sale and decrements the stock of each line that
+// names a product
+func (uc *salesUseCase) CreateSale(businessID string, actor Domain.AuditActor, req Domain.CreateSaleRequest) (*Domain.SaleResponse, error) {
 	// Validate business exists
 	business, err := uc.businessRepo.FindByID(businessID)
 	if err != nil {
@@ -55,59 +60,43 @@ func (uc *salesUseCase) CreateSale(businessID, userID string, req Domain.CreateS
 		return nil, fmt.Errorf("invalid business ID: %w", err)
 	}
 
-	// Resolve optional product ID
-	var objProductID *primitive.ObjectID
-	if req.ProductID != nil && *req.ProductID != "" {
-		id, err := primitive.ObjectIDFromHex(*req.ProductID)
+	// Build the lines, checking every product belongs to the business
+	lineRequests := req.LineRequests()
+	lines := make([]Domain.SaleLine, 0, len(lineRequests))
+	for i, lineReq := range lineRequests {
+		productID, err := uc.resolveSaleProduct(businessID, lineReq.ProductID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid product ID: %w", err)
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
 		}
-		// Verify product belongs to business
-		product, err := uc.inventoryRepo.FindByID(*req.ProductID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find product: %w", err)
-		}
-		if product == nil {
-			return nil, fmt.Errorf("VAL_005: specified product does not exist")
-		}
-		if product.BusinessID.Hex() != businessID {
-			return nil, fmt.Errorf("product does not belong to this business")
-		}
-		objProductID = &id
+		lines = append(lines, Domain.NewSaleLine(
+			productID,
+			decimal.NewFromFloat(lineReq.Quantity),
+			decimal.NewFromFloat(lineReq.UnitPrice),
+			decimal.NewFromFloat(lineReq.Discount),
+		))
 	}
 
 	// Build sale domain object
-	sale := Domain.NewSale(
-		objBusinessID,
-		objProductID,
-		req.UnitPrice,
-		req.Quantity,
-		req.Note,
-	)
+	sale := Domain.NewSale(objBusinessID, lines, req.Note)
 
 	if err := sale.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid sale: %w", err)
 	}
 
+	// Take the stock first: the guarded decrement fails instead of overselling
+	// when concurrent sales compete for the last units
+	taken, err := uc.takeStock(sale, actor)
+	if err != nil {
+		return nil, fmt.Errorf("stock adjustment failed: %w", err)
+	}
+
 	// Persist the sale
 	if err := uc.salesRepo.Create(sale); err != nil {
+		uc.returnStock(sale, taken, "Sale failed – stock returned", actor)
 		return nil, fmt.Errorf("failed to create sale: %w", err)
 	}
 
-	// Adjust inventory (stock decrease) if a product was specified
-	if objProductID != nil {
-		referenceID := sale.ID.Hex()
-		if err := uc.inventoryRepo.AdjustStock(
-			objProductID.Hex(),
-			req.Quantity,
-			Domain.MovementTypeSale,
-			"Sale transaction",
-			&referenceID,
-			userID,
-		); err != nil {
-			return nil, fmt.Errorf("sale recorded but stock adjustment failed: %w", err)
-		}
-	}
+	recordAudit(uc.audit, Domain.NewAuditEntry(actor, sale.BusinessID, Domain.AuditEntitySale, sale.ID.Hex(), Domain.AuditActionCreate, nil, sale))
 
 	return uc.toSaleResponse(sale), nil
 }
@@ -166,7 +155,7 @@ func (uc *salesUseCase) GetSaleByID(id, businessID string) (*Domain.SaleResponse
 }
 
 // VoidSale marks a sale as voided and reverses the inventory stock if applicable
-func (uc *salesUseCase) VoidSale(id, businessID, userID string) error {
+func (uc *salesUseCase) VoidSale(id, businessID string, actor Domain.AuditActor) error {
 	sale, err := uc.salesRepo.FindByID(id)
 	if err != nil {
 		return fmt.Errorf("failed to find sale: %w", err)
@@ -186,26 +175,18 @@ func (uc *salesUseCase) VoidSale(id, businessID, userID string) error {
 		return fmt.Errorf("failed to void sale: %w", err)
 	}
 
-	// Reverse inventory: return stock if product was specified
-	if sale.ProductID != nil {
-		referenceID := sale.ID.Hex()
-		if err := uc.inventoryRepo.AdjustStock(
-			sale.ProductID.Hex(),
-			sale.Quantity,
-			Domain.MovementTypeReturn,
-			"Sale voided – stock returned",
-			&referenceID,
-			userID,
-		); err != nil {
-			fmt.Printf("WARNING: failed to reverse inventory for voided sale %s: %v\n", id, err)
-		}
-	}
+	voided := *sale
+	voided.IsVoided = true
+	recordAudit(uc.audit, Domain.NewAuditEntry(actor, sale.BusinessID, Domain.AuditEntitySale, id, Domain.AuditActionVoid, sale, &voided))
+
+	// Reverse inventory: return the stock of every line with a product
+	uc.returnStock(sale, sale.Lines, "Sale voided – stock returned", actor)
 
 	return nil
 }
 
 // UpdateSale updates the note of a sale (only allowed before sync)
-func (uc *salesUseCase) UpdateSale(id, businessID string, req Domain.UpdateSaleRequest) (*Domain.SaleResponse, error) {
+func (uc *salesUseCase) UpdateSale(id, businessID string, actor Domain.AuditActor, req Domain.UpdateSaleRequest) (*Domain.SaleResponse, error) {
 	sale, err := uc.salesRepo.FindByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find sale: %w", err)
@@ -229,7 +210,10 @@ func (uc *salesUseCase) UpdateSale(id, businessID string, req Domain.UpdateSaleR
 		return nil, fmt.Errorf("failed to update sale: %w", err)
 	}
 
+	before := *sale
 	sale.Note = note
+	recordAudit(uc.audit, Domain.NewAuditEntry(actor, sale.BusinessID, Domain.AuditEntitySale, id, Domain.AuditActionUpdate, &before, sale))
+
 	return uc.toSaleResponse(sale), nil
 }
 
@@ -312,20 +296,104 @@ func (uc *salesUseCase) GetSalesSummary(businessID string, startDateStr, endDate
 // Helpers
 // ──────────────────────────────────────────────
 
+// resolveSaleProduct checks that an optional line product exists and
+// belongs to the business
+func (uc *salesUseCase) resolveSaleProduct(businessID string, productID *string) (*primitive.ObjectID, error) {
+	if productID == nil || *productID == "" {
+		return nil, nil
+	}
+	id, err := primitive.ObjectIDFromHex(*productID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid product ID: %w", err)
+	}
+	product, err := uc.inventoryRepo.FindByID(*productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find product: %w", err)
+	}
+	if product == nil {
+		return nil, fmt.Errorf("VAL_005: specified product does not exist")
+	}
+	if product.BusinessID.Hex() != businessID {
+		return nil, fmt.Errorf("product does not belong to this business")
+	}
+	return &id, nil
+}
+
+// takeStock decrements stock for each line with a product and records the
+// cost of goods on the line. If a line cannot be taken, the lines already
+// taken are returned and the error is reported.
+func (uc *salesUseCase) takeStock(sale *Domain.Sale, actor Domain.AuditActor) ([]Domain.SaleLine, error) {
+	referenceID := sale.ID.Hex()
+	var taken []Domain.SaleLine
+	for i := range sale.Lines {
+		line := &sale.Lines[i]
+		if line.ProductID == nil {
+			continue
+		}
+		cost, err := uc.inventoryRepo.TakeStock(
+			line.ProductID.Hex(),
+			line.Quantity,
+			Domain.MovementTypeSale,
+			"Sale transaction",
+			&referenceID,
+			actor.UserID,
+		)
+		if err != nil {
+			uc.returnStock(sale, taken, "Sale failed – stock returned", actor)
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		line.Cost = cost
+		taken = append(taken, *line)
+	}
+	return taken, nil
+}
+
+// returnStock puts back the stock of the given lines of a sale, at the cost
+// each line took it out at. Failures are logged, not returned, since the
+// caller is already undoing or voiding.
+func (uc *salesUseCase) returnStock(sale *Domain.Sale, lines []Domain.SaleLine, reason string, actor Domain.AuditActor) {
+	referenceID := sale.ID.Hex()
+	for _, line := range lines {
+		if line.ProductID == nil {
+			continue
+		}
+		var err error
+		if line.Cost.IsPositive() {
+			unitCost := line.Cost.Div(line.Quantity).Round(4)
+			err = uc.inventoryRepo.ReceiveStock(line.ProductID.Hex(), line.Quantity, unitCost, Domain.MovementTypeReturn, reason, &referenceID, actor.UserID)
+		} else {
+			err = uc.inventoryRepo.AdjustStock(line.ProductID.Hex(), line.Quantity, Domain.MovementTypeReturn, reason, &referenceID, actor.UserID)
+		}
+		if err != nil {
+			fmt.Printf("WARNING: failed to return stock of product %s for sale %s: %v\n", line.ProductID.Hex(), referenceID, err)
+		}
+	}
+}
+
 func (uc *salesUseCase) toSaleResponse(sale *Domain.Sale) *Domain.SaleResponse {
 	resp := &Domain.SaleResponse{
-		ID:         sale.ID.Hex(),
-		BusinessID: sale.BusinessID.Hex(),
-		UnitPrice:  sale.UnitPrice,
-		Quantity:   sale.Quantity,
-		Total:      sale.Total,
-		Note:       sale.Note,
-		IsVoided:   sale.IsVoided,
-		CreatedAt:  sale.CreatedAt,
-	}
-	if sale.ProductID != nil {
-		s := sale.ProductID.Hex()
-		resp.ProductID = &s
+		ID:          sale.ID.Hex(),
+		BusinessID:  sale.BusinessID.Hex(),
+		Lines:       make([]Domain.SaleLineResponse, len(sale.Lines)),
+		Quantity:    sale.Quantity(),
+		Total:       sale.Total,
+		CostOfGoods: sale.CostOfGoods(),
+		Note:        sale.Note,
+		IsVoided:    sale.IsVoided,
+		CreatedAt:   sale.CreatedAt,
+	}
+	for i, line := range sale.Lines {
+		resp.Lines[i] = Domain.SaleLineResponse{
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			Discount:  line.Discount,
+			Total:     line.Total,
+			Cost:      line.Cost,
+		}
+		if line.ProductID != nil {
+			s := line.ProductID.Hex()
+			resp.Lines[i].ProductID = &s
+		}
 	}
 	return resp
 }
@@ -2,22 +2,51 @@ package usecases
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	domain "shop-ops/Domain"
 	repositories "shop-ops/Repositories"
+	"strconv"
 	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-const maxSyncBatchSize = 1000
+const (
+	maxSyncBatchSize     = 1000
+	defaultSyncPullLimit = 500
+	maxSyncPullLimit     = 1000
+	syncCursorPrefix     = "v1:"
+	syncRetryBatchSize   = 100
+
+	maxSyncUploadChunks       = 500
+	maxSyncUploadTransactions = 20000
+
+	defaultSyncHealthWindow = 30 * 24 * time.Hour
+	maxSyncHealthWindow     = 366 * 24 * time.Hour
+	defaultSyncSilentDays   = 7
+	maxSyncSilentDays       = 365
+	syncHealthTopFailures   = 10
+)
+
+// SyncRoleResolver looks up the role a user holds in a business now.
+type SyncRoleResolver interface {
+	ResolveRole(businessId string, userId string) (domain.BusinessRole, error)
+}
 
 // SyncUseCases orchestrates sync business logic.
 type SyncUseCases struct {
 	syncRepo repositories.SyncRepository
+	audit    AuditRecorder
+	roles    SyncRoleResolver
 }
 
-// NewSyncUseCases creates a new sync use case service.
-func NewSyncUseCases(syncRepo repositories.SyncRepository) *SyncUseCases {
-	return &SyncUseCases{syncRepo: syncRepo}
+// NewSyncUseCases creates a new sync use case service. roles is used for
+// work that runs after the request that queued it, such as retries.
+func NewSyncUseCases(syncRepo repositories.SyncRepository, audit AuditRecorder, roles SyncRoleResolver) *SyncUseCases {
+	return &SyncUseCases{syncRepo: syncRepo, audit: audit, roles: roles}
 }
 
 // SyncBatch validates and processes a sync batch.
@@ -34,23 +63,257 @@ func (uc *SyncUseCases) SyncBatch(req domain.SyncBatchRequest) (*domain.SyncBatc
 	if len(req.Transactions) > maxSyncBatchSize {
 		return nil, errors.New("maximum 1000 transactions per sync batch")
 	}
+	if !domain.IsSupportedSyncSchemaVersion(req.SchemaVersion) {
+		return nil, domain.ErrUnsupportedSchemaVersion
+	}
+	if err := validateSyncTransactions(req.Transactions, req.Role); err != nil {
+		return nil, err
+	}
+
+	result, err := uc.syncRepo.ProcessBatch(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	uc.auditSync(req, result)
+	return result, nil
+}
+
+// syncAuditActions maps each sync transaction type to the audit entry its
+// replay produces.
+var syncAuditActions = map[domain.SyncTransactionType]struct {
+	entity domain.AuditEntityType
+	action domain.AuditAction
+	ref    string
+}{
+	domain.SyncTransactionTypeSale:            {domain.AuditEntitySale, domain.AuditActionCreate, ""},
+	domain.SyncTransactionTypeExpense:         {domain.AuditEntityExpense, domain.AuditActionCreate, ""},
+	domain.SyncTransactionTypeProduct:         {domain.AuditEntityProduct, domain.AuditActionCreate, ""},
+	domain.SyncTransactionTypeStockAdjustment: {domain.AuditEntityProduct, domain.AuditActionAdjustStock, "product_id"},
+	domain.SyncTransactionTypeSaleVoid:        {domain.AuditEntitySale, domain.AuditActionVoid, ""},
+	domain.SyncTransactionTypeExpenseUpdate:   {domain.AuditEntityExpense, domain.AuditActionUpdate, ""},
+}
+
+// auditSync records every transaction a batch applied. Duplicates, conflicts
+// the server won and failures changed nothing and are skipped.
+func (uc *SyncUseCases) auditSync(req domain.SyncBatchRequest, result *domain.SyncBatchResponse) {
+	if uc.audit == nil {
+		return
+	}
+	businessID, err := primitive.ObjectIDFromHex(req.BusinessID)
+	if err != nil {
+		return
+	}
 
-	seenLocalIDs := make(map[string]struct{}, len(req.Transactions))
+	transactions := make(map[string]domain.SyncBatchTransaction, len(req.Transactions))
 	for _, tx := range req.Transactions {
+		transactions[tx.LocalID] = tx
+	}
+	actor := syncAuditActor(req.UserID, req.Role, req.RequestID, req.DeviceID)
+	for _, item := range result.Results {
+		if item.Status != "success" {
+			continue
+		}
+		tx, ok := transactions[item.LocalID]
+		if !ok {
+			continue
+		}
+		uc.audit.Record(syncAuditEntry(actor, businessID, tx, item))
+	}
+}
+
+// auditRetries records the items the retry queue applied, as the user and
+// device whose batch queued them.
+func (uc *SyncUseCases) auditRetries(outcomes []domain.SyncRetryOutcome) {
+	if uc.audit == nil {
+		return
+	}
+	for _, outcome := range outcomes {
+		if outcome.Result.Status != "success" {
+			continue
+		}
+		item := outcome.Item
+		actor := syncAuditActor(item.UserID, item.Role, item.RequestID, item.DeviceID)
+		tx := domain.SyncBatchTransaction{LocalID: item.LocalID, Type: item.Type, Data: item.Data}
+		uc.audit.Record(syncAuditEntry(actor, item.BusinessID, tx, outcome.Result))
+	}
+}
+
+func syncAuditActor(userID string, role domain.BusinessRole, requestID, deviceID string) domain.AuditActor {
+	return domain.AuditActor{
+		UserID:    userID,
+		Role:      role,
+		RequestID: requestID,
+		DeviceID:  deviceID,
+		Source:    domain.AuditSourceSync,
+	}
+}
+
+// syncAuditEntry builds the audit entry for an applied sync item. Mutations
+// diff the record before and after the change; creations log the payload.
+func syncAuditEntry(actor domain.AuditActor, businessID primitive.ObjectID, tx domain.SyncBatchTransaction, item domain.SyncItemResult) *domain.AuditEntry {
+	mapping := syncAuditActions[tx.Type]
+	entityID := item.ServerID
+	var before, after interface{} = nil, tx.Data
+	if mapping.ref != "" {
+		if ref, ok := tx.Data[mapping.ref].(string); ok && ref != "" {
+			entityID = ref
+		}
+	}
+	if applied := item.Applied; applied != nil {
+		entityID, before, after = applied.EntityID, applied.Before, applied.After
+	}
+	return domain.NewAuditEntry(actor, businessID, mapping.entity, entityID, mapping.action, before, after)
+}
+
+// validateSyncTransactions checks that every transaction has a unique
+// local_id and a type the server can replay. When role is set, every type
+// must also be one the role may record.
+func validateSyncTransactions(transactions []domain.SyncBatchTransaction, role domain.BusinessRole) error {
+	seenLocalIDs := make(map[string]struct{}, len(transactions))
+	for _, tx := range transactions {
 		localID := strings.TrimSpace(tx.LocalID)
 		if localID == "" {
-			return nil, errors.New("local_id is required for all transactions")
+			return errors.New("local_id is required for all transactions")
 		}
 		if _, exists := seenLocalIDs[localID]; exists {
-			return nil, errors.New("duplicate local_id in batch: " + localID)
+			return errors.New("duplicate local_id in batch: " + localID)
 		}
 		seenLocalIDs[localID] = struct{}{}
-		if tx.Type != domain.SyncTransactionTypeSale && tx.Type != domain.SyncTransactionTypeExpense {
-			return nil, errors.New("transaction type must be sale or expense")
+		if !domain.IsValidSyncTransactionType(tx.Type) {
+			return errors.New("transaction type must be one of sale, expense, product, stock_adjustment, sale_void, expense_update")
+		}
+		if role != "" && !role.Can(domain.SyncPermission(tx.Type)) {
+			return fmt.Errorf("%w: %s cannot sync %s transactions", domain.ErrPermissionDenied, role, tx.Type)
 		}
 	}
+	return nil
+}
+
+// StartUpload opens a resumable chunked upload for a large offline backlog.
+func (uc *SyncUseCases) StartUpload(req domain.StartSyncUploadRequest) (*domain.SyncUpload, error) {
+	if strings.TrimSpace(req.BusinessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if strings.TrimSpace(req.DeviceID) == "" {
+		return nil, errors.New("device_id is required")
+	}
+	if req.TotalChunks < 1 || req.TotalChunks > maxSyncUploadChunks {
+		return nil, errors.New("total_chunks must be between 1 and 500")
+	}
+	if !domain.IsSupportedSyncSchemaVersion(req.SchemaVersion) {
+		return nil, domain.ErrUnsupportedSchemaVersion
+	}
 
-	return uc.syncRepo.ProcessBatch(context.Background(), req)
+	businessID, err := primitive.ObjectIDFromHex(req.BusinessID)
+	if err != nil {
+		return nil, errors.New("invalid business_id")
+	}
+
+	upload := &domain.SyncUpload{
+		BusinessID:    businessID,
+		DeviceID:      req.DeviceID,
+		UserID:        req.UserID,
+		TotalChunks:   req.TotalChunks,
+		Atomic:        req.Atomic,
+		SchemaVersion: req.SchemaVersion,
+		SyncTimestamp: req.SyncTimestamp,
+	}
+	if err := uc.syncRepo.CreateUpload(context.Background(), upload); err != nil {
+		return nil, err
+	}
+	upload.MissingChunks = upload.Missing()
+	return upload, nil
+}
+
+// GetUpload reports which chunks of an upload have arrived.
+func (uc *SyncUseCases) GetUpload(businessID, uploadID string) (*domain.SyncUpload, error) {
+	if strings.TrimSpace(businessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	upload, err := uc.syncRepo.GetUpload(context.Background(), businessID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	upload.MissingChunks = upload.Missing()
+	return upload, nil
+}
+
+// UploadChunk stores one chunk of an upload. Chunks may arrive in any order
+// and a chunk sent twice replaces the earlier copy.
+func (uc *SyncUseCases) UploadChunk(uploadID string, index int, req domain.SyncUploadChunkRequest) (*domain.SyncUpload, error) {
+	if strings.TrimSpace(req.BusinessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if len(req.Transactions) == 0 {
+		return nil, errors.New("transactions cannot be empty")
+	}
+	if len(req.Transactions) > maxSyncBatchSize {
+		return nil, errors.New("maximum 1000 transactions per upload chunk")
+	}
+	if err := validateSyncTransactions(req.Transactions, req.Role); err != nil {
+		return nil, err
+	}
+
+	upload, err := uc.syncRepo.SaveUploadChunk(context.Background(), req.BusinessID, uploadID, index, req.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	upload.MissingChunks = upload.Missing()
+	return upload, nil
+}
+
+// CommitUpload processes every chunk of a complete upload as one sync batch.
+// If the upload cannot be processed it is reopened so the device can resend
+// the offending chunk and commit again.
+func (uc *SyncUseCases) CommitUpload(uploadID string, req domain.CommitSyncUploadRequest) (*domain.SyncBatchResponse, error) {
+	if strings.TrimSpace(req.BusinessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+
+	ctx := context.Background()
+	upload, transactions, err := uc.syncRepo.ClaimUpload(ctx, req.BusinessID, uploadID, req.UserID, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The batch runs as the user who started the upload, with the role that
+	// user holds now rather than when the chunks arrived
+	role := req.Role
+	if uc.roles != nil {
+		role, err = uc.currentRole(req.BusinessID, upload.UserID)
+	}
+	if err == nil && len(transactions) > maxSyncUploadTransactions {
+		err = domain.ErrSyncUploadTooLarge
+	} else if err == nil {
+		err = validateSyncTransactions(transactions, role)
+	}
+	if err != nil {
+		_ = uc.syncRepo.ReleaseUpload(ctx, uploadID, "")
+		return nil, err
+	}
+
+	batch := domain.SyncBatchRequest{
+		BusinessID:    req.BusinessID,
+		DeviceID:      upload.DeviceID,
+		SyncTimestamp: upload.SyncTimestamp,
+		SchemaVersion: upload.SchemaVersion,
+		Transactions:  transactions,
+		Atomic:        upload.Atomic,
+		UserID:        upload.UserID,
+		Role:          role,
+		RequestID:     req.RequestID,
+	}
+	result, err := uc.syncRepo.ProcessBatch(ctx, batch)
+	if err != nil {
+		_ = uc.syncRepo.ReleaseUpload(ctx, uploadID, "")
+		return nil, err
+	}
+	uc.auditSync(batch, result)
+
+	if err := uc.syncRepo.ReleaseUpload(ctx, uploadID, result.SyncID); err != nil {
+		fmt.Printf("WARNING: failed to mark sync upload %s committed: %v\n", uploadID, err)
+	}
+	return result, nil
 }
 
 // GetStatus fetches current sync status for a business.
@@ -77,3 +340,285 @@ func (uc *SyncUseCases) GetHistory(businessID string, page, limit int) (*domain.
 	}
 	return uc.syncRepo.GetHistory(context.Background(), businessID, page, limit)
 }
+
+// GetHealth builds the sync health report for a business. The window defaults
+// to the last 30 days and devices count as silent after 7 days without a sync.
+func (uc *SyncUseCases) GetHealth(query domain.SyncHealthQuery) (*domain.SyncHealthReport, error) {
+	if strings.TrimSpace(query.BusinessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if query.To.IsZero() {
+		query.To = time.Now().UTC()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.Add(-defaultSyncHealthWindow)
+	}
+	if !query.From.Before(query.To) {
+		return nil, errors.New("from must be before to")
+	}
+	if query.To.Sub(query.From) > maxSyncHealthWindow {
+		return nil, errors.New("the report window cannot exceed 366 days")
+	}
+	if query.SilentDays == 0 {
+		query.SilentDays = defaultSyncSilentDays
+	}
+	if query.SilentDays < 1 || query.SilentDays > maxSyncSilentDays {
+		return nil, errors.New("silent_days must be between 1 and 365")
+	}
+	query.TopFailures = syncHealthTopFailures
+	return uc.syncRepo.GetHealth(context.Background(), query)
+}
+
+// Pull returns the server-side changes a device has not seen yet, without
+// what the caller's role may not see.
+func (uc *SyncUseCases) Pull(req domain.SyncPullRequest) (*domain.SyncPullResponse, error) {
+	if strings.TrimSpace(req.BusinessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if strings.TrimSpace(req.DeviceID) == "" {
+		return nil, errors.New("device_id is required")
+	}
+
+	limit := req.Limit
+	if limit < 1 {
+		limit = defaultSyncPullLimit
+	}
+	if limit > maxSyncPullLimit {
+		limit = maxSyncPullLimit
+	}
+
+	var cursor *domain.SyncPullCursor
+	if strings.TrimSpace(req.Cursor) != "" {
+		decoded, err := decodeSyncCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = &decoded
+	}
+
+	result, next, err := uc.syncRepo.PullChanges(context.Background(), req.BusinessID, req.DeviceID, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result.RedactFor(req.Role)
+	result.NextCursor = encodeSyncCursor(next)
+	if result.ServerTime.IsZero() {
+		result.ServerTime = time.Now().UTC()
+	}
+	return result, nil
+}
+
+// ListConflicts fetches stored sync conflicts for a business with pagination.
+func (uc *SyncUseCases) ListConflicts(businessID string, status domain.SyncConflictStatus, page, limit int) (*domain.SyncConflictList, error) {
+	if strings.TrimSpace(businessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if status != "" && status != domain.SyncConflictOpen && status != domain.SyncConflictResolved {
+		return nil, errors.New("status must be open or resolved")
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return uc.syncRepo.ListConflicts(context.Background(), businessID, status, page, limit)
+}
+
+// ResolveConflict decides an open conflict in favour of the server or the client.
+func (uc *SyncUseCases) ResolveConflict(conflictID, userID string, req domain.ResolveSyncConflictRequest) (*domain.SyncConflict, error) {
+	if strings.TrimSpace(req.BusinessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if req.Resolution != domain.SyncResolutionServer && req.Resolution != domain.SyncResolutionClient {
+		return nil, domain.ErrInvalidResolution
+	}
+	conflict, err := uc.syncRepo.ResolveConflict(context.Background(), req.BusinessID, conflictID, userID, req.Resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolving for the client applies the device's change, which is audited
+	// as the user and device that sent it
+	if req.Resolution == domain.SyncResolutionClient && uc.audit != nil {
+		actor := syncAuditActor(conflict.UserID, conflict.Role, conflict.RequestID, conflict.DeviceID)
+		tx := domain.SyncBatchTransaction{LocalID: conflict.LocalID, Type: conflict.Type, Data: conflict.Info.Client}
+		item := domain.SyncItemResult{LocalID: conflict.LocalID, ServerID: conflict.Info.EntityID, Status: "success", Applied: conflict.Applied}
+		entry := syncAuditEntry(actor, conflict.BusinessID, tx, item)
+		entry.Reason = fmt.Sprintf("sync conflict %s resolved for the client by %s", conflict.ID.Hex(), userID)
+		uc.audit.Record(entry)
+	}
+	return conflict, nil
+}
+
+// GetItemHistory returns the sync lineage of a single local_id.
+func (uc *SyncUseCases) GetItemHistory(businessID, deviceID, localID string) (*domain.SyncItemHistory, error) {
+	if strings.TrimSpace(businessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if strings.TrimSpace(deviceID) == "" {
+		return nil, errors.New("device_id is required")
+	}
+	if strings.TrimSpace(localID) == "" {
+		return nil, errors.New("local_id is required")
+	}
+	return uc.syncRepo.GetItemHistory(context.Background(), businessID, deviceID, localID)
+}
+
+// ListRetries fetches retry queue items for a business with pagination.
+func (uc *SyncUseCases) ListRetries(businessID, deviceID string, state domain.SyncRetryState, page, limit int) (*domain.SyncRetryList, error) {
+	if strings.TrimSpace(businessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if state != "" && !domain.IsValidSyncRetryState(state) {
+		return nil, errors.New("state must be one of pending, succeeded, dead_letter, acked, discarded")
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return uc.syncRepo.ListRetries(context.Background(), businessID, deviceID, state, page, limit)
+}
+
+// AckRetries lets a device confirm it has seen the outcome of finished items,
+// removing them from its queue view.
+func (uc *SyncUseCases) AckRetries(req domain.SyncRetryActionRequest) (*domain.SyncRetryActionResponse, error) {
+	from := []domain.SyncRetryState{domain.SyncRetrySucceeded, domain.SyncRetryDeadLetter}
+	return uc.updateRetryState(req, from, domain.SyncRetryAcked)
+}
+
+// DiscardRetries lets a device give up on items that are still queued or
+// dead-lettered.
+func (uc *SyncUseCases) DiscardRetries(req domain.SyncRetryActionRequest) (*domain.SyncRetryActionResponse, error) {
+	from := []domain.SyncRetryState{domain.SyncRetryPending, domain.SyncRetryDeadLetter}
+	return uc.updateRetryState(req, from, domain.SyncRetryDiscarded)
+}
+
+func (uc *SyncUseCases) updateRetryState(req domain.SyncRetryActionRequest, from []domain.SyncRetryState, to domain.SyncRetryState) (*domain.SyncRetryActionResponse, error) {
+	if strings.TrimSpace(req.BusinessID) == "" {
+		return nil, errors.New("business_id is required")
+	}
+	if strings.TrimSpace(req.DeviceID) == "" {
+		return nil, errors.New("device_id is required")
+	}
+	if len(req.LocalIDs) == 0 {
+		return nil, errors.New("local_ids cannot be empty")
+	}
+	if len(req.LocalIDs) > maxSyncBatchSize {
+		return nil, errors.New("maximum 1000 local_ids per request")
+	}
+
+	updated, err := uc.syncRepo.UpdateRetryState(context.Background(), req.BusinessID, req.DeviceID, req.LocalIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.SyncRetryActionResponse{Updated: updated}, nil
+}
+
+// ProcessRetries attempts every queued item that is due, in chunks, and
+// returns how many were attempted.
+func (uc *SyncUseCases) ProcessRetries(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		outcomes, err := uc.syncRepo.ProcessRetries(ctx, time.Now().UTC(), syncRetryBatchSize, uc.authorizeRetry)
+		total += len(outcomes)
+		uc.auditRetries(outcomes)
+		if err != nil || len(outcomes) < syncRetryBatchSize {
+			return total, err
+		}
+	}
+}
+
+// authorizeRetry resolves the role a queued item's user holds now, so a retry
+// never runs with authority the user has lost since the item was queued.
+func (uc *SyncUseCases) authorizeRetry(item *domain.SyncRetryItem) (domain.BusinessRole, error) {
+	if uc.roles == nil {
+		return item.Role, nil
+	}
+	role, err := uc.currentRole(item.BusinessID.Hex(), item.UserID)
+	if err != nil {
+		return "", err
+	}
+	if !role.Can(domain.SyncPermission(item.Type)) {
+		return role, fmt.Errorf("%w: %s cannot sync %s transactions", domain.ErrPermissionDenied, role, item.Type)
+	}
+	return role, nil
+}
+
+// currentRole resolves the role a user holds in a business now. A user who
+// has left the business is denied rather than reported as missing.
+func (uc *SyncUseCases) currentRole(businessID, userID string) (domain.BusinessRole, error) {
+	role, err := uc.roles.ResolveRole(businessID, userID)
+	if errors.Is(err, domain.ErrNotMember) || errors.Is(err, domain.ErrBusinessNotFound) {
+		return "", fmt.Errorf("%w: user is no longer a member of this business", domain.ErrPermissionDenied)
+	}
+	return role, err
+}
+
+// RunRetryWorker processes the retry queue every interval until ctx is done.
+// It also rolls back atomic batches a crash left half-applied.
+func (uc *SyncUseCases) RunRetryWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := uc.syncRepo.RecoverJournals(ctx); err != nil {
+				fmt.Printf("WARNING: sync retry worker: %v\n", err)
+			}
+			if _, err := uc.ProcessRetries(ctx); err != nil {
+				fmt.Printf("WARNING: sync retry worker: %v\n", err)
+			}
+		}
+	}
+}
+
+// encodeSyncCursor makes the opaque cursor for a pull position: the feed
+// sequence, followed by the entity and last record while a snapshot is paged.
+func encodeSyncCursor(cursor domain.SyncPullCursor) string {
+	value := syncCursorPrefix + strconv.FormatInt(cursor.Seq, 10)
+	if cursor.Snapshot != "" {
+		value += ":" + string(cursor.Snapshot) + ":" + cursor.After.Hex()
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeSyncCursor(encoded string) (domain.SyncPullCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return domain.SyncPullCursor{}, domain.ErrInvalidSyncCursor
+	}
+	value := string(raw)
+	if !strings.HasPrefix(value, syncCursorPrefix) {
+		return domain.SyncPullCursor{}, domain.ErrInvalidSyncCursor
+	}
+	parts := strings.Split(strings.TrimPrefix(value, syncCursorPrefix), ":")
+	if len(parts) != 1 && len(parts) != 3 {
+		return domain.SyncPullCursor{}, domain.ErrInvalidSyncCursor
+	}
+
+	var cursor domain.SyncPullCursor
+	cursor.Seq, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || cursor.Seq < 0 {
+		return domain.SyncPullCursor{}, domain.ErrInvalidSyncCursor
+	}
+	if len(parts) == 3 {
+		cursor.Snapshot = domain.ChangeEntity(parts[1])
+		if cursor.After, err = primitive.ObjectIDFromHex(parts[2]); err != nil || cursor.Snapshot == "" {
+			return domain.SyncPullCursor{}, domain.ErrInvalidSyncCursor
+		}
+	}
+	return cursor, nil
+}
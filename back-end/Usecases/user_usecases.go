@@ -2,13 +2,16 @@ package usecases
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	domain "shop-ops/Domain"
 	repositories "shop-ops/Repositories"
 
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Service interfaces needed by UserUseCases
@@ -18,16 +21,29 @@ type PasswordService interface {
 }
 
 type JWTService interface {
-	GenerateToken(userId string) (string, error)
-	GenerateRefreshToken(userId string) (string, error)
+	GenerateToken(userId, sessionId string) (string, error)
+	GenerateRefreshToken(userId, tokenId, familyId string) (string, error)
 	ValidateToken(tokenString string) (*jwt.Token, error)
 }
 
+// Notifier delivers messages such as one-time codes by SMS or email.
+type Notifier interface {
+	Send(message domain.Notification) error
+}
+
+// InvitationRedeemer lets a new user join the business that invited them.
+type InvitationRedeemer interface {
+	ValidateInvitation(code string, phone string) (*domain.Invitation, error)
+	RedeemByID(invitationId primitive.ObjectID, user *domain.User) error
+}
+
 type RegisterRequest struct {
 	Name     string `json:"name"`
 	Phone    string `json:"phone"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// InviteCode joins the new user to the business that issued it
+	InviteCode string `json:"invite_code,omitempty"`
 }
 
 type LoginResponse struct {
@@ -51,10 +67,37 @@ type ChangePhoneRequest struct {
 	NewPhone        string `json:"new_phone"`
 }
 
+// ForgotPasswordRequest names the account by phone or, failing that, email.
+type ForgotPasswordRequest struct {
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Phone       string `json:"phone"`
+	Email       string `json:"email"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+const phoneVerificationMessage = "Your Shop Ops verification code is %s. It expires in 10 minutes."
+
 type UserUseCases interface {
 	Register(req *RegisterRequest) (*domain.User, error)
-	Login(phone, password string) (*LoginResponse, error)
-	RefreshToken(refreshToken string) (*LoginResponse, error)
+	Login(phone, password string, client domain.SessionClient) (*LoginResponse, error)
+	RefreshToken(refreshToken string, client domain.SessionClient) (*LoginResponse, error)
+	Logout(refreshToken string) error
+	LogoutAll(userId string) (int64, error)
+	ListSessions(userId string, currentSessionId string) ([]*domain.Session, error)
+	RevokeSession(userId string, sessionId string) error
+	IsSessionActive(sessionId string) (bool, error)
+	ForgotPassword(req *ForgotPasswordRequest) error
+	ResetPassword(req *ResetPasswordRequest) error
+	SendPhoneVerification(phone string) error
+	VerifyPhone(phone, code string) (*domain.User, error)
+	ConfirmPhoneChange(userId string, code string) (*domain.User, error)
+	RequestLoginCode(phone string) error
+	LoginWithOTP(phone, code string, client domain.SessionClient) (*LoginResponse, error)
 	GetProfile(userId string) (*domain.User, error)
 	UpdateProfile(userId string, req *UpdateProfileRequest) (*domain.User, error)
 	ChangePassword(userId string, req *ChangePasswordRequest) error
@@ -62,16 +105,26 @@ type UserUseCases interface {
 }
 
 type userUseCases struct {
-	userRepo   repositories.UserRepository
-	pwdService PasswordService
-	jwtService JWTService
+	userRepo         repositories.UserRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	sessionRepo      repositories.SessionRepository
+	otpRepo          repositories.OTPRepository
+	pwdService       PasswordService
+	jwtService       JWTService
+	notifier         Notifier
+	invitations      InvitationRedeemer
 }
 
-func NewUserUseCases(userRepo repositories.UserRepository, pwdService PasswordService, jwtService JWTService) UserUseCases {
+func NewUserUseCases(userRepo repositories.UserRepository, refreshTokenRepo repositories.RefreshTokenRepository, sessionRepo repositories.SessionRepository, otpRepo repositories.OTPRepository, pwdService PasswordService, jwtService JWTService, notifier Notifier, invitations InvitationRedeemer) UserUseCases {
 	return &userUseCases{
-		userRepo:   userRepo,
-		pwdService: pwdService,
-		jwtService: jwtService,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		otpRepo:          otpRepo,
+		pwdService:       pwdService,
+		jwtService:       jwtService,
+		notifier:         notifier,
+		invitations:      invitations,
 	}
 }
 
@@ -90,6 +143,19 @@ func (u *userUseCases) Register(req *RegisterRequest) (*domain.User, error) {
 		}
 	}
 
+	// Check the invitation before creating the account so a bad code fails cleanly
+	var invitation *domain.Invitation
+	if req.InviteCode != "" {
+		if u.invitations == nil {
+			return nil, domain.ErrInvitationNotFound
+		}
+		var err error
+		invitation, err = u.invitations.ValidateInvitation(req.InviteCode, req.Phone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Hash password
 	hashedPwd, err := u.pwdService.Hash(req.Password)
 	if err != nil {
@@ -100,15 +166,31 @@ func (u *userUseCases) Register(req *RegisterRequest) (*domain.User, error) {
 	if err := userInfo.Validate(); err != nil {
 		return nil, err
 	}
+	if invitation != nil {
+		// The phone is not proven yet, so the user joins when it is verified
+		userInfo.PendingInvitationID = &invitation.ID
+	}
 
 	if err := u.userRepo.Save(userInfo); err != nil {
 		return nil, err
 	}
 
+	// The account works without it, but the user is asked to confirm the phone
+	if userInfo.Phone != "" {
+		if err := u.sendOTP(userInfo, domain.OTPPurposePhoneVerification, domain.NotificationSMS, userInfo.Phone, phoneVerificationMessage); err != nil {
+			fmt.Printf("WARNING: failed to send phone verification code to user %s: %v\n", userInfo.ID.Hex(), err)
+		}
+	}
+
 	return userInfo, nil
 }
 
-func (u *userUseCases) Login(phone, password string) (*LoginResponse, error) {
+// Login signs in with the password. Accounts whose phone is not verified yet
+// may sign in to their own account and businesses, but cannot join another
+// business, by invitation or by being added, or sign in by code until the
+// phone is verified. The returned user's phone_verified tells the client to
+// ask for the code.
+func (u *userUseCases) Login(phone, password string, client domain.SessionClient) (*LoginResponse, error) {
 	user, err := u.userRepo.FindByPhone(phone)
 	if err != nil || user == nil {
 		return nil, errors.New("invalid credentials")
@@ -118,13 +200,140 @@ func (u *userUseCases) Login(phone, password string) (*LoginResponse, error) {
 		return nil, errors.New("invalid credentials")
 	}
 
-	return u.generateTokens(user)
+	return u.startSession(user, client)
+}
+
+// startSession signs user in on a new device.
+func (u *userUseCases) startSession(user *domain.User, client domain.SessionClient) (*LoginResponse, error) {
+	// Every login starts a new session, whose ID names its refresh token family
+	session := domain.NewSession(user.ID, client)
+	if err := u.sessionRepo.Save(session); err != nil {
+		return nil, err
+	}
+	return u.issueTokens(user, domain.NewRefreshToken(user.ID, session.ID))
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. Each refresh
+// token works once: presenting a used one again revokes its whole family,
+// logging out both the attacker and the legitimate device.
+func (u *userUseCases) RefreshToken(refreshToken string, client domain.SessionClient) (*LoginResponse, error) {
+	stored, err := u.findRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if stored.RevokedAt != nil || !stored.ExpiresAt.After(time.Now()) {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+	if stored.UsedAt != nil {
+		u.revokeFamily(stored)
+		return nil, domain.ErrRefreshTokenReused
+	}
+
+	user, err := u.userRepo.FindById(stored.UserID.Hex())
+	if err != nil || user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	next := domain.NewRefreshToken(user.ID, stored.FamilyID)
+	if err := u.refreshTokenRepo.MarkUsed(stored.ID, next.ID, time.Now()); err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenReused) {
+			u.revokeFamily(stored)
+		}
+		return nil, err
+	}
+	if err := u.sessionRepo.Rotate(stored.FamilyID, client, next.ExpiresAt, time.Now()); err != nil {
+		fmt.Printf("WARNING: failed to update session %s: %v\n", stored.FamilyID.Hex(), err)
+	}
+
+	return u.issueTokens(user, next)
+}
+
+// Logout ends the login the refresh token belongs to.
+func (u *userUseCases) Logout(refreshToken string) error {
+	stored, err := u.findRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	return u.endFamily(stored)
+}
+
+// LogoutAll ends every session of the user and reports how many were active.
+func (u *userUseCases) LogoutAll(userId string) (int64, error) {
+	now := time.Now()
+	revoked, err := u.sessionRepo.RevokeAllForUser(userId, now)
+	if err != nil {
+		return 0, err
+	}
+	if err := u.refreshTokenRepo.RevokeAllForUser(userId, now); err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
+
+// ListSessions returns the user's active sessions, flagging the one the
+// request was made with.
+func (u *userUseCases) ListSessions(userId string, currentSessionId string) ([]*domain.Session, error) {
+	sessions, err := u.sessionRepo.FindActiveByUser(userId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID.Hex() == currentSessionId
+	}
+	return sessions, nil
 }
 
-func (u *userUseCases) RefreshToken(refreshToken string) (*LoginResponse, error) {
+// RevokeSession signs one of the user's devices out. Its refresh token stops
+// working at once and AuthMiddleware rejects its access token.
+func (u *userUseCases) RevokeSession(userId string, sessionId string) error {
+	sID, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return domain.ErrSessionNotFound
+	}
+	// Revoking the session first checks that it belongs to the user
+	if err := u.sessionRepo.Revoke(userId, sessionId, time.Now()); err != nil {
+		return err
+	}
+	return u.refreshTokenRepo.RevokeFamily(sID, time.Now())
+}
+
+// IsSessionActive reports whether access tokens of the session are still
+// honoured. AuthMiddleware calls it on every request, so an active session
+// also records its use there.
+func (u *userUseCases) IsSessionActive(sessionId string) (bool, error) {
+	session, err := u.sessionRepo.FindByID(sessionId)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if session == nil || !session.ActiveAt(now) {
+		return false, nil
+	}
+
+	if now.Sub(session.LastUsedAt) >= domain.SessionTouchInterval {
+		if err := u.sessionRepo.Touch(session.ID, now); err != nil {
+			fmt.Printf("WARNING: failed to record use of session %s: %v\n", session.ID.Hex(), err)
+		}
+	}
+	return true, nil
+}
+
+// endFamily ends the session of stored and every token rotated within it.
+// Ending an already ended session is not an error.
+func (u *userUseCases) endFamily(stored *domain.RefreshToken) error {
+	now := time.Now()
+	if err := u.sessionRepo.Revoke(stored.UserID.Hex(), stored.FamilyID.Hex(), now); err != nil && !errors.Is(err, domain.ErrSessionNotFound) {
+		return err
+	}
+	return u.refreshTokenRepo.RevokeFamily(stored.FamilyID, now)
+}
+
+// findRefreshToken checks the token's signature and type and loads its
+// server-side record.
+func (u *userUseCases) findRefreshToken(refreshToken string) (*domain.RefreshToken, error) {
 	token, err := u.jwtService.ValidateToken(refreshToken)
 	if err != nil || !token.Valid {
-		return nil, errors.New("invalid refresh token")
+		return nil, domain.ErrInvalidRefreshToken
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -142,25 +351,45 @@ func (u *userUseCases) RefreshToken(refreshToken string) (*LoginResponse, error)
 		return nil, errors.New("invalid user id in token")
 	}
 
-	user, err := u.userRepo.FindById(userId)
-	if err != nil || user == nil {
-		return nil, errors.New("user not found")
+	// Tokens issued before rotation have no jti and are no longer accepted
+	tokenId, _ := claims["jti"].(string)
+	if tokenId == "" {
+		return nil, domain.ErrInvalidRefreshToken
 	}
 
-	return u.generateTokens(user)
+	stored, err := u.refreshTokenRepo.FindByID(tokenId)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.UserID.Hex() != userId {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+	return stored, nil
 }
 
-func (u *userUseCases) generateTokens(user *domain.User) (*LoginResponse, error) {
-	token, err := u.jwtService.GenerateToken(user.ID.Hex())
+// revokeFamily ends the session whose refresh token was replayed.
+func (u *userUseCases) revokeFamily(stored *domain.RefreshToken) {
+	if err := u.endFamily(stored); err != nil {
+		fmt.Printf("WARNING: failed to revoke refresh token family %s: %v\n", stored.FamilyID.Hex(), err)
+	}
+}
+
+// issueTokens stores next and returns it with a fresh access token.
+func (u *userUseCases) issueTokens(user *domain.User, next *domain.RefreshToken) (*LoginResponse, error) {
+	token, err := u.jwtService.GenerateToken(user.ID.Hex(), next.FamilyID.Hex())
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := u.jwtService.GenerateRefreshToken(user.ID.Hex())
+	refreshToken, err := u.jwtService.GenerateRefreshToken(user.ID.Hex(), next.ID.Hex(), next.FamilyID.Hex())
 	if err != nil {
 		return nil, err
 	}
 
+	if err := u.refreshTokenRepo.Save(next); err != nil {
+		return nil, err
+	}
+
 	return &LoginResponse{
 		Token:        token,
 		RefreshToken: refreshToken,
@@ -234,6 +463,191 @@ func (u *userUseCases) ChangePassword(userId string, req *ChangePasswordRequest)
 	return u.userRepo.Update(user)
 }
 
+// ForgotPassword sends a reset code to the account's phone or email. It
+// succeeds whether or not the account exists, so it cannot be used to find
+// out who is registered.
+func (u *userUseCases) ForgotPassword(req *ForgotPasswordRequest) error {
+	user, channel, err := u.findByContact(req.Phone, req.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	destination := user.Phone
+	if channel == domain.NotificationEmail {
+		destination = user.Email
+	}
+	return u.sendOTP(user, domain.OTPPurposePasswordReset, channel, destination, "Your Shop Ops password reset code is %s. It expires in 10 minutes.")
+}
+
+// ResetPassword sets a new password using a code from ForgotPassword and
+// signs the account out everywhere.
+func (u *userUseCases) ResetPassword(req *ResetPasswordRequest) error {
+	if len(req.NewPassword) < 8 {
+		return errors.New("new password must be at least 8 characters")
+	}
+
+	user, _, err := u.findByContact(req.Phone, req.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return domain.ErrInvalidOTP
+	}
+
+	if _, err := u.verifyOTP(user, domain.OTPPurposePasswordReset, req.Code); err != nil {
+		return err
+	}
+
+	hashedPwd, err := u.pwdService.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hashedPwd
+	user.UpdatedAt = time.Now()
+	if err := u.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	if _, err := u.LogoutAll(user.ID.Hex()); err != nil {
+		fmt.Printf("WARNING: failed to end sessions after password reset for user %s: %v\n", user.ID.Hex(), err)
+	}
+	return nil
+}
+
+// SendPhoneVerification (re)sends the code that confirms a registered phone.
+// Unknown and already verified numbers are ignored.
+func (u *userUseCases) SendPhoneVerification(phone string) error {
+	user, err := u.userRepo.FindByPhone(strings.TrimSpace(phone))
+	if err != nil {
+		return err
+	}
+	if user == nil || user.PhoneVerified {
+		return nil
+	}
+	return u.sendOTP(user, domain.OTPPurposePhoneVerification, domain.NotificationSMS, user.Phone, phoneVerificationMessage)
+}
+
+// VerifyPhone marks the phone verified using the code sent at registration.
+func (u *userUseCases) VerifyPhone(phone, code string) (*domain.User, error) {
+	user, err := u.userRepo.FindByPhone(strings.TrimSpace(phone))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrInvalidOTP
+	}
+	if user.PhoneVerified {
+		return user, nil
+	}
+
+	if _, err := u.verifyOTP(user, domain.OTPPurposePhoneVerification, code); err != nil {
+		return nil, err
+	}
+	pending := user.PendingInvitationID
+	user.PendingInvitationID = nil
+	if err := u.markPhoneVerified(user); err != nil {
+		return nil, err
+	}
+
+	// The user can still join with the code if the invitation is gone by now
+	if pending != nil && u.invitations != nil {
+		if err := u.invitations.RedeemByID(*pending, user); err != nil {
+			fmt.Printf("WARNING: failed to redeem invitation %s for user %s: %v\n", pending.Hex(), user.ID.Hex(), err)
+		}
+	}
+	return user, nil
+}
+
+// ConfirmPhoneChange switches the account to the number ChangePhone sent a
+// code to. The number is checked for uniqueness again, since another account
+// may have taken it in the meantime.
+func (u *userUseCases) ConfirmPhoneChange(userId string, code string) (*domain.User, error) {
+	user, err := u.userRepo.FindById(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	otp, err := u.verifyOTP(user, domain.OTPPurposePhoneChange, code)
+	if err != nil {
+		return nil, err
+	}
+
+	existingUser, _ := u.userRepo.FindByPhone(otp.Destination)
+	if existingUser != nil && existingUser.ID != user.ID {
+		return nil, errors.New("user with this phone already exists")
+	}
+
+	user.Phone = otp.Destination
+	if err := u.markPhoneVerified(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RequestLoginCode sends a passwordless login code. Only verified phones can
+// sign in this way; other numbers are ignored without saying so.
+func (u *userUseCases) RequestLoginCode(phone string) error {
+	user, err := u.userRepo.FindByPhone(strings.TrimSpace(phone))
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.PhoneVerified {
+		return nil
+	}
+	return u.sendOTP(user, domain.OTPPurposeLogin, domain.NotificationSMS, user.Phone, "Your Shop Ops login code is %s. It expires in 10 minutes. Never share it.")
+}
+
+// LoginWithOTP signs in with a code from RequestLoginCode instead of the
+// password.
+func (u *userUseCases) LoginWithOTP(phone, code string, client domain.SessionClient) (*LoginResponse, error) {
+	user, err := u.userRepo.FindByPhone(strings.TrimSpace(phone))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.PhoneVerified {
+		return nil, domain.ErrInvalidOTP
+	}
+
+	if _, err := u.verifyOTP(user, domain.OTPPurposeLogin, code); err != nil {
+		return nil, err
+	}
+	return u.startSession(user, client)
+}
+
+func (u *userUseCases) markPhoneVerified(user *domain.User) error {
+	now := time.Now()
+	user.PhoneVerified = true
+	user.PhoneVerifiedAt = &now
+	user.UpdatedAt = now
+	return u.userRepo.Update(user)
+}
+
+// findByContact looks the user up by phone, or by email when no phone is
+// given, and reports which channel reaches them.
+func (u *userUseCases) findByContact(phone, email string) (*domain.User, domain.NotificationChannel, error) {
+	phone = strings.TrimSpace(phone)
+	email = strings.TrimSpace(email)
+	switch {
+	case phone != "":
+		user, err := u.userRepo.FindByPhone(phone)
+		return user, domain.NotificationSMS, err
+	case email != "":
+		user, err := u.userRepo.FindByEmail(email)
+		return user, domain.NotificationEmail, err
+	default:
+		return nil, "", errors.New("phone or email is required")
+	}
+}
+
+// ChangePhone checks the password and the new number, then sends a code to
+// the new number. The phone only changes once ConfirmPhoneChange gets that
+// code, proving the user receives SMS there.
 func (u *userUseCases) ChangePhone(userId string, req *ChangePhoneRequest) (*domain.User, error) {
 	user, err := u.userRepo.FindById(userId)
 	if err != nil {
@@ -254,18 +668,34 @@ func (u *userUseCases) ChangePhone(userId string, req *ChangePhoneRequest) (*dom
 		return nil, errors.New("invalid phone format")
 	}
 
+	if user.Phone == req.NewPhone {
+		return nil, errors.New("new phone must differ from the current phone")
+	}
+
 	// Check uniqueness
-	if user.Phone != req.NewPhone {
-		existingUser, _ := u.userRepo.FindByPhone(req.NewPhone)
-		if existingUser != nil {
-			return nil, errors.New("user with this phone already exists")
-		}
+	existingUser, _ := u.userRepo.FindByPhone(req.NewPhone)
+	if existingUser != nil {
+		return nil, errors.New("user with this phone already exists")
 	}
 
-	user.Phone = req.NewPhone
-	user.UpdatedAt = time.Now()
+	// sendOTP keeps a recent or burned code instead of sending a new one.
+	// When that code went to another number, the new number would never get
+	// one, so the caller is told to wait instead
+	pending, err := u.otpRepo.FindLatest(user.ID, domain.OTPPurposePhoneChange)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if pending != nil && pending.Destination != req.NewPhone && pending.ConsumedAt == nil && pending.ExpiresAt.After(now) {
+		if pending.Attempts >= domain.OTPMaxAttempts {
+			return nil, domain.ErrOTPAttemptsExceeded
+		}
+		if now.Sub(pending.CreatedAt) < domain.OTPResendInterval {
+			return nil, domain.ErrOTPResendTooSoon
+		}
+	}
 
-	if err := u.userRepo.Update(user); err != nil {
+	if err := u.sendOTP(user, domain.OTPPurposePhoneChange, domain.NotificationSMS, req.NewPhone, "Your Shop Ops code to confirm this phone number is %s. It expires in 10 minutes."); err != nil {
 		return nil, err
 	}
 